@@ -0,0 +1,169 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/gompdf/gompdf"
+)
+
+const batchUsage = `Usage: gompdf batch [flags] pattern...
+
+Converts the HTML and Markdown (.md) files matching the glob patterns, such
+as 'reports/*.html', in parallel. Each file is converted to a PDF of the same name in the output
+directory, or next to it without one. The exit code is 1 when any file
+failed to convert.
+
+Flags:
+`
+
+// batchJob is the conversion of one file of a batch
+type batchJob struct {
+	input  string
+	output string
+	err    error
+}
+
+// runBatch runs the batch subcommand and returns the number of files that
+// failed to convert
+func runBatch(args []string) (int, error) {
+	fs := flag.NewFlagSet("batch", flag.ExitOnError)
+	fs.Usage = func() {
+		fmt.Fprint(fs.Output(), batchUsage)
+		fs.PrintDefaults()
+	}
+	var (
+		outDir     string
+		jobs       int
+		verbose    bool
+		configPath string
+		conversion conversionOptions
+	)
+	fs.StringVar(&outDir, "output", "", "Directory to write the PDF files to")
+	fs.StringVar(&outDir, "o", "", "Shorthand for -output")
+	fs.IntVar(&jobs, "jobs", runtime.NumCPU(), "Number of files converted at once")
+	fs.IntVar(&jobs, "j", runtime.NumCPU(), "Shorthand for -jobs")
+	fs.BoolVar(&verbose, "verbose", false, "Print every file converted")
+	fs.BoolVar(&verbose, "v", false, "Shorthand for -verbose")
+	fs.StringVar(&configPath, "config", "", configUsage)
+	addConversionFlags(fs, &conversion)
+
+	// Patterns and flags may come in any order
+	var patterns []string
+	for {
+		if err := fs.Parse(args); err != nil {
+			return 0, err
+		}
+		if fs.NArg() == 0 {
+			break
+		}
+		patterns = append(patterns, fs.Arg(0))
+		args = fs.Args()[1:]
+	}
+	if err := applyEnv(fs); err != nil {
+		return 0, err
+	}
+	if len(patterns) == 0 {
+		fs.Usage()
+		return 0, fmt.Errorf("no input files given")
+	}
+	options, err := resolveOptions(configPath, conversion)
+	if err != nil {
+		return 0, err
+	}
+
+	batch, err := planBatch(patterns, outDir)
+	if err != nil {
+		return 0, err
+	}
+	if outDir != "" {
+		if err := os.MkdirAll(outDir, 0o755); err != nil {
+			return 0, err
+		}
+	}
+
+	if jobs < 1 {
+		jobs = 1
+	}
+	// The conversions share one converter, and with it the parsed user agent
+	// stylesheet and the fonts
+	converter := gompdf.NewWithOptions(options)
+	queue := make(chan *batchJob)
+	var wg sync.WaitGroup
+	for i := 0; i < min(jobs, len(batch)); i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for job := range queue {
+				job.err = convertFile(converter, job.input, job.output)
+				if verbose && job.err == nil {
+					fmt.Printf("%s -> %s\n", job.input, job.output)
+				}
+			}
+		}()
+	}
+	for _, job := range batch {
+		queue <- job
+	}
+	close(queue)
+	wg.Wait()
+
+	failed := 0
+	for _, job := range batch {
+		if job.err != nil {
+			failed++
+			fmt.Fprintf(os.Stderr, "%s: %v\n", job.input, job.err)
+		}
+	}
+	fmt.Printf("Converted %d of %d files", len(batch)-failed, len(batch))
+	if failed > 0 {
+		fmt.Printf(", %d failed", failed)
+	}
+	fmt.Println()
+	return failed, nil
+}
+
+// planBatch expands the patterns into the files to convert, in order of
+// name, and the PDF each is written to
+func planBatch(patterns []string, outDir string) ([]*batchJob, error) {
+	seen := make(map[string]bool)
+	var inputs []string
+	for _, pattern := range patterns {
+		matches, err := filepath.Glob(pattern)
+		if err != nil {
+			return nil, fmt.Errorf("invalid pattern %q: %v", pattern, err)
+		}
+		if len(matches) == 0 {
+			return nil, fmt.Errorf("no files match %q", pattern)
+		}
+		for _, m := range matches {
+			if info, err := os.Stat(m); err != nil || info.IsDir() || seen[m] {
+				continue
+			}
+			seen[m] = true
+			inputs = append(inputs, m)
+		}
+	}
+	sort.Strings(inputs)
+
+	var batch []*batchJob
+	outputs := make(map[string]string)
+	for _, input := range inputs {
+		output := strings.TrimSuffix(input, filepath.Ext(input)) + ".pdf"
+		if outDir != "" {
+			output = filepath.Join(outDir, filepath.Base(output))
+		}
+		if other, ok := outputs[output]; ok {
+			return nil, fmt.Errorf("%s and %s would both be written to %s", other, input, output)
+		}
+		outputs[output] = input
+		batch = append(batch, &batchJob{input: input, output: output})
+	}
+	return batch, nil
+}
@@ -0,0 +1,82 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+
+	"github.com/gompdf/gompdf"
+	"gopkg.in/yaml.v3"
+)
+
+// configNames are the config files looked up in the working directory when
+// no -config flag is given, in order
+var configNames = []string{"gompdf.yaml", "gompdf.yml", "gompdf.json"}
+
+// configUsage is the usage of the -config flag
+const configUsage = "YAML or JSON file of conversion options, which flags override (default gompdf.yaml, gompdf.yml or gompdf.json when present)"
+
+// loadConfig reads conversion options from a YAML or JSON config file.
+// Relative resource paths and font directories are resolved against the
+// directory of the file, so that it works from any working directory.
+func loadConfig(path string) (conversionOptions, error) {
+	var c conversionOptions
+	f, err := os.Open(path)
+	if err != nil {
+		return c, err
+	}
+	defer f.Close()
+
+	// JSON is a subset of YAML, so one decoder reads both
+	dec := yaml.NewDecoder(f)
+	dec.KnownFields(true)
+	if err := dec.Decode(&c); err != nil && !errors.Is(err, io.EOF) {
+		return c, fmt.Errorf("invalid config file %s: %v", path, err)
+	}
+
+	dir := filepath.Dir(path)
+	for _, paths := range [][]string{c.ResourcePaths, c.FontDirectories} {
+		for i, p := range paths {
+			if !filepath.IsAbs(p) {
+				paths[i] = filepath.Join(dir, p)
+			}
+		}
+	}
+	return c, nil
+}
+
+// findConfig returns the path of the config file in the working directory,
+// "" when there is none
+func findConfig() string {
+	for _, name := range configNames {
+		if info, err := os.Stat(name); err == nil && !info.IsDir() {
+			return name
+		}
+	}
+	return ""
+}
+
+// resolveOptions returns the conversion options of the config file at
+// configPath, or else of the one found in the working directory, with those
+// of flags and environment variables applied over them
+func resolveOptions(configPath string, flags conversionOptions) (gompdf.Options, error) {
+	options := gompdf.DefaultOptions()
+	if configPath == "" {
+		configPath = findConfig()
+	}
+	if configPath != "" {
+		config, err := loadConfig(configPath)
+		if err != nil {
+			return options, err
+		}
+		if err := config.apply(&options); err != nil {
+			return options, fmt.Errorf("config file %s: %v", configPath, err)
+		}
+	}
+	if err := flags.apply(&options); err != nil {
+		return options, err
+	}
+	return options, nil
+}
@@ -0,0 +1,115 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// stringList is a flag that may be repeated, collecting its values
+type stringList []string
+
+func (l *stringList) String() string { return strings.Join(*l, string(filepath.ListSeparator)) }
+
+func (l *stringList) Set(v string) error {
+	*l = append(*l, v)
+	return nil
+}
+
+// marginsFlag sets page margins from one to four lengths in points,
+// separated by commas or spaces, in the order of the CSS margin property:
+// top, right, bottom, left
+type marginsFlag struct{ m **marginOptions }
+
+func (f marginsFlag) String() string {
+	if f.m == nil || *f.m == nil {
+		return ""
+	}
+	m := *f.m
+	return fmt.Sprintf("%g,%g,%g,%g", m.Top, m.Right, m.Bottom, m.Left)
+}
+
+func (f marginsFlag) Set(v string) error {
+	fields := strings.FieldsFunc(v, func(r rune) bool { return r == ',' || r == ' ' })
+	if len(fields) == 0 || len(fields) > 4 {
+		return fmt.Errorf("expected one to four margins, got %q", v)
+	}
+	var values [4]float64
+	for i, field := range fields {
+		n, err := strconv.ParseFloat(field, 64)
+		if err != nil || n < 0 {
+			return fmt.Errorf("invalid margin %q", field)
+		}
+		values[i] = n
+	}
+	switch len(fields) {
+	case 1:
+		values[1], values[2], values[3] = values[0], values[0], values[0]
+	case 2:
+		values[2], values[3] = values[0], values[1]
+	case 3:
+		values[3] = values[1]
+	}
+	*f.m = &marginOptions{Top: values[0], Right: values[1], Bottom: values[2], Left: values[3]}
+	return nil
+}
+
+// addConversionFlags defines the flags setting conversion options on fs
+func addConversionFlags(fs *flag.FlagSet, c *conversionOptions) {
+	fs.StringVar(&c.PageSize, "page-size", "", "Page size: A0 to A6, Letter or Legal (default A4)")
+	fs.Float64Var(&c.PageWidth, "page-width", 0, "Page width in points, overriding -page-size")
+	fs.Float64Var(&c.PageHeight, "page-height", 0, "Page height in points, overriding -page-size")
+	fs.StringVar(&c.Orientation, "orientation", "", "Page orientation: portrait or landscape")
+	fs.Var(marginsFlag{&c.Margins}, "margins", "Page margins in points: one to four values, top, right, bottom, left (default 72)")
+	fs.StringVar(&c.Title, "title", "", "Document title")
+	fs.StringVar(&c.Author, "author", "", "Document author")
+	fs.StringVar(&c.Subject, "subject", "", "Document subject")
+	fs.StringVar(&c.Keywords, "keywords", "", "Document keywords")
+	fs.StringVar(&c.MediaType, "media-type", "", "Media type @media rules are evaluated for (default print)")
+	fs.IntVar(&c.TableOfContents, "toc", 0, "Insert a table of contents listing headings down to this level")
+	fs.StringVar(&c.Header, "header", "", "HTML shown in the top margin of every page; elements of class pageNumber and totalPages show page numbers")
+	fs.StringVar(&c.Footer, "footer", "", "HTML shown in the bottom margin of every page")
+	fs.IntVar(&c.CompressionLevel, "compression-level", 0, "Compression level of the streams of the document: 1 (fastest) to 9 (smallest), or -1 for none")
+	fs.Float64Var(&c.MaxImageDPI, "max-image-dpi", 0, "Scale images down to at most this many pixels per inch, such as 150")
+	fs.IntVar(&c.JPEGQuality, "jpeg-quality", 0, "Compress JPEG images again at this quality, 1 to 100")
+	fs.StringVar(&c.PDFVersion, "pdf-version", "", "Version of PDF to write the document as: 1.3 to 1.7 or 2.0")
+	fs.BoolVar(&c.Reproducible, "reproducible", false, "Write the same bytes for the same input, dated by SOURCE_DATE_EPOCH")
+	fs.BoolVar(&c.Streaming, "streaming", false, "Lay out, paginate and draw the document a few pages at a time, for very long documents")
+	fs.Var((*stringList)(&c.ResourcePaths), "resource-path", "Directory to look up resources in; may be repeated")
+	fs.Var((*stringList)(&c.FontDirectories), "font-dir", "Directory to load fonts from; may be repeated")
+}
+
+// applyEnv sets the flags of fs that are not given on the command line from
+// environment variables named after them, such as GOMPDF_PAGE_SIZE for
+// -page-size. Repeatable flags take lists separated like PATH.
+func applyEnv(fs *flag.FlagSet) error {
+	given := make(map[string]bool)
+	fs.Visit(func(f *flag.Flag) { given[f.Name] = true })
+	var err error
+	fs.VisitAll(func(f *flag.Flag) {
+		name := envName(f.Name)
+		v, ok := os.LookupEnv(name)
+		if given[f.Name] || !ok || err != nil {
+			return
+		}
+		values := []string{v}
+		if _, list := f.Value.(*stringList); list {
+			values = filepath.SplitList(v)
+		}
+		for _, v := range values {
+			if setErr := fs.Set(f.Name, v); setErr != nil {
+				err = fmt.Errorf("invalid value %q for %s: %v", v, name, setErr)
+				return
+			}
+		}
+	})
+	return err
+}
+
+// envName returns the environment variable equivalent of a flag
+func envName(flagName string) string {
+	return "GOMPDF_" + strings.ToUpper(strings.ReplaceAll(flagName, "-", "_"))
+}
@@ -5,22 +5,63 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
+	"strings"
 
 	"github.com/gompdf/gompdf"
 )
 
 func main() {
+	if len(os.Args) > 1 && os.Args[1] == "serve" {
+		if err := runServe(os.Args[2:]); err != nil {
+			fmt.Printf("Error: %v\n", err)
+			os.Exit(1)
+		}
+		return
+	}
+	if len(os.Args) > 1 && os.Args[1] == "batch" {
+		failed, err := runBatch(os.Args[2:])
+		if err != nil {
+			fmt.Printf("Error: %v\n", err)
+			os.Exit(2)
+		}
+		if failed > 0 {
+			os.Exit(1)
+		}
+		return
+	}
+
 	var (
 		inputFile  string
 		outputFile string
 		verbose    bool
+		strict     bool
+		configPath string
+		conversion conversionOptions
 	)
 
-	flag.StringVar(&inputFile, "input", "", "Input HTML file path")
+	flag.StringVar(&inputFile, "input", "", "Input HTML or Markdown (.md) file path")
+	flag.StringVar(&inputFile, "i", "", "Shorthand for -input")
 	flag.StringVar(&outputFile, "output", "", "Output PDF file path")
+	flag.StringVar(&outputFile, "o", "", "Shorthand for -output")
 	flag.BoolVar(&verbose, "verbose", false, "Enable verbose logging")
+	flag.BoolVar(&verbose, "v", false, "Shorthand for -verbose")
+	flag.BoolVar(&strict, "strict", false, "Print the warnings of the conversion and exit with status 3 when there are any")
+	flag.StringVar(&configPath, "config", "", configUsage)
+	addConversionFlags(flag.CommandLine, &conversion)
+	flag.Usage = func() {
+		fmt.Fprintf(flag.CommandLine.Output(), "Usage: gompdf [flags] [input.html|input.md]\n       gompdf batch [flags] pattern...\n       gompdf serve [flags]\n\n")
+		fmt.Fprintf(flag.CommandLine.Output(), "Every flag can also be set with an environment variable, such as\n%s for -page-size.\n\nFlags:\n", envName("page-size"))
+		flag.PrintDefaults()
+	}
 	flag.Parse()
+	if err := applyEnv(flag.CommandLine); err != nil {
+		fmt.Printf("Error: %v\n", err)
+		os.Exit(1)
+	}
 
+	if inputFile == "" {
+		inputFile = flag.Arg(0)
+	}
 	if inputFile == "" {
 		fmt.Println("Error: input file is required")
 		flag.Usage()
@@ -32,13 +73,22 @@ func main() {
 		outputFile = inputFile[:len(inputFile)-len(ext)] + ".pdf"
 	}
 
-	converter := gompdf.New()
+	options, err := resolveOptions(configPath, conversion)
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		os.Exit(1)
+	}
+	converter := gompdf.NewWithOptions(options)
 
 	if verbose {
 		converter = converter.SetDebug(true)
 	}
-	err := converter.ConvertFile(inputFile, outputFile)
-	if err != nil {
+	var warnings *warningCounter
+	if strict {
+		warnings = &warningCounter{Logger: gompdf.NewWriterLogger(os.Stderr), debug: verbose}
+		converter = converter.SetLogger(warnings)
+	}
+	if err := convertFile(converter, inputFile, outputFile); err != nil {
 		fmt.Printf("Error converting file: %v\n", err)
 		os.Exit(1)
 	}
@@ -46,4 +96,37 @@ func main() {
 	if verbose {
 		fmt.Printf("Successfully converted %s to %s\n", inputFile, outputFile)
 	}
+	if warnings != nil && warnings.count > 0 {
+		fmt.Fprintf(os.Stderr, "%d warning(s)\n", warnings.count)
+		os.Exit(3)
+	}
+}
+
+// warningCounter prints the warnings of a conversion with their code and
+// counts them. Debug output is printed only when debug is set.
+type warningCounter struct {
+	gompdf.Logger
+	debug bool
+	count int
+}
+
+func (w *warningCounter) Debugf(format string, args ...any) {
+	if w.debug {
+		w.Logger.Debugf(format, args...)
+	}
+}
+
+func (w *warningCounter) Warn(warning gompdf.Warning) {
+	w.count++
+	w.Logger.Warnf("[%s] %s", warning.Code, warning)
+}
+
+// convertFile converts an HTML file, or a Markdown file when its extension
+// is .md or .markdown, to a PDF file
+func convertFile(converter *gompdf.Converter, inputPath, outputPath string) error {
+	switch strings.ToLower(filepath.Ext(inputPath)) {
+	case ".md", ".markdown":
+		return converter.ConvertMarkdownFile(inputPath, outputPath)
+	}
+	return converter.ConvertFile(inputPath, outputPath)
 }
@@ -0,0 +1,173 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/gompdf/gompdf"
+)
+
+// conversionOptions are the conversion settings the CLI accepts, such as the
+// JSON options of a request to the server or the contents of a config file
+type conversionOptions struct {
+	// PageSize is a preset: A0 to A6, Letter or Legal. PageWidth and
+	// PageHeight, in points, override it.
+	PageSize    string  `json:"pageSize,omitempty" yaml:"pageSize,omitempty"`
+	PageWidth   float64 `json:"pageWidth,omitempty" yaml:"pageWidth,omitempty"`
+	PageHeight  float64 `json:"pageHeight,omitempty" yaml:"pageHeight,omitempty"`
+	Orientation string  `json:"orientation,omitempty" yaml:"orientation,omitempty"`
+	// Margins are in points
+	Margins *marginOptions `json:"margins,omitempty" yaml:"margins,omitempty"`
+
+	Title    string `json:"title,omitempty" yaml:"title,omitempty"`
+	Author   string `json:"author,omitempty" yaml:"author,omitempty"`
+	Subject  string `json:"subject,omitempty" yaml:"subject,omitempty"`
+	Keywords string `json:"keywords,omitempty" yaml:"keywords,omitempty"`
+
+	MediaType string `json:"mediaType,omitempty" yaml:"mediaType,omitempty"`
+	// TableOfContents inserts a table of contents listing headings down to
+	// this level; 0 inserts none
+	TableOfContents    int   `json:"tableOfContents,omitempty" yaml:"tableOfContents,omitempty"`
+	RepeatTableHeaders *bool `json:"repeatTableHeaders,omitempty" yaml:"repeatTableHeaders,omitempty"`
+	// MinTableRows is the fewest body rows a table keeps with its header at
+	// the bottom of a page
+	MinTableRows *int `json:"minTableRows,omitempty" yaml:"minTableRows,omitempty"`
+
+	// Header and Footer are HTML templates shown in the top and bottom
+	// margin of every page
+	Header string `json:"header,omitempty" yaml:"header,omitempty"`
+	Footer string `json:"footer,omitempty" yaml:"footer,omitempty"`
+
+	// CompressionLevel is the zlib level of the streams of the document,
+	// 1 to 9; -1 leaves the content of pages uncompressed. MaxImageDPI and
+	// JPEGQuality scale images down and compress JPEG images again.
+	CompressionLevel int     `json:"compressionLevel,omitempty" yaml:"compressionLevel,omitempty"`
+	MaxImageDPI      float64 `json:"maxImageDPI,omitempty" yaml:"maxImageDPI,omitempty"`
+	JPEGQuality      int     `json:"jpegQuality,omitempty" yaml:"jpegQuality,omitempty"`
+	SubsetFonts      *bool   `json:"subsetFonts,omitempty" yaml:"subsetFonts,omitempty"`
+
+	// PDFVersion is the version of PDF the document is written as, such as
+	// 1.4
+	PDFVersion string `json:"pdfVersion,omitempty" yaml:"pdfVersion,omitempty"`
+
+	// Reproducible writes the same bytes for the same input, dating the
+	// document by SOURCE_DATE_EPOCH
+	Reproducible bool `json:"reproducible,omitempty" yaml:"reproducible,omitempty"`
+
+	// Streaming lays out, paginates and draws the document a few pages at
+	// a time
+	Streaming bool `json:"streaming,omitempty" yaml:"streaming,omitempty"`
+
+	// ResourcePaths and FontDirectories are directories on the machine
+	// converting
+	ResourcePaths   []string `json:"resourcePaths,omitempty" yaml:"resourcePaths,omitempty"`
+	FontDirectories []string `json:"fontDirectories,omitempty" yaml:"fontDirectories,omitempty"`
+}
+
+// marginOptions are page margins in points
+type marginOptions struct {
+	Top    float64 `json:"top" yaml:"top"`
+	Right  float64 `json:"right" yaml:"right"`
+	Bottom float64 `json:"bottom" yaml:"bottom"`
+	Left   float64 `json:"left" yaml:"left"`
+}
+
+// pageSizes are the page size presets by lower case name, in points
+var pageSizes = map[string][2]float64{
+	"a0":     {gompdf.PageSizeA0Width, gompdf.PageSizeA0Height},
+	"a1":     {gompdf.PageSizeA1Width, gompdf.PageSizeA1Height},
+	"a2":     {gompdf.PageSizeA2Width, gompdf.PageSizeA2Height},
+	"a3":     {gompdf.PageSizeA3Width, gompdf.PageSizeA3Height},
+	"a4":     {gompdf.PageSizeA4Width, gompdf.PageSizeA4Height},
+	"a5":     {gompdf.PageSizeA5Width, gompdf.PageSizeA5Height},
+	"a6":     {gompdf.PageSizeA6Width, gompdf.PageSizeA6Height},
+	"letter": {gompdf.PageSizeLetterWidth, gompdf.PageSizeLetterHeight},
+	"legal":  {gompdf.PageSizeLegalWidth, gompdf.PageSizeLegalHeight},
+}
+
+// apply sets the options that are given on o
+func (c *conversionOptions) apply(o *gompdf.Options) error {
+	if c.PageSize != "" {
+		size, ok := pageSizes[strings.ToLower(c.PageSize)]
+		if !ok {
+			return fmt.Errorf("unknown page size %q", c.PageSize)
+		}
+		o.PageWidth, o.PageHeight = size[0], size[1]
+	}
+	if c.PageWidth > 0 {
+		o.PageWidth = c.PageWidth
+	}
+	if c.PageHeight > 0 {
+		o.PageHeight = c.PageHeight
+	}
+	switch orientation := gompdf.PageOrientation(strings.ToLower(c.Orientation)); orientation {
+	case "":
+	case gompdf.PageOrientationPortrait, gompdf.PageOrientationLandscape:
+		o.PageOrientation = orientation
+	default:
+		return fmt.Errorf("unknown orientation %q", c.Orientation)
+	}
+	if m := c.Margins; m != nil {
+		o.MarginTop, o.MarginRight, o.MarginBottom, o.MarginLeft = m.Top, m.Right, m.Bottom, m.Left
+	}
+
+	for _, s := range []struct {
+		value string
+		field *string
+	}{
+		{c.Title, &o.Title},
+		{c.Author, &o.Author},
+		{c.Subject, &o.Subject},
+		{c.Keywords, &o.Keywords},
+		{c.MediaType, &o.MediaType},
+		{c.Header, &o.HeaderTemplate},
+		{c.Footer, &o.FooterTemplate},
+	} {
+		if s.value != "" {
+			*s.field = s.value
+		}
+	}
+	if c.TableOfContents > 0 {
+		o.TableOfContents = true
+		o.TOCDepth = c.TableOfContents
+	}
+	if c.RepeatTableHeaders != nil {
+		o.RepeatTableHeaders = *c.RepeatTableHeaders
+	}
+	if c.MinTableRows != nil {
+		if *c.MinTableRows < 0 {
+			return fmt.Errorf("invalid minimum number of table rows %d", *c.MinTableRows)
+		}
+		o.MinTableRows = *c.MinTableRows
+	}
+	switch {
+	case c.CompressionLevel < -1 || c.CompressionLevel > 9:
+		return fmt.Errorf("invalid compression level %d", c.CompressionLevel)
+	case c.JPEGQuality < 0 || c.JPEGQuality > 100:
+		return fmt.Errorf("invalid JPEG quality %d", c.JPEGQuality)
+	}
+	if c.CompressionLevel != 0 {
+		o.CompressionLevel = gompdf.CompressionLevel(c.CompressionLevel)
+	}
+	if c.MaxImageDPI > 0 {
+		o.MaxImageDPI = c.MaxImageDPI
+	}
+	if c.JPEGQuality > 0 {
+		o.JPEGQuality = c.JPEGQuality
+	}
+	if c.SubsetFonts != nil {
+		o.SubsetFonts = *c.SubsetFonts
+	}
+	if c.PDFVersion != "" {
+		o.PDFVersion = gompdf.PDFVersion(c.PDFVersion)
+	}
+	if c.Reproducible {
+		o.Reproducible = true
+	}
+	if c.Streaming {
+		o.Streaming = true
+	}
+	o.ResourcePaths = append(o.ResourcePaths, c.ResourcePaths...)
+	o.FontDirectories = append(o.FontDirectories, c.FontDirectories...)
+	return nil
+}
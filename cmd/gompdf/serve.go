@@ -0,0 +1,344 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"mime"
+	"mime/multipart"
+	"net/http"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/gompdf/gompdf"
+)
+
+const serveUsage = `Usage: gompdf serve [flags]
+
+Runs an HTTP server converting HTML to PDF.
+
+  POST /convert   converts the HTML of the request and responds with the PDF
+  GET  /healthz   responds with 200 OK while the server is up
+
+The request body of /convert is one of:
+
+  text/html            the document; options may be given as JSON in the
+                       "options" query parameter
+  application/json     {"html": "...", "options": {...}}
+  multipart/form-data  the document in the "html" field or file, options as
+                       JSON in the "options" field, and any other files as
+                       assets the document refers to by their file name,
+                       such as <img src="logo.png">
+
+Options are pageSize (A0-A6, Letter, Legal), pageWidth and pageHeight in
+points, orientation (portrait, landscape), margins {top, right, bottom, left}
+in points, title, author, subject, keywords, mediaType, tableOfContents
+(heading depth), repeatTableHeaders, minTableRows, and header and footer HTML
+shown in the page margins.
+
+Documents load http, https and data URLs and the files of their own request,
+and no other files of the server unless -allow-local-files is given. They may
+not load resources from loopback, private or link-local addresses unless
+-allow-private-networks is given.
+
+Flags:
+`
+
+// server converts the HTML of HTTP requests to PDF
+type server struct {
+	// slots holds a token for every conversion running
+	slots   chan struct{}
+	timeout time.Duration
+	maxBody int64
+	logger  *log.Logger
+	// policy restricts the resources of the documents of requests
+	policy gompdf.ResourcePolicy
+	// localFiles lets documents read any file of the server rather than
+	// the files of their request only
+	localFiles bool
+}
+
+// convertRequest is the JSON body of a request to /convert
+type convertRequest struct {
+	HTML    string            `json:"html"`
+	Options conversionOptions `json:"options"`
+}
+
+// httpError is an error answered with an HTTP status code
+type httpError struct {
+	status int
+	msg    string
+}
+
+func (e *httpError) Error() string { return e.msg }
+
+// runServe runs the serve subcommand until it is interrupted
+func runServe(args []string) error {
+	fs := flag.NewFlagSet("serve", flag.ExitOnError)
+	fs.Usage = func() {
+		fmt.Fprint(fs.Output(), serveUsage)
+		fs.PrintDefaults()
+	}
+	addr := fs.String("addr", ":8080", "Address to listen on")
+	concurrency := fs.Int("concurrency", runtime.NumCPU(), "Maximum number of conversions running at once")
+	timeout := fs.Duration("timeout", time.Minute, "Maximum time a conversion may take, including waiting for a free slot")
+	maxBody := fs.Int64("max-body", 32<<20, "Maximum size of a request in bytes")
+	maxResource := fs.Int64("max-resource-size", 0, "Maximum size in bytes of a resource a document loads; 0 for no limit")
+	allowPrivate := fs.Bool("allow-private-networks", false, "Let documents load resources from loopback, private and link-local addresses")
+	allowLocal := fs.Bool("allow-local-files", false, "Let documents load any file of the server, not only the files of their request")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if *concurrency < 1 {
+		*concurrency = 1
+	}
+
+	s := &server{
+		slots:   make(chan struct{}, *concurrency),
+		timeout: *timeout,
+		maxBody: *maxBody,
+		logger:  log.New(os.Stderr, "gompdf: ", log.LstdFlags),
+		policy: gompdf.ResourcePolicy{
+			BlockPrivateNetworks: !*allowPrivate,
+			MaxSize:              *maxResource,
+		},
+		localFiles: *allowLocal,
+	}
+	if !s.localFiles {
+		// Local files are those of the request, read from its directory
+		// (see handleConvert)
+		s.policy.AllowedSchemes = []string{"http", "https", "data", "file"}
+	}
+	mux := http.NewServeMux()
+	mux.HandleFunc("/convert", s.handleConvert)
+	mux.HandleFunc("/healthz", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintln(w, "ok")
+	})
+	srv := &http.Server{
+		Addr:              *addr,
+		Handler:           mux,
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	errc := make(chan error, 1)
+	go func() {
+		s.logger.Printf("listening on %s", *addr)
+		errc <- srv.ListenAndServe()
+	}()
+	select {
+	case err := <-errc:
+		return err
+	case <-ctx.Done():
+	}
+
+	// Let the conversions running finish
+	s.logger.Printf("shutting down")
+	shutdown, cancel := context.WithTimeout(context.Background(), *timeout)
+	defer cancel()
+	return srv.Shutdown(shutdown)
+}
+
+// handleConvert answers a request to /convert with the PDF of its HTML
+func (s *server) handleConvert(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.Header().Set("Allow", http.MethodPost)
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	ctx, cancel := context.WithTimeout(r.Context(), s.timeout)
+	defer cancel()
+	r.Body = http.MaxBytesReader(w, r.Body, s.maxBody)
+
+	dir, err := os.MkdirTemp("", "gompdf-serve-*")
+	if err != nil {
+		s.fail(w, err)
+		return
+	}
+	defer os.RemoveAll(dir)
+
+	input := filepath.Join(dir, "index.html")
+	opts, err := readConvertRequest(r, dir, input)
+	if err != nil {
+		s.fail(w, err)
+		return
+	}
+	// Requests may not point the conversion at directories of the server
+	opts.ResourcePaths, opts.FontDirectories = nil, nil
+	options := gompdf.DefaultOptions()
+	if err := opts.apply(&options); err != nil {
+		s.fail(w, &httpError{http.StatusBadRequest, err.Error()})
+		return
+	}
+	options.ResourcePolicy = &s.policy
+	source := input
+	if !s.localFiles {
+		// The document and its local resources are read from the directory
+		// of the request, whose root absolute paths stand for, so that
+		// documents cannot reach the other files of the server
+		options.ResourceFS = os.DirFS(dir)
+		source = "/" + filepath.Base(input)
+	}
+
+	select {
+	case s.slots <- struct{}{}:
+		defer func() { <-s.slots }()
+	case <-ctx.Done():
+		s.fail(w, &httpError{http.StatusServiceUnavailable, "too many conversions running"})
+		return
+	}
+	output := filepath.Join(dir, "output.pdf")
+	if err := gompdf.NewWithOptions(options).ConvertFileContext(ctx, source, output); err != nil {
+		s.fail(w, err)
+		return
+	}
+
+	pdf, err := os.Open(output)
+	if err != nil {
+		s.fail(w, err)
+		return
+	}
+	defer pdf.Close()
+	if info, err := pdf.Stat(); err == nil {
+		w.Header().Set("Content-Length", strconv.FormatInt(info.Size(), 10))
+	}
+	w.Header().Set("Content-Type", "application/pdf")
+	if _, err := io.Copy(w, pdf); err != nil {
+		s.logger.Printf("writing response: %v", err)
+	}
+}
+
+// readConvertRequest writes the HTML of a request to input and its assets
+// to dir, and returns its options
+func readConvertRequest(r *http.Request, dir, input string) (conversionOptions, error) {
+	var opts conversionOptions
+	mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	switch mediaType {
+	case "application/json":
+		var req convertRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			return opts, badRequest("invalid JSON: %v", err)
+		}
+		return req.Options, os.WriteFile(input, []byte(req.HTML), 0o600)
+
+	case "multipart/form-data":
+		mr, err := r.MultipartReader()
+		if err != nil {
+			return opts, badRequest("invalid multipart body: %v", err)
+		}
+		found := false
+		for {
+			part, err := mr.NextPart()
+			if err == io.EOF {
+				break
+			}
+			if err != nil {
+				return opts, badRequest("invalid multipart body: %v", err)
+			}
+			switch name := part.FormName(); {
+			case name == "html":
+				found = true
+				err = writePart(part, input)
+			case name == "options":
+				err = json.NewDecoder(part).Decode(&opts)
+				if err != nil {
+					err = badRequest("invalid options: %v", err)
+				}
+			case part.FileName() != "":
+				err = saveAsset(part, dir)
+			}
+			if err != nil {
+				return opts, err
+			}
+		}
+		if !found {
+			return opts, badRequest("missing html field")
+		}
+		return opts, nil
+
+	default:
+		if q := r.URL.Query().Get("options"); q != "" {
+			if err := json.Unmarshal([]byte(q), &opts); err != nil {
+				return opts, badRequest("invalid options: %v", err)
+			}
+		}
+		f, err := os.Create(input)
+		if err != nil {
+			return opts, err
+		}
+		defer f.Close()
+		_, err = io.Copy(f, r.Body)
+		return opts, err
+	}
+}
+
+// saveAsset stores a file of a multipart request in dir under its file
+// name, which may name subdirectories but must stay inside dir
+func saveAsset(part *multipart.Part, dir string) error {
+	// FileName drops directories; the raw name of the part keeps them
+	_, params, _ := mime.ParseMediaType(part.Header.Get("Content-Disposition"))
+	name := filepath.FromSlash(params["filename"])
+	if name == "" {
+		name = part.FileName()
+	}
+	name = filepath.Clean(name)
+	if filepath.IsAbs(name) || name == "." || name == ".." || strings.HasPrefix(name, ".."+string(filepath.Separator)) || name == "index.html" || name == "output.pdf" {
+		return badRequest("invalid asset name %q", params["filename"])
+	}
+	path := filepath.Join(dir, name)
+	if err := os.MkdirAll(filepath.Dir(path), 0o700); err != nil {
+		return err
+	}
+	return writePart(part, path)
+}
+
+// writePart writes the content of a multipart part to a file
+func writePart(part *multipart.Part, path string) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(f, part); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// badRequest returns an error answered with 400 Bad Request
+func badRequest(format string, args ...any) error {
+	return &httpError{http.StatusBadRequest, fmt.Sprintf(format, args...)}
+}
+
+// fail answers a request with the status code of err
+func (s *server) fail(w http.ResponseWriter, err error) {
+	status := http.StatusInternalServerError
+	var he *httpError
+	var tooLarge *http.MaxBytesError
+	switch {
+	case errors.As(err, &he):
+		status = he.status
+	case errors.As(err, &tooLarge):
+		status = http.StatusRequestEntityTooLarge
+	case errors.Is(err, context.DeadlineExceeded):
+		status = http.StatusGatewayTimeout
+	case errors.Is(err, context.Canceled):
+		// The client went away; nobody reads the answer
+		return
+	}
+	if status == http.StatusInternalServerError {
+		s.logger.Printf("conversion failed: %v", err)
+	}
+	http.Error(w, err.Error(), status)
+}
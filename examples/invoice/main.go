@@ -1,11 +1,10 @@
 package main
 
 import (
+	"encoding/xml"
 	"fmt"
 	"html/template"
 	"log"
-	"os"
-	"path/filepath"
 	"time"
 
 	"github.com/gompdf/gompdf"
@@ -78,29 +77,29 @@ func main() {
 		},
 	}
 
-	tmpl, err := template.New("invoice.tmpl.html").Funcs(funcMap).ParseFiles("invoice.tmpl.html")
+	// Embed the invoice data as XML so that accounting software can read
+	// the invoice without parsing the PDF
+	invoiceXML, err := xml.MarshalIndent(struct {
+		XMLName xml.Name `xml:"Invoice"`
+		InvoiceData
+	}{InvoiceData: data}, "", "  ")
 	if err != nil {
-		log.Fatalf("parse template: %v", err)
+		log.Fatalf("marshal xml: %v", err)
 	}
 
-	htmlPath := "invoice.html"
-	f, err := os.Create(htmlPath)
-	if err != nil {
-		log.Fatalf("create html: %v", err)
-	}
-	if err := tmpl.Execute(f, data); err != nil {
-		log.Fatalf("execute template: %v", err)
-	}
-	_ = f.Close()
-
-	abs, _ := filepath.Abs(htmlPath)
-
 	opts := gompdf.DefaultOptions()
 	opts.MarginTop, opts.MarginRight, opts.MarginBottom, opts.MarginLeft = 36, 36, 36, 36
+	gompdf.WithAttachment(gompdf.Attachment{
+		Name:         "invoice.xml",
+		Description:  "Invoice " + data.InvoiceNo,
+		MIMEType:     "text/xml",
+		Relationship: gompdf.AttachmentAlternative,
+		Data:         append([]byte(xml.Header), invoiceXML...),
+	})(&opts)
 	conv := gompdf.NewWithOptions(opts)
 
 	out := "invoice.pdf"
-	if err := conv.ConvertFile(abs, out); err != nil {
+	if err := conv.ConvertTemplateFile("invoice.tmpl.html", funcMap, data, out); err != nil {
 		log.Fatalf("convert: %v", err)
 	}
 	fmt.Printf("Wrote %s\n", out)
@@ -2,11 +2,9 @@ package main
 
 import (
 	"fmt"
-	"html/template"
 	"log"
 	"math/rand"
 	"os"
-	"path/filepath"
 	"strings"
 	"time"
 
@@ -100,35 +98,8 @@ func main() {
 		TotalPages:         3,
 	}
 
-	tmpl, err := template.ParseFiles("report.tmpl.html")
-	if err != nil {
-		log.Fatalf("Error parsing template: %v", err)
-	}
-
-	htmlFile, err := os.Create("report.html")
-	if err != nil {
-		log.Fatalf("Error creating HTML file: %v", err)
-	}
-	defer htmlFile.Close()
-
-	err = tmpl.Execute(htmlFile, reportData)
-	if err != nil {
-		log.Fatalf("Error executing template: %v", err)
-	}
-	absPath, err := filepath.Abs("report.html")
-	if err != nil {
-		log.Fatalf("Error getting absolute path: %v", err)
-	}
-
-	fmt.Println("HTML content written to report.html")
-
 	fmt.Println("Starting PDF conversion (tailwind-report)...")
 
-	currentDir, err := os.Getwd()
-	if err != nil {
-		log.Fatalf("Error getting current directory: %v", err)
-	}
-
 	// Create PDF with options
 	options := gompdf.DefaultOptions()
 	options.PageWidth = gompdf.PageSizeLetterWidth
@@ -138,14 +109,13 @@ func main() {
 	options.MarginBottom = 36
 	options.MarginLeft = 36
 	options.MarginRight = 36
-	options.ResourcePaths = []string{currentDir}
 	// options.Debug = true
 	// Enable debug mode for logging but disable box drawing
 
 	converter := gompdf.NewWithOptions(options)
 
 	outputPath := "report.pdf"
-	err = converter.ConvertFile(absPath, outputPath)
+	err := converter.ConvertTemplateFile("report.tmpl.html", nil, reportData, outputPath)
 	if err != nil {
 		log.Fatalf("Error generating PDF: %v", err)
 	}
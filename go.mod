@@ -6,13 +6,15 @@ toolchain go1.24.6
 
 require golang.org/x/net v0.38.0
 
-require codeberg.org/go-pdf/fpdf v0.11.1
-
 require (
-	github.com/srwiley/oksvg v0.0.0-20221011165216-be6e8873101c // indirect
-	github.com/srwiley/rasterx v0.0.0-20220730225603-2ab79fcdd4ef // indirect
-	golang.org/x/image v0.15.0 // indirect
-	golang.org/x/text v0.23.0 // indirect
+	codeberg.org/go-pdf/fpdf v0.11.1
+	github.com/go-text/typesetting v0.2.1
+	github.com/srwiley/oksvg v0.0.0-20221011165216-be6e8873101c
+	github.com/srwiley/rasterx v0.0.0-20220730225603-2ab79fcdd4ef
+	github.com/yuin/goldmark v1.7.8
+	golang.org/x/image v0.15.0
+	golang.org/x/text v0.23.0
+	gopkg.in/yaml.v3 v3.0.1
 )
 
 replace github.com/gompdf/gompdf => /home/henrrius/code/gompdf
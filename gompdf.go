@@ -8,27 +8,134 @@ type Converter = api.Converter
 type Options = api.Options
 type Option = api.Option
 type PageOrientation = api.PageOrientation
+type Logger = api.Logger
+type Watermark = api.Watermark
+type WatermarkPosition = api.WatermarkPosition
+type PageLabel = api.PageLabel
+type PageMargins = api.PageMargins
+type Letterhead = api.Letterhead
+type PDFPages = api.PDFPages
+type CompressionLevel = api.CompressionLevel
+type PDFVersion = api.PDFVersion
+type OutputIntent = api.OutputIntent
+type OutputIntentSubtype = api.OutputIntentSubtype
+type Attachment = api.Attachment
+type AttachmentRelationship = api.AttachmentRelationship
+type ImageFormat = api.ImageFormat
+type ImageOptions = api.ImageOptions
+type MarkdownRenderer = api.MarkdownRenderer
+type MarkdownRendererFunc = api.MarkdownRendererFunc
+type Layout = api.Layout
+type PageLayout = api.PageLayout
+type BoxLayout = api.BoxLayout
+type Result = api.Result
+type Location = api.Location
+type Hooks = api.Hooks
+type Warning = api.Warning
+type WarningCode = api.WarningCode
+type WarningLogger = api.WarningLogger
+type ResourceCache = api.ResourceCache
+type Resource = api.Resource
+type DiskCache = api.DiskCache
+type ResourcePolicy = api.ResourcePolicy
+type Fetcher = api.Fetcher
+type FetcherFunc = api.FetcherFunc
+type ResolveFunc = api.ResolveFunc
 
 func New() *Converter                           { return api.New() }
 func NewWithOptions(options Options) *Converter { return api.NewWithOptions(options) }
 func DefaultOptions() Options                   { return api.DefaultOptions() }
 
 var (
-	WithPageSize            = api.WithPageSize
-	WithMargins             = api.WithMargins
-	WithDPI                 = api.WithDPI
-	WithDebug               = api.WithDebug
-	WithResourcePath        = api.WithResourcePath
-	WithFontDirectory       = api.WithFontDirectory
-	WithTitle               = api.WithTitle
-	WithAuthor              = api.WithAuthor
-	WithSubject             = api.WithSubject
-	WithKeywords            = api.WithKeywords
-	WithUserAgentStylesheet = api.WithUserAgentStylesheet
-	WithPageSizeA4          = api.WithPageSizeA4
-	WithPageSizeLetter      = api.WithPageSizeLetter
-	WithPageSizeLegal       = api.WithPageSizeLegal
-	WithPageOrientation     = api.WithPageOrientation
+	NewSlogLogger   = api.NewSlogLogger
+	NewWriterLogger = api.NewWriterLogger
+	NewDiskCache    = api.NewDiskCache
+)
+
+func NewMarkdownRenderer() MarkdownRenderer { return api.NewMarkdownRenderer() }
+
+const DefaultMarkdownStylesheet = api.DefaultMarkdownStylesheet
+
+var (
+	ErrResourceNotFound   = api.ErrResourceNotFound
+	ErrResourceDenied     = api.ErrResourceDenied
+	ErrCSSParse           = api.ErrCSSParse
+	ErrFontLoad           = api.ErrFontLoad
+	ErrUnsupportedFeature = api.ErrUnsupportedFeature
+	ErrTooManyPages       = api.ErrTooManyPages
+)
+
+const (
+	WarningResource        = api.WarningResource
+	WarningImage           = api.WarningImage
+	WarningFont            = api.WarningFont
+	WarningStylesheet      = api.WarningStylesheet
+	WarningUnknownProperty = api.WarningUnknownProperty
+	WarningOther           = api.WarningOther
+)
+
+var (
+	WithPageSize               = api.WithPageSize
+	WithMargins                = api.WithMargins
+	WithFirstPageMargins       = api.WithFirstPageMargins
+	WithMirrorMargins          = api.WithMirrorMargins
+	WithDPI                    = api.WithDPI
+	WithDebug                  = api.WithDebug
+	WithLogger                 = api.WithLogger
+	WithResourcePath           = api.WithResourcePath
+	WithResourceConcurrency    = api.WithResourceConcurrency
+	WithResourceTimeout        = api.WithResourceTimeout
+	WithResourceCache          = api.WithResourceCache
+	WithHTTPClient             = api.WithHTTPClient
+	WithHTTPTransport          = api.WithHTTPTransport
+	WithHTTPHeader             = api.WithHTTPHeader
+	WithCookie                 = api.WithCookie
+	WithResourcePolicy         = api.WithResourcePolicy
+	WithResourceFS             = api.WithResourceFS
+	WithResolveURL             = api.WithResolveURL
+	WithFetcher                = api.WithFetcher
+	WithFontDirectory          = api.WithFontDirectory
+	WithFallbackFonts          = api.WithFallbackFonts
+	WithFontFallbacks          = api.WithFontFallbacks
+	WithWatermark              = api.WithWatermark
+	WithTextWatermark          = api.WithTextWatermark
+	WithLetterhead             = api.WithLetterhead
+	WithAppendedPages          = api.WithAppendedPages
+	WithPageLabels             = api.WithPageLabels
+	WithPDFVersion             = api.WithPDFVersion
+	WithOutputIntent           = api.WithOutputIntent
+	WithSRGBOutputIntent       = api.WithSRGBOutputIntent
+	WithAttachment             = api.WithAttachment
+	WithTableOfContents        = api.WithTableOfContents
+	WithHeaderTemplate         = api.WithHeaderTemplate
+	WithFooterTemplate         = api.WithFooterTemplate
+	WithBookmarks              = api.WithBookmarks
+	WithFormFields             = api.WithFormFields
+	WithTitle                  = api.WithTitle
+	WithAuthor                 = api.WithAuthor
+	WithSubject                = api.WithSubject
+	WithKeywords               = api.WithKeywords
+	WithCreationDate           = api.WithCreationDate
+	WithReproducibleOutput     = api.WithReproducibleOutput
+	WithUserAgentStylesheet    = api.WithUserAgentStylesheet
+	WithPageSizeA4             = api.WithPageSizeA4
+	WithPageSizeLetter         = api.WithPageSizeLetter
+	WithPageSizeLegal          = api.WithPageSizeLegal
+	WithPageOrientation        = api.WithPageOrientation
+	WithRepeatTableHeaders     = api.WithRepeatTableHeaders
+	WithMinTableRows           = api.WithMinTableRows
+	WithStreaming              = api.WithStreaming
+	WithMaxPages               = api.WithMaxPages
+	WithIgnoreImageOrientation = api.WithIgnoreImageOrientation
+	WithCompressionLevel       = api.WithCompressionLevel
+	WithMaxImageDPI            = api.WithMaxImageDPI
+	WithJPEGQuality            = api.WithJPEGQuality
+	WithSubsetFonts            = api.WithSubsetFonts
+	WithMediaType              = api.WithMediaType
+	WithMaxImportDepth         = api.WithMaxImportDepth
+	WithMarkdownRenderer       = api.WithMarkdownRenderer
+	WithMarkdownStylesheet     = api.WithMarkdownStylesheet
+	WithHooks                  = api.WithHooks
 )
 
 const (
@@ -54,4 +161,37 @@ const (
 
 	PageOrientationPortrait  = api.PageOrientationPortrait
 	PageOrientationLandscape = api.PageOrientationLandscape
+
+	WatermarkCenter      = api.WatermarkCenter
+	WatermarkTop         = api.WatermarkTop
+	WatermarkBottom      = api.WatermarkBottom
+	WatermarkLeft        = api.WatermarkLeft
+	WatermarkRight       = api.WatermarkRight
+	WatermarkTopLeft     = api.WatermarkTopLeft
+	WatermarkTopRight    = api.WatermarkTopRight
+	WatermarkBottomLeft  = api.WatermarkBottomLeft
+	WatermarkBottomRight = api.WatermarkBottomRight
+
+	AttachmentSource      = api.AttachmentSource
+	AttachmentData        = api.AttachmentData
+	AttachmentAlternative = api.AttachmentAlternative
+	AttachmentSupplement  = api.AttachmentSupplement
+	AttachmentUnspecified = api.AttachmentUnspecified
+
+	CompressionDefault = api.CompressionDefault
+	CompressionNone    = api.CompressionNone
+	CompressionBest    = api.CompressionBest
+
+	PDFVersion13 = api.PDFVersion13
+	PDFVersion14 = api.PDFVersion14
+	PDFVersion15 = api.PDFVersion15
+	PDFVersion16 = api.PDFVersion16
+	PDFVersion17 = api.PDFVersion17
+	PDFVersion20 = api.PDFVersion20
+
+	OutputIntentPDFA = api.OutputIntentPDFA
+	OutputIntentPDFX = api.OutputIntentPDFX
+
+	ImageFormatPNG  = api.ImageFormatPNG
+	ImageFormatJPEG = api.ImageFormatJPEG
 )
@@ -0,0 +1,278 @@
+// Package httpserve converts HTML to PDF in HTTP servers. Middleware turns
+// the HTML responses of a handler, such as pages rendered from templates,
+// into PDF, and Handler converts HTML posted to it. Both stream the PDF with
+// its Content-Type and Content-Disposition headers.
+//
+// The HTML a server converts often comes from its clients, so conversions
+// are restricted by default: they load remote resources over http and https
+// from public addresses only, and data URLs, and read no local files. See
+// Config.Unrestricted.
+package httpserve
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"io/fs"
+	"log"
+	"mime"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gompdf/gompdf"
+)
+
+// defaultMaxBodySize limits the HTML posted to Handler when the config
+// gives no limit
+const defaultMaxBodySize = 32 << 20
+
+// Config configures Middleware and Handler
+type Config struct {
+	// Options returns the conversion options of a request, such as a page
+	// size chosen by a query parameter. An error answers the request with
+	// 400 Bad Request. When nil, requests use gompdf.DefaultOptions and share
+	// one converter, and with it its caches. Options without a
+	// ResourcePolicy or ResourceFS get the restricted ones of the package;
+	// a ResourceFS such as os.DirFS of a directory of assets gives
+	// documents the files in it.
+	Options func(r *http.Request) (gompdf.Options, error)
+	// Unrestricted lets conversions load any resource, local files and
+	// private network addresses included, for servers converting HTML they
+	// trust only
+	Unrestricted bool
+	// Filename returns the file name of the PDF of a request, "document.pdf"
+	// when nil or empty
+	Filename func(r *http.Request) string
+	// Attachment has browsers download the PDF instead of showing it
+	Attachment bool
+	// Timeout bounds a conversion; zero leaves it to the request's context
+	Timeout time.Duration
+	// MaxBodySize limits the size of the HTML posted to Handler, 32 MiB
+	// when zero
+	MaxBodySize int64
+	// ErrorLog receives conversion failures; when nil they go to the log
+	// package's standard logger
+	ErrorLog *log.Logger
+}
+
+// Middleware converts the HTML responses of next to PDF. Responses of
+// other types and of statuses other than 200 OK are passed on unchanged.
+// The response of next is buffered, so next cannot stream it.
+func Middleware(next http.Handler, config Config) http.Handler {
+	converter := gompdf.NewWithOptions(config.restrict(gompdf.DefaultOptions()))
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		rec := &recorder{header: make(http.Header)}
+		next.ServeHTTP(rec, r)
+		if rec.status == 0 {
+			rec.status = http.StatusOK
+		}
+		if rec.status != http.StatusOK || !isHTML(rec.header, rec.body.Bytes()) {
+			copyHeader(w.Header(), rec.header)
+			w.WriteHeader(rec.status)
+			w.Write(rec.body.Bytes())
+			return
+		}
+		// Headers of next such as cookies and caching apply to the PDF; those
+		// describing the HTML body do not
+		copyHeader(w.Header(), rec.header)
+		for _, name := range []string{"Content-Type", "Content-Length", "Content-Encoding", "Content-Disposition", "Etag", "Last-Modified"} {
+			w.Header().Del(name)
+		}
+		config.convert(w, r, converter, rec.body.String())
+	})
+}
+
+// Handler converts the HTML posted to it to PDF. The HTML is the request
+// body, or the "html" field of a form.
+func Handler(config Config) http.Handler {
+	converter := gompdf.NewWithOptions(config.restrict(gompdf.DefaultOptions()))
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			w.Header().Set("Allow", http.MethodPost)
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		maxBody := config.MaxBodySize
+		if maxBody <= 0 {
+			maxBody = defaultMaxBodySize
+		}
+		r.Body = http.MaxBytesReader(w, r.Body, maxBody)
+
+		var htmlContent string
+		mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+		switch mediaType {
+		case "application/x-www-form-urlencoded", "multipart/form-data":
+			if err := r.ParseMultipartForm(maxBody); err != nil && !errors.Is(err, http.ErrNotMultipart) {
+				config.fail(w, err)
+				return
+			}
+			htmlContent = r.FormValue("html")
+			if htmlContent == "" {
+				http.Error(w, "missing html field", http.StatusBadRequest)
+				return
+			}
+		default:
+			body, err := io.ReadAll(r.Body)
+			if err != nil {
+				config.fail(w, err)
+				return
+			}
+			htmlContent = string(body)
+		}
+		config.convert(w, r, converter, htmlContent)
+	})
+}
+
+// convert answers a request with the PDF of htmlContent, converted by
+// converter unless the config gives the request options of its own
+func (config Config) convert(w http.ResponseWriter, r *http.Request, converter *gompdf.Converter, htmlContent string) {
+	if config.Options != nil {
+		options, err := config.Options(r)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		converter = gompdf.NewWithOptions(config.restrict(options))
+	}
+	ctx := r.Context()
+	if config.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, config.Timeout)
+		defer cancel()
+	}
+
+	filename := "document.pdf"
+	if config.Filename != nil {
+		if name := config.Filename(r); name != "" {
+			filename = name
+		}
+	}
+	disposition := "inline"
+	if config.Attachment {
+		disposition = "attachment"
+	}
+	pw := &pdfWriter{w: w, disposition: mime.FormatMediaType(disposition, map[string]string{"filename": filename})}
+	if err := converter.ConvertContext(ctx, htmlContent, pw); err != nil {
+		if pw.wrote {
+			// The PDF is cut short; all that is left is to log it
+			config.logf("writing PDF: %v", err)
+			return
+		}
+		config.fail(w, err)
+	}
+}
+
+// restrict gives options that do not restrict the resources conversions
+// load the restrictions of the package, unless the config is unrestricted
+func (config Config) restrict(options gompdf.Options) gompdf.Options {
+	if config.Unrestricted {
+		return options
+	}
+	if options.ResourcePolicy == nil {
+		options.ResourcePolicy = &gompdf.ResourcePolicy{
+			AllowedSchemes:       []string{"http", "https", "data"},
+			BlockPrivateNetworks: true,
+		}
+	}
+	if options.ResourceFS == nil {
+		options.ResourceFS = emptyFS{}
+	}
+	return options
+}
+
+// emptyFS is a file system without files
+type emptyFS struct{}
+
+func (emptyFS) Open(name string) (fs.File, error) {
+	return nil, &fs.PathError{Op: "open", Path: name, Err: fs.ErrNotExist}
+}
+
+// fail answers a request with the status code of err
+func (config Config) fail(w http.ResponseWriter, err error) {
+	status := http.StatusInternalServerError
+	var tooLarge *http.MaxBytesError
+	switch {
+	case errors.As(err, &tooLarge):
+		status = http.StatusRequestEntityTooLarge
+	case errors.Is(err, context.DeadlineExceeded):
+		status = http.StatusGatewayTimeout
+	case errors.Is(err, context.Canceled):
+		// The client went away; nobody reads the answer
+		return
+	}
+	if status == http.StatusInternalServerError {
+		config.logf("conversion failed: %v", err)
+	}
+	http.Error(w, err.Error(), status)
+}
+
+// logf reports a failure to the error log of the config
+func (config Config) logf(format string, args ...any) {
+	if config.ErrorLog != nil {
+		config.ErrorLog.Printf(format, args...)
+		return
+	}
+	log.Printf(format, args...)
+}
+
+// pdfWriter writes the PDF headers before the first byte of the PDF, so
+// that a conversion failing before can still answer with an error
+type pdfWriter struct {
+	w           http.ResponseWriter
+	disposition string
+	wrote       bool
+}
+
+func (pw *pdfWriter) Write(p []byte) (int, error) {
+	if !pw.wrote {
+		pw.wrote = true
+		pw.w.Header().Set("Content-Type", "application/pdf")
+		pw.w.Header().Set("Content-Disposition", pw.disposition)
+		pw.w.WriteHeader(http.StatusOK)
+	}
+	return pw.w.Write(p)
+}
+
+// recorder buffers the response of a handler
+type recorder struct {
+	header http.Header
+	status int
+	body   bytes.Buffer
+}
+
+func (rec *recorder) Header() http.Header { return rec.header }
+
+func (rec *recorder) WriteHeader(status int) {
+	if rec.status == 0 {
+		rec.status = status
+	}
+}
+
+func (rec *recorder) Write(p []byte) (int, error) {
+	if rec.status == 0 {
+		rec.status = http.StatusOK
+	}
+	return rec.body.Write(p)
+}
+
+// isHTML reports whether a response is HTML by its Content-Type, or by its
+// content when it has none
+func isHTML(header http.Header, body []byte) bool {
+	contentType := header.Get("Content-Type")
+	if contentType == "" {
+		contentType = http.DetectContentType(body)
+	}
+	mediaType, _, _ := mime.ParseMediaType(contentType)
+	return strings.EqualFold(mediaType, "text/html") || strings.EqualFold(mediaType, "application/xhtml+xml")
+}
+
+// copyHeader adds the values of src to dst
+func copyHeader(dst, src http.Header) {
+	for name, values := range src {
+		for _, v := range values {
+			dst.Add(name, v)
+		}
+	}
+}
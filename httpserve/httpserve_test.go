@@ -0,0 +1,100 @@
+package httpserve
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+
+	"github.com/gompdf/gompdf"
+)
+
+// warnings collects the warnings of conversions
+type warnings struct {
+	mu       sync.Mutex
+	messages []string
+}
+
+func (w *warnings) Debugf(string, ...any) {}
+
+func (w *warnings) Warnf(format string, args ...any) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.messages = append(w.messages, fmt.Sprintf(format, args...))
+}
+
+func (w *warnings) String() string {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return strings.Join(w.messages, "\n")
+}
+
+func TestRefusesLocalResources(t *testing.T) {
+	var hits atomic.Int32
+	private := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		hits.Add(1)
+		http.NotFound(w, r)
+	}))
+	defer private.Close()
+	file := filepath.Join(t.TempDir(), "secret.css")
+	if err := os.WriteFile(file, []byte("p { color: red }"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	html := fmt.Sprintf(`<link rel="stylesheet" href="%s"><img src="%s/logo.png"><img src="file://%s"><p>text</p>`,
+		file, private.URL, file)
+
+	page := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html")
+		fmt.Fprint(w, html)
+	})
+	for _, unrestricted := range []bool{false, true} {
+		for _, name := range []string{"Handler", "Middleware"} {
+			hits.Store(0)
+			log := &warnings{}
+			config := Config{
+				Unrestricted: unrestricted,
+				Options: func(*http.Request) (gompdf.Options, error) {
+					options := gompdf.DefaultOptions()
+					options.Logger = log
+					return options, nil
+				},
+			}
+			var resp *http.Response
+			var err error
+			if name == "Handler" {
+				server := httptest.NewServer(Handler(config))
+				resp, err = http.Post(server.URL, "text/html", strings.NewReader(html))
+				server.Close()
+			} else {
+				server := httptest.NewServer(Middleware(page, config))
+				resp, err = http.Get(server.URL)
+				server.Close()
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			resp.Body.Close()
+			if resp.StatusCode != http.StatusOK {
+				t.Fatalf("%s, unrestricted %v: got status %d", name, unrestricted, resp.StatusCode)
+			}
+			denied := strings.Count(log.String(), "not allowed") + strings.Count(log.String(), "not public")
+			if unrestricted {
+				if hits.Load() == 0 || denied > 0 {
+					t.Errorf("%s unrestricted: got %d requests to the private server and warnings %q, want the resources loaded", name, hits.Load(), log)
+				}
+				continue
+			}
+			if hits.Load() > 0 {
+				t.Errorf("%s: got %d requests to the private server, want none", name, hits.Load())
+			}
+			if denied < 3 {
+				t.Errorf("%s: got warnings %q, want the stylesheet, the private image and the file refused", name, log)
+			}
+		}
+	}
+}
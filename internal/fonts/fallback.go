@@ -0,0 +1,279 @@
+package fonts
+
+import (
+	"sort"
+	"strings"
+	"unicode"
+
+	"golang.org/x/image/font/sfnt"
+	"golang.org/x/text/encoding/charmap"
+)
+
+// Run is a piece of text drawn with a single font
+type Run struct {
+	// Text is UTF-8; use Encode before handing it to fpdf
+	Text   string
+	Family string
+	Style  string
+}
+
+// SetFallbacks configures the families tried, in order, for characters the
+// primary font cannot display. Registered families not listed here are tried
+// afterwards, followed by the core fonts.
+func (r *Registry) SetFallbacks(families ...string) {
+	if r == nil {
+		return
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.fallbacks = cleanFamilies(families)
+}
+
+// SetScriptFallbacks configures the families tried, in order, for characters
+// of a Unicode script the primary font cannot display, before the general
+// fallbacks. Scripts are named as in the unicode package, such as "Han",
+// "Arabic" or "Cyrillic", ignoring case.
+func (r *Registry) SetScriptFallbacks(script string, families ...string) {
+	if r == nil {
+		return
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.scriptFallbacks == nil {
+		r.scriptFallbacks = make(map[string][]string)
+	}
+	r.scriptFallbacks[strings.ToLower(strings.TrimSpace(script))] = cleanFamilies(families)
+}
+
+// cleanFamilies trims quotes and spaces from family names, dropping empty ones
+func cleanFamilies(families []string) []string {
+	var out []string
+	for _, f := range families {
+		if f = strings.TrimSpace(strings.Trim(strings.TrimSpace(f), "'\"")); f != "" {
+			out = append(out, f)
+		}
+	}
+	return out
+}
+
+// Runs splits text into runs that can each be drawn with one font. Every
+// character uses the primary font when it has a glyph for it, otherwise the
+// first font of the fallback chain for its script that does. Characters no
+// font covers stay with the primary font.
+func (r *Registry) Runs(text, family, style string) []Run {
+	if text == "" {
+		return nil
+	}
+	primary := fontRef{Family: family, Style: style}
+	if r.coversAll(primary, text) {
+		return []Run{{Text: text, Family: family, Style: style}}
+	}
+	chains := make(map[string][]fontRef)
+
+	var runs []Run
+	var cur strings.Builder
+	curFont := primary
+	flush := func() {
+		if cur.Len() > 0 {
+			runs = append(runs, Run{Text: cur.String(), Family: curFont.Family, Style: curFont.Style})
+			cur.Reset()
+		}
+	}
+	for _, ch := range text {
+		// Spaces stay with the current run to avoid needless font switches
+		if unicode.IsSpace(ch) && r.covers(curFont, ch) {
+			cur.WriteRune(ch)
+			continue
+		}
+		script := scriptOf(ch)
+		chain, ok := chains[script]
+		if !ok {
+			chain = r.fallbackChain(family, style, script)
+			chains[script] = chain
+		}
+		font := chain[0]
+		for _, f := range chain {
+			if r.covers(f, ch) {
+				font = f
+				break
+			}
+		}
+		if font != curFont {
+			flush()
+			curFont = font
+		}
+		cur.WriteRune(ch)
+	}
+	flush()
+	return runs
+}
+
+// Encode converts UTF-8 text to the encoding fpdf expects for the family.
+// Registered faces take UTF-8; the core fonts use Windows-1252, with '?' for
+// characters outside it.
+func (r *Registry) Encode(family, text string) string {
+	if r.Has(family) || isASCII(text) {
+		return text
+	}
+	b := make([]byte, 0, len(text))
+	for _, ch := range text {
+		if c, ok := charmap.Windows1252.EncodeRune(ch); ok {
+			b = append(b, c)
+		} else {
+			b = append(b, '?')
+		}
+	}
+	return string(b)
+}
+
+// fontRef names a font by family and fpdf style
+type fontRef struct {
+	Family string
+	Style  string
+}
+
+// fallbackChain lists the fonts tried for a character of a script, starting
+// with the primary font, then the fallbacks for the script and the general
+// fallbacks
+func (r *Registry) fallbackChain(family, style, script string) []fontRef {
+	chain := []fontRef{{Family: family, Style: style}}
+	if r == nil {
+		return chain
+	}
+	seen := map[string]bool{strings.ToLower(family): true}
+	add := func(name string) {
+		key := strings.ToLower(name)
+		if seen[key] {
+			return
+		}
+		seen[key] = true
+		if face := r.lookup(name, style); face != nil {
+			chain = append(chain, fontRef{Family: face.Family, Style: face.Style})
+		} else if core := coreFamily(name); core != "" {
+			chain = append(chain, fontRef{Family: core, Style: style})
+		}
+	}
+
+	r.mu.RLock()
+	fallbacks := append(append([]string(nil), r.scriptFallbacks[strings.ToLower(script)]...), r.fallbacks...)
+	registered := make([]string, 0, len(r.faces))
+	for _, variants := range r.faces {
+		for _, face := range variants {
+			registered = append(registered, face.Family)
+			break
+		}
+	}
+	r.mu.RUnlock()
+	sort.Strings(registered)
+
+	for _, name := range fallbacks {
+		add(name)
+	}
+	for _, name := range registered {
+		add(name)
+	}
+	add("Helvetica")
+	return chain
+}
+
+// covers reports whether the font has a glyph for the character
+func (r *Registry) covers(f fontRef, ch rune) bool {
+	face := r.face(f.Family, f.Style)
+	if face == nil {
+		_, ok := charmap.Windows1252.EncodeRune(ch)
+		return ok
+	}
+	return face.covers(ch)
+}
+
+// coversAll reports whether the font has glyphs for every character of s
+func (r *Registry) coversAll(f fontRef, s string) bool {
+	if r.face(f.Family, f.Style) == nil && isASCII(s) {
+		return true
+	}
+	for _, ch := range s {
+		if !r.covers(f, ch) {
+			return false
+		}
+	}
+	return true
+}
+
+// FaceData returns the TrueType data of the registered face for an exact
+// family and fpdf style, or nil for the core fonts
+func (r *Registry) FaceData(family, style string) []byte {
+	if face := r.face(family, style); face != nil {
+		return face.Data
+	}
+	return nil
+}
+
+// Face returns the registered face for an exact family and fpdf style, or
+// nil for the core fonts
+func (r *Registry) Face(family, style string) *Face {
+	return r.face(family, style)
+}
+
+// face returns the registered face for an exact family and style
+func (r *Registry) face(family, style string) *Face {
+	if r == nil {
+		return nil
+	}
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.faces[strings.ToLower(family)][style]
+}
+
+// covers reports whether the face maps the character to a glyph
+func (f *Face) covers(ch rune) bool {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if ok, cached := f.coverage[ch]; cached {
+		return ok
+	}
+	var buf sfnt.Buffer
+	idx, err := f.font.GlyphIndex(&buf, ch)
+	ok := err == nil && idx != 0
+	f.coverage[ch] = ok
+	return ok
+}
+
+// Characters returns the characters the face has glyphs for, of the basic
+// and supplementary multilingual planes, in order
+func (f *Face) Characters() []rune {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	var buf sfnt.Buffer
+	var chars []rune
+	for ch := rune(0x20); ch < 0x20000; ch++ {
+		if ch >= 0xD800 && ch < 0xE000 {
+			// Surrogates are not characters
+			continue
+		}
+		if idx, err := f.font.GlyphIndex(&buf, ch); err == nil && idx != 0 {
+			chars = append(chars, ch)
+		}
+	}
+	return chars
+}
+
+// scriptOf returns the name of the Unicode script of a character, "Common"
+// for punctuation, symbols and others shared between scripts
+func scriptOf(ch rune) string {
+	for name, table := range unicode.Scripts {
+		if name != "Common" && name != "Inherited" && unicode.Is(table, ch) {
+			return name
+		}
+	}
+	return "Common"
+}
+
+// isASCII reports whether s contains only ASCII characters
+func isASCII(s string) bool {
+	for i := 0; i < len(s); i++ {
+		if s[i] >= 0x80 {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,351 @@
+// Package fonts manages the fonts available to a conversion: TrueType files
+// found in font directories and faces declared by @font-face rules. Faces are
+// embedded into the PDF as UTF-8 fonts and looked up by CSS font properties.
+package fonts
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io/fs"
+	"maps"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+
+	"codeberg.org/go-pdf/fpdf"
+	"golang.org/x/image/font/sfnt"
+)
+
+// Variant styles as understood by fpdf
+const (
+	StyleRegular    = ""
+	StyleBold       = "B"
+	StyleItalic     = "I"
+	StyleBoldItalic = "BI"
+)
+
+// Face is a single font file registered for a family and variant. Family
+// and Style name the face for fpdf: faces of weights other than 400 and 700
+// get a family of their own, such as "Inter 500".
+type Face struct {
+	Family string
+	Style  string
+	// Weight is the numeric CSS weight of the face, 400 for regular
+	Weight int
+	Italic bool
+	// Data holds the TrueType (sfnt) bytes of the face
+	Data []byte
+
+	font     *sfnt.Font
+	mu       sync.Mutex
+	coverage map[rune]bool
+}
+
+// Registry collects the font faces available to a conversion. A nil Registry
+// is valid and resolves every family to the PDF core fonts.
+type Registry struct {
+	mu sync.RWMutex
+	// faces maps a lower-cased fpdf family name to its variants by style
+	faces map[string]map[string]*Face
+	// families lists the faces of every lower-cased CSS family name
+	families map[string][]*Face
+	// dirs records the font directories that have already been scanned
+	dirs map[string]bool
+	// fallbacks lists the families tried for characters the primary font lacks
+	fallbacks []string
+	// scriptFallbacks lists the families tried first for characters of a
+	// Unicode script, keyed by lower-cased script name
+	scriptFallbacks map[string][]string
+}
+
+// NewRegistry creates an empty font registry
+func NewRegistry() *Registry {
+	return &Registry{
+		faces:    make(map[string]map[string]*Face),
+		families: make(map[string][]*Face),
+		dirs:     make(map[string]bool),
+	}
+}
+
+// Clone returns a registry with the faces, scanned directories and
+// fallbacks of r. Faces added to either registry afterwards are not seen by
+// the other, so a registry of directory fonts can be scanned once and cloned
+// for every conversion adding @font-face rules of its own.
+func (r *Registry) Clone() *Registry {
+	clone := NewRegistry()
+	if r == nil {
+		return clone
+	}
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	for key, variants := range r.faces {
+		clone.faces[key] = maps.Clone(variants)
+	}
+	for key, faces := range r.families {
+		clone.families[key] = slices.Clone(faces)
+	}
+	maps.Copy(clone.dirs, r.dirs)
+	clone.fallbacks = slices.Clone(r.fallbacks)
+	if r.scriptFallbacks != nil {
+		clone.scriptFallbacks = maps.Clone(r.scriptFallbacks)
+	}
+	return clone
+}
+
+// AddFace registers font data for a family and variant. WOFF data is
+// converted to TrueType. A face registered later replaces an earlier one for
+// the same family and variant, so @font-face rules win over directory fonts.
+func (r *Registry) AddFace(family string, bold, italic bool, data []byte) error {
+	weight := 400
+	if bold {
+		weight = 700
+	}
+	return r.AddFaceWeight(family, weight, italic, data)
+}
+
+// AddFaceWeight registers font data for a family, numeric weight and style,
+// like AddFace
+func (r *Registry) AddFaceWeight(family string, weight int, italic bool, data []byte) error {
+	family = strings.TrimSpace(strings.Trim(strings.TrimSpace(family), "'\""))
+	if family == "" {
+		return errors.New("font family is empty")
+	}
+	data, err := toTrueType(data)
+	if err != nil {
+		return &loadError{name: fmt.Sprintf("font %q", family), err: err}
+	}
+	font, err := validate(data)
+	if err != nil {
+		return &loadError{name: fmt.Sprintf("font %q", family), err: err}
+	}
+
+	weight = clampWeight(weight)
+	// fpdf knows regular and bold variants; other weights are families of
+	// their own
+	pdfFamily, st := family, variantStyle(weight == 700, italic)
+	if weight != 400 && weight != 700 {
+		pdfFamily, st = fmt.Sprintf("%s %d", family, weight), variantStyle(false, italic)
+	}
+	face := &Face{Family: pdfFamily, Style: st, Weight: weight, Italic: italic, Data: data, font: font, coverage: make(map[rune]bool)}
+	key := strings.ToLower(pdfFamily)
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.faces[key] == nil {
+		r.faces[key] = make(map[string]*Face)
+	}
+	r.faces[key][st] = face
+	cssKey := strings.ToLower(family)
+	variants := r.families[cssKey][:0:0]
+	for _, f := range r.families[cssKey] {
+		if f.Weight != weight || f.Italic != italic {
+			variants = append(variants, f)
+		}
+	}
+	r.families[cssKey] = append(variants, face)
+	return nil
+}
+
+// AddFile registers a font file, taking its family and variant from the
+// font's name table
+func (r *Registry) AddFile(path string) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return &loadError{err: err}
+	}
+	data, err = toTrueType(data)
+	if err != nil {
+		return &loadError{name: path, err: err}
+	}
+	family, subfamily, err := faceNames(data)
+	if err != nil {
+		return &loadError{name: path, err: err}
+	}
+	sub := strings.ToLower(subfamily)
+	weight, ok := os2Weight(data)
+	if !ok {
+		weight = subfamilyWeight(sub)
+	}
+	italic := strings.Contains(sub, "italic") || strings.Contains(sub, "oblique")
+	return r.AddFaceWeight(family, weight, italic, data)
+}
+
+// AddDirectory registers every TrueType, OpenType and WOFF font below dir.
+// Files that cannot be used are skipped; their errors are returned joined so
+// callers can report them.
+func (r *Registry) AddDirectory(dir string) error {
+	r.mu.Lock()
+	if r.dirs[dir] {
+		r.mu.Unlock()
+		return nil
+	}
+	r.dirs[dir] = true
+	r.mu.Unlock()
+
+	var errs []error
+	walkErr := filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() {
+			return nil
+		}
+		switch strings.ToLower(filepath.Ext(path)) {
+		case ".ttf", ".otf", ".woff":
+			if err := r.AddFile(path); err != nil {
+				errs = append(errs, err)
+			}
+		}
+		return nil
+	})
+	if walkErr != nil {
+		return walkErr
+	}
+	return errors.Join(errs...)
+}
+
+// Has reports whether any face is registered for the family
+func (r *Registry) Has(family string) bool {
+	if r == nil {
+		return false
+	}
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return len(r.faces[strings.ToLower(strings.TrimSpace(family))]) > 0
+}
+
+// Resolve maps CSS font-family, font-weight and font-style values to a font
+// family and style that are usable with fpdf; see Match
+func (r *Registry) Resolve(family, weight, fontStyle string) (string, string) {
+	sel := r.Match(family, weight, fontStyle)
+	return sel.Family, sel.Style
+}
+
+// lookup returns the registered face of the CSS family closest to an fpdf
+// style
+func (r *Registry) lookup(family, want string) *Face {
+	weight := 400
+	if strings.Contains(want, "B") {
+		weight = 700
+	}
+	return r.matchFace(family, weight, strings.Contains(want, "I"))
+}
+
+// Embed adds the registered face of an fpdf family and style to a PDF
+// document; the core fonts need no embedding. fpdf writes only the glyphs of
+// the characters drawn with a face, so embedding faces as they are first used
+// keeps faces that are registered but never drawn out of the output, and
+// limits large fonts such as CJK ones to the glyphs the document shows.
+// Embedding a face twice is harmless.
+func (r *Registry) Embed(pdf *fpdf.Fpdf, family, style string) {
+	if pdf == nil {
+		return
+	}
+	if face := r.face(family, style); face != nil {
+		pdf.AddUTF8FontFromBytes(face.Family, face.Style, face.Data)
+	}
+}
+
+// IsBold reports whether a CSS font-weight value selects a bold face
+func IsBold(weight string) bool {
+	return ParseWeight(weight) >= 600
+}
+
+// IsItalic reports whether a CSS font-style value selects an italic face
+func IsItalic(fontStyle string) bool {
+	s := strings.ToLower(strings.TrimSpace(fontStyle))
+	return strings.HasPrefix(s, "italic") || strings.HasPrefix(s, "oblique")
+}
+
+// coreFamily maps CSS family names to the PDF core fonts
+func coreFamily(name string) string {
+	switch strings.ToLower(name) {
+	case "arial", "helvetica", "sans-serif", "system-ui":
+		return "Helvetica"
+	case "times", "times new roman", "serif":
+		return "Times"
+	case "courier", "courier new", "monospace":
+		return "Courier"
+	}
+	return ""
+}
+
+// variantStyle builds the fpdf style string for a variant
+func variantStyle(bold, italic bool) string {
+	switch {
+	case bold && italic:
+		return StyleBoldItalic
+	case bold:
+		return StyleBold
+	case italic:
+		return StyleItalic
+	}
+	return StyleRegular
+}
+
+// faceNames reads the family and subfamily names of a font, preferring the
+// typographic names when present
+func faceNames(data []byte) (string, string, error) {
+	f, err := sfnt.Parse(data)
+	if err != nil {
+		return "", "", err
+	}
+	var buf sfnt.Buffer
+	family, err := f.Name(&buf, sfnt.NameIDTypographicFamily)
+	if err != nil || family == "" {
+		if family, err = f.Name(&buf, sfnt.NameIDFamily); err != nil {
+			return "", "", err
+		}
+	}
+	subfamily, err := f.Name(&buf, sfnt.NameIDTypographicSubfamily)
+	if err != nil || subfamily == "" {
+		subfamily, _ = f.Name(&buf, sfnt.NameIDSubfamily)
+	}
+	return family, subfamily, nil
+}
+
+// ErrLoad is matched by the errors of fonts that cannot be loaded, such as
+// unreadable, malformed or unsupported font files
+var ErrLoad = errors.New("font cannot be loaded")
+
+// loadError is the error of a font that cannot be loaded; name identifies
+// the font, its family or file, when err does not
+type loadError struct {
+	name string
+	err  error
+}
+
+func (e *loadError) Error() string {
+	if e.name == "" {
+		return e.err.Error()
+	}
+	return e.name + ": " + e.err.Error()
+}
+
+func (e *loadError) Unwrap() []error {
+	return []error{ErrLoad, e.err}
+}
+
+// validate checks that fpdf can embed the font. fpdf records font errors on
+// the document, so a broken face would otherwise fail the whole conversion.
+func validate(data []byte) (*sfnt.Font, error) {
+	switch {
+	case bytes.HasPrefix(data, []byte("OTTO")):
+		return nil, fmt.Errorf("OpenType fonts with CFF outlines are not supported: %w", errors.ErrUnsupported)
+	case !bytes.HasPrefix(data, []byte{0, 1, 0, 0}) && !bytes.HasPrefix(data, []byte("true")):
+		return nil, errors.New("not a TrueType font")
+	}
+	font, err := sfnt.Parse(data)
+	if err != nil {
+		return nil, err
+	}
+	probe := fpdf.New("P", "pt", "", "")
+	probe.AddUTF8FontFromBytes("probe", "", data)
+	if err := probe.Error(); err != nil {
+		return nil, err
+	}
+	return font, nil
+}
@@ -0,0 +1,184 @@
+package fonts
+
+import (
+	"encoding/binary"
+	"strconv"
+	"strings"
+)
+
+// Selection is the font chosen for CSS font properties. The synthetic flags
+// ask the renderer to embolden or slant the face when the family has no
+// face close enough to the requested weight or style.
+type Selection struct {
+	Family string
+	Style  string
+	// SyntheticBold is set when a bold weight was asked for and the chosen
+	// face is lighter than 600
+	SyntheticBold bool
+	// SyntheticOblique is set when an italic or oblique style was asked for
+	// and the family has no italic face
+	SyntheticOblique bool
+}
+
+// Match maps CSS font-family, font-weight and font-style values to a font
+// usable with fpdf. Families from the list are tried in order; registered
+// faces take precedence over the core fonts. Within a registered family the
+// face is chosen by the CSS font matching rules: style first, then the
+// nearest weight.
+func (r *Registry) Match(family, weight, fontStyle string) Selection {
+	w, italic := ParseWeight(weight), IsItalic(fontStyle)
+	for _, name := range strings.Split(family, ",") {
+		name = strings.TrimSpace(strings.Trim(strings.TrimSpace(name), "'\""))
+		if name == "" {
+			continue
+		}
+		if face := r.matchFace(name, w, italic); face != nil {
+			return Selection{
+				Family:           face.Family,
+				Style:            face.Style,
+				SyntheticBold:    w >= 600 && face.Weight < 600,
+				SyntheticOblique: italic && !face.Italic,
+			}
+		}
+		if core := coreFamily(name); core != "" {
+			return Selection{Family: core, Style: variantStyle(w >= 600, italic)}
+		}
+	}
+	return Selection{Family: "Helvetica", Style: variantStyle(w >= 600, italic)}
+}
+
+// matchFace returns the face of a registered CSS family that best matches a
+// weight and style, or nil when the family has no faces. Faces of the
+// requested style are preferred; among them the weight is matched as CSS
+// Fonts describes: for 400 and 500 heavier faces up to 500 first, then
+// lighter ones; for lighter weights lighter faces first; for bolder weights
+// bolder faces first.
+func (r *Registry) matchFace(family string, weight int, italic bool) *Face {
+	if r == nil {
+		return nil
+	}
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	faces := r.families[strings.ToLower(family)]
+	if len(faces) == 0 {
+		return nil
+	}
+	var styled []*Face
+	for _, f := range faces {
+		if f.Italic == italic {
+			styled = append(styled, f)
+		}
+	}
+	if len(styled) == 0 {
+		styled = faces
+	}
+
+	var best *Face
+	bestRank := 0
+	for _, f := range styled {
+		rank := weightRank(weight, f.Weight)
+		if best == nil || rank < bestRank {
+			best, bestRank = f, rank
+		}
+	}
+	return best
+}
+
+// weightRank orders the weight of a face by how well it matches the desired
+// weight; lower is better
+func weightRank(desired, have int) int {
+	const tier = 10000
+	switch {
+	case desired >= 400 && desired <= 500:
+		switch {
+		case have >= desired && have <= 500:
+			return have - desired
+		case have < desired:
+			return tier + desired - have
+		default:
+			return 2*tier + have - desired
+		}
+	case desired < 400:
+		if have <= desired {
+			return desired - have
+		}
+		return tier + have - desired
+	default:
+		if have >= desired {
+			return have - desired
+		}
+		return tier + desired - have
+	}
+}
+
+// ParseWeight converts a CSS font-weight value to a number between 1 and
+// 1000. normal is 400 and bold 700; bolder and lighter, which the style
+// engine resolves against the parent weight, map to 700 and 100 here. The
+// first number of a range, as @font-face allows, is used.
+func ParseWeight(weight string) int {
+	w := strings.ToLower(strings.TrimSpace(weight))
+	switch w {
+	case "", "normal":
+		return 400
+	case "bold", "bolder":
+		return 700
+	case "lighter":
+		return 100
+	}
+	n, err := strconv.ParseFloat(strings.Fields(w)[0], 64)
+	if err != nil {
+		return 400
+	}
+	return clampWeight(int(n))
+}
+
+// clampWeight keeps a weight within the CSS range
+func clampWeight(w int) int {
+	return min(max(w, 1), 1000)
+}
+
+// os2Weight reads usWeightClass from the OS/2 table of TrueType data
+func os2Weight(data []byte) (int, bool) {
+	if len(data) < 12 {
+		return 0, false
+	}
+	numTables := int(binary.BigEndian.Uint16(data[4:]))
+	for i := 0; i < numTables; i++ {
+		rec := 12 + 16*i
+		if rec+16 > len(data) {
+			break
+		}
+		if string(data[rec:rec+4]) != "OS/2" {
+			continue
+		}
+		off := int(binary.BigEndian.Uint32(data[rec+8:]))
+		if off+6 > len(data) {
+			return 0, false
+		}
+		w := int(binary.BigEndian.Uint16(data[off+4:]))
+		if w < 1 || w > 1000 {
+			return 0, false
+		}
+		return w, true
+	}
+	return 0, false
+}
+
+// subfamilyWeight guesses the weight of a face from its lower-cased
+// subfamily name
+func subfamilyWeight(sub string) int {
+	sub = strings.NewReplacer(" ", "", "-", "").Replace(sub)
+	for _, kw := range []struct {
+		name   string
+		weight int
+	}{
+		{"extralight", 200}, {"ultralight", 200}, {"semibold", 600}, {"demibold", 600},
+		{"extrabold", 800}, {"ultrabold", 800}, {"thin", 100}, {"hairline", 100},
+		{"light", 300}, {"medium", 500}, {"bold", 700}, {"black", 900}, {"heavy", 900},
+	} {
+		if strings.Contains(sub, kw.name) {
+			return kw.weight
+		}
+	}
+	return 400
+}
@@ -0,0 +1,101 @@
+package fonts
+
+import (
+	"bytes"
+	"compress/zlib"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+)
+
+// toTrueType returns sfnt bytes for font data, unpacking WOFF containers
+func toTrueType(data []byte) ([]byte, error) {
+	switch {
+	case bytes.HasPrefix(data, []byte("wOFF")):
+		return decodeWOFF(data)
+	case bytes.HasPrefix(data, []byte("wOF2")):
+		return nil, fmt.Errorf("WOFF2 fonts are not supported: %w", errors.ErrUnsupported)
+	}
+	return data, nil
+}
+
+// decodeWOFF rebuilds the sfnt font wrapped in a WOFF 1.0 file
+func decodeWOFF(data []byte) ([]byte, error) {
+	const headerSize, entrySize = 44, 20
+	if len(data) < headerSize {
+		return nil, errors.New("woff: truncated header")
+	}
+	be := binary.BigEndian
+	flavor := be.Uint32(data[4:8])
+	numTables := int(be.Uint16(data[12:14]))
+	if len(data) < headerSize+numTables*entrySize {
+		return nil, errors.New("woff: truncated table directory")
+	}
+
+	type table struct {
+		tag      uint32
+		checksum uint32
+		data     []byte
+	}
+	tables := make([]table, 0, numTables)
+	for i := 0; i < numTables; i++ {
+		e := data[headerSize+i*entrySize:]
+		tag := be.Uint32(e[0:4])
+		offset := int(be.Uint32(e[4:8]))
+		compLength := int(be.Uint32(e[8:12]))
+		origLength := int(be.Uint32(e[12:16]))
+		if offset < 0 || compLength < 0 || offset+compLength > len(data) {
+			return nil, fmt.Errorf("woff: table %d out of bounds", i)
+		}
+		raw := data[offset : offset+compLength]
+		if compLength < origLength {
+			zr, err := zlib.NewReader(bytes.NewReader(raw))
+			if err != nil {
+				return nil, fmt.Errorf("woff: %w", err)
+			}
+			raw, err = io.ReadAll(io.LimitReader(zr, int64(origLength)))
+			zr.Close()
+			if err != nil {
+				return nil, fmt.Errorf("woff: %w", err)
+			}
+		}
+		if len(raw) != origLength {
+			return nil, fmt.Errorf("woff: table %d has wrong length", i)
+		}
+		tables = append(tables, table{tag: tag, checksum: be.Uint32(e[16:20]), data: raw})
+	}
+
+	searchRange, entrySelector := 1, 0
+	for searchRange*2 <= numTables {
+		searchRange *= 2
+		entrySelector++
+	}
+	searchRange *= 16
+
+	var out bytes.Buffer
+	header := make([]byte, 12+16*numTables)
+	be.PutUint32(header[0:4], flavor)
+	be.PutUint16(header[4:6], uint16(numTables))
+	be.PutUint16(header[6:8], uint16(searchRange))
+	be.PutUint16(header[8:10], uint16(entrySelector))
+	be.PutUint16(header[10:12], uint16(numTables*16-searchRange))
+
+	offset := len(header)
+	for i, t := range tables {
+		rec := header[12+16*i:]
+		be.PutUint32(rec[0:4], t.tag)
+		be.PutUint32(rec[4:8], t.checksum)
+		be.PutUint32(rec[8:12], uint32(offset))
+		be.PutUint32(rec[12:16], uint32(len(t.data)))
+		offset += (len(t.data) + 3) &^ 3
+	}
+	out.Write(header)
+	for _, t := range tables {
+		out.Write(t.data)
+		if pad := (4 - len(t.data)%4) % 4; pad > 0 {
+			out.Write(make([]byte, pad))
+		}
+	}
+	return out.Bytes(), nil
+}
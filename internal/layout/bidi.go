@@ -0,0 +1,124 @@
+package layout
+
+import (
+	"strings"
+
+	"github.com/gompdf/gompdf/internal/parser/html"
+	"github.com/gompdf/gompdf/internal/style"
+	"github.com/gompdf/gompdf/internal/text"
+)
+
+// objectReplacement stands for images and inline-blocks in the text handed
+// to the bidi algorithm, which treats them as neutral
+const objectReplacement = "\ufffc"
+
+// paragraphDirection returns the base direction of the paragraph laid out
+// from node: the CSS direction property, which the user agent stylesheet
+// sets from dir="rtl" and dir="ltr". dir="auto" takes the direction of the
+// first strong character of content instead.
+func paragraphDirection(node *html.Node, st style.ComputedStyle, content string) text.Direction {
+	if node != nil {
+		for _, a := range node.Attr {
+			if strings.EqualFold(a.Key, "dir") && strings.EqualFold(strings.TrimSpace(a.Val), "auto") {
+				return text.NewBidiProcessor().Process(content).Direction
+			}
+		}
+	}
+	if isRTL(st) {
+		return text.RightToLeft
+	}
+	return text.LeftToRight
+}
+
+// isRTL reports whether a style has a right-to-left direction
+func isRTL(st style.ComputedStyle) bool {
+	return strings.EqualFold(strings.TrimSpace(st["direction"].Value), "rtl")
+}
+
+// bidiText returns the logical text of a paragraph's tokens and the byte
+// offset each token starts at
+func bidiText(raw []lineToken) (string, []int) {
+	var sb strings.Builder
+	starts := make([]int, len(raw))
+	for i, tk := range raw {
+		starts[i] = sb.Len()
+		switch {
+		case tk.newline:
+			sb.WriteString("\n")
+		case tk.img != nil || tk.block != nil:
+			sb.WriteString(objectReplacement)
+		default:
+			sb.WriteString(tk.text)
+		}
+	}
+	return sb.String(), starts
+}
+
+// assignBidiLevels runs the bidi algorithm over a paragraph and gives every
+// token the embedding level of the text it starts with. It reports whether
+// any token is right-to-left, which is when lines need reordering.
+func assignBidiLevels(raw []lineToken, dir text.Direction, content string, starts []int) bool {
+	p := text.NewBidiProcessor()
+	if dir == text.LeftToRight && !p.IsRTL(content) {
+		return false
+	}
+	runs := p.ProcessDirection(content, dir).Runs
+	r := 0
+	for i := range raw {
+		for r < len(runs)-1 && starts[i] >= runs[r].Start+runs[r].Length {
+			r++
+		}
+		if r < len(runs) {
+			raw[i].level = runs[r].Level
+		}
+	}
+	return true
+}
+
+// visualOrder returns the order the tokens of a line are placed in from left
+// to right. Spaces ending the line take the paragraph level so that they
+// stay at its end.
+func visualOrder(line []lineToken, base uint8) []int {
+	levels := make([]uint8, len(line))
+	trailing := true
+	for i := len(line) - 1; i >= 0; i-- {
+		trailing = trailing && line[i].isSpace
+		if trailing {
+			levels[i] = base
+		} else {
+			levels[i] = line[i].level
+		}
+	}
+	return text.VisualOrder(levels)
+}
+
+// displayText returns the text a token draws, in logical order. The
+// renderer shapes right-to-left words in their direction.
+func displayText(tk lineToken) string {
+	if tk.isSpace {
+		return " "
+	}
+	return stripSoftHyphens(tk.text)
+}
+
+// lineAlignment resolves the text-align of a container to left, right or
+// center, taking start and end from the paragraph direction
+func lineAlignment(st style.ComputedStyle, dir text.Direction) string {
+	align := strings.ToLower(strings.TrimSpace(st["text-align"].Value))
+	rtl := dir == text.RightToLeft
+	switch align {
+	case "right", "center":
+		return align
+	case "end":
+		if rtl {
+			return "left"
+		}
+		return "right"
+	case "left":
+		return "left"
+	}
+	if rtl {
+		return "right"
+	}
+	return "left"
+}
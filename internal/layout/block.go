@@ -3,7 +3,6 @@ package layout
 import (
 	"github.com/gompdf/gompdf/internal/parser/html"
 	"github.com/gompdf/gompdf/internal/style"
-	"strings"
 )
 
 // BlockBox represents a block-level box in the layout
@@ -27,41 +26,12 @@ type BlockBox struct {
 	BorderBottom  float64
 	BorderLeft    float64
 	Children      []Box
-}
-
-// parseBoxShorthand parses CSS shorthand like:
-//  - "10px"
-//  - "10px 20px"
-//  - "10px 15px 8px"
-//  - "10px 12px 8px 6px"
-// and returns (top, right, bottom, left) values.
-func parseBoxShorthand(value string, containerSize float64, def float64) (float64, float64, float64, float64) {
-    v := strings.TrimSpace(value)
-    if v == "" {
-        return def, def, def, def
-    }
-    parts := strings.Fields(v)
-    to := func(s string) float64 { return parseLength(s, containerSize, def) }
-    switch len(parts) {
-    case 1:
-        a := to(parts[0])
-        return a, a, a, a
-    case 2:
-        vtb := to(parts[0])
-        vrl := to(parts[1])
-        return vtb, vrl, vtb, vrl
-    case 3:
-        t := to(parts[0])
-        r := to(parts[1])
-        b := to(parts[2])
-        return t, r, b, r
-    default:
-        t := to(parts[0])
-        r := to(parts[1])
-        b := to(parts[2])
-        l := to(parts[3])
-        return t, r, b, l
-    }
+	// Clip is set when an ancestor clips its overflow
+	Clip *Clip
+	// Marker is the marker of a list item, nil for other boxes
+	Marker *ListMarker
+	// Widget is set on the box of a form control, drawn as a static widget
+	Widget *FormWidget
 }
 
 // NewBlockBox creates a new block box for an element
@@ -76,12 +46,14 @@ func NewBlockBox(node *html.Node, computedStyle style.ComputedStyle) *BlockBox {
 // Layout performs layout for this block box and its children
 func (b *BlockBox) Layout(containingBlock *BlockBox) {
 	if containingBlock != nil {
-		b.X = containingBlock.X + containingBlock.PaddingLeft
-		b.Y = containingBlock.Y + containingBlock.PaddingTop
+		b.X = containingBlock.X + containingBlock.PaddingLeft + containingBlock.BorderLeft
+		b.Y = containingBlock.Y + containingBlock.PaddingTop + containingBlock.BorderTop
 
 		availableWidth := containingBlock.Width -
 			containingBlock.PaddingLeft -
-			containingBlock.PaddingRight
+			containingBlock.PaddingRight -
+			containingBlock.BorderLeft -
+			containingBlock.BorderRight
 
 		b.Width = availableWidth
 	}
@@ -91,36 +63,25 @@ func (b *BlockBox) Layout(containingBlock *BlockBox) {
 	b.calculateHeight()
 }
 
-// parseBoxModel parses margin, padding, and border properties
+// parseBoxModel parses margin, padding, and border properties and gives
+// the box its declared width. Width is that of the border box, which fills
+// the available width without a declared one; see contentSize for how
+// box-sizing applies.
 func (b *BlockBox) parseBoxModel() {
-	// Margin shorthand support
-	if m, ok := b.Style["margin"]; ok && strings.TrimSpace(m.Value) != "" {
-		t, r, bt, l := parseBoxShorthand(m.Value, b.Width, 0)
-		b.MarginTop, b.MarginRight, b.MarginBottom, b.MarginLeft = t, r, bt, l
-	} else {
-		b.MarginTop = parseLength(b.Style["margin-top"].Value, b.Width, 0)
-		b.MarginRight = parseLength(b.Style["margin-right"].Value, b.Width, 0)
-		b.MarginBottom = parseLength(b.Style["margin-bottom"].Value, b.Width, 0)
-		b.MarginLeft = parseLength(b.Style["margin-left"].Value, b.Width, 0)
-	}
-
-	// Padding shorthand support
-	if p, ok := b.Style["padding"]; ok && strings.TrimSpace(p.Value) != "" {
-		t, r, bt, l := parseBoxShorthand(p.Value, b.Width, 0)
-		b.PaddingTop, b.PaddingRight, b.PaddingBottom, b.PaddingLeft = t, r, bt, l
-	} else {
-		b.PaddingTop = parseLength(b.Style["padding-top"].Value, b.Width, 0)
-		b.PaddingRight = parseLength(b.Style["padding-right"].Value, b.Width, 0)
-		b.PaddingBottom = parseLength(b.Style["padding-bottom"].Value, b.Width, 0)
-		b.PaddingLeft = parseLength(b.Style["padding-left"].Value, b.Width, 0)
+	b.MarginTop, b.MarginRight, b.MarginBottom, b.MarginLeft = boxEdges(b.Style, "margin", b.Width)
+	b.PaddingTop, b.PaddingRight, b.PaddingBottom, b.PaddingLeft = boxEdges(b.Style, "padding", b.Width)
+
+	b.BorderTop = b.Style.Border("top").Width
+	b.BorderRight = b.Style.Border("right").Width
+	b.BorderBottom = b.Style.Border("bottom").Width
+	b.BorderLeft = b.Style.Border("left").Width
+
+	insets := b.PaddingLeft + b.PaddingRight + b.BorderLeft + b.BorderRight
+	if w := declaredWidth(b.Node, b.Style); w != "" {
+		if v := parseLength(w, b.Width, -1); v >= 0 {
+			b.Width = contentSize(b.Style, v, insets) + insets
+		}
 	}
-
-	b.BorderTop = parseLength(b.Style["border-top-width"].Value, b.Width, 0)
-	b.BorderRight = parseLength(b.Style["border-right-width"].Value, b.Width, 0)
-	b.BorderBottom = parseLength(b.Style["border-bottom-width"].Value, b.Width, 0)
-	b.BorderLeft = parseLength(b.Style["border-left-width"].Value, b.Width, 0)
-
-	b.Width = b.Width - b.PaddingLeft - b.PaddingRight - b.BorderLeft - b.BorderRight
 }
 
 // layoutChildren performs layout for all children
@@ -138,7 +99,8 @@ func (b *BlockBox) layoutChildren() {
 // calculateHeight calculates the height of the block box
 func (b *BlockBox) calculateHeight() {
 	if heightProp, exists := b.Style["height"]; exists {
-		b.Height = parseLength(heightProp.Value, b.Width, 0)
+		insets := b.PaddingTop + b.PaddingBottom + b.BorderTop + b.BorderBottom
+		b.Height = contentSize(b.Style, parseLength(heightProp.Value, b.Width, 0), insets) + insets
 		return
 	}
 
@@ -0,0 +1,189 @@
+package layout
+
+import (
+	"strconv"
+	"strings"
+)
+
+// evalCalc evaluates a CSS calc() expression such as "calc(100% - 48px)".
+// Operands are resolved with parseLength against containerSize; +, -, * and
+// / are supported with the usual precedence, along with parentheses and
+// nested calc(). Unitless operands are plain numbers, so "calc(2 * 10px)" and
+// "calc(100% / 3)" work. It reports false for malformed expressions.
+func evalCalc(value string, containerSize float64) (float64, bool) {
+	v := strings.TrimSpace(value)
+	if !isCalc(v) {
+		return 0, false
+	}
+	p := &calcParser{src: v[len("calc"):], size: containerSize}
+	p.skipSpace()
+	val, ok := p.factor()
+	if !ok {
+		return 0, false
+	}
+	p.skipSpace()
+	if p.pos != len(p.src) {
+		return 0, false
+	}
+	return val.n, true
+}
+
+// isCalc reports whether a value is a calc() expression
+func isCalc(v string) bool {
+	return len(v) > 5 && strings.EqualFold(v[:5], "calc(")
+}
+
+// calcValue is an operand of a calc() expression; number is set for unitless
+// values, which are the only ones allowed on one side of * and /
+type calcValue struct {
+	n      float64
+	number bool
+}
+
+// calcParser is a recursive descent parser over a calc() expression
+type calcParser struct {
+	src  string
+	pos  int
+	size float64
+}
+
+func (p *calcParser) skipSpace() {
+	for p.pos < len(p.src) && (p.src[p.pos] == ' ' || p.src[p.pos] == '\t' || p.src[p.pos] == '\n') {
+		p.pos++
+	}
+}
+
+// sum parses terms joined by + and -
+func (p *calcParser) sum() (calcValue, bool) {
+	left, ok := p.product()
+	if !ok {
+		return left, false
+	}
+	for {
+		p.skipSpace()
+		if p.pos >= len(p.src) || (p.src[p.pos] != '+' && p.src[p.pos] != '-') {
+			return left, true
+		}
+		op := p.src[p.pos]
+		p.pos++
+		p.skipSpace()
+		right, ok := p.product()
+		if !ok {
+			return left, false
+		}
+		if op == '+' {
+			left.n += right.n
+		} else {
+			left.n -= right.n
+		}
+		left.number = left.number && right.number
+	}
+}
+
+// product parses factors joined by * and /
+func (p *calcParser) product() (calcValue, bool) {
+	left, ok := p.factor()
+	if !ok {
+		return left, false
+	}
+	for {
+		p.skipSpace()
+		if p.pos >= len(p.src) || (p.src[p.pos] != '*' && p.src[p.pos] != '/') {
+			return left, true
+		}
+		op := p.src[p.pos]
+		p.pos++
+		p.skipSpace()
+		right, ok := p.factor()
+		if !ok {
+			return left, false
+		}
+		switch {
+		case op == '*' && (left.number || right.number):
+			left = calcValue{n: left.n * right.n, number: left.number && right.number}
+		case op == '/' && right.number && right.n != 0:
+			left.n /= right.n
+		default:
+			return left, false
+		}
+	}
+}
+
+// factor parses a parenthesized expression, a nested calc() or an operand
+func (p *calcParser) factor() (calcValue, bool) {
+	if isCalc(p.src[p.pos:]) {
+		p.pos += len("calc")
+	}
+	if p.pos < len(p.src) && p.src[p.pos] == '(' {
+		p.pos++
+		p.skipSpace()
+		val, ok := p.sum()
+		p.skipSpace()
+		if !ok || p.pos >= len(p.src) || p.src[p.pos] != ')' {
+			return val, false
+		}
+		p.pos++
+		return val, true
+	}
+
+	start := p.pos
+	if p.pos < len(p.src) && (p.src[p.pos] == '-' || p.src[p.pos] == '+') {
+		p.pos++
+	}
+	for p.pos < len(p.src) {
+		c := p.src[p.pos]
+		if c == ' ' || c == '\t' || c == '\n' || c == '(' || c == ')' || c == '*' || c == '/' || c == '+' ||
+			(c == '-' && p.pos > start && !isLetter(p.src[p.pos-1])) {
+			break
+		}
+		p.pos++
+	}
+	tok := p.src[start:p.pos]
+	if tok == "" {
+		return calcValue{}, false
+	}
+	if n, err := strconv.ParseFloat(tok, 64); err == nil {
+		return calcValue{n: n, number: true}, true
+	}
+	const invalid = -1e308
+	n := parseLength(tok, p.size, invalid)
+	if n == invalid {
+		return calcValue{}, false
+	}
+	return calcValue{n: n}, true
+}
+
+// isLetter reports whether c is an ASCII letter
+func isLetter(c byte) bool {
+	return c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z'
+}
+
+// splitValues splits a space separated list of values, keeping function
+// arguments such as calc(100% - 10px) together
+func splitValues(v string) []string {
+	var parts []string
+	depth, start := 0, -1
+	for i := 0; i < len(v); i++ {
+		switch c := v[i]; {
+		case c == '(':
+			depth++
+		case c == ')':
+			if depth > 0 {
+				depth--
+			}
+		case (c == ' ' || c == '\t' || c == '\n' || c == '\r') && depth == 0:
+			if start >= 0 {
+				parts = append(parts, v[start:i])
+				start = -1
+			}
+			continue
+		}
+		if start < 0 {
+			start = i
+		}
+	}
+	if start >= 0 {
+		parts = append(parts, v[start:])
+	}
+	return parts
+}
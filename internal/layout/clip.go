@@ -0,0 +1,109 @@
+package layout
+
+import (
+	"math"
+	"slices"
+)
+
+// Clip is the area a box is drawn within, relative to the position of the
+// box so that it follows the box when pagination moves it. It is set on the
+// boxes inside an element that clips its overflow.
+type Clip struct {
+	X, Y, Width, Height float64
+}
+
+// rect is an absolute rectangle on the layout canvas
+type rect struct {
+	left, top, right, bottom float64
+}
+
+// paddingRect returns the padding box of a block, which clips its overflow
+func paddingRect(b *BlockBox) rect {
+	return rect{
+		left:   b.X + b.Style.Border("left").Width,
+		top:    b.Y + b.Style.Border("top").Width,
+		right:  b.X + b.Width - b.Style.Border("right").Width,
+		bottom: b.Y + b.Height - b.Style.Border("bottom").Width,
+	}
+}
+
+// intersect returns the overlap of two rectangles, which may be empty
+func (r rect) intersect(o rect) rect {
+	return rect{
+		left:   math.Max(r.left, o.left),
+		top:    math.Max(r.top, o.top),
+		right:  math.Max(math.Min(r.right, o.right), math.Max(r.left, o.left)),
+		bottom: math.Max(math.Min(r.bottom, o.bottom), math.Max(r.top, o.top)),
+	}
+}
+
+// relativeTo expresses the rectangle as a Clip for a box at (x, y)
+func (r rect) relativeTo(x, y float64) *Clip {
+	return &Clip{X: r.left - x, Y: r.top - y, Width: r.right - r.left, Height: r.bottom - r.top}
+}
+
+// applyClips sets the clip of every box in a finished layout tree that lies
+// inside one or more elements clipping their overflow, and drops the boxes
+// clipped away entirely, so that they do not run on to later pages. clip is
+// the area inherited from the ancestors of b, or nil.
+func applyClips(b Box, clip *rect) {
+	switch c := b.(type) {
+	case *BlockBox:
+		if clip != nil {
+			c.Clip = clip.relativeTo(c.X, c.Y)
+		}
+		// Form controls clip their text like scrolling boxes
+		if c.Style.ClipsOverflow() || c.Widget != nil {
+			inner := paddingRect(c)
+			if clip != nil {
+				inner = clip.intersect(inner)
+			}
+			clip = &inner
+		}
+		for _, ch := range c.Children {
+			applyClips(ch, clip)
+		}
+		c.Children = dropClipped(c.Children, clip)
+	case *InlineBox:
+		if clip != nil {
+			c.Clip = clip.relativeTo(c.X, c.Y)
+		}
+		for _, ch := range c.Children {
+			applyClips(ch, clip)
+		}
+		c.Children = dropClipped(c.Children, clip)
+	case *ImageBox:
+		if clip != nil {
+			c.Clip = clip.relativeTo(c.X, c.Y)
+		}
+	}
+}
+
+// dropClipped removes the boxes that lie outside clip with all their
+// descendants
+func dropClipped(boxes []Box, clip *rect) []Box {
+	if clip == nil {
+		return boxes
+	}
+	return slices.DeleteFunc(boxes, func(b Box) bool { return outside(b, *clip) })
+}
+
+// outside reports whether a box and its descendants lie outside r
+func outside(b Box, r rect) bool {
+	if b.GetX() < r.right && b.GetX()+b.GetWidth() > r.left && b.GetY() < r.bottom && b.GetY()+b.GetHeight() > r.top {
+		return false
+	}
+	var children []Box
+	switch c := b.(type) {
+	case *BlockBox:
+		children = c.Children
+	case *InlineBox:
+		children = c.Children
+	}
+	for _, ch := range children {
+		if !outside(ch, r) {
+			return false
+		}
+	}
+	return true
+}
@@ -1,375 +1,40 @@
 package layout
 
 import (
-	"fmt"
+	"context"
 	"math"
-	"strconv"
 	"strings"
-	"sync"
 	"unicode"
 	"unicode/utf8"
 
-	"codeberg.org/go-pdf/fpdf"
+	"github.com/gompdf/gompdf/internal/logging"
 	"github.com/gompdf/gompdf/internal/parser/html"
+	"github.com/gompdf/gompdf/internal/res"
 	"github.com/gompdf/gompdf/internal/style"
+	"github.com/gompdf/gompdf/internal/text"
 	xhtml "golang.org/x/net/html"
 )
 
-// Singleton PDF instance for text measurement using go-pdf/fpdf metrics
-var (
-	measureOnce sync.Once
-	measurePDF  *fpdf.Fpdf
-	measureMu   sync.Mutex
-)
-
-// orientation is a package variable to control PDF orientation for measurement
-var orientation = "P" // Default to portrait
-
-// SetMeasurementOrientation sets the orientation for text measurement
-func SetMeasurementOrientation(o string) {
-	if o == "L" || o == "P" {
-		orientation = o
-	}
-}
-
-// computeTableColumnWidths determines consistent column widths for a table row.
-// It prefers widths declared on the first header row (<thead> > <tr>) if present.
-// Otherwise it uses the current row's cells. It honors percentage and px widths
-// and supports colspan by dividing the declared width evenly across spanned columns.
-func (e *Engine) computeTableColumnWidths(row *BlockBox, totalWidth, gap float64) ([]float64, int) {
-    if row == nil || row.Node == nil {
-        return nil, 0
-    }
-    // Find the ancestor <table>
-    t := row.Node.Parent
-    for t != nil && !strings.EqualFold(t.Data, "table") {
-        t = t.Parent
-    }
-    if t == nil {
-        // Not inside a table
-        cells := 0
-        for _, ch := range row.Children {
-            if bb, ok := ch.(*BlockBox); ok && bb.Node != nil {
-                tag := strings.ToLower(bb.Node.Data)
-                if tag == "td" || tag == "th" { cells++ }
-            }
-        }
-        if cells == 0 { return nil, 0 }
-        eff := totalWidth - gap*math.Max(0, float64(cells-1))
-        w := eff / float64(cells)
-        out := make([]float64, cells)
-        for i := range out { out[i] = w }
-        return out, cells
-    }
-
-    // Helper to scan a <tr> node's children for widths/colspans using computed styles
-    type colSpec struct{ width float64; span int; hasWidth bool }
-    scanTR := func(tr *html.Node) ([]colSpec, int) {
-        specs := []colSpec{}
-        colCount := 0
-        for c := tr.FirstChild; c != nil; c = c.NextSibling {
-            if c.Type != xhtml.ElementNode { continue }
-            tag := strings.ToLower(c.Data)
-            if tag != "th" && tag != "td" { continue }
-            span := 1
-            for _, a := range c.Attr {
-                if strings.EqualFold(a.Key, "colspan") {
-                    if n, err := strconv.Atoi(strings.TrimSpace(a.Val)); err == nil && n > 1 { span = n }
-                }
-            }
-            wv := 0.0
-            hasW := false
-            if st, ok := e.styles[c]; ok {
-                if wp, ok2 := st["width"]; ok2 && strings.TrimSpace(wp.Value) != "" {
-                    wv = parseLength(wp.Value, totalWidth, 0)
-                    if wv > 0 { hasW = true }
-                }
-            }
-            if !hasW {
-                for _, a := range c.Attr {
-                    if strings.EqualFold(a.Key, "width") {
-                        v := strings.TrimSpace(a.Val)
-                        // Support percentage or pixels
-                        if strings.HasSuffix(v, "%") || strings.HasSuffix(v, "px") {
-                            wv = parseLength(v, totalWidth, 0)
-                            if wv > 0 { hasW = true }
-                        } else if f, err := strconv.ParseFloat(v, 64); err == nil && f > 0 {
-                            wv = f
-                            hasW = true
-                        }
-                    }
-                }
-            }
-            specs = append(specs, colSpec{width: wv, span: span, hasWidth: hasW})
-            colCount += span
-        }
-        return specs, colCount
-    }
-
-    // Prefer header row specs
-    var specs []colSpec
-    cols := 0
-    // Locate the first <tr> within <thead>
-    for n := t.FirstChild; n != nil && cols == 0; n = n.NextSibling {
-        if n.Type == xhtml.ElementNode && strings.EqualFold(n.Data, "thead") {
-            for tr := n.FirstChild; tr != nil && cols == 0; tr = tr.NextSibling {
-                if tr.Type == xhtml.ElementNode && strings.EqualFold(tr.Data, "tr") {
-                    specs, cols = scanTR(tr)
-                }
-            }
-        }
-    }
-    // If no thead widths, use current row
-    if cols == 0 {
-        specs, cols = scanTR(row.Node)
-    }
-    if cols == 0 {
-        return nil, 0
-    }
-
-    effective := totalWidth - gap*math.Max(0, float64(cols-1))
-    colWidths := make([]float64, cols)
-
-    // First, assign declared widths
-    idx := 0
-    totalDeclared := 0.0
-    undeclaredCols := 0
-    for _, s := range specs {
-        if s.hasWidth {
-            // divide width evenly across spanned columns
-            share := s.width / float64(s.span)
-            for j := 0; j < s.span && idx < cols; j++ {
-                colWidths[idx] = share
-                totalDeclared += share
-                idx++
-            }
-        } else {
-            for j := 0; j < s.span && idx < cols; j++ {
-                // mark as undeclared
-                undeclaredCols++
-                idx++
-            }
-        }
-    }
-
-    remaining := effective - totalDeclared
-    if remaining < 0 { remaining = 0 }
-    // Count how many zeros remain
-    zeroCount := 0
-    for i := 0; i < cols; i++ { if colWidths[i] == 0 { zeroCount++ } }
-    if zeroCount > 0 {
-        each := remaining / float64(zeroCount)
-        for i := 0; i < cols; i++ {
-            if colWidths[i] == 0 { colWidths[i] = each }
-        }
-    }
-    return colWidths, cols
-}
-
-func initMeasurePDF() {
-	measurePDF = fpdf.New(orientation, "pt", "", "")
-	measurePDF.SetFont("Helvetica", "", 12)
-}
-
-// measureTextWidth returns a font-aware width using fpdf metrics
-func measureTextWidth(text string, fontSize float64, st style.ComputedStyle) float64 {
-	if text == "" || fontSize <= 0 {
-		return 0
-	}
-	measureOnce.Do(initMeasurePDF)
-	measureMu.Lock()
-	defer measureMu.Unlock()
-	fam, sty := resolveFontFromStyle(st)
-	measurePDF.SetFont(fam, sty, fontSize)
-	return measurePDF.GetStringWidth(text)
-}
-
-// resolveFontFromStyle maps CSS-like style to core PDF font family and style
-func resolveFontFromStyle(st style.ComputedStyle) (string, string) {
-	family := "Helvetica"
-	if ff, ok := st["font-family"]; ok && strings.TrimSpace(ff.Value) != "" {
-		first := strings.Split(ff.Value, ",")[0]
-		first = strings.TrimSpace(strings.Trim(first, "'\""))
-		switch strings.ToLower(first) {
-		case "arial", "helvetica", "sans-serif":
-			family = "Helvetica"
-		case "times", "times new roman", "serif":
-			family = "Times"
-		case "courier", "courier new", "monospace":
-			family = "Courier"
-		}
-	}
-	styleStr := ""
-	if fw, ok := st["font-weight"]; ok {
-		v := strings.TrimSpace(fw.Value)
-		if v == "bold" || v == "700" || v == "800" || v == "900" {
-			styleStr += "B"
-		}
-	}
-	if fs, ok := st["font-style"]; ok {
-		if strings.TrimSpace(fs.Value) == "italic" {
-			styleStr += "I"
-		}
-	}
-	return family, styleStr
-}
-
 // Options represents options for the layout engine
 type Options struct {
 	Width  float64
 	Height float64
-	DPI    float64
-}
-
-// layoutTableRow arranges the direct children <td>/<th> of a <tr> horizontally
-// with either explicit CSS widths or equal-width distribution, and sets the row height
-// to the max of the cell heights. It also shifts cell descendants when repositioning.
-func (e *Engine) layoutTableRow(row *BlockBox) {
-	if row == nil {
-		return
-	}
-	// Collect cell boxes
-	var cells []*BlockBox
-	for _, ch := range row.Children {
-		if bb, ok := ch.(*BlockBox); ok && bb.Node != nil {
-			tag := strings.ToLower(bb.Node.Data)
-			if tag == "td" || tag == "th" {
-				cells = append(cells, bb)
-			}
-		}
-	}
-	if len(cells) == 0 {
-		return
-	}
-
-	totalWidth := row.Width
-	// Determine horizontal gap between cells. Prefer explicit border-spacing from the nearest table ancestor
-	// or the row itself. If not set, try 'gap' or 'column-gap'. If nothing set, default to 0.
-	cellGapX := 0.0
-	// Helper to extract spacing from a style map
-	extractGap := func(st style.ComputedStyle) (float64, bool) {
-		if st == nil {
-			return 0, false
-		}
-		if bs, ok := st["border-spacing"]; ok && strings.TrimSpace(bs.Value) != "" {
-			parts := strings.Fields(bs.Value)
-			if len(parts) > 0 {
-				return parseLength(parts[0], row.Width, 0), true
-			}
-		}
-		if g, ok := st["gap"]; ok && strings.TrimSpace(g.Value) != "" {
-			return parseLength(g.Value, row.Width, 0), true
-		}
-		if cg, ok := st["column-gap"]; ok && strings.TrimSpace(cg.Value) != "" {
-			return parseLength(cg.Value, row.Width, 0), true
-		}
-		return 0, false
-	}
-	// 1) Check the row's own style
-	if v, ok := extractGap(row.Style); ok {
-		cellGapX = v
-	} else if row != nil && row.Node != nil {
-		// 2) Walk up to find the nearest table's style
-		findTable := row.Node.Parent
-		for findTable != nil && !strings.EqualFold(findTable.Data, "table") {
-			findTable = findTable.Parent
-		}
-		if findTable != nil {
-			if st, ok := e.styles[findTable]; ok {
-				if v, ok2 := extractGap(st); ok2 {
-					cellGapX = v
-				}
-			}
-		}
-	}
-	if cellGapX < 0 { cellGapX = 0 }
-	// Build per-column widths for the table, respecting widths from a header row when present
-    colWidths, colCount := e.computeTableColumnWidths(row, totalWidth, cellGapX)
-    if colCount == 0 {
-        // Fallback: treat each cell as one column
-        colCount = len(cells)
-        effective := totalWidth - cellGapX*math.Max(0, float64(colCount-1))
-        w := 0.0
-        if colCount > 0 { w = effective / float64(colCount) }
-        colWidths = make([]float64, colCount)
-        for i := 0; i < colCount; i++ { colWidths[i] = w }
-    }
-
-    // Column positions
-    colX := make([]float64, colCount)
-    cx := row.X
-    for i := 0; i < colCount; i++ {
-        colX[i] = cx
-        cx += colWidths[i]
-        if i < colCount-1 { cx += cellGapX }
-    }
-
-    // Place cells using colspan
-    x := row.X
-    maxH := 0.0
-    colIdx := 0
-    for _, cell := range cells {
-        span := 1
-        if cell.Node != nil {
-            for _, a := range cell.Node.Attr {
-                if strings.EqualFold(a.Key, "colspan") {
-                    if n, err := strconv.Atoi(strings.TrimSpace(a.Val)); err == nil && n > 1 {
-                        span = n
-                    }
-                }
-            }
-        }
-
-        if colIdx >= len(colX) {
-            colIdx = len(colX) - 1
-        }
-
-        // Compute width across spanned columns + inner gaps
-        w := 0.0
-        for j := 0; j < span && colIdx+j < len(colWidths); j++ {
-            w += colWidths[colIdx+j]
-        }
-        if span > 1 {
-            w += cellGapX * float64(span-1)
-        }
-
-        oldX, oldY := cell.X, cell.Y
-        newX, newY := colX[colIdx], row.Y
-        dx, dy := newX-oldX, newY-oldY
-
-        // Apply position and width
-        cell.X = newX
-        cell.Y = newY
-        cell.Width = w
-
-        e.shiftDescendants(cell, dx, dy)
-
-        if len(cell.Children) > 0 {
-            last := cell.Children[len(cell.Children)-1]
-            calcH := last.GetY() + last.GetHeight() - cell.Y
-            if calcH < 20 {
-                calcH = 20
-            }
-            cell.Height = calcH
-        } else if cell.Height == 0 {
-            cell.Height = 20
-        }
-
-        if cell.Height > maxH {
-            maxH = cell.Height
-        }
-
-        // Advance by spanned columns
-        x = newX + w
-        if colIdx+span < colCount {
-            x += cellGapX
-        }
-        colIdx += span
-    }
-	if maxH < 20 {
-		maxH = 20
-	}
-	row.Height = maxH
+	// DPI is the resolution of the output, which chooses among the
+	// candidates of srcset attributes
+	DPI float64
+	// MediaType is the media type the media conditions of <source> and
+	// sizes attributes are evaluated for; "" for print
+	MediaType string
+	// IgnoreImageOrientation lays out and draws JPEG images as they are
+	// stored, not turned upright by their EXIF orientation
+	IgnoreImageOrientation bool
+	// Margins of the page. Content is laid out in one continuous flow as
+	// wide as the page's content area, starting at its top left corner;
+	// pagination cuts the flow into pages.
+	MarginTop    float64
+	MarginRight  float64
+	MarginBottom float64
+	MarginLeft   float64
 }
 
 // shiftDescendants shifts all descendant boxes of the given block by (dx, dy)
@@ -393,14 +58,55 @@ func (e *Engine) shiftDescendants(b *BlockBox, dx, dy float64) {
 	}
 }
 
+// fitTolerance is the width by which a line may overflow its box and still
+// fit, so that content does not wrap in a box sized to its intrinsic width
+// when the width comes out a rounding error narrower, as it does for the
+// columns of a table nested in a cell
+const fitTolerance = 0.01
+
 // Engine handles the layout process
 type Engine struct {
 	options Options
 	styles  map[*html.Node]style.ComputedStyle
+	// firstLine holds the ::first-line styles of elements; see
+	// SetFirstLineStyles
+	firstLine map[*html.Node]style.ComputedStyle
+	// loader resolves images to read their intrinsic dimensions
+	loader *res.Loader
+	// ctx allows a long layout to be abandoned; see SetContext
+	ctx context.Context
+	// logger receives debug output; nil discards it
+	logger logging.Logger
+	// floats lists the floats of the current block formatting context that
+	// line boxes flow around; floated marks every box placed as a float
+	floats  []floatArea
+	floated map[Box]bool
+	// marginOwners maps a block whose top margin collapsed with that of
+	// its parent to the outermost block holding the collapsed margin, and
+	// marginsAbove holds the bottom margin of the block before a block
+	marginOwners map[*BlockBox]*BlockBox
+	marginsAbove map[*BlockBox]float64
+	// running lists the running elements taken out of the flow
+	running []*RunningElement
+	// sink receives the boxes as they are laid out; see SetSink. open
+	// holds the blocks whose children go to it, innermost last, announced
+	// those it was told of and standIns the boxes left in place of those
+	// handed over.
+	sink      Sink
+	open      []*BlockBox
+	announced map[*BlockBox]bool
+	standIns  map[Box]bool
+	// listValues holds the ordinal values of the items of the lists laid
+	// out so far; see listItemValue
+	listValues map[*html.Node]int
+	// measurer measures text; see SetMeasurer
+	measurer *Measurer
+	// turning marks the blocks whose content is being laid out in a
+	// turned frame; see layoutVertical
+	turning map[*html.Node]bool
 	Debug   bool
 	Width   float64
 	Height  float64
-	Margin  float64
 }
 
 // NewEngine creates a new layout engine
@@ -410,12 +116,17 @@ func NewEngine() *Engine {
 			Width:  595.28, // Default A4 width in points
 			Height: 841.89, // Default A4 height in points
 			DPI:    96,     // Default DPI
+			MarginTop:    50, // Default margins in points
+			MarginRight:  50,
+			MarginBottom: 50,
+			MarginLeft:   50,
 		},
-		styles: make(map[*html.Node]style.ComputedStyle),
-		Debug:  true,
-		Width:  595.28, // Default A4 width in points
-		Height: 841.89, // Default A4 height in points
-		Margin: 50,     // Default margin in points
+		styles:  make(map[*html.Node]style.ComputedStyle),
+		floated: make(map[Box]bool),
+		turning: make(map[*html.Node]bool),
+		Debug:   true,
+		Width:   595.28, // Default A4 width in points
+		Height:  841.89, // Default A4 height in points
 	}
 }
 
@@ -424,9 +135,6 @@ func (e *Engine) SetOptions(options Options) {
 	e.options = options
 	e.Width = options.Width
 	e.Height = options.Height
-	if e.Margin == 0 {
-		e.Margin = 50 // Default margin
-	}
 }
 
 // SetStyles sets the computed styles for the layout engine
@@ -434,36 +142,89 @@ func (e *Engine) SetStyles(styles map[*html.Node]style.ComputedStyle) {
 	e.styles = styles
 }
 
+// SetFirstLineStyles sets the ::first-line styles of elements, which apply
+// to the first line of their text
+func (e *Engine) SetFirstLineStyles(styles map[*html.Node]style.ComputedStyle) {
+	e.firstLine = styles
+}
+
+// SetLoader sets the resource loader used to read image dimensions
+func (e *Engine) SetLoader(loader *res.Loader) {
+	e.loader = loader
+}
+
+// SetMeasurer sets the measurer of text, which knows the fonts the renderer
+// draws with. Without one, text is measured with the core fonts.
+func (e *Engine) SetMeasurer(m *Measurer) {
+	e.measurer = m
+}
+
+// measureTextWidth returns the width of text in the font of a computed style
+func (e *Engine) measureTextWidth(text string, fontSize float64, st style.ComputedStyle) float64 {
+	if e.measurer == nil {
+		e.measurer = NewMeasurer(nil, nil)
+	}
+	return e.measurer.textWidth(text, fontSize, st)
+}
+
+// SetContext sets a context that aborts layout once it is done. The tree
+// built so far is returned; callers should check ctx.Err() afterwards.
+func (e *Engine) SetContext(ctx context.Context) {
+	e.ctx = ctx
+}
+
+// SetLogger sets the logger that receives the engine's diagnostics. Debug
+// tracing is only produced when Debug is set.
+func (e *Engine) SetLogger(logger logging.Logger) {
+	e.logger = logger
+}
+
+// debugf forwards a debug message to the engine's logger
+func (e *Engine) debugf(format string, args ...any) {
+	logging.Or(e.logger).Debugf(format, args...)
+}
+
 // Layout creates a layout tree from a document
 func (e *Engine) Layout(doc interface{}) *BlockBox {
-	// Create the root box
+	e.floats = nil
+	e.floated = make(map[Box]bool)
+	e.marginOwners = make(map[*BlockBox]*BlockBox)
+	e.marginsAbove = make(map[*BlockBox]float64)
+	e.running = nil
+	e.listValues = nil
+	e.open = nil
+	e.announced = make(map[*BlockBox]bool)
+	e.standIns = make(map[Box]bool)
+
+	// Create the root box, the page's content area
+	o := e.options
 	rootBox := &BlockBox{
-		X:        e.Margin,
-		Y:        e.Margin,
-		Width:    e.Width - (2 * e.Margin),
-		Height:   e.Height - (2 * e.Margin),
+		X:        o.MarginLeft,
+		Y:        o.MarginTop,
+		Width:    e.Width - o.MarginLeft - o.MarginRight,
+		Height:   e.Height - o.MarginTop - o.MarginBottom,
 		Children: []Box{},
 	}
 
 	if e.Debug {
-		fmt.Printf("Creating layout with root box: x=%.2f, y=%.2f, width=%.2f, height=%.2f\n",
+		e.debugf("Creating layout with root box: x=%.2f, y=%.2f, width=%.2f, height=%.2f\n",
 			rootBox.X, rootBox.Y, rootBox.Width, rootBox.Height)
 	}
 
 	var htmlNode *html.Node
 	if htmlDoc, ok := doc.(*html.Document); ok {
 		if e.Debug {
-			fmt.Println("Processing standard HTML document")
+			e.debugf("Processing standard HTML document")
 		}
 		htmlNode = htmlDoc.Root
 	} else if node, ok := doc.(*html.Node); ok {
 		if e.Debug {
-			fmt.Println("Processing HTML node directly")
+			e.debugf("Processing HTML node directly")
 		}
 		htmlNode = node
 	} else {
 		if e.Debug {
-			fmt.Printf("Unknown document type: %T", doc)
+			e.debugf("Unknown document type: %T", doc)
 		}
 		return rootBox
 	}
@@ -471,6 +232,9 @@ func (e *Engine) Layout(doc interface{}) *BlockBox {
 	if e.Debug {
 		e.debugDocumentStructure(htmlNode, 0)
 	}
+	if e.sink != nil {
+		e.open = append(e.open, rootBox)
+	}
 	var htmlElement, bodyElement *html.Node
 
 	if htmlNode.Type == xhtml.DocumentNode { // DocumentNode
@@ -486,7 +250,7 @@ func (e *Engine) Layout(doc interface{}) *BlockBox {
 
 	if htmlElement != nil {
 		if e.Debug {
-			fmt.Println("Found HTML element, looking for BODY")
+			e.debugf("Found HTML element, looking for BODY")
 		}
 
 		// Look for BODY element in the HTML element's children
@@ -499,7 +263,7 @@ func (e *Engine) Layout(doc interface{}) *BlockBox {
 	} else {
 		// If we didn't find the HTML element, look for BODY directly
 		if e.Debug {
-			fmt.Println("No HTML element found, looking for BODY directly")
+			e.debugf("No HTML element found, looking for BODY directly")
 		}
 
 		// Look for BODY element in the document's children
@@ -525,16 +289,17 @@ func (e *Engine) Layout(doc interface{}) *BlockBox {
 
 		// Add HTML box to root
 		rootBox.Children = append(rootBox.Children, htmlBox)
+		e.openBlock(htmlBox, rootBox, htmlElement, nil)
 
 		if e.Debug {
-			fmt.Println("Created HTML box")
+			e.debugf("Created HTML box")
 		}
 	} else {
 		// Use root box as HTML box
 		htmlBox = rootBox
 
 		if e.Debug {
-			fmt.Println("Using root box as HTML box")
+			e.debugf("Using root box as HTML box")
 		}
 	}
 
@@ -552,21 +317,23 @@ func (e *Engine) Layout(doc interface{}) *BlockBox {
 
 		// Add BODY box to HTML box
 		htmlBox.Children = append(htmlBox.Children, bodyBox)
+		e.openBlock(bodyBox, htmlBox, bodyElement, nil)
 
 		if e.Debug {
-			fmt.Println("Created BODY box")
+			e.debugf("Created BODY box")
 		}
 
 		// Process all children of the BODY element
 		for child := bodyElement.FirstChild; child != nil; child = child.NextSibling {
 			e.processNode(child, bodyBox, 1)
+			e.flush(bodyBox)
 		}
 	} else {
 		// Use HTML box as BODY box
 		bodyBox = htmlBox
 
 		if e.Debug {
-			fmt.Println("No BODY element found, using HTML box as BODY box")
+			e.debugf("No BODY element found, using HTML box as BODY box")
 		}
 
 		// Process all children of the HTML element or document
@@ -582,7 +349,7 @@ func (e *Engine) Layout(doc interface{}) *BlockBox {
 			// Skip HEAD element and its children
 			if child.Type == xhtml.ElementNode && strings.ToLower(child.Data) == "head" {
 				if e.Debug {
-					fmt.Println("Skipping HEAD element")
+					e.debugf("Skipping HEAD element")
 				}
 				continue
 			}
@@ -594,35 +361,44 @@ func (e *Engine) Layout(doc interface{}) *BlockBox {
 
 			// Process other children
 			e.processNode(child, bodyBox, 1)
+			e.flush(bodyBox)
 		}
 	}
 
-	// Adjust box heights based on children
+	// Adjust box heights based on children. The root establishes a block
+	// formatting context, so it also grows to contain floats.
 	if len(bodyBox.Children) > 0 {
-		lastChild := bodyBox.Children[len(bodyBox.Children)-1]
-		bodyBox.Height = lastChild.GetY() + lastChild.GetHeight() - bodyBox.Y
+		bottom := floatsBottom(e.floats)
+		if lastChild := e.lastInFlow(bodyBox); lastChild != nil {
+			bottom = math.Max(bottom, lastChild.GetY()+lastChild.GetHeight())
+		}
+		bodyBox.Height = bottom - bodyBox.Y
 	}
 
+	e.closeBlock(bodyBox)
+
 	if htmlBox != rootBox && len(htmlBox.Children) > 0 {
 		lastChild := htmlBox.Children[len(htmlBox.Children)-1]
 		htmlBox.Height = lastChild.GetY() + lastChild.GetHeight() - htmlBox.Y
 	}
+	e.closeBlock(htmlBox)
+	applyClips(rootBox, nil)
 
 	// Debug output
 	if e.Debug {
-		fmt.Printf("Final layout tree:\n")
-		fmt.Printf("Root box has %d children\n", len(rootBox.Children))
+		e.debugf("Final layout tree:\n")
+		e.debugf("Root box has %d children\n", len(rootBox.Children))
 
 		for i, child := range rootBox.Children {
-			fmt.Printf("  Child %d: type=%T, x=%.2f, y=%.2f, width=%.2f, height=%.2f\n",
+			e.debugf("  Child %d: type=%T, x=%.2f, y=%.2f, width=%.2f, height=%.2f\n",
 				i, child, child.GetX(), child.GetY(), child.GetWidth(), child.GetHeight())
 
 			// If it's a block box, check its children too
 			if blockChild, ok := child.(*BlockBox); ok {
-				fmt.Printf("    Block child has %d children\n", len(blockChild.Children))
+				e.debugf("    Block child has %d children\n", len(blockChild.Children))
 
 				for j, grandchild := range blockChild.Children {
-					fmt.Printf("      Grandchild %d: type=%T, x=%.2f, y=%.2f, width=%.2f, height=%.2f\n",
+					e.debugf("      Grandchild %d: type=%T, x=%.2f, y=%.2f, width=%.2f, height=%.2f\n",
 						j, grandchild, grandchild.GetX(), grandchild.GetY(), grandchild.GetWidth(), grandchild.GetHeight())
 				}
 			}
@@ -636,21 +412,24 @@ func (e *Engine) Layout(doc interface{}) *BlockBox {
 func (e *Engine) processNode(node *html.Node, parentBox *BlockBox, depth int) {
 	if node == nil {
 		if e.Debug {
-			fmt.Printf("Skipping nil node\n")
+			e.debugf("Skipping nil node\n")
 		}
 		return
 	}
+	if e.stopped() {
+		return
+	}
 
 	// Debug output
 	if e.Debug {
 		indent := strings.Repeat("  ", depth)
-		fmt.Printf("%sProcessing node: type=%d, data='%s', parent=%T\n",
+		e.debugf("%sProcessing node: type=%d, data='%s', parent=%T\n",
 			indent, node.Type, node.Data, parentBox)
 
 		// Print attributes for element nodes
 		if node.Type == xhtml.ElementNode { // ElementNode
 			for _, attr := range node.Attr {
-				fmt.Printf("%s  Attr: %s='%s'\n", indent, attr.Key, attr.Val)
+				e.debugf("%s  Attr: %s='%s'\n", indent, attr.Key, attr.Val)
 			}
 		}
 	}
@@ -658,21 +437,21 @@ func (e *Engine) processNode(node *html.Node, parentBox *BlockBox, depth int) {
 	// Handle different node types
 	if node.Type == xhtml.CommentNode { // CommentNode
 		if e.Debug {
-			fmt.Printf("Skipping comment node\n")
+			e.debugf("Skipping comment node\n")
 		}
 		return
 	}
 
 	if node.Type == xhtml.DoctypeNode { // DoctypeNode
 		if e.Debug {
-			fmt.Printf("Skipping doctype node\n")
+			e.debugf("Skipping doctype node\n")
 		}
 		return
 	}
 
 	if node.Type == xhtml.DocumentNode { // DocumentNode
 		if e.Debug {
-			fmt.Printf("Processing document node\n")
+			e.debugf("Processing document node\n")
 		}
 		// Process all children of the document node
 		for child := node.FirstChild; child != nil; child = child.NextSibling {
@@ -684,13 +463,13 @@ func (e *Engine) processNode(node *html.Node, parentBox *BlockBox, depth int) {
 	if node.Type == xhtml.TextNode { // TextNode
 		if strings.TrimSpace(node.Data) == "" {
 			if e.Debug {
-				fmt.Printf("Skipping whitespace-only text node\n")
+				e.debugf("Skipping whitespace-only text node\n")
 			}
 			return
 		}
 
 		if e.Debug {
-			fmt.Printf("Processing text node: '%s'\n", strings.TrimSpace(node.Data))
+			e.debugf("Processing text node: '%s'\n", strings.TrimSpace(node.Data))
 		}
 
 		effectiveStyle := style.ComputedStyle{}
@@ -698,7 +477,7 @@ func (e *Engine) processNode(node *html.Node, parentBox *BlockBox, depth int) {
 			if ps, ok := e.styles[parentBox.GetNode()]; ok {
 				effectiveStyle = ps
 				if e.Debug {
-					fmt.Printf("Found parent box style for text node: %v\n", effectiveStyle)
+					e.debugf("Found parent box style for text node: %v\n", effectiveStyle)
 				}
 			}
 		}
@@ -713,7 +492,7 @@ func (e *Engine) processNode(node *html.Node, parentBox *BlockBox, depth int) {
 				}
 				effectiveStyle = merged
 				if e.Debug {
-					fmt.Printf("Merged parent element style for text node: %v\n", ps)
+					e.debugf("Merged parent element style for text node: %v\n", ps)
 				}
 			}
 		}
@@ -741,18 +520,12 @@ func (e *Engine) processNode(node *html.Node, parentBox *BlockBox, depth int) {
 		fontSize := parseLength(fontSizeVal, 0, 16)
 
 		// Determine vertical position below the previous sibling; include parent padding/border for first line
-		childY := parentBox.Y
-		if len(parentBox.Children) > 0 {
-			last := parentBox.Children[len(parentBox.Children)-1]
+		childY := parentBox.Y + parentBox.PaddingTop + parentBox.BorderTop
+		if last := e.lastInFlow(parentBox); last != nil {
 			childY = last.GetY() + last.GetHeight()
-		} else {
-			childY = parentBox.Y + parentBox.PaddingTop + parentBox.BorderTop
 		}
 
-		lineHeight := 1.25 * fontSize
-		if lhProp, ok := effectiveStyle["line-height"]; ok && strings.TrimSpace(lhProp.Value) != "" {
-			lineHeight = parseLength(lhProp.Value, 0, lineHeight)
-		}
+		lineHeight := parseLineHeight(effectiveStyle["line-height"].Value, fontSize, 1.25*fontSize)
 
 		// Respect parent content box (padding/border) for X/Width so padding works in TD/TH
 		contentX := parentBox.X + parentBox.PaddingLeft + parentBox.BorderLeft
@@ -760,6 +533,14 @@ func (e *Engine) processNode(node *html.Node, parentBox *BlockBox, depth int) {
 		if contentW < 0 {
 			contentW = 0
 		}
+		// Flow around floats beside the line
+		text := style.TransformText(strings.TrimSpace(node.Data), effectiveStyle["text-transform"].Value, true)
+		if len(e.floats) > 0 {
+			textW := e.measureTextWidth(text, fontSize, effectiveStyle)
+			var l, r float64
+			childY, l, r = e.fitLine(childY, lineHeight, math.Min(textW, contentW), contentX, contentX+contentW)
+			contentX, contentW = l, r-l
+		}
 		inlineBox := &InlineBox{
 			Node:   node,
 			Style:  effectiveStyle, // Use merged effective style (captures strong/em)
@@ -767,13 +548,13 @@ func (e *Engine) processNode(node *html.Node, parentBox *BlockBox, depth int) {
 			Y:      childY,
 			Width:  contentW,
 			Height: lineHeight, // add leading to avoid clipping descenders
-			Text:   strings.TrimSpace(node.Data),
+			Text:   text,
 		}
 
 		parentBox.Children = append(parentBox.Children, inlineBox)
 
 		if e.Debug {
-			fmt.Printf("Created inline box for text: x=%.2f, y=%.2f, width=%.2f, height=%.2f, text='%s'\n",
+			e.debugf("Created inline box for text: x=%.2f, y=%.2f, width=%.2f, height=%.2f, text='%s'\n",
 				inlineBox.X, inlineBox.Y, inlineBox.Width, inlineBox.Height, inlineBox.Text)
 		}
 		return
@@ -783,7 +564,7 @@ func (e *Engine) processNode(node *html.Node, parentBox *BlockBox, depth int) {
 		// Skip script and style elements
 		if strings.ToLower(node.Data) == "script" || strings.ToLower(node.Data) == "style" {
 			if e.Debug {
-				fmt.Printf("Skipping %s element\n", node.Data)
+				e.debugf("Skipping %s element\n", node.Data)
 			}
 			return
 		}
@@ -810,20 +591,29 @@ func (e *Engine) processNode(node *html.Node, parentBox *BlockBox, depth int) {
 
 		if display, ok := nodeStyle["display"]; ok {
 			switch display.Value {
-			case "block", "flex", "grid":
+			case "block", "flex", "grid", "list-item":
 				isBlock = true
 			case "inline", "inline-block":
 				isBlock = false
 			}
 		}
 		if e.Debug {
-			fmt.Printf("Element '%s' is block: %v\n", node.Data, isBlock)
+			e.debugf("Element '%s' is block: %v\n", node.Data, isBlock)
 		}
 
 		childContainer := parentBox
 
-		// Special-case inline replaced element: <img>
-		if tagName == "img" {
+		if name := runningName(nodeStyle); name != "" {
+			e.layoutRunning(node, parentBox, nodeStyle, name, depth)
+			return
+		}
+		if side := floatSide(nodeStyle); side != "" {
+			e.layoutFloat(node, parentBox, nodeStyle, side, depth)
+			return
+		}
+
+		// Special-case inline replaced elements: <img> and inline <svg>
+		if isReplaced(tagName) {
 			// Determine merged style for the element
 			nodeStyle := style.ComputedStyle{}
 			parentStyle := style.ComputedStyle{}
@@ -840,58 +630,33 @@ func (e *Engine) processNode(node *html.Node, parentBox *BlockBox, depth int) {
 
 			// Position just like inline
 			childY := parentBox.Y
-			if len(parentBox.Children) > 0 {
-				last := parentBox.Children[len(parentBox.Children)-1]
+			if last := e.lastInFlow(parentBox); last != nil {
 				childY = last.GetY() + last.GetHeight()
 			}
 
-			// Extract src attribute
-			src := ""
-			for _, a := range node.Attr {
-				if strings.EqualFold(a.Key, "src") {
-					src = a.Val
-					break
-				}
-			}
-
-			img := &ImageBox{
-				Node:  node,
-				Style: nodeStyle,
-				X:     parentBox.X,
-				Y:     childY,
-				Src:   src,
-			}
+			img := e.newImageBox(node, nodeStyle, parentBox.X, childY)
 			// Let the image compute its own size based on styles/defaults
 			img.Layout(parentBox)
 			parentBox.Children = append(parentBox.Children, img)
 			if e.Debug {
-				fmt.Printf("Created image box: src='%s' at x=%.2f y=%.2f w=%.2f h=%.2f\n", src, img.X, img.Y, img.Width, img.Height)
+				e.debugf("Created image box: src='%s' at x=%.2f y=%.2f w=%.2f h=%.2f\n", img.Src, img.X, img.Y, img.Width, img.Height)
 			}
 			return
 		}
 
+		// Form controls are drawn as static widgets, except hidden inputs
+		if isHiddenInput(node) {
+			return
+		}
+		if isInlineBlock(nodeStyle) || isFormControl(node) {
+			e.layoutInlineBlock(node, parentBox, nodeStyle, depth)
+			return
+		}
+
 		if isBlock {
-			// Parse margins and padding from the element style (supports shorthand)
-			ml, mr, mt, mb := 0.0, 0.0, 0.0, 0.0
-			if m, ok := nodeStyle["margin"]; ok && strings.TrimSpace(m.Value) != "" {
-				t, r, btm, l := parseBoxShorthand(m.Value, parentBox.Width, 0)
-				mt, mr, mb, ml = t, r, btm, l
-			} else {
-				ml = parseLength(nodeStyle["margin-left"].Value, parentBox.Width, 0)
-				mr = parseLength(nodeStyle["margin-right"].Value, parentBox.Width, 0)
-				mt = parseLength(nodeStyle["margin-top"].Value, parentBox.Width, 0)
-				mb = parseLength(nodeStyle["margin-bottom"].Value, parentBox.Width, 0)
-			}
-			pl, pr, pt, pb := 0.0, 0.0, 0.0, 0.0
-			if p, ok := nodeStyle["padding"]; ok && strings.TrimSpace(p.Value) != "" {
-				t, r, btm, l := parseBoxShorthand(p.Value, parentBox.Width, 0)
-				pt, pr, pb, pl = t, r, btm, l
-			} else {
-				pl = parseLength(nodeStyle["padding-left"].Value, parentBox.Width, 0)
-				pr = parseLength(nodeStyle["padding-right"].Value, parentBox.Width, 0)
-				pt = parseLength(nodeStyle["padding-top"].Value, parentBox.Width, 0)
-				pb = parseLength(nodeStyle["padding-bottom"].Value, parentBox.Width, 0)
-			}
+			// Margins and padding from the element style
+			mt, mr, mb, ml := boxEdges(nodeStyle, "margin", parentBox.Width)
+			pt, pr, pb, pl := boxEdges(nodeStyle, "padding", parentBox.Width)
 
 			// Parent content box
 			parentContentX := parentBox.X + parentBox.PaddingLeft + parentBox.BorderLeft
@@ -899,13 +664,18 @@ func (e *Engine) processNode(node *html.Node, parentBox *BlockBox, depth int) {
 			parentContentW := parentBox.Width - parentBox.PaddingLeft - parentBox.PaddingRight - parentBox.BorderLeft - parentBox.BorderRight
 			if parentContentW < 0 { parentContentW = 0 }
 
-			// Compute Y considering previous sibling bottom margin and our top margin
-			childY := parentContentY
-			if len(parentBox.Children) > 0 {
-				last := parentBox.Children[len(parentBox.Children)-1]
-				childY = last.GetY() + last.GetHeight() + last.GetMarginBottom()
+			// Compute Y below the previous sibling, whose bottom margin
+			// collapses with our top margin
+			childY := parentContentY + mt
+			last := e.lastInFlow(parentBox)
+			if last != nil {
+				childY = last.GetY() + last.GetHeight() + collapseMargins(last.GetMarginBottom(), mt)
+			}
+			// clear moves the block below the floats on the cleared sides
+			cs := clearSide(nodeStyle)
+			if cs != "" {
+				childY = math.Max(childY, e.clearance(cs))
 			}
-			childY += mt
 
 			// Compute X and width considering our margins
 			childX := parentContentX + ml
@@ -924,27 +694,61 @@ func (e *Engine) processNode(node *html.Node, parentBox *BlockBox, depth int) {
 			// Store parsed margins/padding so renderers/layout can reference them
 			blockBox.MarginLeft, blockBox.MarginRight, blockBox.MarginTop, blockBox.MarginBottom = ml, mr, mt, mb
 			blockBox.PaddingLeft, blockBox.PaddingRight, blockBox.PaddingTop, blockBox.PaddingBottom = pl, pr, pt, pb
+			if last != nil {
+				e.marginsAbove[blockBox] = last.GetMarginBottom()
+			} else if cs == "" {
+				e.collapseTop(parentBox, blockBox)
+			}
+			// Borders take room inside the box; tables lay out their own
+			if tagName != "table" {
+				blockBox.BorderTop, blockBox.BorderRight = nodeStyle.Border("top").Width, nodeStyle.Border("right").Width
+				blockBox.BorderBottom, blockBox.BorderLeft = nodeStyle.Border("bottom").Width, nodeStyle.Border("left").Width
+			}
 
 			parentBox.Children = append(parentBox.Children, blockBox)
 			childContainer = blockBox
+			if e.startsVerticalFlow(node, parentStyle, nodeStyle) {
+				e.layoutVertical(node, blockBox, parentContentW, depth)
+				return
+			}
+			if tagName == "hr" {
+				e.layoutRule(blockBox, parentContentW)
+				return
+			}
+			if tagName != "table" {
+				e.sizeBlock(blockBox, parentContentW)
+			}
+			if isListItem(node, nodeStyle) {
+				e.startListItem(node, blockBox)
+				defer placeMarker(blockBox)
+			}
 
 			if e.Debug {
-				fmt.Printf("Created block box for element %s: x=%.2f, y=%.2f, width=%.2f, height=%.2f\n",
+				e.debugf("Created block box for element %s: x=%.2f, y=%.2f, width=%.2f, height=%.2f\n",
 					node.Data, blockBox.X, blockBox.Y, blockBox.Width, blockBox.Height)
 			}
-			if strings.EqualFold(node.Data, "p") {
+			// Paragraphs, list items, text blocks such as the terms and
+			// definitions of a <dl>, and blocks whose white space is not the
+			// default, whose text has leaders or whose lines run vertically,
+			// are laid out in line boxes
+			if strings.EqualFold(node.Data, "p") || ((whiteSpace(nodeStyle) != "normal" || hasLeaders(node) || isListItem(node, nodeStyle) || textBlockTags[tagName] || nodeStyle.VerticalWritingMode()) && !e.hasBlockChildren(node)) {
+				// layoutParagraphInline expects the width of the content box
+				outer := blockBox.Width
+				blockBox.Width = outer - pl - pr - blockBox.BorderLeft - blockBox.BorderRight
 				e.layoutParagraphInline(node, blockBox, nodeStyle)
+				blockBox.Width = outer
+				e.sizeHeight(blockBox)
+				return
+			}
+			if strings.EqualFold(node.Data, "table") {
+				e.openBlock(blockBox, parentBox, node, nodeStyle)
+				e.layoutTable(node, blockBox, nodeStyle, parentContentW)
+				e.closeBlock(blockBox)
 				return
 			}
-			// Lay out table cell inline content with wrapping just like a paragraph
-			// if strings.EqualFold(node.Data, "td") || strings.EqualFold(node.Data, "th") {
-			// 	e.layoutParagraphInline(node, blockBox, nodeStyle)
-			// 	return
-			// }
 		} else {
 			childY := parentBox.Y
-			if len(parentBox.Children) > 0 {
-				last := parentBox.Children[len(parentBox.Children)-1]
+			if last := e.lastInFlow(parentBox); last != nil {
 				childY = last.GetY() + last.GetHeight()
 			}
 			inlineBox := &InlineBox{
@@ -961,55 +765,76 @@ func (e *Engine) processNode(node *html.Node, parentBox *BlockBox, depth int) {
 			parentBox.Children = append(parentBox.Children, inlineBox)
 
 			if e.Debug {
-				fmt.Printf("Created inline box for element %s: x=%.2f, y=%.2f, width=%.2f, height=%.2f\n",
+				e.debugf("Created inline box for element %s: x=%.2f, y=%.2f, width=%.2f, height=%.2f\n",
 					node.Data, inlineBox.X, inlineBox.Y, inlineBox.Width, inlineBox.Height)
 			}
 		}
 
+		// A new block formatting context keeps outside floats away from its
+		// content and contains its own floats
+		bfc := childContainer != parentBox && establishesBFC(nodeStyle)
+		outerFloats, innerFloats := e.floats, []floatArea(nil)
+		if bfc {
+			e.floats = nil
+		}
+		if childContainer != parentBox {
+			e.openBlock(childContainer, parentBox, node, nodeStyle)
+		}
 		for child := node.FirstChild; child != nil; child = child.NextSibling {
 			e.processNode(child, childContainer, depth+1)
+			e.flush(childContainer)
 		}
-		didRowLayout := false
-		if childContainer != parentBox && strings.EqualFold(node.Data, "tr") {
-			e.layoutTableRow(childContainer)
-			didRowLayout = true
-			if e.Debug {
-				fmt.Printf("Applied horizontal layout for table row\n")
-			}
+		if bfc {
+			innerFloats, e.floats = e.floats, outerFloats
 		}
+		if childContainer != parentBox && len(childContainer.Children) > 0 {
+			bottom := floatsBottom(innerFloats)
+			if lastChild := e.lastInFlow(childContainer); lastChild != nil {
+				bottom = math.Max(bottom, lastChild.GetY()+lastChild.GetHeight()+e.collapseBottom(childContainer, lastChild))
+			}
+			if math.IsInf(bottom, -1) {
+				// Only floats, which do not give the block any height
+				childContainer.Height = childContainer.PaddingTop + childContainer.PaddingBottom + childContainer.BorderTop + childContainer.BorderBottom
+			} else {
+				childContainer.Height = bottom + childContainer.PaddingBottom + childContainer.BorderBottom - childContainer.Y
+			}
 
-		if !didRowLayout {
-			if childContainer != parentBox && len(childContainer.Children) > 0 {
-				lastChild := childContainer.Children[len(childContainer.Children)-1]
-				childContainer.Height = lastChild.GetY() + lastChild.GetHeight() - childContainer.Y
-
-				if e.Debug {
-					fmt.Printf("Adjusted block box height for %s: height=%.2f\n", node.Data, childContainer.Height)
-				}
-			} else if childContainer != parentBox {
-				childContainer.Height = 20
+			if e.Debug {
+				e.debugf("Adjusted block box height for %s: height=%.2f\n", node.Data, childContainer.Height)
+			}
+		} else if childContainer != parentBox {
+			childContainer.Height = 20
 
-				if e.Debug {
-					fmt.Printf("Set minimum height for empty block box %s: height=%.2f\n", node.Data, childContainer.Height)
-				}
+			if e.Debug {
+				e.debugf("Set minimum height for empty block box %s: height=%.2f\n", node.Data, childContainer.Height)
 			}
 		}
+		if childContainer != parentBox {
+			e.sizeHeight(childContainer)
+			e.closeBlock(childContainer)
+		}
 	}
 
-	if len(parentBox.Children) == 0 {
+	lastChild := e.lastInFlow(parentBox)
+	if lastChild == nil {
 		return
 	}
-	lastChild := parentBox.Children[len(parentBox.Children)-1]
 	if parentBox.Y+parentBox.Height < lastChild.GetY()+lastChild.GetHeight() {
 		parentBox.Height = lastChild.GetY() + lastChild.GetHeight() - parentBox.Y
 	}
 }
 
-// mergeStyles combines parent and child styles with child styles taking precedence
+// mergeStyles combines parent and child styles with child styles taking
+// precedence. Only inherited properties are taken from the parent; the style
+// engine has already applied inheritance along the document tree, so this
+// matters for boxes laid out in a container other than their parent element.
 func (e *Engine) mergeStyles(parentStyle, childStyle style.ComputedStyle) style.ComputedStyle {
 	mergedStyle := make(style.ComputedStyle)
 
 	for key, value := range parentStyle {
+		if !style.IsInherited(key) {
+			continue
+		}
 		mergedStyle[key] = value
 	}
 
@@ -1020,6 +845,14 @@ func (e *Engine) mergeStyles(parentStyle, childStyle style.ComputedStyle) style.
 	return mergedStyle
 }
 
+// textBlockTags are the block elements whose text is wrapped in line boxes
+// like that of a paragraph when they have no block-level children
+var textBlockTags = map[string]bool{
+	"dt":         true,
+	"dd":         true,
+	"blockquote": true,
+}
+
 // isBlockTag reports whether a tag name is treated as block-level
 func (e *Engine) isBlockTag(tag string) bool {
 	switch strings.ToLower(tag) {
@@ -1027,38 +860,65 @@ func (e *Engine) isBlockTag(tag string) bool {
 		"ul", "ol", "li", "table", "thead", "tbody", "tfoot",
 		"tr", "td", "th", "header", "footer", "section", "article",
 		"form", "fieldset", "hr", "blockquote", "address", "main",
-		"nav", "aside":
+		"nav", "aside", "pre", "figure", "figcaption", "dl", "dt", "dd":
 		return true
 	default:
 		return false
 	}
 }
 
-// inlineRun represents a contiguous text run with a specific style
+// inlineRun represents a contiguous text run with a specific style, an
+// inline <img> or <svg> when image is set or an inline-block element when block is set
 type inlineRun struct {
 	text  string
 	style style.ComputedStyle
+	image *html.Node
+	block *html.Node
 }
 
 // layoutParagraphInline lays out inline content of a <p> with wrapping and shared baseline per line
 func (e *Engine) layoutParagraphInline(pNode *html.Node, container *BlockBox, baseStyle style.ComputedStyle) {
+	// vertical-align of the container itself does not shift its lines
+	runStyle := baseStyle
+	if _, ok := baseStyle["vertical-align"]; ok {
+		runStyle = make(style.ComputedStyle, len(baseStyle))
+		for k, v := range baseStyle {
+			runStyle[k] = v
+		}
+		delete(runStyle, "vertical-align")
+	}
 	runs := []inlineRun{}
-	e.collectInlineRuns(pNode, baseStyle, &runs)
+	e.collectInlineRuns(pNode, runStyle, &runs)
+	e.layoutInlineRuns(pNode, runs, container)
+}
 
+// layoutInlineRuns wraps the inline runs of an element into line boxes
+// within the width of container
+func (e *Engine) layoutInlineRuns(pNode *html.Node, runs []inlineRun, container *BlockBox) {
 	normalizeInlineRuns(&runs)
 
-	type tkn struct {
-		text    string
-		style   style.ComputedStyle
-		width   float64
-		isSpace bool    // Whether this token is a space
-		drop    bool    // Whether to drop this token during layout
-		fs      float64 // Font size
-		lh      float64 // Line height
-	}
-
-	raw := []tkn{}
+	raw := []lineToken{}
 	for _, run := range runs {
+		if side := floatSide(run.style); run.image != nil && side != "" {
+			img, _ := e.buildShrinkToFit(run.image, container, run.style, container.Width, 0).(*ImageBox)
+			outer := img.MarginLeft + img.Width + img.MarginRight
+			raw = append(raw, lineToken{style: run.style, width: outer, img: img, float: side})
+			continue
+		}
+		if run.image != nil {
+			img := e.newImageBox(run.image, run.style, 0, 0)
+			img.Layout(container)
+			raw = append(raw, lineToken{style: run.style, fs: img.Height, lh: img.Height, width: img.Width, img: img})
+			continue
+		}
+		if run.block != nil {
+			// An inline-block is one unbreakable token whose margin box
+			// extends from its baseline up by fs and down by lh-fs
+			b, baseline := e.buildInlineBlock(run.block, container, run.style, container.Width, 0)
+			outerH := b.MarginTop + b.Height + b.MarginBottom
+			raw = append(raw, lineToken{style: run.style, fs: baseline, lh: outerH, width: b.MarginLeft + b.Width + b.MarginRight, block: b})
+			continue
+		}
 		if run.text == "" {
 			continue
 		}
@@ -1066,45 +926,60 @@ func (e *Engine) layoutParagraphInline(pNode *html.Node, container *BlockBox, ba
 		if prop, ok := run.style["font-size"]; ok && strings.TrimSpace(prop.Value) != "" {
 			fs = parseLength(prop.Value, 0, 16)
 		}
-		lh := 1.2 * fs
-		if prop, ok := run.style["line-height"]; ok && strings.TrimSpace(prop.Value) != "" {
-			lh = parseLength(prop.Value, 0, 1.2*fs)
-		}
+		lh := parseLineHeight(run.style["line-height"].Value, fs, 1.2*fs)
 
-		tokens := splitTokens(run.text)
-		for _, t := range tokens {
-			isSpace := isAllSpace(t)
-			w := 0.0
-			if isSpace {
-				// Measure space width using font metrics to avoid over/under spacing
-				w = measureTextWidth(" ", fs, run.style)
-			} else {
-				t = strings.TrimSpace(t)
-				if t != "" {
-					w = measureTextWidth(t, fs, run.style)
-				}
-			}
-			if t != "" {
-				raw = append(raw, tkn{
-					text:    t,
-					isSpace: isSpace,
-					style:   run.style,
-					fs:      fs,
-					lh:      lh,
-					width:   w,
-				})
+		ws := whiteSpace(run.style)
+		rest := run.text
+		for rest != "" {
+			before, pattern, after, found := style.CutLeader(rest)
+			raw = e.appendTextTokens(raw, before, run.style, fs, lh, ws)
+			if !found {
+				break
 			}
+			raw = append(raw, lineToken{style: run.style, fs: fs, lh: lh, leader: pattern})
+			rest = after
 		}
 	}
 
+	// Right-to-left text puts the tokens of each line in visual order
+	content, starts := bidiText(raw)
+	dir := paragraphDirection(pNode, container.Style, content)
+	bidiLevel := uint8(0)
+	if dir == text.RightToLeft {
+		bidiLevel = 1
+	}
+	reorder := assignBidiLevels(raw, dir, content, starts)
+
 	// Start within the content box of the container (respect padding/border)
 	startX := container.X + container.PaddingLeft + container.BorderLeft
-	maxWidth := container.Width
+	maxWidth := container.Width + fitTolerance
 	curY := container.Y + container.PaddingTop + container.BorderTop
-	line := []tkn{}
-	lineWidth := 0.0
-	maxAscent := 0.0
-	maxDescent := 0.0
+	// lineX and maxWidth describe the current line, which floats may shorten
+	lineX := startX
+	startLine := func(width, lh float64) {
+		if len(e.floats) == 0 {
+			return
+		}
+		var l, r float64
+		curY, l, r = e.fitLine(curY, lh, width, startX, startX+container.Width)
+		lineX, maxWidth = l, r-l+fitTolerance
+	}
+	line := []lineToken{}
+	// text-indent shifts the first line as if it began with content of that
+	// width
+	indent := parseLength(container.Style["text-indent"].Value, container.Width, 0) + markerIndent(container)
+	lineWidth := indent
+	strut := strutToken(container.Style)
+	ellipsis := ellipsizes(container.Style)
+	lines := 0
+	// Tokens on the first line take the container's ::first-line style
+	firstLine := e.firstLine[pNode]
+	current := func(i int) lineToken {
+		if lines > 0 || firstLine == nil {
+			return raw[i]
+		}
+		return e.firstLineToken(raw[i], container.Style, firstLine)
+	}
 
 	emitLine := func() {
 		if len(line) == 0 {
@@ -1113,91 +988,219 @@ func (e *Engine) layoutParagraphInline(pNode *html.Node, container *BlockBox, ba
 		if len(line) > 0 && line[len(line)-1].isSpace {
 			line[len(line)-1].drop = true
 		}
-		maxAscent, maxDescent = 0, 0
+		lineStart := 0.0
+		if lines == 0 {
+			lineStart = indent
+		}
+		if ellipsis && lineWidth > maxWidth {
+			line, lineWidth = e.truncateLine(line, maxWidth-lineStart, strut)
+			lineWidth += lineStart
+		}
+		// Leaders share the space the line leaves free
+		leaders := 0
 		for _, tk := range line {
-			if tk.drop {
-				continue
+			if tk.leader != "" && !tk.drop {
+				leaders++
 			}
-			if tk.fs > maxAscent {
-				maxAscent = tk.fs
-			}
-			if tk.lh-tk.fs > maxDescent {
-				maxDescent = tk.lh - tk.fs
+		}
+		if leaders > 0 && lineWidth < maxWidth {
+			for i := range line {
+				if line[i].leader != "" {
+					line[i].width = (maxWidth - lineWidth) / float64(leaders)
+				}
 			}
+			lineWidth = maxWidth
 		}
-		baselineY := curY + maxAscent
+		baselines, lineHeight := arrangeLine(line, strut)
 		// Compute alignment offset for the entire line
 		// total lineWidth has been accumulated while building the line
 		offsetX := 0.0
-		align := "left"
-		if prop, ok := container.Style["text-align"]; ok && strings.TrimSpace(prop.Value) != "" {
-			align = strings.ToLower(strings.TrimSpace(prop.Value))
-		}
-		if align == "right" || align == "end" {
+		align := lineAlignment(container.Style, dir)
+		if align == "right" {
 			if lineWidth < maxWidth { offsetX = maxWidth - lineWidth }
 		} else if align == "center" {
 			if lineWidth < maxWidth { offsetX = (maxWidth - lineWidth) / 2 }
 		}
-		x := offsetX
-		for _, tk := range line {
+		// The indent is on the starting side of the line
+		x := offsetX + lineStart
+		if dir == text.RightToLeft {
+			x = offsetX
+		}
+		order := make([]int, len(line))
+		for i := range order {
+			order[i] = i
+		}
+		if reorder {
+			order = visualOrder(line, bidiLevel)
+		}
+		for _, i := range order {
+			tk := line[i]
 			if tk.drop {
 				continue
 			}
+			baselineY := curY + baselines[i]
 			// Use the precomputed token width (font-aware for both words and spaces)
 			w := tk.width
+			if tk.img != nil {
+				// Replaced content sits on the baseline
+				tk.img.SetPosition(lineX+x, baselineY-tk.img.Height)
+				container.Children = append(container.Children, tk.img)
+				x += w
+				continue
+			}
+			if b := tk.block; b != nil {
+				nx, ny := lineX+x+b.MarginLeft, baselineY-tk.fs+b.MarginTop
+				dx, dy := nx-b.X, ny-b.Y
+				b.SetPosition(nx, ny)
+				e.shiftDescendants(b, dx, dy)
+				container.Children = append(container.Children, b)
+				x += w
+				continue
+			}
+			if tk.leader != "" {
+				if ib := e.leaderBox(tk, startX, lineX+x, baselineY-tk.fs, curY+lineHeight); ib != nil {
+					container.Children = append(container.Children, ib)
+				}
+				x += w
+				continue
+			}
+			// Text boxes keep their baseline one font size below their top,
+			// where the renderer expects it, and reach down to the bottom
+			// of the line box
 			ib := &InlineBox{
 				Node:   nil,
 				Style:  tk.style,
-				X:      startX + x,
+				X:      lineX + x,
 				Y:      baselineY - tk.fs,
 				Width:  w,
-				Height: maxAscent + maxDescent,
-				Text:   map[bool]string{true: " ", false: tk.text}[tk.isSpace],
+				Height: math.Max(curY+lineHeight-(baselineY-tk.fs), 0),
+				Text:   displayText(tk),
+				RTL:    tk.level%2 == 1,
 			}
 			container.Children = append(container.Children, ib)
 			x += w
 		}
-		curY += (maxAscent + maxDescent)
+		curY += lineHeight
+		lines++
 		line = line[:0]
 		lineWidth = 0
 	}
 
 	pendingSpace := false
+	// pendingLevel is the bidi level of the collapsed space
+	pendingLevel := bidiLevel
 	for i := 0; i < len(raw); i++ {
-		tk := raw[i]
-		if tk.isSpace {
+		tk := current(i)
+		if tk.float != "" {
+			// A float goes beside the current line when it fits, otherwise
+			// below it
+			if len(line) > 0 && lineWidth+tk.width > maxWidth {
+				emitLine()
+			}
+			e.placeFloat(tk.img, container, tk.float, curY, startX, startX+container.Width)
+			if len(line) > 0 {
+				l, r := e.lineSpace(curY, line[0].lh, startX, startX+container.Width)
+				lineX, maxWidth = l, r-l+fitTolerance
+			}
+			continue
+		}
+		if tk.newline {
+			// A preserved line break ends the line, even an empty one, except
+			// at the very end of the content
+			pendingSpace = false
+			if i == len(raw)-1 {
+				continue
+			}
+			if len(line) == 0 {
+				startLine(0, tk.lh)
+				line = append(line, lineToken{style: tk.style, fs: tk.fs, lh: tk.lh, isSpace: true, preserved: true})
+			}
+			emitLine()
+			continue
+		}
+		if tk.tab {
+			tk.width = e.tabAdvance(lineWidth, tk.width, tk.fs, tk.style)
+		}
+		if tk.isSpace && !tk.preserved {
 			if !pendingSpace {
 				pendingSpace = true
+				pendingLevel = tk.level
 			}
 			continue
 		}
+		if tk.isSpace && !tk.noWrap && lineWidth+tk.width > maxWidth && len(line) > 0 {
+			// Preserved spaces that do not fit hang at the end of the line
+			emitLine()
+			continue
+		}
 
 		if pendingSpace {
-			if r, _ := utf8.DecodeRuneInString(tk.text); r != utf8.RuneError && strings.ContainsRune(",.;:!?)]}»", r) {
-			} else {
-				fs, lh := tk.fs, tk.lh
-				// Use font-aware space width
-				spw := measureTextWidth(" ", fs, tk.style)
-				if lineWidth+spw+tk.width > maxWidth && len(line) > 0 {
+			fs, lh := tk.fs, tk.lh
+			if tk.block != nil {
+				// The space takes the font of the surrounding text
+				fs = parseLength(tk.style["font-size"].Value, 0, 16)
+				lh = parseLineHeight(tk.style["line-height"].Value, fs, 1.2*fs)
+			}
+			// Use font-aware space width
+			spw := e.measureTextWidth(" ", fs, tk.style)
+			space := lineToken{text: " ", style: tk.style, fs: fs, lh: lh, width: spw, isSpace: true, level: pendingLevel}
+			pendingSpace = false
+			if lineWidth+spw+tk.width > maxWidth && len(line) > 0 && !tk.noWrap {
+				// Hyphenate the word after the space, or move it to the next line
+				if head, tail, ok := e.splitWord(tk, maxWidth-lineWidth-spw, false); ok {
+					line = append(line, space, head)
+					lineWidth += spw + head.width
 					emitLine()
-					pendingSpace = false
+					raw[i] = e.restyle(tail, raw[i].style)
+					i--
 					continue
 				}
-				if len(line) > 0 {
-					line = append(line, tkn{text: " ", style: tk.style, fs: fs, lh: lh, width: spw, isSpace: true})
-					lineWidth += spw
-				}
+				emitLine()
+				tk = current(i)
+			} else if len(line) > 0 {
+				line = append(line, space)
+				lineWidth += spw
+			}
+		}
+		if len(line) > 0 && !tk.isSpace && !tk.noWrap && tk.text != "" && lineWidth+tk.width > maxWidth {
+			// A word directly following other content may still be broken
+			if head, tail, ok := e.splitWord(tk, maxWidth-lineWidth, false); ok {
+				line = append(line, head)
+				lineWidth += head.width
+				emitLine()
+				raw[i] = e.restyle(tail, raw[i].style)
+				i--
+				continue
 			}
-			pendingSpace = false
 		}
 
-		if tk.width > maxWidth { // extremely long word: place on new line anyway
+		switch {
+		case tk.noWrap && len(line) > 0:
+			// white-space: nowrap and pre only break at preserved newlines
+		case tk.width > maxWidth: // extremely long word: place on new line anyway
 			if len(line) > 0 {
 				emitLine()
 			}
-		} else if lineWidth+tk.width > maxWidth && len(line) > 0 {
+		case lineWidth+tk.width > maxWidth && len(line) > 0:
 			emitLine()
 		}
+		if firstLine != nil && len(line) == 0 {
+			tk = current(i)
+		}
+		if len(line) == 0 {
+			startLine(tk.width, tk.lh)
+			if !tk.isSpace && !tk.noWrap && tk.text != "" && tk.width > maxWidth {
+				// A word wider than the whole line is broken where allowed
+				if head, tail, ok := e.splitWord(tk, maxWidth, true); ok {
+					line = append(line, head)
+					lineWidth += head.width
+					emitLine()
+					raw[i] = e.restyle(tail, raw[i].style)
+					i--
+					continue
+				}
+			}
+		}
 
 		line = append(line, tk)
 		lineWidth += tk.width
@@ -1206,8 +1209,9 @@ func (e *Engine) layoutParagraphInline(pNode *html.Node, container *BlockBox, ba
 		emitLine()
 	}
 
-	if len(container.Children) > 0 {
-		last := container.Children[len(container.Children)-1]
+	if lines > 0 {
+		container.Height = curY - container.Y
+	} else if last := e.lastInFlow(container); last != nil {
 		container.Height = (last.GetY() + last.GetHeight()) - container.Y
 	} else {
 		container.Height = 0
@@ -1227,22 +1231,6 @@ func (e *Engine) collectInlineRuns(n *html.Node, inherited style.ComputedStyle,
 				continue
 			}
 
-			isFirstNode := ch.PrevSibling == nil || (ch.PrevSibling.Type != xhtml.TextNode && ch.PrevSibling.Type != xhtml.ElementNode)
-			isLastNode := ch.NextSibling == nil || (ch.NextSibling.Type != xhtml.TextNode && ch.NextSibling.Type != xhtml.ElementNode)
-
-			txt = normalizeWhitespace(txt)
-
-			if isFirstNode {
-				txt = strings.TrimLeftFunc(txt, unicode.IsSpace)
-			}
-			if isLastNode {
-				txt = strings.TrimRightFunc(txt, unicode.IsSpace)
-			}
-
-			if txt == "" {
-				continue
-			}
-
 			eff := make(style.ComputedStyle)
 			for k, v := range inherited {
 				eff[k] = v
@@ -1254,12 +1242,31 @@ func (e *Engine) collectInlineRuns(n *html.Node, inherited style.ComputedStyle,
 					}
 				}
 			}
+			// Only inline ancestors shift text, never the block it is in
+			if va, ok := inherited["vertical-align"]; ok {
+				eff["vertical-align"] = va
+			} else {
+				delete(eff, "vertical-align")
+			}
 			if _, ok := eff["color"]; !ok {
 				eff["color"] = style.StyleProperty{Name: "color", Value: "#000000"}
 			}
 			if _, ok := eff["font-size"]; !ok {
 				eff["font-size"] = style.StyleProperty{Name: "font-size", Value: "16px"}
 			}
+			// Spaces at the start and end of lines are dropped by the line
+			// breaker, so runs keep theirs
+			txt = processWhitespace(txt, eff)
+			if txt == "" {
+				continue
+			}
+			// capitalize only starts a word where the previous run ended one
+			wordStart := true
+			if k := len(*out); k > 0 && (*out)[k-1].text != "" {
+				last, _ := utf8.DecodeLastRuneInString((*out)[k-1].text)
+				wordStart = unicode.IsSpace(last)
+			}
+			txt = style.TransformText(txt, eff["text-transform"].Value, wordStart)
 			*out = append(*out, inlineRun{text: txt, style: eff})
 		case xhtml.ElementNode:
 			tag := strings.ToLower(ch.Data)
@@ -1270,6 +1277,25 @@ func (e *Engine) collectInlineRuns(n *html.Node, inherited style.ComputedStyle,
 			eff := inherited
 			if thisStyle, ok := e.styles[ch]; ok {
 				eff = e.mergeStyles(inherited, thisStyle)
+				// Runs are flattened, so text nested in a shifted element
+				// keeps its vertical-align, and shifts of nested elements
+				// add up
+				if va, ok := thisStyle["vertical-align"]; ok {
+					eff["vertical-align"] = nestedShift(va, inherited, eff)
+				} else if va, ok := inherited["vertical-align"]; ok {
+					eff["vertical-align"] = va
+				}
+			}
+			if isReplaced(tag) {
+				*out = append(*out, inlineRun{style: eff, image: ch})
+				continue
+			}
+			if isHiddenInput(ch) {
+				continue
+			}
+			if (isInlineBlock(eff) || isFormControl(ch)) && floatSide(eff) == "" {
+				*out = append(*out, inlineRun{style: eff, block: ch})
+				continue
 			}
 			e.collectInlineRuns(ch, eff, out)
 		default:
@@ -1278,54 +1304,123 @@ func (e *Engine) collectInlineRuns(n *html.Node, inherited style.ComputedStyle,
 	}
 }
 
-// splitTokens splits text into tokens of words and spaces
-func splitTokens(s string) []string {
-	s = strings.TrimSpace(s)
-	if s == "" {
+// leaderBox returns the text box drawing a leader placed at x, or nil when
+// not a single repetition of its pattern fits. Repetitions are aligned on a
+// grid starting at the content edge origin, so that the leaders of
+// consecutive lines line up.
+func (e *Engine) leaderBox(tk lineToken, origin, x, top, bottom float64) *InlineBox {
+	pw := e.measureTextWidth(tk.leader, tk.fs, tk.style)
+	if pw <= 0 {
 		return nil
 	}
+	first := origin + math.Ceil((x-origin)/pw-1e-6)*pw
+	n := int(math.Floor((x+tk.width-first)/pw + 1e-6))
+	if n <= 0 {
+		return nil
+	}
+	return &InlineBox{
+		Style:  tk.style,
+		X:      first,
+		Y:      top,
+		Width:  float64(n) * pw,
+		Height: math.Max(bottom-top, 0),
+		Text:   strings.Repeat(tk.leader, n),
+	}
+}
+
+// hasLeaders reports whether the text under n has leaders from leader() in
+// generated content, which only line boxes can fill
+func hasLeaders(n *html.Node) bool {
+	for c := n.FirstChild; c != nil; c = c.NextSibling {
+		if c.Type == xhtml.TextNode && style.HasLeaders(c.Data) || hasLeaders(c) {
+			return true
+		}
+	}
+	return false
+}
 
+// appendTextTokens appends the words, spaces and preserved newlines of text
+// in a run's style to raw
+func (e *Engine) appendTextTokens(raw []lineToken, s string, st style.ComputedStyle, fs, lh float64, ws string) []lineToken {
+	for _, t := range splitTokens(s) {
+		if t == "\n" {
+			raw = append(raw, lineToken{style: st, fs: fs, lh: lh, newline: true})
+			continue
+		}
+		if t == "\t" {
+			// The width of a tab is the interval between tab stops until
+			// its place on the line is known
+			raw = append(raw, lineToken{text: t, isSpace: true, style: st, fs: fs, lh: lh,
+				width: e.tabInterval(st, fs), preserved: true, tab: true, noWrap: !wrapsLines(ws)})
+			continue
+		}
+		isSpace := isAllSpace(t)
+		preserved := isSpace && !collapsesSpaces(ws)
+		if isSpace && !preserved {
+			t = " "
+		}
+		// Measure with font metrics to avoid over/under spacing
+		raw = append(raw, lineToken{
+			text:      t,
+			isSpace:   isSpace,
+			style:     st,
+			fs:        fs,
+			lh:        lh,
+			width:     e.measureTextWidth(stripSoftHyphens(t), fs, st),
+			preserved: preserved,
+			noWrap:    !wrapsLines(ws),
+		})
+	}
+	return raw
+}
+
+// splitTokens splits text into tokens of words, runs of spaces and preserved
+// newlines
+func splitTokens(s string) []string {
 	tokens := []string{}
 	var cur []rune
-	var curIsSpace *bool
+	kind := 0 // 0 none, 1 word, 2 spaces
 
-	for _, r := range s {
-		isSp := unicode.IsSpace(r)
-		if curIsSpace == nil {
-			curIsSpace = new(bool)
-			*curIsSpace = isSp
+	flush := func() {
+		if len(cur) > 0 {
+			tokens = append(tokens, string(cur))
 		}
-
+		cur = cur[:0]
+	}
+	for _, r := range s {
 		switch {
-		case *curIsSpace != isSp:
-			if len(cur) > 0 {
-				tokens = append(tokens, string(cur))
-			}
-			cur = []rune{}
-
-			if isSp {
-				cur = append(cur, ' ')
-			} else {
-				cur = append(cur, r)
-			}
-			*curIsSpace = isSp
-		case isSp:
-			if len(cur) == 0 {
-				cur = append(cur, ' ')
+		case r == '\n':
+			// Preserved line breaks are tokens of their own
+			flush()
+			tokens = append(tokens, "\n")
+			kind = 0
+		case r == '\t':
+			// Tabs left by processWhitespace are preserved and advance to
+			// the next tab stop, so they are tokens of their own too
+			flush()
+			tokens = append(tokens, "\t")
+			kind = 0
+		case isWhiteSpace(r):
+			if kind != 2 {
+				flush()
 			}
+			cur = append(cur, ' ')
+			kind = 2
 		default:
+			if kind != 1 {
+				flush()
+			}
 			cur = append(cur, r)
+			kind = 1
 		}
 	}
-	if len(cur) > 0 {
-		tokens = append(tokens, string(cur))
-	}
+	flush()
 	return tokens
 }
 
 func isAllSpace(s string) bool {
 	for _, r := range s {
-		if !unicode.IsSpace(r) {
+		if !isWhiteSpace(r) {
 			return false
 		}
 	}
@@ -1339,7 +1434,7 @@ func normalizeWhitespace(s string) string {
 	var lastWasSpace bool
 
 	for _, r := range s {
-		isSpace := unicode.IsSpace(r)
+		isSpace := isWhiteSpace(r)
 
 		if isSpace {
 			if !lastWasSpace {
@@ -1355,8 +1450,8 @@ func normalizeWhitespace(s string) string {
 	return string(result)
 }
 
-// normalizeInlineRuns ensures proper spacing between inline elements
-// by examining adjacent runs and adding spaces where needed
+// normalizeInlineRuns collapses white space across the boundaries of
+// adjacent runs, as it collapses within a run
 func normalizeInlineRuns(runs *[]inlineRun) {
 	if runs == nil || len(*runs) <= 1 {
 		return
@@ -1365,27 +1460,24 @@ func normalizeInlineRuns(runs *[]inlineRun) {
 	result := make([]inlineRun, 0, len(*runs))
 
 	for i, run := range *runs {
-		result = append(result, run)
-
-		if i < len(*runs)-1 {
-			currentEndsWithSpace := len(run.text) > 0 && unicode.IsSpace(rune(run.text[len(run.text)-1]))
-			nextStartsWithSpace := len((*runs)[i+1].text) > 0 && unicode.IsSpace(rune((*runs)[i+1].text[0]))
-			if !currentEndsWithSpace && !nextStartsWithSpace {
-				if len(run.text) > 0 && len((*runs)[i+1].text) > 0 {
-					spaceRun := inlineRun{
-						text:  " ",
-						style: run.style,
-					}
-					result = append(result, spaceRun)
+		// A collapsible space following a space at the end of the previous
+		// run collapses into it
+		if i > 0 && run.text != "" && collapsesSpaces(whiteSpace(run.style)) {
+			prev := (*runs)[i-1].text
+			if prev != "" && isWhiteSpace(rune(prev[len(prev)-1])) {
+				run.text = strings.TrimLeft(run.text, " ")
+				if run.text == "" {
+					continue
 				}
 			}
 		}
+		result = append(result, run)
 	}
 
 	*runs = result
 }
 
-// debugDocumentStructure prints the HTML document structure for debugging
+// debugDocumentStructure logs the HTML document structure for debugging
 func (e *Engine) debugDocumentStructure(node *html.Node, depth int) {
 	if node == nil {
 		return
@@ -1394,17 +1486,17 @@ func (e *Engine) debugDocumentStructure(node *html.Node, depth int) {
 	indent := strings.Repeat("  ", depth)
 	switch node.Type {
 	case xhtml.ElementNode: // ElementNode
-		fmt.Printf("%s[ElementNode] %s\n", indent, node.Data)
+		e.debugf("%s[ElementNode] %s\n", indent, node.Data)
 	case xhtml.TextNode: // TextNode
-		fmt.Printf("%s[TextNode] %s\n", indent, node.Data)
+		e.debugf("%s[TextNode] %s\n", indent, node.Data)
 	case xhtml.DocumentNode: // DocumentNode
-		fmt.Printf("%s[DocumentNode] %s\n", indent, node.Data)
+		e.debugf("%s[DocumentNode] %s\n", indent, node.Data)
 	case xhtml.CommentNode: // CommentNode
-		fmt.Printf("%s[CommentNode] %s\n", indent, node.Data)
+		e.debugf("%s[CommentNode] %s\n", indent, node.Data)
 	case xhtml.DoctypeNode: // DoctypeNode
-		fmt.Printf("%s[DoctypeNode] %s\n", indent, node.Data)
+		e.debugf("%s[DoctypeNode] %s\n", indent, node.Data)
 	default:
-		fmt.Printf("%s[unknown] %s\n", indent, node.Data)
+		e.debugf("%s[unknown] %s\n", indent, node.Data)
 	}
 
 	for child := node.FirstChild; child != nil; child = child.NextSibling {
@@ -0,0 +1,319 @@
+package layout
+
+import (
+	"math"
+	"strings"
+
+	"github.com/gompdf/gompdf/internal/parser/html"
+	"github.com/gompdf/gompdf/internal/style"
+)
+
+// floatArea is the margin box of a floated box, the area that line boxes of
+// the same block formatting context flow around
+type floatArea struct {
+	box                      Box
+	side                     string
+	left, right, top, bottom float64
+}
+
+// floatSide returns "left" or "right" for a floated style, or ""
+func floatSide(st style.ComputedStyle) string {
+	switch v := strings.ToLower(strings.TrimSpace(st["float"].Value)); v {
+	case "left", "right":
+		return v
+	case "inline-start":
+		return "left"
+	case "inline-end":
+		return "right"
+	}
+	return ""
+}
+
+// clearSide returns "left", "right" or "both" for a style with clear, or ""
+func clearSide(st style.ComputedStyle) string {
+	switch v := strings.ToLower(strings.TrimSpace(st["clear"].Value)); v {
+	case "left", "right", "both":
+		return v
+	case "inline-start":
+		return "left"
+	case "inline-end":
+		return "right"
+	}
+	return ""
+}
+
+// establishesBFC reports whether a block is the root of a new block
+// formatting context: floats outside it do not affect its content and it
+// grows to contain its own floats
+func establishesBFC(st style.ComputedStyle) bool {
+	if floatSide(st) != "" {
+		return true
+	}
+	if st.ClipsOverflow() {
+		return true
+	}
+	switch strings.ToLower(strings.TrimSpace(st["display"].Value)) {
+	case "flow-root", "inline-block", "table-cell":
+		return true
+	}
+	return false
+}
+
+// isFloated reports whether a box was placed as a float
+func (e *Engine) isFloated(b Box) bool {
+	return e.floated[b]
+}
+
+// lastInFlow returns the last child of b that is not floated, or nil
+func (e *Engine) lastInFlow(b *BlockBox) Box {
+	for i := len(b.Children) - 1; i >= 0; i-- {
+		if !e.isFloated(b.Children[i]) {
+			return b.Children[i]
+		}
+	}
+	return nil
+}
+
+// clearance returns the lowest float bottom the given clear value has to
+// move below, or -Inf when there is nothing to clear
+func (e *Engine) clearance(side string) float64 {
+	y := math.Inf(-1)
+	for _, f := range e.floats {
+		if side == "both" || side == f.side {
+			y = math.Max(y, f.bottom)
+		}
+	}
+	return y
+}
+
+// floatsBottom returns the lowest bottom of the given floats, or -Inf
+func floatsBottom(floats []floatArea) float64 {
+	y := math.Inf(-1)
+	for _, f := range floats {
+		y = math.Max(y, f.bottom)
+	}
+	return y
+}
+
+// lineSpace narrows the horizontal span [left, right] to what is left beside
+// the floats that overlap the band from y to y+h
+func (e *Engine) lineSpace(y, h, left, right float64) (float64, float64) {
+	for _, f := range e.floats {
+		if f.bottom <= y+0.01 || f.top >= y+h-0.01 || f.right <= left || f.left >= right {
+			continue
+		}
+		if f.side == "left" {
+			left = math.Max(left, f.right)
+		} else {
+			right = math.Min(right, f.left)
+		}
+	}
+	return left, right
+}
+
+// nextFloatBottom returns the nearest float bottom below y among the floats
+// overlapping the band from y to y+h, or false when none does
+func (e *Engine) nextFloatBottom(y, h float64) (float64, bool) {
+	next, found := math.Inf(1), false
+	for _, f := range e.floats {
+		if f.bottom > y+0.01 && f.top < y+h-0.01 && f.bottom < next {
+			next, found = f.bottom, true
+		}
+	}
+	return next, found
+}
+
+// fitLine finds the first position at or below y where a line of height h
+// and at least the given width fits between left and right, moving past
+// floats as needed. It returns the line's top and horizontal span.
+func (e *Engine) fitLine(y, h, width, left, right float64) (float64, float64, float64) {
+	for {
+		l, r := e.lineSpace(y, h, left, right)
+		if r-l >= width || (l == left && r == right) {
+			return y, l, r
+		}
+		next, ok := e.nextFloatBottom(y, h)
+		if !ok {
+			return y, l, r
+		}
+		y = next
+	}
+}
+
+// layoutFloat lays out a floated element and places it at the left or right
+// edge of its containing block, below earlier floats when there is no room
+// beside them. The float is added to parentBox but stays out of the normal
+// flow; later line boxes are shortened around it.
+func (e *Engine) layoutFloat(node *html.Node, parentBox *BlockBox, st style.ComputedStyle, side string, depth int) {
+	left := parentBox.X + parentBox.PaddingLeft + parentBox.BorderLeft
+	right := parentBox.X + parentBox.Width - parentBox.PaddingRight - parentBox.BorderRight
+	if right < left {
+		right = left
+	}
+
+	// A float starts no higher than the current line and earlier floats
+	top := parentBox.Y + parentBox.PaddingTop + parentBox.BorderTop
+	if last := e.lastInFlow(parentBox); last != nil {
+		top = last.GetY() + last.GetHeight() + last.GetMarginBottom()
+	}
+	for _, f := range e.floats {
+		top = math.Max(top, f.top)
+	}
+	if cs := clearSide(st); cs != "" {
+		top = math.Max(top, e.clearance(cs))
+	}
+	e.placeFloat(e.buildShrinkToFit(node, parentBox, st, right-left, depth), parentBox, side, top, left, right)
+}
+
+// buildShrinkToFit lays out the content of a floated or inline-block element
+// at the origin of its containing block and returns the resulting box.
+// Without a declared width the box shrinks to fit its content.
+func (e *Engine) buildShrinkToFit(node *html.Node, parentBox *BlockBox, st style.ComputedStyle, available float64, depth int) Box {
+	mt, mr, mb, ml := boxEdges(st, "margin", parentBox.Width)
+	originX := parentBox.X + parentBox.PaddingLeft + parentBox.BorderLeft
+
+	if isReplaced(strings.ToLower(node.Data)) {
+		img := e.newImageBox(node, st, originX+ml, mt)
+		img.Layout(parentBox)
+		img.MarginTop, img.MarginRight, img.MarginBottom, img.MarginLeft = mt, mr, mb, ml
+		return img
+	}
+	if isFormControl(node) {
+		return e.buildFormControl(node, parentBox, st, available)
+	}
+
+	pt, pr, pb, pl := boxEdges(st, "padding", parentBox.Width)
+	width, autoWidth := available-ml-mr, true
+	if w := declaredWidth(node, st); w != "" {
+		width, autoWidth = contentSize(st, parseLength(w, parentBox.Width, width-pl-pr), pl+pr)+pl+pr, false
+	} else {
+		// The shrink-to-fit width: the preferred width of the content, but
+		// no less than its widest word and no more than the space available
+		minW, maxW := e.intrinsicWidths(node, st)
+		width = math.Min(math.Max(minW, width-pl-pr), maxW) + pl + pr
+	}
+	if width < 0 {
+		width = 0
+	}
+	box := &BlockBox{
+		Node:     node,
+		Style:    st,
+		X:        originX + ml,
+		Y:        mt,
+		Width:    width,
+		Children: []Box{},
+	}
+	box.MarginTop, box.MarginRight, box.MarginBottom, box.MarginLeft = mt, mr, mb, ml
+	box.PaddingTop, box.PaddingRight, box.PaddingBottom, box.PaddingLeft = pt, pr, pb, pl
+	if strings.EqualFold(node.Data, "button") {
+		box.Widget = &FormWidget{Kind: "button"}
+	}
+	if e.startsVerticalFlow(node, parentBox.Style, st) {
+		e.layoutVertical(node, box, available, depth)
+		return box
+	}
+
+	// Floats and inline-blocks are block formatting contexts of their own
+	saved := e.floats
+	e.floats = nil
+	switch {
+	case strings.EqualFold(node.Data, "table"):
+		e.layoutTable(node, box, st, 0)
+	case strings.EqualFold(node.Data, "p") || !e.hasBlockChildren(node):
+		// Inline content is wrapped into line boxes within the content box
+		box.Width = width - pl - pr
+		e.layoutParagraphInline(node, box, st)
+		box.Width = width
+	default:
+		for c := node.FirstChild; c != nil; c = c.NextSibling {
+			e.processNode(c, box, depth+1)
+		}
+	}
+	e.floats = saved
+
+	bottom := box.Y + box.PaddingTop + box.BorderTop
+	for _, ch := range box.Children {
+		bottom = math.Max(bottom, ch.GetY()+ch.GetHeight()+ch.GetMarginBottom())
+	}
+	box.Height = bottom - box.Y + box.PaddingBottom + box.BorderBottom
+	if h := parseLength(st["height"].Value, 0, 0); h > 0 {
+		box.Height = contentSize(st, h, pt+pb) + pt + pb
+	}
+
+	// Shrink to fit the content when no width is given
+	if autoWidth && len(box.Children) > 0 {
+		extent := box.X + box.PaddingLeft
+		for _, ch := range box.Children {
+			extent = math.Max(extent, e.contentRight(ch))
+		}
+		if w := extent - box.X + box.PaddingRight; w < box.Width {
+			box.Width = w
+			// Keep standalone text boxes, sized to the old width, inside
+			for _, ch := range box.Children {
+				if ib, ok := ch.(*InlineBox); ok && ib.X+ib.Width > box.X+w-box.PaddingRight {
+					ib.Width = math.Max(0, box.X+w-box.PaddingRight-ib.X)
+				}
+			}
+		}
+	}
+	return box
+}
+
+// placeFloat moves a laid out float to its final position and registers it
+// with the current block formatting context
+func (e *Engine) placeFloat(b Box, parentBox *BlockBox, side string, top, left, right float64) {
+	outerW := b.GetMarginLeft() + b.GetWidth() + b.GetMarginRight()
+	outerH := b.GetMarginTop() + b.GetHeight() + b.GetMarginBottom()
+
+	y, l, r := e.fitLine(top, outerH, outerW, left, right)
+	x := l + b.GetMarginLeft()
+	if side == "right" {
+		x = r - b.GetMarginRight() - b.GetWidth()
+	}
+	dx, dy := x-b.GetX(), y+b.GetMarginTop()-b.GetY()
+	b.SetPosition(x, y+b.GetMarginTop())
+	if bb, ok := b.(*BlockBox); ok {
+		e.shiftDescendants(bb, dx, dy)
+	}
+
+	parentBox.Children = append(parentBox.Children, b)
+	e.floated[b] = true
+	e.floats = append(e.floats, floatArea{
+		box:    b,
+		side:   side,
+		left:   x - b.GetMarginLeft(),
+		right:  x + b.GetWidth() + b.GetMarginRight(),
+		top:    y,
+		bottom: y + outerH,
+	})
+	if e.Debug {
+		e.debugf("Placed %s float at x=%.2f, y=%.2f, width=%.2f, height=%.2f\n",
+			side, b.GetX(), b.GetY(), b.GetWidth(), b.GetHeight())
+	}
+}
+
+// contentRight returns the right edge of the content actually drawn by a box,
+// used to shrink floats without a declared width to their content
+func (e *Engine) contentRight(b Box) float64 {
+	switch c := b.(type) {
+	case *InlineBox:
+		if c.Node != nil && c.Text != "" {
+			fs := parseLength(c.Style["font-size"].Value, 0, 16)
+			return c.X + math.Min(e.measureTextWidth(c.Text, fs, c.Style), c.Width)
+		}
+		if c.Text == "" {
+			return c.X
+		}
+		return c.X + c.Width
+	case *BlockBox:
+		if declaredWidth(c.Node, c.Style) != "" {
+			return c.X + c.Width
+		}
+		right := c.X + c.PaddingLeft
+		for _, ch := range c.Children {
+			right = math.Max(right, e.contentRight(ch))
+		}
+		return right + c.PaddingRight + c.BorderRight
+	}
+	return b.GetX() + b.GetWidth()
+}
@@ -0,0 +1,380 @@
+package layout
+
+import (
+	"math"
+	"strconv"
+	"strings"
+
+	"github.com/gompdf/gompdf/internal/parser/html"
+	"github.com/gompdf/gompdf/internal/style"
+	xhtml "golang.org/x/net/html"
+)
+
+// FormWidget marks the box of a form control, which is drawn as a static
+// widget: its frame and background are drawn whether or not borders and
+// backgrounds are rendered, as they are what shows the control, with the
+// mark of a checkbox or radio button or the arrow of a drop-down list on
+// top. The value or placeholder of the control is laid out as the text of
+// its box.
+type FormWidget struct {
+	// Kind is text for text fields and text areas, checkbox, radio, select
+	// for a drop-down list, list for a list box, or button
+	Kind string
+	// Name is the name attribute of the control, shared by the radio
+	// buttons of a group
+	Name string
+	// Value is the text of a text field, the option shown by a drop-down
+	// list, or the value a checkbox or radio button submits when checked
+	Value string
+	// Options are the labels of the options of a drop-down list or list
+	// box, and Selected the indexes of those selected
+	Options  []string
+	Selected []int
+	// Checked is set for a checkbox or radio button with the checked
+	// attribute
+	Checked bool
+	// Multiline is set for text areas, Password for password fields and
+	// Multiple for list boxes allowing several options to be selected
+	Multiline, Password, Multiple bool
+	// ReadOnly is set for controls that are readonly or disabled
+	ReadOnly bool
+	// MaxLength is the maxlength of a text field, 0 for none
+	MaxLength int
+	// baseline is the distance from the top border edge of a text field or
+	// list to its baseline, 0 for other controls
+	baseline float64
+	// ArrowWidth is the room of the arrow of a drop-down list at the right
+	// of its padding box
+	ArrowWidth float64
+}
+
+// Form control defaults, in px
+const (
+	// checkSize is the size of the box of a checkbox or radio button
+	checkSize = 13
+	// arrowWidth is the room a drop-down list keeps for its arrow, in ems
+	arrowWidth = 1.2
+	// placeholderColor is the color of the placeholder of an empty field
+	placeholderColor = "#757575"
+)
+
+// isFormControl reports whether an element is a form control laid out as a
+// static widget: an <input>, <textarea> or <select>. A <button> has content
+// of its own and is laid out as an inline-block.
+func isFormControl(node *html.Node) bool {
+	switch strings.ToLower(node.Data) {
+	case "input", "textarea", "select":
+		return true
+	}
+	return false
+}
+
+// isHiddenInput reports whether an element is an <input type=hidden>, which
+// is not shown
+func isHiddenInput(node *html.Node) bool {
+	return strings.EqualFold(node.Data, "input") && inputType(node) == "hidden"
+}
+
+// inputType returns the type of an <input>, text by default
+func inputType(node *html.Node) string {
+	if t := strings.ToLower(strings.TrimSpace(elementAttr(node, "type"))); t != "" {
+		return t
+	}
+	return "text"
+}
+
+// formControl is what a form control shows: its text, in rows lines, and
+// the kind of widget drawn around it
+type formControl struct {
+	widget      FormWidget
+	text        string
+	placeholder bool
+	rows        int
+	// cols is the width of a text field in characters, 0 for controls as
+	// wide as their text
+	cols int
+}
+
+// describeFormControl reads what a form control shows from its attributes
+// and content
+func describeFormControl(node *html.Node) formControl {
+	fc := formControl{rows: 1}
+	w := &fc.widget
+	w.Kind = "text"
+	w.Name = elementAttr(node, "name")
+	w.ReadOnly = hasAttribute(node, "readonly") || hasAttribute(node, "disabled")
+	value := elementAttr(node, "value")
+	switch strings.ToLower(node.Data) {
+	case "textarea":
+		w.Value = strings.TrimPrefix(nodeText(node), "\n")
+		w.Multiline = true
+		fc.text = w.Value
+		fc.rows = positiveAttr(node, "rows", 2)
+		fc.cols = positiveAttr(node, "cols", 20)
+	case "select":
+		options := selectOptions(node)
+		for i, o := range options {
+			w.Options = append(w.Options, optionLabel(o))
+			if hasAttribute(o, "selected") {
+				w.Selected = append(w.Selected, i)
+			}
+		}
+		w.Multiple = hasAttribute(node, "multiple")
+		size := positiveAttr(node, "size", 1)
+		if w.Multiple && elementAttr(node, "size") == "" {
+			size = 4
+		}
+		if w.Multiple || size > 1 {
+			// A list box shows its first options, one per line
+			w.Kind = "list"
+			fc.rows = size
+			fc.text = strings.Join(w.Options, "\n")
+			break
+		}
+		// A drop-down list shows its last selected option, or its first
+		w.Kind = "select"
+		if n := len(w.Selected); n > 0 {
+			w.Selected = w.Selected[n-1:]
+		} else if len(options) > 0 {
+			w.Selected = []int{0}
+		}
+		if len(w.Selected) > 0 {
+			w.Value = w.Options[w.Selected[0]]
+			fc.text = w.Value
+		}
+	default:
+		switch t := inputType(node); t {
+		case "checkbox", "radio":
+			w.Kind = t
+			w.Checked = hasAttribute(node, "checked")
+			w.Value = value
+			if !hasAttribute(node, "value") {
+				w.Value = "on"
+			}
+			fc.rows = 0
+			return fc
+		case "submit", "reset", "button", "image":
+			w.Kind = "button"
+			fc.text = value
+			if !hasAttribute(node, "value") {
+				fc.text = map[string]string{"submit": "Submit", "reset": "Reset", "image": elementAttr(node, "alt")}[t]
+			}
+			return fc
+		case "file":
+			w.Kind = "button"
+			fc.text = "Choose File"
+			return fc
+		case "password":
+			w.Password = true
+			fc.text = strings.Repeat("•", len([]rune(value)))
+		default:
+			fc.text = value
+		}
+		w.Value = value
+		w.MaxLength = positiveAttr(node, "maxlength", 0)
+		fc.cols = positiveAttr(node, "size", 20)
+	}
+	if fc.text == "" {
+		if p := elementAttr(node, "placeholder"); p != "" {
+			fc.text, fc.placeholder = p, true
+		}
+	}
+	return fc
+}
+
+// selectOptions returns the options of a <select>, including those in its
+// option groups
+func selectOptions(node *html.Node) []*html.Node {
+	var options []*html.Node
+	for c := node.FirstChild; c != nil; c = c.NextSibling {
+		if c.Type != xhtml.ElementNode {
+			continue
+		}
+		switch strings.ToLower(c.Data) {
+		case "option":
+			options = append(options, c)
+		case "optgroup":
+			options = append(options, selectOptions(c)...)
+		}
+	}
+	return options
+}
+
+// optionLabel returns the text an <option> shows: its label attribute or
+// its content with white space collapsed
+func optionLabel(o *html.Node) string {
+	if l := elementAttr(o, "label"); l != "" {
+		return l
+	}
+	return strings.Join(strings.Fields(nodeText(o)), " ")
+}
+
+// nodeText returns the text content of an element
+func nodeText(n *html.Node) string {
+	var sb strings.Builder
+	for c := n.FirstChild; c != nil; c = c.NextSibling {
+		switch c.Type {
+		case xhtml.TextNode:
+			sb.WriteString(c.Data)
+		case xhtml.ElementNode:
+			sb.WriteString(nodeText(c))
+		}
+	}
+	return sb.String()
+}
+
+// positiveAttr returns an attribute holding a positive integer, such as the
+// rows of a <textarea>, or def when it is missing or invalid
+func positiveAttr(n *html.Node, key string, def int) int {
+	if v, err := strconv.Atoi(strings.TrimSpace(elementAttr(n, key))); err == nil && v > 0 {
+		return v
+	}
+	return def
+}
+
+// formControlWidth returns the width of the content box of a form control
+// without a declared width: that of its cols or size in characters, or of
+// its text, with room for the arrow of a drop-down list
+func (e *Engine) formControlWidth(node *html.Node, st style.ComputedStyle) float64 {
+	fc := describeFormControl(node)
+	fs := parseLength(st["font-size"].Value, 0, 16)
+	switch {
+	case fc.rows == 0:
+		return checkSize
+	case fc.cols > 0:
+		return float64(fc.cols) * e.measureTextWidth("0", fs, st)
+	}
+	w := 0.0
+	for _, line := range strings.Split(fc.text, "\n") {
+		w = math.Max(w, e.measureTextWidth(line, fs, st))
+	}
+	if fc.widget.Kind == "select" {
+		w += arrowWidth * fs
+	}
+	return w
+}
+
+// buildFormControl lays out a form control as an inline-block at the origin
+// of container, as buildShrinkToFit does: a box sized by its attributes or
+// its text, unless its width and height are declared, holding its value, or
+// its placeholder when it has none, in line boxes. Checkboxes and radio
+// buttons hold no text.
+func (e *Engine) buildFormControl(node *html.Node, container *BlockBox, st style.ComputedStyle, available float64) *BlockBox {
+	fc := describeFormControl(node)
+	mt, mr, mb, ml := boxEdges(st, "margin", container.Width)
+	pt, pr, pb, pl := boxEdges(st, "padding", container.Width)
+	bt, br, bb, bl := st.Border("top").Width, st.Border("right").Width, st.Border("bottom").Width, st.Border("left").Width
+	fs := parseLength(st["font-size"].Value, 0, 16)
+	lh := parseLineHeight(st["line-height"].Value, fs, 1.2*fs)
+
+	box := &BlockBox{
+		Node:     node,
+		Style:    st,
+		X:        container.X + container.PaddingLeft + container.BorderLeft + ml,
+		Y:        mt,
+		Children: []Box{},
+		Widget:   &fc.widget,
+	}
+	box.MarginTop, box.MarginRight, box.MarginBottom, box.MarginLeft = mt, mr, mb, ml
+	box.PaddingTop, box.PaddingRight, box.PaddingBottom, box.PaddingLeft = pt, pr, pb, pl
+	box.BorderTop, box.BorderRight, box.BorderBottom, box.BorderLeft = bt, br, bb, bl
+	if fc.widget.Kind == "select" {
+		fc.widget.ArrowWidth = arrowWidth * fs
+		box.PaddingRight += fc.widget.ArrowWidth
+	}
+
+	edgesW, edgesH := box.PaddingLeft+box.PaddingRight+bl+br, pt+pb+bt+bb
+	if fc.rows == 0 {
+		// Checkboxes and radio buttons are a fixed size, their frame
+		// included
+		box.Width = parseLength(declaredWidth(node, st), container.Width, checkSize)
+		box.Height = parseLength(st["height"].Value, 0, checkSize)
+		return box
+	}
+	width := e.formControlWidth(node, st) - fc.widget.ArrowWidth
+	if w := declaredWidth(node, st); w != "" {
+		width = contentSize(st, parseLength(w, container.Width, width), edgesW)
+	} else {
+		width = math.Min(width, available-ml-mr-edgesW)
+	}
+	box.Width = math.Max(width, 0) + edgesW
+	height := float64(fc.rows) * lh
+	if h := strings.TrimSpace(st["height"].Value); h != "" && !isAuto(h) {
+		height = contentSize(st, parseLength(h, 0, height), edgesH)
+	}
+	box.Height = height + edgesH
+	// A field of one line has the baseline of its text, centered in it
+	// whether or not it has any; one of several lines scrolls, so it has
+	// that of its bottom margin edge like boxes clipping their overflow
+	if fc.rows == 1 {
+		fc.widget.baseline = bt + pt + (height-lh)/2 + (lh-fs)/2 + 0.8*fs
+	} else {
+		fc.widget.baseline = box.Height + mb
+	}
+
+	if fc.text == "" {
+		return box
+	}
+	runStyle := make(style.ComputedStyle, len(st))
+	for k, v := range st {
+		runStyle[k] = v
+	}
+	delete(runStyle, "vertical-align")
+	if fc.placeholder {
+		runStyle["color"] = style.StyleProperty{Name: "color", Value: placeholderColor}
+	}
+	// Single line fields do not wrap their text, which is clipped
+	ws := "pre"
+	if strings.EqualFold(node.Data, "textarea") {
+		ws = "pre-wrap"
+	}
+	runStyle["white-space"] = style.StyleProperty{Name: "white-space", Value: ws}
+	text := processWhitespace(fc.text, runStyle)
+
+	outer := box.Width
+	box.Width = outer - edgesW
+	e.layoutInlineRuns(node, []inlineRun{{text: text, style: runStyle}}, box)
+	// The lines do not change the size of the control
+	box.Width, box.Height = outer, height+edgesH
+
+	// The text of a single line is centered vertically, as in browsers
+	if fc.rows == 1 && len(box.Children) > 0 {
+		last := box.Children[len(box.Children)-1]
+		used := last.GetY() + last.GetHeight() - box.Y - pt - bt
+		if dy := (height - used) / 2; dy > 0 {
+			e.shiftDescendants(box, 0, dy)
+		}
+	}
+	return box
+}
+
+// checkMark is the outline of the mark of a checked checkbox in a unit
+// square
+var checkMark = [][2]float64{{0.18, 0.52}, {0.3, 0.4}, {0.43, 0.55}, {0.72, 0.22}, {0.84, 0.34}, {0.43, 0.78}}
+
+// CheckMark returns the outline of the mark of a checked checkbox, which
+// fills its box within its borders
+func (w *FormWidget) CheckMark(b *BlockBox) [][2]float64 {
+	x, y := b.X+b.BorderLeft, b.Y+b.BorderTop
+	sw, sh := b.Width-b.BorderLeft-b.BorderRight, b.Height-b.BorderTop-b.BorderBottom
+	points := make([][2]float64, len(checkMark))
+	for i, p := range checkMark {
+		points[i] = [2]float64{x + p[0]*sw, y + p[1]*sh}
+	}
+	return points
+}
+
+// RadioCircle returns the center and radius of the circle of a radio button,
+// the largest that fits its box
+func (w *FormWidget) RadioCircle(b *BlockBox) (cx, cy, radius float64) {
+	return b.X + b.Width/2, b.Y + b.Height/2, math.Min(b.Width, b.Height) / 2
+}
+
+// Arrow returns the outline of the arrow of a drop-down list: a triangle
+// pointing down, centered in the room kept for it
+func (w *FormWidget) Arrow(b *BlockBox) [][2]float64 {
+	cx := b.X + b.Width - b.BorderRight - w.ArrowWidth/2
+	cy := b.Y + b.Height/2
+	half := w.ArrowWidth / 4
+	return [][2]float64{{cx - half, cy - half/2}, {cx + half, cy - half/2}, {cx, cy + half/2}}
+}
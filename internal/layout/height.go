@@ -0,0 +1,67 @@
+package layout
+
+import (
+	"strings"
+
+	"github.com/gompdf/gompdf/internal/parser/html"
+	xhtml "golang.org/x/net/html"
+)
+
+// sizeHeight gives a block box laid out with its content its declared
+// height, kept between its min-height and max-height. Heights are those of
+// the content box, or of the border box with box-sizing: border-box;
+// content taller than a declared height overflows it.
+func (e *Engine) sizeHeight(b *BlockBox) {
+	st := b.Style
+	insets := b.PaddingTop + b.PaddingBottom + b.BorderTop + b.BorderBottom
+	base, definite := e.containingHeight(b.Node)
+	length := func(name string) float64 {
+		v := strings.TrimSpace(st[name].Value)
+		if strings.HasSuffix(v, "%") && !definite {
+			// A percentage of a height that depends on the content
+			// counts as auto
+			return -1
+		}
+		return parseLength(v, base, -1)
+	}
+	height := b.Height
+	if v := length("height"); v >= 0 {
+		height = contentSize(st, v, insets) + insets
+	}
+	if v := length("max-height"); v >= 0 && height > contentSize(st, v, insets)+insets {
+		height = contentSize(st, v, insets) + insets
+	}
+	if v := length("min-height"); v > 0 && height < contentSize(st, v, insets)+insets {
+		height = contentSize(st, v, insets) + insets
+	}
+	b.Height = height
+}
+
+// containingHeight returns the height of the content box of the parent of
+// an element, which percentages of its height are of, and whether it is
+// definite: declared as a length or a percentage of a definite height. The
+// root and body elements fill the page area, the content box of the page,
+// unless they declare a height.
+func (e *Engine) containingHeight(n *html.Node) (float64, bool) {
+	page := e.Height - e.options.MarginTop - e.options.MarginBottom
+	if n == nil || n.Parent == nil || n.Parent.Type != xhtml.ElementNode {
+		return page, true
+	}
+	p := n.Parent
+	v := strings.TrimSpace(e.styles[p]["height"].Value)
+	switch {
+	case v == "" || isAuto(v):
+		tag := strings.ToLower(p.Data)
+		return page, tag == "html" || tag == "body"
+	case strings.HasSuffix(v, "%"):
+		base, ok := e.containingHeight(p)
+		if !ok {
+			return 0, false
+		}
+		return parseLength(v, base, 0), true
+	}
+	if h := parseLength(v, 0, -1); h >= 0 {
+		return h, true
+	}
+	return 0, false
+}
@@ -0,0 +1,64 @@
+package layout
+
+import (
+	"testing"
+
+	"github.com/gompdf/gompdf/internal/parser/html"
+	"github.com/gompdf/gompdf/internal/style"
+)
+
+// layoutHTML lays out an HTML document with the default options
+func layoutHTML(t *testing.T, content string) *BlockBox {
+	t.Helper()
+	doc, err := html.NewParser().ParseString(content)
+	if err != nil {
+		t.Fatal(err)
+	}
+	e := NewEngine()
+	e.Debug = false
+	e.SetStyles(style.NewStyleEngine().ComputeStyles(doc))
+	return e.Layout(doc)
+}
+
+// blockByID returns the block box of the element with the given id
+func blockByID(b Box, id string) *BlockBox {
+	if bb, ok := b.(*BlockBox); ok {
+		if bb.Node != nil {
+			for _, a := range bb.Node.Attr {
+				if a.Key == "id" && a.Val == id {
+					return bb
+				}
+			}
+		}
+		for _, c := range bb.Children {
+			if found := blockByID(c, id); found != nil {
+				return found
+			}
+		}
+	}
+	return nil
+}
+
+func TestAutoHeightIncludesBottomInsets(t *testing.T) {
+	tests := []struct {
+		name, content string
+	}{
+		{"block child", `<div style="margin:0"><p style="margin:0">text</p></div>`},
+		{"text", `text`},
+	}
+	for _, tt := range tests {
+		root := layoutHTML(t, `<body style="margin:0"><div id="a" style="padding:10px;border:2px solid">`+tt.content+`</div><div id="b">next</div></body>`)
+		a, b := blockByID(root, "a"), blockByID(root, "b")
+		if a == nil || b == nil {
+			t.Fatalf("%s: blocks not found", tt.name)
+		}
+		inner := a.Children[len(a.Children)-1]
+		want := inner.GetY() + inner.GetHeight() + a.PaddingBottom + a.BorderBottom
+		if got := a.Y + a.Height; got < want-0.01 || got > want+0.01 {
+			t.Errorf("%s: bottom of block is %.2f, want %.2f", tt.name, got, want)
+		}
+		if b.Y < want-0.01 {
+			t.Errorf("%s: next block at %.2f overlaps the block ending at %.2f", tt.name, b.Y, want)
+		}
+	}
+}
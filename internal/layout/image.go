@@ -1,13 +1,22 @@
 package layout
 
 import (
+	"math"
+	"strconv"
+	"strings"
+
 	"github.com/gompdf/gompdf/internal/parser/html"
+	"github.com/gompdf/gompdf/internal/parser/pdf"
+	"github.com/gompdf/gompdf/internal/res"
 	"github.com/gompdf/gompdf/internal/style"
 )
 
-// ImageBox represents an <img> element laid out as an inline replaced element
+// ImageBox represents an <img> or inline <svg> element laid out as an inline
+// replaced element
 // It implements the Box interface.
-// For simplicity we treat it as inline-level and size it from CSS width/height or a default.
+// It is sized from CSS width/height, then the width/height attributes, then the
+// intrinsic dimensions of the image, keeping the aspect ratio when only one
+// dimension is given, within its min/max width and height.
 
 type ImageBox struct {
 	Node   *html.Node
@@ -34,24 +43,299 @@ type ImageBox struct {
 	BorderLeft   float64
 
 	Src string // resolved later by renderer via Loader; stores the attribute value
+
+	// IntrinsicWidth and IntrinsicHeight are the natural dimensions of the
+	// image, or 0 when unknown (for example when it failed to load)
+	IntrinsicWidth  float64
+	IntrinsicHeight float64
+	// Orientation is the EXIF orientation the image is turned upright
+	// from when it is drawn, 0 or 1 to draw it as stored
+	Orientation int
+
+	// Clip is set when an ancestor clips its overflow
+	Clip *Clip
 }
 
+// defaultImageSize is used for images whose size cannot be determined
+const defaultImageSize = 40.0
+
+// Layout sizes the image as CSS sizes replaced elements. A dimension set in
+// CSS is used as is; one given by the width or height attribute is the
+// preferred size of the image, which like an auto dimension keeps the
+// aspect ratio when min-width, max-width, min-height or max-height change
+// the other. The ratio is that of the aspect-ratio property, else of the
+// image, else of the width and height attributes.
 func (b *ImageBox) Layout(containingBlock *BlockBox) {
-	// Size from CSS width/height if present, else default square 40px
-	w := 40.0
-	h := 40.0
-	if prop, ok := b.Style["width"]; ok && prop.Value != "" {
-		if v := parseLength(prop.Value, containingBlock.Width, w); v > 0 {
-			w = v
+	cbWidth := 0.0
+	if containingBlock != nil {
+		cbWidth = containingBlock.Width - containingBlock.PaddingLeft - containingBlock.PaddingRight -
+			containingBlock.BorderLeft - containingBlock.BorderRight
+	}
+	cssW, cssH := b.cssDimension("width", cbWidth), b.cssDimension("height", cbWidth)
+	attrW, attrH := b.attrDimension("width", cbWidth), b.attrDimension("height", cbWidth)
+	w, h := cssW, cssH
+	if w == 0 {
+		w = attrW
+	}
+	if h == 0 {
+		h = attrH
+	}
+	ratio := b.aspectRatio(attrW, attrH)
+
+	switch {
+	case w > 0 && h > 0:
+	case w > 0:
+		h = w
+		if ratio > 0 {
+			h = w / ratio
+		}
+	case h > 0:
+		w = h
+		if ratio > 0 {
+			w = h * ratio
+		}
+	case b.IntrinsicWidth > 0 && b.IntrinsicHeight > 0:
+		w, h = b.IntrinsicWidth, b.IntrinsicHeight
+	default:
+		w, h = defaultImageSize, defaultImageSize
+		if ratio > 0 {
+			h = w / ratio
 		}
 	}
-	if prop, ok := b.Style["height"]; ok && prop.Value != "" {
-		if v := parseLength(prop.Value, containingBlock.Width, h); v > 0 {
-			h = v
+
+	minW, maxW := b.sizeLimits("width", cbWidth)
+	minH, maxH := b.sizeLimits("height", cbWidth)
+	switch {
+	case cssW == 0 && cssH == 0:
+		w, h = constrainReplaced(w, h, minW, maxW, minH, maxH)
+	case cssW > 0 && cssH == 0 && attrH == 0:
+		// The auto height follows the width as constrained
+		w = clampSize(w, minW, maxW)
+		h = w
+		if ratio > 0 {
+			h = w / ratio
+		}
+		h = clampSize(h, minH, maxH)
+	case cssH > 0 && cssW == 0 && attrW == 0:
+		h = clampSize(h, minH, maxH)
+		w = h
+		if ratio > 0 {
+			w = h * ratio
 		}
+		w = clampSize(w, minW, maxW)
+	default:
+		w, h = clampSize(w, minW, maxW), clampSize(h, minH, maxH)
 	}
 	b.Width = w
 	b.Height = h
+	if b.Style.VerticalWritingMode() {
+		// Laid out in the turned frame of a vertical flow, the image runs
+		// along the lines by its height
+		b.Width, b.Height = h, w
+	}
+}
+
+// cssDimension returns the width or height of the image set in CSS, or 0
+// when it is auto
+func (b *ImageBox) cssDimension(name string, cbWidth float64) float64 {
+	v := strings.TrimSpace(b.Style[name].Value)
+	if v == "" || strings.EqualFold(v, "auto") {
+		return 0
+	}
+	if strings.HasSuffix(v, "%") && name == "height" {
+		// Percentages of the height of the containing block, which is not
+		// known while its content is laid out, count as auto
+		return 0
+	}
+	return math.Max(0, parseLength(v, cbWidth, 0))
+}
+
+// attrDimension returns the width or height attribute of the image, or 0
+// when it has none
+func (b *ImageBox) attrDimension(name string, cbWidth float64) float64 {
+	if b.Node == nil {
+		return 0
+	}
+	for _, a := range b.Node.Attr {
+		if strings.EqualFold(a.Key, name) {
+			return math.Max(0, parseLength(strings.TrimSpace(a.Val), cbWidth, 0))
+		}
+	}
+	return 0
+}
+
+// sizeLimits returns the min-width and max-width, or min-height and
+// max-height, of the image; the maximum is infinite for none and never less
+// than the minimum
+func (b *ImageBox) sizeLimits(name string, cbWidth float64) (float64, float64) {
+	limit := func(prop string, none float64) float64 {
+		v := strings.TrimSpace(b.Style[prop].Value)
+		if v == "" || strings.EqualFold(v, "none") || strings.EqualFold(v, "auto") ||
+			strings.HasSuffix(v, "%") && name == "height" {
+			return none
+		}
+		return parseLength(v, cbWidth, none)
+	}
+	lo := math.Max(0, limit("min-"+name, 0))
+	hi := math.Max(lo, limit("max-"+name, math.Inf(1)))
+	return lo, hi
+}
+
+// aspectRatio returns the width over the height the image keeps: that of
+// the aspect-ratio property unless it is auto and the image has its own, or
+// that of the image, or of its width and height attributes; 0 when there
+// is none
+func (b *ImageBox) aspectRatio(attrW, attrH float64) float64 {
+	intrinsic := 0.0
+	switch {
+	case b.IntrinsicWidth > 0 && b.IntrinsicHeight > 0:
+		intrinsic = b.IntrinsicWidth / b.IntrinsicHeight
+	case attrW > 0 && attrH > 0:
+		intrinsic = attrW / attrH
+	}
+	v := strings.ToLower(strings.TrimSpace(b.Style["aspect-ratio"].Value))
+	auto := strings.Contains(v, "auto")
+	if auto && intrinsic > 0 {
+		return intrinsic
+	}
+	if ratio := parseRatio(strings.TrimSpace(strings.ReplaceAll(v, "auto", ""))); ratio > 0 {
+		return ratio
+	}
+	return intrinsic
+}
+
+// parseRatio parses a CSS ratio such as "16 / 9" or "1.5", returning 0 when
+// it is invalid
+func parseRatio(v string) float64 {
+	num, den, hasDen := strings.Cut(v, "/")
+	n, err := strconv.ParseFloat(strings.TrimSpace(num), 64)
+	if err != nil || n <= 0 {
+		return 0
+	}
+	if !hasDen {
+		return n
+	}
+	d, err := strconv.ParseFloat(strings.TrimSpace(den), 64)
+	if err != nil || d <= 0 {
+		return 0
+	}
+	return n / d
+}
+
+// clampSize limits a dimension to a minimum and a maximum
+func clampSize(v, lo, hi float64) float64 {
+	return math.Max(lo, math.Min(v, hi))
+}
+
+// constrainReplaced applies minimum and maximum sizes to the width and
+// height of a replaced element whose dimensions are both auto, keeping its
+// proportions as far as the limits allow, as in the table of section 10.4
+// of CSS 2.1
+func constrainReplaced(w, h, minW, maxW, minH, maxH float64) (float64, float64) {
+	if w <= 0 || h <= 0 {
+		return clampSize(w, minW, maxW), clampSize(h, minH, maxH)
+	}
+	switch {
+	case w > maxW && h > maxH:
+		if maxW/w <= maxH/h {
+			return maxW, math.Max(minH, maxW*h/w)
+		}
+		return math.Max(minW, maxH*w/h), maxH
+	case w < minW && h < minH:
+		if minW/w <= minH/h {
+			return math.Min(maxW, minH*w/h), minH
+		}
+		return minW, math.Min(maxH, minW*h/w)
+	case w < minW && h > maxH:
+		return minW, maxH
+	case w > maxW && h < minH:
+		return maxW, minH
+	case w > maxW:
+		return maxW, math.Max(maxW*h/w, minH)
+	case w < minW:
+		return minW, math.Min(minW*h/w, maxH)
+	case h > maxH:
+		return math.Max(maxH*w/h, minW), maxH
+	case h < minH:
+		return math.Min(minH*w/h, maxW), minH
+	}
+	return w, h
+}
+// newImageBox creates the box for an <img> or inline <svg> element, reading
+// the intrinsic dimensions of the image through the engine's loader when one
+// is set. The caller lays the box out against its containing block.
+func (e *Engine) newImageBox(node *html.Node, st style.ComputedStyle, x, y float64) *ImageBox {
+	img := &ImageBox{
+		Node:  node,
+		Style: st,
+		X:     x,
+		Y:     y,
+	}
+	choice := selectImage(node, e.options)
+	img.Src = choice.src
+	if e.loader != nil && img.Src != "" {
+		if resrc, err := e.loader.LoadImage(img.Src); err == nil {
+			img.IntrinsicWidth, img.IntrinsicHeight = intrinsicSize(resrc, choice)
+			if e.appliesOrientation(st) {
+				img.Orientation = resrc.ImageOrientation()
+			}
+			if img.Orientation >= 5 {
+				// The image is turned a quarter turn
+				img.IntrinsicWidth, img.IntrinsicHeight = img.IntrinsicHeight, img.IntrinsicWidth
+			}
+		} else if e.Debug {
+			e.debugf("Failed to load image %q: %v\n", img.Src, err)
+		}
+	}
+	return img
+}
+
+// appliesOrientation reports whether images of an element are turned
+// upright by their EXIF orientation: unless the options ignore it or the
+// element has image-orientation: none
+func (e *Engine) appliesOrientation(st style.ComputedStyle) bool {
+	if e.options.IgnoreImageOrientation {
+		return false
+	}
+	return !strings.EqualFold(strings.TrimSpace(st["image-orientation"].Value), "none")
+}
+
+// intrinsicSize returns the intrinsic dimensions of the image chosen for a
+// replaced element, 0 when they are unknown. Those of SVG documents are the
+// size of their view box. A raster image is as large as its pixels at the
+// density of its srcset candidate, or else at the resolution it declares,
+// so that a 300 DPI scan has its printed size; images without one count a
+// pixel as a point. A PDF document has the size of the page the fragment of
+// its source selects, the first by default.
+func intrinsicSize(resrc *res.Resource, choice imageChoice) (float64, float64) {
+	if resrc.IsPDF() {
+		doc, err := pdf.Read(resrc.Data)
+		if err != nil {
+			return 0, 0
+		}
+		w, h, err := doc.PageSize(res.FragmentPage(choice.src))
+		if err != nil {
+			return 0, 0
+		}
+		return w, h
+	}
+	w, h, err := resrc.ImageSize()
+	if err != nil || w <= 0 || h <= 0 {
+		return 0, 0
+	}
+	iw, ih := float64(w), float64(h)
+	switch {
+	case resrc.IsSVG():
+	case choice.slot > 0:
+		iw, ih = choice.slot, choice.slot*ih/iw
+	case choice.density > 0:
+		iw, ih = iw/choice.density, ih/choice.density
+	default:
+		if dpiX, dpiY, ok := resrc.ImageResolution(); ok {
+			iw, ih = iw*72/dpiX, ih*72/dpiY
+		}
+	}
+	return iw, ih
 }
 
 func (b *ImageBox) GetX() float64      { return b.X }
@@ -2,7 +2,6 @@ package layout
 
 import (
 	"math"
-	"strconv"
 	"strings"
 
 	"github.com/gompdf/gompdf/internal/parser/html"
@@ -33,6 +32,14 @@ type InlineBox struct {
 	BorderLeft    float64
 	Children      []Box
 	Text          string
+	// RTL marks text drawn right to left; Text stays in logical order
+	RTL bool
+	// Vertical marks text set in a vertical writing mode, which runs down
+	// the box with its baseline a font size left of the right edge; see
+	// layoutVertical
+	Vertical bool
+	// Clip is set when an ancestor clips its overflow
+	Clip *Clip
 }
 
 // NewInlineBox creates a new inline box for an element
@@ -74,32 +81,13 @@ func (b *InlineBox) Layout(containingBlock *BlockBox) {
 
 // parseBoxModel parses margin, padding, and border properties
 func (b *InlineBox) parseBoxModel() {
-    // Margin shorthand support
-    if m, ok := b.Style["margin"]; ok && strings.TrimSpace(m.Value) != "" {
-        t, r, bt, l := parseBoxShorthand(m.Value, b.Width, 0)
-        b.MarginTop, b.MarginRight, b.MarginBottom, b.MarginLeft = t, r, bt, l
-    } else {
-        b.MarginTop = parseLength(b.Style["margin-top"].Value, b.Width, 0)
-        b.MarginRight = parseLength(b.Style["margin-right"].Value, b.Width, 0)
-        b.MarginBottom = parseLength(b.Style["margin-bottom"].Value, b.Width, 0)
-        b.MarginLeft = parseLength(b.Style["margin-left"].Value, b.Width, 0)
-    }
-
-    // Padding shorthand support
-    if p, ok := b.Style["padding"]; ok && strings.TrimSpace(p.Value) != "" {
-        t, r, bt, l := parseBoxShorthand(p.Value, b.Width, 0)
-        b.PaddingTop, b.PaddingRight, b.PaddingBottom, b.PaddingLeft = t, r, bt, l
-    } else {
-        b.PaddingTop = parseLength(b.Style["padding-top"].Value, b.Width, 0)
-        b.PaddingRight = parseLength(b.Style["padding-right"].Value, b.Width, 0)
-        b.PaddingBottom = parseLength(b.Style["padding-bottom"].Value, b.Width, 0)
-        b.PaddingLeft = parseLength(b.Style["padding-left"].Value, b.Width, 0)
-    }
-
-	b.BorderTop = parseLength(b.Style["border-top-width"].Value, b.Width, 0)
-	b.BorderRight = parseLength(b.Style["border-right-width"].Value, b.Width, 0)
-	b.BorderBottom = parseLength(b.Style["border-bottom-width"].Value, b.Width, 0)
-	b.BorderLeft = parseLength(b.Style["border-left-width"].Value, b.Width, 0)
+	b.MarginTop, b.MarginRight, b.MarginBottom, b.MarginLeft = boxEdges(b.Style, "margin", b.Width)
+	b.PaddingTop, b.PaddingRight, b.PaddingBottom, b.PaddingLeft = boxEdges(b.Style, "padding", b.Width)
+
+	b.BorderTop = b.Style.Border("top").Width
+	b.BorderRight = b.Style.Border("right").Width
+	b.BorderBottom = b.Style.Border("bottom").Width
+	b.BorderLeft = b.Style.Border("left").Width
 }
 
 // calculateTextDimensions calculates dimensions for text content
@@ -213,41 +201,43 @@ func parseLength(value string, containerSize float64, defaultValue float64) floa
 		return defaultValue
 	}
 
-	if strings.HasSuffix(value, "%") {
-		percentage, err := strconv.ParseFloat(value[:len(value)-1], 64)
-		if err != nil {
-			return defaultValue
+	if isCalc(strings.TrimSpace(value)) {
+		if v, ok := evalCalc(value, containerSize); ok {
+			return v
 		}
-		return containerSize * percentage / 100
+		return defaultValue
 	}
 
-	if strings.HasSuffix(value, "px") {
-		pixels, err := strconv.ParseFloat(value[:len(value)-2], 64)
-		if err != nil {
-			return defaultValue
-		}
-		return pixels
+	n, unit, ok := style.SplitUnit(value)
+	if !ok {
+		return defaultValue
 	}
-
-	if strings.HasSuffix(value, "em") {
-		ems, err := strconv.ParseFloat(value[:len(value)-2], 64)
-		if err != nil {
-			return defaultValue
-		}
-		return ems * 16
+	switch unit {
+	case "%":
+		return containerSize * n / 100
+	case "em", "rem":
+		return n * 16
+	case "ex", "ch":
+		// Without font metrics both are taken as half an em
+		return n * 8
 	}
-
-	if strings.HasSuffix(value, "rem") {
-		rems, err := strconv.ParseFloat(value[:len(value)-3], 64)
-		if err != nil {
-			return defaultValue
-		}
-		return rems * 16
+	if points, ok := style.AbsoluteLength(value); ok {
+		return points
 	}
-
-	pixels, err := strconv.ParseFloat(value, 64)
-	if err != nil {
-		return defaultValue
+	return defaultValue
+}
+
+// parseLineHeight resolves a line-height for text of the given font size.
+// Numbers and percentages multiply the font size; normal and unparsable
+// values give def.
+func parseLineHeight(value string, fontSize, def float64) float64 {
+	if n, unit, ok := style.SplitUnit(value); ok {
+		switch unit {
+		case "":
+			return n * fontSize
+		case "%":
+			return n * fontSize / 100
+		}
 	}
-	return pixels
+	return parseLength(value, 0, def)
 }
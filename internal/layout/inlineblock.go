@@ -0,0 +1,96 @@
+package layout
+
+import (
+	"math"
+	"strings"
+
+	"github.com/gompdf/gompdf/internal/parser/html"
+	"github.com/gompdf/gompdf/internal/style"
+)
+
+// isInlineBlock reports whether a style makes an element an atomic inline:
+// a block container that is laid out on its own and then placed in a line
+// like a single glyph
+func isInlineBlock(st style.ComputedStyle) bool {
+	switch strings.ToLower(strings.TrimSpace(st["display"].Value)) {
+	case "inline-block", "inline-flex", "inline-grid", "inline-flow-root":
+		return true
+	}
+	return false
+}
+
+// buildInlineBlock lays out an inline-block element with a shrink-to-fit
+// width at the origin of container. It returns the box and the distance from
+// the top of its margin box to its baseline.
+func (e *Engine) buildInlineBlock(node *html.Node, container *BlockBox, st style.ComputedStyle, available float64, depth int) (*BlockBox, float64) {
+	box, _ := e.buildShrinkToFit(node, container, st, available, depth).(*BlockBox)
+	return box, box.MarginTop + inlineBlockBaseline(box)
+}
+
+// inlineBlockBaseline returns the baseline of an inline-block relative to
+// its top border edge: that of its last line box, or the bottom margin edge
+// when it has none or clips its overflow. Form fields have their own; see
+// buildFormControl.
+func inlineBlockBaseline(b *BlockBox) float64 {
+	if b.Widget != nil && b.Widget.baseline > 0 {
+		return b.Widget.baseline
+	}
+	bottom := b.Height + b.MarginBottom
+	if b.Style.ClipsOverflow() {
+		return bottom
+	}
+	if y, ok := lastBaseline(b); ok {
+		return y - b.Y
+	}
+	return bottom
+}
+
+// lastBaseline returns the baseline of the lowest line of text inside b
+func lastBaseline(b *BlockBox) (float64, bool) {
+	baseline, found := math.Inf(-1), false
+	for _, ch := range b.Children {
+		switch c := ch.(type) {
+		case *InlineBox:
+			if strings.TrimSpace(c.Text) == "" {
+				continue
+			}
+			// Text boxes are positioned with their baseline one font size
+			// below their top
+			y := c.Y + parseLength(c.Style["font-size"].Value, 0, 16)
+			if y > baseline {
+				baseline, found = y, true
+			}
+		case *BlockBox:
+			if y, ok := lastBaseline(c); ok && y > baseline {
+				baseline, found = y, true
+			}
+		}
+	}
+	return baseline, found
+}
+
+// layoutInlineBlock places an inline-block that is not part of a line of
+// text, such as one directly inside a div, at the start of the next line of
+// the block flow
+func (e *Engine) layoutInlineBlock(node *html.Node, parentBox *BlockBox, st style.ComputedStyle, depth int) {
+	left := parentBox.X + parentBox.PaddingLeft + parentBox.BorderLeft
+	available := parentBox.Width - parentBox.PaddingLeft - parentBox.PaddingRight - parentBox.BorderLeft - parentBox.BorderRight
+	box, _ := e.buildInlineBlock(node, parentBox, st, math.Max(available, 0), depth)
+
+	top := parentBox.Y + parentBox.PaddingTop + parentBox.BorderTop
+	if last := e.lastInFlow(parentBox); last != nil {
+		top = last.GetY() + last.GetHeight()
+	}
+	y := top + box.MarginTop
+	if len(e.floats) > 0 {
+		var l float64
+		y, l, _ = e.fitLine(top, box.MarginTop+box.Height+box.MarginBottom, box.MarginLeft+box.Width+box.MarginRight, left, left+available)
+		left = l
+		y += box.MarginTop
+	}
+	x := left + box.MarginLeft
+	dx, dy := x-box.X, y-box.Y
+	box.SetPosition(x, y)
+	e.shiftDescendants(box, dx, dy)
+	parentBox.Children = append(parentBox.Children, box)
+}
@@ -0,0 +1,272 @@
+package layout
+
+import (
+	"math"
+	"strconv"
+	"strings"
+
+	"github.com/gompdf/gompdf/internal/style"
+)
+
+// lineToken is one unit of inline content placed by layoutParagraphInline:
+// a word, a space, an inline image, an inline-block or a leader
+type lineToken struct {
+	text    string
+	style   style.ComputedStyle
+	width   float64
+	isSpace bool    // Whether this token is a space
+	drop    bool    // Whether to drop this token during layout
+	fs      float64 // Font size
+	lh      float64 // Line height
+	img     *ImageBox
+	block   *BlockBox // Inline-block; fs is the distance to its baseline
+	float   string    // Side a floated image is placed on
+	// preserved marks spaces kept as written instead of collapsed, newline
+	// a preserved line break, tab a preserved tab, which advances to the
+	// next tab stop, and noWrap text that may not wrap before it
+	preserved bool
+	newline   bool
+	tab       bool
+	noWrap    bool
+	level     uint8 // Bidi embedding level; odd levels are right-to-left
+	// leader is the pattern of a leader, which takes the space its line
+	// leaves free once the line is complete
+	leader string
+}
+
+// Text is approximated with an ascent of 0.8em and a descent of 0.2em, the
+// same split the renderer uses
+const (
+	ascentRatio  = 0.8
+	descentRatio = 0.2
+)
+
+// extent returns the top and bottom of the box a token contributes to the
+// line, relative to its baseline with y growing downwards. Text is centered
+// in its line-height; images sit on the baseline; inline-blocks extend by
+// their margin box around their own baseline.
+func (tk lineToken) extent() (float64, float64) {
+	switch {
+	case tk.img != nil:
+		return -tk.img.Height, 0
+	case tk.block != nil:
+		return -tk.fs, tk.lh - tk.fs
+	}
+	halfLeading := (tk.lh - tk.fs) / 2
+	return -(ascentRatio*tk.fs + halfLeading), descentRatio*tk.fs + halfLeading
+}
+
+// strutToken is the zero width token standing for the container's own font
+// and line-height, which every line box is at least as tall as
+func strutToken(st style.ComputedStyle) lineToken {
+	fs := parseLength(st["font-size"].Value, 0, 16)
+	return lineToken{style: st, fs: fs, lh: parseLineHeight(st["line-height"].Value, fs, 1.2*fs)}
+}
+
+// arrangeLine builds the line box for a line of tokens. It returns the
+// baseline of each token relative to the top of the line box and the line
+// box height. vertical-align shifts tokens against the strut's baseline;
+// top and bottom align them with the edges of the line box once its height
+// is known from the other tokens.
+func arrangeLine(line []lineToken, strut lineToken) ([]float64, float64) {
+	shifts := make([]float64, len(line))
+	edges := make([]string, len(line))
+
+	// The line box spans the boxes of the strut and of every token aligned
+	// relative to the baseline
+	lineTop, lineBottom := strut.extent()
+	for i, tk := range line {
+		if tk.drop {
+			continue
+		}
+		top, bottom := tk.extent()
+		shifts[i], edges[i] = baselineShift(tk, top, bottom, strut.fs)
+		if edges[i] == "" {
+			lineTop = math.Min(lineTop, top-shifts[i])
+			lineBottom = math.Max(lineBottom, bottom-shifts[i])
+		}
+	}
+	// Tokens aligned with the top or bottom grow the line box if they are
+	// taller than it
+	for i, tk := range line {
+		if tk.drop || edges[i] == "" {
+			continue
+		}
+		top, bottom := tk.extent()
+		if h := bottom - top; h > lineBottom-lineTop {
+			if edges[i] == "top" {
+				lineBottom = lineTop + h
+			} else {
+				lineTop = lineBottom - h
+			}
+		}
+	}
+
+	height := lineBottom - lineTop
+	baselines := make([]float64, len(line))
+	for i, tk := range line {
+		top, bottom := tk.extent()
+		switch edges[i] {
+		case "top":
+			baselines[i] = -top
+		case "bottom":
+			baselines[i] = height - bottom
+		default:
+			baselines[i] = -lineTop - shifts[i]
+		}
+	}
+	return baselines, height
+}
+
+// baselineShift returns how far vertical-align raises a token above the
+// line's baseline, given the token's extent and the strut's font size, or
+// names the line box edge ("top" or "bottom") the token is aligned with
+// instead
+func baselineShift(tk lineToken, top, bottom, strutFS float64) (float64, string) {
+	v := strings.ToLower(strings.TrimSpace(tk.style["vertical-align"].Value))
+	switch v {
+	case "", "baseline":
+		return 0, ""
+	case "top", "bottom":
+		return 0, v
+	case "super":
+		return strutFS / 3, ""
+	case "sub":
+		return -strutFS / 5, ""
+	case "middle":
+		// The middle of the box goes half an x-height, taken as a quarter
+		// em, above the baseline
+		return (top+bottom)/2 + strutFS/4, ""
+	case "text-top":
+		return top + ascentRatio*strutFS, ""
+	case "text-bottom":
+		return bottom - descentRatio*strutFS, ""
+	}
+	if n, unit, ok := style.SplitUnit(v); ok && unit == "%" {
+		// Percentages refer to the token's line-height
+		return n * tk.lh / 100, ""
+	}
+	return parseLength(v, 0, 0), ""
+}
+
+// nestedShift resolves the vertical-align of an inline element, whose
+// style is own, to the distance its text is raised above the baseline of
+// the line. baseline, sub, super, lengths and percentages of its
+// line-height shift it from the baseline of its parent, which may itself be
+// shifted; sub and super take the font size of the parent. Other values
+// align it with the line box and are kept.
+func nestedShift(va style.StyleProperty, parent, own style.ComputedStyle) style.StyleProperty {
+	v := strings.ToLower(strings.TrimSpace(va.Value))
+	pfs := parseLength(parent["font-size"].Value, 0, 16)
+	var shift float64
+	switch v {
+	case "baseline":
+	case "super":
+		shift = pfs / 3
+	case "sub":
+		shift = -pfs / 5
+	default:
+		n, unit, ok := style.SplitUnit(v)
+		switch {
+		case !ok && !isCalc(v):
+			return va
+		case unit == "%":
+			fs := parseLength(own["font-size"].Value, 0, 16)
+			shift = n * parseLineHeight(own["line-height"].Value, fs, 1.2*fs) / 100
+		default:
+			shift = parseLength(v, 0, 0)
+		}
+	}
+	if p := strings.TrimSpace(parent["vertical-align"].Value); p != "" {
+		if _, unit, ok := style.SplitUnit(p); ok && unit != "%" {
+			shift += parseLength(p, 0, 0)
+		}
+	}
+	va.Value = strconv.FormatFloat(shift, 'f', 2, 64) + "pt"
+	return va
+}
+
+// ellipsizes reports whether lines too wide for a container are cut short
+// with an ellipsis: text-overflow: ellipsis on a box that clips its overflow
+func ellipsizes(st style.ComputedStyle) bool {
+	return strings.EqualFold(strings.TrimSpace(st["text-overflow"].Value), "ellipsis") && st.ClipsOverflow()
+}
+
+// truncateLine cuts a line wider than maxWidth after the last character that
+// still leaves room for an ellipsis, which takes the font of the text it
+// follows. It returns the new line and its width.
+func (e *Engine) truncateLine(line []lineToken, maxWidth float64, strut lineToken) ([]lineToken, float64) {
+	width := 0.0
+	for i, tk := range line {
+		if tk.drop {
+			continue
+		}
+		ell := strut
+		if tk.img == nil && tk.block == nil {
+			ell = tk
+		}
+		ell.text, ell.isSpace, ell.preserved = "…", false, false
+		ell.width = e.measureTextWidth(ell.text, ell.fs, ell.style)
+		if width+tk.width+ell.width <= maxWidth {
+			width += tk.width
+			continue
+		}
+
+		out := line[:i]
+		if !tk.isSpace && tk.text != "" {
+			runes := []rune(tk.text)
+			for n := len(runes) - 1; n > 0; n-- {
+				if w := e.measureTextWidth(string(runes[:n]), tk.fs, tk.style); width+w+ell.width <= maxWidth {
+					tk.text, tk.width = string(runes[:n]), w
+					out = append(out, tk)
+					width += w
+					break
+				}
+			}
+		}
+		for len(out) > 0 && out[len(out)-1].isSpace {
+			width -= out[len(out)-1].width
+			out = out[:len(out)-1]
+		}
+		return append(out, ell), width + ell.width
+	}
+	return line, width
+}
+
+// restyle gives a text token another style and measures it again. Images
+// and inline-blocks are returned unchanged.
+func (e *Engine) restyle(tk lineToken, st style.ComputedStyle) lineToken {
+	if tk.img != nil || tk.block != nil {
+		return tk
+	}
+	tk.style = st
+	tk.fs = parseLength(st["font-size"].Value, 0, 16)
+	tk.lh = parseLineHeight(st["line-height"].Value, tk.fs, 1.2*tk.fs)
+	if !tk.newline {
+		tk.width = e.measureTextWidth(stripSoftHyphens(tk.text), tk.fs, st)
+	}
+	return tk
+}
+
+// firstLineToken styles a token on the first line of a container with the
+// container's ::first-line style. The pseudo-element sits between the
+// container and its content, so a token keeps the properties it does not
+// inherit from the container.
+func (e *Engine) firstLineToken(tk lineToken, container, firstLine style.ComputedStyle) lineToken {
+	if tk.img != nil || tk.block != nil {
+		return tk
+	}
+	st := make(style.ComputedStyle, len(tk.style))
+	for k, v := range tk.style {
+		st[k] = v
+	}
+	for k, v := range firstLine {
+		if own, ok := tk.style[k]; !ok || own.Value == container[k].Value {
+			st[k] = v
+		}
+	}
+	if t, ok := firstLine["text-transform"]; ok && !tk.isSpace {
+		tk.text = style.TransformText(tk.text, t.Value, true)
+	}
+	return e.restyle(tk, st)
+}
@@ -0,0 +1,112 @@
+package layout
+
+import (
+	"math"
+	"strings"
+
+	"github.com/gompdf/gompdf/internal/style"
+)
+
+// softHyphen marks where a word may be hyphenated. It is invisible unless
+// the line is broken there.
+const softHyphen = "\u00ad"
+
+// breaksAll reports whether words may be broken between any two characters
+// to fill lines (word-break: break-all)
+func breaksAll(st style.ComputedStyle) bool {
+	return strings.EqualFold(strings.TrimSpace(st["word-break"].Value), "break-all")
+}
+
+// wrapsAnywhere reports whether a word too long for a line of its own may be
+// broken between any two characters: overflow-wrap (or its legacy alias
+// word-wrap) break-word or anywhere, or word-break: break-word
+func wrapsAnywhere(st style.ComputedStyle) bool {
+	for _, name := range []string{"overflow-wrap", "word-wrap", "word-break"} {
+		switch strings.ToLower(strings.TrimSpace(st[name].Value)) {
+		case "break-word", "anywhere":
+			return true
+		}
+	}
+	return false
+}
+
+// hyphenates reports whether words may be broken at soft hyphens. Without
+// hyphenation dictionaries hyphens: auto breaks at the same places as manual.
+func hyphenates(st style.ComputedStyle) bool {
+	return !strings.EqualFold(strings.TrimSpace(st["hyphens"].Value), "none")
+}
+
+// stripSoftHyphens removes the soft hyphens of a word for measuring and
+// drawing
+func stripSoftHyphens(s string) string {
+	return strings.ReplaceAll(s, softHyphen, "")
+}
+
+// splitWord breaks a word token so that its first part fits in avail. It
+// prefers soft hyphens, which are drawn as a hyphen at the end of the line,
+// and otherwise breaks between characters when word-break: break-all
+// applies, or when the word is alone on its line and overflow-wrap allows
+// it. It reports false when the word cannot be broken to fit.
+func (e *Engine) splitWord(tk lineToken, avail float64, alone bool) (lineToken, lineToken, bool) {
+	head, tail := tk, tk
+	measure := func(s string) float64 { return e.measureTextWidth(s, tk.fs, tk.style) }
+
+	if hyphenates(tk.style) && strings.Contains(tk.text, softHyphen) {
+		parts := strings.Split(tk.text, softHyphen)
+		for n := len(parts) - 1; n > 0; n-- {
+			text := strings.Join(parts[:n], "") + "-"
+			if w := measure(text); w <= avail {
+				head.text, head.width = text, w
+				tail.text = strings.Join(parts[n:], softHyphen)
+				tail.width = measure(stripSoftHyphens(tail.text))
+				return head, tail, true
+			}
+		}
+	}
+
+	if !breaksAll(tk.style) && !(alone && wrapsAnywhere(tk.style)) {
+		return tk, tk, false
+	}
+	runes := []rune(stripSoftHyphens(tk.text))
+	if len(runes) < 2 {
+		return tk, tk, false
+	}
+	n := len(runes) - 1
+	for n > 0 && measure(string(runes[:n])) > avail {
+		n--
+	}
+	if n == 0 {
+		if !alone {
+			return tk, tk, false
+		}
+		// Even one character overflows; it goes on the line anyway
+		n = 1
+	}
+	head.text, head.width = string(runes[:n]), measure(string(runes[:n]))
+	tail.text, tail.width = string(runes[n:]), measure(string(runes[n:]))
+	return head, tail, true
+}
+
+// minContentWidth returns the width of the widest piece a word can be broken
+// into, which the min-content width of its container has to fit
+func (e *Engine) minContentWidth(word string, fs float64, st style.ComputedStyle) float64 {
+	if breaksAll(st) || strings.EqualFold(strings.TrimSpace(st["overflow-wrap"].Value), "anywhere") {
+		widest := 0.0
+		for _, r := range stripSoftHyphens(word) {
+			widest = math.Max(widest, e.measureTextWidth(string(r), fs, st))
+		}
+		return widest
+	}
+	if !hyphenates(st) {
+		return e.measureTextWidth(stripSoftHyphens(word), fs, st)
+	}
+	parts := strings.Split(word, softHyphen)
+	widest := 0.0
+	for i, part := range parts {
+		if i < len(parts)-1 {
+			part += "-"
+		}
+		widest = math.Max(widest, e.measureTextWidth(part, fs, st))
+	}
+	return widest
+}
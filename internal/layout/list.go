@@ -0,0 +1,267 @@
+package layout
+
+import (
+	"math"
+	"strconv"
+	"strings"
+
+	"github.com/gompdf/gompdf/internal/parser/html"
+	"github.com/gompdf/gompdf/internal/style"
+	xhtml "golang.org/x/net/html"
+)
+
+// ListMarker is the marker of a list item: the bullet or number standing
+// by its first line, outside the box of the item or, for
+// list-style-position: inside, at the start of its first line.
+// Its position is relative to the top left corner of the item's box, so
+// that it moves with the box when pagination places it on a page.
+type ListMarker struct {
+	// Text is the number of the item as its list-style-type writes it,
+	// such as "3." or "iv.", or the string of a list-style-type given as
+	// one; "" for a bullet
+	Text string
+	// Bullet is the list-style-type of a marker drawn as a shape: disc,
+	// circle or square
+	Bullet string
+	// X is the left edge of the marker and Baseline the baseline of the
+	// first line of the item
+	X        float64
+	Baseline float64
+	Width    float64
+	FontSize float64
+	// Inside is set for markers placed on the first line of the item
+	Inside bool
+	// Style is the style of the item, which the marker is drawn in
+	Style style.ComputedStyle
+}
+
+// markerGap is the space between a marker and the content of its item, in
+// ems
+const markerGap = 0.5
+
+// BulletRadius returns the radius of the shape of a bullet marker
+func (m *ListMarker) BulletRadius() float64 {
+	return math.Max(m.FontSize*0.18, 1.2)
+}
+
+// isListItem reports whether an element is laid out as a list item with a
+// marker: one with display: list-item, or an <li> without a display
+func isListItem(node *html.Node, st style.ComputedStyle) bool {
+	display := strings.ToLower(strings.TrimSpace(st["display"].Value))
+	return display == "list-item" || display == "" && strings.EqualFold(node.Data, "li")
+}
+
+// listMarker returns the marker of a list item, sized but not yet placed;
+// see placeMarker. It is nil for list-style-type: none.
+func (e *Engine) listMarker(node *html.Node, st style.ComputedStyle) *ListMarker {
+	listStyle := strings.TrimSpace(st["list-style-type"].Value)
+	if listStyle == "" {
+		listStyle = "disc"
+	}
+	fs := parseLength(st["font-size"].Value, 0, 16)
+	m := &ListMarker{
+		FontSize: fs,
+		Inside:   strings.EqualFold(strings.TrimSpace(st["list-style-position"].Value), "inside"),
+		Style:    st,
+	}
+	switch lower := strings.ToLower(listStyle); {
+	case lower == "none":
+		return nil
+	case lower == "disc" || lower == "circle" || lower == "square":
+		m.Bullet = lower
+		m.Width = 2 * m.BulletRadius()
+		return m
+	case strings.HasPrefix(listStyle, `"`) || strings.HasPrefix(listStyle, "'"):
+		// A string is the marker as is
+		m.Text = strings.Trim(listStyle, `"'`)
+	default:
+		m.Text = style.FormatCounter(e.listItemValue(node), lower) + "."
+	}
+	if m.Text == "" {
+		return nil
+	}
+	m.Width = e.measureTextWidth(m.Text, fs, st)
+	return m
+}
+
+// startListItem gives the box of a list item its marker before its content
+// is laid out. An inside marker of an item whose content starts with a
+// block is on a line of its own above it, as in browsers.
+func (e *Engine) startListItem(node *html.Node, b *BlockBox) {
+	b.Marker = e.listMarker(node, b.Style)
+	m := b.Marker
+	if m == nil || !m.Inside || !e.startsWithBlock(node) {
+		return
+	}
+	lh := parseLineHeight(b.Style["line-height"].Value, m.FontSize, 1.2*m.FontSize)
+	top := b.PaddingTop + b.BorderTop
+	b.Children = append(b.Children, &InlineBox{
+		Style:  style.ComputedStyle{},
+		X:      b.X + b.PaddingLeft + b.BorderLeft,
+		Y:      b.Y + top,
+		Width:  m.Width,
+		Height: lh,
+	})
+	m.Baseline = top + (lh-m.FontSize)/2 + 0.8*m.FontSize
+}
+
+// startsWithBlock reports whether the first content of an element is a
+// block-level element
+func (e *Engine) startsWithBlock(node *html.Node) bool {
+	for c := node.FirstChild; c != nil; c = c.NextSibling {
+		switch c.Type {
+		case xhtml.TextNode:
+			if strings.TrimSpace(c.Data) != "" {
+				return false
+			}
+		case xhtml.ElementNode:
+			display := strings.TrimSpace(e.styles[c]["display"].Value)
+			switch display {
+			case "none":
+				continue
+			case "block", "flex", "grid", "list-item", "table":
+				return true
+			}
+			return display == "" && e.isBlockTag(c.Data)
+		}
+	}
+	return false
+}
+
+// listItemValue returns the ordinal value of a list item. Items count up
+// from the start attribute of their <ol>, 1 by default, or down when it is
+// reversed, from the number of items by default; an item with a value
+// attribute takes its value and the following ones count on from it.
+func (e *Engine) listItemValue(item *html.Node) int {
+	if v, ok := e.listValues[item]; ok {
+		return v
+	}
+	list := item.Parent
+	if list == nil {
+		return 1
+	}
+	var items []*html.Node
+	for c := list.FirstChild; c != nil; c = c.NextSibling {
+		if c.Type == xhtml.ElementNode && isListItem(c, e.styles[c]) {
+			items = append(items, c)
+		}
+	}
+	ordered := strings.EqualFold(list.Data, "ol")
+	step, n := 1, 1
+	if ordered && hasAttribute(list, "reversed") {
+		step, n = -1, len(items)
+	}
+	if ordered {
+		if start, err := strconv.Atoi(strings.TrimSpace(elementAttr(list, "start"))); err == nil {
+			n = start
+		}
+	}
+	if e.listValues == nil {
+		e.listValues = make(map[*html.Node]int)
+	}
+	for _, it := range items {
+		if v, err := strconv.Atoi(strings.TrimSpace(elementAttr(it, "value"))); err == nil {
+			n = v
+		}
+		e.listValues[it] = n
+		n += step
+	}
+	if v, ok := e.listValues[item]; ok {
+		return v
+	}
+	return 1
+}
+
+// hasAttribute reports whether an element has an attribute, which for
+// boolean attributes such as reversed is whether it is set
+func hasAttribute(n *html.Node, key string) bool {
+	for _, a := range n.Attr {
+		if strings.EqualFold(a.Key, key) {
+			return true
+		}
+	}
+	return false
+}
+
+// markerIndent returns how far the first line of a list item is indented
+// for its inside marker
+func markerIndent(b *BlockBox) float64 {
+	if m := b.Marker; m != nil && m.Inside {
+		return m.Width + markerGap*m.FontSize
+	}
+	return 0
+}
+
+// placeMarker places the marker of a laid out list item by its first line.
+// An outside marker ends a gap before the left edge of the item, or starts
+// one after its right edge in right-to-left text; an inside marker starts
+// the first line, which is indented for it.
+func placeMarker(b *BlockBox) {
+	m := b.Marker
+	if m == nil {
+		return
+	}
+	if indent := markerIndent(b); indent > 0 && len(b.Children) > 0 {
+		// Line boxes are indented as they are laid out; text laid out as
+		// a box of its own is moved aside
+		if ib, ok := b.Children[0].(*InlineBox); ok && ib.Node != nil && ib.Node.Type == xhtml.TextNode {
+			ib.X += indent
+			ib.Width = math.Max(ib.Width-indent, 0)
+		}
+	}
+	if m.Baseline == 0 {
+		if y, ok := firstBaseline(b.Children); ok {
+			m.Baseline = y - b.Y
+		} else {
+			m.Baseline = b.PaddingTop + b.BorderTop + m.FontSize
+		}
+	}
+	rtl := isRTL(b.Style)
+	gap := markerGap * m.FontSize
+	switch {
+	case m.Inside && rtl:
+		m.X = b.Width - b.PaddingRight - b.BorderRight - m.Width
+	case m.Inside:
+		m.X = b.PaddingLeft + b.BorderLeft
+	case rtl:
+		m.X = b.Width + gap
+	default:
+		m.X = -gap - m.Width
+	}
+}
+
+// firstBaseline returns the baseline of the first line of text or image
+// among boxes and their descendants, as the renderers draw it
+func firstBaseline(boxes []Box) (float64, bool) {
+	for _, child := range boxes {
+		switch c := child.(type) {
+		case *InlineBox:
+			if c.Text == "" {
+				if y, ok := firstBaseline(c.Children); ok {
+					return y, true
+				}
+				continue
+			}
+			fs := parseLength(c.Style["font-size"].Value, 0, 16)
+			if c.Node == nil {
+				// Text of line boxes has its baseline a font size below its top
+				return c.Y + fs, true
+			}
+			// Other text is centered in the box; see the renderers
+			content := math.Max(c.Height-c.PaddingTop-c.PaddingBottom-c.BorderTop-c.BorderBottom, 0)
+			ascent, descent := 0.8*fs, 0.2*fs
+			if ascent+descent > content {
+				scale := content / (ascent + descent)
+				ascent, descent = ascent*scale, descent*scale
+			}
+			return c.Y + c.BorderTop + c.PaddingTop + ascent + math.Max(content-ascent-descent, 0)/2, true
+		case *ImageBox:
+			return c.Y + c.Height, true
+		case *BlockBox:
+			if y, ok := firstBaseline(c.Children); ok {
+				return y, true
+			}
+		}
+	}
+	return 0, false
+}
@@ -0,0 +1,83 @@
+package layout
+
+import (
+	"math"
+	"strings"
+)
+
+// Adjoining vertical margins of blocks collapse into one: the bottom margin
+// of a block and the top margin of the block after it, and the top and
+// bottom margins of a block and those of its first and last children when no
+// border, padding or content lies between them. Collapsing through empty
+// blocks is not done, as they are given a height.
+
+// collapseMargins returns the margin that adjoining margins a and b
+// collapse into: the largest positive margin plus the most negative one
+func collapseMargins(a, b float64) float64 {
+	return math.Max(math.Max(a, 0), math.Max(b, 0)) + math.Min(math.Min(a, 0), math.Min(b, 0))
+}
+
+// adjoinsChildren reports whether the margins of a block adjoin those of
+// its children on the given side, "top" or "bottom": the block is laid out
+// in the normal flow of its parent, not a new formatting context, and no
+// border or padding separates the margins. The root and body boxes, which
+// fill the page area, do not take the margins of their children.
+func adjoinsChildren(b *BlockBox, side string) bool {
+	st := b.Style
+	if st == nil || establishesBFC(st) {
+		return false
+	}
+	switch strings.ToLower(strings.TrimSpace(st["display"].Value)) {
+	case "flex", "grid", "table":
+		return false
+	}
+	if side == "top" {
+		return b.PaddingTop == 0 && b.BorderTop == 0
+	}
+	if h := strings.TrimSpace(st["height"].Value); h != "" && !isAuto(h) {
+		return false
+	}
+	return b.PaddingBottom == 0 && b.BorderBottom == 0
+}
+
+// collapseTop collapses the top margin of child, the first block in its
+// parent, with the top margin of the parent when they adjoin: the child
+// goes to the top of the parent, which moves down by as much as the
+// collapsed margin outgrows its own. The child is placed below its margin
+// before, and has not been added to the parent.
+func (e *Engine) collapseTop(parent, child *BlockBox) {
+	if len(parent.Children) > 0 || !adjoinsChildren(parent, "top") {
+		return
+	}
+	// The margin of the parent may itself have collapsed into that of an
+	// ancestor, which then takes the margin of the child too
+	owner := parent
+	if o := e.marginOwners[parent]; o != nil {
+		owner = o
+	}
+	e.marginOwners[child] = owner
+	above := e.marginsAbove[owner]
+	margin := collapseMargins(owner.MarginTop, child.MarginTop)
+	dy := collapseMargins(above, margin) - collapseMargins(above, owner.MarginTop)
+	owner.MarginTop = margin
+	if dy != 0 {
+		owner.Y += dy
+		e.shiftDescendants(owner, 0, dy)
+	}
+	child.Y = parent.Y
+}
+
+// collapseBottom collapses the bottom margin of the last child of a block
+// with the block's own when they adjoin, and returns the part of it that
+// stays inside the block: none when it collapsed, all of it otherwise
+func (e *Engine) collapseBottom(b *BlockBox, last Box) float64 {
+	mb := last.GetMarginBottom()
+	if _, ok := last.(*BlockBox); !ok {
+		return 0
+	}
+	if !adjoinsChildren(b, "bottom") {
+		return mb
+	}
+	b.MarginBottom = collapseMargins(b.MarginBottom, mb)
+	return 0
+}
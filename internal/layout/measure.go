@@ -0,0 +1,178 @@
+package layout
+
+import (
+	"container/list"
+	"strings"
+	"sync"
+	"unicode/utf8"
+
+	"codeberg.org/go-pdf/fpdf"
+	"github.com/gompdf/gompdf/internal/fonts"
+	"github.com/gompdf/gompdf/internal/style"
+	"github.com/gompdf/gompdf/internal/text"
+)
+
+// maxCachedWidths bounds a WidthCache; the least recently used widths are
+// dropped beyond it
+const maxCachedWidths = 1 << 16
+
+// WidthCache remembers the widths of measured runs of text, keeping the
+// most recently used. Widths are kept by face, so measurers of conversions
+// with different fonts can share a cache. A WidthCache is safe for
+// concurrent use; a nil WidthCache caches nothing.
+type WidthCache struct {
+	mu     sync.Mutex
+	widths map[widthKey]*list.Element
+	// recent orders the entries from most to least recently used
+	recent *list.List
+}
+
+// widthEntry is an entry of a WidthCache
+type widthEntry struct {
+	key   widthKey
+	width float64
+}
+
+// widthKey identifies a run of text set in a face at a size. Core fonts
+// have no face and are told apart by family and style.
+type widthKey struct {
+	face   *fonts.Face
+	family string
+	style  string
+	size   float64
+	text   string
+}
+
+// NewWidthCache creates an empty width cache
+func NewWidthCache() *WidthCache {
+	return &WidthCache{widths: make(map[widthKey]*list.Element), recent: list.New()}
+}
+
+func (c *WidthCache) get(key widthKey) (float64, bool) {
+	if c == nil {
+		return 0, false
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	el, ok := c.widths[key]
+	if !ok {
+		return 0, false
+	}
+	c.recent.MoveToFront(el)
+	return el.Value.(*widthEntry).width, true
+}
+
+func (c *WidthCache) put(key widthKey, width float64) {
+	if c == nil {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if el, ok := c.widths[key]; ok {
+		el.Value.(*widthEntry).width = width
+		c.recent.MoveToFront(el)
+		return
+	}
+	c.widths[key] = c.recent.PushFront(&widthEntry{key: key, width: width})
+	if c.recent.Len() > maxCachedWidths {
+		oldest := c.recent.Back()
+		c.recent.Remove(oldest)
+		delete(c.widths, oldest.Value.(*widthEntry).key)
+	}
+}
+
+// Measurer measures text with the faces of a font registry so that layout
+// uses the same metrics as the renderer. Registered faces are measured by
+// shaping them; fpdf measures the core fonts only. Every measurer has a
+// shaper and an fpdf document of its own, so conversions measuring at the
+// same time do not wait for each other. A Measurer is safe for concurrent
+// use.
+type Measurer struct {
+	fonts  *fonts.Registry
+	widths *WidthCache
+	shaper *text.TextShaper
+
+	// pdf measures the core fonts; it is created as first needed
+	mu  sync.Mutex
+	pdf *fpdf.Fpdf
+}
+
+// NewMeasurer creates a measurer for the faces of reg, which may be nil for
+// the core fonts only, keeping the widths it measures in widths, which may
+// be nil
+func NewMeasurer(reg *fonts.Registry, widths *WidthCache) *Measurer {
+	return &Measurer{fonts: reg, widths: widths, shaper: text.NewTextShaper()}
+}
+
+// textWidth returns the width of text set in the font of a computed style
+func (m *Measurer) textWidth(text string, fontSize float64, st style.ComputedStyle) float64 {
+	if text == "" || fontSize <= 0 {
+		return 0
+	}
+	// Page numbers are filled in after pagination; two digits stand in
+	// for them
+	if style.HasPageCounters(text) {
+		text = style.ResolvePageCounters(text, func(string, string) string { return "00" })
+	}
+	fam, sty := m.resolveFont(st)
+	width := 0.0
+	if st.VerticalWritingMode() {
+		// Upright characters of vertical text advance by an em
+		for _, seg := range st.VerticalSegments(text) {
+			if seg.Upright {
+				width += fontSize
+				continue
+			}
+			for _, run := range m.fonts.Runs(seg.Text, fam, sty) {
+				width += m.runWidth(run, fontSize)
+			}
+		}
+	} else {
+		for _, run := range m.fonts.Runs(text, fam, sty) {
+			width += m.runWidth(run, fontSize)
+		}
+	}
+	// letter-spacing follows every character, word-spacing every space
+	width += st.LetterSpacing()*float64(utf8.RuneCountInString(text)) + st.WordSpacing()*float64(strings.Count(text, " "))
+	return width
+}
+
+// runWidth returns the width of a run of text set in one font
+func (m *Measurer) runWidth(run fonts.Run, fontSize float64) float64 {
+	face := m.fonts.Face(run.Family, run.Style)
+	key := widthKey{face: face, size: fontSize, text: run.Text}
+	if face == nil {
+		key.family, key.style = run.Family, run.Style
+	}
+	if w, ok := m.widths.get(key); ok {
+		return w
+	}
+	var width float64
+	if face != nil {
+		// Registered faces are shaped, which accounts for kerning,
+		// ligatures and joining forms
+		f := &text.Font{Size: fontSize, Data: face.Data}
+		width = m.shaper.Shape(run.Text, f, text.DirectionOf(run.Text)).Width
+	} else {
+		width = m.coreWidth(run, fontSize)
+	}
+	m.widths.put(key, width)
+	return width
+}
+
+// coreWidth returns the width of a run of text set in a core font
+func (m *Measurer) coreWidth(run fonts.Run, fontSize float64) float64 {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.pdf == nil {
+		m.pdf = fpdf.New("P", "pt", "", "")
+	}
+	m.pdf.SetFont(run.Family, run.Style, fontSize)
+	return m.pdf.GetStringWidth(m.fonts.Encode(run.Family, run.Text))
+}
+
+// resolveFont maps CSS-like style to a registered or core PDF font family
+// and style
+func (m *Measurer) resolveFont(st style.ComputedStyle) (string, string) {
+	return m.fonts.Resolve(st["font-family"].Value, st["font-weight"].Value, st["font-style"].Value)
+}
@@ -0,0 +1,84 @@
+package layout
+
+import (
+	"math"
+	"strings"
+)
+
+// ObjectRect returns where the image of the box is drawn, as object-fit
+// sizes it and object-position places it within the box: fill, the default,
+// stretches it to the box, contain fits it inside keeping its aspect ratio,
+// cover fills the box keeping its aspect ratio, none keeps its intrinsic
+// size and scale-down is the smaller of none and contain. The parts of the
+// image outside the box are clipped when it is drawn.
+func (b *ImageBox) ObjectRect() (x, y, w, h float64) {
+	w, h = b.Width, b.Height
+	iw, ih := b.IntrinsicWidth, b.IntrinsicHeight
+	if iw <= 0 || ih <= 0 || w <= 0 || h <= 0 {
+		return b.X, b.Y, w, h
+	}
+	switch strings.ToLower(strings.TrimSpace(b.Style["object-fit"].Value)) {
+	case "contain":
+		s := math.Min(w/iw, h/ih)
+		w, h = iw*s, ih*s
+	case "cover":
+		s := math.Max(w/iw, h/ih)
+		w, h = iw*s, ih*s
+	case "none":
+		w, h = iw, ih
+	case "scale-down":
+		s := math.Min(1, math.Min(w/iw, h/ih))
+		w, h = iw*s, ih*s
+	default:
+		return b.X, b.Y, w, h
+	}
+	px, py := objectPosition(b.Style["object-position"].Value, b.Width-w, b.Height-h)
+	return b.X + px, b.Y + py, w, h
+}
+
+// ObjectOverflows reports whether the image of the box, as ObjectRect
+// places it, extends beyond the box
+func (b *ImageBox) ObjectOverflows() bool {
+	x, y, w, h := b.ObjectRect()
+	const epsilon = 0.01
+	return x < b.X-epsilon || y < b.Y-epsilon ||
+		x+w > b.X+b.Width+epsilon || y+h > b.Y+b.Height+epsilon
+}
+
+// objectPosition resolves object-position to the offset of the image within
+// the box. freeX and freeY are the box size minus the image size, which
+// percentages and keywords are relative to; the image is centered when no
+// position is given.
+func objectPosition(value string, freeX, freeY float64) (float64, float64) {
+	parts := strings.Fields(strings.ToLower(strings.TrimSpace(value)))
+	switch len(parts) {
+	case 0:
+		return freeX / 2, freeY / 2
+	case 1:
+		// A single vertical keyword positions vertically and centers
+		// horizontally
+		if parts[0] == "top" || parts[0] == "bottom" {
+			parts = []string{"center", parts[0]}
+		} else {
+			parts = append(parts, "center")
+		}
+	}
+	// Keywords may be given in either order ("top left")
+	if parts[0] == "top" || parts[0] == "bottom" || parts[1] == "left" || parts[1] == "right" {
+		parts[0], parts[1] = parts[1], parts[0]
+	}
+	return positionOffset(parts[0], freeX), positionOffset(parts[1], freeY)
+}
+
+// positionOffset resolves one component of object-position
+func positionOffset(v string, free float64) float64 {
+	switch v {
+	case "left", "top":
+		return 0
+	case "center":
+		return free / 2
+	case "right", "bottom":
+		return free
+	}
+	return parseLength(v, free, 0)
+}
@@ -0,0 +1,20 @@
+package layout
+
+import "strings"
+
+// layoutRule sizes the box of an <hr>, which has no content: it is as tall
+// as its height, 0 by default, and its padding and borders, so that a rule
+// is drawn by its borders or its background. A declared width, or the
+// legacy width attribute, narrows it; it is then centered between auto
+// margins, or put against the right one when only the left is auto.
+func (e *Engine) layoutRule(b *BlockBox, available float64) {
+	st := b.Style
+	e.sizeBlock(b, available)
+	height := parseLength(st["height"].Value, 0, 0)
+	b.Height = b.BorderTop + b.PaddingTop + height + b.PaddingBottom + b.BorderBottom
+}
+
+// isAuto reports whether a property value is auto
+func isAuto(v string) bool {
+	return strings.EqualFold(strings.TrimSpace(v), "auto")
+}
@@ -0,0 +1,57 @@
+package layout
+
+import (
+	"strings"
+
+	"github.com/gompdf/gompdf/internal/parser/html"
+	"github.com/gompdf/gompdf/internal/style"
+)
+
+// RunningElement is an element taken out of the flow by position:
+// running(name), to be shown in page margin boxes by element(name)
+type RunningElement struct {
+	Name string
+	// Box is the element laid out with a shrink-to-fit width at the top of
+	// the content area of a page, ready to be moved into a margin box
+	Box Box
+	// Anchor is an empty box left in the flow where the element was, which
+	// tells the page the element belongs to
+	Anchor *BlockBox
+}
+
+// runningName returns the name of position: running(name), "" for other
+// positions
+func runningName(st style.ComputedStyle) string {
+	v := strings.TrimSpace(st["position"].Value)
+	if len(v) < len("running()") || !strings.EqualFold(v[:len("running(")], "running(") || !strings.HasSuffix(v, ")") {
+		return ""
+	}
+	return strings.TrimSpace(v[len("running(") : len(v)-1])
+}
+
+// layoutRunning lays out a running element on its own, as wide as its
+// content within a page's content area, and leaves an anchor in its place
+// in parentBox
+func (e *Engine) layoutRunning(node *html.Node, parentBox *BlockBox, st style.ComputedStyle, name string, depth int) {
+	o := e.options
+	area := &BlockBox{X: o.MarginLeft, Width: e.Width - o.MarginLeft - o.MarginRight}
+	box := e.buildShrinkToFit(node, area, st, area.Width, depth)
+
+	y := parentBox.Y + parentBox.PaddingTop + parentBox.BorderTop
+	if last := e.lastInFlow(parentBox); last != nil {
+		y = last.GetY() + last.GetHeight() + last.GetMarginBottom()
+	}
+	anchor := &BlockBox{
+		X:        parentBox.X + parentBox.PaddingLeft + parentBox.BorderLeft,
+		Y:        y,
+		Children: []Box{},
+	}
+	parentBox.Children = append(parentBox.Children, anchor)
+	e.running = append(e.running, &RunningElement{Name: name, Box: box, Anchor: anchor})
+}
+
+// RunningElements returns the running elements of the last layout in
+// document order
+func (e *Engine) RunningElements() []*RunningElement {
+	return e.running
+}
@@ -0,0 +1,165 @@
+package layout
+
+import (
+	"strings"
+
+	"github.com/gompdf/gompdf/internal/parser/html"
+	"github.com/gompdf/gompdf/internal/style"
+	xhtml "golang.org/x/net/html"
+)
+
+// imageChoice is the image a replaced element shows, chosen among the
+// candidates of its srcset and of the <source> elements of its <picture>
+type imageChoice struct {
+	src string
+	// density is the pixel density of the chosen candidate, which divides
+	// the pixel dimensions of the image into its intrinsic size; 0 when the
+	// image has no candidate and its own resolution applies
+	density float64
+	// slot is the width the sizes attribute gives the image, when
+	// the candidate was chosen by its width
+	slot float64
+}
+
+// ImageSource returns the source of the image of an <img> element, chosen
+// among the candidates of its srcset and of the <source> elements of its
+// <picture> for the page and DPI of options, as layout loads it
+func ImageSource(node *html.Node, options Options) string {
+	return selectImage(node, options).src
+}
+
+// selectImage chooses the image of a replaced element. An inline <svg> is
+// its own image. For an <img> the first <source> of its <picture> with a
+// matching media and a supported type gives the candidates, else its own
+// srcset and src. The candidate with the lowest density at least that of
+// the output, the DPI over 96, is chosen, or else the densest; candidates
+// with a width take their density from the slot size of sizes.
+func selectImage(node *html.Node, options Options) imageChoice {
+	if strings.EqualFold(node.Data, "svg") {
+		return imageChoice{src: svgSource(node)}
+	}
+	src := strings.TrimSpace(elementAttr(node, "src"))
+	srcset, sizes := elementAttr(node, "srcset"), elementAttr(node, "sizes")
+	fromSource := false
+	if node.Parent != nil && strings.EqualFold(node.Parent.Data, "picture") {
+		for s := node.Parent.FirstChild; s != nil && s != node; s = s.NextSibling {
+			if s.Type != xhtml.ElementNode || !strings.EqualFold(s.Data, "source") {
+				continue
+			}
+			if set := elementAttr(s, "srcset"); set != "" && supportedImageType(elementAttr(s, "type")) &&
+				style.MediaMatches(elementAttr(s, "media"), mediaType(options), options.Width, options.Height) {
+				srcset, sizes, fromSource = set, elementAttr(s, "sizes"), true
+				break
+			}
+		}
+	}
+
+	candidates := html.ParseSrcset(srcset)
+	if !fromSource && src != "" && !hasCandidateDensity(candidates, 1) {
+		// The src of an <img> is its 1x candidate
+		candidates = append(candidates, html.ImageCandidate{URL: src, Density: 1})
+	}
+	if len(candidates) == 0 || len(candidates) == 1 && candidates[0].URL == src && !fromSource {
+		return imageChoice{src: src}
+	}
+
+	target := 1.0
+	if options.DPI > 0 {
+		target = options.DPI / 96
+	}
+	slot := sizesSlot(sizes, options)
+	var best imageChoice
+	bestDensity := 0.0
+	for _, c := range candidates {
+		d := c.Density
+		if c.Width > 0 {
+			d = float64(c.Width) / slot
+		}
+		better := bestDensity == 0 ||
+			d >= target && (bestDensity < target || d < bestDensity) ||
+			d < target && bestDensity < target && d > bestDensity
+		if better {
+			best, bestDensity = imageChoice{src: c.URL, density: c.Density}, d
+			if c.Width > 0 {
+				best.slot = slot
+			}
+		}
+	}
+	return best
+}
+
+// hasCandidateDensity reports whether a candidate is for the pixel density
+// d or has a width, in which case the src of the <img> is not a candidate
+func hasCandidateDensity(candidates []html.ImageCandidate, d float64) bool {
+	for _, c := range candidates {
+		if c.Width > 0 || c.Density == d {
+			return true
+		}
+	}
+	return false
+}
+
+// sizesSlot returns the width of the slot of the sizes attribute of an
+// image: the length of its first entry whose media condition matches the
+// page, 100vw when there is none
+func sizesSlot(sizes string, options Options) float64 {
+	for _, entry := range strings.Split(sizes, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		condition, length := "", entry
+		if i := strings.LastIndexAny(entry, " \t\n)"); i >= 0 {
+			condition, length = strings.TrimSpace(entry[:i+1]), strings.TrimSpace(entry[i+1:])
+		}
+		if condition != "" && !style.MediaMatches(condition, mediaType(options), options.Width, options.Height) {
+			continue
+		}
+		if w, ok := viewportLength(length, options.Width); ok && w > 0 {
+			return w
+		}
+	}
+	return options.Width
+}
+
+// viewportLength parses a length of sizes, where vw is a percentage of the
+// page width
+func viewportLength(v string, pageWidth float64) (float64, bool) {
+	if n, unit, ok := style.SplitUnit(v); ok && unit == "vw" {
+		return pageWidth * n / 100, true
+	}
+	w := parseLength(v, pageWidth, -1)
+	return w, w >= 0
+}
+
+// mediaType returns the media type media conditions are evaluated for
+func mediaType(options Options) string {
+	if options.MediaType == "" {
+		return style.DefaultMediaType
+	}
+	return strings.ToLower(options.MediaType)
+}
+
+// supportedImageType reports whether images of a MIME type, as given by
+// the type attribute of a <source>, can be decoded; any type can when it is
+// empty
+func supportedImageType(mimeType string) bool {
+	mimeType, _, _ = strings.Cut(mimeType, ";")
+	switch strings.ToLower(strings.TrimSpace(mimeType)) {
+	case "", "image/png", "image/jpeg", "image/jpg", "image/gif", "image/svg+xml",
+		"image/webp", "image/bmp", "image/tiff":
+		return true
+	}
+	return false
+}
+
+// elementAttr returns the value of an attribute of an element, "" when it
+// has none
+func elementAttr(n *html.Node, key string) string {
+	for _, a := range n.Attr {
+		if strings.EqualFold(a.Key, key) {
+			return a.Val
+		}
+	}
+	return ""
+}
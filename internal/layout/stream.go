@@ -0,0 +1,212 @@
+package layout
+
+import (
+	"slices"
+	"strings"
+
+	"github.com/gompdf/gompdf/internal/parser/html"
+	"github.com/gompdf/gompdf/internal/style"
+)
+
+// Sink receives the boxes of a layout as layout finishes with them, so
+// that they can be paginated and let go of before the rest of the document
+// is laid out. Without a sink the whole layout tree is returned at once.
+//
+// Blocks of the normal flow that hold other blocks, and tables, hand their
+// children to the sink one at a time and keep a stand-in for the last one,
+// which later content is placed after. The boxes reach the sink in document
+// order and in flow coordinates, like those of a whole layout tree.
+type Sink interface {
+	// Open announces a block whose content follows in pieces. Its geometry
+	// is final except for its height and bottom margin, which Close tells.
+	Open(b *BlockBox)
+	// Add hands over a box laid out in full with its descendants; layout
+	// no longer refers to any of them
+	Add(b Box)
+	// Close tells that a block announced by Open is laid out in full
+	Close(b *BlockBox)
+	// Err reports a failure of the sink, which stops layout
+	Err() error
+}
+
+// SetSink sets the sink that receives the boxes of the next layout as they
+// are laid out, or nil to return the whole layout tree
+func (e *Engine) SetSink(s Sink) {
+	e.sink = s
+}
+
+// stopped reports whether layout is to be abandoned: its context is done
+// or its sink failed
+func (e *Engine) stopped() bool {
+	return e.ctx != nil && e.ctx.Err() != nil || e.sink != nil && e.sink.Err() != nil
+}
+
+// streams reports whether the children of b go to the sink as they are
+// laid out
+func (e *Engine) streams(b *BlockBox) bool {
+	return e.sink != nil && len(e.open) > 0 && e.open[len(e.open)-1] == b
+}
+
+// openBlock starts handing the children of the block b in parent to the
+// sink, if parent does and b may
+func (e *Engine) openBlock(b, parent *BlockBox, node *html.Node, st style.ComputedStyle) {
+	if !e.streams(parent) || !e.streamable(node, st) {
+		return
+	}
+	e.open = append(e.open, b)
+}
+
+// streamable reports whether the content of a block may reach the sink in
+// pieces: a table, or a block holding other blocks whose height depends on
+// its content and that neither clips it nor is kept whole on a page
+func (e *Engine) streamable(node *html.Node, st style.ComputedStyle) bool {
+	if st.ClipsOverflow() || keptTogether(node, st) {
+		return false
+	}
+	if strings.EqualFold(node.Data, "table") {
+		return true
+	}
+	if isListItem(node, st) || st.VerticalWritingMode() {
+		return false
+	}
+	for _, name := range []string{"height", "max-height"} {
+		if v := strings.TrimSpace(st[name].Value); v != "" && !isAuto(v) && v != "none" {
+			return false
+		}
+	}
+	switch strings.ToLower(strings.TrimSpace(st["display"].Value)) {
+	case "", "block":
+		// The root element holds the body, which is not a block tag
+		return strings.EqualFold(node.Data, "html") || e.hasBlockChildren(node)
+	}
+	return false
+}
+
+// keptTogether reports whether pagination keeps a block whole on a page:
+// its break-inside value avoids breaks or it has a data-keep-together
+// attribute other than "false"
+func keptTogether(node *html.Node, st style.ComputedStyle) bool {
+	for _, name := range []string{"break-inside", "page-break-inside"} {
+		if v, ok := st[name]; ok {
+			if v := strings.ToLower(strings.TrimSpace(v.Value)); v == "avoid" || v == "avoid-page" {
+				return true
+			}
+			break
+		}
+	}
+	for _, a := range node.Attr {
+		if strings.EqualFold(a.Key, "data-keep-together") {
+			return !strings.EqualFold(strings.TrimSpace(a.Val), "false")
+		}
+	}
+	return false
+}
+
+// closeBlock ends the streaming of the block b laid out in full. A block
+// announced to the sink hands it the rest of its children and is closed;
+// the parent of one that was not hands it over whole.
+func (e *Engine) closeBlock(b *BlockBox) {
+	if !e.streams(b) {
+		return
+	}
+	if e.announced[b] {
+		e.flush(b)
+		e.sink.Close(b)
+	}
+	e.open = e.open[:len(e.open)-1]
+}
+
+// flush hands the children of b laid out so far to the sink, announcing
+// the blocks they are in first, and leaves b a stand-in for the last one
+func (e *Engine) flush(b *BlockBox) {
+	if !e.streams(b) || e.sink.Err() != nil {
+		return
+	}
+	if !slices.ContainsFunc(b.Children, func(ch Box) bool { return !e.standIns[ch] }) {
+		return
+	}
+	// The root box is the page area, not part of the flow
+	for _, o := range e.open[1:] {
+		if !e.announced[o] {
+			e.announced[o] = true
+			e.sink.Open(o)
+		}
+	}
+	// The last box in the flow places the content after it. Floats before
+	// it do not; when there are only floats, the next block goes to the top
+	// but does not take the margin of b. The stand-in is taken before the
+	// sink moves the boxes to its own coordinates.
+	var last, lastFloat Box
+	for _, ch := range b.Children {
+		if e.isFloated(ch) {
+			lastFloat = ch
+		} else {
+			last = ch
+		}
+	}
+	keep := last
+	if keep == nil {
+		keep = lastFloat
+	}
+	standIn := standInFor(keep)
+	for _, ch := range b.Children {
+		if e.standIns[ch] {
+			delete(e.standIns, ch)
+			delete(e.floated, ch)
+			continue
+		}
+		if bb, ok := ch.(*BlockBox); ok && e.announced[bb] {
+			delete(e.announced, bb)
+		} else {
+			applyClips(ch, nil)
+			e.sink.Add(ch)
+		}
+		e.forget(ch)
+	}
+	e.standIns[standIn] = true
+	if last == nil {
+		e.floated[standIn] = true
+	}
+	b.Children = []Box{standIn}
+	// Floats above the content to come no longer narrow lines or need
+	// clearing
+	top := standIn.GetY()
+	e.floats = slices.DeleteFunc(e.floats, func(f floatArea) bool { return f.bottom < top })
+}
+
+// forget drops what layout keeps about a box handed to the sink and its
+// descendants
+func (e *Engine) forget(b Box) {
+	delete(e.floated, b)
+	var children []Box
+	switch c := b.(type) {
+	case *BlockBox:
+		delete(e.marginOwners, c)
+		delete(e.marginsAbove, c)
+		children = c.Children
+	case *InlineBox:
+		children = c.Children
+	}
+	for _, ch := range children {
+		e.forget(ch)
+	}
+}
+
+// standInFor returns a copy of a box without its children, which layout
+// places the content after the box by once the box has been handed over
+func standInFor(b Box) Box {
+	switch c := b.(type) {
+	case *BlockBox:
+		s := *c
+		s.Children = nil
+		return &s
+	case *InlineBox:
+		s := *c
+		s.Children = nil
+		return &s
+	case *ImageBox:
+		s := *c
+		return &s
+	}
+	return b
+}
@@ -0,0 +1,94 @@
+package layout
+
+import (
+	"encoding/base64"
+	"encoding/xml"
+	"strings"
+
+	"github.com/gompdf/gompdf/internal/parser/html"
+	xhtml "golang.org/x/net/html"
+)
+
+// Namespaces an inline <svg> element is serialized with when it does not
+// declare them, as an SVG document on its own needs them
+const (
+	svgNamespace   = "http://www.w3.org/2000/svg"
+	xlinkNamespace = "http://www.w3.org/1999/xlink"
+)
+
+// isReplaced reports whether an element is laid out as an image: <img> and
+// inline <svg>
+func isReplaced(tag string) bool {
+	return tag == "img" || tag == "svg"
+}
+
+// svgSource returns a data URL holding an inline <svg> element as a
+// document of its own, so that it loads through the loader as images do
+func svgSource(node *html.Node) string {
+	return "data:image/svg+xml;base64," + base64.StdEncoding.EncodeToString([]byte(svgDocument(node)))
+}
+
+// svgDocument serializes an inline <svg> element as XML, declaring the SVG
+// and XLink namespaces it uses on the root
+func svgDocument(node *html.Node) string {
+	var b strings.Builder
+	var write func(n *html.Node, root bool)
+	write = func(n *html.Node, root bool) {
+		switch n.Type {
+		case xhtml.TextNode:
+			xml.EscapeText(&b, []byte(n.Data))
+			return
+		case xhtml.ElementNode:
+		default:
+			return
+		}
+		b.WriteString("<" + n.Data)
+		hasXMLNS, hasXLink := false, false
+		for _, a := range n.Attr {
+			key := a.Key
+			if a.Namespace != "" {
+				key = a.Namespace + ":" + a.Key
+			}
+			hasXMLNS = hasXMLNS || key == "xmlns"
+			hasXLink = hasXLink || key == "xmlns:xlink"
+			b.WriteString(" " + key + `="`)
+			xml.EscapeText(&b, []byte(a.Val))
+			b.WriteString(`"`)
+		}
+		if root {
+			if !hasXMLNS {
+				b.WriteString(` xmlns="` + svgNamespace + `"`)
+			}
+			if !hasXLink && usesXLink(n) {
+				b.WriteString(` xmlns:xlink="` + xlinkNamespace + `"`)
+			}
+		}
+		if n.FirstChild == nil {
+			b.WriteString("/>")
+			return
+		}
+		b.WriteString(">")
+		for c := n.FirstChild; c != nil; c = c.NextSibling {
+			write(c, false)
+		}
+		b.WriteString("</" + n.Data + ">")
+	}
+	write(node, true)
+	return b.String()
+}
+
+// usesXLink reports whether n or an element below it has an XLink
+// attribute, such as the xlink:href of <use>
+func usesXLink(n *html.Node) bool {
+	for _, a := range n.Attr {
+		if a.Namespace == "xlink" {
+			return true
+		}
+	}
+	for c := n.FirstChild; c != nil; c = c.NextSibling {
+		if usesXLink(c) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,955 @@
+package layout
+
+import (
+	"math"
+	"strconv"
+	"strings"
+
+	"github.com/gompdf/gompdf/internal/parser/html"
+	"github.com/gompdf/gompdf/internal/style"
+	xhtml "golang.org/x/net/html"
+)
+
+// tableCell is a <td>/<th> placed on the table grid
+type tableCell struct {
+	node    *html.Node
+	style   style.ComputedStyle
+	row     int
+	col     int
+	rowSpan int
+	colSpan int
+	box     *BlockBox
+	// contentHeight is the height required by the laid out content,
+	// including the cell's own padding
+	contentHeight float64
+	// minHeight is the declared height of the cell, which its row grows to
+	minHeight float64
+	// baseline is the distance from the top of the cell to the baseline of
+	// its first line, or to the bottom of its content without one
+	baseline float64
+}
+
+// tableRow is a <tr> together with the cells that start in it
+type tableRow struct {
+	index int
+	node  *html.Node
+	style style.ComputedStyle
+	cells []*tableCell
+	box   *BlockBox
+	// baseline is the distance from the top of the row to the baseline its
+	// cells aligned by their baselines share
+	baseline float64
+}
+
+// tableSection groups rows belonging to a <thead>, <tbody> or <tfoot>.
+// Rows placed directly under <table> get an implicit section with a nil node.
+type tableSection struct {
+	node  *html.Node
+	style style.ComputedStyle
+	rows  []*tableRow
+	box   *BlockBox
+}
+
+// tableColumn holds a width declared through <col>/<colgroup>
+type tableColumn struct {
+	width    string
+	hasWidth bool
+}
+
+// tableGrid is the structural model of a table used by the layout algorithm
+type tableGrid struct {
+	caption  *html.Node
+	columns  []tableColumn
+	sections []*tableSection
+	rows     []*tableRow
+	numCols  int
+}
+
+// layoutTable lays out a <table> element into box. It builds the cell grid
+// (honoring rowspan/colspan), resolves column widths using either the fixed or
+// the auto table layout algorithm, lays out every cell at its final width and
+// finally distributes row heights and applies vertical alignment in cells.
+// A table in the normal flow is aligned within the width available to it
+// once its own width is known; available is 0 for tables sized otherwise.
+//
+// When the content of box goes to the sink of the layout (see Sink), the
+// rows of the table bodies are laid out and handed over in runs, as few at
+// a time as their row spans allow.
+func (e *Engine) layoutTable(node *html.Node, box *BlockBox, tableStyle style.ComputedStyle, available float64) {
+	grid := e.buildTableGrid(node)
+
+	collapse := strings.EqualFold(strings.TrimSpace(tableStyle["border-collapse"].Value), "collapse")
+	spacingX, spacingY := 0.0, 0.0
+	if !collapse {
+		spacingX, spacingY = parseBorderSpacing(tableStyle["border-spacing"].Value, box.Width)
+	}
+
+	inner := box.Width
+	declared := strings.TrimSpace(tableStyle["width"].Value)
+	autoWidth := declared == "" || strings.EqualFold(declared, "auto")
+	if !autoWidth {
+		if w := parseLength(declared, inner, 0); w > 0 {
+			box.Width = w
+		} else {
+			autoWidth = true
+		}
+	}
+
+	insetX := box.PaddingLeft + box.PaddingRight + box.BorderLeft + box.BorderRight
+	spacingTotal := spacingX * float64(grid.numCols+1)
+	if collapse {
+		spacingTotal = 0
+	}
+
+	var colWidths []float64
+	fixed := strings.EqualFold(strings.TrimSpace(tableStyle["table-layout"].Value), "fixed")
+	if fixed && !autoWidth {
+		colWidths = e.fixedColumnWidths(grid, box.Width-insetX-spacingTotal)
+	} else {
+		colWidths = e.autoColumnWidths(grid, inner-insetX-spacingTotal, box.Width-insetX-spacingTotal, autoWidth, spacingX)
+	}
+
+	contentWidth := spacingTotal
+	for _, w := range colWidths {
+		contentWidth += w
+	}
+	if autoWidth || contentWidth+insetX > box.Width {
+		box.Width = contentWidth + insetX
+	}
+	e.alignBlock(box, available)
+
+	if e.Debug {
+		e.debugf("Table layout: fixed=%v collapse=%v columns=%d widths=%v\n", fixed, collapse, grid.numCols, colWidths)
+	}
+
+	contentX := box.X + box.PaddingLeft + box.BorderLeft
+	curY := box.Y + box.PaddingTop + box.BorderTop
+
+	captionSide := strings.ToLower(strings.TrimSpace(tableStyle["caption-side"].Value))
+	var captionBox *BlockBox
+	if grid.caption != nil {
+		captionBox = e.newTableBlock(grid.caption, e.mergeStyles(tableStyle, e.styles[grid.caption]), contentX, curY, contentWidth)
+		e.layoutTableContent(grid.caption, captionBox)
+		// A caption at the bottom goes after the rows
+		if captionSide != "bottom" {
+			box.Children = append(box.Children, captionBox)
+			curY += captionBox.Height
+		}
+	}
+
+	// Column origins
+	colX := make([]float64, grid.numCols+1)
+	x := contentX + spacingX
+	if collapse {
+		x = contentX
+	}
+	for i := 0; i < grid.numCols; i++ {
+		colX[i] = x
+		x += colWidths[i] + spacingX
+	}
+	colX[grid.numCols] = x
+
+	if spacingY > 0 {
+		curY += spacingY
+	}
+	streamed := e.streams(box)
+	for _, section := range grid.sections {
+		if len(section.rows) == 0 {
+			continue
+		}
+		e.flush(box)
+		section.box = e.newTableBlock(section.node, section.style, contentX, curY, contentWidth)
+		box.Children = append(box.Children, section.box)
+		runs := [][]*tableRow{section.rows}
+		// Header and footer rows are handed over with their section, which
+		// pagination repeats and keeps with the rows after it
+		stream := streamed && !isTableHeadOrFoot(section.node)
+		if stream {
+			e.open = append(e.open, section.box)
+			runs = rowRuns(section.rows)
+		}
+		for _, run := range runs {
+			curY = e.layoutTableRows(run, section.box, colX, spacingX, spacingY, curY)
+			if stream {
+				e.flush(section.box)
+				for _, row := range run {
+					row.cells, row.box = nil, nil
+				}
+			}
+		}
+		section.box.Height = curY - spacingY - section.box.Y
+		if stream {
+			e.closeBlock(section.box)
+		}
+	}
+
+	if captionBox != nil && captionSide == "bottom" {
+		oldY := captionBox.Y
+		captionBox.Y = curY
+		e.shiftDescendants(captionBox, 0, captionBox.Y-oldY)
+		box.Children = append(box.Children, captionBox)
+		curY += captionBox.Height
+	}
+
+	box.Height = curY - box.Y + box.PaddingBottom + box.BorderBottom
+	if h := parseLength(tableStyle["height"].Value, 0, 0); h > box.Height {
+		box.Height = h
+	}
+}
+
+// buildTableGrid collects the caption, column declarations and rows of a
+// table and assigns every cell its grid slot. Header rows are moved to the top
+// and footer rows to the bottom, mirroring how browsers render them.
+func (e *Engine) buildTableGrid(table *html.Node) *tableGrid {
+	grid := &tableGrid{}
+
+	var head, foot *tableSection
+	var bodies []*tableSection
+	var implicit *tableSection
+
+	newSection := func(n *html.Node) *tableSection {
+		s := &tableSection{node: n, style: e.styles[table]}
+		if n != nil {
+			s.style = e.mergeStyles(e.styles[table], e.styles[n])
+		}
+		return s
+	}
+	addRow := func(s *tableSection, tr *html.Node) {
+		parent := table
+		if s.node != nil {
+			parent = s.node
+		}
+		s.rows = append(s.rows, &tableRow{node: tr, style: e.mergeStyles(e.styles[parent], e.styles[tr])})
+	}
+
+	for c := table.FirstChild; c != nil; c = c.NextSibling {
+		if c.Type != xhtml.ElementNode {
+			continue
+		}
+		switch strings.ToLower(c.Data) {
+		case "caption":
+			if grid.caption == nil {
+				grid.caption = c
+			}
+		case "colgroup":
+			grid.columns = append(grid.columns, e.columnsFromColgroup(c)...)
+		case "col":
+			grid.columns = append(grid.columns, e.columnsFromCol(c, tableColumn{})...)
+		case "thead", "tbody", "tfoot":
+			s := newSection(c)
+			for tr := c.FirstChild; tr != nil; tr = tr.NextSibling {
+				if tr.Type == xhtml.ElementNode && strings.EqualFold(tr.Data, "tr") {
+					addRow(s, tr)
+				}
+			}
+			switch {
+			case strings.EqualFold(c.Data, "thead") && head == nil:
+				head = s
+			case strings.EqualFold(c.Data, "tfoot") && foot == nil:
+				foot = s
+			default:
+				bodies = append(bodies, s)
+			}
+			implicit = nil
+		case "tr":
+			if implicit == nil {
+				implicit = newSection(nil)
+				bodies = append(bodies, implicit)
+			}
+			addRow(implicit, c)
+		}
+	}
+
+	if head != nil {
+		grid.sections = append(grid.sections, head)
+	}
+	grid.sections = append(grid.sections, bodies...)
+	if foot != nil {
+		grid.sections = append(grid.sections, foot)
+	}
+
+	grid.numCols = len(grid.columns)
+	for _, s := range grid.sections {
+		base := len(grid.rows)
+		occupied := map[[2]int]bool{}
+		for ri, row := range s.rows {
+			col := 0
+			for c := row.node.FirstChild; c != nil; c = c.NextSibling {
+				if c.Type != xhtml.ElementNode {
+					continue
+				}
+				tag := strings.ToLower(c.Data)
+				if tag != "td" && tag != "th" {
+					continue
+				}
+				for occupied[[2]int{ri, col}] {
+					col++
+				}
+				colSpan := spanAttr(c, "colspan", 1)
+				rowSpan := spanAttr(c, "rowspan", 1)
+				if rowSpan == 0 || ri+rowSpan > len(s.rows) {
+					// rowspan="0" and overlong spans extend to the end of the section
+					rowSpan = len(s.rows) - ri
+				}
+				for dr := 0; dr < rowSpan; dr++ {
+					for dc := 0; dc < colSpan; dc++ {
+						occupied[[2]int{ri + dr, col + dc}] = true
+					}
+				}
+				row.cells = append(row.cells, &tableCell{
+					node:    c,
+					style:   e.mergeStyles(e.styles[row.node], e.styles[c]),
+					row:     base + ri,
+					col:     col,
+					rowSpan: rowSpan,
+					colSpan: colSpan,
+				})
+				col += colSpan
+				if col > grid.numCols {
+					grid.numCols = col
+				}
+			}
+			row.index = len(grid.rows)
+			grid.rows = append(grid.rows, row)
+		}
+	}
+
+	for len(grid.columns) < grid.numCols {
+		grid.columns = append(grid.columns, tableColumn{})
+	}
+	return grid
+}
+
+// columnsFromColgroup expands a <colgroup> into its columns. A colgroup without
+// <col> children contributes span columns sharing the colgroup's width.
+func (e *Engine) columnsFromColgroup(n *html.Node) []tableColumn {
+	group := tableColumn{}
+	if w := declaredWidth(n, e.styles[n]); w != "" {
+		group = tableColumn{width: w, hasWidth: true}
+	}
+	var cols []tableColumn
+	for c := n.FirstChild; c != nil; c = c.NextSibling {
+		if c.Type == xhtml.ElementNode && strings.EqualFold(c.Data, "col") {
+			cols = append(cols, e.columnsFromCol(c, group)...)
+		}
+	}
+	if len(cols) == 0 {
+		for i := 0; i < spanAttr(n, "span", 1); i++ {
+			cols = append(cols, group)
+		}
+	}
+	return cols
+}
+
+// columnsFromCol expands a <col> into span columns, inheriting the group width
+// when the col does not declare its own.
+func (e *Engine) columnsFromCol(n *html.Node, group tableColumn) []tableColumn {
+	col := group
+	if w := declaredWidth(n, e.styles[n]); w != "" {
+		col = tableColumn{width: w, hasWidth: true}
+	}
+	cols := make([]tableColumn, spanAttr(n, "span", 1))
+	for i := range cols {
+		cols[i] = col
+	}
+	return cols
+}
+
+// fixedColumnWidths implements table-layout: fixed. Widths come from <col>
+// elements first, then from the cells of the first row; the remaining space is
+// shared equally by the columns without a declared width.
+func (e *Engine) fixedColumnWidths(grid *tableGrid, available float64) []float64 {
+	widths := make([]float64, grid.numCols)
+	set := make([]bool, grid.numCols)
+	for i, c := range grid.columns {
+		if c.hasWidth {
+			widths[i] = parseLength(c.width, available, 0)
+			set[i] = widths[i] > 0
+		}
+	}
+	if len(grid.rows) > 0 {
+		for _, cell := range grid.rows[0].cells {
+			w := declaredWidth(cell.node, cell.style)
+			if w == "" {
+				continue
+			}
+			share := parseLength(w, available, 0) / float64(cell.colSpan)
+			for i := cell.col; i < cell.col+cell.colSpan; i++ {
+				if !set[i] && share > 0 {
+					widths[i] = share
+					set[i] = true
+				}
+			}
+		}
+	}
+
+	used, unset := 0.0, 0
+	for i := range widths {
+		if set[i] {
+			used += widths[i]
+		} else {
+			unset++
+		}
+	}
+	if unset > 0 {
+		each := math.Max(0, available-used) / float64(unset)
+		for i := range widths {
+			if !set[i] {
+				widths[i] = each
+			}
+		}
+	} else if used < available && used > 0 {
+		scale := available / used
+		for i := range widths {
+			widths[i] *= scale
+		}
+	}
+	return widths
+}
+
+// autoColumnWidths implements the automatic table layout algorithm. Every
+// column gets a minimum (longest unbreakable content) and a maximum (content on
+// a single line) width; declared widths pin a column. The columns then receive
+// their maximum widths if they fit, their minimum widths if nothing else fits,
+// and a proportional interpolation in between. A table with a declared width
+// grows its columns to fill it.
+func (e *Engine) autoColumnWidths(grid *tableGrid, available, target float64, autoWidth bool, spacingX float64) []float64 {
+	n := grid.numCols
+	ref := target
+	if autoWidth {
+		ref = available
+	}
+	minW, maxW, pinned := e.columnIntrinsicWidths(grid, ref, spacingX)
+
+	sumMin, sumMax := 0.0, 0.0
+	for i := 0; i < n; i++ {
+		sumMin += minW[i]
+		sumMax += maxW[i]
+	}
+
+	goal := available
+	if !autoWidth {
+		goal = target
+	}
+
+	widths := make([]float64, n)
+	switch {
+	case sumMax <= goal:
+		copy(widths, maxW)
+		if !autoWidth && sumMax < goal {
+			// Grow the flexible columns (or all of them) to fill the declared width
+			flex := 0.0
+			for i := 0; i < n; i++ {
+				if !pinned[i] {
+					flex += maxW[i]
+				}
+			}
+			extra := goal - sumMax
+			for i := 0; i < n; i++ {
+				switch {
+				case flex > 0 && !pinned[i]:
+					widths[i] += extra * maxW[i] / flex
+				case flex == 0 && sumMax > 0:
+					widths[i] += extra * maxW[i] / sumMax
+				case sumMax == 0:
+					widths[i] = goal / float64(n)
+				}
+			}
+		}
+	case sumMin >= goal:
+		copy(widths, minW)
+	default:
+		ratio := (goal - sumMin) / (sumMax - sumMin)
+		for i := 0; i < n; i++ {
+			widths[i] = minW[i] + (maxW[i]-minW[i])*ratio
+		}
+	}
+	return widths
+}
+
+// columnIntrinsicWidths returns the minimum and maximum widths of the
+// columns of a table, from the widths of their cells including padding, and
+// which columns are pinned by a declared width. ref is the width that
+// percentages are of.
+func (e *Engine) columnIntrinsicWidths(grid *tableGrid, ref, spacingX float64) ([]float64, []float64, []bool) {
+	n := grid.numCols
+	minW := make([]float64, n)
+	maxW := make([]float64, n)
+	pinned := make([]bool, n)
+
+	for i, c := range grid.columns {
+		if c.hasWidth {
+			if w := parseLength(c.width, ref, 0); w > 0 {
+				minW[i], maxW[i], pinned[i] = w, w, true
+			}
+		}
+	}
+
+	type measured struct {
+		cell     *tableCell
+		min, max float64
+	}
+	var spanning []measured
+	for _, row := range grid.rows {
+		for _, cell := range row.cells {
+			cmin, cmax := e.intrinsicWidths(cell.node, cell.style)
+			_, r, _, l := boxEdges(cell.style, "padding", ref)
+			cmin += l + r
+			cmax += l + r
+			if w := declaredWidth(cell.node, cell.style); w != "" {
+				if dw := parseLength(w, ref, 0); dw > 0 {
+					cmax = math.Max(dw, cmin)
+					if cell.colSpan == 1 {
+						pinned[cell.col] = true
+					}
+				}
+			}
+			if cell.colSpan > 1 {
+				spanning = append(spanning, measured{cell, cmin, cmax})
+				continue
+			}
+			minW[cell.col] = math.Max(minW[cell.col], cmin)
+			if pinned[cell.col] {
+				maxW[cell.col] = math.Max(maxW[cell.col], math.Max(cmax, minW[cell.col]))
+			} else {
+				maxW[cell.col] = math.Max(maxW[cell.col], cmax)
+			}
+		}
+	}
+
+	// Cells spanning several columns distribute any excess evenly
+	for _, m := range spanning {
+		inner := spacingX * float64(m.cell.colSpan-1)
+		curMin, curMax := inner, inner
+		for i := m.cell.col; i < m.cell.col+m.cell.colSpan; i++ {
+			curMin += minW[i]
+			curMax += maxW[i]
+		}
+		if extra := m.min - curMin; extra > 0 {
+			for i := m.cell.col; i < m.cell.col+m.cell.colSpan; i++ {
+				minW[i] += extra / float64(m.cell.colSpan)
+			}
+		}
+		if extra := m.max - curMax; extra > 0 {
+			for i := m.cell.col; i < m.cell.col+m.cell.colSpan; i++ {
+				maxW[i] += extra / float64(m.cell.colSpan)
+			}
+		}
+	}
+	for i := 0; i < n; i++ {
+		if maxW[i] < minW[i] {
+			maxW[i] = minW[i]
+		}
+	}
+	return minW, maxW, pinned
+}
+
+// tableIntrinsicWidths returns the min-content and max-content widths of a
+// table: those of its columns with the spacing between them and the borders
+// of the table, so that a table nested in a cell widens the column it is in
+// to fit its own columns
+func (e *Engine) tableIntrinsicWidths(n *html.Node, st style.ComputedStyle) (float64, float64) {
+	grid := e.buildTableGrid(n)
+	spacingX, spacingTotal := 0.0, 0.0
+	if !strings.EqualFold(strings.TrimSpace(st["border-collapse"].Value), "collapse") {
+		spacingX, _ = parseBorderSpacing(st["border-spacing"].Value, 0)
+		spacingTotal = spacingX * float64(grid.numCols+1)
+	}
+	minW, maxW, _ := e.columnIntrinsicWidths(grid, 0, spacingX)
+	sumMin := spacingTotal + st.Border("left").Width + st.Border("right").Width
+	sumMax := sumMin
+	for i := range minW {
+		sumMin += minW[i]
+		sumMax += maxW[i]
+	}
+	return sumMin, sumMax
+}
+
+// layoutTableRows lays out rows of a section, which no cell spans past,
+// into the section box from curY down, and returns the flow position below
+// them. Cell contents are laid out at their final widths first; vertical
+// placement waits for the row heights.
+func (e *Engine) layoutTableRows(rows []*tableRow, section *BlockBox, colX []float64, spacingX, spacingY, curY float64) float64 {
+	for _, row := range rows {
+		for _, cell := range row.cells {
+			w := colX[cell.col+cell.colSpan] - colX[cell.col] - spacingX
+			cell.box = e.newTableBlock(cell.node, cell.style, colX[cell.col], curY, w)
+			e.layoutTableContent(cell.node, cell.box)
+			cell.contentHeight = cell.box.Height
+			cell.minHeight = parseLength(cell.style["height"].Value, 0, 0)
+			cell.baseline = cell.contentHeight - cell.box.PaddingBottom - cell.box.BorderBottom
+			if y, ok := firstBaseline(cell.box.Children); ok {
+				cell.baseline = y - cell.box.Y
+			}
+		}
+	}
+
+	rowHeights := e.tableRowHeights(rows, spacingY)
+	rowY := make([]float64, len(rows)+1)
+	for i := range rows {
+		rowY[i] = curY
+		curY += rowHeights[i] + spacingY
+	}
+	rowY[len(rows)] = curY
+
+	for i, row := range rows {
+		row.box = e.newTableBlock(row.node, row.style, section.X, rowY[i], section.Width)
+		row.box.Height = rowHeights[i]
+		section.Children = append(section.Children, row.box)
+	}
+	// A cell is a child of the last row it spans, so that it is painted
+	// after the backgrounds of all its rows
+	base := rows[0].index
+	for _, row := range rows {
+		for _, cell := range row.cells {
+			r := cell.row - base
+			e.placeTableCell(cell, rowY[r], rowY[r+cell.rowSpan]-rowY[r]-spacingY, row.baseline)
+			end := rows[r+cell.rowSpan-1].box
+			end.Children = append(end.Children, cell.box)
+		}
+	}
+	return curY
+}
+
+// rowRuns splits the rows of a section into the shortest runs that no cell
+// spans past
+func rowRuns(rows []*tableRow) [][]*tableRow {
+	var runs [][]*tableRow
+	start, end := 0, 0
+	for i, row := range rows {
+		for _, cell := range row.cells {
+			end = max(end, i+cell.rowSpan)
+		}
+		if i+1 >= end {
+			runs = append(runs, rows[start:i+1])
+			start = i + 1
+		}
+	}
+	return runs
+}
+
+// isTableHeadOrFoot reports whether a table section is a <thead> or <tfoot>
+func isTableHeadOrFoot(n *html.Node) bool {
+	return n != nil && (strings.EqualFold(n.Data, "thead") || strings.EqualFold(n.Data, "tfoot"))
+}
+
+// tableRowHeights computes the height of every row of a section, or of rows
+// of it that no cell spans past. Single-row cells size their row directly;
+// cells spanning several rows grow the last spanned row when the rows are
+// not tall enough to contain them. Cells aligned by their baselines set the
+// baseline of the row they start in, which is lowered to that of the cell
+// with the most above it.
+func (e *Engine) tableRowHeights(rows []*tableRow, spacingY float64) []float64 {
+	base := rows[0].index
+	heights := make([]float64, len(rows))
+	for i, row := range rows {
+		if h := parseLength(e.styles[row.node]["height"].Value, 0, 0); h > heights[i] {
+			heights[i] = h
+		}
+		row.baseline = 0
+		for _, cell := range row.cells {
+			if cellAlign(cell) == "baseline" {
+				row.baseline = math.Max(row.baseline, cell.baseline)
+			}
+		}
+		for _, cell := range row.cells {
+			if cell.rowSpan == 1 {
+				heights[i] = math.Max(heights[i], cellHeight(cell, row.baseline))
+			}
+		}
+	}
+	for _, row := range rows {
+		for _, cell := range row.cells {
+			if cell.rowSpan == 1 {
+				continue
+			}
+			span := spacingY * float64(cell.rowSpan-1)
+			for i := cell.row - base; i < cell.row-base+cell.rowSpan; i++ {
+				span += heights[i]
+			}
+			if extra := cellHeight(cell, row.baseline) - span; extra > 0 {
+				heights[cell.row-base+cell.rowSpan-1] += extra
+			}
+		}
+	}
+	return heights
+}
+
+// cellAlign returns how a cell places its content in the height of its
+// rows: "top", "middle", "bottom" or "baseline", which other values of
+// vertical-align, such as sub or a length, align like
+func cellAlign(cell *tableCell) string {
+	switch v := strings.ToLower(strings.TrimSpace(cell.style["vertical-align"].Value)); v {
+	case "", "middle":
+		// middle is the initial value for table cells
+		return "middle"
+	case "top", "text-top":
+		return "top"
+	case "bottom", "text-bottom":
+		return "bottom"
+	}
+	return "baseline"
+}
+
+// cellHeight returns the height a cell needs in its rows: its content, its
+// declared height, and for a cell aligned by its baseline the content below
+// the baseline of the row, which is baseline from the top
+func cellHeight(cell *tableCell, baseline float64) float64 {
+	h := math.Max(cell.contentHeight, cell.minHeight)
+	if cellAlign(cell) == "baseline" {
+		h = math.Max(h, baseline-cell.baseline+cell.contentHeight)
+	}
+	return h
+}
+
+// placeTableCell moves a laid out cell to its final row position, stretches it
+// to the height of the rows it spans and applies vertical-align to its
+// content. baseline is that of the row the cell starts in.
+func (e *Engine) placeTableCell(cell *tableCell, y, height, baseline float64) {
+	b := cell.box
+	dy := y - b.Y
+	b.Y = y
+	b.Height = height
+
+	offset := 0.0
+	free := height - cell.contentHeight
+	if free > 0 {
+		switch cellAlign(cell) {
+		case "bottom":
+			offset = free
+		case "middle":
+			offset = free / 2
+		case "baseline":
+			offset = math.Min(baseline-cell.baseline, free)
+		}
+	}
+	e.shiftDescendants(b, 0, dy+offset)
+}
+
+// newTableBlock creates a block box for a table part with its padding resolved
+func (e *Engine) newTableBlock(n *html.Node, st style.ComputedStyle, x, y, width float64) *BlockBox {
+	b := &BlockBox{
+		Node:     n,
+		Style:    st,
+		X:        x,
+		Y:        y,
+		Width:    width,
+		Children: []Box{},
+	}
+	if n != nil {
+		tag := strings.ToLower(n.Data)
+		if tag == "td" || tag == "th" || tag == "caption" {
+			b.PaddingTop, b.PaddingRight, b.PaddingBottom, b.PaddingLeft = boxEdges(st, "padding", width)
+		}
+	}
+	return b
+}
+
+// layoutTableContent lays out the children of a cell or caption. Purely
+// inline content is wrapped like a paragraph; anything containing block-level
+// elements goes through the regular block flow.
+func (e *Engine) layoutTableContent(n *html.Node, b *BlockBox) {
+	// Cells are block formatting contexts; their floats stay inside them
+	saved := e.floats
+	e.floats = nil
+	defer func() { e.floats = saved }()
+
+	if e.hasBlockChildren(n) {
+		for c := n.FirstChild; c != nil; c = c.NextSibling {
+			e.processNode(c, b, 1)
+		}
+	} else {
+		// layoutParagraphInline works in the content box but expects the
+		// available width without padding
+		outer := b.Width
+		b.Width = outer - b.PaddingLeft - b.PaddingRight
+		e.layoutParagraphInline(n, b, b.Style)
+		b.Width = outer
+	}
+
+	bottom := b.Y + b.PaddingTop + b.BorderTop
+	for _, ch := range b.Children {
+		if v := ch.GetY() + ch.GetHeight() + ch.GetMarginBottom(); v > bottom {
+			bottom = v
+		}
+	}
+	b.Height = bottom - b.Y + b.PaddingBottom + b.BorderBottom
+}
+
+// hasBlockChildren reports whether any child element of n is block-level
+func (e *Engine) hasBlockChildren(n *html.Node) bool {
+	for c := n.FirstChild; c != nil; c = c.NextSibling {
+		if c.Type != xhtml.ElementNode {
+			continue
+		}
+		if e.isBlockTag(c.Data) {
+			return true
+		}
+		if d, ok := e.styles[c]["display"]; ok && (d.Value == "block" || d.Value == "flex" || d.Value == "grid" || d.Value == "list-item") {
+			return true
+		}
+	}
+	return false
+}
+
+// intrinsicWidths returns the min-content and max-content widths of the
+// content of n: the widest unbreakable word and the widest unwrapped line.
+func (e *Engine) intrinsicWidths(n *html.Node, st style.ComputedStyle) (float64, float64) {
+	if isFormControl(n) {
+		w := e.formControlWidth(n, st)
+		return w, w
+	}
+	if strings.EqualFold(n.Data, "table") {
+		return e.tableIntrinsicWidths(n, st)
+	}
+	minW, lineW, maxLine, blockMax := 0.0, 0.0, 0.0, 0.0
+
+	runs := []inlineRun{}
+	e.collectInlineRuns(n, st, &runs)
+	normalizeInlineRuns(&runs)
+	for _, run := range runs {
+		if run.block != nil {
+			bmin, bmax := e.outerIntrinsicWidths(run.block, run.style)
+			minW = math.Max(minW, bmin)
+			lineW += bmax
+			continue
+		}
+		fs := parseLength(run.style["font-size"].Value, 0, 16)
+		wraps := wrapsLines(whiteSpace(run.style))
+		for i, seg := range strings.Split(run.text, "\n") {
+			if i > 0 {
+				// A preserved line break starts a new line
+				maxLine, lineW = math.Max(maxLine, lineW), 0
+			}
+			// Tabs advance to the tab stops of the line
+			start := lineW
+			for j, part := range strings.Split(seg, "\t") {
+				if j > 0 {
+					lineW += e.tabAdvance(lineW, e.tabInterval(run.style, fs), fs, run.style)
+				}
+				lineW += e.measureTextWidth(stripSoftHyphens(part), fs, run.style)
+			}
+			if !wraps {
+				minW = math.Max(minW, lineW-start)
+				continue
+			}
+			for _, word := range strings.Fields(seg) {
+				minW = math.Max(minW, e.minContentWidth(word, fs, run.style))
+			}
+		}
+	}
+	lineW = math.Max(lineW, maxLine)
+
+	var walk func(p *html.Node, pst style.ComputedStyle)
+	walk = func(p *html.Node, pst style.ComputedStyle) {
+		for c := p.FirstChild; c != nil; c = c.NextSibling {
+			if c.Type != xhtml.ElementNode {
+				continue
+			}
+			cst := e.mergeStyles(pst, e.styles[c])
+			tag := strings.ToLower(c.Data)
+			switch {
+			case isReplaced(tag):
+				w := parseLength(declaredWidth(c, cst), 0, 40)
+				minW = math.Max(minW, w)
+				lineW += w
+			case (isInlineBlock(cst) || isFormControl(c)) && floatSide(cst) == "":
+				// Measured with the inline runs
+			case e.isBlockTag(tag):
+				cmin, cmax := e.outerIntrinsicWidths(c, cst)
+				minW = math.Max(minW, cmin)
+				blockMax = math.Max(blockMax, cmax)
+			case tag == "script" || tag == "style":
+			default:
+				walk(c, cst)
+			}
+		}
+	}
+	walk(n, st)
+
+	return minW, math.Max(lineW, math.Max(blockMax, minW))
+}
+
+// outerIntrinsicWidths returns the min-content and max-content widths of an
+// element including its margins and padding. A fixed declared width
+// replaces the content widths.
+func (e *Engine) outerIntrinsicWidths(n *html.Node, st style.ComputedStyle) (float64, float64) {
+	cmin, cmax := e.intrinsicWidths(n, st)
+	_, mr, _, ml := boxEdges(st, "margin", 0)
+	_, pr, _, pl := boxEdges(st, "padding", 0)
+	extra := ml + mr + pl + pr
+	if w := declaredWidth(n, st); w != "" && !strings.HasSuffix(w, "%") {
+		if dw := parseLength(w, 0, 0); dw > 0 {
+			cmin, cmax = math.Max(cmin, dw), math.Max(cmin, dw)
+		}
+	}
+	return cmin + extra, cmax + extra
+}
+
+// boxEdges resolves the per-side longhands of margin or padding into top,
+// right, bottom and left values.
+func boxEdges(st style.ComputedStyle, prop string, containerSize float64) (float64, float64, float64, float64) {
+	return parseLength(st[prop+"-top"].Value, containerSize, 0),
+		parseLength(st[prop+"-right"].Value, containerSize, 0),
+		parseLength(st[prop+"-bottom"].Value, containerSize, 0),
+		parseLength(st[prop+"-left"].Value, containerSize, 0)
+}
+
+// declaredWidth returns the CSS width of an element, falling back to the
+// legacy width attribute. Unitless attribute values are treated as pixels.
+func declaredWidth(n *html.Node, st style.ComputedStyle) string {
+	if w, ok := st["width"]; ok {
+		if v := strings.TrimSpace(w.Value); v != "" && !strings.EqualFold(v, "auto") {
+			return v
+		}
+	}
+	if n == nil {
+		return ""
+	}
+	for _, a := range n.Attr {
+		if strings.EqualFold(a.Key, "width") {
+			v := strings.TrimSpace(a.Val)
+			if _, err := strconv.ParseFloat(v, 64); err == nil {
+				return v + "px"
+			}
+			return v
+		}
+	}
+	return ""
+}
+
+// parseBorderSpacing parses border-spacing: one value applies to both axes,
+// two values are horizontal then vertical.
+func parseBorderSpacing(value string, containerSize float64) (float64, float64) {
+	parts := splitValues(value)
+	switch len(parts) {
+	case 0:
+		return 0, 0
+	case 1:
+		v := math.Max(0, parseLength(parts[0], containerSize, 0))
+		return v, v
+	default:
+		return math.Max(0, parseLength(parts[0], containerSize, 0)), math.Max(0, parseLength(parts[1], containerSize, 0))
+	}
+}
+
+// spanAttr reads a colspan/rowspan/span attribute, returning def when it is
+// missing or invalid
+func spanAttr(n *html.Node, key string, def int) int {
+	for _, a := range n.Attr {
+		if strings.EqualFold(a.Key, key) {
+			v, err := strconv.Atoi(strings.TrimSpace(a.Val))
+			if err != nil || v < 0 {
+				return def
+			}
+			if v == 0 && key != "rowspan" {
+				return def
+			}
+			if v > 1000 {
+				v = 1000
+			}
+			return v
+		}
+	}
+	return def
+}
@@ -0,0 +1,46 @@
+package layout
+
+import "testing"
+
+// paintOrder returns the boxes of a tree in the order they are painted
+func paintOrder(b Box, out []Box) []Box {
+	out = append(out, b)
+	if bb, ok := b.(*BlockBox); ok {
+		for _, c := range bb.Children {
+			out = paintOrder(c, out)
+		}
+	}
+	return out
+}
+
+func TestRowspanCellPaintedAfterItsRows(t *testing.T) {
+	root := layoutHTML(t, `<table><tr id="r1"><td id="span" rowspan="2">spanning</td><td>a</td></tr>
+		<tr id="r2"><td>b</td></tr><tr id="r3"><td>c</td><td>d</td></tr></table>`)
+	order := make(map[string]int)
+	for i, b := range paintOrder(root, nil) {
+		if b.GetNode() == nil {
+			continue
+		}
+		for _, a := range b.GetNode().Attr {
+			if a.Key == "id" {
+				order[a.Val] = i
+			}
+		}
+	}
+	for _, id := range []string{"r1", "r2", "r3", "span"} {
+		if _, ok := order[id]; !ok {
+			t.Fatalf("no box for %s", id)
+		}
+	}
+	if order["span"] < order["r2"] {
+		t.Errorf("rowspan cell is painted before the second row it spans")
+	}
+	if order["span"] > order["r3"] {
+		t.Errorf("rowspan cell is painted after the row below its span")
+	}
+	span := blockByID(root, "span")
+	r1, r2 := blockByID(root, "r1"), blockByID(root, "r2")
+	if span.Y != r1.Y || span.Y+span.Height < r2.Y+r2.Height-0.01 {
+		t.Errorf("rowspan cell at %.2f-%.2f does not cover rows %.2f-%.2f", span.Y, span.Y+span.Height, r1.Y, r2.Y+r2.Height)
+	}
+}
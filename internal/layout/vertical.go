@@ -0,0 +1,186 @@
+package layout
+
+import (
+	"math"
+	"strings"
+
+	"github.com/gompdf/gompdf/internal/parser/html"
+	"github.com/gompdf/gompdf/internal/style"
+)
+
+// startsVerticalFlow reports whether a block, float or inline-block is set
+// in a vertical writing mode while its parent is not, so that it lays out its content turned a
+// quarter turn; see layoutVertical. Blocks inside it are laid out in its
+// turned frame with it.
+func (e *Engine) startsVerticalFlow(node *html.Node, parent, st style.ComputedStyle) bool {
+	return st.VerticalWritingMode() && !parent.VerticalWritingMode() && !e.turning[node]
+}
+
+// layoutVertical lays out the content of a block b set in a vertical
+// writing mode. The content is laid out as horizontal text in a frame as
+// wide as the block is tall: its declared height, or else the height of the
+// page area less the vertical margins, padding and borders of the block.
+// The frame is then turned a quarter turn clockwise onto the content box of
+// the block, so that lines run downwards and follow each other from right
+// to left in vertical-rl or from left to right in vertical-lr. Without a
+// declared width the block is as wide as its lines, and without a declared
+// height as tall as its longest line. The margins, padding and borders of
+// the block stay where they are declared.
+//
+// Text measured in a vertical writing mode advances by an em for every
+// upright character, and images are laid out with their sides exchanged,
+// so that both stand upright once turned; see ImageBox.Layout and the
+// Vertical boxes of text.
+func (e *Engine) layoutVertical(node *html.Node, b *BlockBox, available float64, depth int) {
+	st := b.Style
+	edgesW := b.PaddingLeft + b.PaddingRight + b.BorderLeft + b.BorderRight
+	edgesH := b.PaddingTop + b.PaddingBottom + b.BorderTop + b.BorderBottom
+	inline := parseLength(st["height"].Value, 0, -1)
+	autoHeight := inline < 0
+	if !autoHeight {
+		inline = contentSize(st, inline, edgesH)
+	}
+	if autoHeight {
+		page := e.options.Height - e.options.MarginTop - e.options.MarginBottom
+		inline = math.Max(page-b.MarginTop-b.MarginBottom-edgesH, 0)
+	}
+
+	// The block is laid out again into the frame, without its own margins,
+	// padding, borders and sizes; its box there is dropped for its content
+	frame := &BlockBox{Style: st, Width: inline}
+	own := e.styles[node]
+	e.styles[node] = frameStyle(own)
+	outerFloats := e.floats
+	e.floats = nil
+	e.turning[node] = true
+	e.processNode(node, frame, depth)
+	delete(e.turning, node)
+	e.floats = outerFloats
+	e.styles[node] = own
+
+	var content []Box
+	extent, length := 0.0, 0.0
+	if len(frame.Children) > 0 {
+		if inner, ok := frame.Children[0].(*BlockBox); ok {
+			content, extent = inner.Children, inner.Height
+		}
+	}
+	for _, c := range content {
+		length = math.Max(length, contentLength(c))
+	}
+
+	width := parseLength(declaredWidth(node, st), available, -1)
+	if width < 0 {
+		width = extent
+	} else {
+		width = contentSize(st, width, edgesW)
+	}
+	height := inline
+	if autoHeight {
+		height = math.Min(length, inline)
+	}
+	x, y := b.X+b.PaddingLeft+b.BorderLeft, b.Y+b.PaddingTop+b.BorderTop
+	rl := st.WritingMode() == "vertical-rl"
+	for _, c := range content {
+		turnBox(c, x, y, width, rl)
+	}
+	b.Children = content
+	b.Width, b.Height = width+edgesW, height+edgesH
+}
+
+// frameStyle returns the style of a block laid out in a turned frame,
+// without the margins, padding, borders, backgrounds and sizes its own box
+// keeps. Floats and inline-blocks are laid out as plain blocks there.
+func frameStyle(st style.ComputedStyle) style.ComputedStyle {
+	out := make(style.ComputedStyle, len(st))
+	for k, v := range st {
+		switch {
+		case k == "width", k == "height", k == "float", k == "clear":
+		case hasAnyPrefix(k, "margin", "padding", "border", "background", "min-", "max-"):
+		case k == "display" && isInlineBlock(st):
+			out[k] = style.StyleProperty{Name: k, Value: "block"}
+		default:
+			out[k] = v
+		}
+	}
+	return out
+}
+
+// hasAnyPrefix reports whether s starts with one of prefixes
+func hasAnyPrefix(s string, prefixes ...string) bool {
+	for _, p := range prefixes {
+		if strings.HasPrefix(s, p) {
+			return true
+		}
+	}
+	return false
+}
+
+// contentLength returns how far the content of a box laid out in a frame
+// reaches along its lines: the right edge of its text, images and other
+// boxes without children
+func contentLength(b Box) float64 {
+	var children []Box
+	switch c := b.(type) {
+	case *BlockBox:
+		children = c.Children
+	case *InlineBox:
+		children = c.Children
+	}
+	if len(children) == 0 {
+		return b.GetX() + b.GetWidth()
+	}
+	length := 0.0
+	for _, c := range children {
+		length = math.Max(length, contentLength(c))
+	}
+	return length
+}
+
+// turnBox moves a box laid out in a frame, and its descendants, onto the
+// page: the frame is turned a quarter turn clockwise and placed with its
+// top left corner at x, y. width is the width the lines of the frame take
+// on the page; in vertical-rl (rl) the first line is at its right.
+func turnBox(b Box, x, y, width float64, rl bool) {
+	fx, fy, fw, fh := b.GetX(), b.GetY(), b.GetWidth(), b.GetHeight()
+	nx, ny := x+fy, y+fx
+	if rl {
+		nx = x + width - fy - fh
+	}
+	switch c := b.(type) {
+	case *BlockBox:
+		c.X, c.Y, c.Width, c.Height = nx, ny, fh, fw
+		if m := c.Marker; m != nil {
+			// The marker stands upright at the start of the first line
+			bx := m.Baseline
+			if rl {
+				bx = fh - m.Baseline
+			}
+			m.X, m.Baseline = bx-m.Width/2, m.X+m.FontSize
+		}
+		for _, ch := range c.Children {
+			turnBox(ch, x, y, width, rl)
+		}
+	case *InlineBox:
+		c.X, c.Y, c.Width, c.Height = nx, ny, fh, fw
+		c.Vertical = true
+		for _, ch := range c.Children {
+			turnBox(ch, x, y, width, rl)
+		}
+	case *ImageBox:
+		c.X, c.Y, c.Width, c.Height = nx, ny, fh, fw
+	}
+}
+
+// VerticalBaseline returns the x of the baseline of the text of a Vertical
+// box. Text of line boxes has it a font size left of the right edge, where
+// the top of the box was in its turned frame; other text is centered in the
+// width of the box.
+func (b *InlineBox) VerticalBaseline() float64 {
+	fs := parseLength(b.Style["font-size"].Value, 0, 16)
+	if b.Node == nil {
+		return b.X + b.Width - fs
+	}
+	ascent, descent := ascentRatio*fs, descentRatio*fs
+	return b.X + b.Width - ascent - math.Max(b.Width-ascent-descent, 0)/2
+}
@@ -0,0 +1,114 @@
+package layout
+
+import (
+	"math"
+	"strconv"
+	"strings"
+
+	"github.com/gompdf/gompdf/internal/style"
+)
+
+// whiteSpace returns the white-space value of a style, normal by default
+func whiteSpace(st style.ComputedStyle) string {
+	switch v := strings.ToLower(strings.TrimSpace(st["white-space"].Value)); v {
+	case "nowrap", "pre", "pre-wrap", "pre-line", "break-spaces":
+		return v
+	}
+	return "normal"
+}
+
+// collapsesSpaces reports whether sequences of spaces and tabs collapse into
+// a single space under a white-space value
+func collapsesSpaces(ws string) bool {
+	return ws == "normal" || ws == "nowrap" || ws == "pre-line"
+}
+
+// preservesNewlines reports whether newlines in the source force line breaks
+// under a white-space value
+func preservesNewlines(ws string) bool {
+	return ws != "normal" && ws != "nowrap"
+}
+
+// wrapsLines reports whether lines may be broken at spaces to fit the width
+// under a white-space value
+func wrapsLines(ws string) bool {
+	return ws != "nowrap" && ws != "pre"
+}
+
+// processWhitespace prepares the text of a text node for line breaking
+// according to the white-space value of its style: collapsing spaces and
+// turning newlines into spaces or keeping them. Tabs are kept where white
+// space is preserved; line layout advances them to the next tab stop.
+func processWhitespace(s string, st style.ComputedStyle) string {
+	s = strings.ReplaceAll(s, "\r\n", "\n")
+	s = strings.ReplaceAll(s, "\r", "\n")
+	ws := whiteSpace(st)
+	switch {
+	case !collapsesSpaces(ws):
+		return s
+	case !preservesNewlines(ws):
+		return normalizeWhitespace(s)
+	}
+	// pre-line keeps the newlines but drops the spaces around them
+	lines := strings.Split(s, "\n")
+	for i, line := range lines {
+		line = normalizeWhitespace(line)
+		if i > 0 {
+			line = strings.TrimLeft(line, " ")
+		}
+		if i < len(lines)-1 {
+			line = strings.TrimRight(line, " ")
+		}
+		lines[i] = line
+	}
+	return strings.Join(lines, "\n")
+}
+
+// tabInterval returns the distance between the tab stops of text in a
+// style and font size. tab-size is a number of spaces, 8 by default, or a
+// length.
+func (e *Engine) tabInterval(st style.ComputedStyle, fs float64) float64 {
+	space := e.measureTextWidth(" ", fs, st)
+	v := strings.TrimSpace(st["tab-size"].Value)
+	if n, err := strconv.ParseFloat(v, 64); err == nil && n >= 0 {
+		return n * space
+	}
+	// Font-relative lengths are taken from the font of the text
+	n, unit, ok := style.SplitUnit(v)
+	switch {
+	case !ok || n < 0:
+	case unit == "em":
+		return n * fs
+	case unit == "ch":
+		return n * e.measureTextWidth("0", fs, st)
+	default:
+		if l := parseLength(v, 0, -1); l >= 0 {
+			return l
+		}
+	}
+	return 8 * space
+}
+
+// tabAdvance returns the width of a tab at x from the start of its line:
+// the distance to the next tab stop, skipping a stop closer than half a
+// space
+func (e *Engine) tabAdvance(x, interval, fs float64, st style.ComputedStyle) float64 {
+	if interval <= 0 {
+		return 0
+	}
+	w := interval - math.Mod(x, interval)
+	if w < e.measureTextWidth(" ", fs, st)/2 {
+		w += interval
+	}
+	return w
+}
+
+// isWhiteSpace reports whether r is document white space, which collapses
+// and separates words. No-break spaces are not.
+func isWhiteSpace(r rune) bool {
+	switch r {
+	case ' ', '\t', '\n', '\r', '\f':
+		return true
+	}
+	return false
+}
@@ -0,0 +1,75 @@
+package layout
+
+import (
+	"math"
+	"strings"
+
+	"github.com/gompdf/gompdf/internal/style"
+)
+
+// sizeBlock narrows a block box to its declared width, kept between its
+// min-width and max-width, and places it between its horizontal margins.
+// Widths are those of the content box, or of the border box with
+// box-sizing: border-box; see contentSize. available is the width of the
+// content box of its parent.
+func (e *Engine) sizeBlock(b *BlockBox, available float64) {
+	st := b.Style
+	insets := b.PaddingLeft + b.PaddingRight + b.BorderLeft + b.BorderRight
+	width := b.Width - insets
+	declared := false
+	if w := declaredWidth(b.Node, st); w != "" {
+		if v := parseLength(w, available, -1); v >= 0 {
+			width, declared = contentSize(st, v, insets), true
+		}
+	}
+	if v := parseLength(st["max-width"].Value, available, -1); v >= 0 && width > contentSize(st, v, insets) {
+		width, declared = contentSize(st, v, insets), true
+	}
+	if v := parseLength(st["min-width"].Value, available, -1); v > 0 && width < contentSize(st, v, insets) {
+		width, declared = contentSize(st, v, insets), true
+	}
+	if !declared {
+		return
+	}
+	b.Width = math.Max(0, width) + insets
+	e.alignBlock(b, available)
+}
+
+// alignBlock moves a block narrower than the room between its margins:
+// it is centered between auto margins, or put against the right one when
+// only the left is auto. Its descendants move with it.
+func (e *Engine) alignBlock(b *BlockBox, available float64) {
+	free := available - b.MarginLeft - b.MarginRight - b.Width
+	if free <= 0 {
+		return
+	}
+	autoLeft, autoRight := isAuto(b.Style["margin-left"].Value), isAuto(b.Style["margin-right"].Value)
+	var dx float64
+	switch {
+	case autoLeft && autoRight:
+		dx = free / 2
+	case autoLeft:
+		dx = free
+	default:
+		return
+	}
+	b.X += dx
+	e.shiftDescendants(b, dx, 0)
+}
+
+// borderBoxSizing reports whether the width and height of an element are
+// those of its border box, with box-sizing: border-box, rather than those
+// of its content box
+func borderBoxSizing(st style.ComputedStyle) bool {
+	return strings.EqualFold(strings.TrimSpace(st["box-sizing"].Value), "border-box")
+}
+
+// contentSize returns the size of the content box of an element for its
+// declared size v: v less the padding and borders on that side, insets,
+// when the element sizes its border box, and v itself otherwise
+func contentSize(st style.ComputedStyle, v, insets float64) float64 {
+	if borderBoxSizing(st) {
+		return math.Max(0, v-insets)
+	}
+	return v
+}
@@ -0,0 +1,199 @@
+// Package logging defines how the conversion pipeline reports diagnostics.
+// Components never print directly; they hand messages to a Logger chosen by
+// the host, which may capture, redirect or drop them.
+package logging
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"strings"
+	"sync"
+)
+
+// Logger receives diagnostic messages. Debugf carries tracing output, most of
+// which is only produced in debug mode; Warnf reports problems the conversion
+// recovered from, such as a resource that could not be loaded.
+// Implementations must be safe for concurrent use.
+type Logger interface {
+	Debugf(format string, args ...any)
+	Warnf(format string, args ...any)
+}
+
+// Discard is a Logger that drops every message
+var Discard Logger = discard{}
+
+type discard struct{}
+
+func (discard) Debugf(string, ...any) {}
+func (discard) Warnf(string, ...any)  {}
+
+// NewWriter returns a Logger that writes one line per message to w. Warnings
+// are prefixed with "warning: ".
+func NewWriter(w io.Writer) Logger {
+	return &writer{w: w}
+}
+
+type writer struct {
+	mu sync.Mutex
+	w  io.Writer
+}
+
+func (l *writer) Debugf(format string, args ...any) {
+	l.write("", format, args)
+}
+
+func (l *writer) Warnf(format string, args ...any) {
+	l.write("warning: ", format, args)
+}
+
+func (l *writer) write(prefix, format string, args []any) {
+	msg := strings.TrimRight(fmt.Sprintf(format, args...), "\n")
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	fmt.Fprintln(l.w, prefix+msg)
+}
+
+// NewSlog returns a Logger that forwards messages to an slog.Logger at debug
+// and warning level
+func NewSlog(l *slog.Logger) Logger {
+	if l == nil {
+		return Discard
+	}
+	return slogLogger{l}
+}
+
+type slogLogger struct {
+	l *slog.Logger
+}
+
+func (s slogLogger) Debugf(format string, args ...any) {
+	s.log(slog.LevelDebug, format, args)
+}
+
+func (s slogLogger) Warnf(format string, args ...any) {
+	s.log(slog.LevelWarn, format, args)
+}
+
+func (s slogLogger) log(level slog.Level, format string, args []any) {
+	ctx := context.Background()
+	if !s.l.Enabled(ctx, level) {
+		return
+	}
+	s.l.Log(ctx, level, strings.TrimRight(fmt.Sprintf(format, args...), "\n"))
+}
+
+// Or returns l, or Discard when l is nil
+func Or(l Logger) Logger {
+	if l == nil {
+		return Discard
+	}
+	return l
+}
+
+// WarningCode classifies a warning, so that hosts can tell the problems
+// they expect from those they do not
+type WarningCode string
+
+const (
+	// WarningResource is an image, stylesheet or other resource that could
+	// not be loaded
+	WarningResource WarningCode = "resource"
+	// WarningImage is an image that was loaded but could not be decoded or
+	// embedded
+	WarningImage WarningCode = "image"
+	// WarningFont is a font that could not be loaded or parsed
+	WarningFont WarningCode = "font"
+	// WarningStylesheet is a stylesheet or @import that could not be parsed
+	// or was skipped
+	WarningStylesheet WarningCode = "stylesheet"
+	// WarningUnknownProperty is a declaration of a property CSS does not
+	// define, which is dropped
+	WarningUnknownProperty WarningCode = "unknown-property"
+	// WarningOther is any other warning
+	WarningOther WarningCode = "other"
+)
+
+// Warning is a problem a conversion recovered from
+type Warning struct {
+	Code    WarningCode
+	Message string
+	// Node locates the element the warning is about as a selector path,
+	// such as "html > body > div:nth-of-type(2) > img#logo"; it is empty
+	// for warnings that are not about an element
+	Node string
+	// Err is the error that caused the warning, if any, for classifying it
+	// with errors.Is
+	Err error
+}
+
+// String returns the message of the warning followed by its element
+func (w Warning) String() string {
+	if w.Node == "" {
+		return w.Message
+	}
+	return w.Message + " (at " + w.Node + ")"
+}
+
+// WarningLogger is a Logger that receives warnings in structured form
+type WarningLogger interface {
+	Logger
+	Warn(w Warning)
+}
+
+// Warn reports a warning to l: as is when l is a WarningLogger, as a Warnf
+// message otherwise
+func Warn(l Logger, w Warning) {
+	if wl, ok := l.(WarningLogger); ok {
+		wl.Warn(w)
+		return
+	}
+	Or(l).Warnf("%s", w)
+}
+
+// Collector is a Logger that keeps the warnings it receives, in order, and
+// passes every message on to Next
+type Collector struct {
+	Next Logger
+
+	mu       sync.Mutex
+	warnings []Warning
+}
+
+func (c *Collector) Debugf(format string, args ...any) {
+	Or(c.Next).Debugf(format, args...)
+}
+
+// Warnf keeps a warning of code WarningOther
+func (c *Collector) Warnf(format string, args ...any) {
+	c.Warn(Warning{Code: WarningOther, Message: strings.TrimRight(fmt.Sprintf(format, args...), "\n")})
+}
+
+func (c *Collector) Warn(w Warning) {
+	c.mu.Lock()
+	c.warnings = append(c.warnings, w)
+	c.mu.Unlock()
+	Warn(c.Next, w)
+}
+
+// Warnings returns the warnings received so far
+func (c *Collector) Warnings() []Warning {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return append([]Warning(nil), c.warnings...)
+}
+
+// Warnf reports a warning of the given code about the element at the
+// selector path node, "" when it is not about an element, as Warn does. The
+// last error among args is the Err of the warning.
+func Warnf(l Logger, code WarningCode, node string, format string, args ...any) {
+	w := Warning{Code: code, Message: strings.TrimRight(fmt.Sprintf(format, args...), "\n"), Node: node}
+	for i := len(args) - 1; i >= 0; i-- {
+		if err, ok := args[i].(error); ok {
+			w.Err = err
+			break
+		}
+	}
+	Warn(l, w)
+}
@@ -0,0 +1,297 @@
+package pagination
+
+import (
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/gompdf/gompdf/internal/layout"
+	"github.com/gompdf/gompdf/internal/parser/html"
+)
+
+// pageBreaks applies the page-break-* and break-* properties of blocks while
+// the flow is fragmented. A break is expressed by opening a vertical gap in
+// the flow: everything from the break onwards is pushed down to the next cut.
+//
+// Paragraphs split across pages keep the orphans and widows properties: a
+// cut never leaves fewer lines than orphans at the bottom of a page or than
+// widows at the top of the next, and falls between lines.
+//
+// A change of named page (the page property) forces a break as well. named
+// holds the first blocks of content after each change of named page and the
+// name in effect from them on, "" for unnamed pages.
+type pageBreaks struct {
+	f     flow
+	boxes []layout.Box
+	named map[*html.Node]string
+	// regions holds the blocks with a page name that enclose the current
+	// box, innermost last
+	regions []*layout.BlockBox
+	current string
+	// pending is a change of name not yet recorded. It is recorded on the
+	// first block without block children from the change on, as enclosing
+	// blocks may start on another page than their content.
+	pending string
+	changed bool
+	// after holds the blocks with a forced break after them whose end the
+	// flow has not reached yet
+	after []*layout.BlockBox
+}
+
+// newPageBreaks returns the page break state for a flow of boxes sorted by
+// position
+func newPageBreaks(f flow, boxes []layout.Box) *pageBreaks {
+	return &pageBreaks{f: f, boxes: boxes, named: make(map[*html.Node]string)}
+}
+
+// reach applies the forced breaks after the blocks that end at or above y.
+// Breaks wait for the flow to reach the end of their block, which its
+// content may have moved down since the block was met. A break after the
+// last content opens no page.
+func (pb *pageBreaks) reach(y float64) {
+	for i := 0; i < len(pb.after); {
+		bb := pb.after[i]
+		bottom := bb.Y + bb.Height
+		if bottom > y+0.01 {
+			i++
+			continue
+		}
+		pb.after = append(pb.after[:i], pb.after[i+1:]...)
+		if pb.follows(bottom) {
+			openGap(pb.boxes, bottom, pb.f.gapBefore(bottom))
+		}
+	}
+}
+
+// follows reports whether some box of the flow starts at or below y
+func (pb *pageBreaks) follows(y float64) bool {
+	for _, b := range pb.boxes {
+		if b.GetY() >= y-0.01 {
+			return true
+		}
+	}
+	return false
+}
+
+// block applies the page break rules of a block met in the flow
+func (pb *pageBreaks) block(bb *layout.BlockBox) {
+	for len(pb.regions) > 0 {
+		r := pb.regions[len(pb.regions)-1]
+		if bb.Y < r.Y+r.Height-0.01 {
+			break
+		}
+		pb.regions = pb.regions[:len(pb.regions)-1]
+	}
+	name := pageName(bb)
+	if name != "" {
+		pb.regions = append(pb.regions, bb)
+	} else if len(pb.regions) > 0 {
+		name = pageName(pb.regions[len(pb.regions)-1])
+	}
+	if name != pb.current {
+		openGap(pb.boxes, bb.Y, pb.f.gapBefore(bb.Y))
+		pb.pending, pb.changed = name, true
+		pb.current = name
+	}
+	if pb.changed && !hasBlockChildren(bb) {
+		pb.named[bb.Node] = pb.pending
+		pb.changed = false
+	}
+
+	if forcesPageBreak(breakValue(bb, "before")) {
+		openGap(pb.boxes, bb.Y, pb.f.gapBefore(bb.Y))
+	}
+
+	if keepsTogether(bb) && pb.f.fits(bb) && pb.f.crosses(bb) {
+		openGap(pb.boxes, bb.Y, pb.f.gapBefore(bb.Y))
+	}
+
+	if !hasBlockChildren(bb) && !inTable(bb.Node) {
+		keepLines(pb.boxes, bb, pb.f)
+	}
+
+	if forcesPageBreak(breakValue(bb, "after")) {
+		pb.after = append(pb.after, bb)
+	}
+}
+
+// finish applies the forced breaks after the last blocks of the flow
+func (pb *pageBreaks) finish() {
+	pb.reach(math.Inf(1))
+}
+
+// keepLines moves lines of a block laid out in line boxes down to the next
+// page wherever a cut crosses a line or leaves fewer lines than the block's
+// orphans before it or widows after it. When the first page would keep too
+// few lines the whole block moves.
+func keepLines(boxes []layout.Box, bb *layout.BlockBox, f flow) {
+	tops, bottoms := blockLines(bb)
+	n := len(tops)
+	orphans := lineCount(bb.Style["orphans"].Value)
+	widows := lineCount(bb.Style["widows"].Value)
+	// shift is how far the lines from first on have been moved down
+	shift := 0.0
+	first := 0
+	for i := 0; i < n; i++ {
+		top, bottom := tops[i]+shift, bottoms[i]+shift
+		crosses := f.page(bottom-0.02) > f.page(top)
+		if !crosses && (i == first || f.page(top) == f.page(tops[i-1]+shift)) {
+			continue
+		}
+		// Line i is the first after the cut when it falls between lines,
+		// otherwise the line the cut crosses
+		split := i
+		if n-split < widows {
+			split = n - widows
+		}
+		if split-first < orphans {
+			split = i
+			if first == 0 {
+				split = 0
+			}
+		}
+		if split == i && !crosses {
+			first = i
+			continue
+		}
+		y := tops[split] + shift
+		if split == 0 {
+			y = bb.Y
+		}
+		gap := f.gapBefore(y)
+		if gap <= 0 {
+			first = i
+			continue
+		}
+		openGap(boxes, y, gap)
+		shift += gap
+		first, i = split, split
+	}
+}
+
+// blockLines returns the top and bottom of the line boxes of a block, from
+// the text boxes layout placed in them. Text boxes reach down to the bottom
+// of their line box, which every line after the first starts at.
+func blockLines(bb *layout.BlockBox) ([]float64, []float64) {
+	var bottoms []float64
+	top := math.Inf(1)
+	for _, ch := range bb.Children {
+		ib, ok := ch.(*layout.InlineBox)
+		if !ok || ib.Node != nil {
+			continue
+		}
+		top = math.Min(top, ib.Y)
+		bottom := ib.Y + ib.Height
+		i := sort.SearchFloat64s(bottoms, bottom-0.01)
+		if i < len(bottoms) && bottoms[i] < bottom+0.01 {
+			continue
+		}
+		bottoms = append(bottoms, 0)
+		copy(bottoms[i+1:], bottoms[i:])
+		bottoms[i] = bottom
+	}
+	// The first line starts at the top of the content box, which text boxes
+	// may reach slightly above
+	top = math.Max(top, bb.Y+bb.BorderTop+bb.PaddingTop)
+	tops := make([]float64, len(bottoms))
+	for i := range bottoms {
+		if i == 0 {
+			tops[i] = top
+		} else {
+			tops[i] = bottoms[i-1]
+		}
+	}
+	return tops, bottoms
+}
+
+// lineCount parses an orphans or widows value, 2 when it is not a positive
+// integer
+func lineCount(v string) int {
+	n, err := strconv.Atoi(strings.TrimSpace(v))
+	if err != nil || n < 1 {
+		return 2
+	}
+	return n
+}
+
+// inTable reports whether a node is inside a table, whose rows the
+// paginator moves as a whole
+func inTable(n *html.Node) bool {
+	for ; n != nil; n = n.Parent {
+		if n.Data == "table" {
+			return true
+		}
+	}
+	return false
+}
+
+// openGap moves every box starting at or below y down by gap and stretches
+// the boxes that span y so that they still enclose their content.
+func openGap(boxes []layout.Box, y, gap float64) {
+	if gap <= 0 {
+		return
+	}
+	for _, b := range boxes {
+		switch {
+		case b.GetY() >= y-0.01:
+			b.SetPosition(b.GetX(), b.GetY()+gap)
+		case b.GetY()+b.GetHeight() > y+0.01:
+			if bb, ok := b.(*layout.BlockBox); ok {
+				bb.Height += gap
+			}
+		}
+	}
+}
+
+// breakValue returns the box's break value for the given side ("before",
+// "after" or "inside"). The modern break-* property wins over the legacy
+// page-break-* alias when both are present.
+func breakValue(b *layout.BlockBox, side string) string {
+	if v, ok := b.Style["break-"+side]; ok {
+		return strings.ToLower(strings.TrimSpace(v.Value))
+	}
+	if v, ok := b.Style["page-break-"+side]; ok {
+		return strings.ToLower(strings.TrimSpace(v.Value))
+	}
+	return ""
+}
+
+// forcesPageBreak reports whether a break value forces a page break
+func forcesPageBreak(v string) bool {
+	switch v {
+	case "always", "page", "left", "right", "recto", "verso":
+		return true
+	}
+	return false
+}
+
+// avoidsPageBreak reports whether a break value asks to avoid a page break
+func avoidsPageBreak(v string) bool {
+	return v == "avoid" || v == "avoid-page"
+}
+
+// keepsTogether reports whether a block should not be split across pages:
+// its break-inside value avoids breaks or it has a data-keep-together
+// attribute other than "false". Blocks taller than a page split anyway.
+func keepsTogether(b *layout.BlockBox) bool {
+	if avoidsPageBreak(breakValue(b, "inside")) {
+		return true
+	}
+	for _, a := range b.Node.Attr {
+		if strings.EqualFold(a.Key, "data-keep-together") {
+			return !strings.EqualFold(strings.TrimSpace(a.Val), "false")
+		}
+	}
+	return false
+}
+
+// hasBlockChildren reports whether a block contains other blocks
+func hasBlockChildren(b *layout.BlockBox) bool {
+	for _, ch := range b.Children {
+		if _, ok := ch.(*layout.BlockBox); ok {
+			return true
+		}
+	}
+	return false
+}
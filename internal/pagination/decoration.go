@@ -0,0 +1,112 @@
+package pagination
+
+import (
+	"strings"
+
+	"github.com/gompdf/gompdf/internal/layout"
+	"github.com/gompdf/gompdf/internal/style"
+)
+
+// A block split across pages paints its background on every fragment. Its
+// box-decoration-break decides what happens to its borders and padding at
+// the cuts: with slice, the initial value, the block is painted as if it
+// were cut apart with scissors, so only the first fragment has the top
+// border and only the last the bottom one. With clone every fragment is
+// framed by the borders and padding of the block, for which room is made
+// at the cuts when the flow is prepared (see clonedBlocks).
+
+// clonesDecorations reports whether a block's box-decoration-break is clone
+func clonesDecorations(bb *layout.BlockBox) bool {
+	return strings.EqualFold(strings.TrimSpace(bb.Style["box-decoration-break"].Value), "clone")
+}
+
+// sliceFragment takes the top border off a fragment that continues a block
+// from an earlier page and the bottom border off one that the block
+// continues from on a later page. The style is copied, as the block and
+// its other fragments share it.
+func sliceFragment(frag *layout.BlockBox, continued, continues bool) {
+	if !continued && !continues {
+		return
+	}
+	st := make(style.ComputedStyle, len(frag.Style)+2)
+	for k, v := range frag.Style {
+		st[k] = v
+	}
+	if continued {
+		st["border-top-style"] = style.StyleProperty{Name: "border-top-style", Value: "none"}
+		frag.BorderTop = 0
+	}
+	if continues {
+		st["border-bottom-style"] = style.StyleProperty{Name: "border-bottom-style", Value: "none"}
+		frag.BorderBottom = 0
+	}
+	frag.Style = st
+}
+
+// clonedBlocks makes room for the borders and padding of blocks with
+// box-decoration-break: clone where the flow is cut inside them: content
+// that would run into the bottom border and padding before a cut moves
+// below it, and content that starts a page inside such a block moves down
+// by its top border and padding.
+type clonedBlocks struct {
+	f     flow
+	boxes []layout.Box
+	// open holds the cloning blocks that enclose the current box,
+	// innermost last
+	open []*layout.BlockBox
+	// page is the page of the last content met
+	page int
+}
+
+// box moves a box met in the flow clear of the decorations of the cloning
+// blocks around it
+func (c *clonedBlocks) box(b layout.Box) {
+	for len(c.open) > 0 {
+		last := c.open[len(c.open)-1]
+		if b.GetY() < last.Y+last.Height-0.01 {
+			break
+		}
+		c.open = c.open[:len(c.open)-1]
+	}
+	if bb, ok := b.(*layout.BlockBox); ok && bb.Node != nil && clonesDecorations(bb) {
+		c.open = append(c.open, bb)
+		return
+	}
+	if !unbreakable(b, c.f) {
+		return
+	}
+	if len(c.open) == 0 {
+		c.page = max(c.page, c.f.page(b.GetY()))
+		return
+	}
+
+	f := c.f
+	k := f.page(b.GetY())
+	if b.GetY() > f.cut(k)+0.01 && f.fits(b) {
+		next := f.cut(k + 1)
+		bottom := 0.0
+		for _, bb := range c.open {
+			if bb.Y+bb.Height > next+0.01 {
+				bottom += bb.PaddingBottom + bb.BorderBottom
+			}
+		}
+		if b.GetY()+b.GetHeight() > next-bottom+0.01 {
+			openGap(c.boxes, b.GetY(), f.gapBefore(b.GetY()))
+			k++
+		}
+	}
+	first := k > c.page
+	c.page = k
+	if !first {
+		return
+	}
+	// The first content of a page goes below the top border and padding
+	// of the blocks continuing on it
+	top := f.cut(k)
+	for _, bb := range c.open {
+		if bb.Y < f.cut(k)-0.01 {
+			top += bb.PaddingTop + bb.BorderTop
+		}
+	}
+	openGap(c.boxes, b.GetY(), top-b.GetY())
+}
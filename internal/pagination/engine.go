@@ -1,9 +1,20 @@
 package pagination
 
 import (
+	"context"
+	"errors"
+
 	"github.com/gompdf/gompdf/internal/layout"
 )
 
+// ErrTooManyPages is the failure of a document that would have more pages
+// than allowed, such as one with a block millions of points tall
+var ErrTooManyPages = errors.New("too many pages")
+
+// DefaultMaxPages is the most pages a document may have unless the options
+// set another limit
+const DefaultMaxPages = 10000
+
 // Options represents options for the pagination engine
 type Options struct {
 	PageWidth    float64
@@ -12,11 +23,33 @@ type Options struct {
 	MarginRight  float64
 	MarginBottom float64
 	MarginLeft   float64
+	// RepeatTableHeaders repeats <thead> rows on pages a table continues on
+	RepeatTableHeaders bool
+	// MinTableRows is the fewest body rows a table keeps with its header at
+	// the bottom of a page
+	MinTableRows int
+	// PageLabels starts sections of page numbering
+	PageLabels []PageLabel
+	// FirstPageMargins, LeftPageMargins and RightPageMargins, when set,
+	// replace the margins on the first page and on left-hand (even) and
+	// right-hand (odd) pages
+	FirstPageMargins *Margins
+	LeftPageMargins  *Margins
+	RightPageMargins *Margins
+	// RunningElements are the running elements of the layout, which
+	// MarginBoxes show in the page margins
+	RunningElements []*layout.RunningElement
+	MarginBoxes     []MarginBox
+	// MaxPages is the most pages a document may have, DefaultMaxPages when
+	// 0; negative for no limit
+	MaxPages int
 }
 
 // Engine handles the pagination process
 type Engine struct {
 	options Options
+	// ctx allows a long pagination to be abandoned; see SetContext
+	ctx context.Context
 }
 
 // NewEngine creates a new pagination engine
@@ -29,6 +62,9 @@ func NewEngine() *Engine {
 			MarginRight:  72,
 			MarginBottom: 72,
 			MarginLeft:   72,
+
+			RepeatTableHeaders: true,
+			MinTableRows:       1,
 		},
 	}
 }
@@ -38,8 +74,26 @@ func (e *Engine) SetOptions(options Options) {
 	e.options = options
 }
 
-// Paginate breaks content into pages
-func (e *Engine) Paginate(rootBox *layout.BlockBox) []*Page {
+// SetContext sets a context that aborts pagination with its error once it
+// is done
+func (e *Engine) SetContext(ctx context.Context) {
+	e.ctx = ctx
+}
+
+// Paginate breaks content into pages. The boxes of the layout are moved
+// onto the pages, so a layout can be paginated once. It fails with an error
+// wrapping ErrTooManyPages when the layout needs more pages than allowed.
+func (e *Engine) Paginate(rootBox *layout.BlockBox) ([]*Page, error) {
+	pages, err := e.paginator().Paginate(rootBox)
+	if err != nil {
+		return nil, err
+	}
+	numberPages(pages, e.options.PageLabels)
+	return pages, nil
+}
+
+// paginator returns a paginator set up with the engine's options
+func (e *Engine) paginator() *Paginator {
 	paginator := NewPaginator(
 		PageSize{
 			Width:  e.options.PageWidth,
@@ -54,5 +108,14 @@ func (e *Engine) Paginate(rootBox *layout.BlockBox) []*Page {
 		},
 	)
 
-	return paginator.Paginate(rootBox)
+	paginator.RepeatTableHeaders = e.options.RepeatTableHeaders
+	paginator.MinTableRows = e.options.MinTableRows
+	paginator.FirstPageMargins = e.options.FirstPageMargins
+	paginator.LeftPageMargins = e.options.LeftPageMargins
+	paginator.RightPageMargins = e.options.RightPageMargins
+	paginator.RunningElements = e.options.RunningElements
+	paginator.MarginBoxes = e.options.MarginBoxes
+	paginator.MaxPages = e.options.MaxPages
+	paginator.ctx = e.ctx
+	return paginator
 }
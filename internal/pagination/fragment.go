@@ -0,0 +1,384 @@
+package pagination
+
+import (
+	"context"
+	"math"
+	"strings"
+
+	"github.com/gompdf/gompdf/internal/layout"
+	"github.com/gompdf/gompdf/internal/parser/html"
+)
+
+// Layout places the whole document in one continuous flow, as wide as the
+// content area of a page and as tall as the content. Pagination fragments
+// that flow into pages by cutting it where each page's content area is
+// full. Before the flow is cut, in one pass down the flow:
+//
+//   - page breaks, named pages, keep-together blocks, orphans and widows are
+//     expressed as gaps in the flow (see pageBreaks),
+//   - unbreakable boxes crossing a cut, such as lines of text, images and
+//     table rows, are moved below it,
+//   - room is made for the borders and padding of blocks framing each of
+//     their fragments (see clonedBlocks),
+//   - tables whose header would end a page with too few rows after it
+//     move to the next page (see keepRowsWithHeader), and
+//   - room is made for the table headers repeated where a table continues
+//     after a cut.
+//
+// Every piece of content then lies between two cuts and goes on exactly one
+// page. Blocks that span cuts are split into one fragment per page.
+
+// flow describes where the flow is cut: its top and the heights of the
+// content areas of the first page and of the left- and right-hand pages
+// after it. Pages alternate from the first, a right-hand page.
+type flow struct {
+	start float64
+	first float64
+	left  float64
+	right float64
+}
+
+// height returns the height of the content area of page k
+func (f flow) height(k int) float64 {
+	switch {
+	case k == 0:
+		return f.first
+	case k%2 == 1:
+		return f.left
+	default:
+		return f.right
+	}
+}
+
+// page returns the index of the page the flow position y falls on. Content
+// above the top of the flow, such as that of negative margins, goes on the
+// first page.
+func (f flow) page(y float64) int {
+	rel := y - f.start + 0.01
+	if y <= f.start || rel < f.first {
+		return 0
+	}
+	rel -= f.first
+	pair := f.left + f.right
+	n := math.Floor(rel / pair)
+	k := 1 + 2*int(n)
+	if rel-n*pair >= f.left {
+		k++
+	}
+	return k
+}
+
+// cut returns the flow position where page k starts
+func (f flow) cut(k int) float64 {
+	if k <= 0 {
+		return f.start
+	}
+	m := k - 1
+	y := f.start + f.first + float64(m/2)*(f.left+f.right)
+	if m%2 == 1 {
+		y += f.left
+	}
+	return y
+}
+
+// crosses reports whether a box extends across a cut
+func (f flow) crosses(b layout.Box) bool {
+	return f.page(b.GetY()+b.GetHeight()-0.02) > f.page(b.GetY())
+}
+
+// fits reports whether a box fits on the page after the flow position y
+// it starts at, so that moving it there keeps it whole
+func (f flow) fits(b layout.Box) bool {
+	return b.GetHeight() <= f.height(f.page(b.GetY())+1)
+}
+
+// gapBefore returns the distance from y down to the next cut, or 0 when y
+// already sits at the top of a page
+func (f flow) gapBefore(y float64) float64 {
+	if y-f.start <= 0.01 {
+		return 0
+	}
+	k := f.page(y)
+	next := f.cut(k + 1)
+	if y-f.cut(k) <= 0.01 || next-y <= 0.01 {
+		return 0
+	}
+	return next - y
+}
+
+// repeatedHeader is a table header repeated at flow position y, where a
+// table continues after a cut
+type repeatedHeader struct {
+	header *layout.BlockBox
+	y      float64
+}
+
+// fragmentFlow prepares a flow of boxes sorted by position for cutting. It
+// returns the first blocks after changes of named page (see pageBreaks) and
+// the table headers to repeat. headers indexes the <thead> boxes of the
+// document; headers are not repeated when it is nil. minRows is the fewest
+// body rows a table keeps with its header at the bottom of a page. The pass
+// stops with the error of ctx once it is done.
+func fragmentFlow(ctx context.Context, boxes []layout.Box, f flow, headers map[*html.Node]*layout.BlockBox, minRows int) (map[*html.Node]string, []repeatedHeader, error) {
+	fr := newFragmenter(f, boxes, findTables(boxes), headers, minRows)
+	for i, b := range boxes {
+		if err := ctx.Err(); err != nil {
+			return nil, nil, err
+		}
+		fr.box(b, boxes[i+1:])
+	}
+	fr.breaks.finish()
+	return fr.breaks.named, fr.repeated, nil
+}
+
+// fragmenter holds the state of the pass down the flow that prepares it for
+// cutting, which meets the boxes one at a time in order of position
+type fragmenter struct {
+	f flow
+	// boxes is the flow, the boxes gaps are opened in
+	boxes   []layout.Box
+	breaks  *pageBreaks
+	tables  map[*html.Node]*layout.BlockBox
+	clones  *clonedBlocks
+	headers map[*html.Node]*layout.BlockBox
+	minRows int
+	// repeated are the table headers to repeat and shown the last page
+	// each table header is on
+	repeated []repeatedHeader
+	shown    map[*html.Node]int
+}
+
+// newFragmenter returns the state of a pass down the flow boxes. tables
+// indexes the <table> boxes of the flow and headers its <thead> boxes, nil
+// when headers are not repeated.
+func newFragmenter(f flow, boxes []layout.Box, tables, headers map[*html.Node]*layout.BlockBox, minRows int) *fragmenter {
+	return &fragmenter{
+		f:       f,
+		boxes:   boxes,
+		breaks:  newPageBreaks(f, boxes),
+		tables:  tables,
+		clones:  &clonedBlocks{f: f, boxes: boxes},
+		headers: headers,
+		minRows: minRows,
+		shown:   make(map[*html.Node]int),
+	}
+}
+
+// setBoxes replaces the flow the pass opens gaps in, for a flow that grows
+// and shrinks as the pass goes down it
+func (fr *fragmenter) setBoxes(boxes []layout.Box) {
+	fr.boxes = boxes
+	fr.breaks.boxes = boxes
+	fr.clones.boxes = boxes
+}
+
+// keepWhole moves an unbreakable box that fits on a page but crosses a cut
+// below the cut
+func (fr *fragmenter) keepWhole(b layout.Box) {
+	if unbreakable(b, fr.f) && fr.f.fits(b) && fr.f.crosses(b) {
+		openGap(fr.boxes, b.GetY(), fr.f.gapBefore(b.GetY()))
+	}
+}
+
+// box prepares the box b met in the flow; after holds the boxes after it
+// in order of position
+func (fr *fragmenter) box(b layout.Box, after []layout.Box) {
+	f := fr.f
+	fr.breaks.reach(b.GetY())
+	bb, ok := b.(*layout.BlockBox)
+	if ok && bb.Node != nil {
+		fr.breaks.block(bb)
+		if strings.EqualFold(bb.Node.Data, "thead") {
+			keepRowsWithHeader(fr.boxes, bb, after, fr.tables, f, fr.minRows)
+		}
+	}
+	fr.keepWhole(b)
+	fr.clones.box(b)
+	if !ok || bb.Node == nil || fr.headers == nil || !strings.EqualFold(bb.Node.Data, "tr") {
+		return
+	}
+	if thead := ancestorWithTag(bb.Node, "thead"); thead != nil {
+		fr.shown[thead] = f.page(bb.Y)
+		return
+	}
+	table := ancestorWithTag(bb.Node, "table")
+	if table == nil || ancestorWithTag(bb.Node, "tfoot") != nil {
+		return
+	}
+	thead := firstChildWithTag(table, "thead")
+	header := fr.headers[thead]
+	last, ok := fr.shown[thead]
+	if header == nil || !ok || last >= f.page(bb.Y) || header.Height > f.height(f.page(bb.Y))/2 {
+		return
+	}
+	// The row is the first of its table on a page without the header
+	fr.repeated = append(fr.repeated, repeatedHeader{header: header, y: bb.Y})
+	openGap(fr.boxes, bb.Y, header.Height)
+	fr.shown[thead] = f.page(bb.Y)
+	fr.keepWhole(b)
+}
+
+// detach drops the children of a box placed on a page
+func detach(b layout.Box) {
+	switch b := b.(type) {
+	case *layout.BlockBox:
+		b.Children = nil
+	case *layout.InlineBox:
+		b.Children = nil
+	}
+}
+
+// unbreakable reports whether a box moves to the next page as a whole
+// rather than being split where the flow is cut: text, images, table rows,
+// inline-blocks, blocks without children and blocks set in a vertical
+// writing mode, whose lines run down the page. Blocks taller than a page do
+// not fit on any and are split like the others.
+func unbreakable(b layout.Box, f flow) bool {
+	bb, ok := b.(*layout.BlockBox)
+	if !ok {
+		return true
+	}
+	if !f.fits(bb) {
+		return false
+	}
+	if len(bb.Children) == 0 || bb.Style.VerticalWritingMode() {
+		return true
+	}
+	if bb.Node != nil && strings.EqualFold(bb.Node.Data, "tr") {
+		return true
+	}
+	return strings.EqualFold(strings.TrimSpace(bb.Style["display"].Value), "inline-block")
+}
+
+// cutPages cuts the prepared flow into pages. boxes are in document order,
+// which is the order they are painted in. Boxes are moved from their flow
+// position into the content area of their page; blocks spanning cuts get a
+// fragment on every page they span, clipped to it. It also returns the
+// running elements anchored on each page.
+//
+// The flow is not used once cut, so a box that lands on a single page is
+// placed on it as is rather than copied; only fragments and repeated table
+// headers are copies. Placed boxes leave their children behind, since those
+// are placed as boxes of their own.
+func (p *Paginator) cutPages(boxes []layout.Box, f flow, repeated []repeatedHeader) ([]*Page, [][]*layout.RunningElement) {
+	var pages []*Page
+	pageAt := func(k int) *Page {
+		for len(pages) <= k {
+			pages = append(pages, p.newPage(len(pages)))
+		}
+		return pages[k]
+	}
+	pageAt(0)
+
+	// Repeated table headers go where the flow made room for them. They are
+	// copied before the boxes of the headers are placed on their first page.
+	type header struct {
+		boxes []layout.Box
+		page  int
+		down  float64
+	}
+	var headers []header
+	for _, r := range repeated {
+		headers = append(headers, header{boxes: copyHeader(r.header), page: f.page(r.y), down: r.y - r.header.Y})
+	}
+
+	// Running elements belong to the page their anchor is on
+	anchors := make(map[layout.Box]*layout.RunningElement)
+	for _, r := range p.RunningElements {
+		anchors[r.Anchor] = r
+	}
+	var running [][]*layout.RunningElement
+
+	for _, b := range boxes {
+		if r, ok := anchors[b]; ok {
+			k := f.page(b.GetY())
+			for len(running) <= k {
+				running = append(running, nil)
+			}
+			running[k] = append(running[k], r)
+			continue
+		}
+		first, last := f.page(b.GetY()), f.page(b.GetY()+b.GetHeight()-0.02)
+		bb, ok := b.(*layout.BlockBox)
+		if !ok || first >= last || unbreakable(b, f) {
+			p.place(pageAt(first), b, f, first, 0)
+			continue
+		}
+		// A block spanning cuts is split into one fragment per page
+		for k := first; k <= last; k++ {
+			page := pageAt(k)
+			page.Boxes = append(page.Boxes, p.fragment(bb, f, k, first, last))
+		}
+	}
+
+	for _, h := range headers {
+		for _, hb := range h.boxes {
+			p.place(pageAt(h.page), hb, f, h.page, h.down)
+		}
+	}
+
+	// Pages only continuing blocks from earlier pages, such as those of a
+	// block taller than a page, are kept like the others. Running elements
+	// are anchored on pages holding content.
+	for len(running) < len(pages) {
+		running = append(running, nil)
+	}
+	return pages, running[:len(pages)]
+}
+
+// newPage returns the empty page k
+func (p *Paginator) newPage(k int) *Page {
+	return &Page{
+		Width:   p.PageSize.Width,
+		Height:  p.PageSize.Height,
+		Boxes:   make([]layout.Box, 0),
+		Margins: p.pageMargins(k),
+	}
+}
+
+// offset returns how far content of page k moves from the flow onto the
+// page. Content keeps the width it was laid out in and moves with the
+// page's left margin.
+func (p *Paginator) offset(f flow, k int) (dx, dy float64) {
+	m := p.pageMargins(k)
+	return m.Left - p.Margins.Left, m.Top - f.cut(k)
+}
+
+// place puts b on page, page k of the flow, moved down by down in the flow
+func (p *Paginator) place(page *Page, b layout.Box, f flow, k int, down float64) {
+	detach(b)
+	dx, dy := p.offset(f, k)
+	b.SetPosition(b.GetX()+dx, b.GetY()+dy+down)
+	page.Boxes = append(page.Boxes, b)
+}
+
+// fragment returns the fragment on page k of the block bb, which spans the
+// pages first to last, clipped to the page
+func (p *Paginator) fragment(bb *layout.BlockBox, f flow, k, first, last int) *layout.BlockBox {
+	top := math.Max(bb.Y, f.cut(k))
+	bottom := math.Min(bb.Y+bb.Height, f.cut(k+1))
+	frag := cloneBox(bb).(*layout.BlockBox)
+	dx, dy := p.offset(f, k)
+	frag.X += dx
+	frag.Y = top + dy
+	frag.Height = bottom - top
+	if k > first {
+		// The marker of a list item is on its first fragment
+		frag.Marker = nil
+	}
+	if !clonesDecorations(bb) {
+		sliceFragment(frag, k > first, k < last)
+	}
+	return frag
+}
+
+// copyHeader returns copies of the boxes of a table header to repeat, flat
+// like boxes placed on a page
+func copyHeader(header *layout.BlockBox) []layout.Box {
+	var boxes []layout.Box
+	collectBoxes(header, &boxes)
+	for i, b := range boxes {
+		boxes[i] = cloneBox(b)
+	}
+	return boxes
+}
@@ -0,0 +1,227 @@
+package pagination
+
+import (
+	"strings"
+
+	"github.com/gompdf/gompdf/internal/layout"
+	"github.com/gompdf/gompdf/internal/parser/html"
+	"github.com/gompdf/gompdf/internal/style"
+)
+
+// PageLabel starts a section of page numbering, such as lower-roman numbers
+// for the front matter and decimal ones restarting at 1 for the body
+type PageLabel struct {
+	// Page is the 1-based index of the page the section starts on. When
+	// Name is set instead, the section starts on every page where the named
+	// page Name begins and lasts as long as it.
+	Page int
+	Name string
+	// Style is the list-style-type numbers are written in: decimal (the
+	// default), lower-roman, upper-roman, lower-alpha, upper-alpha or none
+	Style string
+	// Prefix is written before the number, such as "A-"
+	Prefix string
+	// Start is the number of the section's first page, 1 when 0
+	Start int
+}
+
+// Label returns the label of page number n in the section
+func (l *PageLabel) Label(n int) string {
+	if l == nil {
+		return style.FormatCounter(n, "decimal")
+	}
+	return l.Prefix + style.FormatCounter(n, l.numberStyle())
+}
+
+// numberStyle returns the list-style-type of the section's numbers
+func (l *PageLabel) numberStyle() string {
+	if l.Style == "" {
+		return "decimal"
+	}
+	return l.Style
+}
+
+// Label returns the page label of the page: its number in the style of its
+// numbering section
+func (p *Page) Label() string {
+	return p.Numbering.Label(p.Number)
+}
+
+// pageName returns the value of the page property of a block, "" for auto
+func pageName(b *layout.BlockBox) string {
+	v := strings.TrimSpace(b.Style["page"].Value)
+	if strings.EqualFold(v, "auto") {
+		return ""
+	}
+	return v
+}
+
+// assignPageNames gives every page the named page in effect at its top.
+// named maps the first blocks of content after a change of named page to
+// the new name; a change of name always starts a new page.
+func assignPageNames(pages []*Page, named map[*html.Node]string) {
+	n := &pageNamer{named: named}
+	for _, page := range pages {
+		n.name(page)
+	}
+}
+
+// pageNamer names pages one after the other; see assignPageNames
+type pageNamer struct {
+	named   map[*html.Node]string
+	current string
+}
+
+// name gives the next page the named page in effect at its top
+func (n *pageNamer) name(page *Page) {
+	first := true
+	for _, box := range page.Boxes {
+		name, ok := n.named[box.GetNode()]
+		if box.GetNode() == nil || !ok {
+			continue
+		}
+		if first {
+			page.Name = name
+			first = false
+		}
+		n.current = name
+	}
+	if first {
+		page.Name = n.current
+	}
+}
+
+// numberPages sets the page counter of every page. Sections by page index
+// start where they say; sections of named pages start where their named
+// page begins and, when it ends, the section by index in effect resumes
+// from its start again. Pages outside any section count from 1 in decimal.
+func numberPages(pages []*Page, labels []PageLabel) {
+	n := newPageNumbering(labels)
+	for _, page := range pages {
+		n.number(page)
+	}
+}
+
+// pageNumbering numbers pages one after the other; see numberPages
+type pageNumbering struct {
+	byIndex map[int]*PageLabel
+	byName  map[string]*PageLabel
+	// base is the section by index in effect and section the one the last
+	// page numbered is in; page is the count of pages numbered
+	base, section *PageLabel
+	value         int
+	page          int
+	prevName      string
+}
+
+// newPageNumbering returns the numbering of pages in the sections labels
+func newPageNumbering(labels []PageLabel) *pageNumbering {
+	n := &pageNumbering{
+		byIndex: make(map[int]*PageLabel),
+		byName:  make(map[string]*PageLabel),
+	}
+	for i := range labels {
+		l := &labels[i]
+		switch {
+		case l.Name != "":
+			n.byName[l.Name] = l
+		case l.Page > 0:
+			n.byIndex[l.Page] = l
+		}
+	}
+	return n
+}
+
+// start starts the section l on the next page
+func (n *pageNumbering) start(l *PageLabel) {
+	n.section = l
+	n.value = 1
+	if l != nil && l.Start != 0 {
+		n.value = l.Start
+	}
+}
+
+// number sets the page counter of the next page, named already
+func (n *pageNumbering) number(page *Page) {
+	l, ok := n.byIndex[n.page+1]
+	switch {
+	case ok:
+		n.base = l
+		n.start(l)
+	case page.Name != n.prevName && n.byName[page.Name] != nil:
+		n.start(n.byName[page.Name])
+	case page.Name != n.prevName && n.byName[n.prevName] != nil:
+		n.start(n.base)
+	case n.page == 0:
+		n.start(nil)
+	default:
+		n.value++
+	}
+	page.Number = n.value
+	page.Numbering = n.section
+	n.prevName = page.Name
+	n.page++
+}
+
+// ContinueNumbering numbers the pages of a document appended to the pages
+// of documents before it. Its pages before its first section of numbering
+// continue the numbering of the last page before them instead of counting
+// from 1 again.
+func ContinueNumbering(before, pages []*Page) {
+	if len(before) == 0 {
+		return
+	}
+	last := before[len(before)-1]
+	for i, page := range pages {
+		if page.Numbering != nil {
+			return
+		}
+		page.Number = last.Number + i + 1
+		page.Numbering = last.Numbering
+	}
+}
+
+// Targets maps the id of every element of the pages to the first page its
+// content appears on, for cross references such as target-counter()
+func Targets(pages []*Page) map[string]*Page {
+	targets := make(map[string]*Page)
+	for _, page := range pages {
+		for _, box := range page.Boxes {
+			node := box.GetNode()
+			if node == nil {
+				continue
+			}
+			for _, a := range node.Attr {
+				if strings.EqualFold(a.Key, "id") && a.Val != "" {
+					if _, ok := targets[a.Val]; !ok {
+						targets[a.Val] = page
+					}
+				}
+			}
+		}
+	}
+	return targets
+}
+
+// PageCounter returns the value of the page or pages counter on page, of
+// pageCount pages, or of the page counter of the target of a
+// target-counter() named "#" followed by its id. Page counters without a
+// list style give the page label.
+func PageCounter(page *Page, pageCount int, targets map[string]*Page, name, listStyle string) string {
+	if name == "pages" {
+		if listStyle == "" {
+			listStyle = "decimal"
+		}
+		return style.FormatCounter(pageCount, listStyle)
+	}
+	if strings.HasPrefix(name, "#") {
+		page = targets[name[1:]]
+	}
+	if page == nil {
+		return ""
+	}
+	if listStyle == "" {
+		return page.Label()
+	}
+	return style.FormatCounter(page.Number, listStyle)
+}
@@ -0,0 +1,199 @@
+package pagination
+
+import (
+	"strings"
+
+	"github.com/gompdf/gompdf/internal/layout"
+)
+
+// MarginBox is a box in the page margins that shows running elements, from
+// a margin box rule such as @top-center { content: element(chapter) } in an
+// @page rule
+type MarginBox struct {
+	// Position is top-left, top-center, top-right, bottom-left,
+	// bottom-center or bottom-right
+	Position string
+	// Pages selects the pages the box is on: "" for every page, or first,
+	// left or right. Boxes for first pages win over those for left or right
+	// pages, which win over those for every page; among equals the last
+	// one wins.
+	Pages string
+	// Element is the name of the running elements shown, "" for none, and
+	// Policy which of them: first (the default), start, last or
+	// first-except, as for element(name, policy), or all-pages, which is
+	// first except that pages before the first element show it too
+	Element string
+	Policy  string
+}
+
+// pageSelectorRank ranks the page selectors of margin boxes by specificity
+// and reports whether one applies to page k
+func pageSelectorRank(pages string, k int) (int, bool) {
+	switch pages {
+	case "":
+		return 0, true
+	case "left":
+		return 1, k%2 == 1
+	case "right":
+		return 1, k%2 == 0
+	case "first":
+		return 2, k == 0
+	}
+	return 0, false
+}
+
+// marginBoxes returns the margin boxes of page k by position
+func (p *Paginator) marginBoxes(k int) map[string]MarginBox {
+	boxes := make(map[string]MarginBox)
+	ranks := make(map[string]int)
+	for _, mb := range p.MarginBoxes {
+		rank, ok := pageSelectorRank(mb.Pages, k)
+		if !ok {
+			continue
+		}
+		if r, seen := ranks[mb.Position]; seen && r > rank {
+			continue
+		}
+		boxes[mb.Position], ranks[mb.Position] = mb, rank
+	}
+	return boxes
+}
+
+// placeRunningElements puts the running elements that margin boxes show on
+// every page. running holds the elements anchored on each page. A box
+// shows the element its policy picks among those anchored on the page, or
+// else the last one anchored on an earlier page.
+func (p *Paginator) placeRunningElements(pages []*Page, running [][]*layout.RunningElement) {
+	rp := newRunningPlacer(p)
+	for _, here := range running {
+		rp.anchored(here)
+	}
+	for k, page := range pages {
+		var here []*layout.RunningElement
+		if k < len(running) {
+			here = running[k]
+		}
+		rp.place(page, k, here)
+	}
+}
+
+// runningPlacer puts running elements on pages one after the other; see
+// placeRunningElements
+type runningPlacer struct {
+	p *Paginator
+	// last is the last running element of each name so far and first the
+	// first one of the document, which all-pages boxes show before it
+	last  map[string]*layout.RunningElement
+	first map[string]*layout.RunningElement
+}
+
+func newRunningPlacer(p *Paginator) *runningPlacer {
+	return &runningPlacer{
+		p:     p,
+		last:  make(map[string]*layout.RunningElement),
+		first: make(map[string]*layout.RunningElement),
+	}
+}
+
+// anchored records running elements anchored in the document, in document
+// order. All of those of a name before the page showing it must be.
+func (rp *runningPlacer) anchored(running []*layout.RunningElement) {
+	for _, r := range running {
+		if rp.first[r.Name] == nil {
+			rp.first[r.Name] = r
+		}
+	}
+}
+
+// waits reports whether the next page k may show a running element not
+// anchored yet: the first one of a name an all-pages margin box shows
+func (rp *runningPlacer) waits(k int) bool {
+	for _, mb := range rp.p.marginBoxes(k) {
+		if mb.Element != "" && strings.EqualFold(mb.Policy, "all-pages") && rp.first[mb.Element] == nil {
+			return true
+		}
+	}
+	return false
+}
+
+// place puts the running elements margin boxes show on the next page, page
+// k, on which the elements here are anchored
+func (rp *runningPlacer) place(page *Page, k int, here []*layout.RunningElement) {
+	for _, mb := range rp.p.marginBoxes(k) {
+		if mb.Element == "" {
+			continue
+		}
+		var onPage []*layout.RunningElement
+		for _, r := range here {
+			if r.Name == mb.Element {
+				onPage = append(onPage, r)
+			}
+		}
+		r := pickRunning(onPage, rp.last[mb.Element], mb.Policy)
+		if r == nil && strings.EqualFold(mb.Policy, "all-pages") {
+			r = rp.first[mb.Element]
+		}
+		if r != nil {
+			placeInMargin(page, r.Box, mb.Position, rp.p.Margins.Left)
+		}
+	}
+	for _, r := range here {
+		rp.last[r.Name] = r
+	}
+}
+
+// pickRunning returns the running element a policy of element() picks among
+// those anchored on a page, given the one in effect before it
+func pickRunning(onPage []*layout.RunningElement, before *layout.RunningElement, policy string) *layout.RunningElement {
+	switch strings.ToLower(policy) {
+	case "start":
+		if before != nil || len(onPage) == 0 {
+			return before
+		}
+		return onPage[0]
+	case "last":
+		if len(onPage) > 0 {
+			return onPage[len(onPage)-1]
+		}
+	case "first-except":
+		if len(onPage) > 0 {
+			return nil
+		}
+	default:
+		if len(onPage) > 0 {
+			return onPage[0]
+		}
+	}
+	return before
+}
+
+// placeInMargin adds a copy of a running element's boxes to a page, aligned
+// in the content width of the page by the margin box position and centered
+// vertically in the top or bottom margin. The element was laid out at the
+// left edge of the content area for margins whose left one is layoutLeft.
+func placeInMargin(page *Page, box layout.Box, position string, layoutLeft float64) {
+	m := page.Margins
+	width := box.GetMarginLeft() + box.GetWidth() + box.GetMarginRight()
+	dx := m.Left - layoutLeft
+	area := page.Width - m.Left - m.Right
+	switch {
+	case strings.HasSuffix(position, "-center"):
+		dx += (area - width) / 2
+	case strings.HasSuffix(position, "-right"):
+		dx += area - width
+	}
+	top := (m.Top - box.GetHeight()) / 2
+	if strings.HasPrefix(position, "bottom-") {
+		top = page.Height - m.Bottom + (m.Bottom-box.GetHeight())/2
+	}
+	dy := top - box.GetY()
+
+	var boxes []layout.Box
+	collectBoxes(box, &boxes)
+	for _, b := range boxes {
+		clone := cloneBox(b)
+		clone.SetPosition(clone.GetX()+dx, clone.GetY()+dy)
+		page.Boxes = append(page.Boxes, clone)
+	}
+}
+
@@ -0,0 +1,357 @@
+package pagination
+
+import (
+	"math"
+	"slices"
+	"strings"
+
+	"github.com/gompdf/gompdf/internal/layout"
+	"github.com/gompdf/gompdf/internal/parser/html"
+)
+
+// A Stream paginates a layout while it is laid out. It receives the boxes
+// of the flow as layout finishes with them (see layout.Sink) and prepares
+// and cuts the flow as Paginate does, a few pages behind layout: a box is
+// met once the boxes a page below it have been laid out, which is as far
+// as the rules preparing the flow look ahead, and a page is cut once the
+// boxes a page below its bottom have been met, as high up as those rules
+// move content. Pages are emitted as they are cut, so that only the boxes
+// of a few pages are held at any time.
+//
+// The blocks layout opens, those whose content it hands over in pieces,
+// are stood for by proxies: copies that take part in the flow with a
+// placeholder child until they are closed and their height is known. The
+// gaps opened in the flow for page breaks move the content to come as
+// well, which edge, a marker below the content so far, keeps count of.
+type Stream struct {
+	p       *Paginator
+	f       flow
+	running func() []*layout.RunningElement
+	emit    func(*Page) error
+	err     error
+	// look is how far ahead of a box the flow is prepared
+	look float64
+	fr   *fragmenter
+	// window holds the boxes not placed yet in document order, queue
+	// those the pass down the flow has not met and met the position of
+	// the last one it did
+	window []layout.Box
+	queue  []layout.Box
+	met    float64
+	// edge is the marker below the content so far and raw where it is in
+	// the coordinates of layout
+	edge *layout.BlockBox
+	raw  float64
+	// proxies maps the open blocks to their proxies, and copies the table
+	// headers to copies of their boxes taken when they were handed over
+	proxies map[*layout.BlockBox]*layout.BlockBox
+	copies  map[*layout.BlockBox]headerCopy
+	// anchors maps the anchors of the running elements laid out so far,
+	// seen of them, to the elements
+	anchors map[layout.Box]*layout.RunningElement
+	seen    int
+	// pages is the count of pages cut and ready those cut but not emitted
+	pages     int
+	ready     []readyPage
+	namer     *pageNamer
+	numbering *pageNumbering
+	runs      *runningPlacer
+}
+
+// openHeight is the height of the proxy of a block until it is closed,
+// which reaches below any content
+const openHeight = 1e12
+
+// headerCopy holds copies of the boxes of a table header for repeating it,
+// taken when the header was at flow position y
+type headerCopy struct {
+	boxes []layout.Box
+	y     float64
+}
+
+// readyPage is page k, cut, with the running elements anchored on it
+type readyPage struct {
+	page *Page
+	k    int
+	here []*layout.RunningElement
+}
+
+// NewStream returns a stream that paginates a layout with the engine's
+// options as it is laid out and passes every page to emit as soon as it is
+// complete. running returns the running elements laid out so far, in
+// document order. The stream is the sink of the layout; Finish emits the
+// remaining pages once layout is done.
+func (e *Engine) NewStream(running func() []*layout.RunningElement, emit func(*Page) error) *Stream {
+	p := e.paginator()
+	f := p.flow(e.options.MarginTop)
+	var headers map[*html.Node]*layout.BlockBox
+	if p.RepeatTableHeaders {
+		headers = make(map[*html.Node]*layout.BlockBox)
+	}
+	s := &Stream{
+		p:         p,
+		f:         f,
+		running:   running,
+		emit:      emit,
+		look:      math.Max(f.first, math.Max(f.left, f.right)),
+		fr:        newFragmenter(f, nil, make(map[*html.Node]*layout.BlockBox), headers, p.MinTableRows),
+		met:       math.Inf(-1),
+		edge:      &layout.BlockBox{Y: f.start},
+		raw:       f.start,
+		proxies:   make(map[*layout.BlockBox]*layout.BlockBox),
+		copies:    make(map[*layout.BlockBox]headerCopy),
+		anchors:   make(map[layout.Box]*layout.RunningElement),
+		namer:     &pageNamer{},
+		numbering: newPageNumbering(e.options.PageLabels),
+		runs:      newRunningPlacer(p),
+	}
+	s.window = []layout.Box{s.edge}
+	s.namer.named = s.fr.breaks.named
+	return s
+}
+
+// Open adds the proxy of a block whose content follows to the flow
+func (s *Stream) Open(b *layout.BlockBox) {
+	if s.err != nil {
+		return
+	}
+	proxy := cloneBox(b).(*layout.BlockBox)
+	proxy.Children = []layout.Box{&layout.BlockBox{}}
+	proxy.Y += s.shift()
+	// The height is unknown until the block is closed
+	proxy.Height = openHeight
+	s.proxies[b] = proxy
+	s.add(proxy)
+	s.advance(b.Y)
+}
+
+// Add adds a box laid out in full with its descendants to the flow
+func (s *Stream) Add(b layout.Box) {
+	if s.err != nil {
+		return
+	}
+	var boxes []layout.Box
+	collectBoxes(b, &boxes)
+	dy := s.shift()
+	for _, x := range boxes {
+		x.SetPosition(x.GetX(), x.GetY()+dy)
+	}
+	for _, x := range boxes {
+		s.add(x)
+	}
+	s.advance(b.GetY() - dy + b.GetHeight())
+}
+
+// Close gives the proxy of a block laid out in full its height
+func (s *Stream) Close(b *layout.BlockBox) {
+	proxy := s.proxies[b]
+	if s.err != nil || proxy == nil {
+		return
+	}
+	delete(s.proxies, b)
+	proxy.Height = b.Y + b.Height + s.shift() - proxy.Y
+	proxy.MarginBottom = b.MarginBottom
+	s.advance(b.Y + b.Height)
+}
+
+// Err returns the error that ended the stream: that of emitting a page, of
+// the context of the engine or one wrapping ErrTooManyPages
+func (s *Stream) Err() error {
+	return s.err
+}
+
+// Finish cuts the rest of the flow into pages and emits them once layout
+// is done. A document without content has one page.
+func (s *Stream) Finish() error {
+	if s.err != nil {
+		return s.err
+	}
+	s.sync()
+	for i, b := range s.window {
+		if b == s.edge {
+			s.window = append(s.window[:i], s.window[i+1:]...)
+			break
+		}
+	}
+	s.process(math.Inf(1))
+	s.fr.breaks.finish()
+	for s.err == nil && (s.pending() || s.pages == 0) {
+		s.cut()
+	}
+	s.release(true)
+	return s.err
+}
+
+// shift returns how far the content to come moves down in the flow
+func (s *Stream) shift() float64 {
+	return s.edge.Y - s.raw
+}
+
+// add puts a box in the flow
+func (s *Stream) add(b layout.Box) {
+	s.window = append(s.window, b)
+	s.queue = append(s.queue, b)
+	bb, ok := b.(*layout.BlockBox)
+	if !ok || bb.Node == nil {
+		return
+	}
+	switch strings.ToLower(bb.Node.Data) {
+	case "table":
+		s.fr.tables[bb.Node] = bb
+	case "thead":
+		if s.fr.headers != nil {
+			s.fr.headers[bb.Node] = bb
+			s.copies[bb] = headerCopy{boxes: copyHeader(bb), y: bb.Y}
+		}
+	}
+}
+
+// advance moves the edge down to y in the coordinates of layout, where
+// the content laid out so far ends, and paginates as far as it can
+func (s *Stream) advance(y float64) {
+	if y > s.raw {
+		s.edge.Y += y - s.raw
+		s.raw = y
+	}
+	s.sync()
+	s.process(s.edge.Y - s.look)
+	for s.err == nil && s.f.cut(s.pages+1)+s.look <= s.met {
+		s.cut()
+	}
+	s.release(false)
+}
+
+// sync takes in the running elements laid out since it was last called
+func (s *Stream) sync() {
+	if s.running == nil {
+		return
+	}
+	running := s.running()
+	for _, r := range running[s.seen:] {
+		s.anchors[r.Anchor] = r
+	}
+	s.runs.anchored(running[s.seen:])
+	s.seen = len(running)
+}
+
+// process has the pass down the flow meet the boxes above limit
+func (s *Stream) process(limit float64) {
+	if s.err != nil || !slices.ContainsFunc(s.queue, func(b layout.Box) bool { return b.GetY() < limit }) {
+		return
+	}
+	sortBoxesByPosition(s.queue)
+	n := len(s.queue)
+	for i, b := range s.queue {
+		if b.GetY() >= limit {
+			n = i
+			break
+		}
+	}
+	s.fr.setBoxes(s.window)
+	for i, b := range s.queue[:n] {
+		if s.p.ctx != nil {
+			if s.err = s.p.ctx.Err(); s.err != nil {
+				return
+			}
+		}
+		s.fr.box(b, s.queue[i+1:])
+		s.met = math.Max(s.met, b.GetY())
+	}
+	s.queue = append([]layout.Box(nil), s.queue[n:]...)
+}
+
+// pending reports whether content is left to put on pages
+func (s *Stream) pending() bool {
+	if len(s.fr.repeated) > 0 {
+		return true
+	}
+	for _, b := range s.window {
+		if _, ok := s.anchors[b]; !ok && b != s.edge {
+			return true
+		}
+	}
+	return false
+}
+
+// cut cuts the next page: it places the boxes on it, as cutPages does, and
+// queues the page for emitting
+func (s *Stream) cut() {
+	if s.err = s.p.checkPageCount(s.pages + 1); s.err != nil {
+		return
+	}
+	f, k := s.f, s.pages
+	page := s.p.newPage(k)
+	var here []*layout.RunningElement
+	var done []*layout.BlockBox
+	kept := s.window[:0]
+	for _, b := range s.window {
+		first, last := f.page(b.GetY()), f.page(b.GetY()+b.GetHeight()-0.02)
+		if b == s.edge || first > k {
+			kept = append(kept, b)
+			continue
+		}
+		if r, ok := s.anchors[b]; ok {
+			here = append(here, r)
+			delete(s.anchors, b)
+			continue
+		}
+		bb, ok := b.(*layout.BlockBox)
+		if !ok || first >= last || unbreakable(b, f) {
+			s.p.place(page, b, f, k, 0)
+		} else {
+			page.Boxes = append(page.Boxes, s.p.fragment(bb, f, k, first, last))
+			if k < last {
+				kept = append(kept, b)
+				continue
+			}
+		}
+		if ok && bb.Node != nil && strings.EqualFold(bb.Node.Data, "table") {
+			done = append(done, bb)
+		}
+	}
+	clear(s.window[len(kept):])
+	s.window = kept
+
+	repeated := s.fr.repeated[:0]
+	for _, r := range s.fr.repeated {
+		if f.page(r.y) > k {
+			repeated = append(repeated, r)
+			continue
+		}
+		c := s.copies[r.header]
+		for _, hb := range c.boxes {
+			s.p.place(page, cloneBox(hb), f, k, r.y-c.y)
+		}
+	}
+	s.fr.repeated = repeated
+
+	// Tables off the flow take what was kept about them along
+	for _, t := range done {
+		delete(s.fr.tables, t.Node)
+		if thead := firstChildWithTag(t.Node, "thead"); thead != nil {
+			delete(s.copies, s.fr.headers[thead])
+			delete(s.fr.headers, thead)
+			delete(s.fr.shown, thead)
+		}
+	}
+
+	s.namer.name(page)
+	s.numbering.number(page)
+	s.ready = append(s.ready, readyPage{page: page, k: k, here: here})
+	s.pages++
+}
+
+// release emits the pages cut, in order. A page waits while it may show a
+// running element not laid out yet, unless layout is done.
+func (s *Stream) release(final bool) {
+	for len(s.ready) > 0 && s.err == nil {
+		r := s.ready[0]
+		if !final && s.runs.waits(r.k) {
+			return
+		}
+		s.ready[0] = readyPage{}
+		s.ready = s.ready[1:]
+		s.runs.place(r.page, r.k, r.here)
+		s.err = s.emit(r.page)
+	}
+}
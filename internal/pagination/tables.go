@@ -0,0 +1,94 @@
+package pagination
+
+import (
+	"strings"
+
+	"github.com/gompdf/gompdf/internal/layout"
+	"github.com/gompdf/gompdf/internal/parser/html"
+	xhtml "golang.org/x/net/html"
+)
+
+// findTableHeaders indexes the laid out <thead> boxes of the document by node
+func findTableHeaders(b layout.Box, out map[*html.Node]*layout.BlockBox) {
+	bb, ok := b.(*layout.BlockBox)
+	if !ok || bb == nil {
+		return
+	}
+	if bb.Node != nil && strings.EqualFold(bb.Node.Data, "thead") {
+		out[bb.Node] = bb
+		return
+	}
+	for _, ch := range bb.Children {
+		findTableHeaders(ch, out)
+	}
+}
+
+// findTables indexes the <table> boxes among boxes by node
+func findTables(boxes []layout.Box) map[*html.Node]*layout.BlockBox {
+	tables := make(map[*html.Node]*layout.BlockBox)
+	for _, b := range boxes {
+		if bb, ok := b.(*layout.BlockBox); ok && bb.Node != nil && strings.EqualFold(bb.Node.Data, "table") {
+			tables[bb.Node] = bb
+		}
+	}
+	return tables
+}
+
+// keepRowsWithHeader moves a table to the next page when the cut after its
+// header thead comes before minRows of its body rows, so that the header
+// does not end a page alone or with too few rows. after holds the boxes
+// after the header in the flow boxes. Tables that would not fit on a page
+// with their header and those rows stay, as do tables with fewer rows,
+// which then go with all of them.
+func keepRowsWithHeader(boxes []layout.Box, thead *layout.BlockBox, after []layout.Box, tables map[*html.Node]*layout.BlockBox, f flow, minRows int) {
+	table := tables[ancestorWithTag(thead.Node, "table")]
+	if minRows <= 0 || table == nil {
+		return
+	}
+	var last layout.Box
+	n := 0
+	for _, b := range after {
+		if b.GetY() >= table.Y+table.Height-0.01 {
+			break
+		}
+		bb, ok := b.(*layout.BlockBox)
+		if !ok || bb.Node == nil || !strings.EqualFold(bb.Node.Data, "tr") || ancestorWithTag(bb.Node, "table") != table.Node {
+			continue
+		}
+		if section := bb.Node.Parent; section != nil && (strings.EqualFold(section.Data, "thead") || strings.EqualFold(section.Data, "tfoot")) {
+			continue
+		}
+		last, n = bb, n+1
+		if n == minRows {
+			break
+		}
+	}
+	if last == nil {
+		return
+	}
+	top, bottom := table.Y, last.GetY()+last.GetHeight()
+	if f.page(bottom-0.02) == f.page(top) || bottom-top > f.height(f.page(top)+1) {
+		return
+	}
+	openGap(boxes, top, f.gapBefore(top))
+}
+
+// ancestorWithTag returns the closest ancestor-or-self element with the tag
+func ancestorWithTag(n *html.Node, tag string) *html.Node {
+	for cur := n; cur != nil; cur = cur.Parent {
+		if cur.Type == xhtml.ElementNode && strings.EqualFold(cur.Data, tag) {
+			return cur
+		}
+	}
+	return nil
+}
+
+// firstChildWithTag returns the first direct child element with the tag
+func firstChildWithTag(n *html.Node, tag string) *html.Node {
+	for c := n.FirstChild; c != nil; c = c.NextSibling {
+		if c.Type == xhtml.ElementNode && strings.EqualFold(c.Data, tag) {
+			return c
+		}
+	}
+	return nil
+}
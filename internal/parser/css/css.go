@@ -2,6 +2,7 @@ package css
 
 import (
 	"errors"
+	"fmt"
 	"io"
 	"strings"
 )
@@ -15,6 +16,12 @@ type Parser struct {
 type Rule struct {
 	Selectors    []string
 	Declarations []*Declaration
+	// Media lists the media query lists of the @media blocks the rule is
+	// nested in, outermost first; the rule applies only when all match
+	Media []string
+	// Nested holds the at-rules nested in the block of an at-rule, such as
+	// the margin boxes of an @page rule
+	Nested []*Rule
 }
 
 // Declaration represents a CSS declaration (property-value pair)
@@ -29,6 +36,46 @@ type Stylesheet struct {
 	Rules []*Rule
 }
 
+// Import represents an @import rule
+type Import struct {
+	// URL is the imported stylesheet as written, relative to the importing one
+	URL string
+	// Media is the media query list the import is conditional on, or ""
+	Media string
+}
+
+// FontFace represents an @font-face rule
+type FontFace struct {
+	Family string
+	Weight string
+	Style  string
+	// Sources lists the url() entries of the src descriptor in order of preference
+	Sources []string
+}
+
+// PageRule represents an @page rule
+type PageRule struct {
+	// Name is the named page the rule applies to, "" for every page
+	Name string
+	// Pseudo lists the page pseudo-classes of the selector, such as first,
+	// left or right
+	Pseudo       []string
+	Declarations []*Declaration
+	// MarginBoxes are the margin box rules of the page, such as @top-center,
+	// in source order
+	MarginBoxes []*MarginBox
+}
+
+// MarginBox represents a margin box rule inside an @page rule
+type MarginBox struct {
+	// Name is the margin box without the @, such as top-center
+	Name         string
+	Declarations []*Declaration
+}
+
+// ErrSyntax is the error, possibly wrapped, of malformed CSS
+var ErrSyntax = errors.New("invalid CSS")
+
 // NewParser creates a new CSS parser
 func NewParser() *Parser {
 	return &Parser{}
@@ -58,6 +105,14 @@ func (p *Parser) parseCSS(content string) (*Stylesheet, error) {
 	ruleStrings := splitRules(content)
 
 	for _, ruleStr := range ruleStrings {
+		if media, body, ok := parseMediaBlock(ruleStr); ok {
+			nested, _ := p.parseCSS(body)
+			for _, rule := range nested.Rules {
+				rule.Media = append([]string{media}, rule.Media...)
+				stylesheet.Rules = append(stylesheet.Rules, rule)
+			}
+			continue
+		}
 		rule, err := p.parseRule(ruleStr)
 		if err != nil {
 			continue // Skip invalid rules
@@ -68,11 +123,219 @@ func (p *Parser) parseCSS(content string) (*Stylesheet, error) {
 	return stylesheet, nil
 }
 
+// SplitImports separates the @import rules at the start of a stylesheet from
+// the rest of it. As in browsers, @import rules after any other rule are
+// ignored; they stay in rest, where the parser skips them.
+func SplitImports(content string) ([]Import, string) {
+	content = removeComments(content)
+	var imports []Import
+	rest := strings.TrimSpace(content)
+	for {
+		if len(rest) >= 8 && strings.EqualFold(rest[:8], "@charset") {
+			if end := strings.IndexByte(rest, ';'); end >= 0 {
+				rest = strings.TrimSpace(rest[end+1:])
+				continue
+			}
+		}
+		if len(rest) < 7 || !strings.EqualFold(rest[:7], "@import") {
+			return imports, rest
+		}
+		end := len(rest)
+		if parts := splitOutsideParens(rest, ';'); len(parts) > 1 {
+			end = len(parts[0])
+		}
+		if imp, ok := parseImport(rest[7:end]); ok {
+			imports = append(imports, imp)
+		}
+		if end < len(rest) {
+			end++
+		}
+		rest = strings.TrimSpace(rest[end:])
+	}
+}
+
+// ResolveURLs returns content with the reference of every url() replaced by
+// what resolve returns for it, such as the reference resolved against the
+// location of the stylesheet. Strings and comments are left as they are.
+func ResolveURLs(content string, resolve func(ref string) string) string {
+	var b strings.Builder
+	last := 0
+	for i := 0; i < len(content); {
+		switch c := content[i]; {
+		case c == '/' && strings.HasPrefix(content[i:], "/*"):
+			end := strings.Index(content[i+2:], "*/")
+			if end < 0 {
+				i = len(content)
+			} else {
+				i += end + 4
+			}
+		case c == '"' || c == '\'':
+			i = skipString(content, i)
+		case (c == 'u' || c == 'U') && len(content)-i > 4 && strings.EqualFold(content[i:i+4], "url(") &&
+			(i == 0 || !isNameChar(content[i-1])):
+			ref, end, ok := parseURLToken(content, i+4)
+			if !ok {
+				i += 4
+				continue
+			}
+			resolved := resolve(ref)
+			if resolved != ref {
+				quote := `"`
+				if strings.Contains(resolved, quote) {
+					quote = "'"
+				}
+				b.WriteString(content[last:i])
+				b.WriteString("url(" + quote + resolved + quote + ")")
+				last = end
+			}
+			i = end
+		default:
+			i++
+		}
+	}
+	if last == 0 {
+		return content
+	}
+	b.WriteString(content[last:])
+	return b.String()
+}
+
+// skipString returns the index after the string starting with the quote at
+// content[i], or the end of content when it is not closed
+func skipString(content string, i int) int {
+	quote := content[i]
+	for j := i + 1; j < len(content); j++ {
+		switch content[j] {
+		case '\\':
+			j++
+		case quote, '\n':
+			return j + 1
+		}
+	}
+	return len(content)
+}
+
+// parseURLToken parses the reference of a url() whose opening parenthesis
+// ends before content[start], returning it and the index after the closing
+// parenthesis
+func parseURLToken(content string, start int) (string, int, bool) {
+	i := start
+	for i < len(content) && isSpace(content[i]) {
+		i++
+	}
+	var ref string
+	if i < len(content) && (content[i] == '"' || content[i] == '\'') {
+		end := skipString(content, i)
+		if end > len(content) || content[end-1] != content[i] {
+			return "", 0, false
+		}
+		ref = content[i+1 : end-1]
+		i = end
+		for i < len(content) && isSpace(content[i]) {
+			i++
+		}
+		if i >= len(content) || content[i] != ')' {
+			return "", 0, false
+		}
+	} else {
+		end := strings.IndexByte(content[i:], ')')
+		if end < 0 {
+			return "", 0, false
+		}
+		ref = strings.TrimSpace(content[i : i+end])
+		i += end
+	}
+	return ref, i + 1, true
+}
+
+// isSpace reports whether c is CSS whitespace
+func isSpace(c byte) bool {
+	return c == ' ' || c == '\t' || c == '\n' || c == '\r' || c == '\f'
+}
+
+// isNameChar reports whether c can be part of a CSS identifier, so that
+// "url(" after it is not the start of a url()
+func isNameChar(c byte) bool {
+	return c == '-' || c == '_' || c >= '0' && c <= '9' || c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= 0x80
+}
+
+// parseImport parses the prelude of an @import rule: a string or url()
+// followed by optional layer(), supports() and a media query list
+func parseImport(prelude string) (Import, bool) {
+	var imp Import
+	p := strings.TrimSpace(prelude)
+	switch {
+	case strings.HasPrefix(p, "\"") || strings.HasPrefix(p, "'"):
+		end := strings.IndexByte(p[1:], p[0])
+		if end < 0 {
+			return imp, false
+		}
+		imp.URL, p = p[1:end+1], p[end+2:]
+	case len(p) > 4 && strings.EqualFold(p[:4], "url("):
+		end := strings.IndexByte(p, ')')
+		if end < 0 {
+			return imp, false
+		}
+		imp.URL = strings.Trim(strings.TrimSpace(p[4:end]), "\"'")
+		p = p[end+1:]
+	default:
+		return imp, false
+	}
+	p = strings.TrimSpace(p)
+	// Cascade layers and feature queries are not supported; the sheet is
+	// imported unconditionally as far as they are concerned
+	for _, fn := range []string{"layer", "supports("} {
+		if len(p) >= len(fn) && strings.EqualFold(p[:len(fn)], fn) {
+			end := len(fn)
+			if strings.HasSuffix(fn, "(") || len(p) > end && p[end] == '(' {
+				if i := closingParen(p); i >= 0 {
+					end = i + 1
+				}
+			}
+			p = strings.TrimSpace(p[end:])
+		}
+	}
+	imp.Media = p
+	return imp, imp.URL != ""
+}
+
+// closingParen returns the index of the parenthesis closing the first
+// opening one in s, or -1
+func closingParen(s string) int {
+	depth := 0
+	for i := 0; i < len(s); i++ {
+		switch s[i] {
+		case '(':
+			depth++
+		case ')':
+			depth--
+			if depth == 0 {
+				return i
+			}
+		}
+	}
+	return -1
+}
+
+// parseMediaBlock splits an @media block into its media query list and the
+// rules inside it
+func parseMediaBlock(ruleStr string) (string, string, bool) {
+	if len(ruleStr) < 6 || !strings.EqualFold(ruleStr[:6], "@media") {
+		return "", "", false
+	}
+	open := strings.IndexByte(ruleStr, '{')
+	end := strings.LastIndexByte(ruleStr, '}')
+	if open < 0 || end < open {
+		return "", "", false
+	}
+	return strings.TrimSpace(ruleStr[6:open]), ruleStr[open+1 : end], true
+}
+
 // parseRule parses a single CSS rule
 func (p *Parser) parseRule(ruleStr string) (*Rule, error) {
 	parts := strings.SplitN(ruleStr, "{", 2)
 	if len(parts) != 2 {
-		return nil, errors.New("invalid rule format")
+		return nil, fmt.Errorf("%w: invalid rule format", ErrSyntax)
 	}
 
 	selectorStr := strings.TrimSpace(parts[0])
@@ -82,7 +345,18 @@ func (p *Parser) parseRule(ruleStr string) (*Rule, error) {
 
 	selectors := parseSelectors(selectorStr)
 	if len(selectors) == 0 {
-		return nil, errors.New("no selectors found")
+		return nil, fmt.Errorf("%w: no selectors found", ErrSyntax)
+	}
+
+	var nested []*Rule
+	if strings.HasPrefix(selectorStr, "@") {
+		var blocks []string
+		declarationsStr, blocks = splitNestedRules(declarationsStr)
+		for _, block := range blocks {
+			if rule, err := p.parseRule(block); err == nil {
+				nested = append(nested, rule)
+			}
+		}
 	}
 
 	declarations := parseDeclarations(declarationsStr)
@@ -90,12 +364,56 @@ func (p *Parser) parseRule(ruleStr string) (*Rule, error) {
 	return &Rule{
 		Selectors:    selectors,
 		Declarations: declarations,
+		Nested:       nested,
 	}, nil
 }
 
-// parseSelectors parses CSS selectors
+// splitNestedRules separates the at-rules nested in the block of an at-rule,
+// such as @top-center { ... } in @page, from its declarations
+func splitNestedRules(body string) (string, []string) {
+	var decls strings.Builder
+	var rules []string
+	var quote byte
+	for i := 0; i < len(body); i++ {
+		c := body[i]
+		switch {
+		case quote != 0:
+			if c == quote {
+				quote = 0
+			}
+		case c == '"' || c == '\'':
+			quote = c
+		case c == '@':
+			open := strings.IndexByte(body[i:], '{')
+			if open < 0 {
+				break
+			}
+			depth, end := 0, len(body)
+			for j := i + open; j < len(body); j++ {
+				if body[j] == '{' {
+					depth++
+				} else if body[j] == '}' {
+					if depth--; depth == 0 {
+						end = j + 1
+						break
+					}
+				}
+			}
+			rules = append(rules, body[i:end])
+			// The nested rule also ends the declaration before it
+			decls.WriteByte(';')
+			i = end - 1
+			continue
+		}
+		decls.WriteByte(c)
+	}
+	return decls.String(), rules
+}
+
+// parseSelectors splits a selector list on the commas that are not inside
+// parentheses or strings, as in :not(a, b) or [title="a,b"]
 func parseSelectors(selectorStr string) []string {
-	selectors := strings.Split(selectorStr, ",")
+	selectors := splitOutsideParens(selectorStr, ',')
 	result := make([]string, 0, len(selectors))
 
 	for _, selector := range selectors {
@@ -110,7 +428,7 @@ func parseSelectors(selectorStr string) []string {
 
 // parseDeclarations parses CSS declarations
 func parseDeclarations(declarationsStr string) []*Declaration {
-	declarationStrings := strings.Split(declarationsStr, ";")
+	declarationStrings := splitOutsideParens(declarationsStr, ';')
 	result := make([]*Declaration, 0, len(declarationStrings))
 
 	for _, declStr := range declarationStrings {
@@ -144,6 +462,36 @@ func parseDeclarations(declarationsStr string) []*Declaration {
 	return result
 }
 
+// splitOutsideParens splits s on sep, ignoring separators inside parentheses
+// or quotes so values such as url(data:...;base64,...) stay intact
+func splitOutsideParens(s string, sep byte) []string {
+	var parts []string
+	depth := 0
+	var quote byte
+	start := 0
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		switch {
+		case quote != 0:
+			if c == quote {
+				quote = 0
+			}
+		case c == '"' || c == '\'':
+			quote = c
+		case c == '(':
+			depth++
+		case c == ')':
+			if depth > 0 {
+				depth--
+			}
+		case c == sep && depth == 0:
+			parts = append(parts, s[start:i])
+			start = i + 1
+		}
+	}
+	return append(parts, s[start:])
+}
+
 // removeComments removes CSS comments
 func removeComments(content string) string {
 	var result strings.Builder
@@ -187,7 +535,16 @@ func splitRules(content string) []string {
 			}
 		}
 
-		if braceCount > 0 || !isWhitespace(char) {
+		// Statement at-rules such as @import or @charset end at a semicolon
+		// and would otherwise swallow the rule after them
+		if char == ';' && braceCount == 0 && strings.HasPrefix(currentRule.String(), "@") {
+			currentRule.Reset()
+			continue
+		}
+
+		// Whitespace between rules is dropped; inside a selector it is a
+		// descendant combinator and has to be kept
+		if braceCount > 0 || !isWhitespace(char) || currentRule.Len() > 0 {
 			currentRule.WriteByte(char)
 		}
 	}
@@ -199,3 +556,79 @@ func splitRules(content string) []string {
 func isWhitespace(char byte) bool {
 	return char == ' ' || char == '\t' || char == '\n' || char == '\r'
 }
+
+// FontFaces returns the @font-face rules of the stylesheet in source order
+func (s *Stylesheet) FontFaces() []*FontFace {
+	var faces []*FontFace
+	for _, rule := range s.Rules {
+		if len(rule.Selectors) != 1 || !strings.EqualFold(rule.Selectors[0], "@font-face") {
+			continue
+		}
+		face := &FontFace{}
+		for _, decl := range rule.Declarations {
+			switch strings.ToLower(decl.Property) {
+			case "font-family":
+				face.Family = strings.Trim(strings.TrimSpace(decl.Value), "'\"")
+			case "font-weight":
+				face.Weight = decl.Value
+			case "font-style":
+				face.Style = decl.Value
+			case "src":
+				face.Sources = parseFontSources(decl.Value)
+			}
+		}
+		if face.Family != "" && len(face.Sources) > 0 {
+			faces = append(faces, face)
+		}
+	}
+	return faces
+}
+
+// parseFontSources extracts the url() references of an @font-face src value
+func parseFontSources(value string) []string {
+	var urls []string
+	for _, src := range splitOutsideParens(value, ',') {
+		src = strings.TrimSpace(src)
+		if !strings.HasPrefix(strings.ToLower(src), "url(") {
+			continue
+		}
+		end := strings.Index(src, ")")
+		if end < 4 {
+			continue
+		}
+		u := strings.Trim(strings.TrimSpace(src[4:end]), "'\"")
+		if u != "" {
+			urls = append(urls, u)
+		}
+	}
+	return urls
+}
+
+// PageRules returns the @page rules of the stylesheet in source order
+func (s *Stylesheet) PageRules() []*PageRule {
+	var pages []*PageRule
+	for _, rule := range s.Rules {
+		for _, sel := range rule.Selectors {
+			if len(sel) < 5 || !strings.EqualFold(sel[:5], "@page") {
+				continue
+			}
+			parts := strings.Split(strings.TrimSpace(sel[5:]), ":")
+			page := &PageRule{Name: strings.TrimSpace(parts[0]), Declarations: rule.Declarations}
+			for _, nested := range rule.Nested {
+				if len(nested.Selectors) == 1 && strings.HasPrefix(nested.Selectors[0], "@") {
+					page.MarginBoxes = append(page.MarginBoxes, &MarginBox{
+						Name:         strings.ToLower(nested.Selectors[0][1:]),
+						Declarations: nested.Declarations,
+					})
+				}
+			}
+			for _, pseudo := range parts[1:] {
+				if pseudo = strings.ToLower(strings.TrimSpace(pseudo)); pseudo != "" {
+					page.Pseudo = append(page.Pseudo, pseudo)
+				}
+			}
+			pages = append(pages, page)
+		}
+	}
+	return pages
+}
@@ -3,6 +3,7 @@ package html
 import (
 	"bytes"
 	"io"
+	"strconv"
 	"strings"
 
 	"golang.org/x/net/html"
@@ -51,36 +52,81 @@ func (p *Parser) Parse(r io.Reader) (*Document, error) {
 	return &Document{Root: root}, nil
 }
 
+// FromNode builds a document from a tree of html.Node, which is left
+// untouched. A document node or an html element is the document; any other
+// node, such as an element of a fragment, becomes the content of the body
+// of an otherwise empty document.
+func FromNode(n *html.Node) *Document {
+	switch {
+	case n.Type == html.DocumentNode:
+		return &Document{Root: convertNode(n, nil)}
+	case n.Type == html.ElementNode && strings.EqualFold(n.Data, "html"):
+		root := &Node{Type: html.DocumentNode}
+		appendChild(root, convertNode(n, root))
+		return &Document{Root: root}
+	}
+	root := &Node{Type: html.DocumentNode}
+	htmlNode := &Node{Type: html.ElementNode, Data: "html"}
+	head := &Node{Type: html.ElementNode, Data: "head"}
+	body := &Node{Type: html.ElementNode, Data: "body"}
+	appendChild(root, htmlNode)
+	appendChild(htmlNode, head)
+	appendChild(htmlNode, body)
+	appendChild(body, convertNode(n, body))
+	return &Document{Root: root}
+}
+
+// appendChild adds child as the last child of parent
+func appendChild(parent, child *Node) {
+	child.Parent = parent
+	child.PrevSibling = parent.LastChild
+	if parent.LastChild != nil {
+		parent.LastChild.NextSibling = child
+	} else {
+		parent.FirstChild = child
+	}
+	parent.LastChild = child
+}
+
 // convertNode converts an html.Node to our Node structure
 func convertNode(n *html.Node, parent *Node) *Node {
 	if n == nil {
 		return nil
 	}
 
+	// Attributes are copied, as the tree may be changed and the html.Node
+	// may belong to the caller
 	node := &Node{
 		Type:   n.Type,
 		Data:   n.Data,
-		Attr:   n.Attr,
+		Attr:   append([]html.Attribute(nil), n.Attr...),
 		Parent: parent,
 	}
 
-	var lastChild *Node
 	for c := n.FirstChild; c != nil; c = c.NextSibling {
 		child := convertNode(c, node)
-		if node.FirstChild == nil {
-			node.FirstChild = child
+		appendChild(node, child)
+		if child.Type == html.ElementNode && strings.EqualFold(child.Data, "pagebreak") {
+			hoistChildren(child)
 		}
-		if lastChild != nil {
-			lastChild.NextSibling = child
-			child.PrevSibling = lastChild
-		}
-		lastChild = child
 	}
-	node.LastChild = lastChild
 
 	return node
 }
 
+// hoistChildren moves the children of an element after it. A <pagebreak/>
+// is not a void element of HTML, so the parser puts the content that follows
+// it inside it.
+func hoistChildren(n *Node) {
+	for c := n.FirstChild; c != nil; {
+		next := c.NextSibling
+		c.PrevSibling, c.NextSibling = nil, nil
+		appendChild(n.Parent, c)
+		c = next
+	}
+	n.FirstChild, n.LastChild = nil, nil
+}
+
 // Render renders the document back to HTML
 func (d *Document) Render() (string, error) {
 	var buf bytes.Buffer
@@ -124,3 +170,53 @@ func renderNode(w io.Writer, n *Node) error {
 
 	return html.Render(w, node)
 }
+
+// Path returns a selector path locating an element from the root of its
+// document, such as "html > body > div:nth-of-type(2) > img#logo". Other
+// nodes give the path of their parent element.
+func (n *Node) Path() string {
+	for n != nil && n.Type != html.ElementNode {
+		n = n.Parent
+	}
+	var steps []string
+	for ; n != nil && n.Type == html.ElementNode; n = n.Parent {
+		step := strings.ToLower(n.Data)
+		if id := n.attr("id"); id != "" {
+			step += "#" + id
+		} else if index, count := n.typeIndex(); count > 1 {
+			step += ":nth-of-type(" + strconv.Itoa(index) + ")"
+		}
+		steps = append(steps, step)
+	}
+	for i, j := 0, len(steps)-1; i < j; i, j = i+1, j-1 {
+		steps[i], steps[j] = steps[j], steps[i]
+	}
+	return strings.Join(steps, " > ")
+}
+
+// attr returns the value of an attribute of the node, "" when it has none
+func (n *Node) attr(key string) string {
+	for _, a := range n.Attr {
+		if strings.EqualFold(a.Key, key) {
+			return a.Val
+		}
+	}
+	return ""
+}
+
+// typeIndex returns the 1-based position of an element among the siblings
+// of its type and the number of them
+func (n *Node) typeIndex() (index, count int) {
+	if n.Parent == nil {
+		return 1, 1
+	}
+	for s := n.Parent.FirstChild; s != nil; s = s.NextSibling {
+		if s.Type == html.ElementNode && strings.EqualFold(s.Data, n.Data) {
+			count++
+			if s == n {
+				index = count
+			}
+		}
+	}
+	return index, count
+}
@@ -0,0 +1,111 @@
+package html
+
+import (
+	"strconv"
+	"strings"
+)
+
+// ImageCandidate is an image of the srcset attribute of an <img> or
+// <source> element: its URL with either the pixel density it is meant for or
+// its width in pixels
+type ImageCandidate struct {
+	URL string
+	// Density is the pixel density of an "x" descriptor, 1 when the
+	// candidate has no descriptor and 0 when it has a width
+	Density float64
+	// Width is the width in pixels of a "w" descriptor
+	Width int
+}
+
+// String formats the candidate as it is written in a srcset attribute
+func (c ImageCandidate) String() string {
+	switch {
+	case c.Width > 0:
+		return c.URL + " " + strconv.Itoa(c.Width) + "w"
+	case c.Density != 1:
+		return c.URL + " " + strconv.FormatFloat(c.Density, 'g', -1, 64) + "x"
+	}
+	return c.URL
+}
+
+// ParseSrcset parses the candidates of a srcset attribute such as
+// "logo.png 1x, logo@2x.png 2x" or "small.jpg 480w, large.jpg 1080w".
+// Candidates with invalid descriptors are dropped, as browsers do.
+func ParseSrcset(srcset string) []ImageCandidate {
+	var candidates []ImageCandidate
+	s := srcset
+	for {
+		s = strings.TrimLeft(s, " \t\n\r\f,")
+		if s == "" {
+			return candidates
+		}
+		end := strings.IndexAny(s, " \t\n\r\f")
+		if end < 0 {
+			end = len(s)
+		}
+		url := s[:end]
+		s = s[end:]
+		var descriptors []string
+		if trimmed := strings.TrimRight(url, ","); trimmed != url {
+			// A comma ending the URL ends the candidate
+			url = trimmed
+		} else {
+			descriptors, s = candidateDescriptors(s)
+		}
+		if c, ok := newImageCandidate(url, descriptors); ok {
+			candidates = append(candidates, c)
+		}
+	}
+}
+
+// candidateDescriptors splits the descriptors of a candidate off the start
+// of s, up to the comma ending the candidate, and returns the rest of s.
+// Commas inside parentheses do not end it.
+func candidateDescriptors(s string) ([]string, string) {
+	depth := 0
+	for i := 0; i < len(s); i++ {
+		switch s[i] {
+		case '(':
+			depth++
+		case ')':
+			if depth > 0 {
+				depth--
+			}
+		case ',':
+			if depth == 0 {
+				return strings.Fields(s[:i]), s[i+1:]
+			}
+		}
+	}
+	return strings.Fields(s), ""
+}
+
+// newImageCandidate makes the candidate of a URL and its descriptors
+func newImageCandidate(url string, descriptors []string) (ImageCandidate, bool) {
+	c := ImageCandidate{URL: url, Density: 1}
+	if url == "" || len(descriptors) > 1 {
+		return c, false
+	}
+	if len(descriptors) == 0 {
+		return c, true
+	}
+	d := strings.ToLower(descriptors[0])
+	switch {
+	case strings.HasSuffix(d, "w"):
+		w, err := strconv.Atoi(d[:len(d)-1])
+		if err != nil || w <= 0 {
+			return c, false
+		}
+		c.Width, c.Density = w, 0
+	case strings.HasSuffix(d, "x"):
+		x, err := strconv.ParseFloat(d[:len(d)-1], 64)
+		if err != nil || x <= 0 {
+			return c, false
+		}
+		c.Density = x
+	default:
+		// Height descriptors are not used to select images
+		return c, false
+	}
+	return c, true
+}
@@ -0,0 +1,210 @@
+package pdf
+
+import (
+	"bytes"
+	"compress/zlib"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+)
+
+// Decode returns the data of a stream decoded with its filters. Flate,
+// ASCIIHex and ASCII85 data can be decoded, with the PNG predictors of
+// Flate data.
+func Decode(s *Stream) ([]byte, error) {
+	var filters []Object
+	switch f := s.Dict["Filter"].(type) {
+	case Name:
+		filters = []Object{f}
+	case Array:
+		filters = f
+	}
+	var params []Object
+	switch p := s.Dict["DecodeParms"].(type) {
+	case Dict:
+		params = []Object{p}
+	case Array:
+		params = p
+	}
+
+	data := s.Data
+	for i, f := range filters {
+		var parms Dict
+		if i < len(params) {
+			parms, _ = params[i].(Dict)
+		}
+		var err error
+		switch f {
+		case Name("FlateDecode"), Name("Fl"):
+			data, err = inflate(data, parms)
+		case Name("ASCIIHexDecode"), Name("AHx"):
+			data, err = asciiHexDecode(data)
+		case Name("ASCII85Decode"), Name("A85"):
+			data, err = ascii85Decode(data)
+		default:
+			return nil, fmt.Errorf("unsupported filter %v", f)
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+	return data, nil
+}
+
+// inflate decodes Flate data. Data cut short is kept as far as it goes, as
+// PDF viewers do.
+func inflate(data []byte, parms Dict) ([]byte, error) {
+	zr, err := zlib.NewReader(bytes.NewReader(data))
+	if err != nil {
+		return nil, fmt.Errorf("flate: %w", err)
+	}
+	out, err := io.ReadAll(zr)
+	if err != nil && (len(out) == 0 || !errors.Is(err, io.ErrUnexpectedEOF)) {
+		return nil, fmt.Errorf("flate: %w", err)
+	}
+	predictor, _ := parms["Predictor"].(int)
+	if predictor < 10 {
+		if predictor == 2 {
+			return nil, errors.New("flate: TIFF predictor not supported")
+		}
+		return out, nil
+	}
+	columns, colors, bits := 1, 1, 8
+	if v, ok := parms["Columns"].(int); ok && v > 0 {
+		columns = v
+	}
+	if v, ok := parms["Colors"].(int); ok && v > 0 {
+		colors = v
+	}
+	if v, ok := parms["BitsPerComponent"].(int); ok && v > 0 {
+		bits = v
+	}
+	return unpredictPNG(out, columns, colors, bits)
+}
+
+// unpredictPNG undoes the PNG predictors of Flate data: every row starts
+// with the byte of the PNG filter type of the row
+func unpredictPNG(data []byte, columns, colors, bits int) ([]byte, error) {
+	bpp := (colors*bits + 7) / 8
+	rowLen := (columns*colors*bits + 7) / 8
+	out := make([]byte, 0, len(data))
+	prev := make([]byte, rowLen)
+	for len(data) > 0 {
+		if len(data) < rowLen+1 {
+			break
+		}
+		kind, row := data[0], append([]byte(nil), data[1:rowLen+1]...)
+		data = data[rowLen+1:]
+		for i := range row {
+			var left, upLeft byte
+			if i >= bpp {
+				left, upLeft = row[i-bpp], prev[i-bpp]
+			}
+			up := prev[i]
+			switch kind {
+			case 0:
+			case 1:
+				row[i] += left
+			case 2:
+				row[i] += up
+			case 3:
+				row[i] += byte((int(left) + int(up)) / 2)
+			case 4:
+				row[i] += paeth(left, up, upLeft)
+			default:
+				return nil, fmt.Errorf("flate: unknown PNG filter %d", kind)
+			}
+		}
+		out = append(out, row...)
+		prev = row
+	}
+	return out, nil
+}
+
+// paeth returns the Paeth predictor of a byte from its neighbours
+func paeth(a, b, c byte) byte {
+	p := int(a) + int(b) - int(c)
+	pa, pb, pc := abs(p-int(a)), abs(p-int(b)), abs(p-int(c))
+	switch {
+	case pa <= pb && pa <= pc:
+		return a
+	case pb <= pc:
+		return b
+	}
+	return c
+}
+
+func abs(n int) int {
+	if n < 0 {
+		return -n
+	}
+	return n
+}
+
+// asciiHexDecode decodes ASCIIHex data, which ends at >
+func asciiHexDecode(data []byte) ([]byte, error) {
+	var digits []byte
+	for _, c := range data {
+		if c == '>' {
+			break
+		}
+		if !isSpace(c) {
+			digits = append(digits, c)
+		}
+	}
+	if len(digits)%2 == 1 {
+		digits = append(digits, '0')
+	}
+	out := make([]byte, len(digits)/2)
+	if _, err := hex.Decode(out, digits); err != nil {
+		return nil, fmt.Errorf("asciihex: %w", err)
+	}
+	return out, nil
+}
+
+// ascii85Decode decodes ASCII85 data, which ends at ~>. z stands for four
+// zero bytes.
+func ascii85Decode(data []byte) ([]byte, error) {
+	var out []byte
+	var group [5]byte
+	n := 0
+	flush := func(count int) {
+		var v uint32
+		for i := 0; i < 5; i++ {
+			v = v*85 + uint32(group[i])
+		}
+		b := []byte{byte(v >> 24), byte(v >> 16), byte(v >> 8), byte(v)}
+		out = append(out, b[:count]...)
+	}
+	for i := 0; i < len(data); i++ {
+		c := data[i]
+		switch {
+		case c == '~':
+			i = len(data)
+		case isSpace(c):
+		case c == 'z' && n == 0:
+			out = append(out, 0, 0, 0, 0)
+		case c >= '!' && c <= 'u':
+			group[n] = c - '!'
+			n++
+			if n == 5 {
+				flush(4)
+				n = 0
+			}
+		default:
+			return nil, fmt.Errorf("ascii85: invalid character %q", c)
+		}
+	}
+	if n == 1 {
+		return nil, errors.New("ascii85: truncated group")
+	}
+	if n > 0 {
+		// A final partial group is padded with u
+		for i := n; i < 5; i++ {
+			group[i] = 'u' - '!'
+		}
+		flush(n - 1)
+	}
+	return out, nil
+}
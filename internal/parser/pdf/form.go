@@ -0,0 +1,314 @@
+package pdf
+
+import (
+	"bytes"
+	"compress/zlib"
+	"crypto/sha1"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+)
+
+// Form is a page of a document made a form XObject, which draws the page
+// wherever another document places it. Its objects are named by hashes of
+// 40 characters: the hashes of the objects an object refers to stand in its
+// data for their numbers, which are only known once the objects are written.
+type Form struct {
+	// ID is the hash of the form object
+	ID string
+	// Width and Height are the size of the page as it is shown, turned by
+	// its rotation, in points. The form draws it from the origin up and to
+	// the right.
+	Width, Height float64
+	// Objects are the form object and the objects it refers to, without
+	// their obj and endobj keywords
+	Objects map[string][]byte
+	// Refs are, for every object, the offsets in its data of the hashes of
+	// the objects it refers to
+	Refs map[string]map[int]string
+}
+
+// Form makes a page of the document, numbered from 1, a form XObject. The
+// form shows the crop box of the page with its rotation; its resources are
+// copied with it. The hashes of a document's objects are the same for all
+// its forms, so that forms of the same document imported together share
+// them.
+func (d *Document) Form(page int) (form *Form, err error) {
+	defer recoverMalformed(&err)
+	if page < 1 || page > len(d.pages) {
+		return nil, fmt.Errorf("page %d not found; the document has %d pages", page, len(d.pages))
+	}
+	p := d.pages[page-1]
+	llx, lly, urx, ury, rotate := d.pageBox(p)
+	content, err := d.pageContent(p["Contents"])
+	if err != nil {
+		return nil, fmt.Errorf("page %d: %w", page, err)
+	}
+
+	// The matrix moves the corner of the box to the origin, turning the
+	// page clockwise by its rotation
+	f := &Form{Width: urx - llx, Height: ury - lly}
+	matrix := []float64{1, 0, 0, 1, -llx, -lly}
+	switch rotate {
+	case 90:
+		matrix = []float64{0, -1, 1, 0, -lly, urx}
+		f.Width, f.Height = f.Height, f.Width
+	case 180:
+		matrix = []float64{-1, 0, 0, -1, urx, ury}
+	case 270:
+		matrix = []float64{0, 1, -1, 0, ury, -llx}
+		f.Width, f.Height = f.Height, f.Width
+	}
+
+	e := &exporter{doc: d, objects: make(map[string][]byte), refs: make(map[string]map[int]string)}
+	f.ID = d.hash("page:" + strconv.Itoa(page))
+	refs := make(map[int]string)
+	var b bytes.Buffer
+	fmt.Fprintf(&b, "<< /Type /XObject /Subtype /Form /BBox [%s] /Matrix [%s] /Resources ",
+		formatNumbers(llx, lly, urx, ury), formatNumbers(matrix...))
+	if res := p["Resources"]; res != nil {
+		e.write(&b, res, refs)
+	} else {
+		b.WriteString("<< >>")
+	}
+	if group := p["Group"]; group != nil {
+		b.WriteString(" /Group ")
+		e.write(&b, group, refs)
+	}
+	var z bytes.Buffer
+	zw := zlib.NewWriter(&z)
+	zw.Write(content)
+	zw.Close()
+	fmt.Fprintf(&b, " /Filter /FlateDecode /Length %d >>\nstream\n", z.Len())
+	b.Write(z.Bytes())
+	b.WriteString("\nendstream")
+	e.objects[f.ID] = b.Bytes()
+	e.refs[f.ID] = refs
+	f.Objects, f.Refs = e.objects, e.refs
+	return f, nil
+}
+
+// PageSize returns the size of a page of the document, numbered from 1, as
+// it is shown, turned by its rotation, in points
+func (d *Document) PageSize(page int) (w, h float64, err error) {
+	defer recoverMalformed(&err)
+	if page < 1 || page > len(d.pages) {
+		return 0, 0, fmt.Errorf("page %d not found; the document has %d pages", page, len(d.pages))
+	}
+	llx, lly, urx, ury, rotate := d.pageBox(d.pages[page-1])
+	if rotate == 90 || rotate == 270 {
+		return ury - lly, urx - llx, nil
+	}
+	return urx - llx, ury - lly, nil
+}
+
+// pageBox returns the corners of the crop box of a page, which defaults to
+// its media box, and its rotation: 0, 90, 180 or 270 degrees clockwise
+func (d *Document) pageBox(p Dict) (llx, lly, urx, ury float64, rotate int) {
+	box := d.rect(p["CropBox"])
+	if box == nil {
+		box = d.rect(p["MediaBox"])
+	}
+	if box == nil {
+		// US Letter, the default of PDF viewers
+		box = []float64{0, 0, 612, 792}
+	}
+	llx, lly = math.Min(box[0], box[2]), math.Min(box[1], box[3])
+	urx, ury = math.Max(box[0], box[2]), math.Max(box[1], box[3])
+	rotate, _ = d.Resolve(p["Rotate"]).(int)
+	rotate = (rotate%360 + 360) % 360 / 90 * 90
+	return llx, lly, urx, ury, rotate
+}
+
+// hash returns the 40 character name of an object of the document
+func (d *Document) hash(key string) string {
+	sum := sha1.Sum([]byte(d.id + ":" + key))
+	return hex.EncodeToString(sum[:])
+}
+
+// rect returns the numbers of a rectangle, or nil when obj is not one
+func (d *Document) rect(obj Object) []float64 {
+	arr, ok := d.Resolve(obj).(Array)
+	if !ok || len(arr) != 4 {
+		return nil
+	}
+	r := make([]float64, 4)
+	for i, v := range arr {
+		n, ok := number(d.Resolve(v))
+		if !ok {
+			return nil
+		}
+		r[i] = n
+	}
+	return r
+}
+
+// number returns the value of an integer or real number
+func number(obj Object) (float64, bool) {
+	switch n := obj.(type) {
+	case int:
+		return float64(n), true
+	case float64:
+		return n, true
+	}
+	return 0, false
+}
+
+// pageContent returns the decoded content of a page: its content stream,
+// or its content streams joined
+func (d *Document) pageContent(contents Object) ([]byte, error) {
+	var streams []Object
+	switch c := d.Resolve(contents).(type) {
+	case nil:
+		return nil, nil
+	case *Stream:
+		streams = []Object{c}
+	case Array:
+		streams = c
+	default:
+		return nil, errors.New("malformed page contents")
+	}
+	var out []byte
+	for _, s := range streams {
+		stream, ok := d.Resolve(s).(*Stream)
+		if !ok {
+			continue
+		}
+		data, err := Decode(stream)
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, data...)
+		out = append(out, '\n')
+	}
+	return out, nil
+}
+
+// exporter copies the objects a form refers to
+type exporter struct {
+	doc     *Document
+	objects map[string][]byte
+	refs    map[string]map[int]string
+}
+
+// copyObject copies an indirect object and those it refers to, returning
+// its hash
+func (e *exporter) copyObject(num int) string {
+	h := e.doc.hash(strconv.Itoa(num))
+	if _, ok := e.objects[h]; ok {
+		return h
+	}
+	// The object is marked copied before its references are followed,
+	// which may lead back to it
+	e.objects[h] = nil
+	refs := make(map[int]string)
+	var b bytes.Buffer
+	if s, ok := e.doc.object(num).(*Stream); ok {
+		dict := make(Dict, len(s.Dict))
+		for k, v := range s.Dict {
+			dict[k] = v
+		}
+		dict["Length"] = len(s.Data)
+		e.write(&b, dict, refs)
+		b.WriteString("\nstream\n")
+		b.Write(s.Data)
+		b.WriteString("\nendstream")
+	} else {
+		e.write(&b, e.doc.object(num), refs)
+	}
+	e.objects[h] = b.Bytes()
+	e.refs[h] = refs
+	return h
+}
+
+// write writes a direct object, copying the objects it refers to and
+// recording where their hashes are in refs
+func (e *exporter) write(b *bytes.Buffer, obj Object, refs map[int]string) {
+	switch o := obj.(type) {
+	case nil:
+		b.WriteString("null")
+	case bool:
+		b.WriteString(strconv.FormatBool(o))
+	case int:
+		b.WriteString(strconv.Itoa(o))
+	case float64:
+		b.WriteString(formatNumbers(o))
+	case Name:
+		writeName(b, o)
+	case String:
+		b.WriteByte('<')
+		b.WriteString(hex.EncodeToString([]byte(o)))
+		b.WriteByte('>')
+	case Array:
+		b.WriteByte('[')
+		for i, v := range o {
+			if i > 0 {
+				b.WriteByte(' ')
+			}
+			e.write(b, v, refs)
+		}
+		b.WriteByte(']')
+	case Dict:
+		b.WriteString("<<")
+		for _, k := range sortedKeys(o) {
+			writeName(b, k)
+			b.WriteByte(' ')
+			e.write(b, o[k], refs)
+		}
+		b.WriteString(">>")
+	case Ref:
+		if _, ok := e.doc.xref[o.Num]; !ok {
+			b.WriteString("null")
+			return
+		}
+		h := e.copyObject(o.Num)
+		refs[b.Len()] = h
+		b.WriteString(h + " 0 R")
+	case *Stream:
+		// A stream is always an indirect object, referred to by a Ref
+		b.WriteString("null")
+	}
+}
+
+// writeName writes a name, escaping the characters that are not regular
+func writeName(b *bytes.Buffer, n Name) {
+	b.WriteByte('/')
+	for i := 0; i < len(n); i++ {
+		c := n[i]
+		if c < '!' || c > '~' || c == '#' || isDelimiter(c) {
+			fmt.Fprintf(b, "#%02X", c)
+			continue
+		}
+		b.WriteByte(c)
+	}
+}
+
+// sortedKeys returns the keys of a dictionary in order, so that the objects
+// of a form are the same every time
+func sortedKeys(d Dict) []Name {
+	keys := make([]Name, 0, len(d))
+	for k := range d {
+		keys = append(keys, k)
+	}
+	sort.Slice(keys, func(i, j int) bool { return keys[i] < keys[j] })
+	return keys
+}
+
+// formatNumbers formats numbers separated by spaces, without exponents
+func formatNumbers(ns ...float64) string {
+	var b []byte
+	for i, n := range ns {
+		if n == 0 {
+			// No negative zero
+			n = 0
+		}
+		if i > 0 {
+			b = append(b, ' ')
+		}
+		b = strconv.AppendFloat(b, n, 'f', -1, 64)
+	}
+	return string(b)
+}
@@ -0,0 +1,883 @@
+// Package pdf reads existing PDF documents for their pages, which are
+// drawn into the documents gompdf writes, such as the stationery of a
+// letterhead. It reads what drawing a page needs: the cross-reference
+// tables and streams, object streams, the page tree and the objects the
+// pages refer to. Encrypted documents are not supported.
+package pdf
+
+import (
+	"bytes"
+	"crypto/sha1"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+)
+
+// Object is a PDF object: nil for null, a bool, an int, a float64, a Name,
+// a String, an Array, a Dict, a Ref or a *Stream
+type Object any
+
+// Name is a PDF name, without its leading slash
+type Name string
+
+// String is a PDF string, literal or hexadecimal, as its bytes
+type String string
+
+// Array is a PDF array
+type Array []Object
+
+// Dict is a PDF dictionary
+type Dict map[Name]Object
+
+// Ref is a reference to an indirect object
+type Ref struct {
+	Num, Gen int
+}
+
+// Stream is a PDF stream: its dictionary and its data as stored, still
+// encoded with the filters of the dictionary
+type Stream struct {
+	Dict Dict
+	Data []byte
+}
+
+// xrefEntry locates an object: at a byte offset of the document, or as the
+// object at an index of an object stream. Freed objects are at offset -1.
+type xrefEntry struct {
+	offset     int
+	stream     int
+	index      int
+	compressed bool
+}
+
+// Document is a PDF document read for its pages
+type Document struct {
+	data    []byte
+	xref    map[int]xrefEntry
+	trailer Dict
+	objects map[int]Object
+	// streams are the decoded object streams, with the offsets of the
+	// objects they hold
+	streams map[int]*objectStream
+	// resolving guards against objects whose stream length refers back to
+	// themselves
+	resolving map[int]bool
+	pages     []Dict
+	// id identifies the document in the names of the objects it gives; see
+	// Form
+	id string
+}
+
+// objectStream is a decoded object stream
+type objectStream struct {
+	data    []byte
+	offsets []int
+}
+
+// Read reads a PDF document
+func Read(data []byte) (doc *Document, err error) {
+	defer recoverMalformed(&err)
+	head := data
+	if len(head) > 1024 {
+		head = head[:1024]
+	}
+	if !bytes.Contains(head, []byte("%PDF-")) {
+		return nil, errors.New("not a PDF document")
+	}
+	sum := sha1.Sum(data)
+	d := &Document{
+		data:      data,
+		xref:      make(map[int]xrefEntry),
+		objects:   make(map[int]Object),
+		streams:   make(map[int]*objectStream),
+		resolving: make(map[int]bool),
+		id:        hex.EncodeToString(sum[:]),
+	}
+	if err := d.readXref(); err != nil || d.trailer["Root"] == nil {
+		// Damaged documents are read by the objects found in them
+		d.reconstruct()
+	}
+	if d.trailer["Encrypt"] != nil {
+		return nil, errors.New("encrypted PDF documents are not supported")
+	}
+	root, _ := d.Resolve(d.trailer["Root"]).(Dict)
+	if root == nil {
+		return nil, errors.New("document catalog not found")
+	}
+	d.collectPages(d.Resolve(root["Pages"]), Dict{}, make(map[Object]bool))
+	if len(d.pages) == 0 {
+		return nil, errors.New("document has no pages")
+	}
+	return d, nil
+}
+
+// recoverMalformed turns a panic while reading a malformed document, one
+// the checks of the parser missed, into an error, so that a document drawn
+// into another cannot take the host process down. It is deferred by the
+// functions that read objects of a document.
+func recoverMalformed(err *error) {
+	if v := recover(); v != nil {
+		*err = fmt.Errorf("malformed PDF document: %v", v)
+	}
+}
+
+// NumPages returns the number of pages of the document
+func (d *Document) NumPages() int {
+	return len(d.pages)
+}
+
+// Resolve returns the object a reference refers to, or any other object as
+// is
+func (d *Document) Resolve(obj Object) Object {
+	if ref, ok := obj.(Ref); ok {
+		return d.object(ref.Num)
+	}
+	return obj
+}
+
+// object returns an indirect object, or nil when it is missing
+func (d *Document) object(num int) Object {
+	if obj, ok := d.objects[num]; ok {
+		return obj
+	}
+	entry, ok := d.xref[num]
+	if !ok || (!entry.compressed && (entry.offset < 0 || entry.offset >= len(d.data))) || d.resolving[num] {
+		return nil
+	}
+	d.resolving[num] = true
+	defer delete(d.resolving, num)
+
+	var obj Object
+	if entry.compressed {
+		obj = d.compressedObject(entry)
+	} else {
+		p := &parser{doc: d, data: d.data, pos: entry.offset}
+		if n, o, err := p.indirectObject(); err == nil && n == num {
+			obj = o
+		}
+	}
+	d.objects[num] = obj
+	return obj
+}
+
+// compressedObject returns an object held in an object stream
+func (d *Document) compressedObject(entry xrefEntry) Object {
+	stm := d.streams[entry.stream]
+	if stm == nil {
+		stream, ok := d.object(entry.stream).(*Stream)
+		if !ok {
+			return nil
+		}
+		data, err := Decode(stream)
+		if err != nil {
+			return nil
+		}
+		n, _ := d.Resolve(stream.Dict["N"]).(int)
+		first, _ := d.Resolve(stream.Dict["First"]).(int)
+		stm = &objectStream{data: data}
+		p := &parser{doc: d, data: data}
+		// A negative /First or offset leaves the objects of the stream
+		// unread
+		for i := 0; i < n && first >= 0; i++ {
+			_, err1 := p.int()
+			off, err2 := p.int()
+			if err1 != nil || err2 != nil || off < 0 {
+				break
+			}
+			stm.offsets = append(stm.offsets, first+off)
+		}
+		d.streams[entry.stream] = stm
+	}
+	if entry.index < 0 || entry.index >= len(stm.offsets) {
+		return nil
+	}
+	if off := stm.offsets[entry.index]; off < 0 || off > len(stm.data) {
+		return nil
+	}
+	p := &parser{doc: d, data: stm.data, pos: stm.offsets[entry.index]}
+	obj, err := p.object()
+	if err != nil {
+		return nil
+	}
+	return obj
+}
+
+// readXref reads the cross-reference sections of the document, from the
+// last one back through the sections they update
+func (d *Document) readXref() error {
+	at := bytes.LastIndex(d.data, []byte("startxref"))
+	if at < 0 {
+		return errors.New("startxref not found")
+	}
+	p := &parser{doc: d, data: d.data, pos: at + len("startxref")}
+	offset, err := p.int()
+	if err != nil {
+		return fmt.Errorf("malformed startxref: %w", err)
+	}
+	seen := make(map[int]bool)
+	for offset > 0 && !seen[offset] {
+		seen[offset] = true
+		trailer, err := d.readXrefSection(offset)
+		if err != nil {
+			return err
+		}
+		if d.trailer == nil {
+			d.trailer = trailer
+		}
+		// Hybrid documents keep the objects of object streams in an
+		// additional cross-reference stream
+		if stm, ok := trailer["XRefStm"].(int); ok && !seen[stm] {
+			seen[stm] = true
+			if _, err := d.readXrefSection(stm); err != nil {
+				return err
+			}
+		}
+		offset, _ = trailer["Prev"].(int)
+	}
+	if d.trailer == nil {
+		return errors.New("trailer not found")
+	}
+	return nil
+}
+
+// readXrefSection reads a cross-reference table and its trailer, or a
+// cross-reference stream, at an offset. Entries of later sections, read
+// first, take precedence.
+func (d *Document) readXrefSection(offset int) (Dict, error) {
+	if offset < 0 || offset >= len(d.data) {
+		return nil, errors.New("cross-reference section out of range")
+	}
+	p := &parser{doc: d, data: d.data, pos: offset}
+	p.skipSpace()
+	if !p.keyword("xref") {
+		return d.readXrefStream(p)
+	}
+	for {
+		p.skipSpace()
+		if p.keyword("trailer") {
+			obj, err := p.object()
+			if err != nil {
+				return nil, err
+			}
+			trailer, ok := obj.(Dict)
+			if !ok {
+				return nil, errors.New("malformed trailer")
+			}
+			return trailer, nil
+		}
+		first, err := p.int()
+		if err != nil {
+			return nil, fmt.Errorf("malformed cross-reference table: %w", err)
+		}
+		count, err := p.int()
+		if err != nil {
+			return nil, fmt.Errorf("malformed cross-reference table: %w", err)
+		}
+		for i := 0; i < count; i++ {
+			off, err1 := p.int()
+			_, err2 := p.int()
+			p.skipSpace()
+			if err1 != nil || err2 != nil || p.pos >= len(p.data) {
+				return nil, errors.New("malformed cross-reference entry")
+			}
+			kind := p.data[p.pos]
+			p.pos++
+			if _, ok := d.xref[first+i]; ok {
+				continue
+			}
+			if kind != 'n' {
+				off = -1
+			}
+			d.xref[first+i] = xrefEntry{offset: off}
+		}
+	}
+}
+
+// readXrefStream reads a cross-reference stream, whose dictionary is the
+// trailer of its section
+func (d *Document) readXrefStream(p *parser) (Dict, error) {
+	_, obj, err := p.indirectObject()
+	if err != nil {
+		return nil, err
+	}
+	stream, ok := obj.(*Stream)
+	if !ok || stream.Dict["Type"] != Name("XRef") {
+		return nil, errors.New("cross-reference section not found")
+	}
+	data, err := Decode(stream)
+	if err != nil {
+		return nil, err
+	}
+	widths, _ := stream.Dict["W"].(Array)
+	if len(widths) != 3 {
+		return nil, errors.New("malformed cross-reference stream")
+	}
+	var w [3]int
+	for i := range w {
+		w[i], _ = widths[i].(int)
+		if w[i] < 0 {
+			return nil, errors.New("malformed cross-reference stream")
+		}
+	}
+	index, _ := stream.Dict["Index"].(Array)
+	if index == nil {
+		size, _ := stream.Dict["Size"].(int)
+		index = Array{0, size}
+	}
+	rowLen := w[0] + w[1] + w[2]
+	if rowLen == 0 {
+		return nil, errors.New("malformed cross-reference stream")
+	}
+	row := 0
+	for i := 0; i+1 < len(index); i += 2 {
+		first, _ := index[i].(int)
+		count, _ := index[i+1].(int)
+		for j := 0; j < count; j++ {
+			if (row+1)*rowLen > len(data) {
+				return stream.Dict, nil
+			}
+			fields := data[row*rowLen:]
+			row++
+			kind := 1
+			if w[0] > 0 {
+				kind = field(fields[:w[0]])
+			}
+			a, b := field(fields[w[0]:w[0]+w[1]]), field(fields[w[0]+w[1]:rowLen])
+			if _, ok := d.xref[first+j]; ok {
+				continue
+			}
+			switch kind {
+			case 0:
+				d.xref[first+j] = xrefEntry{offset: -1}
+			case 1:
+				d.xref[first+j] = xrefEntry{offset: a}
+			case 2:
+				d.xref[first+j] = xrefEntry{stream: a, index: b, compressed: true}
+			}
+		}
+	}
+	return stream.Dict, nil
+}
+
+// field returns the big-endian number of a field of a cross-reference
+// stream
+func field(b []byte) int {
+	n := 0
+	for _, c := range b {
+		n = n<<8 | int(c)
+	}
+	return n
+}
+
+// reconstruct rebuilds the cross-reference of a damaged document from the
+// objects found in it. The catalog is the root of the last trailer or
+// cross-reference stream naming one, or else the catalog found.
+func (d *Document) reconstruct() {
+	d.xref = make(map[int]xrefEntry)
+	d.objects = make(map[int]Object)
+	d.streams = make(map[int]*objectStream)
+	d.trailer = nil
+	var catalog Object
+	for at := 0; ; {
+		i := bytes.Index(d.data[at:], []byte("obj"))
+		if i < 0 {
+			break
+		}
+		at += i + len("obj")
+		start := objectStart(d.data, at-len("obj"))
+		if start < 0 {
+			continue
+		}
+		p := &parser{doc: d, data: d.data, pos: start}
+		num, err := p.int()
+		if err != nil {
+			continue
+		}
+		d.xref[num] = xrefEntry{offset: start}
+	}
+	for at := 0; ; {
+		i := bytes.Index(d.data[at:], []byte("trailer"))
+		if i < 0 {
+			break
+		}
+		at += i + len("trailer")
+		p := &parser{doc: d, data: d.data, pos: at}
+		if t, err := p.object(); err == nil {
+			if t, ok := t.(Dict); ok && t["Root"] != nil {
+				d.trailer = t
+			}
+		}
+	}
+	// The objects of object streams are found by reading the streams
+	var nums []int
+	for num := range d.xref {
+		nums = append(nums, num)
+	}
+	sort.Ints(nums)
+	for _, num := range nums {
+		s, ok := d.object(num).(*Stream)
+		if !ok || s.Dict["Type"] != Name("ObjStm") {
+			continue
+		}
+		data, err := Decode(s)
+		if err != nil {
+			continue
+		}
+		n, _ := d.Resolve(s.Dict["N"]).(int)
+		p := &parser{doc: d, data: data}
+		for i := 0; i < n; i++ {
+			obj, err1 := p.int()
+			_, err2 := p.int()
+			if err1 != nil || err2 != nil {
+				break
+			}
+			if _, ok := d.xref[obj]; !ok {
+				d.xref[obj] = xrefEntry{stream: num, index: i, compressed: true}
+			}
+		}
+	}
+	nums = nums[:0]
+	for num := range d.xref {
+		nums = append(nums, num)
+	}
+	sort.Ints(nums)
+	for _, num := range nums {
+		switch obj := d.object(num).(type) {
+		case Dict:
+			if obj["Type"] == Name("Catalog") {
+				catalog = Ref{Num: num}
+			}
+		case *Stream:
+			if obj.Dict["Type"] == Name("XRef") && obj.Dict["Root"] != nil && d.trailer == nil {
+				d.trailer = obj.Dict
+			}
+		}
+	}
+	if d.trailer == nil {
+		d.trailer = Dict{}
+	}
+	if d.trailer["Root"] == nil {
+		d.trailer["Root"] = catalog
+	}
+}
+
+// objectStart returns the offset of the object number of an "N G obj" line
+// whose keyword starts at at, or -1 when there is none
+func objectStart(data []byte, at int) int {
+	i := at
+	// The keyword is followed by a delimiter and preceded by the
+	// generation and object numbers
+	if end := at + len("obj"); end < len(data) && !isSpace(data[end]) && !isDelimiter(data[end]) {
+		return -1
+	}
+	for n := 0; n < 2; n++ {
+		j := i
+		for j > 0 && isSpace(data[j-1]) {
+			j--
+		}
+		k := j
+		for k > 0 && data[k-1] >= '0' && data[k-1] <= '9' {
+			k--
+		}
+		if k == j || j == i {
+			return -1
+		}
+		i = k
+	}
+	if i > 0 && !isSpace(data[i-1]) {
+		return -1
+	}
+	return i
+}
+
+// pageAttributes are the attributes pages inherit from the nodes of the
+// page tree
+var pageAttributes = []Name{"Resources", "MediaBox", "CropBox", "Rotate"}
+
+// collectPages adds the pages under a node of the page tree, with the
+// attributes they inherit
+func (d *Document) collectPages(node Object, inherited Dict, seen map[Object]bool) {
+	dict, ok := node.(Dict)
+	if !ok {
+		return
+	}
+	attrs := make(Dict, len(pageAttributes))
+	for _, k := range pageAttributes {
+		if v, ok := dict[k]; ok {
+			attrs[k] = v
+		} else if v, ok := inherited[k]; ok {
+			attrs[k] = v
+		}
+	}
+	kids, isTree := d.Resolve(dict["Kids"]).(Array)
+	if !isTree || dict["Type"] == Name("Page") {
+		page := make(Dict, len(dict)+len(attrs))
+		for k, v := range dict {
+			page[k] = v
+		}
+		for k, v := range attrs {
+			page[k] = v
+		}
+		d.pages = append(d.pages, page)
+		return
+	}
+	for _, kid := range kids {
+		if ref, ok := kid.(Ref); ok {
+			if seen[ref] {
+				continue
+			}
+			seen[ref] = true
+		}
+		d.collectPages(d.Resolve(kid), attrs, seen)
+	}
+}
+
+// parser reads the objects of a document or of an object stream
+type parser struct {
+	doc  *Document
+	data []byte
+	pos  int
+}
+
+// isSpace reports whether c is PDF white-space
+func isSpace(c byte) bool {
+	switch c {
+	case 0, '\t', '\n', '\f', '\r', ' ':
+		return true
+	}
+	return false
+}
+
+// isDelimiter reports whether c is a PDF delimiter
+func isDelimiter(c byte) bool {
+	switch c {
+	case '(', ')', '<', '>', '[', ']', '{', '}', '/', '%':
+		return true
+	}
+	return false
+}
+
+// skipSpace skips white-space and comments
+func (p *parser) skipSpace() {
+	for p.pos < len(p.data) {
+		c := p.data[p.pos]
+		if c == '%' {
+			for p.pos < len(p.data) && p.data[p.pos] != '\n' && p.data[p.pos] != '\r' {
+				p.pos++
+			}
+			continue
+		}
+		if !isSpace(c) {
+			return
+		}
+		p.pos++
+	}
+}
+
+// token returns the regular characters at the position, without reading
+// them
+func (p *parser) token() string {
+	end := p.pos
+	for end < len(p.data) && !isSpace(p.data[end]) && !isDelimiter(p.data[end]) {
+		end++
+	}
+	return string(p.data[p.pos:end])
+}
+
+// keyword reads a keyword when it is at the position, reporting whether it
+// was
+func (p *parser) keyword(kw string) bool {
+	if p.token() != kw {
+		return false
+	}
+	p.pos += len(kw)
+	return true
+}
+
+// int reads a non-negative integer
+func (p *parser) int() (int, error) {
+	p.skipSpace()
+	tok := p.token()
+	n, err := strconv.Atoi(tok)
+	if err != nil || n < 0 {
+		return 0, fmt.Errorf("integer expected at offset %d", p.pos)
+	}
+	p.pos += len(tok)
+	return n, nil
+}
+
+// indirectObject reads an "N G obj ... endobj" object, with the data of a
+// stream
+func (p *parser) indirectObject() (int, Object, error) {
+	num, err := p.int()
+	if err != nil {
+		return 0, nil, err
+	}
+	if _, err := p.int(); err != nil {
+		return 0, nil, err
+	}
+	p.skipSpace()
+	if !p.keyword("obj") {
+		return 0, nil, fmt.Errorf("object %d: obj expected", num)
+	}
+	obj, err := p.object()
+	if err != nil {
+		return 0, nil, fmt.Errorf("object %d: %w", num, err)
+	}
+	dict, ok := obj.(Dict)
+	if !ok {
+		return num, obj, nil
+	}
+	p.skipSpace()
+	if !p.keyword("stream") {
+		return num, obj, nil
+	}
+	if p.pos < len(p.data) && p.data[p.pos] == '\r' {
+		p.pos++
+	}
+	if p.pos < len(p.data) && p.data[p.pos] == '\n' {
+		p.pos++
+	}
+	start := p.pos
+	end := -1
+	if length, ok := p.doc.Resolve(dict["Length"]).(int); ok && start+length <= len(p.data) {
+		q := &parser{data: p.data, pos: start + length}
+		q.skipSpace()
+		if q.keyword("endstream") {
+			end = start + length
+		}
+	}
+	if end < 0 {
+		// A wrong length is made up for by the endstream keyword, less the
+		// end of line before it
+		i := bytes.Index(p.data[start:], []byte("endstream"))
+		if i < 0 {
+			return 0, nil, fmt.Errorf("object %d: endstream not found", num)
+		}
+		end = start + i
+		if end > start && p.data[end-1] == '\n' {
+			end--
+		}
+		if end > start && p.data[end-1] == '\r' {
+			end--
+		}
+	}
+	return num, &Stream{Dict: dict, Data: p.data[start:end]}, nil
+}
+
+// object reads a direct object or a reference
+func (p *parser) object() (Object, error) {
+	p.skipSpace()
+	if p.pos >= len(p.data) {
+		return nil, errors.New("unexpected end of data")
+	}
+	switch c := p.data[p.pos]; {
+	case c == '/':
+		p.pos++
+		return p.name(), nil
+	case c == '(':
+		p.pos++
+		return p.literalString()
+	case c == '<' && p.pos+1 < len(p.data) && p.data[p.pos+1] == '<':
+		p.pos += 2
+		return p.dict()
+	case c == '<':
+		p.pos++
+		return p.hexString()
+	case c == '[':
+		p.pos++
+		return p.array()
+	case c == '+' || c == '-' || c == '.' || (c >= '0' && c <= '9'):
+		return p.number()
+	}
+	tok := p.token()
+	if tok == "" {
+		return nil, fmt.Errorf("unexpected %q at offset %d", p.data[p.pos], p.pos)
+	}
+	p.pos += len(tok)
+	switch tok {
+	case "true":
+		return true, nil
+	case "false":
+		return false, nil
+	case "null":
+		return nil, nil
+	}
+	return nil, fmt.Errorf("unexpected %q at offset %d", tok, p.pos-len(tok))
+}
+
+// name reads a name after its slash, decoding #xx escapes
+func (p *parser) name() Name {
+	tok := p.token()
+	p.pos += len(tok)
+	if !bytes.ContainsRune([]byte(tok), '#') {
+		return Name(tok)
+	}
+	var b []byte
+	for i := 0; i < len(tok); i++ {
+		if tok[i] == '#' && i+2 < len(tok) {
+			if v, err := strconv.ParseUint(tok[i+1:i+3], 16, 8); err == nil {
+				b = append(b, byte(v))
+				i += 2
+				continue
+			}
+		}
+		b = append(b, tok[i])
+	}
+	return Name(b)
+}
+
+// number reads an integer, a real number, or a reference "N G R"
+func (p *parser) number() (Object, error) {
+	tok := p.token()
+	p.pos += len(tok)
+	n, err := strconv.Atoi(tok)
+	if err != nil {
+		f, err := strconv.ParseFloat(tok, 64)
+		if err != nil {
+			return nil, fmt.Errorf("malformed number %q", tok)
+		}
+		return f, nil
+	}
+	// A reference is two integers and R
+	save := p.pos
+	p.skipSpace()
+	if gen, err := strconv.Atoi(p.token()); err == nil && n >= 0 && gen >= 0 {
+		p.pos += len(p.token())
+		p.skipSpace()
+		if p.keyword("R") {
+			return Ref{Num: n, Gen: gen}, nil
+		}
+	}
+	p.pos = save
+	return n, nil
+}
+
+// literalString reads a literal string after its opening parenthesis
+func (p *parser) literalString() (Object, error) {
+	var b []byte
+	depth := 1
+	for p.pos < len(p.data) {
+		c := p.data[p.pos]
+		p.pos++
+		switch c {
+		case '(':
+			depth++
+		case ')':
+			depth--
+			if depth == 0 {
+				return String(b), nil
+			}
+		case '\\':
+			if p.pos >= len(p.data) {
+				break
+			}
+			e := p.data[p.pos]
+			p.pos++
+			switch e {
+			case 'n':
+				c = '\n'
+			case 'r':
+				c = '\r'
+			case 't':
+				c = '\t'
+			case 'b':
+				c = '\b'
+			case 'f':
+				c = '\f'
+			case '\r':
+				// A line continuation
+				if p.pos < len(p.data) && p.data[p.pos] == '\n' {
+					p.pos++
+				}
+				continue
+			case '\n':
+				continue
+			default:
+				if e >= '0' && e <= '7' {
+					v := int(e - '0')
+					for k := 0; k < 2 && p.pos < len(p.data) && p.data[p.pos] >= '0' && p.data[p.pos] <= '7'; k++ {
+						v = v*8 + int(p.data[p.pos]-'0')
+						p.pos++
+					}
+					c = byte(v)
+				} else {
+					c = e
+				}
+			}
+		}
+		b = append(b, c)
+	}
+	return nil, errors.New("unterminated string")
+}
+
+// hexString reads a hexadecimal string after its opening angle bracket
+func (p *parser) hexString() (Object, error) {
+	var b []byte
+	var digits []byte
+	for p.pos < len(p.data) {
+		c := p.data[p.pos]
+		p.pos++
+		if c == '>' {
+			if len(digits)%2 == 1 {
+				digits = append(digits, '0')
+			}
+			b = make([]byte, len(digits)/2)
+			if _, err := hex.Decode(b, digits); err != nil {
+				return nil, fmt.Errorf("malformed hexadecimal string: %w", err)
+			}
+			return String(b), nil
+		}
+		if !isSpace(c) {
+			digits = append(digits, c)
+		}
+	}
+	return nil, errors.New("unterminated hexadecimal string")
+}
+
+// array reads an array after its opening bracket
+func (p *parser) array() (Object, error) {
+	arr := Array{}
+	for {
+		p.skipSpace()
+		if p.pos >= len(p.data) {
+			return nil, errors.New("unterminated array")
+		}
+		if p.data[p.pos] == ']' {
+			p.pos++
+			return arr, nil
+		}
+		obj, err := p.object()
+		if err != nil {
+			return nil, err
+		}
+		arr = append(arr, obj)
+	}
+}
+
+// dict reads a dictionary after its opening brackets
+func (p *parser) dict() (Object, error) {
+	dict := Dict{}
+	for {
+		p.skipSpace()
+		if p.pos+1 >= len(p.data) {
+			return nil, errors.New("unterminated dictionary")
+		}
+		if p.data[p.pos] == '>' && p.data[p.pos+1] == '>' {
+			p.pos += 2
+			return dict, nil
+		}
+		if p.data[p.pos] != '/' {
+			return nil, fmt.Errorf("dictionary key expected at offset %d", p.pos)
+		}
+		p.pos++
+		key := p.name()
+		value, err := p.object()
+		if err != nil {
+			return nil, err
+		}
+		dict[key] = value
+	}
+}
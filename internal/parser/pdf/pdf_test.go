@@ -0,0 +1,103 @@
+package pdf
+
+import (
+	"bytes"
+	"fmt"
+	"testing"
+)
+
+// objectStreamPDF returns a document whose catalog, page tree and page are
+// held in an object stream with the given /First, located by a
+// cross-reference stream. header is the list of object numbers and offsets
+// that starts the stream; an empty one is that of the objects.
+func objectStreamPDF(first int, header string) []byte {
+	objects := []string{
+		"<< /Type /Catalog /Pages 2 0 R >>",
+		"<< /Type /Pages /Kids [3 0 R] /Count 1 >>",
+		"<< /Type /Page /Parent 2 0 R /MediaBox [0 0 200 100] >>",
+	}
+	var body bytes.Buffer
+	var offsets bytes.Buffer
+	for i, o := range objects {
+		fmt.Fprintf(&offsets, "%d %d ", i+1, body.Len())
+		body.WriteString(o + "\n")
+	}
+	if header == "" {
+		header = offsets.String()
+	}
+	if first == 0 {
+		first = len(header)
+	}
+	stream := header + body.String()
+
+	var b bytes.Buffer
+	b.WriteString("%PDF-1.5\n")
+	stmAt := b.Len()
+	fmt.Fprintf(&b, "4 0 obj\n<< /Type /ObjStm /N 3 /First %d /Length %d >>\nstream\n%s\nendstream\nendobj\n", first, len(stream), stream)
+	xrefAt := b.Len()
+	var rows bytes.Buffer
+	row := func(kind, a, c int) {
+		rows.Write([]byte{byte(kind), byte(a >> 8), byte(a), byte(c >> 8), byte(c)})
+	}
+	row(0, 0, 0)
+	for i := range objects {
+		row(2, 4, i)
+	}
+	row(1, stmAt, 0)
+	row(1, xrefAt, 0)
+	fmt.Fprintf(&b, "5 0 obj\n<< /Type /XRef /Size 6 /W [1 2 2] /Root 1 0 R /Length %d >>\nstream\n", rows.Len())
+	b.Write(rows.Bytes())
+	fmt.Fprintf(&b, "\nendstream\nendobj\nstartxref\n%d\n%%%%EOF\n", xrefAt)
+	return b.Bytes()
+}
+
+func TestReadObjectStream(t *testing.T) {
+	d, err := Read(objectStreamPDF(0, ""))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if w, h, err := d.PageSize(1); err != nil || w != 200 || h != 100 {
+		t.Errorf("page size %vx%v (%v), want 200x100", w, h, err)
+	}
+}
+
+func TestReadMalformedObjectStream(t *testing.T) {
+	tests := []struct {
+		name   string
+		first  int
+		header string
+	}{
+		{"negative first", -100, ""},
+		{"offset before the stream", -20, "1 0 2 5 3 10 "},
+		{"offset past the stream", 0, "1 0 2 100000 3 10 "},
+		{"negative offset", 0, "1 -5 2 0 3 0 "},
+	}
+	for _, tt := range tests {
+		// The document is rejected or read; it must not panic
+		if d, err := Read(objectStreamPDF(tt.first, tt.header)); err == nil {
+			for page := 1; page <= d.NumPages(); page++ {
+				d.Form(page)
+			}
+		}
+	}
+}
+
+func FuzzOpen(f *testing.F) {
+	f.Add(objectStreamPDF(0, ""))
+	f.Add(objectStreamPDF(-100, ""))
+	f.Add([]byte("%PDF-1.4\n1 0 obj\n<< /Type /Catalog /Pages 2 0 R >>\nendobj\n" +
+		"2 0 obj\n<< /Type /Pages /Kids [3 0 R] /Count 1 >>\nendobj\n" +
+		"3 0 obj\n<< /Type /Page /Parent 2 0 R /MediaBox [0 0 612 792] /Contents 4 0 R >>\nendobj\n" +
+		"4 0 obj\n<< /Length 8 >>\nstream\n0 0 m S\n\nendstream\nendobj\n" +
+		"trailer\n<< /Root 1 0 R >>\n%%EOF\n"))
+	f.Fuzz(func(t *testing.T, data []byte) {
+		d, err := Read(data)
+		if err != nil {
+			return
+		}
+		for page := 1; page <= d.NumPages() && page <= 10; page++ {
+			d.PageSize(page)
+			d.Form(page)
+		}
+	})
+}
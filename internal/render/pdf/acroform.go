@@ -0,0 +1,314 @@
+package pdf
+
+import (
+	"bytes"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"codeberg.org/go-pdf/fpdf"
+	"github.com/gompdf/gompdf/internal/layout"
+	"github.com/gompdf/gompdf/internal/style"
+)
+
+// Field flags of PDF interactive forms
+const (
+	fieldReadOnly    = 1 << 0
+	fieldMultiline   = 1 << 12
+	fieldPassword    = 1 << 13
+	fieldNoToggleOff = 1 << 14
+	fieldRadio       = 1 << 15
+	fieldCombo       = 1 << 17
+	fieldMultiSelect = 1 << 21
+)
+
+// formField is a form control made a fillable field of the interactive
+// form of the document, with the widget annotation showing it on its page
+type formField struct {
+	widget *layout.FormWidget
+	// page is the number of the page of the field, from 1
+	page int
+	// rect is the rectangle of the widget in PDF user space, from the
+	// bottom left corner of the page
+	rect [4]float64
+	// mark is the outline of the mark of a checked checkbox, relative to
+	// the bottom left corner of the widget
+	mark     [][2]float64
+	fontSize float64
+	color    [3]int
+}
+
+// radioGroup is the parent field of the radio buttons sharing a name, whose
+// value is that of the checked one
+type radioGroup struct {
+	num   int
+	name  string
+	value string
+	flags int
+	kids  []string
+}
+
+// addFormField makes the form control of a box a fillable field when form
+// fields are enabled. Buttons stay static. It reports whether the box is a
+// field, whose value the viewer draws.
+func (r *Renderer) addFormField(pdf *fpdf.Fpdf, box *layout.BlockBox) bool {
+	w := box.Widget
+	if !r.FormFields || w.Kind == "button" {
+		return false
+	}
+	_, pageH := pdf.GetPageSize()
+	f := formField{
+		widget:   w,
+		page:     pdf.PageNo(),
+		rect:     [4]float64{box.X, pageH - box.Y - box.Height, box.X + box.Width, pageH - box.Y},
+		fontSize: parseCSSFloat(box.Style["font-size"].Value, 12),
+	}
+	if v := strings.TrimSpace(box.Style["color"].Value); v != "" {
+		f.color = style.ParseColor(v)
+	}
+	if w.Kind == "checkbox" {
+		for _, p := range w.CheckMark(box) {
+			f.mark = append(f.mark, [2]float64{p[0] - box.X, box.Y + box.Height - p[1]})
+		}
+	}
+	r.fields = append(r.fields, f)
+	return true
+}
+
+// patchFormFields adds the fields of the document as an interactive form:
+// a widget annotation on the page of each field, and the /AcroForm entry of
+// the catalog listing them. Text fields and lists are drawn by the viewer,
+// which the form asks to do with /NeedAppearances; checkboxes and radio
+// buttons have the appearance of their checked and unchecked states.
+// The radio buttons sharing a name are the kids of one field.
+func (r *Renderer) patchFormFields(patch *pdfPatch) error {
+	if len(r.fields) == 0 {
+		return nil
+	}
+	pages := pageObjects(patch.data)
+	font, err := patch.addObject("<< /Type /Font /Subtype /Type1 /BaseFont /Helvetica /Encoding /WinAnsiEncoding >>")
+	if err != nil {
+		return err
+	}
+
+	var fields []string
+	annots := make(map[int][]string)
+	var groups []*radioGroup
+	groupOf := make(map[string]*radioGroup)
+	unnamed := 0
+	for _, f := range r.fields {
+		if f.page < 1 || f.page > len(pages) {
+			return fmt.Errorf("page %d of a form field not found", f.page)
+		}
+		w := f.widget
+		name := w.Name
+		if name == "" {
+			unnamed++
+			name = "field" + strconv.Itoa(unnamed)
+		}
+		num, err := patch.reserveObject()
+		if err != nil {
+			return err
+		}
+		ref := fmt.Sprintf("%d 0 R", num)
+		annots[f.page] = append(annots[f.page], ref)
+		dict := []string{
+			"/Type /Annot /Subtype /Widget",
+			fmt.Sprintf("/Rect [%.2f %.2f %.2f %.2f]", f.rect[0], f.rect[1], f.rect[2], f.rect[3]),
+			"/F 4",
+			"/P " + pages[f.page-1] + " 0 R",
+			fmt.Sprintf("/DA (/Helv %.2f Tf %s)", f.fontSize, colorOperator(f.color)),
+		}
+
+		if w.Kind == "radio" {
+			// The buttons of a group are widgets of one parent field
+			g := groupOf[name]
+			if g == nil {
+				g = &radioGroup{name: name, value: "/Off", flags: fieldRadio | fieldNoToggleOff | readOnlyFlag(w)}
+				if g.num, err = patch.reserveObject(); err != nil {
+					return err
+				}
+				groupOf[name] = g
+				groups = append(groups, g)
+				fields = append(fields, fmt.Sprintf("%d 0 R", g.num))
+			}
+			g.kids = append(g.kids, ref)
+			on := "/" + pdfName(w.Value)
+			if w.Checked {
+				g.value = on
+			}
+			ap, err := r.checkAppearances(patch, f, on)
+			if err != nil {
+				return err
+			}
+			dict = append(dict, fmt.Sprintf("/Parent %d 0 R", g.num), "/AS "+stateName(w.Checked, on), ap)
+			patch.defineObject(num, "<< "+strings.Join(dict, " ")+" >>")
+			continue
+		}
+
+		fields = append(fields, ref)
+		dict = append(dict, "/T "+pdfTextString(name))
+		flags := readOnlyFlag(w)
+		switch w.Kind {
+		case "checkbox":
+			on := "/" + pdfName(w.Value)
+			ap, err := r.checkAppearances(patch, f, on)
+			if err != nil {
+				return err
+			}
+			state := stateName(w.Checked, on)
+			dict = append(dict, "/FT /Btn", "/V "+state, "/AS "+state, ap)
+		case "select", "list":
+			if w.Kind == "select" {
+				flags |= fieldCombo
+			} else if w.Multiple {
+				flags |= fieldMultiSelect
+			}
+			opts := make([]string, len(w.Options))
+			for i, o := range w.Options {
+				opts[i] = pdfTextString(o)
+			}
+			dict = append(dict, "/FT /Ch", "/Opt ["+strings.Join(opts, " ")+"]")
+			if len(w.Selected) > 0 {
+				values, indexes := make([]string, len(w.Selected)), make([]string, len(w.Selected))
+				for i, k := range w.Selected {
+					values[i], indexes[i] = opts[k], strconv.Itoa(k)
+				}
+				value := values[0]
+				if len(values) > 1 {
+					value = "[" + strings.Join(values, " ") + "]"
+				}
+				dict = append(dict, "/V "+value, "/I ["+strings.Join(indexes, " ")+"]")
+			}
+		default:
+			if w.Multiline {
+				flags |= fieldMultiline
+			}
+			if w.Password {
+				flags |= fieldPassword
+			}
+			dict = append(dict, "/FT /Tx", "/V "+pdfTextString(w.Value))
+			if w.MaxLength > 0 {
+				dict = append(dict, "/MaxLen "+strconv.Itoa(w.MaxLength))
+			}
+		}
+		if flags != 0 {
+			dict = append(dict, "/Ff "+strconv.Itoa(flags))
+		}
+		patch.defineObject(num, "<< "+strings.Join(dict, " ")+" >>")
+	}
+
+	for _, g := range groups {
+		patch.defineObject(g.num, fmt.Sprintf("<< /FT /Btn /Ff %d /T %s /V %s /Kids [%s] >>",
+			g.flags, pdfTextString(g.name), g.value, strings.Join(g.kids, " ")))
+	}
+	for page, refs := range annots {
+		if err := addPageAnnots(patch, pages[page-1], refs); err != nil {
+			return err
+		}
+	}
+	return patch.addToCatalog(fmt.Sprintf("/AcroForm << /Fields [%s] /NeedAppearances true /DR << /Font << /Helv %d 0 R >> >> /DA (/Helv 0 Tf 0 g) >>",
+		strings.Join(fields, " "), font))
+}
+
+// checkAppearances adds the appearances of the checked and unchecked states
+// of a checkbox or radio button, on being the name of the checked state,
+// and returns the /AP entry of its widget. The frame is drawn on the page;
+// the checked state draws the mark of the widget.
+func (r *Renderer) checkAppearances(patch *pdfPatch, f formField, on string) (string, error) {
+	w, h := f.rect[2]-f.rect[0], f.rect[3]-f.rect[1]
+	var mark strings.Builder
+	fmt.Fprintf(&mark, "%s\n", colorOperator(f.color))
+	if f.widget.Kind == "radio" {
+		// A disc of half the radius of the button, drawn with four Bézier
+		// curves
+		cx, cy, radius := w/2, h/2, min(w, h)/4
+		k := 0.5523 * radius
+		fmt.Fprintf(&mark, "%.2f %.2f m\n", cx+radius, cy)
+		fmt.Fprintf(&mark, "%.2f %.2f %.2f %.2f %.2f %.2f c\n", cx+radius, cy+k, cx+k, cy+radius, cx, cy+radius)
+		fmt.Fprintf(&mark, "%.2f %.2f %.2f %.2f %.2f %.2f c\n", cx-k, cy+radius, cx-radius, cy+k, cx-radius, cy)
+		fmt.Fprintf(&mark, "%.2f %.2f %.2f %.2f %.2f %.2f c\n", cx-radius, cy-k, cx-k, cy-radius, cx, cy-radius)
+		fmt.Fprintf(&mark, "%.2f %.2f %.2f %.2f %.2f %.2f c\n", cx+k, cy-radius, cx+radius, cy-k, cx+radius, cy)
+	} else {
+		for i, p := range f.mark {
+			op := "l"
+			if i == 0 {
+				op = "m"
+			}
+			fmt.Fprintf(&mark, "%.2f %.2f %s\n", p[0], p[1], op)
+		}
+	}
+	mark.WriteString("h f")
+	form := func(content string) string {
+		return fmt.Sprintf("<< /Type /XObject /Subtype /Form /BBox [0 0 %.2f %.2f] /Length %d >>\nstream\n%s\nendstream", w, h, len(content), content)
+	}
+	checked, err := patch.addObject(form(mark.String()))
+	if err != nil {
+		return "", err
+	}
+	unchecked, err := patch.addObject(form(""))
+	if err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("/AP << /N << %s %d 0 R /Off %d 0 R >> >>", on, checked, unchecked), nil
+}
+
+// stateName returns the appearance state of a checkbox or radio button
+func stateName(checked bool, on string) string {
+	if checked {
+		return on
+	}
+	return "/Off"
+}
+
+// readOnlyFlag returns the read-only field flag of a control that cannot
+// be changed
+func readOnlyFlag(w *layout.FormWidget) int {
+	if w.ReadOnly {
+		return fieldReadOnly
+	}
+	return 0
+}
+
+// colorOperator returns the operator selecting a fill color for text
+func colorOperator(c [3]int) string {
+	return fmt.Sprintf("%.3f %.3f %.3f rg", float64(c[0])/255, float64(c[1])/255, float64(c[2])/255)
+}
+
+// pageObjects returns the object numbers of the pages of a document written
+// by fpdf, in order
+func pageObjects(data []byte) []string {
+	const marker = "<</Type /Page\n"
+	var pages []string
+	for at := 0; ; {
+		i := bytes.Index(data[at:], []byte(marker))
+		if i < 0 {
+			return pages
+		}
+		at += i + len(marker)
+		if num, ok := objectNumber(data, at); ok {
+			pages = append(pages, num)
+		}
+	}
+}
+
+// addPageAnnots adds annotations to the /Annots of a page, which fpdf
+// writes for pages with links
+func addPageAnnots(patch *pdfPatch, page string, refs []string) error {
+	start := bytes.Index(patch.data, []byte("\n"+page+" 0 obj\n"))
+	if start < 0 {
+		return fmt.Errorf("page object %s not found", page)
+	}
+	start++
+	end := bytes.Index(patch.data[start:], []byte("endobj"))
+	if end < 0 {
+		return fmt.Errorf("page object %s not found", page)
+	}
+	list := strings.Join(refs, " ")
+	if i := bytes.Index(patch.data[start:start+end], []byte("/Annots [")); i >= 0 {
+		patch.insert(start+i+len("/Annots ["), list+" ")
+		return nil
+	}
+	_, err := patch.insertAfter(start, "<</Type /Page\n", "/Annots ["+list+"]\n")
+	return err
+}
@@ -0,0 +1,114 @@
+package pdf
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+
+	"codeberg.org/go-pdf/fpdf"
+)
+
+// Attachment is a file embedded in the document, such as the XML of an
+// electronic invoice
+type Attachment struct {
+	// Name is the file name shown by PDF viewers
+	Name        string
+	Description string
+	// MIMEType is the media type of the content, such as "text/xml"
+	MIMEType string
+	// Relationship is the relation of the file to the document for PDF/A-3
+	// associated files: Source, Data, Alternative, Supplement or
+	// Unspecified. Files with a relationship are listed as associated
+	// files of the document.
+	Relationship string
+	Content      []byte
+}
+
+// setAttachments hands the attachments to fpdf, which embeds them first
+// when the document is written
+func (r *Renderer) setAttachments(pdf *fpdf.Fpdf) {
+	if len(r.Attachments) == 0 {
+		return
+	}
+	as := make([]fpdf.Attachment, len(r.Attachments))
+	for i, a := range r.Attachments {
+		as[i] = fpdf.Attachment{Content: a.Content, Filename: a.Name, Description: a.Description}
+	}
+	pdf.SetAttachments(as)
+}
+
+// patchAttachments adds what fpdf leaves out of embedded files: the media
+// type of their content and their relationship to the document. fpdf writes
+// every attachment as an embedded file stream followed by its file
+// specification, in order, before any other object.
+func (r *Renderer) patchAttachments(patch *pdfPatch) error {
+	var associated []string
+	pos := 0
+	for _, a := range r.Attachments {
+		at, err := patch.insertAfter(pos, "/Type /EmbeddedFile", mimeSubtype(a.MIMEType))
+		if err != nil {
+			return err
+		}
+		spec := bytes.Index(patch.data[at:], []byte("/Type /Filespec"))
+		if spec < 0 {
+			return fmt.Errorf("file specification of %q not found", a.Name)
+		}
+		spec += at
+		pos = spec + len("/Type /Filespec")
+		if a.Relationship == "" {
+			continue
+		}
+		patch.insert(pos, " /AFRelationship /"+pdfName(a.Relationship))
+		num, ok := objectNumber(patch.data, spec)
+		if !ok {
+			return fmt.Errorf("file specification of %q not found", a.Name)
+		}
+		associated = append(associated, num+" 0 R")
+	}
+	if len(associated) == 0 {
+		return nil
+	}
+	return patch.addToCatalog("/AF [" + strings.Join(associated, " ") + "]")
+}
+
+// mimeSubtype returns the /Subtype entry of an embedded file stream for a
+// media type, or "" without one
+func mimeSubtype(mimeType string) string {
+	mimeType = strings.TrimSpace(mimeType)
+	if mimeType == "" {
+		return ""
+	}
+	return " /Subtype /" + pdfName(mimeType)
+}
+
+// pdfName encodes a PDF name, escaping delimiters, whitespace and other
+// characters outside the printable ASCII range as #xx
+func pdfName(s string) string {
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		if c < '!' || c > '~' || strings.IndexByte("#%()/<>[]{}", c) >= 0 {
+			fmt.Fprintf(&b, "#%02X", c)
+			continue
+		}
+		b.WriteByte(c)
+	}
+	return b.String()
+}
+
+// objectNumber returns the number of the object whose dictionary contains
+// offset at: fpdf starts objects with "N 0 obj" and a new line
+func objectNumber(data []byte, at int) (string, bool) {
+	i := bytes.LastIndex(data[:at], []byte(" 0 obj\n"))
+	if i < 0 {
+		return "", false
+	}
+	j := i
+	for j > 0 && data[j-1] >= '0' && data[j-1] <= '9' {
+		j--
+	}
+	if j == i {
+		return "", false
+	}
+	return string(data[j:i]), true
+}
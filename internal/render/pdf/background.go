@@ -0,0 +1,198 @@
+package pdf
+
+import (
+	"math"
+	"strconv"
+	"strings"
+
+	"codeberg.org/go-pdf/fpdf"
+	"github.com/gompdf/gompdf/internal/layout"
+	"github.com/gompdf/gompdf/internal/logging"
+)
+
+// maxBackgroundTiles bounds the number of tiles drawn for one background so
+// tiny images on large boxes cannot blow up the output
+const maxBackgroundTiles = 10000
+
+// renderBackgroundImage paints the background-image of a block box honoring
+// background-size, background-position and background-repeat. The image is
+// positioned against and clipped to the box.
+func (r *Renderer) renderBackgroundImage(pdf *fpdf.Fpdf, box *layout.BlockBox) {
+	src := cssURL(box.Style["background-image"].Value)
+	if src == "" || r.Loader == nil || box.Width <= 0 || box.Height <= 0 {
+		return
+	}
+	resrc, err := r.Loader.LoadImage(src)
+	if err != nil {
+		r.warn(logging.WarningResource, box.Node, "Failed to load background image %q: %v\n", src, err)
+		return
+	}
+	iw, ih, err := resrc.ImageSize()
+	if err != nil || iw <= 0 || ih <= 0 {
+		r.warn(logging.WarningImage, box.Node, "Failed to read background image size %q: %v\n", src, err)
+		return
+	}
+
+	tw, th := backgroundSize(box.Style["background-size"].Value, box.Width, box.Height, float64(iw), float64(ih))
+	if tw < 0.5 || th < 0.5 {
+		return
+	}
+	px, py := backgroundPosition(box.Style["background-position"].Value, box.Width-tw, box.Height-th)
+	repeatX, repeatY := backgroundRepeat(box.Style["background-repeat"].Value)
+
+	name, ok := r.registerImage(pdf, box.Node, src, resrc, tw, th, 1)
+	if !ok {
+		return
+	}
+
+	x0, x1 := box.X+px, box.X+px+tw
+	if repeatX {
+		x0 -= math.Ceil(px/tw) * tw
+		x1 = box.X + box.Width
+	}
+	y0, y1 := box.Y+py, box.Y+py+th
+	if repeatY {
+		y0 -= math.Ceil(py/th) * th
+		y1 = box.Y + box.Height
+	}
+	if math.Ceil((x1-x0)/tw)*math.Ceil((y1-y0)/th) > maxBackgroundTiles {
+		return
+	}
+
+	pdf.ClipRect(box.X, box.Y, box.Width, box.Height, false)
+	for y := y0; y < y1-0.01; y += th {
+		for x := x0; x < x1-0.01; x += tw {
+			pdf.ImageOptions(name, x, y, tw, th, false, fpdf.ImageOptions{}, 0, "")
+		}
+	}
+	pdf.ClipEnd()
+}
+
+// cssURL extracts the address of a url() value, or "" for none
+func cssURL(value string) string {
+	v := strings.TrimSpace(value)
+	if !strings.HasPrefix(strings.ToLower(v), "url(") {
+		return ""
+	}
+	end := strings.Index(v, ")")
+	if end < 4 {
+		return ""
+	}
+	return strings.Trim(strings.TrimSpace(v[4:end]), "'\"")
+}
+
+// backgroundSize resolves background-size for an image of intrinsic size
+// iw x ih painted into an area of w x h
+func backgroundSize(value string, w, h, iw, ih float64) (float64, float64) {
+	v := strings.ToLower(strings.TrimSpace(value))
+	switch v {
+	case "cover":
+		s := math.Max(w/iw, h/ih)
+		return iw * s, ih * s
+	case "contain":
+		s := math.Min(w/iw, h/ih)
+		return iw * s, ih * s
+	case "", "auto", "auto auto":
+		return iw, ih
+	}
+
+	parts := strings.Fields(v)
+	sw := backgroundLength(parts[0], w)
+	sh := -1.0
+	if len(parts) > 1 {
+		sh = backgroundLength(parts[1], h)
+	}
+	switch {
+	case sw >= 0 && sh >= 0:
+		return sw, sh
+	case sw >= 0:
+		return sw, sw * ih / iw
+	case sh >= 0:
+		return sh * iw / ih, sh
+	}
+	return iw, ih
+}
+
+// backgroundPosition resolves background-position to an offset within the
+// box. freeX and freeY are the box size minus the image size, which is what
+// percentages and keywords are relative to.
+func backgroundPosition(value string, freeX, freeY float64) (float64, float64) {
+	parts := strings.Fields(strings.ToLower(strings.TrimSpace(value)))
+	if len(parts) == 0 {
+		return 0, 0
+	}
+	// A single vertical keyword positions vertically and centers horizontally
+	if len(parts) == 1 {
+		switch parts[0] {
+		case "top", "bottom":
+			parts = []string{"center", parts[0]}
+		default:
+			parts = append(parts, "center")
+		}
+	}
+	// Keywords may be given in either order ("top left")
+	if parts[0] == "top" || parts[0] == "bottom" || parts[1] == "left" || parts[1] == "right" {
+		parts[0], parts[1] = parts[1], parts[0]
+	}
+	return positionComponent(parts[0], freeX), positionComponent(parts[1], freeY)
+}
+
+// positionComponent resolves one background-position value
+func positionComponent(v string, free float64) float64 {
+	switch v {
+	case "left", "top":
+		return 0
+	case "center":
+		return free / 2
+	case "right", "bottom":
+		return free
+	}
+	if strings.HasSuffix(v, "%") {
+		if p, err := strconv.ParseFloat(strings.TrimSuffix(v, "%"), 64); err == nil {
+			return free * p / 100
+		}
+		return 0
+	}
+	return parseCSSFloat(v, 0)
+}
+
+// backgroundRepeat reports whether a background repeats horizontally and
+// vertically. space and round are treated as repeat.
+func backgroundRepeat(value string) (bool, bool) {
+	parts := strings.Fields(strings.ToLower(strings.TrimSpace(value)))
+	if len(parts) == 0 {
+		return true, true
+	}
+	switch parts[0] {
+	case "no-repeat":
+		if len(parts) == 1 {
+			return false, false
+		}
+	case "repeat-x":
+		return true, false
+	case "repeat-y":
+		return false, true
+	}
+	if len(parts) == 2 {
+		return parts[0] != "no-repeat", parts[1] != "no-repeat"
+	}
+	return true, true
+}
+
+// backgroundLength parses a background-size component relative to size.
+// It returns -1 for auto.
+func backgroundLength(v string, size float64) float64 {
+	if v == "auto" {
+		return -1
+	}
+	if strings.HasSuffix(v, "%") {
+		if p, err := strconv.ParseFloat(strings.TrimSuffix(v, "%"), 64); err == nil {
+			return size * p / 100
+		}
+		return -1
+	}
+	if l := parseCSSFloat(v, -1); l >= 0 {
+		return l
+	}
+	return -1
+}
@@ -0,0 +1,103 @@
+package pdf
+
+import (
+	"math"
+
+	"codeberg.org/go-pdf/fpdf"
+	"github.com/gompdf/gompdf/internal/style"
+)
+
+// drawBorders paints the four borders of the rectangle x, y, w, h. Each side
+// has its own width, color and style; the stroke is centered on the edge. It
+// reports whether any border was painted.
+func (r *Renderer) drawBorders(pdf *fpdf.Fpdf, st style.ComputedStyle, x, y, w, h float64) bool {
+	var sides [4]style.Border
+	visible := false
+	for i, side := range style.BorderSides {
+		sides[i] = st.Border(side)
+		visible = visible || sides[i].Visible()
+	}
+	if !visible {
+		return false
+	}
+
+	// Identical solid borders on all sides are drawn as one rectangle so
+	// that the corners join cleanly
+	if sides[0] == sides[1] && sides[1] == sides[2] && sides[2] == sides[3] && isSolid(sides[0].Style) {
+		r.setBorderColor(pdf, st, sides[0])
+		pdf.SetLineWidth(sides[0].Width)
+		pdf.Rect(x, y, w, h, "D")
+		return true
+	}
+
+	top, right, bottom, left := sides[0], sides[1], sides[2], sides[3]
+	// Lines extend by half the adjacent border widths to fill the corners
+	r.drawBorderLine(pdf, st, top, x-left.Width/2, y, x+w+right.Width/2, y, 0, 1)
+	r.drawBorderLine(pdf, st, right, x+w, y-top.Width/2, x+w, y+h+bottom.Width/2, -1, 0)
+	r.drawBorderLine(pdf, st, bottom, x-left.Width/2, y+h, x+w+right.Width/2, y+h, 0, -1)
+	r.drawBorderLine(pdf, st, left, x, y-top.Width/2, x, y+h+bottom.Width/2, 1, 0)
+	return true
+}
+
+// drawBorderLine strokes one side from (x1, y1) to (x2, y2). nx and ny point
+// towards the inside of the box and are used to offset the lines of a double
+// border.
+func (r *Renderer) drawBorderLine(pdf *fpdf.Fpdf, st style.ComputedStyle, b style.Border, x1, y1, x2, y2, nx, ny float64) {
+	if !b.Visible() {
+		return
+	}
+	r.setBorderColor(pdf, st, b)
+	defer func() {
+		pdf.SetDashPattern(nil, 0)
+		pdf.SetLineCapStyle("butt")
+	}()
+
+	switch b.Style {
+	case "dashed":
+		dash := math.Max(3*b.Width, 3)
+		pdf.SetLineWidth(b.Width)
+		pdf.SetDashPattern([]float64{dash, dash}, 0)
+		pdf.Line(x1, y1, x2, y2)
+	case "dotted":
+		// Zero length dashes with round caps draw dots one width apart
+		pdf.SetLineWidth(b.Width)
+		pdf.SetLineCapStyle("round")
+		pdf.SetDashPattern([]float64{0, 2 * b.Width}, 0)
+		pdf.Line(x1, y1, x2, y2)
+	case "double":
+		if b.Width < 3 {
+			pdf.SetLineWidth(b.Width)
+			pdf.Line(x1, y1, x2, y2)
+			return
+		}
+		// Two lines of a third of the width with a gap of the same size
+		lw := b.Width / 3
+		off := b.Width / 3
+		pdf.SetLineWidth(lw)
+		pdf.Line(x1-nx*off, y1-ny*off, x2-nx*off, y2-ny*off)
+		pdf.Line(x1+nx*off, y1+ny*off, x2+nx*off, y2+ny*off)
+	default:
+		pdf.SetLineWidth(b.Width)
+		pdf.Line(x1, y1, x2, y2)
+	}
+}
+
+// setBorderColor selects the stroke color of a border, falling back to the
+// text color as CSS currentColor does
+func (r *Renderer) setBorderColor(pdf *fpdf.Fpdf, st style.ComputedStyle, b style.Border) {
+	value := b.Color
+	if value == "" || value == "currentColor" || value == "currentcolor" {
+		value = st["color"].Value
+	}
+	color := style.ParseColor(value)
+	pdf.SetDrawColor(color[0], color[1], color[2])
+}
+
+// isSolid reports whether a border style is drawn as a plain line
+func isSolid(s string) bool {
+	switch s {
+	case "", "solid", "groove", "ridge", "inset", "outset":
+		return true
+	}
+	return false
+}
@@ -0,0 +1,84 @@
+package pdf
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode/utf16"
+
+	"github.com/gompdf/gompdf/internal/pagination"
+)
+
+// pageLabels returns the /PageLabels catalog entry for the rendered pages,
+// or "" when they are numbered as viewers number pages by default, in
+// decimal from 1
+func pageLabels(pages []*pagination.Page) string {
+	custom := false
+	var nums []string
+	for i, page := range pages {
+		custom = custom || page.Numbering != nil || page.Number != i+1
+		if i > 0 && page.Numbering == pages[i-1].Numbering && page.Number == pages[i-1].Number+1 {
+			continue
+		}
+		entry := []string{strconv.Itoa(i), "<<"}
+		listStyle := "decimal"
+		if page.Numbering != nil {
+			listStyle = page.Numbering.Style
+			if page.Numbering.Prefix != "" {
+				entry = append(entry, "/P", pdfTextString(page.Numbering.Prefix))
+			}
+		}
+		if s := labelStyle(listStyle); s != "" {
+			entry = append(entry, "/S", s)
+		}
+		if page.Number != 1 {
+			entry = append(entry, "/St", strconv.Itoa(page.Number))
+		}
+		nums = append(nums, strings.Join(append(entry, ">>"), " "))
+	}
+	if !custom {
+		return ""
+	}
+	return "/PageLabels << /Nums [" + strings.Join(nums, " ") + "] >>"
+}
+
+// labelStyle maps a list-style-type to a PDF page label numbering style;
+// styles PDF has no equivalent for are numbered in decimal
+func labelStyle(listStyle string) string {
+	switch strings.ToLower(strings.TrimSpace(listStyle)) {
+	case "none":
+		return ""
+	case "lower-roman":
+		return "/r"
+	case "upper-roman":
+		return "/R"
+	case "lower-alpha", "lower-latin":
+		return "/a"
+	case "upper-alpha", "upper-latin":
+		return "/A"
+	}
+	return "/D"
+}
+
+// pdfTextString encodes a PDF text string: a literal string for ASCII text
+// and UTF-16 with a byte order mark otherwise
+func pdfTextString(s string) string {
+	ascii := true
+	for i := 0; i < len(s); i++ {
+		if s[i] >= 0x80 {
+			ascii = false
+			break
+		}
+	}
+	if ascii {
+		r := strings.NewReplacer(`\`, `\\`, "(", `\(`, ")", `\)`)
+		return "(" + r.Replace(s) + ")"
+	}
+	var b strings.Builder
+	b.WriteString("<FEFF")
+	for _, u := range utf16.Encode([]rune(s)) {
+		fmt.Fprintf(&b, "%04X", u)
+	}
+	b.WriteString(">")
+	return b.String()
+}
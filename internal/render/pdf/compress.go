@@ -0,0 +1,168 @@
+package pdf
+
+import (
+	"bytes"
+	"compress/zlib"
+	"errors"
+	"fmt"
+	"io"
+	"regexp"
+	"sort"
+	"strconv"
+)
+
+// Compression levels of the streams of the document, besides the zlib
+// levels 1 to 9
+const (
+	// CompressionDefault compresses streams as fpdf does, for speed
+	CompressionDefault = 0
+	// CompressionNone leaves the content streams of pages uncompressed
+	CompressionNone = -1
+)
+
+// checkCompressionLevel returns an error for a level that is neither a zlib
+// level nor one of the levels above
+func checkCompressionLevel(level int) error {
+	if level < CompressionNone || level > zlib.BestCompression {
+		return fmt.Errorf("invalid compression level %d", level)
+	}
+	return nil
+}
+
+// lengthEntry matches the direct /Length entry of a stream dictionary
+var lengthEntry = regexp.MustCompile(`/Length (\d+)`)
+
+// recompressStreams compresses the Flate streams of a document written by
+// fpdf again at a zlib level, which fpdf always compresses for speed.
+// Objects move as their streams shrink, so the cross-reference table is
+// written again; streams that cannot be inflated are kept as they are.
+func recompressStreams(data []byte, level int) ([]byte, error) {
+	start := bytes.LastIndex(data, []byte("startxref\n"))
+	if start < 0 {
+		return nil, errors.New("startxref not found")
+	}
+	var xref int
+	if _, err := fmt.Sscanf(string(data[start+len("startxref\n"):]), "%d", &xref); err != nil {
+		return nil, fmt.Errorf("malformed startxref: %w", err)
+	}
+	if xref <= 0 || xref >= start {
+		return nil, errors.New("malformed startxref")
+	}
+	lines := bytes.SplitN(data[xref:], []byte("\n"), 3)
+	if len(lines) < 3 || string(lines[0]) != "xref" {
+		return nil, errors.New("cross-reference table not found")
+	}
+	var first, count int
+	if _, err := fmt.Sscanf(string(lines[1]), "%d %d", &first, &count); err != nil {
+		return nil, fmt.Errorf("malformed cross-reference table: %w", err)
+	}
+	entries := xref + len(lines[0]) + len(lines[1]) + 2
+	if entries+20*count > len(data) {
+		return nil, errors.New("truncated cross-reference table")
+	}
+
+	// The objects in the order they are written, each running to the next
+	// or to the table
+	type object struct{ index, offset int }
+	var objects []object
+	for i := 0; i < count; i++ {
+		e := data[entries+20*i : entries+20*i+20]
+		if e[17] != 'n' {
+			continue
+		}
+		off, err := strconv.Atoi(string(e[:10]))
+		if err != nil || off <= 0 || off >= xref {
+			return nil, fmt.Errorf("malformed cross-reference entry %d", first+i)
+		}
+		objects = append(objects, object{index: i, offset: off})
+	}
+	if len(objects) == 0 {
+		return data, nil
+	}
+	sort.Slice(objects, func(i, j int) bool { return objects[i].offset < objects[j].offset })
+
+	var out bytes.Buffer
+	out.Grow(len(data))
+	out.Write(data[:objects[0].offset])
+	offsets := make(map[int]int, len(objects))
+	for i, o := range objects {
+		end := xref
+		if i+1 < len(objects) {
+			end = objects[i+1].offset
+		}
+		offsets[o.index] = out.Len()
+		obj, err := recompressObject(data[o.offset:end], level)
+		if err != nil {
+			return nil, err
+		}
+		out.Write(obj)
+	}
+
+	table := out.Len()
+	out.Write(data[xref:entries])
+	for i := 0; i < count; i++ {
+		e := data[entries+20*i : entries+20*i+20]
+		if off, ok := offsets[i]; ok {
+			fmt.Fprintf(&out, "%010d", off)
+			out.Write(e[10:])
+			continue
+		}
+		out.Write(e)
+	}
+	out.Write(data[entries+20*count : start])
+	fmt.Fprintf(&out, "startxref\n%d\n%%%%EOF\n", table)
+	return out.Bytes(), nil
+}
+
+// recompressObject returns an object with its stream compressed again, or
+// the object as it is when it has no stream of Flate data alone
+func recompressObject(obj []byte, level int) ([]byte, error) {
+	at := bytes.Index(obj, []byte("stream\n"))
+	if at < 0 {
+		return obj, nil
+	}
+	dict := obj[:at]
+	if bytes.Count(dict, []byte("/FlateDecode")) != 1 || bytes.Contains(dict, []byte("/Filter [")) {
+		return obj, nil
+	}
+	m := lengthEntry.FindSubmatchIndex(dict)
+	if m == nil || bytes.Count(dict, []byte("/Length ")) != 1 {
+		return obj, nil
+	}
+	n, _ := strconv.Atoi(string(dict[m[2]:m[3]]))
+	body := at + len("stream\n")
+	if body+n > len(obj) {
+		return obj, nil
+	}
+	zr, err := zlib.NewReader(bytes.NewReader(obj[body : body+n]))
+	if err != nil {
+		return obj, nil
+	}
+	raw, err := io.ReadAll(zr)
+	if err != nil {
+		return obj, nil
+	}
+	var z bytes.Buffer
+	zw, err := zlib.NewWriterLevel(&z, level)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := zw.Write(raw); err != nil {
+		return nil, err
+	}
+	if err := zw.Close(); err != nil {
+		return nil, err
+	}
+	if z.Len() >= n {
+		return obj, nil
+	}
+
+	var b bytes.Buffer
+	b.Write(dict[:m[2]])
+	b.WriteString(strconv.Itoa(z.Len()))
+	b.Write(dict[m[3]:])
+	b.WriteString("stream\n")
+	b.Write(z.Bytes())
+	b.Write(obj[body+n:])
+	return b.Bytes(), nil
+}
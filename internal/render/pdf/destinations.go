@@ -0,0 +1,106 @@
+package pdf
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/gompdf/gompdf/internal/layout"
+	"github.com/gompdf/gompdf/internal/pagination"
+	"github.com/gompdf/gompdf/internal/parser/html"
+)
+
+// anchor is an element with an id: where the page shows its first box
+type anchor struct {
+	id   string
+	x, y float64
+}
+
+// destination is a named destination of the document, which links into it
+// such as report.pdf#nameddest=intro open at
+type destination struct {
+	name string
+	// page is the number of the page, from 1, and left and top the point
+	// shown at the top left corner of the viewer, in PDF user space
+	page      int
+	left, top float64
+}
+
+// collectAnchors returns the anchors of a page: the elements with an id
+// whose first box is on the page, those of earlier pages being seen
+func collectAnchors(page *pagination.Page, seen map[string]bool) []anchor {
+	var anchors []anchor
+	var visit func(box layout.Box)
+	visit = func(box layout.Box) {
+		if id := elementID(box.GetNode()); id != "" && !seen[id] {
+			seen[id] = true
+			anchors = append(anchors, anchor{id: id, x: box.GetX(), y: box.GetY()})
+		}
+		var children []layout.Box
+		switch b := box.(type) {
+		case *layout.BlockBox:
+			children = b.Children
+		case *layout.InlineBox:
+			children = b.Children
+		}
+		for _, child := range children {
+			visit(child)
+		}
+	}
+	for _, box := range page.Boxes {
+		visit(box)
+	}
+	return anchors
+}
+
+// elementID returns the id of an element, "" for other nodes
+func elementID(n *html.Node) string {
+	if n == nil {
+		return ""
+	}
+	for _, a := range n.Attr {
+		if strings.EqualFold(a.Key, "id") {
+			return a.Val
+		}
+	}
+	return ""
+}
+
+// patchDestinations adds the named destinations of the document to the
+// name dictionary of the catalog, which fpdf always writes. Names are
+// sorted, as the name tree requires.
+func (r *Renderer) patchDestinations(patch *pdfPatch) error {
+	if len(r.destinations) == 0 {
+		return nil
+	}
+	pages := pageObjects(patch.data)
+	dests := append([]destination(nil), r.destinations...)
+	sort.Slice(dests, func(i, j int) bool { return dests[i].name < dests[j].name })
+	entries := make([]string, 0, len(dests))
+	for _, d := range dests {
+		if d.page < 1 || d.page > len(pages) {
+			return fmt.Errorf("page %d of destination %q not found", d.page, d.name)
+		}
+		entries = append(entries, fmt.Sprintf("%s [%s 0 R /XYZ %.2f %.2f null]", pdfByteString(d.name), pages[d.page-1], d.left, d.top))
+	}
+	num, err := patch.addObject("<< /Names [" + strings.Join(entries, " ") + "] >>")
+	if err != nil {
+		return err
+	}
+	const catalog = "/Type /Catalog\n"
+	at := bytes.LastIndex(patch.data, []byte(catalog))
+	if at < 0 {
+		return errors.New("catalog not found")
+	}
+	_, err = patch.insertAfter(at, "/Names <<\n", fmt.Sprintf("/Dests %d 0 R\n", num))
+	return err
+}
+
+// pdfByteString encodes a PDF literal string of the bytes of s, such as a
+// name of a name tree, which is compared byte by byte
+func pdfByteString(s string) string {
+	r := strings.NewReplacer(`\`, `\\`, "(", `\(`, ")", `\)`, "\r", `\r`)
+	return "(" + r.Replace(s) + ")"
+}
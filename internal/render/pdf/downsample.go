@@ -0,0 +1,77 @@
+package pdf
+
+import (
+	"bytes"
+	"image"
+	"image/jpeg"
+	"image/png"
+	"math"
+
+	"github.com/gompdf/gompdf/internal/res"
+	"golang.org/x/image/draw"
+)
+
+// defaultJPEGQuality is the quality JPEG images are encoded again at without
+// a JPEGQuality
+const defaultJPEGQuality = 92
+
+// downsampleSize returns the size in pixels a raster image drawn w by h
+// points is scaled down to, so that it has no more than MaxImageDPI pixels
+// per inch, keeping its aspect ratio; 0, 0 when it is kept as it is. The
+// size is that of the image turned upright for its EXIF orientation.
+func (r *Renderer) downsampleSize(data []byte, w, h float64, orientation int) (int, int) {
+	if r.MaxImageDPI <= 0 || w <= 0 || h <= 0 {
+		return 0, 0
+	}
+	cfg, _, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil || cfg.Width <= 0 || cfg.Height <= 0 {
+		return 0, 0
+	}
+	pw, ph := cfg.Width, cfg.Height
+	if orientation >= 5 {
+		pw, ph = ph, pw
+	}
+	// Points are 1/72 inch
+	scale := math.Max(w/72*r.MaxImageDPI/float64(pw), h/72*r.MaxImageDPI/float64(ph))
+	if scale >= 1 {
+		return 0, 0
+	}
+	sw := max(1, int(math.Round(float64(pw)*scale)))
+	sh := max(1, int(math.Round(float64(ph)*scale)))
+	return sw, sh
+}
+
+// reencodeImage decodes a raster image, turns it upright for its EXIF
+// orientation, scales it to width by height pixels unless they are 0 and
+// encodes it again: as JPEG at the JPEG quality when it was one, so that
+// photos stay small, else as PNG. A JPEG image that is neither turned nor
+// scaled is kept when encoding it again does not make it smaller. It
+// returns the data and its fpdf image type.
+func (r *Renderer) reencodeImage(data []byte, orientation, width, height int) ([]byte, string, error) {
+	img, _, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		return nil, "", err
+	}
+	img = res.OrientImage(img, orientation)
+	if width > 0 && height > 0 {
+		scaled := image.NewRGBA(image.Rect(0, 0, width, height))
+		draw.CatmullRom.Scale(scaled, scaled.Bounds(), img, img.Bounds(), draw.Src, nil)
+		img = scaled
+	}
+	var buf bytes.Buffer
+	if !isJPEG(data) {
+		err = png.Encode(&buf, img)
+		return buf.Bytes(), "PNG", err
+	}
+	quality := r.JPEGQuality
+	if quality <= 0 || quality > 100 {
+		quality = defaultJPEGQuality
+	}
+	if err := jpeg.Encode(&buf, img, &jpeg.Options{Quality: quality}); err != nil {
+		return nil, "", err
+	}
+	if orientation <= 1 && width == 0 && buf.Len() >= len(data) {
+		return data, "JPG", nil
+	}
+	return buf.Bytes(), "JPG", nil
+}
@@ -0,0 +1,67 @@
+package pdf
+
+import (
+	"strings"
+
+	"codeberg.org/go-pdf/fpdf"
+	"github.com/gompdf/gompdf/internal/layout"
+	"github.com/gompdf/gompdf/internal/style"
+)
+
+// renderFormWidget draws a form control as a static widget: its background
+// and frame, which show the control whether or not backgrounds and borders
+// are rendered, then the mark of a checked checkbox or radio button or the
+// arrow of a drop-down list. Radio buttons are round. The marks of fillable
+// checkboxes and radio buttons are the appearances of their annotations
+// instead.
+func (r *Renderer) renderFormWidget(pdf *fpdf.Fpdf, box *layout.BlockBox, fillable bool) {
+	w := box.Widget
+	bg, hasBg := box.Style.BackgroundColor()
+	color := [3]int{0, 0, 0}
+	if v := strings.TrimSpace(box.Style["color"].Value); v != "" {
+		color = style.ParseColor(v)
+	}
+
+	if w.Kind == "radio" {
+		cx, cy, radius := w.RadioCircle(box)
+		if hasBg {
+			fill := style.ParseColor(bg)
+			pdf.SetFillColor(fill[0], fill[1], fill[2])
+			pdf.Circle(cx, cy, radius, "F")
+		}
+		if b := box.Style.Border("top"); b.Visible() {
+			r.setBorderColor(pdf, box.Style, b)
+			pdf.SetLineWidth(b.Width)
+			pdf.Circle(cx, cy, radius-b.Width/2, "D")
+		}
+		if w.Checked && !fillable {
+			pdf.SetFillColor(color[0], color[1], color[2])
+			pdf.Circle(cx, cy, radius/2, "F")
+		}
+		return
+	}
+
+	if hasBg {
+		fill := style.ParseColor(bg)
+		pdf.SetFillColor(fill[0], fill[1], fill[2])
+		pdf.Rect(box.X, box.Y, box.Width, box.Height, "F")
+	}
+	r.drawBorders(pdf, box.Style, box.X, box.Y, box.Width, box.Height)
+
+	var outline [][2]float64
+	switch {
+	case w.Kind == "checkbox" && w.Checked && !fillable:
+		outline = w.CheckMark(box)
+	case w.Kind == "select":
+		outline = w.Arrow(box)
+	}
+	if len(outline) == 0 {
+		return
+	}
+	points := make([]fpdf.PointType, len(outline))
+	for i, p := range outline {
+		points[i] = fpdf.PointType{X: p[0], Y: p[1]}
+	}
+	pdf.SetFillColor(color[0], color[1], color[2])
+	pdf.Polygon(points, "F")
+}
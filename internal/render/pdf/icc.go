@@ -0,0 +1,163 @@
+package pdf
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"math"
+	"strings"
+	"unicode/utf16"
+)
+
+// srgbName is the output condition of the built in sRGB profile, as the ICC
+// registry names it
+const srgbName = "sRGB IEC61966-2.1"
+
+// iccProfile is what an output intent needs to know of an ICC profile
+type iccProfile struct {
+	// components is the number of color components of the profile's color
+	// space: 1 for gray, 3 for RGB and 4 for CMYK
+	components  int
+	description string
+}
+
+// parseICCProfile reads the header of an ICC profile and its description.
+// Only gray, RGB and CMYK profiles can describe an output device.
+func parseICCProfile(data []byte) (*iccProfile, error) {
+	if len(data) < 132 || string(data[36:40]) != "acsp" {
+		return nil, errors.New("not an ICC profile")
+	}
+	p := &iccProfile{}
+	switch string(data[16:20]) {
+	case "GRAY":
+		p.components = 1
+	case "RGB ":
+		p.components = 3
+	case "CMYK":
+		p.components = 4
+	default:
+		return nil, errors.New("unsupported color space " + strings.TrimSpace(string(data[16:20])))
+	}
+	switch string(data[12:16]) {
+	case "prtr", "mntr", "scnr":
+	default:
+		return nil, errors.New("not the profile of a device")
+	}
+	count := int(binary.BigEndian.Uint32(data[128:]))
+	for i := 0; i < count && 132+12*i+12 <= len(data); i++ {
+		entry := data[132+12*i:]
+		if string(entry[:4]) != "desc" {
+			continue
+		}
+		off, size := int(binary.BigEndian.Uint32(entry[4:])), int(binary.BigEndian.Uint32(entry[8:]))
+		if off >= 0 && size >= 0 && off+size <= len(data) {
+			p.description = iccText(data[off : off+size])
+		}
+		break
+	}
+	return p, nil
+}
+
+// iccText returns the text of a description tag: the ASCII text of a
+// version 2 textDescriptionType or the first record of a version 4
+// multiLocalizedUnicodeType
+func iccText(tag []byte) string {
+	switch {
+	case len(tag) >= 12 && string(tag[:4]) == "desc":
+		n := int(binary.BigEndian.Uint32(tag[8:]))
+		if n > len(tag)-12 {
+			return ""
+		}
+		return strings.TrimRight(string(tag[12:12+n]), "\x00")
+	case len(tag) >= 28 && string(tag[:4]) == "mluc":
+		n, off := int(binary.BigEndian.Uint32(tag[20:])), int(binary.BigEndian.Uint32(tag[24:]))
+		if off+n > len(tag) || n%2 != 0 {
+			return ""
+		}
+		units := make([]uint16, n/2)
+		for i := range units {
+			units[i] = binary.BigEndian.Uint16(tag[off+2*i:])
+		}
+		return string(utf16.Decode(units))
+	}
+	return ""
+}
+
+// srgbProfile returns a version 2 ICC profile of the sRGB color space, the
+// color space of CSS colors and of most images: the primaries adapted to
+// the D50 illuminant of the profile connection space and the sRGB transfer
+// function as a table
+func srgbProfile() []byte {
+	xyz := func(x, y, z float64) []byte {
+		b := []byte("XYZ \x00\x00\x00\x00")
+		for _, v := range []float64{x, y, z} {
+			b = binary.BigEndian.AppendUint32(b, uint32(int32(math.Round(v*65536))))
+		}
+		return b
+	}
+	const points = 1024
+	trc := []byte("curv\x00\x00\x00\x00")
+	trc = binary.BigEndian.AppendUint32(trc, points)
+	for i := 0; i < points; i++ {
+		v := float64(i) / (points - 1)
+		if v <= 0.04045 {
+			v /= 12.92
+		} else {
+			v = math.Pow((v+0.055)/1.055, 2.4)
+		}
+		trc = binary.BigEndian.AppendUint16(trc, uint16(math.Round(v*65535)))
+	}
+	desc := []byte("desc\x00\x00\x00\x00")
+	desc = binary.BigEndian.AppendUint32(desc, uint32(len(srgbName)+1))
+	desc = append(desc, srgbName+"\x00"...)
+	// No Unicode or ScriptCode description
+	desc = append(desc, make([]byte, 4+4+2+1+67)...)
+	tags := []struct {
+		sig  string
+		data []byte
+	}{
+		{"desc", desc},
+		{"cprt", []byte("text\x00\x00\x00\x00No copyright, use freely\x00")},
+		{"wtpt", xyz(0.9505, 1, 1.0891)},
+		{"rXYZ", xyz(0.4360747, 0.2225045, 0.0139322)},
+		{"gXYZ", xyz(0.3850649, 0.7168786, 0.0971045)},
+		{"bXYZ", xyz(0.1430804, 0.0606169, 0.7141733)},
+		{"rTRC", trc},
+		{"gTRC", trc},
+		{"bTRC", trc},
+	}
+
+	var table, data bytes.Buffer
+	start := 128 + 4 + 12*len(tags)
+	offsets := make(map[*byte]int)
+	binary.Write(&table, binary.BigEndian, uint32(len(tags)))
+	for _, t := range tags {
+		// Tags with the same data share it
+		off, ok := offsets[&t.data[0]]
+		if !ok {
+			for data.Len()%4 != 0 {
+				data.WriteByte(0)
+			}
+			off = start + data.Len()
+			offsets[&t.data[0]] = off
+			data.Write(t.data)
+		}
+		table.WriteString(t.sig)
+		binary.Write(&table, binary.BigEndian, [2]uint32{uint32(off), uint32(len(t.data))})
+	}
+
+	header := make([]byte, 128)
+	binary.BigEndian.PutUint32(header[0:], uint32(start+data.Len()))
+	binary.BigEndian.PutUint32(header[8:], 0x02100000)
+	copy(header[12:], "mntrRGB XYZ ")
+	// Created 1 January 2024, so that the profile is the same every time
+	for i, v := range []uint16{2024, 1, 1} {
+		binary.BigEndian.PutUint16(header[24+2*i:], v)
+	}
+	copy(header[36:], "acsp")
+	// The D50 illuminant of the profile connection space
+	for i, v := range []float64{0.9642, 1, 0.8249} {
+		binary.BigEndian.PutUint32(header[68+4*i:], uint32(int32(math.Round(v*65536))))
+	}
+	return append(append(header, table.Bytes()...), data.Bytes()...)
+}
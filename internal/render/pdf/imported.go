@@ -0,0 +1,218 @@
+package pdf
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	"codeberg.org/go-pdf/fpdf"
+	"github.com/gompdf/gompdf/internal/layout"
+	"github.com/gompdf/gompdf/internal/logging"
+	pdfparser "github.com/gompdf/gompdf/internal/parser/pdf"
+	"github.com/gompdf/gompdf/internal/res"
+)
+
+// PDFPages are pages of an existing PDF document added after the pages of
+// the document, such as standard terms and conditions
+type PDFPages struct {
+	// Source is the path or URL of the PDF document, loaded like
+	// <img src>; Data holds the document instead
+	Source string
+	Data   []byte
+	// Pages are the numbers of the pages added, from 1, in order; all
+	// pages when empty
+	Pages []int
+}
+
+// importedPage is a page of another PDF document imported as a form
+// XObject, drawn by its name
+type importedPage struct {
+	name          string
+	width, height float64
+}
+
+// importedForms are the form XObjects of the pages imported so far, which
+// are written once the document is, as fpdf writes the objects it imports
+// in no particular order
+type importedForms struct {
+	// objects are the objects of the forms by hash, and refs where the
+	// hashes of the objects they refer to are in their data
+	objects map[string][]byte
+	refs    map[string]map[int]string
+	// names maps the names of the forms to their hashes
+	names map[string]string
+}
+
+// importPage imports a page of a document as a form XObject, named by the
+// form of the page
+func (r *Renderer) importPage(doc *pdfparser.Document, page int) (*importedPage, error) {
+	form, err := doc.Form(page)
+	if err != nil {
+		return nil, err
+	}
+	if form.Width <= 0 || form.Height <= 0 {
+		return nil, fmt.Errorf("page %d is empty", page)
+	}
+	name := "/PDFPage" + form.ID[:16]
+	if r.forms.objects == nil {
+		r.forms = importedForms{
+			objects: make(map[string][]byte),
+			refs:    make(map[string]map[int]string),
+			names:   make(map[string]string),
+		}
+	}
+	for h, data := range form.Objects {
+		r.forms.objects[h] = data
+		r.forms.refs[h] = form.Refs[h]
+	}
+	r.forms.names[name] = form.ID
+	return &importedPage{name: name, width: form.Width, height: form.Height}, nil
+}
+
+// patchImportedPages adds the objects of the imported forms, numbered in
+// the order of their hashes, and names the forms in the resource
+// dictionary fpdf writes for all pages
+func (r *Renderer) patchImportedPages(patch *pdfPatch) error {
+	if len(r.forms.names) == 0 {
+		return nil
+	}
+	hashes := make([]string, 0, len(r.forms.objects))
+	for h := range r.forms.objects {
+		hashes = append(hashes, h)
+	}
+	sort.Strings(hashes)
+	nums := make(map[string]int, len(hashes))
+	for _, h := range hashes {
+		num, err := patch.reserveObject()
+		if err != nil {
+			return err
+		}
+		nums[h] = num
+	}
+	for _, h := range hashes {
+		data := r.forms.objects[h]
+		refs := r.forms.refs[h]
+		offsets := make([]int, 0, len(refs))
+		for off := range refs {
+			offsets = append(offsets, off)
+		}
+		// From the end, so that the offsets before stay where they are
+		sort.Sort(sort.Reverse(sort.IntSlice(offsets)))
+		for _, off := range offsets {
+			data = append(append(data[:off:off], strconv.Itoa(nums[refs[off]])...), data[off+40:]...)
+		}
+		patch.defineObject(nums[h], string(data))
+	}
+
+	names := make([]string, 0, len(r.forms.names))
+	for name := range r.forms.names {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	var entries strings.Builder
+	for _, name := range names {
+		fmt.Fprintf(&entries, "%s %d 0 R\n", name, nums[r.forms.names[name]])
+	}
+	const resources = "\n2 0 obj\n"
+	at := bytes.Index(patch.data, []byte(resources))
+	if at < 0 {
+		return errors.New("resource dictionary not found")
+	}
+	_, err := patch.insertAfter(at, "/XObject <<\n", entries.String())
+	return err
+}
+
+// loadPDF reads a PDF document from data, or else loads it from source. what
+// names the document in warnings; nil is returned when it cannot be read.
+func (r *Renderer) loadPDF(what, source string, data []byte) *pdfparser.Document {
+	if data == nil {
+		if r.Loader == nil {
+			r.warn(logging.WarningResource, nil, "No loader set; cannot load %s %q\n", what, source)
+			return nil
+		}
+		resrc, err := r.Loader.Load(source)
+		if err != nil {
+			r.warn(logging.WarningResource, nil, "Failed to load %s %q: %v\n", what, source, err)
+			return nil
+		}
+		data = resrc.Data
+	}
+	doc, err := pdfparser.Read(data)
+	if err != nil {
+		r.warn(logging.WarningResource, nil, "Failed to read %s %q: %v\n", what, source, err)
+		return nil
+	}
+	return doc
+}
+
+// renderPDFPage draws the page of a PDF document an image shows, the one
+// the fragment of its source selects, scaled to where object-fit and
+// object-position place it. Pages are imported once per document drawn.
+func (r *Renderer) renderPDFPage(pdf *fpdf.Fpdf, box *layout.ImageBox, resrc *res.Resource) {
+	page := res.FragmentPage(box.Src)
+	key := fmt.Sprintf("%s#%d", box.Src, page)
+	if resrc.URL != "" {
+		key = fmt.Sprintf("%s#%d", resrc.URL, page)
+	}
+	p, ok := r.importedPages[key]
+	if !ok {
+		doc, err := pdfparser.Read(resrc.Data)
+		if err == nil {
+			p, err = r.importPage(doc, page)
+		}
+		if err != nil {
+			r.warn(logging.WarningImage, box.Node, "Failed to import page %d of %q: %v\n", page, box.Src, err)
+		}
+		r.importedPages[key] = p
+	}
+	if p == nil {
+		return
+	}
+	x, y, w, h := box.ObjectRect()
+	if box.ObjectOverflows() {
+		pdf.ClipRect(box.X, box.Y, box.Width, box.Height, false)
+		defer pdf.ClipEnd()
+	}
+	// fpdf places templates from the bottom of the page
+	pdf.UseImportedTemplate(p.name, w/p.width, h/p.height, x, -y-h)
+}
+
+// renderAppendedPages adds the appended pages of PDF documents after the
+// pages of the document, each page at its own size, with the watermark
+func (r *Renderer) renderAppendedPages(ctx context.Context, pdf *fpdf.Fpdf) error {
+	for _, a := range r.AppendedPages {
+		doc := r.loadPDF("appended document", a.Source, a.Data)
+		if doc == nil {
+			continue
+		}
+		pages := a.Pages
+		if len(pages) == 0 {
+			for n := 1; n <= doc.NumPages(); n++ {
+				pages = append(pages, n)
+			}
+		}
+		for _, n := range pages {
+			if err := ctx.Err(); err != nil {
+				return err
+			}
+			p, err := r.importPage(doc, n)
+			if err != nil {
+				r.warn(logging.WarningResource, nil, "Failed to import page %d of %q: %v\n", n, a.Source, err)
+				continue
+			}
+			pdf.AddPageFormat("P", fpdf.SizeType{Wd: p.width, Ht: p.height})
+			if r.Watermark != nil && !r.Watermark.Above {
+				r.renderWatermark(pdf, r.Watermark)
+			}
+			pdf.UseImportedTemplate(p.name, 1, 1, 0, -p.height)
+			if r.Watermark != nil && r.Watermark.Above {
+				r.renderWatermark(pdf, r.Watermark)
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,79 @@
+package pdf
+
+import (
+	"math"
+
+	"codeberg.org/go-pdf/fpdf"
+	"github.com/gompdf/gompdf/internal/logging"
+	pdfparser "github.com/gompdf/gompdf/internal/parser/pdf"
+)
+
+// Letterhead is stationery the pages are printed on: pages of an existing
+// PDF document, such as a company letterhead, drawn beneath the content
+type Letterhead struct {
+	// Source is the path or URL of the PDF document, loaded like <img src>;
+	// Data holds the document instead. Page is the page drawn beneath the
+	// first page, from 1; 0 means 1.
+	Source string
+	Data   []byte
+	Page   int
+	// ContinuationSource, ContinuationData and ContinuationPage give the
+	// stationery of the other pages in the same way. Without a document of
+	// its own it is a page of the letterhead's document; with none of them
+	// set the other pages have no stationery.
+	ContinuationSource string
+	ContinuationData   []byte
+	ContinuationPage   int
+}
+
+// importLetterhead imports the pages of the letterhead, returning those
+// drawn beneath the first page and beneath the others, nil when there is
+// none or it cannot be loaded
+func (r *Renderer) importLetterhead() (first, next *importedPage) {
+	lh := r.Letterhead
+	if lh == nil {
+		return nil, nil
+	}
+	doc := r.loadPDF("letterhead", lh.Source, lh.Data)
+	if doc == nil {
+		return nil, nil
+	}
+	first = r.importLetterheadPage(doc, lh.Page)
+	if lh.ContinuationSource == "" && lh.ContinuationData == nil && lh.ContinuationPage == 0 {
+		return first, nil
+	}
+	if lh.ContinuationSource != "" || lh.ContinuationData != nil {
+		if doc = r.loadPDF("letterhead", lh.ContinuationSource, lh.ContinuationData); doc == nil {
+			return first, nil
+		}
+	}
+	return first, r.importLetterheadPage(doc, lh.ContinuationPage)
+}
+
+// importLetterheadPage imports a page of a letterhead document, the first
+// when page is 0
+func (r *Renderer) importLetterheadPage(doc *pdfparser.Document, page int) *importedPage {
+	if page <= 0 {
+		page = 1
+	}
+	p, err := r.importPage(doc, page)
+	if err != nil {
+		r.warn(logging.WarningResource, nil, "Failed to import letterhead page: %v\n", err)
+		return nil
+	}
+	return p
+}
+
+// drawStationery draws an imported page beneath the content of the current
+// page, scaled to fit it and centered on it
+func drawStationery(pdf *fpdf.Fpdf, p *importedPage) {
+	if p == nil {
+		return
+	}
+	pageW, pageH := pdf.GetPageSize()
+	scale := math.Min(pageW/p.width, pageH/p.height)
+	w, h := p.width*scale, p.height*scale
+	x, y := (pageW-w)/2, (pageH-h)/2
+	// fpdf places templates from the bottom of the page
+	pdf.UseImportedTemplate(p.name, scale, scale, x, -y-h)
+}
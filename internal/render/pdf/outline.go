@@ -0,0 +1,121 @@
+package pdf
+
+import (
+	"strings"
+	"unicode/utf16"
+
+	"codeberg.org/go-pdf/fpdf"
+	"github.com/gompdf/gompdf/internal/layout"
+	"github.com/gompdf/gompdf/internal/pagination"
+	"github.com/gompdf/gompdf/internal/parser/html"
+	xhtml "golang.org/x/net/html"
+)
+
+// bookmark is an entry of the document outline: a heading and where its
+// page shows it
+type bookmark struct {
+	title string
+	level int
+	y     float64
+}
+
+// outline collects the bookmarks of the pages of a document, one page
+// after the other: one for each h1 to h6 heading on the page its first
+// fragment is on. Headings nest under the closest heading of a higher rank
+// before them, so that levels of the outline are never skipped.
+type outline struct {
+	seen map[*html.Node]bool
+	// open holds the ranks of the headings the next one may nest under
+	open []int
+}
+
+func newOutline() *outline {
+	return &outline{seen: make(map[*html.Node]bool)}
+}
+
+// bookmarks returns the bookmarks of the next page
+func (o *outline) bookmarks(page *pagination.Page) []bookmark {
+	var bookmarks []bookmark
+	var visit func(box layout.Box)
+	visit = func(box layout.Box) {
+		b, ok := box.(*layout.BlockBox)
+		if !ok {
+			return
+		}
+		if rank := headingRank(b.Node); rank > 0 && !o.seen[b.Node] {
+			o.seen[b.Node] = true
+			if title := headingTitle(b.Node); title != "" {
+				for len(o.open) > 0 && o.open[len(o.open)-1] >= rank {
+					o.open = o.open[:len(o.open)-1]
+				}
+				bookmarks = append(bookmarks, bookmark{title: title, level: len(o.open), y: b.Y})
+				o.open = append(o.open, rank)
+			}
+			return
+		}
+		for _, child := range b.Children {
+			visit(child)
+		}
+	}
+	for _, box := range page.Boxes {
+		visit(box)
+	}
+	return bookmarks
+}
+
+// headingRank returns the rank of an h1 to h6 element, 0 for other nodes
+func headingRank(n *html.Node) int {
+	if n == nil || n.Type != xhtml.ElementNode || len(n.Data) != 2 || (n.Data[0] != 'h' && n.Data[0] != 'H') || n.Data[1] < '1' || n.Data[1] > '6' {
+		return 0
+	}
+	return int(n.Data[1] - '0')
+}
+
+// headingTitle returns the text of a heading with white space collapsed
+func headingTitle(n *html.Node) string {
+	var b strings.Builder
+	var collect func(*html.Node)
+	collect = func(n *html.Node) {
+		if n.Type == xhtml.TextNode {
+			b.WriteString(n.Data)
+		}
+		for c := n.FirstChild; c != nil; c = c.NextSibling {
+			collect(c)
+		}
+	}
+	collect(n)
+	return strings.Join(strings.Fields(b.String()), " ")
+}
+
+// addBookmarks adds the bookmarks of the current page to the outline. fpdf
+// encodes their titles as the current font does, so a core font is selected
+// first and titles beyond ASCII are given in UTF-16 with a byte order mark.
+func addBookmarks(pdf *fpdf.Fpdf, bookmarks []bookmark) {
+	if len(bookmarks) == 0 {
+		return
+	}
+	pdf.SetFont("Helvetica", "", 12)
+	for _, bm := range bookmarks {
+		pdf.Bookmark(outlineTitle(bm.title), bm.level, bm.y)
+	}
+}
+
+// outlineTitle encodes the title of an outline entry as a PDF text string
+// without delimiters
+func outlineTitle(s string) string {
+	ascii := true
+	for i := 0; i < len(s); i++ {
+		if s[i] >= 0x80 {
+			ascii = false
+			break
+		}
+	}
+	if ascii {
+		return s
+	}
+	b := []byte{0xFE, 0xFF}
+	for _, u := range utf16.Encode([]rune(s)) {
+		b = append(b, byte(u>>8), byte(u))
+	}
+	return string(b)
+}
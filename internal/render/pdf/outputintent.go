@@ -0,0 +1,109 @@
+package pdf
+
+import (
+	"bytes"
+	"compress/zlib"
+	"fmt"
+
+	"github.com/gompdf/gompdf/internal/logging"
+)
+
+// OutputIntent is the color profile of the device the document is meant
+// for, which print vendors and PDF/A readers take its colors to be in
+type OutputIntent struct {
+	// Profile is an ICC profile of a gray, RGB or CMYK device; Source is
+	// the path or URL it is loaded from instead, loaded like <img src>.
+	// Without either the sRGB profile, built in, is embedded.
+	Source  string
+	Profile []byte
+	// Subtype is the standard the intent is for: GTS_PDFA1 for PDF/A,
+	// GTS_PDFX for PDF/X; "" means GTS_PDFA1
+	Subtype string
+	// Identifier names the output condition, such as "FOGRA39" for a
+	// condition of the ICC registry, RegistryName the registry that defines
+	// it and Condition and Info describe it for people. Identifier and Info
+	// default to the description of the profile.
+	Identifier   string
+	RegistryName string
+	Condition    string
+	Info         string
+}
+
+// patchOutputIntent embeds the ICC profile of the output intent and adds
+// the intent to the catalog
+func (r *Renderer) patchOutputIntent(patch *pdfPatch) error {
+	oi := r.OutputIntent
+	if oi == nil {
+		return nil
+	}
+	data := oi.Profile
+	identifier, registry := oi.Identifier, oi.RegistryName
+	switch {
+	case data != nil:
+	case oi.Source != "":
+		if r.Loader == nil {
+			r.warn(logging.WarningResource, nil, "No loader set; cannot load ICC profile %q\n", oi.Source)
+			return nil
+		}
+		resrc, err := r.Loader.Load(oi.Source)
+		if err != nil {
+			r.warn(logging.WarningResource, nil, "Failed to load ICC profile %q: %v\n", oi.Source, err)
+			return nil
+		}
+		data = resrc.Data
+	default:
+		data = srgbProfile()
+		if identifier == "" {
+			identifier = srgbName
+		}
+		if registry == "" {
+			registry = "http://www.color.org"
+		}
+	}
+	profile, err := parseICCProfile(data)
+	if err != nil {
+		r.warn(logging.WarningResource, nil, "Failed to read ICC profile of the output intent: %v\n", err)
+		return nil
+	}
+	if identifier == "" {
+		identifier = profile.description
+	}
+	if identifier == "" {
+		identifier = "Custom"
+	}
+	info := oi.Info
+	if info == "" {
+		info = profile.description
+	}
+	subtype := oi.Subtype
+	if subtype == "" {
+		subtype = "GTS_PDFA1"
+	}
+
+	var z bytes.Buffer
+	zw := zlib.NewWriter(&z)
+	zw.Write(data)
+	zw.Close()
+	num, err := patch.addObject(fmt.Sprintf("<< /N %d /Filter /FlateDecode /Length %d >>\nstream\n%s\nendstream",
+		profile.components, z.Len(), z.Bytes()))
+	if err != nil {
+		return err
+	}
+	intent := fmt.Sprintf("<< /Type /OutputIntent /S /%s /OutputConditionIdentifier %s",
+		pdfName(subtype), pdfTextString(identifier))
+	for _, e := range []struct{ key, value string }{
+		{"OutputCondition", oi.Condition},
+		{"RegistryName", registry},
+		{"Info", info},
+	} {
+		if e.value != "" {
+			intent += fmt.Sprintf(" /%s %s", e.key, pdfTextString(e.value))
+		}
+	}
+	intent += fmt.Sprintf(" /DestOutputProfile %d 0 R >>", num)
+	intentNum, err := patch.addObject(intent)
+	if err != nil {
+		return err
+	}
+	return patch.addToCatalog(fmt.Sprintf("/OutputIntents [%d 0 R]", intentNum))
+}
@@ -0,0 +1,242 @@
+package pdf
+
+import (
+	"bytes"
+	"crypto/md5"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// pdfPatch inserts text into the objects of a PDF written by fpdf, which has
+// no way to extend the catalog or the dictionaries of the objects it writes,
+// and adds objects of its own after them. Insertions move the objects after
+// them, so the offsets of the cross-reference table are moved to match.
+type pdfPatch struct {
+	data    []byte
+	inserts []pdfInsert
+	// objects are the added objects, numbered on from the last object of
+	// the document; "" for numbers reserved but not yet defined
+	objects []string
+	size    int
+}
+
+// pdfInsert is text inserted at a byte offset of the original document
+type pdfInsert struct {
+	at   int
+	text string
+}
+
+// newPDFPatch starts patching a document
+func newPDFPatch(data []byte) *pdfPatch {
+	return &pdfPatch{data: data}
+}
+
+// insert inserts text at a byte offset of the original document
+func (p *pdfPatch) insert(at int, text string) {
+	p.inserts = append(p.inserts, pdfInsert{at: at, text: text})
+}
+
+// insertAfter inserts text right after the first occurrence of marker at or
+// after offset from, returning the offset just past the marker
+func (p *pdfPatch) insertAfter(from int, marker, text string) (int, error) {
+	i := bytes.Index(p.data[from:], []byte(marker))
+	if i < 0 {
+		return 0, fmt.Errorf("%q not found", marker)
+	}
+	at := from + i + len(marker)
+	p.insert(at, text)
+	return at, nil
+}
+
+// reserveObject returns the number of a new object, which defineObject
+// then gives its content, so that objects can refer to each other
+func (p *pdfPatch) reserveObject() (int, error) {
+	if p.size == 0 {
+		size, err := trailerSize(p.data)
+		if err != nil {
+			return 0, err
+		}
+		p.size = size
+	}
+	p.objects = append(p.objects, "")
+	return p.size + len(p.objects) - 1, nil
+}
+
+// defineObject sets the content of a reserved object: a dictionary or a
+// stream, without its obj and endobj lines
+func (p *pdfPatch) defineObject(num int, content string) {
+	p.objects[num-p.size] = content
+}
+
+// addObject adds an object and returns its number
+func (p *pdfPatch) addObject(content string) (int, error) {
+	num, err := p.reserveObject()
+	if err != nil {
+		return 0, err
+	}
+	p.defineObject(num, content)
+	return num, nil
+}
+
+// trailerSize returns the /Size of the trailer: the number of objects of
+// the document, plus one for object 0
+func trailerSize(data []byte) (int, error) {
+	const key = "/Size "
+	at := bytes.LastIndex(data, []byte("trailer\n"))
+	if at < 0 {
+		return 0, errors.New("trailer not found")
+	}
+	i := bytes.Index(data[at:], []byte(key))
+	if i < 0 {
+		return 0, errors.New("trailer size not found")
+	}
+	start := at + i + len(key)
+	end := start
+	for end < len(data) && data[end] >= '0' && data[end] <= '9' {
+		end++
+	}
+	size, err := strconv.Atoi(string(data[start:end]))
+	if err != nil {
+		return 0, fmt.Errorf("malformed trailer size: %w", err)
+	}
+	return size, nil
+}
+
+// addID adds the file identifier of the document to the trailer, which fpdf
+// leaves out: a hash of the document as fpdf wrote it, so that the same
+// document, written at the same date, has the same identifier
+func (p *pdfPatch) addID() error {
+	at := bytes.LastIndex(p.data, []byte("trailer\n"))
+	if at < 0 {
+		return errors.New("trailer not found")
+	}
+	sum := md5.Sum(p.data)
+	id := hex.EncodeToString(sum[:])
+	_, err := p.insertAfter(at, "trailer\n<<\n", fmt.Sprintf("/ID [<%s> <%s>]\n", id, id))
+	return err
+}
+
+// addToCatalog adds entries to the document catalog. fpdf writes the
+// catalog last, right before the cross-reference table.
+func (p *pdfPatch) addToCatalog(entries string) error {
+	if entries == "" {
+		return nil
+	}
+	const catalog = "/Type /Catalog\n"
+	at := bytes.LastIndex(p.data, []byte(catalog))
+	if at < 0 {
+		return errors.New("catalog not found")
+	}
+	p.insert(at+len(catalog), entries+"\n")
+	return nil
+}
+
+// bytes returns the patched document
+func (p *pdfPatch) bytes() ([]byte, error) {
+	if len(p.inserts) == 0 && len(p.objects) == 0 {
+		return p.data, nil
+	}
+	start := bytes.LastIndex(p.data, []byte("startxref\n"))
+	if start < 0 {
+		return nil, errors.New("startxref not found")
+	}
+	numStart := start + len("startxref\n")
+	numEnd := bytes.IndexByte(p.data[numStart:], '\n')
+	if numEnd < 0 {
+		return nil, errors.New("malformed startxref")
+	}
+	numEnd += numStart
+	xref, err := strconv.Atoi(string(p.data[numStart:numEnd]))
+	if err != nil {
+		return nil, fmt.Errorf("malformed startxref: %w", err)
+	}
+
+	// Added objects go between the last object and the table
+	var block strings.Builder
+	offsets := make([]int, len(p.objects))
+	for i, content := range p.objects {
+		offsets[i] = block.Len()
+		fmt.Fprintf(&block, "%d 0 obj\n%s\nendobj\n", p.size+i, content)
+	}
+	if block.Len() > 0 {
+		p.insert(xref, block.String())
+	}
+
+	sort.SliceStable(p.inserts, func(i, j int) bool { return p.inserts[i].at < p.inserts[j].at })
+	// shift returns where an offset of the original document moves to
+	shift := func(off int) int {
+		moved := off
+		for _, ins := range p.inserts {
+			if ins.at > off {
+				break
+			}
+			moved += len(ins.text)
+		}
+		return moved
+	}
+
+	var out bytes.Buffer
+	out.Grow(len(p.data))
+	last := 0
+	for _, ins := range p.inserts {
+		out.Write(p.data[last:ins.at])
+		out.WriteString(ins.text)
+		last = ins.at
+	}
+	out.Write(p.data[last:numStart])
+	out.WriteString(strconv.Itoa(shift(xref)))
+	out.Write(p.data[numEnd:])
+	data := out.Bytes()
+
+	// The table is a line with the first object number and count followed
+	// by one 20 byte entry per object, offsets first
+	table := shift(xref)
+	lines := bytes.SplitN(data[table:], []byte("\n"), 3)
+	if len(lines) < 3 || string(lines[0]) != "xref" {
+		return nil, errors.New("cross-reference table not found")
+	}
+	var first, count int
+	if _, err := fmt.Sscanf(string(lines[1]), "%d %d", &first, &count); err != nil {
+		return nil, fmt.Errorf("malformed cross-reference table: %w", err)
+	}
+	entries := table + len(lines[0]) + len(lines[1]) + 2
+	if entries+20*count > len(data) {
+		return nil, errors.New("truncated cross-reference table")
+	}
+	for i := 0; i < count; i++ {
+		e := data[entries+20*i : entries+20*i+20]
+		if e[17] != 'n' {
+			continue
+		}
+		off, err := strconv.Atoi(string(e[:10]))
+		if err != nil {
+			return nil, fmt.Errorf("malformed cross-reference entry: %w", err)
+		}
+		copy(e, fmt.Sprintf("%010d", shift(off)))
+	}
+	if len(p.objects) == 0 {
+		return data, nil
+	}
+
+	// The entries of the added objects follow those of the others, and the
+	// trailer counts them
+	if first != 0 || count != p.size {
+		return nil, errors.New("unexpected cross-reference table")
+	}
+	end := entries + 20*count
+	var patched bytes.Buffer
+	patched.Grow(len(data) + 20*len(p.objects))
+	patched.Write(data[:table])
+	fmt.Fprintf(&patched, "xref\n0 %d\n", count+len(p.objects))
+	patched.Write(data[entries:end])
+	for _, off := range offsets {
+		fmt.Fprintf(&patched, "%010d 00000 n \n", table-block.Len()+off)
+	}
+	trailer := bytes.Replace(data[end:], []byte(fmt.Sprintf("/Size %d\n", count)), []byte(fmt.Sprintf("/Size %d\n", count+len(p.objects))), 1)
+	patched.Write(trailer)
+	return patched.Bytes(), nil
+}
@@ -2,21 +2,29 @@ package pdf
 
 import (
 	"bytes"
+	"compress/zlib"
+	"context"
 	"fmt"
 	"image"
 	"image/png"
 	"math"
 	"os"
 	"path/filepath"
-	"strconv"
 	"strings"
+	"time"
 
 	"codeberg.org/go-pdf/fpdf"
+	"github.com/gompdf/gompdf/internal/fonts"
 	"github.com/gompdf/gompdf/internal/layout"
+	"github.com/gompdf/gompdf/internal/logging"
 	"github.com/gompdf/gompdf/internal/pagination"
+	"github.com/gompdf/gompdf/internal/parser/html"
 	"github.com/gompdf/gompdf/internal/res"
+	"github.com/gompdf/gompdf/internal/style"
+	"github.com/gompdf/gompdf/internal/text"
 	"github.com/srwiley/oksvg"
 	"github.com/srwiley/rasterx"
+	xhtml "golang.org/x/net/html"
 )
 
 // Renderer handles rendering to PDF
@@ -24,7 +32,7 @@ type Renderer struct {
 	// Configuration options
 	FontDirs []string
 	DPI      float64
-	// Debug enables verbose logging to stdout
+	// Debug enables verbose tracing through Logger
 	Debug bool
 	// RenderBackgrounds controls whether box backgrounds are painted
 	RenderBackgrounds bool
@@ -32,18 +40,110 @@ type Renderer struct {
 	RenderBorders bool
 	// DebugDrawBoxes controls drawing of debug overlays (outlines/placeholder fills)
 	DebugDrawBoxes bool
-	// listStack tracks nested list contexts while rendering
-	listStack []listContext
-	// renderedTexts tracks which text boxes have been rendered to avoid duplicates
+	// renderedTexts tracks which text boxes of the page being drawn have been
+	// rendered to avoid duplicates
 	renderedTexts map[string]bool
 	// Loader allows resolving images and other resources
 	Loader *res.Loader
+	// PageLoaders resolve the resources of the pages they are given for
+	// instead of Loader, such as pages of documents merged from other
+	// directories
+	PageLoaders map[*pagination.Page]*res.Loader
+	// Bookmarks adds the headings of the pages to the document outline
+	Bookmarks bool
+	// Fonts holds the font faces available in addition to the core fonts
+	Fonts *fonts.Registry
+	// Logger receives warnings and, when Debug is set, verbose tracing.
+	// A nil Logger discards them.
+	Logger logging.Logger
+	// Watermark, when set, is stamped on every page
+	Watermark *Watermark
+	// Letterhead, when set, is the stationery the pages are printed on
+	Letterhead *Letterhead
+	// AppendedPages are pages of other PDF documents added after the pages
+	// of the document
+	AppendedPages []PDFPages
+	// CreationDate, when set, is the creation and modification date of the
+	// document instead of the time it is written, so that the same pages
+	// are written to the same bytes
+	CreationDate time.Time
+	// MaxImageDPI, when above 0, scales raster images down to that many
+	// pixels per inch of the size they are drawn at
+	MaxImageDPI float64
+	// JPEGQuality, from 1 to 100, compresses JPEG images again at that
+	// quality; 0 keeps them as they are, re-encoding them at 92
+	JPEGQuality int
+	// CompressionLevel is the zlib level of the streams of the document,
+	// from 1 to 9; CompressionDefault leaves it to fpdf, which compresses
+	// for speed, and CompressionNone leaves the content of pages
+	// uncompressed
+	CompressionLevel int
+	// SubsetFonts embeds only the glyphs of the characters drawn with a
+	// face; without it faces are embedded whole, for editing the document
+	// or filling its form fields with any character
+	SubsetFonts bool
+	// PDFVersion, when set, is the version of PDF the document is written
+	// as, from "1.3" to "1.7" or "2.0": features the version lacks are left
+	// out or drawn without them, or rendering fails. Without it the
+	// document has the version of the features it uses.
+	PDFVersion string
+	// OutputIntent, when set, embeds the color profile of the device the
+	// document is meant for
+	OutputIntent *OutputIntent
+	// Attachments are embedded files of the document
+	Attachments []Attachment
+	// FormFields makes the form controls of the document fillable fields
+	// of an interactive form
+	FormFields bool
+	// OnPage, when set, is called after the page of the given index is
+	// drawn; an error stops rendering with it
+	OnPage func(index int) error
+	// ReleasePages drops the boxes of every page once it is drawn, so that
+	// the memory of a long document is given back as rendering advances.
+	// The pages cannot be rendered again.
+	ReleasePages bool
+	// page is the page being rendered and pageCount the number of pages,
+	// for the page counters of generated content
+	page      *pagination.Page
+	pageCount int
+	// targets maps element ids to the page they start on
+	targets map[string]*pagination.Page
+	// textShaper shapes text set in registered faces; see shaper
+	textShaper *text.TextShaper
+	// fields are the fillable form fields of the pages drawn so far
+	fields []formField
+	// importedPages are the pages of PDF documents drawn by images so far,
+	// by source and page; nil for those that cannot be imported
+	importedPages map[string]*importedPage
+	// forms are the pages of PDF documents imported so far
+	forms importedForms
+	// destinations are the named destinations of the pages drawn so far
+	destinations []destination
+	// faces are the registered faces drawn with so far
+	faces map[*fonts.Face]bool
+	// doc is the document being rendered, from Begin to End
+	doc *document
+}
+
+// debugf forwards a debug message to the renderer's logger
+func (r *Renderer) debugf(format string, args ...any) {
+	logging.Or(r.Logger).Debugf(format, args...)
+}
+
+// warn reports a recoverable problem about node, which may be nil, to the
+// renderer's logger
+func (r *Renderer) warn(code logging.WarningCode, node *html.Node, format string, args ...any) {
+	var path string
+	if node != nil {
+		path = node.Path()
+	}
+	logging.Warnf(r.Logger, code, path, format, args...)
 }
 
 // resourceToPNG decodes a resource image (including SVG) and returns PNG bytes.
 // For SVG, it rasterizes to approximately the requested pixel size (w x h).
 func (r *Renderer) resourceToPNG(resrc *res.Resource, w, h int) ([]byte, error) {
-	if strings.EqualFold(strings.TrimSpace(resrc.MimeType), "image/svg+xml") {
+	if resrc.IsSVG() {
 		// Rasterize SVG using oksvg
 		if w <= 0 { w = 100 }
 		if h <= 0 { h = 100 }
@@ -85,12 +185,92 @@ func (r *Renderer) resourceToPNG(resrc *res.Resource, w, h int) ([]byte, error)
 	return buf.Bytes(), nil
 }
 
+// registerImage embeds an image resource into the document and returns the
+// name to draw it with. JPEG is embedded as is; everything else is converted
+// to PNG so fpdf can handle all formats consistently (including SVG, which is
+// rasterized for the given size). Raster images are registered once per
+// source and reused; sources are told apart by where they were loaded from,
+// as the same relative src of pages of different documents may not be the
+// same image. An EXIF orientation other than 1 turns a raster image upright,
+// re-encoding it, as do scaling it down to MaxImageDPI and compressing JPEG
+// images again at JPEGQuality.
+func (r *Renderer) registerImage(pdf *fpdf.Fpdf, node *html.Node, src string, resrc *res.Resource, w, h float64, orientation int) (string, bool) {
+	name := "img-" + src
+	if resrc.URL != "" {
+		name = "img-" + resrc.URL
+	}
+	var scaleW, scaleH int
+	if resrc.IsSVG() {
+		name = fmt.Sprintf("%s-%.0fx%.0f", name, w, h)
+		orientation = 1
+	} else {
+		scaleW, scaleH = r.downsampleSize(resrc.Data, w, h, orientation)
+	}
+	if orientation > 1 {
+		name = fmt.Sprintf("%s-orientation%d", name, orientation)
+	}
+	if scaleW > 0 {
+		name = fmt.Sprintf("%s-%dx%d", name, scaleW, scaleH)
+	}
+	if pdf.GetImageInfo(name) != nil {
+		return name, true
+	}
+	if orientation > 1 || scaleW > 0 || (r.JPEGQuality > 0 && isJPEG(resrc.Data)) {
+		data, imageType, err := r.reencodeImage(resrc.Data, orientation, scaleW, scaleH)
+		if err != nil {
+			r.warn(logging.WarningImage, node, "Failed to re-encode image %q: %v\n", src, err)
+			return "", false
+		}
+		if imageType == "PNG" {
+			if data, err = r.pngForVersion(data); err != nil {
+				r.warn(logging.WarningImage, node, "Failed to convert image %q for PDF %s: %v\n", src, r.PDFVersion, err)
+				return "", false
+			}
+		}
+		pdf.RegisterImageOptionsReader(name, fpdf.ImageOptions{ImageType: imageType}, bytes.NewReader(data))
+		if err := pdf.Error(); err != nil {
+			r.warn(logging.WarningImage, node, "Failed to embed image %q: %v\n", src, err)
+			pdf.ClearError()
+			return "", false
+		}
+		return name, true
+	}
+	if isJPEG(resrc.Data) {
+		pdf.RegisterImageOptionsReader(name, fpdf.ImageOptions{ImageType: "JPG"}, bytes.NewReader(resrc.Data))
+		if pdf.Error() == nil {
+			return name, true
+		}
+		// Some JPEG variants are not supported by fpdf; re-encode them below
+		pdf.ClearError()
+	}
+	pngBytes, err := r.resourceToPNG(resrc, int(math.Ceil(w)), int(math.Ceil(h)))
+	if err != nil {
+		r.warn(logging.WarningImage, node, "Failed to convert image %q to PNG: %v\n", src, err)
+		return "", false
+	}
+	if pngBytes, err = r.pngForVersion(pngBytes); err != nil {
+		r.warn(logging.WarningImage, node, "Failed to convert image %q for PDF %s: %v\n", src, r.PDFVersion, err)
+		return "", false
+	}
+	pdf.RegisterImageOptionsReader(name, fpdf.ImageOptions{ImageType: "PNG", ReadDpi: true}, bytes.NewReader(pngBytes))
+	if err := pdf.Error(); err != nil {
+		// Do not let one undecodable image fail the whole document
+		r.warn(logging.WarningImage, node, "Failed to embed image %q: %v\n", src, err)
+		pdf.ClearError()
+		return "", false
+	}
+	return name, true
+}
+
+// isJPEG reports whether data starts with the JPEG SOI marker
+func isJPEG(data []byte) bool {
+	return len(data) > 3 && data[0] == 0xFF && data[1] == 0xD8 && data[2] == 0xFF
+}
+
 // renderImageBox draws an image for an ImageBox using the configured Loader.
 func (r *Renderer) renderImageBox(pdf *fpdf.Fpdf, box *layout.ImageBox) {
 	if r.Loader == nil {
-		if r.Debug {
-			fmt.Printf("No loader set; cannot render image src=%q\n", box.Src)
-		}
+		r.warn(logging.WarningResource, box.Node, "No loader set; cannot render image src=%q\n", box.Src)
 		return
 	}
 	if strings.TrimSpace(box.Src) == "" {
@@ -98,24 +278,26 @@ func (r *Renderer) renderImageBox(pdf *fpdf.Fpdf, box *layout.ImageBox) {
 	}
 	resrc, err := r.Loader.LoadImage(box.Src)
 	if err != nil {
-		if r.Debug {
-			fmt.Printf("Failed to load image %q: %v\n", box.Src, err)
-		}
+		r.warn(logging.WarningResource, box.Node, "Failed to load image %q: %v\n", box.Src, err)
 		return
 	}
-	// Convert to PNG bytes so fpdf can handle all formats consistently (including SVG via rasterization)
-	pngBytes, err := r.resourceToPNG(resrc, int(math.Ceil(box.Width)), int(math.Ceil(box.Height)))
-	if err != nil {
-		if r.Debug {
-			fmt.Printf("Failed to convert image %q to PNG: %v\n", box.Src, err)
-		}
+	if resrc.IsPDF() {
+		r.renderPDFPage(pdf, box, resrc)
+		return
+	}
+	// object-fit and object-position place the image within the box
+	x, y, w, h := box.ObjectRect()
+	name, ok := r.registerImage(pdf, box.Node, box.Src, resrc, w, h, box.Orientation)
+	if !ok {
 		return
 	}
-	name := fmt.Sprintf("img-%p", box)
-	opt := fpdf.ImageOptions{ImageType: "PNG", ReadDpi: true}
-	pdf.RegisterImageOptionsReader(name, opt, bytes.NewReader(pngBytes))
-	// Place image at top-left of box with specified width/height
-	pdf.ImageOptions(name, box.X, box.Y, box.Width, box.Height, false, opt, 0, "")
+	if box.ObjectOverflows() {
+		pdf.ClipRect(box.X, box.Y, box.Width, box.Height, false)
+		pdf.ImageOptions(name, x, y, w, h, false, fpdf.ImageOptions{}, 0, "")
+		pdf.ClipEnd()
+	} else {
+		pdf.ImageOptions(name, x, y, w, h, false, fpdf.ImageOptions{}, 0, "")
+	}
 
 	if r.DebugDrawBoxes {
 		pdf.SetDrawColor(0, 150, 0)
@@ -123,13 +305,6 @@ func (r *Renderer) renderImageBox(pdf *fpdf.Fpdf, box *layout.ImageBox) {
 	}
 }
 
-// listContext represents an active list (ul/ol) while rendering
-type listContext struct {
-	kind    string // "ul" or "ol"
-	style   string // list-style-type
-	counter int    // for ordered lists
-}
-
 // RenderOptions contains options for rendering
 type RenderOptions struct {
 	Title       string
@@ -150,6 +325,7 @@ func NewRenderer(loader *res.Loader) *Renderer {
 		RenderBackgrounds: true,
 		RenderBorders:     true,
 		DebugDrawBoxes:    false,
+		SubsetFonts:       true,
 		renderedTexts:     make(map[string]bool),
 		Loader:            loader,
 	}
@@ -162,8 +338,45 @@ func (r *Renderer) AddFontDirectory(dir string) {
 
 // Render renders pages to a PDF file
 func (r *Renderer) Render(pages []*pagination.Page, outputPath string, options RenderOptions) error {
+	return r.RenderContext(context.Background(), pages, outputPath, options)
+}
+
+// RenderContext renders pages to a PDF file, stopping with the context's
+// error if it is done before rendering completes
+func (r *Renderer) RenderContext(ctx context.Context, pages []*pagination.Page, outputPath string, options RenderOptions) error {
+	if err := r.Begin(ctx, options, len(pages), pagination.Targets(pages)); err != nil {
+		return err
+	}
+	if r.Debug {
+		r.debugf("Rendering %d pages\n", len(pages))
+	}
+	for _, page := range pages {
+		if err := r.RenderPage(page); err != nil {
+			return err
+		}
+	}
+	return r.End(outputPath)
+}
+
+// Begin starts rendering a document of pageCount pages page by page, for
+// pages that are not all at hand at once: RenderPage draws each of them in
+// order and End writes the document. targets maps element ids to the page
+// they start on, for the cross references of generated content; see
+// pagination.Targets.
+func (r *Renderer) Begin(ctx context.Context, options RenderOptions, pageCount int, targets map[string]*pagination.Page) error {
+	if err := r.checkVersion(); err != nil {
+		return err
+	}
+	if err := checkCompressionLevel(r.CompressionLevel); err != nil {
+		return err
+	}
 	// Reset the rendered texts map to ensure clean state for each rendering
 	r.renderedTexts = make(map[string]bool)
+	r.fields = nil
+	r.importedPages = make(map[string]*importedPage)
+	r.forms = importedForms{}
+	r.destinations = nil
+	r.faces = make(map[*fonts.Face]bool)
 
 	// Always use the orientation from options
 	orient := options.Orientation
@@ -172,6 +385,14 @@ func (r *Renderer) Render(pages []*pagination.Page, outputPath string, options R
 	}
 
 	pdf := fpdf.New(orient, "pt", "", "")
+	pdf.SetCompression(r.CompressionLevel != CompressionNone)
+	// Fonts and images are listed in the order they are added, not in the
+	// order of a map, so that documents come out the same every time
+	pdf.SetCatalogSort(true)
+	if !r.CreationDate.IsZero() {
+		pdf.SetCreationDate(r.CreationDate)
+		pdf.SetModificationDate(r.CreationDate)
+	}
 
 	pdf.SetAutoPageBreak(true, 2)
 	pdf.SetTitle(options.Title, true)
@@ -181,47 +402,132 @@ func (r *Renderer) Render(pages []*pagination.Page, outputPath string, options R
 	pdf.SetCreator(options.Creator, true)
 	pdf.SetProducer(options.Producer, true)
 	r.registerFonts(pdf)
+	r.setAttachments(pdf)
 
-	// Process each page - skip truly empty pages
-	fmt.Printf("Rendering %d pages\n", len(pages))
-	for i, page := range pages {
-		// Skip pages with no boxes at all
-		if len(page.Boxes) == 0 {
-			fmt.Printf("Skipping empty page %d (no boxes)\n", i)
-			continue
+	r.doc = &document{
+		pdf:     pdf,
+		ctx:     ctx,
+		loader:  r.Loader,
+		anchors: make(map[string]bool),
+	}
+	r.doc.firstSheet, r.doc.nextSheet = r.importLetterhead()
+	if r.Bookmarks {
+		r.doc.outline = newOutline()
+	}
+	r.pageCount = pageCount
+	r.targets = targets
+	return nil
+}
+
+// RenderPage draws the next page of the document begun with Begin. Pages
+// without content are left out.
+func (r *Renderer) RenderPage(page *pagination.Page) error {
+	d := r.doc
+	if err := d.ctx.Err(); err != nil {
+		return err
+	}
+	i := d.index
+	d.index++
+	var bookmarks []bookmark
+	if d.outline != nil {
+		bookmarks = d.outline.bookmarks(page)
+	}
+	anchors := collectAnchors(page, d.anchors)
+
+	// Skip pages with no boxes at all
+	if len(page.Boxes) == 0 {
+		if r.Debug {
+			r.debugf("Skipping empty page %d (no boxes)\n", i)
 		}
+		return nil
+	}
 
-		// Check if page has any meaningful content
-		hasContent := false
-		for _, box := range page.Boxes {
-			if blockBox, ok := box.(*layout.BlockBox); ok {
-				// Consider content if box has children, height, or is a table/structural element
-				if len(blockBox.Children) > 0 || blockBox.Height > 0 ||
-					(blockBox.Node != nil && (blockBox.Node.Data == "table" || blockBox.Node.Data == "div" || blockBox.Node.Data == "section")) {
-					hasContent = true
-					break
-				}
-			} else {
-				// Non-block boxes (like InlineBox) are always considered content
+	// Check if page has any meaningful content
+	hasContent := false
+	for _, box := range page.Boxes {
+		if blockBox, ok := box.(*layout.BlockBox); ok {
+			// Consider content if box has children, height, or is a table/structural element
+			if len(blockBox.Children) > 0 || blockBox.Height > 0 ||
+				(blockBox.Node != nil && (blockBox.Node.Data == "table" || blockBox.Node.Data == "div" || blockBox.Node.Data == "section")) {
 				hasContent = true
 				break
 			}
+		} else {
+			// Non-block boxes (like InlineBox) are always considered content
+			hasContent = true
+			break
 		}
+	}
 
-		if !hasContent {
-			fmt.Printf("Skipping empty page %d (no meaningful content)\n", i)
-			continue
+	if !hasContent {
+		if r.Debug {
+			r.debugf("Skipping empty page %d (no meaningful content)\n", i)
 		}
+		return nil
+	}
+	pdf := d.pdf
+	// Pages are laid out oriented; their size is the page box as is
+	if page.Width > 0 && page.Height > 0 {
+		pdf.AddPageFormat("P", fpdf.SizeType{Wd: page.Width, Ht: page.Height})
+	} else {
 		pdf.AddPage()
+	}
+	r.page = page
+	clear(r.renderedTexts)
+	d.rendered = append(d.rendered, page)
+	if len(d.rendered) == 1 {
+		drawStationery(pdf, d.firstSheet)
+	} else {
+		drawStationery(pdf, d.nextSheet)
+	}
+	r.Loader = d.loader
+	if l := r.PageLoaders[page]; l != nil {
+		r.Loader = l
+	}
+	addBookmarks(pdf, bookmarks)
+	_, pageH := pdf.GetPageSize()
+	for _, a := range anchors {
+		r.destinations = append(r.destinations, destination{name: a.id, page: pdf.PageNo(), left: a.x, top: pageH - a.y})
+	}
+	if r.Watermark != nil && !r.Watermark.Above {
+		r.renderWatermark(pdf, r.Watermark)
+	}
 
-		for _, box := range page.Boxes {
-			// Skip rendering boxes with no content
-			if blockBox, ok := box.(*layout.BlockBox); ok && len(blockBox.Children) == 0 && blockBox.Height < 1 {
-				continue
-			}
-			r.renderBox(pdf, box)
+	for _, box := range page.Boxes {
+		// Skip rendering boxes with no content
+		if blockBox, ok := box.(*layout.BlockBox); ok && len(blockBox.Children) == 0 && blockBox.Height < 1 {
+			continue
+		}
+		r.renderBox(pdf, box)
+	}
+	if r.Watermark != nil && r.Watermark.Above {
+		r.renderWatermark(pdf, r.Watermark)
+	}
+	r.page = nil
+	r.Loader = d.loader
+	if r.OnPage != nil {
+		if err := r.OnPage(i); err != nil {
+			return err
 		}
 	}
+	if r.ReleasePages {
+		page.Boxes = nil
+	}
+	return nil
+}
+
+// End adds the appended pages to the document begun with Begin and writes
+// it to outputPath
+func (r *Renderer) End(outputPath string) error {
+	d := r.doc
+	r.doc = nil
+	pdf := d.pdf
+	if err := r.renderAppendedPages(d.ctx, pdf); err != nil {
+		return err
+	}
+	if !r.SubsetFonts {
+		r.embedWholeFaces(pdf)
+	}
 
 	outputDir := filepath.Dir(outputPath)
 	if _, err := os.Stat(outputDir); os.IsNotExist(err) {
@@ -230,17 +536,92 @@ func (r *Renderer) Render(pages []*pagination.Page, outputPath string, options R
 		}
 	}
 
-	return pdf.OutputFileAndClose(outputPath)
+	var buf bytes.Buffer
+	if err := pdf.Output(&buf); err != nil {
+		return err
+	}
+	if err := r.setVersion(buf.Bytes()); err != nil {
+		return err
+	}
+	patch := newPDFPatch(buf.Bytes())
+	if err := patch.addID(); err != nil {
+		return fmt.Errorf("failed to write document ID: %w", err)
+	}
+	if err := r.patchImportedPages(patch); err != nil {
+		return fmt.Errorf("failed to write imported pages: %w", err)
+	}
+	if err := patch.addToCatalog(pageLabels(d.rendered)); err != nil {
+		return fmt.Errorf("failed to write page labels: %w", err)
+	}
+	if err := r.patchAttachments(patch); err != nil {
+		return fmt.Errorf("failed to write attachments: %w", err)
+	}
+	if err := r.patchFormFields(patch); err != nil {
+		return fmt.Errorf("failed to write form fields: %w", err)
+	}
+	if err := r.patchDestinations(patch); err != nil {
+		return fmt.Errorf("failed to write named destinations: %w", err)
+	}
+	if err := r.patchOutputIntent(patch); err != nil {
+		return fmt.Errorf("failed to write output intent: %w", err)
+	}
+	data, err := patch.bytes()
+	if err != nil {
+		return err
+	}
+	if r.CompressionLevel > zlib.BestSpeed {
+		if data, err = recompressStreams(data, r.CompressionLevel); err != nil {
+			return fmt.Errorf("failed to compress streams: %w", err)
+		}
+	}
+	return os.WriteFile(outputPath, data, 0644)
+}
+
+// document is the state of a document being rendered page by page
+type document struct {
+	pdf *fpdf.Fpdf
+	ctx context.Context
+	// loader is the renderer's loader, which the loaders of pages stand in
+	// for while they are drawn
+	loader                *res.Loader
+	firstSheet, nextSheet *importedPage
+	// index is the index of the next page and rendered the pages drawn
+	index    int
+	rendered []*pagination.Page
+	// outline collects the bookmarks of the pages, nil without them, and
+	// anchors holds the ids of the elements with a destination so far
+	outline *outline
+	anchors map[string]bool
+}
+
+// pageCounter returns the value of a page counter of generated content on
+// the page being rendered
+func (r *Renderer) pageCounter(name, listStyle string) string {
+	return pagination.PageCounter(r.page, r.pageCount, r.targets, name, listStyle)
 }
 
-// registerFonts registers fonts with the PDF document
+// registerFonts loads the fonts of the font directories into the registry.
+// Faces are embedded into the PDF document as text first uses them.
 func (r *Renderer) registerFonts(pdf *fpdf.Fpdf) {
+	if r.Fonts == nil {
+		r.Fonts = fonts.NewRegistry()
+	}
+	for _, dir := range r.FontDirs {
+		if err := r.Fonts.AddDirectory(dir); err != nil {
+			r.warn(logging.WarningFont, nil, "Failed to load fonts from %s: %v\n", dir, err)
+		}
+	}
 	pdf.SetFont("Helvetica", "", 12)
-
 }
 
 // renderBox renders a box to the PDF
 func (r *Renderer) renderBox(pdf *fpdf.Fpdf, box layout.Box) {
+	// Boxes inside an element that clips its overflow are cut to its
+	// padding box
+	if clip := boxClip(box); clip != nil {
+		pdf.ClipRect(box.GetX()+clip.X, box.GetY()+clip.Y, clip.Width, clip.Height, false)
+		defer pdf.ClipEnd()
+	}
 	switch b := box.(type) {
 	case *layout.BlockBox:
 		r.renderBlockBox(pdf, b)
@@ -250,20 +631,44 @@ func (r *Renderer) renderBox(pdf *fpdf.Fpdf, box layout.Box) {
 		r.renderImageBox(pdf, b)
 	default:
 		if r.Debug {
-			fmt.Printf("Unknown box type: %T\n", box)
+			r.debugf("Unknown box type: %T\n", box)
 		}
 	}
 }
 
+// boxClip returns the clip of a box, or nil when it is not clipped
+func boxClip(box layout.Box) *layout.Clip {
+	switch b := box.(type) {
+	case *layout.BlockBox:
+		return b.Clip
+	case *layout.InlineBox:
+		return b.Clip
+	case *layout.ImageBox:
+		return b.Clip
+	}
+	return nil
+}
+
 // renderBlockBox renders a block box to the PDF
 func (r *Renderer) renderBlockBox(pdf *fpdf.Fpdf, box *layout.BlockBox) {
-	r.renderBackground(pdf, box)
+	// Form controls draw their own background
+	if box.Widget == nil {
+		r.renderBackground(pdf, box)
+	}
 
-	// Special handling for table elements
-	if box != nil && box.Node != nil {
+	// Special handling for form controls and table elements
+	fillable := false
+	if box.Widget != nil {
+		fillable = r.addFormField(pdf, box)
+		r.renderFormWidget(pdf, box, fillable)
+	} else if box.Node != nil {
 		tag := strings.ToLower(box.Node.Data)
 		if tag == "table" || tag == "td" || tag == "th" {
 			r.renderTableElement(pdf, box, tag)
+		} else if tag == "hr" {
+			// A rule is content drawn by its borders, whether or not
+			// borders are rendered
+			r.drawBorders(pdf, box.Style, box.X, box.Y, box.Width, box.Height)
 		} else {
 			// Standard border rendering for non-table elements
 			r.renderBorders(pdf, box)
@@ -272,44 +677,14 @@ func (r *Renderer) renderBlockBox(pdf *fpdf.Fpdf, box *layout.BlockBox) {
 		r.renderBorders(pdf, box)
 	}
 
-	enteringList := false
-	if box != nil && box.Node != nil {
-		tag := strings.ToLower(box.Node.Data)
-		if tag == "ul" || tag == "ol" {
-			enteringList = true
-			lc := listContext{kind: tag}
-			if prop, ok := box.Style["list-style-type"]; ok && prop.Value != "" {
-				lc.style = strings.ToLower(strings.TrimSpace(prop.Value))
-			}
-			if lc.style == "" {
-				if tag == "ul" {
-					lc.style = "disc"
-				} else {
-					lc.style = "decimal"
-				}
-			}
-			r.listStack = append(r.listStack, lc)
-		}
+	if box.Marker != nil {
+		r.renderListMarker(pdf, box)
 	}
-
-	for _, child := range box.Children {
-		if len(r.listStack) > 0 {
-			if cb, ok := child.(*layout.BlockBox); ok && cb.Node != nil {
-				ctag := strings.ToLower(cb.Node.Data)
-				if ctag == "li" {
-					top := &r.listStack[len(r.listStack)-1]
-					if top.kind == "ol" {
-						top.counter++
-					}
-					r.renderListMarker(pdf, cb, *top)
-				}
-			}
+	// The viewer draws the value of a fillable field
+	if !fillable {
+		for _, child := range box.Children {
+			r.renderBox(pdf, child)
 		}
-		r.renderBox(pdf, child)
-	}
-
-	if enteringList && len(r.listStack) > 0 {
-		r.listStack = r.listStack[:len(r.listStack)-1]
 	}
 
 	if r.DebugDrawBoxes {
@@ -359,23 +734,27 @@ func (r *Renderer) renderBackground(pdf *fpdf.Fpdf, box layout.Box) {
 
 	switch b := box.(type) {
 	case *layout.BlockBox:
-		if bgColor, exists := b.Style["background-color"]; exists && bgColor.Value != "" {
-			color := parseColor(bgColor.Value)
+		if bgColor, ok := b.Style.BackgroundColor(); ok {
+			color := style.ParseColor(bgColor)
 			pdf.SetFillColor(color[0], color[1], color[2])
 			pdf.Rect(box.GetX(), box.GetY(), box.GetWidth(), box.GetHeight(), "F")
 			hasCustomBg = true
 			if r.Debug {
-				fmt.Printf("Applied background color %v to block box\n", color)
+				r.debugf("Applied background color %v to block box\n", color)
 			}
 		}
+		if cssURL(b.Style["background-image"].Value) != "" {
+			r.renderBackgroundImage(pdf, b)
+			hasCustomBg = true
+		}
 	case *layout.InlineBox:
-		if bgColor, exists := b.Style["background-color"]; exists && bgColor.Value != "" {
-			color := parseColor(bgColor.Value)
+		if bgColor, ok := b.Style.BackgroundColor(); ok {
+			color := style.ParseColor(bgColor)
 			pdf.SetFillColor(color[0], color[1], color[2])
 			pdf.Rect(box.GetX(), box.GetY(), box.GetWidth(), box.GetHeight(), "F")
 			hasCustomBg = true
 			if r.Debug {
-				fmt.Printf("Applied background color %v to inline box\n", color)
+				r.debugf("Applied background color %v to inline box\n", color)
 			}
 		}
 	}
@@ -399,41 +778,17 @@ func (r *Renderer) renderBorders(pdf *fpdf.Fpdf, box layout.Box) {
 
 	switch b := box.(type) {
 	case *layout.BlockBox:
-		if borderColor, exists := b.Style["border-color"]; exists && borderColor.Value != "" {
-			color := parseColor(borderColor.Value)
-			pdf.SetDrawColor(color[0], color[1], color[2])
-
-			width := 1.0
-			if borderWidth, exists := b.Style["border-width"]; exists {
-				width = parseFloat(borderWidth.Value, 1.0)
-			}
-			pdf.SetLineWidth(width)
-
-			pdf.Rect(box.GetX(), box.GetY(), box.GetWidth(), box.GetHeight(), "D")
-			hasCustomBorder = true
-
-			if r.Debug {
-				fmt.Printf("Applied border color %v with width %.1f to block box\n", color, width)
-			}
-		}
+		hasCustomBorder = r.drawBorders(pdf, b.Style, b.X, b.Y, b.Width, b.Height)
 	case *layout.InlineBox:
-		if borderColor, exists := b.Style["border-color"]; exists && borderColor.Value != "" {
-			color := parseColor(borderColor.Value)
-			pdf.SetDrawColor(color[0], color[1], color[2])
-			width := 1.0
-			if borderWidth, exists := b.Style["border-width"]; exists {
-				width = parseFloat(borderWidth.Value, 1.0)
-			}
-			pdf.SetLineWidth(width)
-
-			pdf.Rect(box.GetX(), box.GetY(), box.GetWidth(), box.GetHeight(), "D")
-			hasCustomBorder = true
-
-			if r.Debug {
-				fmt.Printf("Applied border color %v with width %.1f to inline box\n", color, width)
-			}
+		// Text boxes carry the style of their element, borders included;
+		// only element boxes paint borders
+		if b.Node != nil && b.Node.Type == xhtml.ElementNode {
+			hasCustomBorder = r.drawBorders(pdf, b.Style, b.X, b.Y, b.Width, b.Height)
 		}
 	}
+	if hasCustomBorder && r.Debug {
+		r.debugf("Applied borders to %T at (%.2f, %.2f)\n", box, box.GetX(), box.GetY())
+	}
 
 	if r.DebugDrawBoxes && !hasCustomBorder {
 		pdf.SetDrawColor(200, 200, 200)
@@ -446,7 +801,7 @@ func (r *Renderer) renderBorders(pdf *fpdf.Fpdf, box layout.Box) {
 func (r *Renderer) renderText(pdf *fpdf.Fpdf, box *layout.InlineBox) {
 	if box.Text == "" {
 		if r.Debug {
-			fmt.Printf("Skipping empty text box\n")
+			r.debugf("Skipping empty text box\n")
 		}
 		return
 	}
@@ -460,7 +815,7 @@ func (r *Renderer) renderText(pdf *fpdf.Fpdf, box *layout.InlineBox) {
 	if r.renderedTexts[textID] {
 		// Skip if already rendered
 		if r.Debug {
-			fmt.Printf("Skipping duplicate text: '%s' at (%.2f, %.2f)\n", box.Text, box.X, box.Y)
+			r.debugf("Skipping duplicate text: '%s' at (%.2f, %.2f)\n", box.Text, box.X, box.Y)
 		}
 		return
 	}
@@ -473,60 +828,36 @@ func (r *Renderer) renderText(pdf *fpdf.Fpdf, box *layout.InlineBox) {
 		// Accept CSS values like "16px" or raw numbers
 		fontSize = parseCSSFloat(fontSizeProp.Value, 12)
 		if r.Debug {
-			fmt.Printf("Using font size: %.1f\n", fontSize)
-		}
-	}
-
-	fontFamily := "Helvetica"
-	if fontFamilyProp, exists := box.Style["font-family"]; exists {
-		fontFamilies := strings.Split(fontFamilyProp.Value, ",")
-		if len(fontFamilies) > 0 {
-			firstFont := strings.TrimSpace(fontFamilies[0])
-			firstFont = strings.Trim(firstFont, "'\"")
-
-			switch strings.ToLower(firstFont) {
-			case "arial", "helvetica", "sans-serif":
-				fontFamily = "Helvetica"
-			case "times", "times new roman", "serif":
-				fontFamily = "Times"
-			case "courier", "courier new", "monospace":
-				fontFamily = "Courier"
-			default:
-				// Keep default Helvetica
-			}
-		}
-		if r.Debug {
-			fmt.Printf("Using font family: %s\n", fontFamily)
+			r.debugf("Using font size: %.1f\n", fontSize)
 		}
 	}
 
-	fontStyle := ""
-	if fontWeightProp, exists := box.Style["font-weight"]; exists {
-		if fontWeightProp.Value == "bold" || fontWeightProp.Value == "700" || fontWeightProp.Value == "800" || fontWeightProp.Value == "900" {
-			fontStyle += "B"
-			if r.Debug {
-				fmt.Printf("Using bold font\n")
-			}
-		}
-	}
-	if fontStyleProp, exists := box.Style["font-style"]; exists {
-		if fontStyleProp.Value == "italic" {
-			fontStyle += "I"
-			if r.Debug {
-				fmt.Printf("Using italic font\n")
-			}
-		}
+	sel := r.Fonts.Match(box.Style["font-family"].Value, box.Style["font-weight"].Value, box.Style["font-style"].Value)
+	fontFamily, fontStyle := sel.Family, sel.Style
+	if r.Debug {
+		r.debugf("Using font family: %s, style: %q\n", fontFamily, fontStyle)
 	}
 
 	textColor := [3]int{0, 0, 0}
 	if colorProp, exists := box.Style["color"]; exists {
-		textColor = parseColor(colorProp.Value)
+		textColor = style.ParseColor(colorProp.Value)
 	}
 	pdf.SetTextColor(textColor[0], textColor[1], textColor[2])
 
-	pdf.SetFont(fontFamily, fontStyle, fontSize)
-
 	text := box.Text
+	if style.HasPageCounters(text) {
+		text = style.ResolvePageCounters(text, r.pageCounter)
+	}
+
+	if box.Vertical {
+		r.renderVerticalText(pdf, box, text, sel, fontSize, textColor)
+		return
+	}
+
+	// Split the text into runs so characters missing from the primary font are
+	// drawn with a fallback font
+	letterSpacing, wordSpacing := box.Style.LetterSpacing(), box.Style.WordSpacing()
+	runs, textWidth := r.textRuns(pdf, text, fontFamily, fontStyle, fontSize, box.RTL, letterSpacing, wordSpacing)
 
 	align := "left"
 	if alignProp, exists := box.Style["text-align"]; exists && alignProp.Value != "" {
@@ -540,7 +871,6 @@ func (r *Renderer) renderText(pdf *fpdf.Fpdf, box *layout.InlineBox) {
 		align = "right"
 	}
 
-	textWidth := pdf.GetStringWidth(text)
 	var startX float64
 	switch align {
 	case "center":
@@ -593,11 +923,23 @@ func (r *Renderer) renderText(pdf *fpdf.Fpdf, box *layout.InlineBox) {
     }
 
 	if r.Debug {
-		fmt.Printf("Rendering text: '%s' at (%.2f, %.2f) with font %s %.0fpt, color: %v\n",
+		r.debugf("Rendering text: '%s' at (%.2f, %.2f) with font %s %.0fpt, color: %v\n",
 			text, startX, baselineY, fontFamily, fontSize, textColor)
 	}
 
-	pdf.Text(startX, baselineY, text)
+	// letter-spacing maps to the PDF character spacing. Word spacing (Tw)
+	// only applies to single byte spaces, so with word-spacing the words are
+	// drawn one by one instead.
+	if letterSpacing != 0 {
+		pdf.RawWriteStr(fmt.Sprintf("%.3f Tc", letterSpacing))
+	}
+	bold, oblique := fontSynthesis(box.Style, sel)
+	synthesize(pdf, bold, oblique, startX, baselineY, fontSize, textColor, func() {
+		r.drawTextRuns(pdf, runs, startX, baselineY, fontSize, letterSpacing, wordSpacing)
+	})
+	if letterSpacing != 0 {
+		pdf.RawWriteStr("0 Tc")
+	}
 
 	if r.DebugDrawBoxes {
 		pdf.SetDrawColor(255, 0, 0)
@@ -628,189 +970,50 @@ func (r *Renderer) renderText(pdf *fpdf.Fpdf, box *layout.InlineBox) {
 	}
 }
 
-// parseFloat parses a float value with a default
-func parseFloat(value string, defaultValue float64) float64 {
-    var result float64
-    _, err := fmt.Sscanf(value, "%f", &result)
-    if err != nil {
-        return defaultValue
-    }
-    return result
-}
-
-// parseCSSFloat parses a numeric CSS value like "16px" or "12" into a float.
-// Only simple units are supported here (px, pt); defaults if parsing fails.
+// parseCSSFloat parses a numeric CSS value like "16px", "12pt" or "12" into a
+// float. Absolute units are converted to points; defaults if parsing fails.
 func parseCSSFloat(value string, defaultValue float64) float64 {
-    v := strings.TrimSpace(value)
-    if strings.HasSuffix(v, "px") {
-        v = strings.TrimSuffix(v, "px")
-    } else if strings.HasSuffix(v, "pt") {
-        v = strings.TrimSuffix(v, "pt")
-    }
-    if f, err := strconv.ParseFloat(v, 64); err == nil {
+    if f, ok := style.AbsoluteLength(value); ok {
         return f
     }
     return defaultValue
 }
 
-// parseColor parses a CSS color value
-func parseColor(value string) [3]int {
-	if strings.HasPrefix(value, "#") {
-		if r, g, b, ok := parseHexColor(value); ok {
-			return [3]int{r, g, b}
-		}
-	}
-
-	var r, g, b int
-	if _, err := fmt.Sscanf(value, "rgb(%d,%d,%d)", &r, &g, &b); err == nil {
-		return [3]int{r, g, b}
-	}
-	if _, err := fmt.Sscanf(value, "rgb(%d, %d, %d)", &r, &g, &b); err == nil {
-		return [3]int{r, g, b}
-	}
-
-	return [3]int{0, 0, 0}
-}
-
-// parseHexColor parses #RRGGBB or #RGB into r,g,b
-func parseHexColor(s string) (int, int, int, bool) {
-	s = strings.TrimPrefix(strings.TrimSpace(s), "#")
-	switch len(s) {
-	case 6:
-		if rv, err := strconv.ParseUint(s[0:2], 16, 8); err == nil {
-			if gv, err := strconv.ParseUint(s[2:4], 16, 8); err == nil {
-				if bv, err := strconv.ParseUint(s[4:6], 16, 8); err == nil {
-					return int(rv), int(gv), int(bv), true
-				}
-			}
-		}
-	case 3:
-		r := string([]byte{s[0], s[0]})
-		g := string([]byte{s[1], s[1]})
-		b := string([]byte{s[2], s[2]})
-		if rv, err := strconv.ParseUint(r, 16, 8); err == nil {
-			if gv, err := strconv.ParseUint(g, 16, 8); err == nil {
-				if bv, err := strconv.ParseUint(b, 16, 8); err == nil {
-					return int(rv), int(gv), int(bv), true
-				}
-			}
-		}
-	}
-	return 0, 0, 0, false
-}
-
-// renderListMarker draws the bullet/number for a list item based on current list context
-func (r *Renderer) renderListMarker(pdf *fpdf.Fpdf, li *layout.BlockBox, ctx listContext) {
-	fontSize := 16.0
-	if ib := firstInlineChild(li); ib != nil {
-		if fs, ok := ib.Style["font-size"]; ok && fs.Value != "" {
-			val := strings.TrimSpace(fs.Value)
-			val = strings.TrimSuffix(val, "px")
-			if v := parseFloat(val, fontSize); v > 0 {
-				fontSize = v
-			}
-		}
-	}
+// renderListMarker draws the marker of a list item where layout placed it:
+// bullets as shapes centered on the middle of the x-height, numbers as text
+// in the font of the item
+func (r *Renderer) renderListMarker(pdf *fpdf.Fpdf, li *layout.BlockBox) {
+	m := li.Marker
 	color := [3]int{0, 0, 0}
-	if ib := firstInlineChild(li); ib != nil {
-		if cprop, ok := ib.Style["color"]; ok && strings.TrimSpace(cprop.Value) != "" {
-			color = parseColor(cprop.Value)
-		}
+	if v := strings.TrimSpace(m.Style["color"].Value); v != "" {
+		color = style.ParseColor(v)
 	}
+	x, baseline := li.X+m.X, li.Y+m.Baseline
 
-	cx := li.X - fontSize      // approx 1em to the left
-	cy := li.Y + fontSize*0.75 // closer to visual middle of the text
-
-	if ctx.kind == "ul" {
-		style := ctx.style
-		if style == "" {
-			style = "disc"
-		}
-		// Basic sizing based on font size
-		rbullet := fontSize * 0.18
-		if rbullet < 1.2 {
-			rbullet = 1.2
-		}
+	if m.Bullet != "" {
+		radius := m.BulletRadius()
+		cx, cy := x+radius, baseline-m.FontSize*0.25
 		pdf.SetDrawColor(color[0], color[1], color[2])
 		pdf.SetFillColor(color[0], color[1], color[2])
-		switch strings.ToLower(style) {
-		case "none":
-			return
+		switch m.Bullet {
 		case "circle":
 			pdf.SetLineWidth(0.8)
-			pdf.Circle(cx, cy, rbullet, "D")
+			pdf.Circle(cx, cy, radius, "D")
 		case "square":
-			side := rbullet * 2
-			pdf.Rect(cx-rbullet, cy-rbullet, side, side, "F")
+			pdf.Rect(cx-radius, cy-radius, 2*radius, 2*radius, "F")
 		default: // disc
-			pdf.Circle(cx, cy, rbullet, "F")
+			pdf.Circle(cx, cy, radius, "F")
 		}
 		return
 	}
 
-	if ctx.kind == "ol" {
-		if ctx.style == "none" {
-			return
-		}
-		marker := ""
-		switch ctx.style {
-		case "decimal", "":
-			marker = fmt.Sprintf("%d.", ctx.counter)
-		case "lower-alpha":
-			marker = fmt.Sprintf("%s.", toAlpha(ctx.counter, false))
-		case "upper-alpha":
-			marker = fmt.Sprintf("%s.", toAlpha(ctx.counter, true))
-		default:
-			marker = fmt.Sprintf("%d.", ctx.counter)
-		}
-
-		pdf.SetTextColor(color[0], color[1], color[2])
-		pdf.SetFont("Helvetica", "", fontSize)
-
-		markerWidth := pdf.GetStringWidth(marker)
-		startX := li.X - markerWidth - fontSize*0.2
-		if startX < 0 {
-			startX = 0
-		}
-		pdf.Text(startX, li.Y+fontSize, marker)
-		return
-	}
-}
-
-// firstInlineChild returns the first InlineBox found within the list item
-func firstInlineChild(b *layout.BlockBox) *layout.InlineBox {
-	for _, ch := range b.Children {
-		if ib, ok := ch.(*layout.InlineBox); ok {
-			return ib
-		}
-		if bb, ok := ch.(*layout.BlockBox); ok {
-			for _, gc := range bb.Children {
-				if ib2, ok := gc.(*layout.InlineBox); ok {
-					return ib2
-				}
-			}
-		}
-	}
-	return nil
-}
-
-// toAlpha converts 1-based index to alphabetic sequence (a..z, aa..zz, ...)
-func toAlpha(n int, upper bool) string {
-	if n <= 0 {
-		return ""
-	}
-	letters := []rune{}
-	for n > 0 {
-		n--
-		rem := n % 26
-		ch := rune('a' + rem)
-		if upper {
-			ch = rune('A' + rem)
-		}
-		letters = append([]rune{ch}, letters...)
-		n /= 26
-	}
-	return string(letters)
+	sel := r.Fonts.Match(m.Style["font-family"].Value, m.Style["font-weight"].Value, m.Style["font-style"].Value)
+	runs, _ := r.textRuns(pdf, m.Text, sel.Family, sel.Style, m.FontSize, false, 0, 0)
+	pdf.SetTextColor(color[0], color[1], color[2])
+	bold, oblique := fontSynthesis(m.Style, sel)
+	synthesize(pdf, bold, oblique, x, baseline, m.FontSize, color, func() {
+		r.drawTextRuns(pdf, runs, x, baseline, m.FontSize, 0, 0)
+	})
 }
 
 // renderTableElement handles special rendering for table elements
@@ -819,33 +1022,14 @@ func (r *Renderer) renderTableElement(pdf *fpdf.Fpdf, box *layout.BlockBox, tag
 		return
 	}
 
-	hasBorder := false
-	borderWidth := 0.0
-	borderColor := [3]int{0, 0, 0}
-
-	if width, exists := box.Style["border-width"]; exists && width.Value != "" && width.Value != "0" {
-		borderWidth = parseFloat(width.Value, 0.0)
-		hasBorder = borderWidth > 0
-	}
-
-	if color, exists := box.Style["border-color"]; exists && color.Value != "" {
-		borderColor = parseColor(color.Value)
-	}
-
-	if hasBorder {
-		pdf.SetDrawColor(borderColor[0], borderColor[1], borderColor[2])
-		pdf.SetLineWidth(borderWidth)
-		pdf.Rect(box.X, box.Y, box.Width, box.Height, "D")
-
-		if r.Debug {
-			fmt.Printf("Rendered border for %s: x=%.2f, y=%.2f, w=%.2f, h=%.2f\n",
-				tag, box.X, box.Y, box.Width, box.Height)
-		}
+	if r.drawBorders(pdf, box.Style, box.X, box.Y, box.Width, box.Height) && r.Debug {
+		r.debugf("Rendered border for %s: x=%.2f, y=%.2f, w=%.2f, h=%.2f\n",
+			tag, box.X, box.Y, box.Width, box.Height)
 	}
 
 	if tag == "th" {
 		hasCustomBg := false
-		if bgColor, exists := box.Style["background-color"]; exists && bgColor.Value != "" {
+		if _, ok := box.Style.BackgroundColor(); ok {
 			hasCustomBg = true
 		}
 
@@ -0,0 +1,200 @@
+package pdf
+
+import (
+	"math"
+	"strings"
+	"unicode/utf8"
+
+	"codeberg.org/go-pdf/fpdf"
+	"github.com/gompdf/gompdf/internal/fonts"
+	"github.com/gompdf/gompdf/internal/style"
+	"github.com/gompdf/gompdf/internal/text"
+)
+
+// obliqueAngle is the slant in degrees of synthesized oblique text
+const obliqueAngle = 12
+
+// textRun is a piece of a text box drawn with one font. Runs set in
+// registered faces are shaped; core font runs are drawn as plain strings.
+type textRun struct {
+	fonts.Run
+	shaped *text.ShapedText
+	width  float64
+}
+
+// shaper returns the renderer's text shaper, creating it on first use
+func (r *Renderer) shaper() *text.TextShaper {
+	if r.textShaper == nil {
+		r.textShaper = text.NewTextShaper()
+	}
+	return r.textShaper
+}
+
+// textRuns splits the text of a box into font runs in visual order and
+// measures them. Right-to-left text is shaped in its direction, or reversed
+// when its font cannot be shaped.
+func (r *Renderer) textRuns(pdf *fpdf.Fpdf, s, family, style string, fontSize float64, rtl bool, letterSpacing, wordSpacing float64) ([]textRun, float64) {
+	dir := text.LeftToRight
+	if rtl {
+		dir = text.RightToLeft
+	}
+	var runs []textRun
+	total := 0.0
+	for _, run := range r.Fonts.Runs(s, family, style) {
+		tr := textRun{Run: run}
+		spacing := letterSpacing*float64(utf8.RuneCountInString(run.Text)) + wordSpacing*float64(strings.Count(run.Text, " "))
+		if data := r.Fonts.FaceData(run.Family, run.Style); data != nil {
+			tr.shaped = r.shaper().Shape(run.Text, &text.Font{Size: fontSize, Data: data}, dir)
+			tr.width = tr.shaped.Width + spacing
+		} else {
+			if rtl {
+				tr.Text = text.ReverseText(tr.Text)
+			}
+			pdf.SetFont(run.Family, run.Style, fontSize)
+			tr.width = pdf.GetStringWidth(r.Fonts.Encode(run.Family, tr.Text)) + spacing
+		}
+		runs = append(runs, tr)
+		total += tr.width
+	}
+	if rtl {
+		for i, j := 0, len(runs)-1; i < j; i, j = i+1, j-1 {
+			runs[i], runs[j] = runs[j], runs[i]
+		}
+	}
+	return runs, total
+}
+
+// drawTextRuns draws runs from x along the baseline. letter-spacing is
+// expected to be set as the PDF character spacing already. Word spacing (Tw)
+// only applies to single byte spaces, so with word-spacing the words are
+// drawn one by one instead.
+func (r *Renderer) drawTextRuns(pdf *fpdf.Fpdf, runs []textRun, x, baseline, fontSize, letterSpacing, wordSpacing float64) {
+	for _, run := range runs {
+		r.Fonts.Embed(pdf, run.Family, run.Style)
+		if face := r.Fonts.Face(run.Family, run.Style); face != nil {
+			r.faces[face] = true
+		}
+		pdf.SetFont(run.Family, run.Style, fontSize)
+		switch {
+		case run.shaped != nil:
+			r.drawShaped(pdf, run, x, baseline, letterSpacing, wordSpacing)
+		case wordSpacing == 0:
+			pdf.Text(x, baseline, r.Fonts.Encode(run.Family, run.Text))
+		default:
+			wx := x
+			for j, word := range strings.Split(run.Text, " ") {
+				if j > 0 {
+					wx += pdf.GetStringWidth(r.Fonts.Encode(run.Family, " ")) + letterSpacing + wordSpacing
+				}
+				if word == "" {
+					continue
+				}
+				enc := r.Fonts.Encode(run.Family, word)
+				pdf.Text(wx, baseline, enc)
+				wx += pdf.GetStringWidth(enc) + letterSpacing*float64(utf8.RuneCountInString(word))
+			}
+		}
+		x += run.width
+	}
+}
+
+// embedWholeFaces makes fpdf embed every glyph of the faces drawn with, not
+// only those of the characters drawn. fpdf subsets a face to the characters
+// drawn with it, which it records in the face it shares with templates, so
+// all the characters of the faces are drawn in a template that is never
+// placed.
+func (r *Renderer) embedWholeFaces(pdf *fpdf.Fpdf) {
+	if len(r.faces) == 0 {
+		return
+	}
+	pdf.CreateTemplate(func(tpl *fpdf.Tpl) {
+		for face := range r.faces {
+			tpl.SetFont(face.Family, face.Style, 12)
+			tpl.Text(0, 0, string(face.Characters()))
+		}
+	})
+}
+
+// drawShaped draws shaped glyphs at their shaped positions. The PDF font maps
+// characters to glyphs, so every glyph is drawn by the character its font
+// maps to it. Glyphs no character maps to, such as Indic conjuncts, are
+// drawn as the characters of their cluster and so come out unshaped.
+// Consecutive glyphs are drawn as one string for as long as the advances of
+// the font agree with the shaped positions; kerning, marks and other
+// adjustments start a new string.
+func (r *Renderer) drawShaped(pdf *fpdf.Fpdf, run textRun, x, baseline, letterSpacing, wordSpacing float64) {
+	var seg strings.Builder
+	segX, segY := x, baseline
+	flush := func() {
+		if seg.Len() > 0 {
+			pdf.Text(segX, segY, seg.String())
+			seg.Reset()
+		}
+	}
+	// drawn is where the font would place the next character of seg
+	drawn := func() float64 {
+		return segX + pdf.GetStringWidth(seg.String()) + letterSpacing*float64(utf8.RuneCountInString(seg.String()))
+	}
+
+	// extra is the letter and word spacing added before the current glyph
+	extra := 0.0
+	for _, g := range run.shaped.Glyphs {
+		gx := x + g.X + extra
+		gy := baseline - (run.shaped.Ascent - g.Y)
+		if g.Text != "" {
+			if seg.Len() == 0 || math.Abs(drawn()-gx) > 0.01 || math.Abs(gy-segY) > 0.01 {
+				flush()
+				segX, segY = gx, gy
+			}
+			seg.WriteString(g.Text)
+		}
+		extra += letterSpacing * float64(utf8.RuneCountInString(g.Text))
+		if g.Text == " " {
+			extra += wordSpacing
+		}
+	}
+	flush()
+}
+
+// fontSynthesis returns whether a font selection is emboldened and slanted,
+// as far as font-synthesis allows it
+func fontSynthesis(st style.ComputedStyle, sel fonts.Selection) (bool, bool) {
+	v := strings.ToLower(strings.TrimSpace(st["font-synthesis"].Value))
+	if v == "" {
+		return sel.SyntheticBold, sel.SyntheticOblique
+	}
+	fields := strings.Fields(v)
+	allows := func(name string) bool {
+		for _, f := range fields {
+			if f == name {
+				return true
+			}
+		}
+		return false
+	}
+	return sel.SyntheticBold && allows("weight"), sel.SyntheticOblique && allows("style")
+}
+
+// synthesize runs draw with a synthetic bold or oblique face: bold strokes
+// the glyph outlines in the text color as well as filling them, oblique
+// slants the text around the start of its baseline
+func synthesize(pdf *fpdf.Fpdf, bold, oblique bool, x, baseline, fontSize float64, color [3]int, draw func()) {
+	if bold {
+		dr, dg, db := pdf.GetDrawColor()
+		lw := pdf.GetLineWidth()
+		pdf.SetDrawColor(color[0], color[1], color[2])
+		pdf.SetLineWidth(fontSize / 30)
+		pdf.SetTextRenderingMode(2)
+		defer func() {
+			pdf.SetTextRenderingMode(0)
+			pdf.SetLineWidth(lw)
+			pdf.SetDrawColor(dr, dg, db)
+		}()
+	}
+	if oblique {
+		pdf.TransformBegin()
+		pdf.TransformSkewX(obliqueAngle, x, baseline)
+		defer pdf.TransformEnd()
+	}
+	draw()
+}
@@ -0,0 +1,125 @@
+package pdf
+
+import (
+	"bytes"
+	"fmt"
+	"image"
+	"image/draw"
+	"image/png"
+	"slices"
+)
+
+// pdfVersions are the versions of PDF a document can be written as
+var pdfVersions = []string{"1.3", "1.4", "1.5", "1.6", "1.7", "2.0"}
+
+// versionAtLeast reports whether the document is written as version v of
+// PDF or a later one. Without a PDFVersion every feature is kept and the
+// header is raised to the version they need; see setVersion.
+func (r *Renderer) versionAtLeast(v string) bool {
+	// Versions are compared as strings, all of them of the same length
+	return r.PDFVersion == "" || r.PDFVersion >= v
+}
+
+// checkVersion returns an error when the document cannot be written as the
+// PDF version it targets: the version is not one of pdfVersions or the
+// document has features the version lacks, which cannot be left out
+func (r *Renderer) checkVersion() error {
+	v := r.PDFVersion
+	if v == "" {
+		return nil
+	}
+	if !slices.Contains(pdfVersions, v) {
+		return fmt.Errorf("unsupported PDF version %q; supported are 1.3 to 1.7 and 2.0", v)
+	}
+	if r.OutputIntent != nil && !r.versionAtLeast("1.4") {
+		return fmt.Errorf("output intents need PDF 1.4; the target is %s", v)
+	}
+	if wm := r.Watermark; wm != nil && wm.Image != "" && !r.versionAtLeast("1.4") {
+		return fmt.Errorf("watermark images are transparent, which needs PDF 1.4; the target is %s", v)
+	}
+	if len(r.Attachments) > 0 && !r.versionAtLeast("1.4") {
+		return fmt.Errorf("attachments need PDF 1.4; the target is %s", v)
+	}
+	for _, a := range r.Attachments {
+		if a.Relationship != "" && !r.versionAtLeast("1.7") {
+			return fmt.Errorf("the relationship of attachment %q needs PDF 1.7 as PDF/A-3 defines it; the target is %s", a.Name, v)
+		}
+	}
+	return nil
+}
+
+// featureVersion returns the lowest PDF version the features the renderer
+// adds to the output of fpdf need, which fpdf does not know of
+func (r *Renderer) featureVersion() string {
+	v := "1.3"
+	if r.OutputIntent != nil || len(r.Attachments) > 0 || r.Watermark != nil && r.Watermark.Image != "" {
+		v = "1.4"
+	}
+	for _, a := range r.Attachments {
+		if a.Relationship != "" {
+			v = "1.7"
+		}
+	}
+	return v
+}
+
+// setVersion writes the PDF version the document targets in the header fpdf
+// wrote, which has the version of the features fpdf uses. A version above
+// the target is an error: a feature of the document could not be left out.
+// Without a target the header is raised to the version of the features the
+// renderer adds, when fpdf's is lower.
+func (r *Renderer) setVersion(data []byte) error {
+	const header = "%PDF-"
+	if len(data) < len(header)+3 || string(data[:len(header)]) != header {
+		return fmt.Errorf("PDF header not found")
+	}
+	used := string(data[len(header) : len(header)+3])
+	if r.PDFVersion == "" {
+		if v := r.featureVersion(); v > used {
+			copy(data[len(header):], v)
+		}
+		return nil
+	}
+	if used > r.PDFVersion {
+		return fmt.Errorf("the document needs PDF %s; the target is %s", used, r.PDFVersion)
+	}
+	copy(data[len(header):], r.PDFVersion)
+	return nil
+}
+
+// pngForVersion returns PNG data fpdf embeds with the features of the
+// target PDF version: images with 16 bits per sample, which need PDF 1.5,
+// get 8, and images with an alpha channel, a soft mask of PDF 1.4, are
+// flattened onto white. Other data is returned as it is.
+func (r *Renderer) pngForVersion(data []byte) ([]byte, error) {
+	// The IHDR chunk comes first, after the signature: bit depth and color
+	// type follow the width and height
+	if len(data) < 26 || string(data[12:16]) != "IHDR" {
+		return data, nil
+	}
+	depth, colorType := data[24], data[25]
+	deep := depth > 8 && !r.versionAtLeast("1.5")
+	alpha := (colorType == 4 || colorType == 6) && !r.versionAtLeast("1.4")
+	if !deep && !alpha {
+		return data, nil
+	}
+	img, err := png.Decode(bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+	b := img.Bounds()
+	var out draw.Image
+	if alpha {
+		out = image.NewRGBA(b)
+		draw.Draw(out, b, image.White, image.Point{}, draw.Src)
+		draw.Draw(out, b, img, b.Min, draw.Over)
+	} else {
+		out = image.NewNRGBA(b)
+		draw.Draw(out, b, img, b.Min, draw.Src)
+	}
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, out); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
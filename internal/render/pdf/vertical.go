@@ -0,0 +1,42 @@
+package pdf
+
+import (
+	"fmt"
+
+	"codeberg.org/go-pdf/fpdf"
+	"github.com/gompdf/gompdf/internal/fonts"
+	"github.com/gompdf/gompdf/internal/layout"
+)
+
+// renderVerticalText draws the text of a box set in a vertical writing mode
+// down the box. Sideways runs are turned a quarter turn clockwise on the
+// baseline of the box. Upright characters stand one em apart, centered on
+// the middle of the glyphs of the line, 0.3em right of the baseline, with
+// their own baseline 0.88em below the top of their em.
+func (r *Renderer) renderVerticalText(pdf *fpdf.Fpdf, box *layout.InlineBox, text string, sel fonts.Selection, fontSize float64, color [3]int) {
+	letterSpacing, wordSpacing := box.Style.LetterSpacing(), box.Style.WordSpacing()
+	if letterSpacing != 0 {
+		pdf.RawWriteStr(fmt.Sprintf("%.3f Tc", letterSpacing))
+		defer pdf.RawWriteStr("0 Tc")
+	}
+	bold, oblique := fontSynthesis(box.Style, sel)
+	baseline, y := box.VerticalBaseline(), box.Y
+	for _, seg := range box.Style.VerticalSegments(text) {
+		runs, width := r.textRuns(pdf, seg.Text, sel.Family, sel.Style, fontSize, false, letterSpacing, wordSpacing)
+		if seg.Upright {
+			x, by := baseline+0.3*fontSize-(width-letterSpacing)/2, y+0.88*fontSize
+			synthesize(pdf, bold, oblique, x, by, fontSize, color, func() {
+				r.drawTextRuns(pdf, runs, x, by, fontSize, letterSpacing, wordSpacing)
+			})
+			y += fontSize + letterSpacing
+			continue
+		}
+		pdf.TransformBegin()
+		pdf.TransformRotate(-90, baseline, y)
+		synthesize(pdf, bold, oblique, baseline, y, fontSize, color, func() {
+			r.drawTextRuns(pdf, runs, baseline, y, fontSize, letterSpacing, wordSpacing)
+		})
+		pdf.TransformEnd()
+		y += width
+	}
+}
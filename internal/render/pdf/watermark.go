@@ -0,0 +1,176 @@
+package pdf
+
+import (
+	"math"
+	"strings"
+
+	"codeberg.org/go-pdf/fpdf"
+	"github.com/gompdf/gompdf/internal/logging"
+	"github.com/gompdf/gompdf/internal/style"
+)
+
+// watermarkInset is the distance in points between a watermark placed
+// against a page edge and that edge
+const watermarkInset = 36
+
+// Watermark is text and/or an image stamped on every page, such as a DRAFT
+// or CONFIDENTIAL mark or a company seal
+type Watermark struct {
+	// Text is drawn in FontFamily (a CSS font-family list, Helvetica by
+	// default) at FontSize points (72 by default) in Color (a CSS color,
+	// gray by default)
+	Text       string
+	FontFamily string
+	FontSize   float64
+	Bold       bool
+	Color      string
+	// Image is the source of an image, loaded like <img src>. It is drawn
+	// Width by Height points; with one of them set the other keeps the
+	// aspect ratio, with neither the image has its natural size.
+	Image  string
+	Width  float64
+	Height float64
+	// Opacity ranges from 0 to 1; 0 means the default of 0.25
+	Opacity float64
+	// Rotation turns the watermark counterclockwise by degrees around its
+	// center
+	Rotation float64
+	// Position places the watermark on the page: center (the default),
+	// top, bottom, left, right, top-left, top-right, bottom-left or
+	// bottom-right
+	Position string
+	// Above draws the watermark over the page content instead of beneath it
+	Above bool
+}
+
+// renderWatermark stamps the watermark on the current page. The image comes
+// first with the text centered over it.
+func (r *Renderer) renderWatermark(pdf *fpdf.Fpdf, wm *Watermark) {
+	if wm == nil || (wm.Text == "" && wm.Image == "") {
+		return
+	}
+
+	var imgName string
+	var imgW, imgH float64
+	if wm.Image != "" {
+		imgName, imgW, imgH = r.watermarkImage(pdf, wm)
+	}
+
+	fontSize := wm.FontSize
+	if fontSize <= 0 {
+		fontSize = 72
+	}
+	family := wm.FontFamily
+	if family == "" {
+		family = "Helvetica"
+	}
+	weight := "normal"
+	if wm.Bold {
+		weight = "bold"
+	}
+	sel := r.Fonts.Match(family, weight, "normal")
+	var runs []textRun
+	textW := 0.0
+	if wm.Text != "" {
+		runs, textW = r.textRuns(pdf, wm.Text, sel.Family, sel.Style, fontSize, false, 0, 0)
+	}
+	textH := 0.0
+	if len(runs) > 0 {
+		textH = fontSize
+	}
+
+	w, h := max(imgW, textW), max(imgH, textH)
+	x, y := watermarkOrigin(pdf, wm.Position, w, h)
+
+	opacity := wm.Opacity
+	if opacity <= 0 || opacity > 1 {
+		opacity = 0.25
+	}
+	// Transparency needs PDF 1.4; before it text is drawn in its color
+	// mixed with the white of the page instead
+	transparent := r.versionAtLeast("1.4")
+	if transparent {
+		pdf.SetAlpha(opacity, "Normal")
+		defer pdf.SetAlpha(1, "Normal")
+	}
+	if wm.Rotation != 0 {
+		pdf.TransformBegin()
+		pdf.TransformRotate(wm.Rotation, x+w/2, y+h/2)
+		defer pdf.TransformEnd()
+	}
+
+	if imgName != "" {
+		pdf.ImageOptions(imgName, x+(w-imgW)/2, y+(h-imgH)/2, imgW, imgH, false, fpdf.ImageOptions{}, 0, "")
+	}
+	if len(runs) > 0 {
+		color := [3]int{128, 128, 128}
+		if wm.Color != "" {
+			color = style.ParseColor(wm.Color)
+		}
+		if !transparent {
+			for i, c := range color {
+				color[i] = int(math.Round(float64(c)*opacity + 255*(1-opacity)))
+			}
+		}
+		pdf.SetTextColor(color[0], color[1], color[2])
+		// The cap height of most fonts is about 0.7em, which centers
+		// capitals such as DRAFT vertically
+		baseline := y + h/2 + fontSize*0.35
+		bold, _ := fontSynthesis(nil, sel)
+		synthesize(pdf, bold, false, x, baseline, fontSize, color, func() {
+			r.drawTextRuns(pdf, runs, x+(w-textW)/2, baseline, fontSize, 0, 0)
+		})
+	}
+}
+
+// watermarkImage embeds the watermark image and returns its name and size,
+// or an empty name when it cannot be loaded
+func (r *Renderer) watermarkImage(pdf *fpdf.Fpdf, wm *Watermark) (string, float64, float64) {
+	if r.Loader == nil {
+		r.warn(logging.WarningResource, nil, "No loader set; cannot render watermark image %q\n", wm.Image)
+		return "", 0, 0
+	}
+	resrc, err := r.Loader.LoadImage(wm.Image)
+	if err != nil {
+		r.warn(logging.WarningResource, nil, "Failed to load watermark image %q: %v\n", wm.Image, err)
+		return "", 0, 0
+	}
+	w, h := wm.Width, wm.Height
+	name, ok := r.registerImage(pdf, nil, wm.Image, resrc, w, h, 1)
+	if !ok {
+		return "", 0, 0
+	}
+	info := pdf.GetImageInfo(name)
+	iw, ih := info.Width(), info.Height()
+	switch {
+	case w > 0 && h > 0:
+	case w > 0 && iw > 0:
+		h = w * ih / iw
+	case h > 0 && ih > 0:
+		w = h * iw / ih
+	default:
+		w, h = iw, ih
+	}
+	return name, w, h
+}
+
+// watermarkOrigin returns the top left corner of a watermark of size w by h
+// at a position on the current page
+func watermarkOrigin(pdf *fpdf.Fpdf, position string, w, h float64) (float64, float64) {
+	pageW, pageH := pdf.GetPageSize()
+	x, y := (pageW-w)/2, (pageH-h)/2
+	position = strings.ToLower(strings.TrimSpace(position))
+	if strings.Contains(position, "top") {
+		y = watermarkInset
+	}
+	if strings.Contains(position, "bottom") {
+		y = pageH - watermarkInset - h
+	}
+	if strings.Contains(position, "left") {
+		x = watermarkInset
+	}
+	if strings.Contains(position, "right") {
+		x = pageW - watermarkInset - w
+	}
+	return x, y
+}
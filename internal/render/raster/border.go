@@ -0,0 +1,80 @@
+package raster
+
+import (
+	"image/color"
+	"math"
+
+	"github.com/gompdf/gompdf/internal/style"
+)
+
+// drawBorders paints the four borders of the rectangle x, y, w, h as the
+// PDF renderer strokes them, centered on the edges
+func (r *Renderer) drawBorders(st style.ComputedStyle, x, y, w, h float64) {
+	top, right, bottom, left := st.Border("top"), st.Border("right"), st.Border("bottom"), st.Border("left")
+	// Lines extend by half the adjacent border widths to fill the corners
+	r.drawBorderLine(st, top, x-left.Width/2, y, x+w+right.Width/2, y, 0, 1)
+	r.drawBorderLine(st, right, x+w, y-top.Width/2, x+w, y+h+bottom.Width/2, -1, 0)
+	r.drawBorderLine(st, bottom, x-left.Width/2, y+h, x+w+right.Width/2, y+h, 0, -1)
+	r.drawBorderLine(st, left, x, y-top.Width/2, x, y+h+bottom.Width/2, 1, 0)
+}
+
+// drawBorderLine paints one side from (x1, y1) to (x2, y2), a horizontal or
+// vertical line. nx and ny point towards the inside of the box and are used
+// to offset the lines of a double border.
+func (r *Renderer) drawBorderLine(st style.ComputedStyle, b style.Border, x1, y1, x2, y2, nx, ny float64) {
+	if !b.Visible() {
+		return
+	}
+	value := b.Color
+	if value == "" || value == "currentColor" || value == "currentcolor" {
+		value = st["color"].Value
+	}
+	c := rgba(style.ParseColor(value))
+
+	switch b.Style {
+	case "dashed":
+		dash := math.Max(3*b.Width, 3)
+		r.dashLine(x1, y1, x2, y2, b.Width, dash, dash, c)
+	case "dotted":
+		// Dots one width across, one width apart
+		length := math.Hypot(x2-x1, y2-y1)
+		for t := 0.0; t <= length; t += 2 * b.Width {
+			px, py := x1+(x2-x1)*t/length, y1+(y2-y1)*t/length
+			r.fillCircle(px, py, b.Width/2, 0, c)
+		}
+	case "double":
+		if b.Width < 3 {
+			r.line(x1, y1, x2, y2, b.Width, c)
+			return
+		}
+		// Two lines of a third of the width with a gap of the same size
+		off := b.Width / 3
+		r.line(x1-nx*off, y1-ny*off, x2-nx*off, y2-ny*off, b.Width/3, c)
+		r.line(x1+nx*off, y1+ny*off, x2+nx*off, y2+ny*off, b.Width/3, c)
+	default:
+		r.line(x1, y1, x2, y2, b.Width, c)
+	}
+}
+
+// line fills a horizontal or vertical line of width lw centered on the
+// segment from (x1, y1) to (x2, y2)
+func (r *Renderer) line(x1, y1, x2, y2, lw float64, c color.Color) {
+	x, y := math.Min(x1, x2), math.Min(y1, y2)
+	w, h := math.Abs(x2-x1), math.Abs(y2-y1)
+	if h == 0 {
+		y, h = y-lw/2, lw
+	} else {
+		x, w = x-lw/2, lw
+	}
+	r.fillRect(x, y, w, h, c)
+}
+
+// dashLine draws a horizontal or vertical line as dashes of length dash
+// separated by gap
+func (r *Renderer) dashLine(x1, y1, x2, y2, lw, dash, gap float64, c color.Color) {
+	length := math.Hypot(x2-x1, y2-y1)
+	for t := 0.0; t < length; t += dash + gap {
+		end := math.Min(t+dash, length)
+		r.line(x1+(x2-x1)*t/length, y1+(y2-y1)*t/length, x1+(x2-x1)*end/length, y1+(y2-y1)*end/length, lw, c)
+	}
+}
@@ -0,0 +1,74 @@
+package raster
+
+import (
+	"image/color"
+	"math"
+	"strings"
+
+	"github.com/gompdf/gompdf/internal/layout"
+	"github.com/gompdf/gompdf/internal/style"
+)
+
+// renderFormWidget draws a form control as a static widget, as the PDF
+// renderer draws it
+func (r *Renderer) renderFormWidget(box *layout.BlockBox) {
+	w := box.Widget
+	bg, hasBg := box.Style.BackgroundColor()
+	c := color.RGBA{A: 0xff}
+	if v := strings.TrimSpace(box.Style["color"].Value); v != "" {
+		c = rgba(style.ParseColor(v))
+	}
+
+	if w.Kind == "radio" {
+		cx, cy, radius := w.RadioCircle(box)
+		if hasBg {
+			r.fillCircle(cx, cy, radius, 0, rgba(style.ParseColor(bg)))
+		}
+		if b := box.Style.Border("top"); b.Visible() {
+			bc := c
+			if b.Color != "" {
+				bc = rgba(style.ParseColor(b.Color))
+			}
+			r.fillCircle(cx, cy, radius, radius-b.Width, bc)
+		}
+		if w.Checked {
+			r.fillCircle(cx, cy, radius/2, 0, c)
+		}
+		return
+	}
+
+	if hasBg {
+		r.fillRect(box.X, box.Y, box.Width, box.Height, rgba(style.ParseColor(bg)))
+	}
+	r.drawBorders(box.Style, box.X, box.Y, box.Width, box.Height)
+	switch {
+	case w.Kind == "checkbox" && w.Checked:
+		r.fillPolygon(w.CheckMark(box), c)
+	case w.Kind == "select":
+		r.fillPolygon(w.Arrow(box), c)
+	}
+}
+
+// fillPolygon fills a polygon whose points are given in points
+func (r *Renderer) fillPolygon(points [][2]float64, c color.Color) {
+	if len(points) < 3 {
+		return
+	}
+	minX, minY, maxX, maxY := math.Inf(1), math.Inf(1), math.Inf(-1), math.Inf(-1)
+	for _, p := range points {
+		minX, minY = math.Min(minX, p[0]), math.Min(minY, p[1])
+		maxX, maxY = math.Max(maxX, p[0]), math.Max(maxY, p[1])
+	}
+	rect := r.pixels(minX, minY, maxX-minX, maxY-minY).Inset(-1)
+	r.rasterizer.Reset(rect.Dx(), rect.Dy())
+	for i, p := range points {
+		px, py := float32(p[0]*r.scale-float64(rect.Min.X)), float32(p[1]*r.scale-float64(rect.Min.Y))
+		if i == 0 {
+			r.rasterizer.MoveTo(px, py)
+		} else {
+			r.rasterizer.LineTo(px, py)
+		}
+	}
+	r.rasterizer.ClosePath()
+	r.fillMask(rect, c)
+}
@@ -0,0 +1,439 @@
+// Package raster renders paginated layout boxes to images. It paints what
+// the PDF renderer paints except background images and watermarks, and draws
+// text set in the core PDF fonts, which come without font files, with the Go
+// fonts instead.
+package raster
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"image"
+	"image/color"
+	_ "image/gif"
+	_ "image/jpeg"
+	_ "image/png"
+	"math"
+	"strconv"
+	"strings"
+
+	"codeberg.org/go-pdf/fpdf"
+	"github.com/gompdf/gompdf/internal/fonts"
+	"github.com/gompdf/gompdf/internal/layout"
+	"github.com/gompdf/gompdf/internal/logging"
+	"github.com/gompdf/gompdf/internal/pagination"
+	"github.com/gompdf/gompdf/internal/parser/html"
+	"github.com/gompdf/gompdf/internal/res"
+	"github.com/gompdf/gompdf/internal/style"
+	"github.com/gompdf/gompdf/internal/text"
+	"github.com/srwiley/oksvg"
+	"github.com/srwiley/rasterx"
+	"golang.org/x/image/draw"
+	"golang.org/x/image/font/sfnt"
+	"golang.org/x/image/vector"
+	xhtml "golang.org/x/net/html"
+)
+
+// Size of the pages that have none, A4 in points as fpdf defaults to
+const (
+	defaultPageWidth  = 595.28
+	defaultPageHeight = 841.89
+)
+
+// Renderer handles rendering to images
+type Renderer struct {
+	// DPI is the resolution of the images; at 72 a point is a pixel
+	DPI float64
+	// RenderBackgrounds controls whether box backgrounds are painted
+	RenderBackgrounds bool
+	// RenderBorders controls whether box borders are painted
+	RenderBorders bool
+	// Loader allows resolving images and other resources
+	Loader *res.Loader
+	// PageLoaders resolve the resources of the pages they are given for
+	// instead of Loader, such as pages of documents merged from other
+	// directories
+	PageLoaders map[*pagination.Page]*res.Loader
+	// Fonts holds the font faces available in addition to the core fonts
+	Fonts *fonts.Registry
+	// Logger receives warnings. A nil Logger discards them.
+	Logger logging.Logger
+	// OnPage, when set, is called after the page of the given index is
+	// painted; an error stops rendering with it
+	OnPage func(index int) error
+
+	// scale converts points to pixels
+	scale float64
+	// img is the page being painted and dst the part of it boxes may paint,
+	// narrowed by the clips of the boxes being rendered
+	img, dst *image.RGBA
+	// page is the page being rendered and pageCount the number of pages,
+	// for the page counters of generated content
+	page      *pagination.Page
+	pageCount int
+	// targets maps element ids to the page they start on
+	targets map[string]*pagination.Page
+	// faces caches parsed fonts by family and style
+	faces      map[string]*sfnt.Font
+	buf        sfnt.Buffer
+	rasterizer *vector.Rasterizer
+	// textShaper shapes text set in registered faces; see shaper
+	textShaper *text.TextShaper
+	// measure measures text set in core fonts; see metrics
+	measure *fpdf.Fpdf
+	// images caches decoded images by source
+	images map[string]image.Image
+}
+
+// NewRenderer creates a new image renderer
+func NewRenderer(loader *res.Loader) *Renderer {
+	return &Renderer{
+		DPI:               96,
+		RenderBackgrounds: true,
+		RenderBorders:     true,
+		Loader:            loader,
+	}
+}
+
+// warn reports a recoverable problem about node, which may be nil, to the
+// renderer's logger
+func (r *Renderer) warn(code logging.WarningCode, node *html.Node, format string, args ...any) {
+	var path string
+	if node != nil {
+		path = node.Path()
+	}
+	logging.Warnf(r.Logger, code, path, format, args...)
+}
+
+// Render paints every page with content to an image, stopping with the
+// context's error if it is done before rendering completes. Pages without
+// content are skipped, as in PDF output.
+func (r *Renderer) Render(ctx context.Context, pages []*pagination.Page) ([]*image.RGBA, error) {
+	if r.DPI <= 0 {
+		r.DPI = 96
+	}
+	if r.Fonts == nil {
+		r.Fonts = fonts.NewRegistry()
+	}
+	r.scale = r.DPI / 72
+	r.faces = make(map[string]*sfnt.Font)
+	r.images = make(map[string]image.Image)
+	r.rasterizer = vector.NewRasterizer(0, 0)
+	r.pageCount = len(pages)
+	r.targets = pagination.Targets(pages)
+	loader := r.Loader
+	defer func() { r.Loader = loader }()
+
+	var images []*image.RGBA
+	for i, page := range pages {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		if !hasContent(page) {
+			continue
+		}
+		r.page = page
+		r.Loader = loader
+		if l := r.PageLoaders[page]; l != nil {
+			r.Loader = l
+		}
+		images = append(images, r.renderPage(page))
+		if r.OnPage != nil {
+			if err := r.OnPage(i); err != nil {
+				return nil, err
+			}
+		}
+	}
+	return images, nil
+}
+
+// pageCounter returns the value of a page counter of generated content on
+// the page being rendered
+func (r *Renderer) pageCounter(name, listStyle string) string {
+	return pagination.PageCounter(r.page, r.pageCount, r.targets, name, listStyle)
+}
+
+// hasContent reports whether a page has boxes the PDF renderer would give
+// a page of the document
+func hasContent(page *pagination.Page) bool {
+	for _, box := range page.Boxes {
+		b, ok := box.(*layout.BlockBox)
+		if !ok || len(b.Children) > 0 || b.Height > 0 {
+			return true
+		}
+		if b.Node != nil && (b.Node.Data == "table" || b.Node.Data == "div" || b.Node.Data == "section") {
+			return true
+		}
+	}
+	return false
+}
+
+// renderPage paints the boxes of a page on a white image of its size
+func (r *Renderer) renderPage(page *pagination.Page) *image.RGBA {
+	w, h := page.Width, page.Height
+	if w <= 0 || h <= 0 {
+		w, h = defaultPageWidth, defaultPageHeight
+	}
+	r.img = image.NewRGBA(image.Rect(0, 0, int(math.Ceil(w*r.scale)), int(math.Ceil(h*r.scale))))
+	draw.Draw(r.img, r.img.Bounds(), image.White, image.Point{}, draw.Src)
+	r.dst = r.img
+
+	for _, box := range page.Boxes {
+		// Skip rendering boxes with no content
+		if b, ok := box.(*layout.BlockBox); ok && len(b.Children) == 0 && b.Height < 1 {
+			continue
+		}
+		r.renderBox(box)
+	}
+	return r.img
+}
+
+// renderBox paints a box and its descendants
+func (r *Renderer) renderBox(box layout.Box) {
+	// Boxes inside an element that clips its overflow are cut to its
+	// padding box
+	if clip := boxClip(box); clip != nil {
+		dst := r.dst
+		r.dst = dst.SubImage(r.pixels(box.GetX()+clip.X, box.GetY()+clip.Y, clip.Width, clip.Height)).(*image.RGBA)
+		defer func() { r.dst = dst }()
+	}
+	switch b := box.(type) {
+	case *layout.BlockBox:
+		r.renderBlockBox(b)
+	case *layout.InlineBox:
+		r.renderInlineBox(b)
+	case *layout.ImageBox:
+		r.renderImageBox(b)
+	}
+}
+
+// boxClip returns the clip of a box, or nil when it is not clipped
+func boxClip(box layout.Box) *layout.Clip {
+	switch b := box.(type) {
+	case *layout.BlockBox:
+		return b.Clip
+	case *layout.InlineBox:
+		return b.Clip
+	case *layout.ImageBox:
+		return b.Clip
+	}
+	return nil
+}
+
+// renderBlockBox paints a block box, its list markers and its children
+func (r *Renderer) renderBlockBox(box *layout.BlockBox) {
+	// Form controls draw their own background and borders
+	if box.Widget != nil {
+		r.renderFormWidget(box)
+		for _, child := range box.Children {
+			r.renderBox(child)
+		}
+		return
+	}
+	r.renderBackground(box.Style, box.X, box.Y, box.Width, box.Height)
+	// A rule is content drawn by its borders, whether or not borders are
+	// rendered
+	if r.RenderBorders || box.Node != nil && strings.EqualFold(box.Node.Data, "hr") {
+		r.drawBorders(box.Style, box.X, box.Y, box.Width, box.Height)
+		// Header cells without a background of their own are shaded
+		if box.Node != nil && strings.EqualFold(box.Node.Data, "th") {
+			if _, ok := box.Style.BackgroundColor(); !ok {
+				r.fillRect(box.X, box.Y, box.Width, box.Height, color.RGBA{240, 240, 240, 0xff})
+			}
+		}
+	}
+
+	if box.Marker != nil {
+		r.renderListMarker(box)
+	}
+	for _, child := range box.Children {
+		r.renderBox(child)
+	}
+}
+
+// renderInlineBox paints an inline box, its text and its children
+func (r *Renderer) renderInlineBox(box *layout.InlineBox) {
+	r.renderBackground(box.Style, box.X, box.Y, box.Width, box.Height)
+	// Text boxes carry the style of their element, borders included; only
+	// element boxes paint borders
+	if r.RenderBorders && box.Node != nil && box.Node.Type == xhtml.ElementNode {
+		r.drawBorders(box.Style, box.X, box.Y, box.Width, box.Height)
+	}
+	if box.Text != "" {
+		r.renderText(box)
+	}
+	for _, child := range box.Children {
+		r.renderBox(child)
+	}
+}
+
+// renderBackground fills a box with its background color
+func (r *Renderer) renderBackground(st style.ComputedStyle, x, y, w, h float64) {
+	if !r.RenderBackgrounds {
+		return
+	}
+	if v, ok := st.BackgroundColor(); ok {
+		r.fillRect(x, y, w, h, rgba(style.ParseColor(v)))
+	}
+}
+
+// renderImageBox draws the image of an ImageBox scaled to where object-fit
+// and object-position place it, clipped to the box
+func (r *Renderer) renderImageBox(box *layout.ImageBox) {
+	if r.Loader == nil || strings.TrimSpace(box.Src) == "" {
+		return
+	}
+	resrc, err := r.Loader.LoadImage(box.Src)
+	if err != nil {
+		r.warn(logging.WarningResource, box.Node, "Failed to load image %q: %v\n", box.Src, err)
+		return
+	}
+	// Pages of PDF documents are only drawn into PDF documents
+	if resrc.IsPDF() {
+		return
+	}
+	clip := r.pixels(box.X, box.Y, box.Width, box.Height).Intersect(r.dst.Bounds())
+	rect := r.pixels(box.ObjectRect())
+	if clip.Empty() || rect.Empty() {
+		return
+	}
+	img, err := r.decodeImage(box.Src, resrc, rect.Dx(), rect.Dy(), box.Orientation)
+	if err != nil {
+		r.warn(logging.WarningImage, box.Node, "Failed to decode image %q: %v\n", box.Src, err)
+		return
+	}
+	dst := r.dst.SubImage(clip).(*image.RGBA)
+	draw.ApproxBiLinear.Scale(dst, rect, img, img.Bounds(), draw.Over, nil)
+}
+
+// decodeImage decodes an image resource once per source. SVG images are
+// rasterized for the pixel size they are drawn at, fitted within it; raster
+// images are turned upright for an EXIF orientation other than 1.
+// Sources are told apart by where they were loaded from, as the same
+// relative src of pages of different documents may not be the same image.
+func (r *Renderer) decodeImage(src string, resrc *res.Resource, w, h, orientation int) (image.Image, error) {
+	key := src
+	if resrc.URL != "" {
+		key = resrc.URL
+	}
+	if resrc.IsSVG() {
+		key += "@" + strconv.Itoa(w) + "x" + strconv.Itoa(h)
+	} else if orientation > 1 {
+		key += "#orientation" + strconv.Itoa(orientation)
+	}
+	if img, ok := r.images[key]; ok {
+		return img, nil
+	}
+	var img image.Image
+	if resrc.IsSVG() {
+		icon, err := oksvg.ReadIconStream(bytes.NewReader(resrc.Data))
+		if err != nil {
+			return nil, fmt.Errorf("svg parse: %w", err)
+		}
+		tw, th := float64(w), float64(h)
+		if vb := icon.ViewBox; vb.W > 0 && vb.H > 0 {
+			s := math.Min(tw/vb.W, th/vb.H)
+			tw, th = vb.W*s, vb.H*s
+		}
+		rgba := image.NewRGBA(image.Rect(0, 0, int(math.Ceil(tw)), int(math.Ceil(th))))
+		scanner := rasterx.NewScannerGV(rgba.Bounds().Dx(), rgba.Bounds().Dy(), rgba, rgba.Bounds())
+		icon.SetTarget(0, 0, float64(rgba.Bounds().Dx()), float64(rgba.Bounds().Dy()))
+		icon.Draw(rasterx.NewDasher(rgba.Bounds().Dx(), rgba.Bounds().Dy(), scanner), 1.0)
+		img = rgba
+	} else {
+		decoded, _, err := image.Decode(bytes.NewReader(resrc.Data))
+		if err != nil {
+			return nil, err
+		}
+		img = res.OrientImage(decoded, orientation)
+	}
+	r.images[key] = img
+	return img, nil
+}
+
+// renderListMarker draws the marker of a list item as the PDF renderer
+// draws it
+func (r *Renderer) renderListMarker(li *layout.BlockBox) {
+	m := li.Marker
+	c := color.RGBA{A: 0xff}
+	if v := strings.TrimSpace(m.Style["color"].Value); v != "" {
+		c = rgba(style.ParseColor(v))
+	}
+	x, baseline := li.X+m.X, li.Y+m.Baseline
+
+	if m.Bullet != "" {
+		radius := m.BulletRadius()
+		cx, cy := x+radius, baseline-m.FontSize*0.25
+		switch m.Bullet {
+		case "circle":
+			r.fillCircle(cx, cy, radius+0.4, radius-0.4, c)
+		case "square":
+			r.fillRect(cx-radius, cy-radius, 2*radius, 2*radius, c)
+		default:
+			r.fillCircle(cx, cy, radius, 0, c)
+		}
+		return
+	}
+
+	sel := r.Fonts.Match(m.Style["font-family"].Value, m.Style["font-weight"].Value, m.Style["font-style"].Value)
+	runs, _ := r.textRuns(m.Text, sel.Family, sel.Style, m.FontSize, false, 0, 0)
+	for _, run := range runs {
+		for _, g := range run.glyphs {
+			r.drawGlyph(run.face, g, x, baseline, m.FontSize, c, false)
+		}
+		x += run.width
+	}
+}
+
+// pixels returns the pixels covering a rectangle given in points. Rectangles
+// thinner than a pixel keep one, so that hairlines do not disappear.
+func (r *Renderer) pixels(x, y, w, h float64) image.Rectangle {
+	rect := image.Rect(
+		int(math.Round(x*r.scale)), int(math.Round(y*r.scale)),
+		int(math.Round((x+w)*r.scale)), int(math.Round((y+h)*r.scale)),
+	)
+	if w > 0 && rect.Dx() == 0 {
+		rect.Max.X++
+	}
+	if h > 0 && rect.Dy() == 0 {
+		rect.Max.Y++
+	}
+	return rect
+}
+
+// fillRect fills a rectangle given in points
+func (r *Renderer) fillRect(x, y, w, h float64, c color.Color) {
+	draw.Draw(r.dst, r.pixels(x, y, w, h), image.NewUniform(c), image.Point{}, draw.Over)
+}
+
+// fillCircle fills a disc given in points, or the ring between radius and
+// inner when inner is positive
+func (r *Renderer) fillCircle(cx, cy, radius, inner float64, c color.Color) {
+	rect := r.pixels(cx-radius, cy-radius, 2*radius, 2*radius).Inset(-1)
+	ox, oy := cx*r.scale-float64(rect.Min.X), cy*r.scale-float64(rect.Min.Y)
+	r.rasterizer.Reset(rect.Dx(), rect.Dy())
+	// The inner circle winds the other way to cut the hole of a ring
+	for _, ring := range []struct{ radius, dir float64 }{{radius, 1}, {inner, -1}} {
+		if ring.radius <= 0 {
+			continue
+		}
+		const steps = 48
+		rr := ring.radius * r.scale
+		for i := 0; i <= steps; i++ {
+			a := ring.dir * 2 * math.Pi * float64(i) / steps
+			px, py := float32(ox+rr*math.Cos(a)), float32(oy+rr*math.Sin(a))
+			if i == 0 {
+				r.rasterizer.MoveTo(px, py)
+			} else {
+				r.rasterizer.LineTo(px, py)
+			}
+		}
+		r.rasterizer.ClosePath()
+	}
+	r.fillMask(rect, c)
+}
+
+// rgba converts a parsed CSS color to an opaque color
+func rgba(c [3]int) color.RGBA {
+	return color.RGBA{uint8(c[0]), uint8(c[1]), uint8(c[2]), 0xff}
+}
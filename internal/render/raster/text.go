@@ -0,0 +1,296 @@
+package raster
+
+import (
+	"image"
+	"image/color"
+	"math"
+	"strings"
+	"unicode/utf8"
+
+	"codeberg.org/go-pdf/fpdf"
+	"github.com/gompdf/gompdf/internal/fonts"
+	"github.com/gompdf/gompdf/internal/layout"
+	"github.com/gompdf/gompdf/internal/logging"
+	"github.com/gompdf/gompdf/internal/style"
+	"github.com/gompdf/gompdf/internal/text"
+	"golang.org/x/image/draw"
+	"golang.org/x/image/font"
+	"golang.org/x/image/font/gofont/gobold"
+	"golang.org/x/image/font/gofont/gobolditalic"
+	"golang.org/x/image/font/gofont/goitalic"
+	"golang.org/x/image/font/gofont/gomono"
+	"golang.org/x/image/font/gofont/gomonobold"
+	"golang.org/x/image/font/gofont/gomonobolditalic"
+	"golang.org/x/image/font/gofont/gomonoitalic"
+	"golang.org/x/image/font/gofont/goregular"
+	"golang.org/x/image/font/sfnt"
+	"golang.org/x/image/math/fixed"
+)
+
+// coreFaces stand in for the core PDF fonts, which come without font files:
+// Courier is drawn in Go Mono and the other core fonts in the proportional
+// Go fonts
+var coreFaces = map[string][]byte{
+	fonts.StyleRegular:             goregular.TTF,
+	fonts.StyleBold:                gobold.TTF,
+	fonts.StyleItalic:              goitalic.TTF,
+	fonts.StyleBoldItalic:          gobolditalic.TTF,
+	"mono" + fonts.StyleRegular:    gomono.TTF,
+	"mono" + fonts.StyleBold:       gomonobold.TTF,
+	"mono" + fonts.StyleItalic:     gomonoitalic.TTF,
+	"mono" + fonts.StyleBoldItalic: gomonobolditalic.TTF,
+}
+
+// textRun is a piece of a text box drawn with one font: its glyphs and
+// their positions relative to the start of the run on the baseline
+type textRun struct {
+	face   *sfnt.Font
+	glyphs []runGlyph
+	width  float64
+}
+
+// runGlyph is a glyph of a text run. Glyphs standing in for characters of
+// core fonts are condensed by sx to the advance of the core font.
+type runGlyph struct {
+	index    sfnt.GlyphIndex
+	x, y, sx float64
+}
+
+// face returns the parsed font of an fpdf family and style: the registered
+// face, or else the Go font standing in for the core font. It reports
+// whether the face is registered, and so shaped.
+func (r *Renderer) face(family, fontStyle string) (*sfnt.Font, bool) {
+	data, registered := r.Fonts.FaceData(family, fontStyle), true
+	key := strings.ToLower(family) + "/" + fontStyle
+	if data == nil {
+		registered = false
+		key = fontStyle
+		if strings.EqualFold(family, "Courier") {
+			key = "mono" + fontStyle
+		}
+		data = coreFaces[key]
+		if data == nil {
+			data = goregular.TTF
+		}
+		key = "core/" + key
+	}
+	if f, ok := r.faces[key]; ok {
+		return f, registered
+	}
+	f, err := sfnt.Parse(data)
+	if err != nil {
+		r.warn(logging.WarningFont, nil, "Failed to parse font %s: %v\n", family, err)
+		f = nil
+	}
+	r.faces[key] = f
+	return f, registered
+}
+
+// textRuns splits the text of a box into font runs in visual order and
+// places their glyphs. Runs set in registered faces are shaped; the others
+// are placed character by character with the advances of the core font,
+// which layout measured them with.
+func (r *Renderer) textRuns(s, family, fontStyle string, fontSize float64, rtl bool, letterSpacing, wordSpacing float64) ([]textRun, float64) {
+	dir := text.LeftToRight
+	if rtl {
+		dir = text.RightToLeft
+	}
+	var runs []textRun
+	total := 0.0
+	for _, run := range r.Fonts.Runs(s, family, fontStyle) {
+		face, registered := r.face(run.Family, run.Style)
+		if face == nil {
+			continue
+		}
+		tr := textRun{face: face}
+		extra := 0.0
+		if registered {
+			shaped := r.shaper().Shape(run.Text, &text.Font{Size: fontSize, Data: r.Fonts.FaceData(run.Family, run.Style)}, dir)
+			for _, g := range shaped.Glyphs {
+				tr.glyphs = append(tr.glyphs, runGlyph{index: sfnt.GlyphIndex(g.Index), x: g.X + extra, y: g.Y - shaped.Ascent, sx: 1})
+				extra += letterSpacing * float64(utf8.RuneCountInString(g.Text))
+				if g.Text == " " {
+					extra += wordSpacing
+				}
+			}
+			tr.width = shaped.Width + extra
+		} else {
+			t := run.Text
+			if rtl {
+				t = text.ReverseText(t)
+			}
+			r.metrics().SetFont(run.Family, run.Style, fontSize)
+			x := 0.0
+			ppem := fixed.Int26_6(fontSize * 64)
+			for _, ch := range t {
+				width := r.metrics().GetStringWidth(r.Fonts.Encode(run.Family, string(ch)))
+				if index, err := face.GlyphIndex(&r.buf, ch); err == nil && index != 0 {
+					g := runGlyph{index: index, x: x, sx: 1}
+					if advance, err := face.GlyphAdvance(&r.buf, index, ppem, font.HintingNone); err == nil && advance > 0 {
+						g.sx = width / (float64(advance) / 64)
+					}
+					tr.glyphs = append(tr.glyphs, g)
+				}
+				x += width + letterSpacing
+				if ch == ' ' {
+					x += wordSpacing
+				}
+			}
+			tr.width = x
+		}
+		runs = append(runs, tr)
+		total += tr.width
+	}
+	if rtl {
+		for i, j := 0, len(runs)-1; i < j; i, j = i+1, j-1 {
+			runs[i], runs[j] = runs[j], runs[i]
+		}
+	}
+	return runs, total
+}
+
+// metrics returns the fpdf document measuring core fonts, creating it on
+// first use
+func (r *Renderer) metrics() *fpdf.Fpdf {
+	if r.measure == nil {
+		r.measure = fpdf.New("P", "pt", "", "")
+	}
+	return r.measure
+}
+
+// shaper returns the renderer's text shaper, creating it on first use
+func (r *Renderer) shaper() *text.TextShaper {
+	if r.textShaper == nil {
+		r.textShaper = text.NewTextShaper()
+	}
+	return r.textShaper
+}
+
+// renderText draws the text of an inline box, aligned and placed on its
+// baseline as the PDF renderer does
+func (r *Renderer) renderText(box *layout.InlineBox) {
+	fontSize := 12.0
+	if v, ok := style.AbsoluteLength(box.Style["font-size"].Value); ok {
+		fontSize = v
+	}
+	sel := r.Fonts.Match(box.Style["font-family"].Value, box.Style["font-weight"].Value, box.Style["font-style"].Value)
+	c := color.RGBA{A: 0xff}
+	if v := box.Style["color"].Value; v != "" {
+		c = rgba(style.ParseColor(v))
+	}
+
+	s := box.Text
+	if style.HasPageCounters(s) {
+		s = style.ResolvePageCounters(s, r.pageCounter)
+	}
+	if box.Vertical {
+		r.renderVerticalText(box, s, sel, fontSize, c)
+		return
+	}
+	runs, width := r.textRuns(s, sel.Family, sel.Style, fontSize, box.RTL, box.Style.LetterSpacing(), box.Style.WordSpacing())
+
+	align := strings.ToLower(strings.TrimSpace(box.Style["text-align"].Value))
+	if (align == "" || align == "left") && strings.EqualFold(strings.TrimSpace(box.Style["direction"].Value), "rtl") {
+		align = "right"
+	}
+	x := box.X
+	switch align {
+	case "center":
+		x = box.X + (box.Width-width)/2
+	case "right", "end":
+		x = box.X + box.Width - width
+	}
+	x = math.Min(math.Max(x, box.X), box.X+box.Width)
+
+	for _, run := range runs {
+		for _, g := range run.glyphs {
+			r.drawGlyph(run.face, g, x, baseline(box, fontSize), fontSize, c, false)
+		}
+		x += run.width
+	}
+}
+
+// baseline returns the baseline of the text of an inline box. Inline tokens
+// of paragraphs have no node and their Y is the baseline minus the font
+// size; other boxes center the ascent and descent of the font in their
+// content height.
+func baseline(box *layout.InlineBox, fontSize float64) float64 {
+	if box.Node == nil {
+		return box.Y + fontSize
+	}
+	content := math.Max(box.Height-box.PaddingTop-box.PaddingBottom-box.BorderTop-box.BorderBottom, 0)
+	ascent, descent := 0.8*fontSize, 0.2*fontSize
+	if ascent+descent > content {
+		scale := content / (ascent + descent)
+		ascent, descent = ascent*scale, descent*scale
+	}
+	leading := math.Max(content-ascent-descent, 0)
+	return box.Y + box.BorderTop + box.PaddingTop + ascent + leading/2
+}
+
+// drawGlyph draws a glyph of a run starting at x on the baseline y, in
+// points. A turned glyph is drawn a quarter turn clockwise about x, y, for
+// text running down a vertical line.
+func (r *Renderer) drawGlyph(f *sfnt.Font, g runGlyph, x, y, fontSize float64, c color.Color, turned bool) {
+	segments, err := f.LoadGlyph(&r.buf, g.index, fixed.Int26_6(fontSize*r.scale*64), nil)
+	if err != nil || len(segments) == 0 {
+		return
+	}
+	ox, oy := x*r.scale, y*r.scale
+	// place maps a point of the glyph, relative to the start of the run, to
+	// the page in pixels
+	place := func(px, py float64) (float64, float64) {
+		dx, dy := g.x*r.scale+px*g.sx, g.y*r.scale+py
+		if turned {
+			return ox - dy, oy + dx
+		}
+		return ox + dx, oy + dy
+	}
+	b := segments.Bounds()
+	x0, y0 := place(float64(b.Min.X)/64, float64(b.Min.Y)/64)
+	x1, y1 := place(float64(b.Max.X)/64, float64(b.Max.Y)/64)
+	minX, minY := math.Floor(math.Min(x0, x1)), math.Floor(math.Min(y0, y1))
+	maxX, maxY := math.Ceil(math.Max(x0, x1)), math.Ceil(math.Max(y0, y1))
+	w, h := int(maxX-minX), int(maxY-minY)
+	if w <= 0 || h <= 0 {
+		return
+	}
+
+	point := func(p fixed.Point26_6) (float32, float32) {
+		px, py := place(float64(p.X)/64, float64(p.Y)/64)
+		return float32(px - minX), float32(py - minY)
+	}
+	r.rasterizer.Reset(w, h)
+	started := false
+	for _, seg := range segments {
+		switch seg.Op {
+		case sfnt.SegmentOpMoveTo:
+			if started {
+				r.rasterizer.ClosePath()
+			}
+			r.rasterizer.MoveTo(point(seg.Args[0]))
+			started = true
+		case sfnt.SegmentOpLineTo:
+			r.rasterizer.LineTo(point(seg.Args[0]))
+		case sfnt.SegmentOpQuadTo:
+			bx, by := point(seg.Args[0])
+			cx, cy := point(seg.Args[1])
+			r.rasterizer.QuadTo(bx, by, cx, cy)
+		case sfnt.SegmentOpCubeTo:
+			bx, by := point(seg.Args[0])
+			cx, cy := point(seg.Args[1])
+			dx, dy := point(seg.Args[2])
+			r.rasterizer.CubeTo(bx, by, cx, cy, dx, dy)
+		}
+	}
+	r.rasterizer.ClosePath()
+	r.fillMask(image.Rect(int(minX), int(minY), int(maxX), int(maxY)), c)
+}
+
+// fillMask paints c through the coverage of the rasterizer's path onto the
+// page at rect, whose size the rasterizer has
+func (r *Renderer) fillMask(rect image.Rectangle, c color.Color) {
+	mask := image.NewAlpha(image.Rect(0, 0, rect.Dx(), rect.Dy()))
+	r.rasterizer.Draw(mask, mask.Bounds(), image.Opaque, image.Point{})
+	draw.DrawMask(r.dst, rect, image.NewUniform(c), image.Point{}, mask, image.Point{}, draw.Over)
+}
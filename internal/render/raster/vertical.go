@@ -0,0 +1,39 @@
+package raster
+
+import (
+	"image/color"
+
+	"github.com/gompdf/gompdf/internal/fonts"
+	"github.com/gompdf/gompdf/internal/layout"
+)
+
+// renderVerticalText draws the text of a box set in a vertical writing mode
+// down the box, as the PDF renderer does: sideways runs turned a quarter
+// turn clockwise on the baseline of the box and upright characters one em
+// apart, centered 0.3em right of the baseline
+func (r *Renderer) renderVerticalText(box *layout.InlineBox, text string, sel fonts.Selection, fontSize float64, c color.Color) {
+	letterSpacing, wordSpacing := box.Style.LetterSpacing(), box.Style.WordSpacing()
+	baseline, y := box.VerticalBaseline(), box.Y
+	for _, seg := range box.Style.VerticalSegments(text) {
+		runs, width := r.textRuns(seg.Text, sel.Family, sel.Style, fontSize, false, letterSpacing, wordSpacing)
+		if seg.Upright {
+			x := baseline + 0.3*fontSize - (width-letterSpacing)/2
+			for _, run := range runs {
+				for _, g := range run.glyphs {
+					r.drawGlyph(run.face, g, x, y+0.88*fontSize, fontSize, c, false)
+				}
+				x += run.width
+			}
+			y += fontSize + letterSpacing
+			continue
+		}
+		start := y
+		for _, run := range runs {
+			for _, g := range run.glyphs {
+				r.drawGlyph(run.face, g, baseline, start, fontSize, c, true)
+			}
+			start += run.width
+		}
+		y += width
+	}
+}
@@ -0,0 +1,163 @@
+package res
+
+import (
+	"crypto/sha256"
+	"encoding/gob"
+	"encoding/hex"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Cache keeps remote resources across loaders, so that conversions of
+// documents referencing the same fonts, images and stylesheets download
+// them once. Resources are kept by their resolved URL. A Cache must be safe
+// for concurrent use; a Cache that fails to read or write an entry should
+// treat it as missing.
+type Cache interface {
+	// Get returns the resource kept for url, if any
+	Get(url string) (*Resource, bool)
+	// Put keeps a resource loaded from url
+	Put(url string, resource *Resource)
+}
+
+// cacheable reports whether a loaded resource is kept in a Cache. Images,
+// fonts and stylesheets are, while documents, which change more often than
+// their assets, are not.
+func cacheable(resource *Resource) bool {
+	switch resource.Type {
+	case ResourceTypeImage, ResourceTypeFont, ResourceTypeCSS:
+		return true
+	}
+	return false
+}
+
+// DiskCache is a Cache keeping resources as files in a directory, one per
+// URL, so that they outlive the process. Entries expire a time to live
+// after they were stored, and when the files exceed the maximum size the
+// oldest are removed. Processes can share the directory.
+type DiskCache struct {
+	dir     string
+	ttl     time.Duration
+	maxSize int64
+
+	// mu serializes the evictions of this process
+	mu sync.Mutex
+}
+
+// diskEntry is the content of a file of a DiskCache
+type diskEntry struct {
+	URL      string
+	Type     ResourceType
+	MimeType string
+	Data     []byte
+}
+
+// diskCacheSuffix ends the names of the files of a DiskCache, which
+// removes no other files
+const diskCacheSuffix = ".res"
+
+// NewDiskCache creates a cache in dir, which is created if needed. Entries
+// older than ttl are not used, 0 keeping them until they are evicted, and
+// the oldest entries are removed when the files exceed maxSize bytes, 0 for
+// no limit.
+func NewDiskCache(dir string, ttl time.Duration, maxSize int64) (*DiskCache, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, err
+	}
+	return &DiskCache{dir: dir, ttl: ttl, maxSize: maxSize}, nil
+}
+
+// path returns the path of the file of url
+func (c *DiskCache) path(url string) string {
+	sum := sha256.Sum256([]byte(url))
+	return filepath.Join(c.dir, hex.EncodeToString(sum[:])+diskCacheSuffix)
+}
+
+// Get returns the resource stored for url unless it has expired
+func (c *DiskCache) Get(url string) (*Resource, bool) {
+	path := c.path(url)
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, false
+	}
+	defer file.Close()
+	if c.ttl > 0 {
+		info, err := file.Stat()
+		if err != nil || time.Since(info.ModTime()) > c.ttl {
+			os.Remove(path)
+			return nil, false
+		}
+	}
+	var entry diskEntry
+	if err := gob.NewDecoder(file).Decode(&entry); err != nil || entry.URL != url {
+		return nil, false
+	}
+	return &Resource{URL: url, Type: entry.Type, Data: entry.Data, MimeType: entry.MimeType}, true
+}
+
+// Put stores a resource for url, replacing what was stored for it
+func (c *DiskCache) Put(url string, resource *Resource) {
+	// The entry is written to a temporary file and renamed into place, so
+	// that no one reads it half written
+	tmp, err := os.CreateTemp(c.dir, "tmp-*")
+	if err != nil {
+		return
+	}
+	entry := diskEntry{URL: url, Type: resource.Type, MimeType: resource.MimeType, Data: resource.Data}
+	err = gob.NewEncoder(tmp).Encode(&entry)
+	if cerr := tmp.Close(); err == nil {
+		err = cerr
+	}
+	if err == nil {
+		err = os.Rename(tmp.Name(), c.path(url))
+	}
+	if err != nil {
+		os.Remove(tmp.Name())
+		return
+	}
+	c.evict()
+}
+
+// evict removes the oldest entries while the files exceed the maximum size
+func (c *DiskCache) evict() {
+	if c.maxSize <= 0 {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	dirEntries, err := os.ReadDir(c.dir)
+	if err != nil {
+		return
+	}
+	var files []os.FileInfo
+	var size int64
+	for _, de := range dirEntries {
+		if !strings.HasSuffix(de.Name(), diskCacheSuffix) {
+			continue
+		}
+		info, err := de.Info()
+		if err != nil {
+			continue
+		}
+		files = append(files, info)
+		size += info.Size()
+	}
+	if size <= c.maxSize {
+		return
+	}
+	slices.SortFunc(files, func(a, b os.FileInfo) int {
+		return a.ModTime().Compare(b.ModTime())
+	})
+	for _, info := range files {
+		if size <= c.maxSize {
+			break
+		}
+		if os.Remove(filepath.Join(c.dir, info.Name())) == nil {
+			size -= info.Size()
+		}
+	}
+}
@@ -1,4 +1,4 @@
-package pdf
+package res
 
 // Register a broad set of image decoders so image.Decode can handle many formats.
 // These are blank imports to hook into the init() of respective packages.
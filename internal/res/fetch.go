@@ -0,0 +1,95 @@
+package res
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// Fetcher loads the resources of the URLs of a scheme, such as
+// "asset://logo.png" from object storage. Fetchers are called concurrently.
+// An error wrapping ErrNotFound reports a resource that does not exist.
+type Fetcher interface {
+	Fetch(ctx context.Context, url string) (*Resource, error)
+}
+
+// FetcherFunc is a function used as a Fetcher
+type FetcherFunc func(ctx context.Context, url string) (*Resource, error)
+
+// Fetch calls f
+func (f FetcherFunc) Fetch(ctx context.Context, url string) (*Resource, error) {
+	return f(ctx, url)
+}
+
+// ResolveFunc rewrites the URL a resource is loaded from, such as to sign
+// it, after it was resolved against the document. It returns the URL to
+// load instead, which may be the same.
+type ResolveFunc func(url string) (string, error)
+
+// SetFetchers sets the fetchers that load the URLs of schemes, by lower
+// case scheme such as "asset". A fetcher for "http" or "https" replaces the
+// loader's HTTP client and cache for those URLs.
+func (l *Loader) SetFetchers(fetchers map[string]Fetcher) {
+	l.fetchers = fetchers
+}
+
+// SetResolver sets the function rewriting the URLs of resources before they
+// are loaded
+func (l *Loader) SetResolver(resolve ResolveFunc) {
+	l.rewrite = resolve
+}
+
+// fetcherOf returns the fetcher of the scheme of u, nil when there is none
+func (l *Loader) fetcherOf(u string) Fetcher {
+	if len(l.fetchers) == 0 {
+		return nil
+	}
+	scheme, _, ok := strings.Cut(u, ":")
+	// A letter before the colon is the drive of a Windows path
+	if !ok || len(scheme) < 2 {
+		return nil
+	}
+	return l.fetchers[strings.ToLower(scheme)]
+}
+
+// fetch loads a resource with a fetcher, completing what it leaves out of
+// the resource: the URL, the MIME type from the extension or the content,
+// and the type
+func (l *Loader) fetch(f Fetcher, urlStr string) (*Resource, error) {
+	ctx := l.context()
+	if l.timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, l.timeout)
+		defer cancel()
+	}
+	fetched, err := f.Fetch(ctx, urlStr)
+	if err != nil {
+		return nil, err
+	}
+	if fetched == nil {
+		return nil, fmt.Errorf("%w: %s", ErrNotFound, urlStr)
+	}
+	if err := l.policy.checkSize(int64(len(fetched.Data)), urlStr); err != nil {
+		return nil, err
+	}
+	res := *fetched
+	if res.URL == "" {
+		res.URL = urlStr
+	}
+	name := urlStr
+	if u, err := url.Parse(urlStr); err == nil {
+		name = u.Path
+	}
+	if res.MimeType == "" {
+		res.MimeType = determineMimeType(name)
+		if res.MimeType == "application/octet-stream" {
+			res.MimeType = http.DetectContentType(res.Data)
+		}
+	}
+	if res.Type == ResourceTypeUnknown {
+		res.Type = determineResourceType(res.MimeType, name)
+	}
+	return &res, nil
+}
@@ -0,0 +1,39 @@
+package res
+
+import (
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// SetFS has the loader read local resources from fsys instead of the file
+// system, such as files embedded with go:embed. Paths are looked up in fsys
+// with their leading slash removed, so "/css/site.css" and "css/site.css"
+// relative to a document at the root are the same file. A nil fsys
+// restores the file system.
+func (l *Loader) SetFS(fsys fs.FS) {
+	l.fsys = fsys
+}
+
+// FSPath returns the name in an fs.FS of a file path such as a resolved
+// resource or the path of a document: slash separated, without a leading
+// slash and cleaned. Paths reaching above the root give names fs.FS
+// rejects.
+func FSPath(name string) string {
+	p := path.Clean(filepath.ToSlash(name))
+	p = strings.TrimPrefix(p, "/")
+	if p == "" {
+		return "."
+	}
+	return p
+}
+
+// open opens a local file from the loader's fs.FS or else the file system
+func (l *Loader) open(name string) (fs.File, error) {
+	if l.fsys == nil {
+		return os.Open(name)
+	}
+	return l.fsys.Open(FSPath(name))
+}
@@ -0,0 +1,125 @@
+package res
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"image"
+	"strconv"
+	"strings"
+
+	"github.com/srwiley/oksvg"
+)
+
+// IsSVG reports whether the resource is an SVG document
+func (r *Resource) IsSVG() bool {
+	return strings.EqualFold(strings.TrimSpace(r.MimeType), "image/svg+xml")
+}
+
+// IsPDF reports whether the resource is a PDF document, whose pages are
+// drawn as images
+func (r *Resource) IsPDF() bool {
+	return strings.HasPrefix(strings.TrimSpace(r.MimeType), "application/pdf") || bytes.HasPrefix(r.Data, []byte("%PDF-"))
+}
+
+// FragmentPage returns the page of a PDF document the fragment of a URL
+// selects, as in "terms.pdf#page=2", from 1; 1 without one
+func FragmentPage(src string) int {
+	_, fragment, _ := strings.Cut(src, "#")
+	for _, param := range strings.Split(fragment, "&") {
+		if v, ok := strings.CutPrefix(param, "page="); ok {
+			if n, err := strconv.Atoi(strings.TrimSpace(v)); err == nil && n > 0 {
+				return n
+			}
+		}
+	}
+	return 1
+}
+
+// ImageSize returns the intrinsic pixel dimensions of an image resource. For
+// SVG documents the size of the view box is used.
+func (r *Resource) ImageSize() (int, int, error) {
+	if r.IsSVG() {
+		icon, err := oksvg.ReadIconStream(bytes.NewReader(r.Data))
+		if err != nil {
+			return 0, 0, fmt.Errorf("svg parse: %w", err)
+		}
+		return int(icon.ViewBox.W + 0.5), int(icon.ViewBox.H + 0.5), nil
+	}
+	cfg, _, err := image.DecodeConfig(bytes.NewReader(r.Data))
+	if err != nil {
+		return 0, 0, err
+	}
+	return cfg.Width, cfg.Height, nil
+}
+
+// ImageResolution returns the resolution in dots per inch a raster image
+// declares, horizontally and vertically: that of the pHYs chunk of a PNG or
+// of the JFIF header of a JPEG. ok is false when the image declares none, or
+// only an aspect ratio.
+func (r *Resource) ImageResolution() (x, y float64, ok bool) {
+	data := r.Data
+	switch {
+	case bytes.HasPrefix(data, []byte("\x89PNG\r\n\x1a\n")):
+		return pngResolution(data[8:])
+	case bytes.HasPrefix(data, []byte{0xFF, 0xD8}):
+		return jfifResolution(data[2:])
+	}
+	return 0, 0, false
+}
+
+// pngResolution reads the resolution of the pHYs chunk among the chunks
+// before the image data of a PNG
+func pngResolution(chunks []byte) (x, y float64, ok bool) {
+	for len(chunks) >= 12 {
+		length := int(binary.BigEndian.Uint32(chunks))
+		kind := string(chunks[4:8])
+		if length < 0 || len(chunks) < 12+length || kind == "IDAT" {
+			break
+		}
+		if kind == "pHYs" && length == 9 {
+			body := chunks[8:]
+			// Unit 1 is pixels per meter; 0 is an aspect ratio only
+			if body[8] != 1 {
+				break
+			}
+			ppmX, ppmY := binary.BigEndian.Uint32(body), binary.BigEndian.Uint32(body[4:])
+			if ppmX == 0 || ppmY == 0 {
+				break
+			}
+			return float64(ppmX) * 0.0254, float64(ppmY) * 0.0254, true
+		}
+		chunks = chunks[12+length:]
+	}
+	return 0, 0, false
+}
+
+// jfifResolution reads the resolution of the JFIF APP0 segment among the
+// segments after the start of a JPEG
+func jfifResolution(segments []byte) (x, y float64, ok bool) {
+	for len(segments) >= 4 && segments[0] == 0xFF {
+		marker := segments[1]
+		length := int(binary.BigEndian.Uint16(segments[2:]))
+		if length < 2 || len(segments) < 2+length || marker == 0xDA {
+			break
+		}
+		body := segments[4 : 2+length]
+		if marker == 0xE0 && len(body) >= 12 && bytes.HasPrefix(body, []byte("JFIF\x00")) {
+			unit := body[7]
+			dx, dy := float64(binary.BigEndian.Uint16(body[8:])), float64(binary.BigEndian.Uint16(body[10:]))
+			if dx == 0 || dy == 0 {
+				break
+			}
+			switch unit {
+			case 1:
+				return dx, dy, true
+			case 2:
+				// Dots per centimeter
+				return dx * 2.54, dy * 2.54, true
+			}
+			break
+		}
+		segments = segments[2+length:]
+	}
+	return 0, 0, false
+}
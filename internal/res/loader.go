@@ -2,18 +2,24 @@ package res
 
 import (
 	"bytes"
+	"context"
 	"encoding/base64"
 	"errors"
 	"fmt"
-	"io"
+	"io/fs"
 	"net/http"
+	"net/http/cookiejar"
 	"net/url"
 	"os"
 	"path/filepath"
 	"strings"
 	"sync"
+	"time"
 )
 
+// ErrNotFound is the error, possibly wrapped, of resources that do not exist
+var ErrNotFound = errors.New("resource not found")
+
 // ResourceType represents the type of resource
 type ResourceType int
 
@@ -46,12 +52,40 @@ type Loader struct {
 	// Resource cache
 	cache     map[string]*Resource
 	cacheLock sync.RWMutex
+	// failed remembers the errors of resources that could not be loaded, so
+	// that they are not requested again
+	failed map[string]error
 
 	// Resource search paths
 	searchPaths []string
 
 	// HTTP client for remote resources
 	client *http.Client
+	// headers are sent with every remote request, and the cookies of
+	// cookies with the requests of the hosts they belong to
+	headers http.Header
+	cookies http.CookieJar
+
+	// ctx bounds resource loading; remote requests are cancelled with it
+	ctx context.Context
+
+	// timeout bounds every remote request; 0 for no limit
+	timeout time.Duration
+
+	// shared keeps remote resources across loaders; nil for none
+	shared Cache
+
+	// policy restricts what is loaded; nil allows everything
+	policy *Policy
+
+	// fsys holds the local resources in place of the file system; nil
+	// for the file system
+	fsys fs.FS
+
+	// fetchers load the URLs of schemes, and rewrite rewrites URLs before
+	// they are loaded
+	fetchers map[string]Fetcher
+	rewrite  ResolveFunc
 }
 
 // NewLoader creates a new resource loader
@@ -59,11 +93,85 @@ func NewLoader(baseURL string) *Loader {
 	return &Loader{
 		BaseURL:     baseURL,
 		cache:       make(map[string]*Resource),
+		failed:      make(map[string]error),
 		searchPaths: []string{},
 		client:      &http.Client{},
 	}
 }
 
+// SetContext sets the context that bounds subsequent loads. Loads fail with
+// the context's error once it is done, and remote requests are cancelled.
+func (l *Loader) SetContext(ctx context.Context) {
+	l.ctx = ctx
+}
+
+// SetTimeout limits how long a remote resource may take to load; 0, the
+// default, leaves only the context to bound it
+func (l *Loader) SetTimeout(timeout time.Duration) {
+	l.timeout = timeout
+}
+
+// SetClient sets the HTTP client remote resources are requested with; nil
+// restores the default client
+func (l *Loader) SetClient(client *http.Client) {
+	if client == nil {
+		client = &http.Client{}
+	}
+	l.client = client
+}
+
+// SetHeaders sets headers sent with every remote request, such as
+// User-Agent or Authorization
+func (l *Loader) SetHeaders(headers http.Header) {
+	l.headers = headers
+}
+
+// SetCookies sets cookies sent with remote requests. A cookie with a Domain
+// goes to that domain and its subdomains; one without goes to the host of
+// the base URL only, and is dropped when the base URL is not a remote URL.
+func (l *Loader) SetCookies(cookies []*http.Cookie) {
+	if len(cookies) == 0 {
+		l.cookies = nil
+		return
+	}
+	jar, _ := cookiejar.New(nil)
+	base, _ := url.Parse(l.BaseURL)
+	for _, cookie := range cookies {
+		u := &url.URL{Scheme: "https", Path: "/"}
+		switch {
+		case cookie.Domain != "":
+			u.Host = strings.TrimPrefix(cookie.Domain, ".")
+		case base != nil && (base.Scheme == "http" || base.Scheme == "https"):
+			u.Scheme, u.Host = base.Scheme, base.Host
+		default:
+			continue
+		}
+		jar.SetCookies(u, []*http.Cookie{cookie})
+	}
+	l.cookies = jar
+}
+
+// SetPolicy restricts the resources the loader loads to those p allows.
+// Remote requests should be made with a client of p.Client, which checks
+// redirects and the addresses connected to.
+func (l *Loader) SetPolicy(p *Policy) {
+	l.policy = p
+}
+
+// SetCache sets the cache remote images, fonts and stylesheets are looked
+// up in before they are downloaded, and kept in after
+func (l *Loader) SetCache(cache Cache) {
+	l.shared = cache
+}
+
+// context returns the loader's context, defaulting to context.Background
+func (l *Loader) context() context.Context {
+	if l.ctx == nil {
+		return context.Background()
+	}
+	return l.ctx
+}
+
 // AddSearchPath adds a directory to search for local resources
 func (l *Loader) AddSearchPath(path string) {
 	l.searchPaths = append(l.searchPaths, path)
@@ -71,16 +179,27 @@ func (l *Loader) AddSearchPath(path string) {
 
 // Load loads a resource from a URL or file path
 func (l *Loader) Load(urlStr string) (*Resource, error) {
+	if err := l.context().Err(); err != nil {
+		return nil, err
+	}
+
 	// Check if the resource is already cached
 	l.cacheLock.RLock()
 	if res, ok := l.cache[urlStr]; ok {
 		l.cacheLock.RUnlock()
 		return res, nil
 	}
+	if err, ok := l.failed[urlStr]; ok {
+		l.cacheLock.RUnlock()
+		return nil, err
+	}
 	l.cacheLock.RUnlock()
 
 	// Handle data URLs directly
 	if strings.HasPrefix(urlStr, "data:") {
+		if err := l.policy.check(&url.URL{Scheme: "data"}); err != nil {
+			return nil, err
+		}
 		res, err := parseDataURL(urlStr)
 		if err != nil {
 			return nil, err
@@ -91,19 +210,29 @@ func (l *Loader) Load(urlStr string) (*Resource, error) {
 		return res, nil
 	}
 
-	resolvedURL, err := l.resolveURL(urlStr)
+	// A fragment selects part of the resource, such as a page of a PDF
+	// document, and is not part of where it is loaded from
+	target, _, _ := strings.Cut(urlStr, "#")
+	resolvedURL, err := l.resolveURL(target)
 	if err != nil {
 		return nil, err
 	}
 
+	if l.rewrite != nil {
+		resolvedURL, err = l.rewrite(resolvedURL)
+	}
 	var res *Resource
-	if strings.HasPrefix(resolvedURL, "http://") || strings.HasPrefix(resolvedURL, "https://") {
-		res, err = l.loadRemote(resolvedURL)
-	} else {
-		res, err = l.loadLocal(resolvedURL)
+	if err == nil {
+		res, err = l.loadResolved(resolvedURL)
 	}
 
 	if err != nil {
+		// Failures of a cancelled load say nothing about the resource
+		if l.context().Err() == nil {
+			l.cacheLock.Lock()
+			l.failed[urlStr] = err
+			l.cacheLock.Unlock()
+		}
 		return nil, err
 	}
 
@@ -114,6 +243,40 @@ func (l *Loader) Load(urlStr string) (*Resource, error) {
 	return res, nil
 }
 
+// loadResolved loads a resource from a resolved URL or file path
+func (l *Loader) loadResolved(resolvedURL string) (*Resource, error) {
+	switch fetcher := l.fetcherOf(resolvedURL); {
+	case fetcher != nil:
+		u, err := url.Parse(resolvedURL)
+		if err == nil {
+			err = l.policy.check(u)
+		}
+		if err != nil {
+			return nil, err
+		}
+		return l.fetch(fetcher, resolvedURL)
+	case strings.HasPrefix(resolvedURL, "data:"):
+		// A rewritten URL may be a data URL
+		if err := l.policy.check(&url.URL{Scheme: "data"}); err != nil {
+			return nil, err
+		}
+		return parseDataURL(resolvedURL)
+	case strings.HasPrefix(resolvedURL, "http://") || strings.HasPrefix(resolvedURL, "https://"):
+		u, err := url.Parse(resolvedURL)
+		if err == nil {
+			err = l.policy.check(u)
+		}
+		if err != nil {
+			return nil, err
+		}
+		return l.loadCachedRemote(resolvedURL)
+	}
+	if err := l.policy.checkPath(resolvedURL); err != nil {
+		return nil, err
+	}
+	return l.loadLocal(resolvedURL)
+}
+
 // parseDataURL parses a data URL (RFC 2397) and returns a Resource.
 // Examples:
 //   data:image/png;base64,<base64>
@@ -172,6 +335,45 @@ func parseDataURL(u string) (*Resource, error) {
 
 // resolveURL resolves a URL relative to the base URL
 func (l *Loader) resolveURL(urlStr string) (string, error) {
+	return l.resolveAgainst(l.BaseURL, urlStr)
+}
+
+// resolveAgainst resolves urlStr relative to base, where URLs of the
+// schemes of the loader's fetchers are URLs like remote ones
+func (l *Loader) resolveAgainst(base, urlStr string) (string, error) {
+	switch {
+	case l.fetcherOf(urlStr) != nil:
+		return urlStr, nil
+	case l.fetcherOf(base) != nil:
+		return resolveURLReference(base, urlStr)
+	}
+	return resolveAgainst(base, urlStr)
+}
+
+// ResolveReference resolves ref against base, a URL or file path such as the
+// location of a stylesheet. An empty base stands for the loader's base URL.
+// File paths are made absolute so that the result loads the same resource
+// regardless of the base URL.
+func (l *Loader) ResolveReference(base, ref string) (string, error) {
+	if strings.HasPrefix(ref, "data:") {
+		return ref, nil
+	}
+	if base == "" {
+		base = l.BaseURL
+	}
+	resolved, err := l.resolveAgainst(base, ref)
+	if err != nil || strings.HasPrefix(resolved, "http://") || strings.HasPrefix(resolved, "https://") || l.fetcherOf(resolved) != nil {
+		return resolved, err
+	}
+	if l.fsys != nil {
+		// Paths in an fs.FS are relative to its root
+		return "/" + FSPath(resolved), nil
+	}
+	return filepath.Abs(resolved)
+}
+
+// resolveAgainst resolves urlStr relative to base
+func resolveAgainst(base, urlStr string) (string, error) {
 	if strings.HasPrefix(urlStr, "http://") || strings.HasPrefix(urlStr, "https://") {
 		return urlStr, nil
 	}
@@ -180,12 +382,17 @@ func (l *Loader) resolveURL(urlStr string) (string, error) {
 		return urlStr, nil
 	}
 
-	if !strings.HasPrefix(l.BaseURL, "http://") && !strings.HasPrefix(l.BaseURL, "https://") {
-		baseDir := filepath.Dir(l.BaseURL)
+	if !strings.HasPrefix(base, "http://") && !strings.HasPrefix(base, "https://") {
+		baseDir := filepath.Dir(base)
 		return filepath.Join(baseDir, urlStr), nil
 	}
 
-	baseURL, err := url.Parse(l.BaseURL)
+	return resolveURLReference(base, urlStr)
+}
+
+// resolveURLReference resolves urlStr relative to the URL base
+func resolveURLReference(base, urlStr string) (string, error) {
+	baseURL, err := url.Parse(base)
 	if err != nil {
 		return "", err
 	}
@@ -198,19 +405,59 @@ func (l *Loader) resolveURL(urlStr string) (string, error) {
 	return baseURL.ResolveReference(relURL).String(), nil
 }
 
+// loadCachedRemote loads a resource from a remote URL out of the loader's
+// Cache, or else downloads it and keeps it there
+func (l *Loader) loadCachedRemote(urlStr string) (*Resource, error) {
+	if l.shared != nil {
+		if res, ok := l.shared.Get(urlStr); ok {
+			return res, nil
+		}
+	}
+	res, err := l.loadRemote(urlStr)
+	if err == nil && l.shared != nil && cacheable(res) {
+		l.shared.Put(urlStr, res)
+	}
+	return res, err
+}
+
 // loadRemote loads a resource from a remote URL
 func (l *Loader) loadRemote(urlStr string) (*Resource, error) {
-	resp, err := l.client.Get(urlStr)
+	ctx := l.context()
+	if l.timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, l.timeout)
+		defer cancel()
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, urlStr, nil)
+	if err != nil {
+		return nil, err
+	}
+	for key, values := range l.headers {
+		req.Header[http.CanonicalHeaderKey(key)] = values
+	}
+	if l.cookies != nil {
+		for _, cookie := range l.cookies.Cookies(req.URL) {
+			req.AddCookie(cookie)
+		}
+	}
+	resp, err := l.client.Do(req)
 	if err != nil {
 		return nil, err
 	}
 	defer resp.Body.Close()
 
-	if resp.StatusCode != http.StatusOK {
+	switch resp.StatusCode {
+	case http.StatusOK:
+	case http.StatusNotFound, http.StatusGone:
+		return nil, fmt.Errorf("%w: %s: HTTP error: %s", ErrNotFound, urlStr, resp.Status)
+	default:
 		return nil, fmt.Errorf("HTTP error: %s", resp.Status)
 	}
 
-	data, err := io.ReadAll(resp.Body)
+	if err := l.policy.checkSize(resp.ContentLength, urlStr); err != nil {
+		return nil, err
+	}
+	data, err := l.policy.readAll(resp.Body, urlStr)
 	if err != nil {
 		return nil, err
 	}
@@ -228,7 +475,7 @@ func (l *Loader) loadRemote(urlStr string) (*Resource, error) {
 
 // loadLocal loads a resource from a local file
 func (l *Loader) loadLocal(path string) (*Resource, error) {
-	file, err := os.Open(path)
+	file, err := l.open(path)
 	if err != nil {
 		if errors.Is(err, os.ErrNotExist) {
 			return l.loadFromSearchPaths(path)
@@ -237,7 +484,7 @@ func (l *Loader) loadLocal(path string) (*Resource, error) {
 	}
 	defer file.Close()
 
-	data, err := io.ReadAll(file)
+	data, err := l.policy.readAll(file, path)
 	if err != nil {
 		return nil, err
 	}
@@ -261,13 +508,16 @@ func (l *Loader) loadFromSearchPaths(filename string) (*Resource, error) {
 	for _, searchPath := range l.searchPaths {
 		path := filepath.Join(searchPath, baseFilename)
 
-		file, err := os.Open(path)
+		file, err := l.open(path)
 		if err != nil {
 			continue
 		}
 		defer file.Close()
 
-		data, err := io.ReadAll(file)
+		data, err := l.policy.readAll(file, path)
+		if errors.Is(err, ErrDenied) {
+			return nil, err
+		}
 		if err != nil {
 			continue
 		}
@@ -284,7 +534,7 @@ func (l *Loader) loadFromSearchPaths(filename string) (*Resource, error) {
 		return res, nil
 	}
 
-	return nil, fmt.Errorf("resource not found: %s", filename)
+	return nil, fmt.Errorf("%w: %s", ErrNotFound, filename)
 }
 
 // determineMimeType determines the MIME type of a file
@@ -308,6 +558,8 @@ func determineMimeType(path string) string {
 		return "image/x-icon"
 	case ".svg":
 		return "image/svg+xml"
+	case ".pdf":
+		return "application/pdf"
 	case ".ttf":
 		return "font/ttf"
 	case ".otf":
@@ -327,11 +579,14 @@ func determineMimeType(path string) string {
 
 // determineResourceType determines the type of a resource
 func determineResourceType(mimeType, path string) ResourceType {
-	if strings.HasPrefix(mimeType, "image/") {
+	// The pages of PDF documents are drawn as images
+	if strings.HasPrefix(mimeType, "image/") || strings.HasPrefix(mimeType, "application/pdf") {
 		return ResourceTypeImage
 	}
 
-	if strings.HasPrefix(mimeType, "font/") {
+	// Fonts are also served as application/font-woff, application/x-font-ttf, ...
+	if strings.HasPrefix(mimeType, "font/") || strings.HasPrefix(mimeType, "application/font-") ||
+		strings.HasPrefix(mimeType, "application/x-font-") {
 		return ResourceTypeFont
 	}
 
@@ -342,7 +597,7 @@ func determineResourceType(mimeType, path string) ResourceType {
 	ext := strings.ToLower(filepath.Ext(path))
 
 	switch ext {
-	case ".jpg", ".jpeg", ".png", ".gif", ".svg", ".webp", ".tiff", ".tif", ".bmp", ".ico":
+	case ".jpg", ".jpeg", ".png", ".gif", ".svg", ".webp", ".tiff", ".tif", ".bmp", ".ico", ".pdf":
 		return ResourceTypeImage
 	case ".ttf", ".otf", ".woff", ".woff2":
 		return ResourceTypeFont
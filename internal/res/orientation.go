@@ -0,0 +1,109 @@
+package res
+
+import (
+	"bytes"
+	"encoding/binary"
+	"image"
+	"image/draw"
+)
+
+// ImageOrientation returns the EXIF orientation of a JPEG image, from 1 for
+// an image stored upright to 8, as cameras record how they were held. It is
+// 1 for other images and JPEGs without one.
+func (r *Resource) ImageOrientation() int {
+	data := r.Data
+	if !bytes.HasPrefix(data, []byte{0xFF, 0xD8}) {
+		return 1
+	}
+	segments := data[2:]
+	for len(segments) >= 4 && segments[0] == 0xFF {
+		marker := segments[1]
+		length := int(binary.BigEndian.Uint16(segments[2:]))
+		if length < 2 || len(segments) < 2+length || marker == 0xDA {
+			break
+		}
+		body := segments[4 : 2+length]
+		if marker == 0xE1 && bytes.HasPrefix(body, []byte("Exif\x00\x00")) {
+			return exifOrientation(body[6:])
+		}
+		segments = segments[2+length:]
+	}
+	return 1
+}
+
+// exifOrientation reads the Orientation tag of the first image file
+// directory of the TIFF structure of an Exif segment
+func exifOrientation(tiff []byte) int {
+	if len(tiff) < 8 {
+		return 1
+	}
+	var order binary.ByteOrder
+	switch string(tiff[:2]) {
+	case "II":
+		order = binary.LittleEndian
+	case "MM":
+		order = binary.BigEndian
+	default:
+		return 1
+	}
+	ifd := int(order.Uint32(tiff[4:]))
+	if ifd < 8 || ifd+2 > len(tiff) {
+		return 1
+	}
+	count := int(order.Uint16(tiff[ifd:]))
+	for i := 0; i < count; i++ {
+		entry := ifd + 2 + 12*i
+		if entry+12 > len(tiff) {
+			break
+		}
+		// Tag 0x0112 is Orientation, a SHORT held in the entry itself
+		if order.Uint16(tiff[entry:]) == 0x0112 {
+			if v := int(order.Uint16(tiff[entry+8:])); v >= 1 && v <= 8 {
+				return v
+			}
+			break
+		}
+	}
+	return 1
+}
+
+// OrientImage returns img turned upright for its EXIF orientation.
+// Orientations 5 to 8 swap its width and height.
+func OrientImage(img image.Image, orientation int) image.Image {
+	if orientation <= 1 || orientation > 8 {
+		return img
+	}
+	b := img.Bounds()
+	w, h := b.Dx(), b.Dy()
+	dw, dh := w, h
+	if orientation >= 5 {
+		dw, dh = h, w
+	}
+	src := image.NewRGBA(image.Rect(0, 0, w, h))
+	draw.Draw(src, src.Bounds(), img, b.Min, draw.Src)
+	dst := image.NewRGBA(image.Rect(0, 0, dw, dh))
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			var dx, dy int
+			switch orientation {
+			case 2: // mirrored left to right
+				dx, dy = w-1-x, y
+			case 3: // turned half a turn
+				dx, dy = w-1-x, h-1-y
+			case 4: // mirrored top to bottom
+				dx, dy = x, h-1-y
+			case 5: // transposed
+				dx, dy = y, x
+			case 6: // turned clockwise
+				dx, dy = h-1-y, x
+			case 7: // transposed across the other diagonal
+				dx, dy = h-1-y, w-1-x
+			case 8: // turned counterclockwise
+				dx, dy = y, w-1-x
+			}
+			i, j := src.PixOffset(x, y), dst.PixOffset(dx, dy)
+			copy(dst.Pix[j:j+4], src.Pix[i:i+4])
+		}
+	}
+	return dst
+}
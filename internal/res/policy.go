@@ -0,0 +1,292 @@
+package res
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/netip"
+	"net/url"
+	"slices"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+)
+
+// ErrDenied is the error, possibly wrapped, of resources a Policy does not
+// allow to load
+var ErrDenied = errors.New("resource denied by policy")
+
+// defaultMaxRedirects is how many redirects are followed when a Policy does
+// not say, as many as net/http follows
+const defaultMaxRedirects = 10
+
+// Policy restricts the resources a loader loads, so that services
+// converting documents of their users cannot be made to reach into their
+// network or to download without bound. The zero Policy allows everything.
+type Policy struct {
+	// AllowedSchemes are the URL schemes that may be loaded, such as
+	// "https", "http", "file" for local files and "data"; all when empty
+	AllowedSchemes []string
+	// AllowedHosts are the hosts remote resources may be loaded from, all
+	// when empty, and DeniedHosts hosts they may not. "*.example.com"
+	// stands for the subdomains of example.com.
+	AllowedHosts []string
+	DeniedHosts  []string
+	// BlockPrivateNetworks refuses remote resources on loopback, private,
+	// link-local and other non-public addresses, checked on every
+	// connection so that host names resolving to them are refused too
+	BlockPrivateNetworks bool
+	// MaxSize limits the size of a resource in bytes; 0 for no limit
+	MaxSize int64
+	// MaxRedirects limits how many redirects a remote request follows; 0
+	// follows up to 10 and a negative value none
+	MaxRedirects int
+}
+
+// check returns an error wrapping ErrDenied when the policy does not allow
+// loading u
+func (p *Policy) check(u *url.URL) error {
+	if p == nil {
+		return nil
+	}
+	scheme := strings.ToLower(u.Scheme)
+	if len(p.AllowedSchemes) > 0 && !slices.ContainsFunc(p.AllowedSchemes, func(s string) bool {
+		return strings.EqualFold(s, scheme)
+	}) {
+		return fmt.Errorf("%w: scheme %q of %s is not allowed", ErrDenied, scheme, redact(u))
+	}
+	if scheme != "http" && scheme != "https" {
+		return nil
+	}
+	host := strings.ToLower(u.Hostname())
+	if len(p.AllowedHosts) > 0 && !matchesHost(p.AllowedHosts, host) {
+		return fmt.Errorf("%w: host %q is not allowed", ErrDenied, host)
+	}
+	if matchesHost(p.DeniedHosts, host) {
+		return fmt.Errorf("%w: host %q is denied", ErrDenied, host)
+	}
+	if p.BlockPrivateNetworks {
+		if addr, err := netip.ParseAddr(strings.Trim(host, "[]")); err == nil && !isPublic(addr) {
+			return fmt.Errorf("%w: address %s is not public", ErrDenied, addr)
+		}
+	}
+	return nil
+}
+
+// checkPath returns an error wrapping ErrDenied when the policy does not
+// allow loading the local file at path
+func (p *Policy) checkPath(path string) error {
+	return p.check(&url.URL{Scheme: "file", Path: path})
+}
+
+// checkSize returns an error wrapping ErrDenied when a resource of size
+// bytes is larger than the policy allows
+func (p *Policy) checkSize(size int64, name string) error {
+	if p == nil || p.MaxSize <= 0 || size <= p.MaxSize {
+		return nil
+	}
+	return fmt.Errorf("%w: %s is larger than %d bytes", ErrDenied, name, p.MaxSize)
+}
+
+// readAll reads r, failing once more than the policy's maximum size was
+// read
+func (p *Policy) readAll(r io.Reader, name string) ([]byte, error) {
+	if p == nil || p.MaxSize <= 0 {
+		return io.ReadAll(r)
+	}
+	data, err := io.ReadAll(io.LimitReader(r, p.MaxSize+1))
+	if err != nil {
+		return nil, err
+	}
+	if err := p.checkSize(int64(len(data)), name); err != nil {
+		return nil, err
+	}
+	return data, nil
+}
+
+// Client returns a client requesting as client does, which may be nil for
+// the default client, that follows only the redirects the policy allows
+// and, when it blocks private networks, connects to public addresses only.
+// Clients of the same http.Transport share its connections.
+func (p *Policy) Client(client *http.Client) *http.Client {
+	if client == nil {
+		client = &http.Client{}
+	}
+	if p == nil {
+		return client
+	}
+	c := *client
+	next := client.CheckRedirect
+	c.CheckRedirect = func(req *http.Request, via []*http.Request) error {
+		limit := p.MaxRedirects
+		if limit == 0 {
+			limit = defaultMaxRedirects
+		}
+		if len(via) > max(limit, 0) {
+			return fmt.Errorf("%w: more than %d redirects", ErrDenied, max(limit, 0))
+		}
+		if err := p.check(req.URL); err != nil {
+			return err
+		}
+		if next != nil {
+			return next(req, via)
+		}
+		return nil
+	}
+	if p.BlockPrivateNetworks {
+		c.Transport = publicTransport(client.Transport)
+	}
+	return &c
+}
+
+// publicTransport returns a transport requesting as rt does that connects
+// to public addresses only. The connections of an http.Transport are
+// checked as they are made, which also covers host names resolving to
+// private addresses. Requests through a proxy and those of other
+// transports, which dial on their own, are checked by looking up the
+// addresses of the host before the request instead.
+func publicTransport(rt http.RoundTripper) http.RoundTripper {
+	if rt == nil {
+		rt = http.DefaultTransport
+	}
+	t, ok := rt.(*http.Transport)
+	if !ok {
+		return lookupCheckingTransport{next: rt}
+	}
+	if public, ok := publicTransports.Load(t); ok {
+		return public.(http.RoundTripper)
+	}
+	public, _ := publicTransports.LoadOrStore(t, newPublicTransport(t))
+	return public.(http.RoundTripper)
+}
+
+// publicTransports are the transports publicTransport made of the
+// http.Transports it was given, so that clients made for every conversion
+// share the connections of one
+var publicTransports sync.Map
+
+// newPublicTransport makes the transport publicTransport returns for t
+func newPublicTransport(t *http.Transport) http.RoundTripper {
+	direct := t.Clone()
+	direct.Proxy = nil
+	if t.DialContext == nil {
+		dialer := &net.Dialer{Timeout: 30 * time.Second, KeepAlive: 30 * time.Second, Control: checkDialAddress}
+		direct.DialContext = dialer.DialContext
+	} else {
+		direct.DialContext = checkingDial(t.DialContext)
+	}
+	if t.DialTLSContext != nil {
+		direct.DialTLSContext = checkingDial(t.DialTLSContext)
+	}
+	if t.Proxy == nil {
+		return direct
+	}
+	return &proxyingTransport{proxy: t.Proxy, direct: direct, proxied: lookupCheckingTransport{next: t}}
+}
+
+// checkDialAddress refuses connections to addresses that are not public
+func checkDialAddress(network, address string, _ syscall.RawConn) error {
+	host, _, err := net.SplitHostPort(address)
+	if err != nil {
+		return err
+	}
+	addr, err := netip.ParseAddr(host)
+	if err != nil {
+		return err
+	}
+	if !isPublic(addr) {
+		return fmt.Errorf("%w: address %s is not public", ErrDenied, addr)
+	}
+	return nil
+}
+
+// checkingDial returns a dial function that closes the connections of dial
+// to addresses that are not public
+func checkingDial(dial func(ctx context.Context, network, addr string) (net.Conn, error)) func(ctx context.Context, network, addr string) (net.Conn, error) {
+	return func(ctx context.Context, network, addr string) (net.Conn, error) {
+		conn, err := dial(ctx, network, addr)
+		if err != nil {
+			return nil, err
+		}
+		if err := checkDialAddress(network, conn.RemoteAddr().String(), nil); err != nil {
+			conn.Close()
+			return nil, err
+		}
+		return conn, nil
+	}
+}
+
+// proxyingTransport makes the requests its proxy function gives a proxy
+// for with proxied and the others with direct
+type proxyingTransport struct {
+	proxy   func(*http.Request) (*url.URL, error)
+	direct  http.RoundTripper
+	proxied http.RoundTripper
+}
+
+func (t *proxyingTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if u, err := t.proxy(req); err == nil && u != nil {
+		return t.proxied.RoundTrip(req)
+	}
+	return t.direct.RoundTrip(req)
+}
+
+// lookupCheckingTransport refuses requests to hosts with addresses that are
+// not public
+type lookupCheckingTransport struct {
+	next http.RoundTripper
+}
+
+func (t lookupCheckingTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	addrs, err := net.DefaultResolver.LookupNetIP(req.Context(), "ip", req.URL.Hostname())
+	if err != nil {
+		return nil, err
+	}
+	for _, addr := range addrs {
+		if !isPublic(addr) {
+			return nil, fmt.Errorf("%w: address %s is not public", ErrDenied, addr.Unmap())
+		}
+	}
+	return t.next.RoundTrip(req)
+}
+
+// sharedAddressSpace is the carrier-grade NAT range (RFC 6598), which
+// netip does not count as private
+var sharedAddressSpace = netip.MustParsePrefix("100.64.0.0/10")
+
+// isPublic reports whether addr is a public unicast address
+func isPublic(addr netip.Addr) bool {
+	addr = addr.Unmap()
+	return addr.IsGlobalUnicast() && !addr.IsPrivate() && !sharedAddressSpace.Contains(addr)
+}
+
+// matchesHost reports whether host is one of hosts, where "*.example.com"
+// matches the subdomains of example.com
+func matchesHost(hosts []string, host string) bool {
+	for _, h := range hosts {
+		h = strings.ToLower(h)
+		if suffix, ok := strings.CutPrefix(h, "*"); ok && strings.HasPrefix(suffix, ".") {
+			if strings.HasSuffix(host, suffix) {
+				return true
+			}
+		} else if h == host {
+			return true
+		}
+	}
+	return false
+}
+
+// redact returns u without its user information and query, for errors that
+// may be shown to users
+func redact(u *url.URL) string {
+	if u.Scheme == "data" {
+		return "data URL"
+	}
+	r := *u
+	r.User, r.RawQuery = nil, ""
+	return r.String()
+}
@@ -0,0 +1,140 @@
+package res
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/netip"
+	"sync/atomic"
+	"testing"
+)
+
+func TestIsPublic(t *testing.T) {
+	tests := []struct {
+		addr string
+		want bool
+	}{
+		{"8.8.8.8", true},
+		{"2001:4860:4860::8888", true},
+		{"100.63.255.255", true},
+		{"100.128.0.0", true},
+		// IPv4-mapped IPv6 addresses are those they map
+		{"::ffff:8.8.8.8", true},
+		{"::ffff:127.0.0.1", false},
+		{"::ffff:10.0.0.1", false},
+		// Shared address space
+		{"100.64.0.1", false},
+		{"100.127.255.255", false},
+		// Link-local, as cloud metadata services are
+		{"169.254.169.254", false},
+		{"fe80::1", false},
+		{"127.0.0.1", false},
+		{"::1", false},
+		{"0.0.0.0", false},
+		{"::", false},
+		{"10.0.0.1", false},
+		{"172.16.0.1", false},
+		{"192.168.1.1", false},
+		{"fc00::1", false},
+		{"224.0.0.1", false},
+	}
+	for _, tt := range tests {
+		if got := isPublic(netip.MustParseAddr(tt.addr)); got != tt.want {
+			t.Errorf("isPublic(%s) = %v, want %v", tt.addr, got, tt.want)
+		}
+	}
+}
+
+func TestMatchesHost(t *testing.T) {
+	tests := []struct {
+		hosts []string
+		host  string
+		want  bool
+	}{
+		{[]string{"*.example.com"}, "a.example.com", true},
+		{[]string{"*.example.com"}, "a.b.example.com", true},
+		{[]string{"*.example.com"}, "example.com", false},
+		{[]string{"*.example.com"}, "evilexample.com", false},
+		{[]string{"*.example.com"}, "example.com.evil.net", false},
+		{[]string{"example.com"}, "example.com", true},
+		{[]string{"Example.COM"}, "example.com", true},
+		{[]string{"example.com"}, "a.example.com", false},
+		{[]string{"*example.com"}, "evilexample.com", false},
+		{nil, "example.com", false},
+	}
+	for _, tt := range tests {
+		if got := matchesHost(tt.hosts, tt.host); got != tt.want {
+			t.Errorf("matchesHost(%q, %q) = %v, want %v", tt.hosts, tt.host, got, tt.want)
+		}
+	}
+}
+
+// publicConn is a connection that reports a public remote address
+type publicConn struct {
+	net.Conn
+}
+
+func (publicConn) RemoteAddr() net.Addr {
+	return &net.TCPAddr{IP: net.ParseIP("203.0.113.10"), Port: 80}
+}
+
+func TestRedirectToPrivateAddressRefused(t *testing.T) {
+	var hits atomic.Int32
+	private := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		hits.Add(1)
+		w.Write([]byte("secret"))
+	}))
+	defer private.Close()
+	_, port, err := net.SplitHostPort(private.Listener.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	// The redirect names a host, which only the address it resolves to
+	// tells is private
+	public := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/redirect" {
+			http.Redirect(w, r, "http://localhost:"+port+"/secret", http.StatusFound)
+			return
+		}
+		w.Write([]byte("public"))
+	}))
+	defer public.Close()
+
+	// public.example stands for a public host, served by public
+	var dialer net.Dialer
+	transport := &http.Transport{
+		DialContext: func(ctx context.Context, network, addr string) (net.Conn, error) {
+			if host, _, _ := net.SplitHostPort(addr); host == "public.example" {
+				conn, err := dialer.DialContext(ctx, network, public.Listener.Addr().String())
+				if err != nil {
+					return nil, err
+				}
+				return publicConn{conn}, nil
+			}
+			return dialer.DialContext(ctx, network, addr)
+		},
+	}
+	defer transport.CloseIdleConnections()
+	policy := &Policy{BlockPrivateNetworks: true}
+	client := policy.Client(&http.Client{Transport: transport})
+
+	resp, err := client.Get("http://public.example/")
+	if err != nil {
+		t.Fatalf("public host: %v", err)
+	}
+	resp.Body.Close()
+
+	resp, err = client.Get("http://public.example/redirect")
+	if err == nil {
+		resp.Body.Close()
+		t.Fatal("redirect to a private address was followed")
+	}
+	if !errors.Is(err, ErrDenied) {
+		t.Errorf("got error %v, want one wrapping ErrDenied", err)
+	}
+	if hits.Load() > 0 {
+		t.Errorf("got %d requests to the private server, want none", hits.Load())
+	}
+}
@@ -0,0 +1,46 @@
+package res
+
+import (
+	"strings"
+	"sync"
+)
+
+// Prefetch loads resources into the loader's cache, at most parallelism of
+// them at a time, so that later loads of them return at once. Errors are
+// kept as well and returned by those loads. Data URLs, which need no
+// fetching, and resources already loaded are skipped. Prefetch returns when
+// every resource is loaded or has failed.
+func (l *Loader) Prefetch(urls []string, parallelism int) {
+	if parallelism < 1 {
+		parallelism = 1
+	}
+	slots := make(chan struct{}, parallelism)
+	var wg sync.WaitGroup
+	seen := make(map[string]bool)
+	for _, u := range urls {
+		if u == "" || seen[u] || strings.HasPrefix(u, "data:") || l.loaded(u) {
+			continue
+		}
+		seen[u] = true
+		if l.context().Err() != nil {
+			break
+		}
+		slots <- struct{}{}
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			defer func() { <-slots }()
+			l.Load(u)
+		}()
+	}
+	wg.Wait()
+}
+
+// loaded reports whether a load of urlStr was already made
+func (l *Loader) loaded(urlStr string) bool {
+	l.cacheLock.RLock()
+	defer l.cacheLock.RUnlock()
+	_, ok := l.cache[urlStr]
+	_, failed := l.failed[urlStr]
+	return ok || failed
+}
@@ -0,0 +1,155 @@
+package style
+
+import (
+	"strings"
+)
+
+// BorderSides lists the sides of a box in CSS order
+var BorderSides = [4]string{"top", "right", "bottom", "left"}
+
+// Border is the resolved border of one side of a box
+type Border struct {
+	// Width in points
+	Width float64
+	// Style is the border-style keyword, or "" when none was given
+	Style string
+	// Color is the CSS color value, or "" for currentColor
+	Color string
+}
+
+// Visible reports whether the border paints anything. A border with no style
+// is painted solid as long as a width or color was given for it.
+func (b Border) Visible() bool {
+	return b.Width > 0 && b.Style != "none" && b.Style != "hidden"
+}
+
+// Border resolves the border of one side ("top", "right", "bottom" or
+// "left") from its longhands; the border shorthands are expanded into those
+// before the cascade.
+func (cs ComputedStyle) Border(side string) Border {
+	prefix := "border-" + side
+	b := Border{
+		Style: strings.ToLower(cs.value(prefix + "-style")),
+		Color: cs.value(prefix + "-color"),
+	}
+	width := cs.value(prefix + "-width")
+	switch {
+	case b.Style == "none" || b.Style == "hidden":
+		return Border{Style: b.Style}
+	case width != "":
+		b.Width, _ = borderWidth(width)
+	case b.Style != "":
+		// The initial border-width is medium
+		b.Width = 3
+	case b.Color != "":
+		// A lone color keeps the 1pt hairline older documents rely on
+		b.Width = 1
+	}
+	if strings.EqualFold(b.Color, "currentcolor") {
+		b.Color = ""
+	}
+	return b
+}
+
+// value returns the trimmed value of a property, or ""
+func (cs ComputedStyle) value(name string) string {
+	return strings.TrimSpace(cs[name].Value)
+}
+
+// borderStyles are the keywords accepted by border-style
+var borderStyles = map[string]bool{
+	"none": true, "hidden": true, "dotted": true, "dashed": true, "solid": true,
+	"double": true, "groove": true, "ridge": true, "inset": true, "outset": true,
+}
+
+// splitBorder splits a border shorthand such as "1px solid #ccc" into its
+// width, style and color components, in any order
+func splitBorder(v string) (string, string, string) {
+	var width, style, color string
+	for _, part := range splitOutsideParens(v) {
+		lower := strings.ToLower(part)
+		switch {
+		case borderStyles[lower]:
+			style = lower
+		case lower == "thin" || lower == "medium" || lower == "thick":
+			width = lower
+		case lower != "" && (lower[0] >= '0' && lower[0] <= '9' || lower[0] == '.'):
+			width = lower
+		default:
+			color = part
+		}
+	}
+	return width, style, color
+}
+
+// boxValue picks the value for side idx from a one to four value list as
+// used by margin, padding, border-width, border-style and border-color
+func boxValue(v string, idx int) string {
+	parts := splitOutsideParens(v)
+	switch len(parts) {
+	case 0:
+		return ""
+	case 1:
+		return parts[0]
+	case 2:
+		return parts[idx%2]
+	case 3:
+		if idx == 3 {
+			return parts[1]
+		}
+		return parts[idx]
+	}
+	return parts[idx]
+}
+
+// splitOutsideParens splits v on whitespace that is not inside parentheses,
+// keeping values like rgb(0, 0, 0) together
+func splitOutsideParens(v string) []string {
+	var parts []string
+	depth, start := 0, -1
+	for i, ch := range v {
+		switch {
+		case ch == '(':
+			depth++
+		case ch == ')':
+			if depth > 0 {
+				depth--
+			}
+		case (ch == ' ' || ch == '\t' || ch == '\n') && depth == 0:
+			if start >= 0 {
+				parts = append(parts, v[start:i])
+				start = -1
+			}
+			continue
+		}
+		if start < 0 {
+			start = i
+		}
+	}
+	if start >= 0 {
+		parts = append(parts, v[start:])
+	}
+	return parts
+}
+
+// borderWidth parses a border width keyword or length
+func borderWidth(v string) (float64, bool) {
+	v = strings.ToLower(strings.TrimSpace(v))
+	switch v {
+	case "thin":
+		return 1, true
+	case "medium":
+		return 3, true
+	case "thick":
+		return 5, true
+	}
+	n, unit, ok := SplitUnit(v)
+	if !ok || n < 0 {
+		return 0, false
+	}
+	if unit == "em" || unit == "rem" {
+		return n * 16, true
+	}
+	f, ok := AbsoluteLength(v)
+	return f, ok
+}
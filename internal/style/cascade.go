@@ -1,8 +1,6 @@
 package style
 
 import (
-	"strings"
-
 	"github.com/gompdf/gompdf/internal/parser/css"
 	"github.com/gompdf/gompdf/internal/parser/html"
 	xhtml "golang.org/x/net/html"
@@ -21,6 +19,8 @@ type StyleProperty struct {
 	Value     string
 	Important bool
 	Source    Source
+	// Specificity is that of the selector of the winning declaration
+	Specificity Specificity
 }
 
 // Source represents the source of a style property
@@ -39,51 +39,113 @@ type ComputedStyle map[string]StyleProperty
 type StyleEngine struct {
 	userAgentStyles *css.Stylesheet
 	authorStyles    []*css.Stylesheet
+	// viewportWidth and viewportHeight are the size of the page area in
+	// points; see SetViewport
+	viewportWidth, viewportHeight float64
+	// pageWidth and pageHeight are the page size in points, which media
+	// queries match; see SetPageSize
+	pageWidth, pageHeight float64
+	// selectors caches parsed selectors by their source text
+	selectors map[string]*selector
+	// mediaType is matched by @media rules; see SetMediaType. media caches
+	// the result of each media query list.
+	mediaType string
+	media     map[string]bool
+	// counters and quoteDepth track generated content in document order
+	counters   counters
+	quoteDepth int
+	// firstLine holds the ::first-line styles; see FirstLineStyles
+	firstLine map[*html.Node]ComputedStyle
 }
 
 // NewStyleEngine creates a new style engine
 func NewStyleEngine() *StyleEngine {
 	return &StyleEngine{
-		userAgentStyles: defaultUserAgentStyles(),
+		userAgentStyles: expandStylesheet(defaultUserAgentStyles()),
 		authorStyles:    []*css.Stylesheet{},
+		selectors:       make(map[string]*selector),
+		mediaType:       DefaultMediaType,
 	}
 }
 
-// AddStylesheet adds an author stylesheet to the style engine
+// AddStylesheet adds an author stylesheet to the style engine. Its shorthand
+// properties are expanded into longhands; the stylesheet itself is not
+// modified.
 func (e *StyleEngine) AddStylesheet(stylesheet *css.Stylesheet) {
-	e.authorStyles = append(e.authorStyles, stylesheet)
+	e.authorStyles = append(e.authorStyles, expandStylesheet(stylesheet))
 }
 
-// ComputeStyles computes styles for all elements in the document
+// ComputeStyles computes styles for all elements in the document. Elements
+// with ::before or ::after content get generated child elements, which are
+// given styles of their own; see IsGenerated.
 func (e *StyleEngine) ComputeStyles(doc *html.Document) map[*html.Node]ComputedStyle {
 	result := make(map[*html.Node]ComputedStyle)
-	e.computeStylesRecursive(doc.Root, result)
+	e.counters = make(counters)
+	e.quoteDepth = 0
+	e.firstLine = nil
+	if e.usesFirstLine() {
+		e.firstLine = make(map[*html.Node]ComputedStyle)
+	}
+	e.computeStylesRecursive(doc.Root, nil, result, fontContext{parent: DefaultFontSize, weight: DefaultFontWeight})
 	return result
 }
 
-// computeStylesRecursive computes styles for an element and its children
-func (e *StyleEngine) computeStylesRecursive(node *html.Node, result map[*html.Node]ComputedStyle) {
+// computeStylesRecursive computes styles for an element and its children.
+// parent is the computed style of the parent element, nil for the root; fc
+// carries the font sizes of the parent and root elements. It returns the
+// counters the element instantiated, which stay in scope for its following
+// siblings and are dropped by the parent.
+func (e *StyleEngine) computeStylesRecursive(node *html.Node, parent ComputedStyle, result map[*html.Node]ComputedStyle, fc fontContext) []string {
 	if node == nil {
-		return
+		return nil
 	}
 
+	var created, scoped []string
 	if node.Type == xhtml.ElementNode {
-		result[node] = e.computeStyleForElement(node)
+		style := e.computeStyleForElement(node)
+		inherit(style, parent)
+		if fc.root == 0 {
+			// The root element's font size resolves against the initial one
+			fc.root = DefaultFontSize
+			fc.parent = e.computeValues(style, fc)
+			fc.root = fc.parent
+		} else {
+			fc.parent = e.computeValues(style, fc)
+		}
+		result[node] = style
+		parent = style
+		fc.weight = style.FontWeight()
+		e.computeFirstLine(node, fc)
+
+		removeGenerated(node)
+		created = e.counters.apply(style)
+		scoped = e.generateContent(node, "before", fc, result)
 	}
 
 	for child := node.FirstChild; child != nil; child = child.NextSibling {
-		e.computeStylesRecursive(child, result)
+		if IsGenerated(child) {
+			continue
+		}
+		scoped = append(scoped, e.computeStylesRecursive(child, parent, result, fc)...)
+	}
+
+	if node.Type == xhtml.ElementNode {
+		scoped = append(scoped, e.generateContent(node, "after", fc, result)...)
 	}
+	for _, name := range scoped {
+		e.counters.drop(name)
+	}
+	return created
 }
 
 // computeStyleForElement computes the style for a single element
 func (e *StyleEngine) computeStyleForElement(node *html.Node) ComputedStyle {
 	style := make(ComputedStyle)
 
-	e.applyStylesheet(style, node, e.userAgentStyles, SourceUserAgent)
+	e.applyStylesheet(style, node, "", e.userAgentStyles, SourceUserAgent)
 
 	for _, stylesheet := range e.authorStyles {
-		e.applyStylesheet(style, node, stylesheet, SourceAuthor)
+		e.applyStylesheet(style, node, "", stylesheet, SourceAuthor)
 	}
 
 	e.applyInlineStyles(style, node)
@@ -91,15 +153,28 @@ func (e *StyleEngine) computeStyleForElement(node *html.Node) ComputedStyle {
 	return style
 }
 
-// applyStylesheet applies styles from a stylesheet to an element
-func (e *StyleEngine) applyStylesheet(style ComputedStyle, node *html.Node, stylesheet *css.Stylesheet, source Source) {
+// applyStylesheet applies styles from a stylesheet to an element, or to its
+// ::before, ::after or ::first-line pseudo-element named by pseudo.
+// A rule whose selector list matches more than once applies with the
+// specificity of its most specific matching selector.
+func (e *StyleEngine) applyStylesheet(style ComputedStyle, node *html.Node, pseudo string, stylesheet *css.Stylesheet, source Source) {
 	for _, rule := range stylesheet.Rules {
+		if len(rule.Media) > 0 && !e.mediaMatches(rule.Media) {
+			continue
+		}
+		matched := false
+		var specificity Specificity
 		for _, selector := range rule.Selectors {
-			if e.selectorMatches(node, selector) {
-				specificity := calculateSpecificity(selector)
-				e.applyDeclarations(style, rule.Declarations, specificity, source)
+			if e.selectorMatches(node, pseudo, selector) {
+				if s := e.calculateSpecificity(selector); !matched || compareSpecificity(s, specificity) > 0 {
+					specificity = s
+				}
+				matched = true
 			}
 		}
+		if matched {
+			e.applyDeclarations(style, rule.Declarations, specificity, source)
+		}
 	}
 }
 
@@ -113,184 +188,86 @@ func (e *StyleEngine) applyInlineStyles(style ComputedStyle, node *html.Node) {
 				continue
 			}
 
-			specificity := Specificity{1, 0, 0}
-			e.applyDeclarations(style, inlineStyles.Rules[0].Declarations, specificity, SourceInline)
+			// The style attribute outranks every selector through its
+			// source; see cascadeRank
+			e.applyDeclarations(style, expandDeclarations(inlineStyles.Rules[0].Declarations), Specificity{}, SourceInline)
 		}
 	}
 }
 
-// applyDeclarations applies CSS declarations to a style
+// applyDeclarations applies CSS declarations to a style. Declarations must be
+// applied in source order: one that ties with the current value of a property
+// on cascade rank and specificity comes later and wins.
 func (e *StyleEngine) applyDeclarations(style ComputedStyle, declarations []*css.Declaration, specificity Specificity, source Source) {
 	for _, decl := range declarations {
 		property := decl.Property
-		existing, exists := style[property]
-
-		// Apply the new declaration if:
-		// 1. The property doesn't exist yet, or
-		// 2. The new declaration is !important and the existing one is not, or
-		// 3. Both have the same importance but the new one has higher specificity, or
-		// 4. Both have the same importance and specificity but the new one comes from a higher priority source
-		if !exists ||
-			(decl.Important && !existing.Important) ||
-			(decl.Important == existing.Important && compareSpecificity(specificity, Specificity{}) > 0) ||
-			(decl.Important == existing.Important && compareSpecificity(specificity, Specificity{}) == 0 && source > existing.Source) {
-
-			style[property] = StyleProperty{
-				Name:      property,
-				Value:     decl.Value,
-				Important: decl.Important,
-				Source:    source,
+		if existing, exists := style[property]; exists {
+			rank, existingRank := cascadeRank(source, decl.Important), cascadeRank(existing.Source, existing.Important)
+			if rank < existingRank || rank == existingRank && compareSpecificity(specificity, existing.Specificity) < 0 {
+				continue
 			}
 		}
+		style[property] = StyleProperty{
+			Name:        property,
+			Value:       decl.Value,
+			Important:   decl.Important,
+			Source:      source,
+			Specificity: specificity,
+		}
 	}
 }
 
-// selectorMatches checks if an element matches a CSS selector
-func (e *StyleEngine) selectorMatches(node *html.Node, selector string) bool {
-	parts := strings.Fields(selector)
-	if len(parts) == 0 || node == nil {
-		return false
+// cascadeRank orders declarations by origin and importance, before
+// specificity is considered: user agent, author and style attribute
+// declarations in that order, then the !important ones in reverse origin
+// order. The style attribute ranks above the author stylesheets as it is more
+// specific than any selector.
+func cascadeRank(source Source, important bool) int {
+	if !important {
+		return int(source)
 	}
-	if !matchCompoundSelector(node, parts[len(parts)-1]) {
-		return false
-	}
-
-	current := node.Parent
-	for i := len(parts) - 2; i >= 0; i-- {
-		found := false
-		for anc := current; anc != nil; anc = anc.Parent {
-			if anc.Type == xhtml.ElementNode && matchCompoundSelector(anc, parts[i]) {
-				found = true
-				current = anc.Parent
-				break
-			}
-		}
-		if !found {
-			return false
-		}
+	switch source {
+	case SourceUserAgent:
+		return 5
+	case SourceAuthor:
+		return 3
 	}
-
-	return true
+	return 4
 }
 
-// matchCompoundSelector matches a single compound selector against a node.
-// Compound selectors can be forms like:
-//   - tag
-//   - .class
-//   - #id
-//   - tag.class
-//   - tag#id.class1.class2
-//   - .class1.class2
-//
-// It does not support attributes, pseudo-classes, or combinators.
-func matchCompoundSelector(node *html.Node, sel string) bool {
-	if node == nil || node.Type != xhtml.ElementNode || sel == "" {
-		return false
-	}
-
-	var wantTag string
-	var wantID string
-	var wantClasses []string
-
-	// Parse the compound selector
-	// Scan sel once, extracting optional tag, optional id, and any number of classes
-	i := 0
-	// Extract tag if first character is a letter or '*'
-	if i < len(sel) && sel[i] != '.' && sel[i] != '#' {
-		// read until '#' or '.'
-		j := i
-		for j < len(sel) && sel[j] != '#' && sel[j] != '.' {
-			j++
-		}
-		wantTag = sel[i:j]
-		i = j
-	}
-	// Extract sequences of (#id | .class)
-	for i < len(sel) {
-		if sel[i] == '#' {
-			// id
-			j := i + 1
-			for j < len(sel) && sel[j] != '.' && sel[j] != '#' {
-				j++
-			}
-			wantID = sel[i+1 : j]
-			i = j
-			continue
-		}
-		if sel[i] == '.' {
-			j := i + 1
-			for j < len(sel) && sel[j] != '.' && sel[j] != '#' {
-				j++
-			}
-			wantClasses = append(wantClasses, sel[i+1:j])
-			i = j
-			continue
-		}
-		// Unexpected character; fail safe
+// selectorMatches checks if an element, or the given pseudo-element of it,
+// matches a CSS selector
+func (e *StyleEngine) selectorMatches(node *html.Node, pseudo string, selector string) bool {
+	sel := e.parsedSelector(selector)
+	if sel == nil || node == nil {
 		return false
 	}
-
-	if wantTag != "" && wantTag != node.Data && wantTag != "*" {
-		return false
+	if pseudo != "" {
+		return sel.matchesPseudo(node, pseudo)
 	}
+	return sel.matches(node)
+}
 
-	if wantID != "" {
-		matched := false
-		for _, attr := range node.Attr {
-			if attr.Key == "id" && attr.Val == wantID {
-				matched = true
-				break
-			}
-		}
-		if !matched {
-			return false
-		}
+// parsedSelector returns the parsed form of a selector, or nil when it is
+// malformed. Selectors are parsed once per engine.
+func (e *StyleEngine) parsedSelector(selector string) *selector {
+	if sel, ok := e.selectors[selector]; ok {
+		return sel
 	}
-
-	if len(wantClasses) > 0 {
-		var classAttr string
-		for _, attr := range node.Attr {
-			if attr.Key == "class" {
-				classAttr = attr.Val
-				break
-			}
-		}
-		if classAttr == "" {
-			return false
-		}
-		have := strings.Fields(classAttr)
-		set := make(map[string]struct{}, len(have))
-		for _, c := range have {
-			set[c] = struct{}{}
-		}
-		for _, need := range wantClasses {
-			if _, ok := set[need]; !ok {
-				return false
-			}
-		}
+	sel, ok := parseSelector(selector)
+	if !ok {
+		sel = nil
 	}
-
-	return true
+	e.selectors[selector] = sel
+	return sel
 }
 
 // calculateSpecificity calculates the specificity of a CSS selector
-func calculateSpecificity(selector string) Specificity {
-	specificity := Specificity{}
-
-	specificity.ID = strings.Count(selector, "#")
-
-	specificity.Class = strings.Count(selector, ".") +
-		strings.Count(selector, "[") +
-		strings.Count(selector, ":")
-	specificity.Element = strings.Count(selector, "::") +
-		len(strings.Fields(strings.NewReplacer(
-			"#", " ",
-			".", " ",
-			"[", " ",
-			":", " ",
-		).Replace(selector)))
-
-	return specificity
+func (e *StyleEngine) calculateSpecificity(selector string) Specificity {
+	if sel := e.parsedSelector(selector); sel != nil {
+		return sel.specificity()
+	}
+	return Specificity{}
 }
 
 // compareSpecificity compares two specificities
@@ -320,9 +297,12 @@ func defaultUserAgentStyles() *css.Stylesheet {
 		a:visited { color: #551A8B; }
 		b, strong { font-weight: bold; }
 		i, em { font-style: italic; }
-		pre { white-space: pre; }
+		[dir=rtl i] { direction: rtl; }
+		[dir=ltr i] { direction: ltr; }
+		pre { display: block; white-space: pre; font-family: monospace; margin: 1em 0; }
 		table { border-collapse: separate; border-spacing: 2px; }
 		th, td { border: 1px solid #ddd; padding: 4px; }
+		th, td { vertical-align: inherit; }
 		th { background-color: #f2f2f2; }
 	`)
 	return stylesheet
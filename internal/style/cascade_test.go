@@ -0,0 +1,117 @@
+package style
+
+import (
+	"testing"
+
+	"github.com/gompdf/gompdf/internal/parser/css"
+	"github.com/gompdf/gompdf/internal/parser/html"
+)
+
+// styleOf computes the styles of a document with the given user agent and
+// author stylesheets and returns the value of a property of the element
+// with the given id
+func styleOf(t *testing.T, ua, author, body, id, property string) string {
+	t.Helper()
+	doc, err := html.NewParser().ParseString("<html><body>" + body + "</body></html>")
+	if err != nil {
+		t.Fatal(err)
+	}
+	e := NewStyleEngine()
+	if ua != "" {
+		sheet, err := css.NewParser().ParseString(ua)
+		if err != nil {
+			t.Fatal(err)
+		}
+		e.userAgentStyles = expandStylesheet(sheet)
+	}
+	if author != "" {
+		sheet, err := css.NewParser().ParseString(author)
+		if err != nil {
+			t.Fatal(err)
+		}
+		e.AddStylesheet(sheet)
+	}
+	for node, style := range e.ComputeStyles(doc) {
+		for _, a := range node.Attr {
+			if a.Key == "id" && a.Val == id {
+				return style[property].Value
+			}
+		}
+	}
+	t.Fatalf("no element with id %q", id)
+	return ""
+}
+
+func TestCascade(t *testing.T) {
+	tests := []struct {
+		name, ua, author, body, want string
+	}{
+		{
+			name:   "inline over author",
+			author: `#x { text-align: right }`,
+			body:   `<p id="x" style="text-align: center">text</p>`,
+			want:   "center",
+		},
+		{
+			name:   "important author over inline",
+			author: `p { text-align: right !important }`,
+			body:   `<p id="x" style="text-align: center">text</p>`,
+			want:   "right",
+		},
+		{
+			name:   "important inline over important author",
+			author: `#x { text-align: right !important }`,
+			body:   `<p id="x" style="text-align: center !important">text</p>`,
+			want:   "center",
+		},
+		{
+			name:   "author over user agent",
+			ua:     `#x { text-align: right }`,
+			author: `p { text-align: center }`,
+			body:   `<p id="x">text</p>`,
+			want:   "center",
+		},
+		{
+			name:   "important user agent over important author",
+			ua:     `p { text-align: right !important }`,
+			author: `#x { text-align: center !important }`,
+			body:   `<p id="x" style="text-align: left !important">text</p>`,
+			want:   "right",
+		},
+		{
+			name:   "equal specificity decided by source order",
+			author: `.a { text-align: right } .b { text-align: center }`,
+			body:   `<p id="x" class="b a">text</p>`,
+			want:   "center",
+		},
+		{
+			name:   "higher specificity over later rule",
+			author: `p.a { text-align: right } .a { text-align: center }`,
+			body:   `<p id="x" class="a">text</p>`,
+			want:   "right",
+		},
+		{
+			name:   "specificity of the most specific matching selector of a list",
+			author: `#x, p { text-align: right } p.a { text-align: center }`,
+			body:   `<p id="x" class="a">text</p>`,
+			want:   "right",
+		},
+		{
+			name:   "specificity tie across selectors decided by source order",
+			author: `div p { text-align: right } span, body p { text-align: center }`,
+			body:   `<div><p id="x">text</p></div>`,
+			want:   "center",
+		},
+		{
+			name:   "specificity tie across selectors in reverse order",
+			author: `span, body p { text-align: center } div p { text-align: right }`,
+			body:   `<div><p id="x">text</p></div>`,
+			want:   "right",
+		},
+	}
+	for _, tt := range tests {
+		if got := styleOf(t, tt.ua, tt.author, tt.body, "x", "text-align"); got != tt.want {
+			t.Errorf("%s: text-align is %q, want %q", tt.name, got, tt.want)
+		}
+	}
+}
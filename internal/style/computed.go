@@ -0,0 +1,136 @@
+package style
+
+import (
+	"strconv"
+	"strings"
+)
+
+// DefaultFontSize is the initial font-size in points
+const DefaultFontSize = 16
+
+// fontSizeKeywords maps the absolute font-size keywords to points
+var fontSizeKeywords = map[string]float64{
+	"xx-small":  9,
+	"x-small":   10,
+	"small":     13,
+	"medium":    16,
+	"large":     18,
+	"x-large":   24,
+	"xx-large":  32,
+	"xxx-large": 48,
+}
+
+// fontContext carries the font sizes relative lengths resolve against while
+// styles are computed down the tree
+type fontContext struct {
+	// parent is the computed font-size of the parent element
+	parent float64
+	// root is the computed font-size of the root element, used by rem
+	root float64
+	// weight is the computed font-weight of the parent element, which
+	// bolder and lighter step from
+	weight int
+}
+
+// computeValues is the computed-value stage: it resolves the font-size and
+// font-weight of an element against its parent and then rewrites the font
+// relative (em, rem, ex, ch) and viewport relative lengths of every property
+// as points. It returns the element's computed font-size.
+func (e *StyleEngine) computeValues(style ComputedStyle, fc fontContext) float64 {
+	vw, vh := e.viewportWidth/100, e.viewportHeight/100
+	viewport := func(unit string) float64 {
+		if vw <= 0 || vh <= 0 {
+			return 0
+		}
+		switch unit {
+		case "vw":
+			return vw
+		case "vh":
+			return vh
+		case "vmin":
+			return min(vw, vh)
+		case "vmax":
+			return max(vw, vh)
+		}
+		return 0
+	}
+	relativeTo := func(em float64) func(string) float64 {
+		return func(unit string) float64 {
+			switch unit {
+			case "em":
+				return em
+			case "rem":
+				return fc.root
+			case "ex", "ch":
+				// Without font metrics both are taken as half an em
+				return em / 2
+			}
+			return viewport(unit)
+		}
+	}
+
+	size := fc.parent
+	if prop, ok := style["font-size"]; ok {
+		if fs, ok := computeFontSize(prop.Value, fc, relativeTo(fc.parent)); ok {
+			size = fs
+			prop.Value = formatPoints(fs)
+		} else {
+			prop.Value = absolutize(prop.Value, relativeTo(fc.parent))
+		}
+		style["font-size"] = prop
+	}
+	if prop, ok := style["font-weight"]; ok {
+		prop.Value = strconv.Itoa(computeFontWeight(prop.Value, fc.weight))
+		style["font-weight"] = prop
+	}
+
+	own := relativeTo(size)
+	for name, prop := range style {
+		if name == "font-size" || !hasDimension(prop.Value) {
+			continue
+		}
+		prop.Value = absolutize(prop.Value, own)
+		style[name] = prop
+	}
+	return size
+}
+
+// computeFontSize resolves a font-size value to points. Percentages and
+// em-based units are relative to the parent's font size. It reports false for
+// values it cannot resolve, such as calc().
+func computeFontSize(v string, fc fontContext, scale func(string) float64) (float64, bool) {
+	v = strings.ToLower(strings.TrimSpace(v))
+	if size, ok := fontSizeKeywords[v]; ok {
+		return size, true
+	}
+	switch v {
+	case "smaller":
+		return fc.parent / 1.2, true
+	case "larger":
+		return fc.parent * 1.2, true
+	case "inherit":
+		return fc.parent, true
+	}
+	n, unit, ok := SplitUnit(v)
+	if !ok {
+		return 0, false
+	}
+	if unit == "%" {
+		return fc.parent * n / 100, true
+	}
+	if f := scale(unit); f != 0 {
+		return n * f, true
+	}
+	return AbsoluteLength(v)
+}
+
+// hasDimension reports whether a value may contain a relative length, so
+// that values such as colors and keywords are passed over quickly
+func hasDimension(v string) bool {
+	for i := 0; i+1 < len(v); i++ {
+		if c := v[i]; c >= '0' && c <= '9' && isUnitLetter(v[i+1]) {
+			return true
+		}
+	}
+	return false
+}
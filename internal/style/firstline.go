@@ -0,0 +1,48 @@
+package style
+
+import (
+	"strings"
+
+	"github.com/gompdf/gompdf/internal/parser/html"
+)
+
+// usesFirstLine reports whether any author stylesheet has a ::first-line
+// rule, so that documents without one skip matching it for every element
+func (e *StyleEngine) usesFirstLine() bool {
+	for _, sheet := range e.authorStyles {
+		for _, rule := range sheet.Rules {
+			for _, sel := range rule.Selectors {
+				if strings.Contains(strings.ToLower(sel), "first-line") {
+					return true
+				}
+			}
+		}
+	}
+	return false
+}
+
+// computeFirstLine records the ::first-line style of an element, if any
+// rule declares one. fc carries the element's own font size, which em
+// lengths in the pseudo-element resolve against.
+func (e *StyleEngine) computeFirstLine(node *html.Node, fc fontContext) {
+	if e.firstLine == nil {
+		return
+	}
+	style := make(ComputedStyle)
+	for _, stylesheet := range e.authorStyles {
+		e.applyStylesheet(style, node, "first-line", stylesheet, SourceAuthor)
+	}
+	if len(style) == 0 {
+		return
+	}
+	e.computeValues(style, fc)
+	e.firstLine[node] = style
+}
+
+// FirstLineStyles returns the ::first-line styles of the elements of the
+// document last passed to ComputeStyles. They hold only the properties
+// declared by ::first-line rules; layout applies them to the first line of
+// the element's text.
+func (e *StyleEngine) FirstLineStyles() map[*html.Node]ComputedStyle {
+	return e.firstLine
+}
@@ -0,0 +1,48 @@
+package style
+
+import (
+	"strconv"
+	"strings"
+)
+
+// DefaultFontWeight is the initial, normal font-weight
+const DefaultFontWeight = 400
+
+// FontWeight returns the computed font-weight as a number between 1 and 1000
+func (cs ComputedStyle) FontWeight() int {
+	return computeFontWeight(cs.value("font-weight"), DefaultFontWeight)
+}
+
+// computeFontWeight resolves a font-weight value to a number. bolder and
+// lighter step from the parent weight as CSS Fonts tabulates.
+func computeFontWeight(v string, parent int) int {
+	switch strings.ToLower(strings.TrimSpace(v)) {
+	case "", "normal":
+		return 400
+	case "bold":
+		return 700
+	case "bolder":
+		switch {
+		case parent < 350:
+			return 400
+		case parent < 550:
+			return 700
+		}
+		return max(parent, 900)
+	case "lighter":
+		switch {
+		case parent < 100:
+			return parent
+		case parent < 550:
+			return 100
+		case parent < 750:
+			return 400
+		}
+		return 700
+	}
+	n, err := strconv.ParseFloat(strings.TrimSpace(v), 64)
+	if err != nil || n < 1 || n > 1000 {
+		return parent
+	}
+	return int(n)
+}
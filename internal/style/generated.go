@@ -0,0 +1,476 @@
+package style
+
+import (
+	"strconv"
+	"strings"
+
+	"github.com/gompdf/gompdf/internal/parser/html"
+	xhtml "golang.org/x/net/html"
+)
+
+// Generated content is materialized in the document: an element with a
+// ::before or ::after style and a content value gets a child element named
+// "::before" (first) or "::after" (last) holding the generated text, so
+// layout treats it like any other inline element.
+
+// IsGenerated reports whether a node was inserted for a ::before or ::after
+// pseudo-element
+func IsGenerated(node *html.Node) bool {
+	return node != nil && node.Type == xhtml.ElementNode && strings.HasPrefix(node.Data, "::")
+}
+
+// counters holds the CSS counters in scope while styles are computed in
+// document order. Each name maps to a stack of nested counter instances.
+type counters map[string][]int
+
+// reset instantiates a counter; the caller drops it again when its scope ends
+func (c counters) reset(name string, v int) {
+	c[name] = append(c[name], v)
+}
+
+// drop removes the innermost instance of a counter
+func (c counters) drop(name string) {
+	if s := c[name]; len(s) > 0 {
+		c[name] = s[:len(s)-1]
+	}
+}
+
+// apply processes the counter-reset, counter-set and counter-increment
+// properties of a style, in that order. It returns the names of the counters
+// it instantiated, whose scope the caller has to close.
+func (c counters) apply(style ComputedStyle) []string {
+	var created []string
+	for _, p := range counterList(style["counter-reset"].Value, 0) {
+		c.reset(p.name, p.value)
+		created = append(created, p.name)
+	}
+	for _, p := range counterList(style["counter-set"].Value, 0) {
+		if len(c[p.name]) == 0 {
+			c.reset(p.name, 0)
+			created = append(created, p.name)
+		}
+		c[p.name][len(c[p.name])-1] = p.value
+	}
+	for _, p := range counterList(style["counter-increment"].Value, 1) {
+		if len(c[p.name]) == 0 {
+			c.reset(p.name, 0)
+			created = append(created, p.name)
+		}
+		c[p.name][len(c[p.name])-1] += p.value
+	}
+	return created
+}
+
+// counterPair is one entry of a counter-reset, -set or -increment list
+type counterPair struct {
+	name  string
+	value int
+}
+
+// counterList parses a list like "section 2 figure" where each name may be
+// followed by an integer; def is used for names without one
+func counterList(v string, def int) []counterPair {
+	var pairs []counterPair
+	for _, f := range strings.Fields(v) {
+		if n, err := strconv.Atoi(f); err == nil {
+			if len(pairs) > 0 {
+				pairs[len(pairs)-1].value = n
+			}
+			continue
+		}
+		if strings.EqualFold(f, "none") {
+			continue
+		}
+		pairs = append(pairs, counterPair{name: f, value: def})
+	}
+	return pairs
+}
+
+// generateContent computes the style of the ::before or ::after
+// pseudo-element of node and, when it has content, inserts the element that
+// carries it. It returns the counters the pseudo-element instantiated.
+func (e *StyleEngine) generateContent(node *html.Node, pseudo string, fc fontContext, result map[*html.Node]ComputedStyle) []string {
+	style := make(ComputedStyle)
+	e.applyStylesheet(style, node, pseudo, e.userAgentStyles, SourceUserAgent)
+	for _, stylesheet := range e.authorStyles {
+		e.applyStylesheet(style, node, pseudo, stylesheet, SourceAuthor)
+	}
+	content := strings.TrimSpace(style["content"].Value)
+	if content == "" || content == "none" || content == "normal" {
+		return nil
+	}
+	if strings.EqualFold(strings.TrimSpace(style["display"].Value), "none") {
+		return nil
+	}
+	inherit(style, result[node])
+	e.computeValues(style, fc)
+
+	created := e.counters.apply(style)
+	text := e.contentText(node, content)
+
+	gen := &html.Node{Type: xhtml.ElementNode, Data: "::" + pseudo, Parent: node}
+	if text != "" {
+		gen.FirstChild = &html.Node{Type: xhtml.TextNode, Data: text, Parent: gen}
+		gen.LastChild = gen.FirstChild
+	}
+	if pseudo == "before" {
+		gen.NextSibling = node.FirstChild
+		if node.FirstChild != nil {
+			node.FirstChild.PrevSibling = gen
+		} else {
+			node.LastChild = gen
+		}
+		node.FirstChild = gen
+	} else {
+		gen.PrevSibling = node.LastChild
+		if node.LastChild != nil {
+			node.LastChild.NextSibling = gen
+		} else {
+			node.FirstChild = gen
+		}
+		node.LastChild = gen
+	}
+	result[gen] = style
+	return created
+}
+
+// removeGenerated unlinks the generated children of node, so that styles
+// can be computed again for the same document
+func removeGenerated(node *html.Node) {
+	for c := node.FirstChild; c != nil; {
+		next := c.NextSibling
+		if IsGenerated(c) {
+			if c.PrevSibling != nil {
+				c.PrevSibling.NextSibling = c.NextSibling
+			} else {
+				node.FirstChild = c.NextSibling
+			}
+			if c.NextSibling != nil {
+				c.NextSibling.PrevSibling = c.PrevSibling
+			} else {
+				node.LastChild = c.PrevSibling
+			}
+		}
+		c = next
+	}
+}
+
+// contentText evaluates a content value: strings, attr(), counter(),
+// counters(), target-counter(), leader() and the quote keywords
+func (e *StyleEngine) contentText(node *html.Node, content string) string {
+	var b strings.Builder
+	for i := 0; i < len(content); {
+		c := content[i]
+		switch {
+		case isSpace(c):
+			i++
+		case c == '"' || c == '\'':
+			s, n := cssString(content[i:])
+			b.WriteString(s)
+			i += n
+		default:
+			j := i
+			for j < len(content) && !isSpace(content[j]) && content[j] != '(' && content[j] != '"' && content[j] != '\'' {
+				j++
+			}
+			name := strings.ToLower(content[i:j])
+			var args []string
+			if j < len(content) && content[j] == '(' {
+				// an unclosed function takes the rest of the value as its
+				// arguments
+				if end := closingParen(content[j:]); end < 0 {
+					args = contentArgs(content[j+1:])
+					j = len(content)
+				} else {
+					args = contentArgs(content[j+1 : j+end])
+					j += end + 1
+				}
+			}
+			b.WriteString(e.contentFunction(node, name, args))
+			i = j
+		}
+	}
+	return b.String()
+}
+
+// contentFunction evaluates one keyword or function of a content value
+func (e *StyleEngine) contentFunction(node *html.Node, name string, args []string) string {
+	switch name {
+	case "attr":
+		if len(args) > 0 {
+			v, _ := attrValue(node, args[0])
+			return v
+		}
+	case "counter":
+		if len(args) > 0 {
+			stack := e.counters[args[0]]
+			if len(stack) == 0 && isPageCounter(args[0]) {
+				listStyle := ""
+				if len(args) > 1 {
+					listStyle = args[1]
+				}
+				return pageCounterPlaceholder(args[0], listStyle)
+			}
+			listStyle := "decimal"
+			if len(args) > 1 {
+				listStyle = args[1]
+			}
+			v := 0
+			if len(stack) > 0 {
+				v = stack[len(stack)-1]
+			}
+			return FormatCounter(v, listStyle)
+		}
+	case "counters":
+		if len(args) > 1 {
+			listStyle := "decimal"
+			if len(args) > 2 {
+				listStyle = args[2]
+			}
+			stack := e.counters[args[0]]
+			if len(stack) == 0 {
+				stack = []int{0}
+			}
+			parts := make([]string, len(stack))
+			for i, v := range stack {
+				parts[i] = FormatCounter(v, listStyle)
+			}
+			return strings.Join(parts, args[1])
+		}
+	case "target-counter":
+		// Only the page counter of targets in the document is known
+		if len(args) > 1 && strings.EqualFold(args[1], "page") {
+			target := args[0]
+			if inner, ok := functionArg(target, "attr"); ok {
+				target, _ = attrValue(node, inner)
+				// An attribute such as data-ref may name the id alone
+				if target != "" && !strings.ContainsAny(target, "#:/") {
+					target = "#" + target
+				}
+			} else if inner, ok := functionArg(target, "url"); ok {
+				target = strings.Trim(inner, "'\"")
+			}
+			if !strings.HasPrefix(target, "#") || len(target) < 2 {
+				return ""
+			}
+			listStyle := ""
+			if len(args) > 2 {
+				listStyle = args[2]
+			}
+			return pageCounterPlaceholder(target, listStyle)
+		}
+	case "leader":
+		pattern := ". "
+		if len(args) > 0 {
+			switch strings.ToLower(args[0]) {
+			case "dotted":
+			case "solid":
+				pattern = "_"
+			case "space":
+				pattern = " "
+			default:
+				pattern = args[0]
+			}
+		}
+		return leaderMarker(pattern)
+	case "open-quote":
+		q := quotes[min(e.quoteDepth, len(quotes)-1)]
+		e.quoteDepth++
+		return q[0]
+	case "close-quote":
+		if e.quoteDepth > 0 {
+			e.quoteDepth--
+		}
+		return quotes[min(e.quoteDepth, len(quotes)-1)][1]
+	case "no-open-quote":
+		e.quoteDepth++
+	case "no-close-quote":
+		if e.quoteDepth > 0 {
+			e.quoteDepth--
+		}
+	}
+	return ""
+}
+
+// quotes are the quotation marks used by open-quote and close-quote, by
+// nesting level
+var quotes = [][2]string{{"“", "”"}, {"‘", "’"}}
+
+// closingParen returns the index of the parenthesis closing the one s
+// starts with, skipping nested functions and strings, or -1
+func closingParen(s string) int {
+	depth := 0
+	var quote byte
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		switch {
+		case quote != 0:
+			if c == '\\' {
+				i++
+			} else if c == quote {
+				quote = 0
+			}
+		case c == '"' || c == '\'':
+			quote = c
+		case c == '(':
+			depth++
+		case c == ')':
+			depth--
+			if depth == 0 {
+				return i
+			}
+		}
+	}
+	return -1
+}
+
+// functionArg returns the argument of a function call such as attr(href)
+func functionArg(s, name string) (string, bool) {
+	if len(s) <= len(name)+1 || !strings.EqualFold(s[:len(name)], name) || s[len(name)] != '(' || !strings.HasSuffix(s, ")") {
+		return "", false
+	}
+	return strings.TrimSpace(s[len(name)+1 : len(s)-1]), true
+}
+
+// contentArgs splits the comma separated arguments of a content function,
+// unquoting strings. Commas inside nested functions do not split.
+func contentArgs(s string) []string {
+	var parts []string
+	depth, start := 0, 0
+	for i := 0; i < len(s); i++ {
+		switch s[i] {
+		case '(':
+			depth++
+		case ')':
+			depth--
+		case ',':
+			if depth == 0 {
+				parts = append(parts, s[start:i])
+				start = i + 1
+			}
+		}
+	}
+	parts = append(parts, s[start:])
+	var args []string
+	for _, a := range parts {
+		a = strings.TrimSpace(a)
+		if a != "" && (a[0] == '"' || a[0] == '\'') {
+			a, _ = cssString(a)
+		}
+		args = append(args, a)
+	}
+	return args
+}
+
+// cssString reads the quoted string at the start of s, resolving escapes
+// such as \A and \201C, and returns it with the number of bytes consumed
+func cssString(s string) (string, int) {
+	q := s[0]
+	var b strings.Builder
+	i := 1
+	for i < len(s) && s[i] != q {
+		if s[i] != '\\' || i+1 >= len(s) {
+			b.WriteByte(s[i])
+			i++
+			continue
+		}
+		i++
+		j := i
+		for j < len(s) && j-i < 6 && isHex(s[j]) {
+			j++
+		}
+		if j == i {
+			b.WriteByte(s[i])
+			i++
+			continue
+		}
+		r, _ := strconv.ParseUint(s[i:j], 16, 32)
+		b.WriteRune(rune(r))
+		// One whitespace character after a hex escape belongs to it
+		if j < len(s) && isSpace(s[j]) {
+			j++
+		}
+		i = j
+	}
+	if i < len(s) {
+		i++
+	}
+	return b.String(), i
+}
+
+// isHex reports whether c is a hexadecimal digit
+func isHex(c byte) bool {
+	return c >= '0' && c <= '9' || c >= 'a' && c <= 'f' || c >= 'A' && c <= 'F'
+}
+
+// FormatCounter formats a counter value in a list-style-type such as
+// decimal, lower-roman, upper-alpha or lower-greek
+func FormatCounter(n int, listStyle string) string {
+	switch strings.ToLower(strings.TrimSpace(listStyle)) {
+	case "none":
+		return ""
+	case "disc":
+		return "•"
+	case "circle":
+		return "◦"
+	case "square":
+		return "▪"
+	case "decimal-leading-zero":
+		if n >= 0 && n < 10 {
+			return "0" + strconv.Itoa(n)
+		}
+	case "lower-alpha", "lower-latin":
+		return alphabetic(n, latinLower)
+	case "upper-alpha", "upper-latin":
+		return alphabetic(n, latinUpper)
+	case "lower-greek":
+		return alphabetic(n, greekLower)
+	case "lower-roman":
+		return strings.ToLower(roman(n))
+	case "upper-roman":
+		return roman(n)
+	}
+	return strconv.Itoa(n)
+}
+
+// The letters alphabetic list styles count in
+var (
+	latinLower = []rune("abcdefghijklmnopqrstuvwxyz")
+	latinUpper = []rune("ABCDEFGHIJKLMNOPQRSTUVWXYZ")
+	// greekLower leaves out the final sigma
+	greekLower = []rune("αβγδεζηθικλμνξοπρστυφχψω")
+)
+
+// alphabetic formats n in letters as a, b, ... z, aa, ab, ...; values below
+// one fall back to decimal
+func alphabetic(n int, letters []rune) string {
+	if n < 1 {
+		return strconv.Itoa(n)
+	}
+	var digits []rune
+	base := len(letters)
+	for n > 0 {
+		n--
+		digits = append([]rune{letters[n%base]}, digits...)
+		n /= base
+	}
+	return string(digits)
+}
+
+// roman formats n in upper case roman numerals; values outside 1..3999 fall
+// back to decimal
+func roman(n int) string {
+	if n < 1 || n > 3999 {
+		return strconv.Itoa(n)
+	}
+	values := []int{1000, 900, 500, 400, 100, 90, 50, 40, 10, 9, 5, 4, 1}
+	symbols := []string{"M", "CM", "D", "CD", "C", "XC", "L", "XL", "X", "IX", "V", "IV", "I"}
+	var b strings.Builder
+	for i, v := range values {
+		for n >= v {
+			b.WriteString(symbols[i])
+			n -= v
+		}
+	}
+	return b.String()
+}
@@ -0,0 +1,29 @@
+package style
+
+import (
+	"testing"
+
+	"github.com/gompdf/gompdf/internal/parser/html"
+	xhtml "golang.org/x/net/html"
+)
+
+func TestContentTextUnclosedFunction(t *testing.T) {
+	node := &html.Node{Type: xhtml.ElementNode, Data: "p", Attr: []xhtml.Attribute{{Key: "x", Val: "value"}}}
+	tests := []struct {
+		content, want string
+	}{
+		{`counter(`, "0"},
+		{`attr(`, ""},
+		{`attr(x`, "value"},
+		{`"a" attr(x`, "avalue"},
+		{`counters(c, "."`, "0"},
+		{`attr(x) "b"`, "valueb"},
+	}
+	for _, tt := range tests {
+		e := NewStyleEngine()
+		e.counters = make(counters)
+		if got := e.contentText(node, tt.content); got != tt.want {
+			t.Errorf("contentText(%q) = %q, want %q", tt.content, got, tt.want)
+		}
+	}
+}
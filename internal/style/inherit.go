@@ -0,0 +1,104 @@
+package style
+
+import "strings"
+
+// inheritedProperties are the properties an element takes from its parent
+// when no declaration sets them, mapped to their initial values. An empty
+// initial value leaves the choice to layout and rendering, as an unset
+// property does.
+var inheritedProperties = map[string]string{
+	"color":                  "#000000",
+	"font-family":            "",
+	"font-size":              "medium",
+	"font-style":             "normal",
+	"font-variant":           "normal",
+	"font-weight":            "normal",
+	"font-stretch":           "normal",
+	"line-height":            "normal",
+	"letter-spacing":         "normal",
+	"word-spacing":           "normal",
+	"text-align":             "start",
+	"text-align-last":        "auto",
+	"text-indent":            "0",
+	"text-transform":         "none",
+	"text-shadow":            "none",
+	"white-space":            "normal",
+	"word-break":             "normal",
+	"overflow-wrap":          "normal",
+	"word-wrap":              "normal",
+	"hyphens":                "manual",
+	"tab-size":               "8",
+	"direction":              "ltr",
+	"writing-mode":           "horizontal-tb",
+	"text-orientation":       "mixed",
+	"visibility":             "visible",
+	"list-style-type":        "disc",
+	"list-style-position":    "outside",
+	"list-style-image":       "none",
+	"quotes":                 "auto",
+	"border-collapse":        "separate",
+	"border-spacing":         "0",
+	"caption-side":           "top",
+	"empty-cells":            "show",
+	"orphans":                "2",
+	"widows":                 "2",
+	"cursor":                 "auto",
+	"image-orientation":      "from-image",
+	"font-kerning":           "auto",
+	"font-feature-settings":  "normal",
+	"font-variant-caps":      "normal",
+	"font-variant-ligatures": "normal",
+	"font-variant-numeric":   "normal",
+}
+
+// IsInherited reports whether a property is inherited by default. Custom
+// properties (--name) are inherited.
+func IsInherited(name string) bool {
+	if strings.HasPrefix(name, "--") {
+		return true
+	}
+	_, ok := inheritedProperties[name]
+	return ok
+}
+
+// inherit completes the cascaded style of an element with the inherited
+// properties of its parent's style, which is nil for the root element, and
+// resolves the inherit, initial and unset keywords. Properties that are not
+// inherited and have no declaration stay unset, so layout and rendering
+// apply their initial values.
+func inherit(style, parent ComputedStyle) {
+	for name, prop := range style {
+		keyword := strings.ToLower(strings.TrimSpace(prop.Value))
+		if keyword == "unset" {
+			keyword = "initial"
+			if IsInherited(name) {
+				keyword = "inherit"
+			}
+		}
+		switch keyword {
+		case "inherit":
+			if p, ok := parent[name]; ok {
+				prop.Value = p.Value
+				style[name] = prop
+			} else if initial, ok := inheritedProperties[name]; ok {
+				prop.Value = initial
+				style[name] = prop
+			} else {
+				delete(style, name)
+			}
+		case "initial":
+			if initial, ok := inheritedProperties[name]; ok {
+				prop.Value = initial
+				style[name] = prop
+			} else {
+				delete(style, name)
+			}
+		}
+	}
+
+	for name, prop := range parent {
+		if _, ok := style[name]; !ok && IsInherited(name) {
+			style[name] = prop
+		}
+	}
+}
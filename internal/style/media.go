@@ -0,0 +1,266 @@
+package style
+
+import (
+	"strconv"
+	"strings"
+)
+
+// DefaultMediaType is the media type stylesheets are evaluated for unless
+// SetMediaType chooses another
+const DefaultMediaType = "print"
+
+// SetMediaType sets the media type @media rules are matched against, such as
+// "print" or "screen"
+func (e *StyleEngine) SetMediaType(mediaType string) {
+	e.mediaType = strings.ToLower(strings.TrimSpace(mediaType))
+	e.media = nil
+}
+
+// SetPageSize sets the page size in points that the width, height and
+// orientation media features match
+func (e *StyleEngine) SetPageSize(width, height float64) {
+	e.pageWidth, e.pageHeight = width, height
+	e.media = nil
+}
+
+// mediaMatches reports whether all the media query lists a rule is nested in
+// match the output: the media type and the page size set with SetPageSize
+func (e *StyleEngine) mediaMatches(lists []string) bool {
+	if e.media == nil {
+		e.media = make(map[string]bool)
+	}
+	for _, list := range lists {
+		ok, seen := e.media[list]
+		if !seen {
+			ok = MediaMatches(list, e.mediaType, e.pageWidth, e.pageHeight)
+			e.media[list] = ok
+		}
+		if !ok {
+			return false
+		}
+	}
+	return true
+}
+
+// MediaMatches evaluates a media query list such as "print and (min-width:
+// 600px), screen" for a media type and a page of the given size in points.
+// An empty list matches everything.
+func MediaMatches(list, mediaType string, width, height float64) bool {
+	list = strings.ToLower(strings.TrimSpace(list))
+	if list == "" {
+		return true
+	}
+	for _, q := range strings.Split(list, ",") {
+		if mediaQueryMatches(strings.TrimSpace(q), mediaType, width, height) {
+			return true
+		}
+	}
+	return false
+}
+
+// mediaQueryMatches evaluates one media query
+func mediaQueryMatches(q, mediaType string, width, height float64) bool {
+	negate := false
+	parts := splitOutsideParens(q)
+	if len(parts) > 0 && (parts[0] == "not" || parts[0] == "only") {
+		negate = parts[0] == "not"
+		parts = parts[1:]
+	}
+	if len(parts) == 0 {
+		return false
+	}
+
+	result := true
+	expectTerm := true
+	for i := 0; i < len(parts); i++ {
+		part := parts[i]
+		if !expectTerm {
+			// Terms are joined by "and"; "or" and anything else is not
+			// supported and makes the query false
+			if part != "and" {
+				return false
+			}
+			expectTerm = true
+			continue
+		}
+		expectTerm = false
+
+		notTerm := false
+		if part == "not" && i+1 < len(parts) {
+			notTerm = true
+			i++
+			part = parts[i]
+		}
+		var ok bool
+		if strings.HasPrefix(part, "(") && strings.HasSuffix(part, ")") {
+			ok = featureMatches(strings.TrimSpace(part[1:len(part)-1]), width, height)
+		} else {
+			ok = part == "all" || part == mediaType
+		}
+		result = result && ok != notTerm
+	}
+	if expectTerm {
+		return false
+	}
+	return result != negate
+}
+
+// featureMatches evaluates a media feature in the forms "min-width: 600px",
+// "color", "width >= 600px" or "400px <= width <= 800px"
+func featureMatches(f string, width, height float64) bool {
+	if name, value, ok := strings.Cut(f, ":"); ok {
+		name, value = strings.TrimSpace(name), strings.TrimSpace(value)
+		prefix := ""
+		if strings.HasPrefix(name, "min-") || strings.HasPrefix(name, "max-") {
+			prefix, name = name[:4], name[4:]
+		}
+		actual, ok := featureValue(name, width, height)
+		if !ok {
+			if name == "orientation" && prefix == "" {
+				orientation := "landscape"
+				if height >= width {
+					orientation = "portrait"
+				}
+				return value == orientation
+			}
+			return false
+		}
+		want, ok := featureOperand(name, value)
+		if !ok {
+			return false
+		}
+		switch prefix {
+		case "min-":
+			return actual >= want
+		case "max-":
+			return actual <= want
+		}
+		return actual == want
+	}
+
+	if strings.ContainsAny(f, "<>=") {
+		return rangeMatches(f, width, height)
+	}
+
+	// Boolean context: a feature matches when its value is not zero
+	switch f {
+	case "width", "height", "device-width", "device-height", "aspect-ratio", "color", "orientation":
+		return true
+	}
+	return false
+}
+
+// rangeMatches evaluates the range syntax of Media Queries Level 4
+func rangeMatches(f string, width, height float64) bool {
+	var operands, ops []string
+	for f != "" {
+		i := strings.IndexAny(f, "<>=")
+		if i < 0 {
+			operands = append(operands, strings.TrimSpace(f))
+			break
+		}
+		operands = append(operands, strings.TrimSpace(f[:i]))
+		op := f[i : i+1]
+		if i+1 < len(f) && f[i+1] == '=' {
+			op = f[i : i+2]
+		}
+		ops = append(ops, op)
+		f = f[i+len(op):]
+	}
+	if len(ops) == 0 || len(operands) != len(ops)+1 {
+		return false
+	}
+
+	// Exactly one operand names the feature
+	name := ""
+	for _, o := range operands {
+		if _, ok := featureValue(o, width, height); ok {
+			name = o
+		}
+	}
+	if name == "" {
+		return false
+	}
+	values := make([]float64, len(operands))
+	for i, o := range operands {
+		if o == name {
+			values[i], _ = featureValue(o, width, height)
+			continue
+		}
+		v, ok := featureOperand(name, o)
+		if !ok {
+			return false
+		}
+		values[i] = v
+	}
+	for i, op := range ops {
+		a, b := values[i], values[i+1]
+		var ok bool
+		switch op {
+		case "<":
+			ok = a < b
+		case "<=":
+			ok = a <= b
+		case ">":
+			ok = a > b
+		case ">=":
+			ok = a >= b
+		case "=":
+			ok = a == b
+		}
+		if !ok {
+			return false
+		}
+	}
+	return true
+}
+
+// featureValue returns the value of a numeric media feature for the page
+func featureValue(name string, width, height float64) (float64, bool) {
+	switch strings.TrimPrefix(name, "device-") {
+	case "width":
+		return width, true
+	case "height":
+		return height, true
+	case "aspect-ratio":
+		if height == 0 {
+			return 0, false
+		}
+		return width / height, true
+	case "color":
+		return 8, true
+	case "monochrome":
+		return 0, true
+	}
+	return 0, false
+}
+
+// featureOperand parses the value a feature is compared with: a ratio for
+// aspect-ratio, a number for color, a length otherwise. em and rem refer to
+// the initial font size.
+func featureOperand(name, v string) (float64, bool) {
+	switch strings.TrimPrefix(name, "device-") {
+	case "aspect-ratio":
+		num, den, ok := strings.Cut(v, "/")
+		n, err := strconv.ParseFloat(strings.TrimSpace(num), 64)
+		if err != nil {
+			return 0, false
+		}
+		if !ok {
+			return n, true
+		}
+		d, err := strconv.ParseFloat(strings.TrimSpace(den), 64)
+		if err != nil || d == 0 {
+			return 0, false
+		}
+		return n / d, true
+	case "color", "monochrome":
+		n, err := strconv.ParseFloat(v, 64)
+		return n, err == nil
+	}
+	n, unit, ok := SplitUnit(v)
+	if ok && (unit == "em" || unit == "rem") {
+		return n * DefaultFontSize, true
+	}
+	return AbsoluteLength(v)
+}
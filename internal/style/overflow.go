@@ -0,0 +1,20 @@
+package style
+
+import "strings"
+
+// ClipsOverflow reports whether a box clips content overflowing its padding
+// box. Paged output cannot scroll, so auto and scroll clip like hidden. A
+// box clipping on one axis clips on both: visible on the other axis then
+// computes to auto, except beside clip, which is taken the same way here.
+func (cs ComputedStyle) ClipsOverflow() bool {
+	return clips(cs.value("overflow-x")) || clips(cs.value("overflow-y"))
+}
+
+// clips reports whether an overflow value clips
+func clips(v string) bool {
+	switch strings.ToLower(v) {
+	case "hidden", "auto", "scroll", "clip":
+		return true
+	}
+	return false
+}
@@ -0,0 +1,110 @@
+package style
+
+import (
+	"encoding/hex"
+	"strings"
+)
+
+// The page and pages counters are only known once the document has been
+// paginated. counter(page), counter(pages) and target-counter(..., page) in
+// generated content therefore leave a placeholder in the text, delimited by
+// private use characters, which the renderer replaces on every page.
+//
+// Leaders, from leader() in generated content, fill the space their line
+// leaves free, which only layout knows. They are marked in the text the same
+// way, with their pattern hex encoded so that white space processing leaves
+// it alone.
+const (
+	pageCounterOpen  = "\ue000"
+	pageCounterClose = "\ue001"
+	leaderOpen       = "\ue002"
+	leaderClose      = "\ue003"
+)
+
+// isPageCounter reports whether a counter name refers to the page context
+func isPageCounter(name string) bool {
+	return name == "page" || name == "pages"
+}
+
+// pageCounterPlaceholder returns the placeholder of counter(name, listStyle),
+// where listStyle may be empty. target-counter() placeholders are named
+// after the fragment of their target, such as "#intro".
+func pageCounterPlaceholder(name, listStyle string) string {
+	return pageCounterOpen + name + ":" + strings.TrimSpace(listStyle) + pageCounterClose
+}
+
+// HasPageCounters reports whether text holds page counter placeholders
+func HasPageCounters(text string) bool {
+	return strings.Contains(text, pageCounterOpen)
+}
+
+// HasDocumentCounters reports whether text holds placeholders whose value
+// is known only once the whole document is paginated: counter(pages) and
+// target-counter()
+func HasDocumentCounters(text string) bool {
+	for {
+		open := strings.Index(text, pageCounterOpen)
+		if open < 0 {
+			return false
+		}
+		text = text[open+len(pageCounterOpen):]
+		if strings.HasPrefix(text, "pages:") || strings.HasPrefix(text, "#") {
+			return true
+		}
+	}
+}
+
+// ResolvePageCounters replaces the page counter placeholders of text with
+// the values value returns for a counter name and list-style-type. The list
+// style is empty when the content did not give one. Names of the page of a
+// target-counter() are the fragment of the target, such as "#intro".
+func ResolvePageCounters(text string, value func(name, listStyle string) string) string {
+	var b strings.Builder
+	for {
+		open := strings.Index(text, pageCounterOpen)
+		if open < 0 {
+			break
+		}
+		end := strings.Index(text[open:], pageCounterClose)
+		if end < 0 {
+			break
+		}
+		name, listStyle := text[open+len(pageCounterOpen):open+end], ""
+		if i := strings.LastIndexByte(name, ':'); i >= 0 {
+			name, listStyle = name[:i], name[i+1:]
+		}
+		b.WriteString(text[:open])
+		b.WriteString(value(name, listStyle))
+		text = text[open+end+len(pageCounterClose):]
+	}
+	b.WriteString(text)
+	return b.String()
+}
+
+// leaderMarker returns the marker of a leader drawn with pattern
+func leaderMarker(pattern string) string {
+	return leaderOpen + hex.EncodeToString([]byte(pattern)) + leaderClose
+}
+
+// HasLeaders reports whether text holds leader markers
+func HasLeaders(text string) bool {
+	return strings.Contains(text, leaderOpen)
+}
+
+// CutLeader slices text around its first leader marker, returning the text
+// before and after it and the leader's pattern
+func CutLeader(text string) (before, pattern, after string, found bool) {
+	open := strings.Index(text, leaderOpen)
+	if open < 0 {
+		return text, "", "", false
+	}
+	end := strings.Index(text[open:], leaderClose)
+	if end < 0 {
+		return text, "", "", false
+	}
+	p, err := hex.DecodeString(text[open+len(leaderOpen) : open+end])
+	if err != nil {
+		return text, "", "", false
+	}
+	return text[:open], string(p), text[open+end+len(leaderClose):], true
+}
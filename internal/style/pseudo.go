@@ -0,0 +1,280 @@
+package style
+
+import (
+	"strconv"
+	"strings"
+
+	"github.com/gompdf/gompdf/internal/parser/html"
+	xhtml "golang.org/x/net/html"
+)
+
+// pseudoClass is a pseudo-class such as :first-child. Functional forms keep
+// their parsed argument: the an+b pattern of :nth-child(2n+1) and the
+// selector list of :not(), :is() or :where() and of :nth-child(... of S).
+type pseudoClass struct {
+	name string
+	a, b int
+	list []*selector
+}
+
+// parsePseudoClass parses a pseudo-class with its optional parenthesized
+// argument. Unknown pseudo-classes are accepted and never match.
+func parsePseudoClass(name, arg string, hasArg bool) (pseudoClass, bool) {
+	pc := pseudoClass{name: name}
+	switch name {
+	case "nth-child", "nth-last-child", "nth-of-type", "nth-last-of-type":
+		if !hasArg {
+			return pc, false
+		}
+		pattern := arg
+		if name == "nth-child" || name == "nth-last-child" {
+			if i := indexOfKeyword(arg, "of"); i >= 0 {
+				pattern = arg[:i]
+				list, ok := parseSelectorList(arg[i+2:])
+				if !ok {
+					return pc, false
+				}
+				pc.list = list
+			}
+		}
+		a, b, ok := parseNth(pattern)
+		if !ok {
+			return pc, false
+		}
+		pc.a, pc.b = a, b
+	case "not", "is", "where", "matches", "any":
+		if !hasArg {
+			return pc, false
+		}
+		list, ok := parseSelectorList(arg)
+		if !ok {
+			return pc, false
+		}
+		pc.list = list
+	default:
+		if hasArg {
+			return pc, false
+		}
+	}
+	return pc, true
+}
+
+// parseSelectorList parses the comma separated selectors of a functional
+// pseudo-class
+func parseSelectorList(s string) ([]*selector, bool) {
+	var list []*selector
+	depth := 0
+	start := 0
+	for i := 0; i <= len(s); i++ {
+		if i < len(s) {
+			switch s[i] {
+			case '(', '[':
+				depth++
+				continue
+			case ')', ']':
+				depth--
+				continue
+			case ',':
+				if depth > 0 {
+					continue
+				}
+			default:
+				continue
+			}
+		}
+		sel, ok := parseSelector(s[start:i])
+		if !ok {
+			return nil, false
+		}
+		list = append(list, sel)
+		start = i + 1
+	}
+	return list, true
+}
+
+// indexOfKeyword finds a whitespace delimited keyword in s, or returns -1
+func indexOfKeyword(s, kw string) int {
+	for i := 1; i+len(kw) < len(s); i++ {
+		if isSpace(s[i-1]) && strings.EqualFold(s[i:i+len(kw)], kw) && isSpace(s[i+len(kw)]) {
+			return i
+		}
+	}
+	return -1
+}
+
+// parseNth parses the an+b microsyntax, including odd and even
+func parseNth(s string) (int, int, bool) {
+	s = strings.ToLower(strings.Join(strings.Fields(s), ""))
+	switch s {
+	case "odd":
+		return 2, 1, true
+	case "even":
+		return 2, 0, true
+	case "":
+		return 0, 0, false
+	}
+	n := strings.IndexByte(s, 'n')
+	if n < 0 {
+		b, err := strconv.Atoi(s)
+		return 0, b, err == nil
+	}
+	a := 0
+	switch coef := s[:n]; coef {
+	case "", "+":
+		a = 1
+	case "-":
+		a = -1
+	default:
+		v, err := strconv.Atoi(coef)
+		if err != nil {
+			return 0, 0, false
+		}
+		a = v
+	}
+	b := 0
+	if rest := s[n+1:]; rest != "" {
+		if rest[0] != '+' && rest[0] != '-' {
+			return 0, 0, false
+		}
+		v, err := strconv.Atoi(rest)
+		if err != nil {
+			return 0, 0, false
+		}
+		b = v
+	}
+	return a, b, true
+}
+
+// nthMatches reports whether the 1-based position idx is a*n+b for some
+// n >= 0
+func nthMatches(a, b, idx int) bool {
+	if a == 0 {
+		return idx == b
+	}
+	d := idx - b
+	return d%a == 0 && d/a >= 0
+}
+
+// specificity returns the specificity a pseudo-class adds: :where() adds
+// none, :not() and :is() add that of their most specific argument
+func (pc pseudoClass) specificity() Specificity {
+	switch pc.name {
+	case "where":
+		return Specificity{}
+	case "not", "is", "matches", "any":
+		return maxSpecificity(pc.list)
+	case "nth-child", "nth-last-child":
+		return Specificity{Class: 1}.add(maxSpecificity(pc.list))
+	}
+	return Specificity{Class: 1}
+}
+
+// maxSpecificity returns the highest specificity in a selector list
+func maxSpecificity(list []*selector) Specificity {
+	var spec Specificity
+	for _, sel := range list {
+		if s := sel.specificity(); compareSpecificity(s, spec) > 0 {
+			spec = s
+		}
+	}
+	return spec
+}
+
+// matches reports whether an element is in the state a pseudo-class
+// describes. A printed document is never hovered, focused or visited, so
+// dynamic and unknown pseudo-classes never match.
+func (pc pseudoClass) matches(node *html.Node) bool {
+	switch pc.name {
+	case "root":
+		return parentElement(node) == nil
+	case "empty":
+		// Whitespace does not count as content, as in Selectors Level 4
+		for c := node.FirstChild; c != nil; c = c.NextSibling {
+			if c.Type == xhtml.ElementNode || c.Type == xhtml.TextNode && strings.TrimSpace(c.Data) != "" {
+				return false
+			}
+		}
+		return true
+	case "first-child":
+		return prevElement(node) == nil
+	case "last-child":
+		return nextElement(node) == nil
+	case "only-child":
+		return prevElement(node) == nil && nextElement(node) == nil
+	case "first-of-type":
+		return siblingIndex(node, prevElement, sameType(node)) == 1
+	case "last-of-type":
+		return siblingIndex(node, nextElement, sameType(node)) == 1
+	case "only-of-type":
+		return siblingIndex(node, prevElement, sameType(node)) == 1 && siblingIndex(node, nextElement, sameType(node)) == 1
+	case "nth-child", "nth-last-child":
+		filter := func(*html.Node) bool { return true }
+		if pc.list != nil {
+			if !matchesAny(pc.list, node) {
+				return false
+			}
+			filter = func(n *html.Node) bool { return matchesAny(pc.list, n) }
+		}
+		step := prevElement
+		if pc.name == "nth-last-child" {
+			step = nextElement
+		}
+		return nthMatches(pc.a, pc.b, siblingIndex(node, step, filter))
+	case "nth-of-type":
+		return nthMatches(pc.a, pc.b, siblingIndex(node, prevElement, sameType(node)))
+	case "nth-last-of-type":
+		return nthMatches(pc.a, pc.b, siblingIndex(node, nextElement, sameType(node)))
+	case "not":
+		return !matchesAny(pc.list, node)
+	case "is", "where", "matches", "any":
+		return matchesAny(pc.list, node)
+	case "link", "any-link":
+		if _, ok := attrValue(node, "href"); ok {
+			return strings.EqualFold(node.Data, "a") || strings.EqualFold(node.Data, "area")
+		}
+	case "checked":
+		_, ok := attrValue(node, "checked")
+		return ok
+	case "disabled":
+		_, ok := attrValue(node, "disabled")
+		return ok
+	}
+	return false
+}
+
+// matchesAny reports whether any selector of a list matches node
+func matchesAny(list []*selector, node *html.Node) bool {
+	for _, sel := range list {
+		if sel.matches(node) {
+			return true
+		}
+	}
+	return false
+}
+
+// siblingIndex returns the 1-based position of node among the siblings that
+// satisfy filter, counting in the direction of step
+func siblingIndex(node *html.Node, step func(*html.Node) *html.Node, filter func(*html.Node) bool) int {
+	idx := 1
+	for s := step(node); s != nil; s = step(s) {
+		if filter(s) {
+			idx++
+		}
+	}
+	return idx
+}
+
+// sameType returns a filter for elements with the same name as node
+func sameType(node *html.Node) func(*html.Node) bool {
+	return func(n *html.Node) bool { return strings.EqualFold(n.Data, node.Data) }
+}
+
+// nextElement returns the closest following sibling element of node
+func nextElement(node *html.Node) *html.Node {
+	for s := node.NextSibling; s != nil; s = s.NextSibling {
+		if s.Type == xhtml.ElementNode {
+			return s
+		}
+	}
+	return nil
+}
@@ -0,0 +1,484 @@
+package style
+
+import (
+	"strings"
+
+	"github.com/gompdf/gompdf/internal/parser/html"
+	xhtml "golang.org/x/net/html"
+)
+
+// selector is a parsed complex selector: compound selectors joined by
+// combinators, in source order
+type selector struct {
+	compounds []compound
+	// combinators[i] joins compounds[i] and compounds[i+1]: ' ' for
+	// descendant, '>' for child, '+' for adjacent and '~' for general sibling
+	combinators []byte
+}
+
+// compound is a sequence of simple selectors that all apply to one element
+type compound struct {
+	// tag is the element name, or "" / "*" for any element
+	tag     string
+	ids     []string
+	classes []string
+	attrs   []attrSelector
+	pseudos []pseudoClass
+	// pseudoElement is set for selectors such as p::first-line
+	pseudoElement string
+}
+
+// attrSelector matches an attribute: op is "" (present), "=", "~=", "|=",
+// "^=", "$=" or "*="
+type attrSelector struct {
+	name  string
+	op    string
+	value string
+	// fold compares the value case-insensitively, as requested by the i flag
+	fold bool
+}
+
+// parseSelector parses a complex selector. It reports false when the
+// selector is malformed; such selectors never match.
+func parseSelector(s string) (*selector, bool) {
+	p := &selectorParser{s: strings.TrimSpace(s)}
+	sel := &selector{}
+	for {
+		c, ok := p.compound()
+		if !ok {
+			return nil, false
+		}
+		sel.compounds = append(sel.compounds, c)
+
+		space := p.skipSpace()
+		if p.done() {
+			return sel, true
+		}
+		comb := byte(' ')
+		switch p.s[p.pos] {
+		case '>', '+', '~':
+			comb = p.s[p.pos]
+			p.pos++
+			p.skipSpace()
+		default:
+			if !space {
+				return nil, false
+			}
+		}
+		// Nothing may follow a pseudo-element
+		if c.pseudoElement != "" {
+			return nil, false
+		}
+		sel.combinators = append(sel.combinators, comb)
+	}
+}
+
+// selectorParser scans a selector string
+type selectorParser struct {
+	s   string
+	pos int
+}
+
+func (p *selectorParser) done() bool {
+	return p.pos >= len(p.s)
+}
+
+// skipSpace skips whitespace and reports whether there was any
+func (p *selectorParser) skipSpace() bool {
+	start := p.pos
+	for !p.done() && isSpace(p.s[p.pos]) {
+		p.pos++
+	}
+	return p.pos > start
+}
+
+// compound parses one compound selector
+func (p *selectorParser) compound() (compound, bool) {
+	var c compound
+	start := p.pos
+	if !p.done() && p.s[p.pos] == '*' {
+		c.tag = "*"
+		p.pos++
+	} else if name := p.ident(); name != "" {
+		c.tag = strings.ToLower(name)
+	}
+
+	for !p.done() {
+		// Only pseudo-classes may follow a pseudo-element
+		if c.pseudoElement != "" && p.s[p.pos] != ':' {
+			break
+		}
+		switch p.s[p.pos] {
+		case '#':
+			p.pos++
+			id := p.ident()
+			if id == "" {
+				return c, false
+			}
+			c.ids = append(c.ids, id)
+		case '.':
+			p.pos++
+			class := p.ident()
+			if class == "" {
+				return c, false
+			}
+			c.classes = append(c.classes, class)
+		case '[':
+			a, ok := p.attr()
+			if !ok {
+				return c, false
+			}
+			c.attrs = append(c.attrs, a)
+		case ':':
+			p.pos++
+			if !p.done() && p.s[p.pos] == ':' {
+				p.pos++
+				c.pseudoElement = strings.ToLower(p.ident())
+				if c.pseudoElement == "" {
+					return c, false
+				}
+				continue
+			}
+			name := strings.ToLower(p.ident())
+			if name == "" {
+				return c, false
+			}
+			// The CSS2 pseudo-elements keep their single colon syntax
+			switch name {
+			case "before", "after", "first-line", "first-letter":
+				c.pseudoElement = name
+				continue
+			}
+			var arg string
+			hasArg := !p.done() && p.s[p.pos] == '('
+			if hasArg {
+				var ok bool
+				if arg, ok = p.parens(); !ok {
+					return c, false
+				}
+			}
+			pc, ok := parsePseudoClass(name, arg, hasArg)
+			if !ok {
+				return c, false
+			}
+			c.pseudos = append(c.pseudos, pc)
+		default:
+			return c, p.pos > start
+		}
+	}
+	return c, p.pos > start
+}
+
+// ident reads an identifier, resolving backslash escapes
+func (p *selectorParser) ident() string {
+	var b strings.Builder
+	for !p.done() {
+		c := p.s[p.pos]
+		switch {
+		case c == '\\' && p.pos+1 < len(p.s):
+			b.WriteByte(p.s[p.pos+1])
+			p.pos += 2
+			continue
+		case c == '-' || c == '_' || c >= 0x80 || isUnitLetter(c) || c >= '0' && c <= '9':
+			b.WriteByte(c)
+			p.pos++
+			continue
+		}
+		break
+	}
+	return b.String()
+}
+
+// attr parses an attribute selector such as [type="text" i]
+func (p *selectorParser) attr() (attrSelector, bool) {
+	var a attrSelector
+	p.pos++ // [
+	p.skipSpace()
+	a.name = strings.ToLower(p.ident())
+	if a.name == "" {
+		return a, false
+	}
+	p.skipSpace()
+	if p.done() {
+		return a, false
+	}
+	if p.s[p.pos] == ']' {
+		p.pos++
+		return a, true
+	}
+
+	if p.s[p.pos] == '=' {
+		a.op = "="
+		p.pos++
+	} else if p.pos+1 < len(p.s) && p.s[p.pos+1] == '=' && strings.IndexByte("~|^$*", p.s[p.pos]) >= 0 {
+		a.op = p.s[p.pos : p.pos+2]
+		p.pos += 2
+	} else {
+		return a, false
+	}
+	p.skipSpace()
+	if p.done() {
+		return a, false
+	}
+	if q := p.s[p.pos]; q == '"' || q == '\'' {
+		end := strings.IndexByte(p.s[p.pos+1:], q)
+		if end < 0 {
+			return a, false
+		}
+		a.value = p.s[p.pos+1 : p.pos+1+end]
+		p.pos += end + 2
+	} else {
+		a.value = p.ident()
+	}
+	p.skipSpace()
+	if !p.done() && (p.s[p.pos] == 'i' || p.s[p.pos] == 'I' || p.s[p.pos] == 's' || p.s[p.pos] == 'S') {
+		a.fold = p.s[p.pos] == 'i' || p.s[p.pos] == 'I'
+		p.pos++
+		p.skipSpace()
+	}
+	if p.done() || p.s[p.pos] != ']' {
+		return a, false
+	}
+	p.pos++
+	return a, true
+}
+
+// parens reads a parenthesized argument and returns its trimmed contents
+func (p *selectorParser) parens() (string, bool) {
+	depth := 0
+	start := p.pos + 1
+	for ; !p.done(); p.pos++ {
+		switch p.s[p.pos] {
+		case '(':
+			depth++
+		case ')':
+			depth--
+			if depth == 0 {
+				arg := p.s[start:p.pos]
+				p.pos++
+				return strings.TrimSpace(arg), true
+			}
+		}
+	}
+	return "", false
+}
+
+// isSpace reports whether c is CSS whitespace
+func isSpace(c byte) bool {
+	return c == ' ' || c == '\t' || c == '\n' || c == '\r' || c == '\f'
+}
+
+// specificity counts the simple selectors of a selector
+func (s *selector) specificity() Specificity {
+	var spec Specificity
+	for _, c := range s.compounds {
+		spec = spec.add(c.specificity())
+	}
+	return spec
+}
+
+// specificity counts the simple selectors of a compound selector
+func (c compound) specificity() Specificity {
+	spec := Specificity{
+		ID:    len(c.ids),
+		Class: len(c.classes) + len(c.attrs),
+	}
+	for _, pc := range c.pseudos {
+		spec = spec.add(pc.specificity())
+	}
+	if c.tag != "" && c.tag != "*" {
+		spec.Element++
+	}
+	if c.pseudoElement != "" {
+		spec.Element++
+	}
+	return spec
+}
+
+func (s Specificity) add(o Specificity) Specificity {
+	return Specificity{ID: s.ID + o.ID, Class: s.Class + o.Class, Element: s.Element + o.Element}
+}
+
+// matches reports whether node is the subject of the selector
+func (s *selector) matches(node *html.Node) bool {
+	return s.matchFrom(len(s.compounds)-1, node)
+}
+
+// matchesPseudo reports whether the selector targets the given
+// pseudo-element ("before" or "after") of node
+func (s *selector) matchesPseudo(node *html.Node, pseudo string) bool {
+	i := len(s.compounds) - 1
+	last := s.compounds[i]
+	if last.pseudoElement != pseudo || !last.matchesElement(node) {
+		return false
+	}
+	return i == 0 || s.matchCombinator(i, node)
+}
+
+// matchFrom matches compounds[0..i] with compounds[i] applied to node,
+// backtracking over the candidates of descendant and sibling combinators
+func (s *selector) matchFrom(i int, node *html.Node) bool {
+	if !s.compounds[i].matches(node) {
+		return false
+	}
+	return i == 0 || s.matchCombinator(i, node)
+}
+
+// matchCombinator matches compounds[0..i-1] against the elements that the
+// combinator before compounds[i] relates to node
+func (s *selector) matchCombinator(i int, node *html.Node) bool {
+	switch s.combinators[i-1] {
+	case '>':
+		parent := parentElement(node)
+		return parent != nil && s.matchFrom(i-1, parent)
+	case '+':
+		prev := prevElement(node)
+		return prev != nil && s.matchFrom(i-1, prev)
+	case '~':
+		for prev := prevElement(node); prev != nil; prev = prevElement(prev) {
+			if s.matchFrom(i-1, prev) {
+				return true
+			}
+		}
+	default:
+		for anc := parentElement(node); anc != nil; anc = parentElement(anc) {
+			if s.matchFrom(i-1, anc) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// matches reports whether a compound selector applies to an element.
+// Compounds with a pseudo-element never match the element itself.
+func (c compound) matches(node *html.Node) bool {
+	return c.pseudoElement == "" && c.matchesElement(node)
+}
+
+// matchesElement matches a compound selector against an element, ignoring
+// any pseudo-element it ends with
+func (c compound) matchesElement(node *html.Node) bool {
+	if node == nil || node.Type != xhtml.ElementNode {
+		return false
+	}
+	if c.tag != "" && c.tag != "*" && !strings.EqualFold(c.tag, node.Data) {
+		return false
+	}
+	for _, id := range c.ids {
+		if v, ok := attrValue(node, "id"); !ok || v != id {
+			return false
+		}
+	}
+	if len(c.classes) > 0 {
+		v, _ := attrValue(node, "class")
+		have := strings.Fields(v)
+		for _, need := range c.classes {
+			found := false
+			for _, h := range have {
+				if h == need {
+					found = true
+					break
+				}
+			}
+			if !found {
+				return false
+			}
+		}
+	}
+	for _, a := range c.attrs {
+		if !a.matches(node) {
+			return false
+		}
+	}
+	for _, pc := range c.pseudos {
+		if !pc.matches(node) {
+			return false
+		}
+	}
+	return true
+}
+
+// matches reports whether an element satisfies an attribute selector
+func (a attrSelector) matches(node *html.Node) bool {
+	v, ok := attrValue(node, a.name)
+	if !ok {
+		return false
+	}
+	want := a.value
+	if a.fold {
+		v, want = strings.ToLower(v), strings.ToLower(want)
+	}
+	switch a.op {
+	case "":
+		return true
+	case "=":
+		return v == want
+	case "~=":
+		for _, w := range strings.Fields(v) {
+			if w == want {
+				return true
+			}
+		}
+		return false
+	case "|=":
+		return v == want || strings.HasPrefix(v, want+"-")
+	case "^=":
+		return want != "" && strings.HasPrefix(v, want)
+	case "$=":
+		return want != "" && strings.HasSuffix(v, want)
+	case "*=":
+		return want != "" && strings.Contains(v, want)
+	}
+	return false
+}
+
+// attrValue returns the value of an attribute, matching its name
+// case-insensitively
+func attrValue(node *html.Node, name string) (string, bool) {
+	for _, a := range node.Attr {
+		if strings.EqualFold(a.Key, name) {
+			return a.Val, true
+		}
+	}
+	return "", false
+}
+
+// parentElement returns the parent of node if it is an element
+func parentElement(node *html.Node) *html.Node {
+	if p := node.Parent; p != nil && p.Type == xhtml.ElementNode {
+		return p
+	}
+	return nil
+}
+
+// prevElement returns the closest preceding sibling element of node
+func prevElement(node *html.Node) *html.Node {
+	for s := node.PrevSibling; s != nil; s = s.PrevSibling {
+		if s.Type == xhtml.ElementNode {
+			return s
+		}
+	}
+	return nil
+}
+
+// Selector is a parsed list of comma separated selectors, for finding
+// elements outside the cascade
+type Selector struct {
+	list []*selector
+}
+
+// ParseSelector parses a comma separated list of selectors. It reports
+// false when any of them is malformed.
+func ParseSelector(s string) (*Selector, bool) {
+	list, ok := parseSelectorList(s)
+	if !ok || len(list) == 0 {
+		return nil, false
+	}
+	return &Selector{list: list}, true
+}
+
+// Matches reports whether an element matches any selector of the list
+func (s *Selector) Matches(node *html.Node) bool {
+	return node != nil && node.Type == xhtml.ElementNode && matchesAny(s.list, node)
+}
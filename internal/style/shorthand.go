@@ -0,0 +1,465 @@
+package style
+
+import (
+	"strings"
+
+	"github.com/gompdf/gompdf/internal/parser/css"
+)
+
+// Shorthand properties are expanded into their longhands before the cascade,
+// so that a later "margin-left" overrides one side of an earlier "margin"
+// and layout and rendering only ever read longhands. A shorthand sets every
+// one of its longhands: the ones it does not mention get their initial value.
+
+// boxSides are the longhand suffixes of the four sided shorthands, in the
+// order their values are given
+var boxSides = []string{"-top", "-right", "-bottom", "-left"}
+
+// cssWideKeywords apply to every longhand of a shorthand alike
+var cssWideKeywords = map[string]bool{"inherit": true, "initial": true, "unset": true}
+
+// shorthands maps each shorthand to its longhands
+var shorthands = map[string][]string{
+	"margin":        {"margin-top", "margin-right", "margin-bottom", "margin-left"},
+	"padding":       {"padding-top", "padding-right", "padding-bottom", "padding-left"},
+	"border-width":  {"border-top-width", "border-right-width", "border-bottom-width", "border-left-width"},
+	"border-style":  {"border-top-style", "border-right-style", "border-bottom-style", "border-left-style"},
+	"border-color":  {"border-top-color", "border-right-color", "border-bottom-color", "border-left-color"},
+	"border-top":    {"border-top-width", "border-top-style", "border-top-color"},
+	"border-right":  {"border-right-width", "border-right-style", "border-right-color"},
+	"border-bottom": {"border-bottom-width", "border-bottom-style", "border-bottom-color"},
+	"border-left":   {"border-left-width", "border-left-style", "border-left-color"},
+	"border": {
+		"border-top-width", "border-right-width", "border-bottom-width", "border-left-width",
+		"border-top-style", "border-right-style", "border-bottom-style", "border-left-style",
+		"border-top-color", "border-right-color", "border-bottom-color", "border-left-color",
+	},
+	"background": {
+		"background-color", "background-image", "background-repeat", "background-position",
+		"background-size", "background-attachment", "background-origin", "background-clip",
+	},
+	"font":       {"font-style", "font-variant", "font-weight", "font-stretch", "font-size", "line-height", "font-family"},
+	"list-style": {"list-style-type", "list-style-position", "list-style-image"},
+	"flex":       {"flex-grow", "flex-shrink", "flex-basis"},
+	"gap":        {"row-gap", "column-gap"},
+	"grid-gap":   {"row-gap", "column-gap"},
+	"overflow":   {"overflow-x", "overflow-y"},
+}
+
+// expandStylesheet returns a copy of a stylesheet whose declarations have
+// their shorthands expanded
+func expandStylesheet(sheet *css.Stylesheet) *css.Stylesheet {
+	if sheet == nil {
+		return nil
+	}
+	out := &css.Stylesheet{Rules: make([]*css.Rule, len(sheet.Rules))}
+	for i, rule := range sheet.Rules {
+		r := *rule
+		r.Declarations = expandDeclarations(rule.Declarations)
+		out.Rules[i] = &r
+	}
+	return out
+}
+
+// expandDeclarations replaces the shorthands in a declaration block by their
+// longhands, keeping the source order. Shorthands with an invalid value are
+// dropped, as CSS drops any invalid declaration.
+func expandDeclarations(decls []*css.Declaration) []*css.Declaration {
+	var out []*css.Declaration
+	for _, decl := range decls {
+		property := strings.ToLower(strings.TrimSpace(decl.Property))
+		if _, ok := shorthands[property]; !ok {
+			out = append(out, decl)
+			continue
+		}
+		for _, l := range ExpandShorthand(property, decl.Value) {
+			out = append(out, &css.Declaration{Property: l.Property, Value: l.Value, Important: decl.Important})
+		}
+	}
+	return out
+}
+
+// Longhand is one property a shorthand expands to
+type Longhand struct {
+	Property string
+	Value    string
+}
+
+// ExpandShorthand expands a shorthand declaration into its longhands. It
+// returns nil when the value is invalid, and the declaration itself when the
+// property is not a shorthand.
+func ExpandShorthand(property, value string) []Longhand {
+	property = strings.ToLower(strings.TrimSpace(property))
+	value = strings.TrimSpace(value)
+	longhands, ok := shorthands[property]
+	if !ok {
+		return []Longhand{{property, value}}
+	}
+	if value == "" {
+		return nil
+	}
+	if kw := strings.ToLower(value); cssWideKeywords[kw] {
+		out := make([]Longhand, len(longhands))
+		for i, name := range longhands {
+			out[i] = Longhand{name, kw}
+		}
+		return out
+	}
+
+	var values []string
+	switch property {
+	case "margin", "padding", "border-width", "border-style", "border-color":
+		values, ok = expandBox(value)
+	case "border-top", "border-right", "border-bottom", "border-left":
+		values, ok = expandBorder(value)
+	case "border":
+		var side []string
+		if side, ok = expandBorder(value); ok {
+			values = []string{side[0], side[0], side[0], side[0], side[1], side[1], side[1], side[1], side[2], side[2], side[2], side[2]}
+		}
+	case "background":
+		values, ok = expandBackground(value)
+	case "font":
+		values, ok = expandFont(value)
+	case "list-style":
+		values, ok = expandListStyle(value)
+	case "flex":
+		values, ok = expandFlex(value)
+	case "gap", "grid-gap", "overflow":
+		parts := splitOutsideParens(value)
+		switch len(parts) {
+		case 1:
+			values, ok = []string{parts[0], parts[0]}, true
+		case 2:
+			values, ok = parts, true
+		default:
+			ok = false
+		}
+	}
+	if !ok {
+		return nil
+	}
+	out := make([]Longhand, len(longhands))
+	for i, name := range longhands {
+		out[i] = Longhand{name, values[i]}
+	}
+	return out
+}
+
+// expandBox distributes one to four values over the top, right, bottom and
+// left sides
+func expandBox(v string) ([]string, bool) {
+	parts := splitOutsideParens(v)
+	if len(parts) == 0 || len(parts) > 4 {
+		return nil, false
+	}
+	values := make([]string, 4)
+	for i := range boxSides {
+		values[i] = boxValue(v, i)
+	}
+	return values, true
+}
+
+// expandBorder splits a border shorthand into width, style and color,
+// defaulting to medium, none and currentcolor
+func expandBorder(v string) ([]string, bool) {
+	if len(splitOutsideParens(v)) > 3 {
+		return nil, false
+	}
+	width, style, color := splitBorder(v)
+	if width == "" {
+		width = "medium"
+	}
+	if style == "" {
+		style = "none"
+	}
+	if color == "" {
+		color = "currentcolor"
+	}
+	return []string{width, style, color}, true
+}
+
+// backgroundRepeats are the keywords of background-repeat
+var backgroundRepeats = map[string]bool{
+	"repeat": true, "repeat-x": true, "repeat-y": true, "no-repeat": true, "space": true, "round": true,
+}
+
+// backgroundBoxes are the keywords of background-origin and background-clip
+var backgroundBoxes = map[string]bool{"border-box": true, "padding-box": true, "content-box": true}
+
+// expandBackground expands a background shorthand. Layers are separated by
+// commas; each longhand gets one value per layer and only the last layer may
+// set the color.
+func expandBackground(v string) ([]string, bool) {
+	layers := splitLayers(v)
+	values := make([][]string, 7)
+	color := "transparent"
+	for i, layer := range layers {
+		image, repeat, attachment := "none", "", "scroll"
+		var position, size, boxes []string
+		inSize := false
+		for _, part := range splitOutsideParens(strings.ReplaceAll(layer, "/", " / ")) {
+			lower := strings.ToLower(part)
+			switch {
+			case lower == "/":
+				if len(position) == 0 || inSize {
+					return nil, false
+				}
+				inSize = true
+			case inSize && (lower == "auto" || lower == "cover" || lower == "contain" || isLengthValue(lower)):
+				size = append(size, lower)
+			case strings.HasPrefix(lower, "url(") || strings.HasSuffix(strings.SplitN(lower, "(", 2)[0], "gradient") || lower == "none":
+				image = part
+			case backgroundRepeats[lower]:
+				repeat = strings.TrimSpace(repeat + " " + lower)
+			case lower == "scroll" || lower == "fixed" || lower == "local":
+				attachment = lower
+			case backgroundBoxes[lower]:
+				boxes = append(boxes, lower)
+			case lower == "left" || lower == "right" || lower == "top" || lower == "bottom" || lower == "center" || isLengthValue(lower):
+				if inSize {
+					return nil, false
+				}
+				position = append(position, lower)
+			default:
+				if i != len(layers)-1 {
+					return nil, false
+				}
+				color = part
+			}
+		}
+		if repeat == "" {
+			repeat = "repeat"
+		}
+		pos := "0% 0%"
+		if len(position) > 0 {
+			pos = strings.Join(position, " ")
+		}
+		sz := "auto"
+		if len(size) > 0 {
+			sz = strings.Join(size, " ")
+		}
+		origin, clip := "padding-box", "border-box"
+		switch len(boxes) {
+		case 1:
+			origin, clip = boxes[0], boxes[0]
+		case 2:
+			origin, clip = boxes[0], boxes[1]
+		}
+		for j, val := range []string{image, repeat, pos, sz, attachment, origin, clip} {
+			values[j] = append(values[j], val)
+		}
+	}
+	out := []string{color}
+	for _, vals := range values {
+		out = append(out, strings.Join(vals, ", "))
+	}
+	return out, true
+}
+
+// splitLayers splits a value on the commas that are not inside parentheses
+func splitLayers(v string) []string {
+	var layers []string
+	depth, start := 0, 0
+	for i := 0; i < len(v); i++ {
+		switch v[i] {
+		case '(':
+			depth++
+		case ')':
+			if depth > 0 {
+				depth--
+			}
+		case ',':
+			if depth == 0 {
+				layers = append(layers, strings.TrimSpace(v[start:i]))
+				start = i + 1
+			}
+		}
+	}
+	return append(layers, strings.TrimSpace(v[start:]))
+}
+
+// isLengthValue reports whether v is a number, length, percentage or calc()
+// expression
+func isLengthValue(v string) bool {
+	if strings.HasPrefix(v, "calc(") {
+		return true
+	}
+	_, _, ok := SplitUnit(v)
+	return ok
+}
+
+// fontStretches are the keywords of font-stretch
+var fontStretches = map[string]bool{
+	"ultra-condensed": true, "extra-condensed": true, "condensed": true, "semi-condensed": true,
+	"semi-expanded": true, "expanded": true, "extra-expanded": true, "ultra-expanded": true,
+}
+
+// expandFont expands a font shorthand such as "italic bold 12pt/1.5 Georgia,
+// serif". The size and the family are required; system font keywords are
+// not supported.
+func expandFont(v string) ([]string, bool) {
+	style, variant, weight, stretch := "normal", "normal", "normal", "normal"
+	rest := v
+	for {
+		rest = strings.TrimLeft(rest, " \t\n\r\f")
+		if rest == "" {
+			return nil, false
+		}
+		end := strings.IndexAny(rest, " \t\n\r\f")
+		if end < 0 {
+			end = len(rest)
+		}
+		token := strings.ToLower(rest[:end])
+
+		// The size may be followed by "/line-height", with or without spaces
+		size, lineHeight, hasSlash := strings.Cut(token, "/")
+		if isFontSize(size) {
+			rest = rest[end:]
+			lineHeight = strings.TrimSpace(lineHeight)
+			if !hasSlash {
+				if t := strings.TrimLeft(rest, " \t\n\r\f"); strings.HasPrefix(t, "/") {
+					hasSlash, rest = true, t[1:]
+				}
+			}
+			if hasSlash && lineHeight == "" {
+				rest = strings.TrimLeft(rest, " \t\n\r\f")
+				end = strings.IndexAny(rest, " \t\n\r\f")
+				if end < 0 {
+					end = len(rest)
+				}
+				lineHeight, rest = strings.ToLower(rest[:end]), rest[end:]
+			}
+			if lineHeight == "" {
+				if hasSlash {
+					return nil, false
+				}
+				lineHeight = "normal"
+			}
+			family := strings.TrimSpace(rest)
+			if family == "" {
+				return nil, false
+			}
+			return []string{style, variant, weight, stretch, size, lineHeight, family}, true
+		}
+
+		switch {
+		case token == "normal":
+		case token == "italic" || token == "oblique":
+			style = token
+		case token == "small-caps":
+			variant = token
+		case token == "bold" || token == "bolder" || token == "lighter" || len(token) == 3 && token[1:] == "00" && token[0] >= '1' && token[0] <= '9':
+			weight = token
+		case fontStretches[token]:
+			stretch = token
+		default:
+			return nil, false
+		}
+		rest = rest[end:]
+	}
+}
+
+// isFontSize reports whether v is a font-size value
+func isFontSize(v string) bool {
+	if _, ok := fontSizeKeywords[v]; ok || v == "smaller" || v == "larger" {
+		return true
+	}
+	if strings.HasPrefix(v, "calc(") {
+		return true
+	}
+	n, unit, ok := SplitUnit(v)
+	// A unitless number other than zero is a weight, not a size
+	return ok && (unit != "" || n == 0)
+}
+
+// listStylePositions are the keywords of list-style-position
+var listStylePositions = map[string]bool{"inside": true, "outside": true}
+
+// expandListStyle expands a list-style shorthand. "none" sets the type, or
+// the image when the type is given as well.
+func expandListStyle(v string) ([]string, bool) {
+	typ, position, image := "", "", ""
+	nones := 0
+	for _, part := range splitOutsideParens(v) {
+		lower := strings.ToLower(part)
+		switch {
+		case lower == "none":
+			nones++
+		case listStylePositions[lower]:
+			if position != "" {
+				return nil, false
+			}
+			position = lower
+		case strings.HasPrefix(lower, "url(") || strings.HasSuffix(strings.SplitN(lower, "(", 2)[0], "gradient"):
+			if image != "" {
+				return nil, false
+			}
+			image = part
+		default:
+			if typ != "" {
+				return nil, false
+			}
+			typ = part
+		}
+	}
+	switch {
+	case nones > 2 || nones == 2 && (typ != "" || image != ""):
+		return nil, false
+	case nones == 2:
+		typ, image = "none", "none"
+	case nones == 1 && typ == "":
+		typ = "none"
+	case nones == 1:
+		if image != "" {
+			return nil, false
+		}
+		image = "none"
+	}
+	if typ == "" {
+		typ = "disc"
+	}
+	if position == "" {
+		position = "outside"
+	}
+	if image == "" {
+		image = "none"
+	}
+	return []string{typ, position, image}, true
+}
+
+// expandFlex expands a flex shorthand into grow, shrink and basis
+func expandFlex(v string) ([]string, bool) {
+	switch strings.ToLower(v) {
+	case "none":
+		return []string{"0", "0", "auto"}, true
+	case "auto":
+		return []string{"1", "1", "auto"}, true
+	}
+	grow, shrink, basis := "", "", ""
+	for _, part := range splitOutsideParens(v) {
+		lower := strings.ToLower(part)
+		n, unit, isNum := SplitUnit(lower)
+		switch {
+		case isNum && unit == "" && n >= 0 && grow == "":
+			grow = lower
+		case isNum && unit == "" && n >= 0 && shrink == "" && basis == "":
+			shrink = lower
+		case basis == "" && (lower == "auto" || lower == "content" || isLengthValue(lower)):
+			basis = lower
+		default:
+			return nil, false
+		}
+	}
+	// A flex with a grow factor but no basis starts from zero
+	if basis == "" {
+		basis = "0%"
+	}
+	if grow == "" {
+		grow = "1"
+	}
+	if shrink == "" {
+		shrink = "1"
+	}
+	return []string{grow, shrink, basis}, true
+}
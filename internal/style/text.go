@@ -0,0 +1,51 @@
+package style
+
+import (
+	"strings"
+	"unicode"
+)
+
+// TransformText applies a text-transform value to text. wordStart tells
+// whether text begins a word, so that capitalize leaves the first letter of
+// a run continuing a word from an earlier run alone.
+func TransformText(text, transform string, wordStart bool) string {
+	switch strings.ToLower(strings.TrimSpace(transform)) {
+	case "uppercase":
+		return strings.ToUpper(text)
+	case "lowercase":
+		return strings.ToLower(text)
+	case "capitalize":
+		runes := []rune(text)
+		for i, r := range runes {
+			if wordStart && unicode.IsLetter(r) {
+				runes[i] = unicode.ToTitle(r)
+			}
+			wordStart = unicode.IsSpace(r) || (wordStart && !unicode.IsLetter(r) && !unicode.IsDigit(r))
+		}
+		return string(runes)
+	}
+	return text
+}
+
+// LetterSpacing returns the extra space in points added after every
+// character; normal is 0
+func (cs ComputedStyle) LetterSpacing() float64 {
+	return cs.spacing("letter-spacing")
+}
+
+// WordSpacing returns the extra space in points added to every space
+// character; normal is 0
+func (cs ComputedStyle) WordSpacing() float64 {
+	return cs.spacing("word-spacing")
+}
+
+// spacing parses a letter-spacing or word-spacing value. Font relative
+// lengths have already been converted to points by the computed value stage.
+func (cs ComputedStyle) spacing(name string) float64 {
+	v := strings.ToLower(cs.value(name))
+	if v == "" || v == "normal" {
+		return 0
+	}
+	n, _ := AbsoluteLength(v)
+	return n
+}
@@ -0,0 +1,122 @@
+package style
+
+import (
+	"math"
+	"strconv"
+	"strings"
+)
+
+// absoluteUnits gives the number of points in one unit of each absolute CSS
+// length. Layout works in points and, as throughout the pipeline, a CSS pixel
+// is taken to be one point.
+var absoluteUnits = map[string]float64{
+	"":   1,
+	"px": 1,
+	"pt": 1,
+	"pc": 12,
+	"in": 72,
+	"cm": 72 / 2.54,
+	"mm": 72 / 25.4,
+	"q":  72 / 101.6,
+}
+
+// SplitUnit splits a CSS dimension such as "12.5mm" into its number and its
+// lower case unit. The unit is "%" for percentages and "" for bare numbers.
+func SplitUnit(v string) (float64, string, bool) {
+	v = strings.TrimSpace(v)
+	i := len(v)
+	for i > 0 && (isUnitLetter(v[i-1]) || v[i-1] == '%') {
+		i--
+	}
+	n, err := strconv.ParseFloat(v[:i], 64)
+	if err != nil {
+		return 0, "", false
+	}
+	return n, strings.ToLower(v[i:]), true
+}
+
+// AbsoluteLength converts a length in an absolute unit (px, pt, pc, in, cm,
+// mm or Q) or a bare number to points
+func AbsoluteLength(v string) (float64, bool) {
+	n, unit, ok := SplitUnit(v)
+	if !ok {
+		return 0, false
+	}
+	scale, ok := absoluteUnits[unit]
+	if !ok {
+		return 0, false
+	}
+	return n * scale, true
+}
+
+// SetViewport sets the size of the page area, the content box of the page,
+// that viewport units (vw, vh, vmin and vmax) resolve against. Without it
+// such lengths are left as written.
+func (e *StyleEngine) SetViewport(width, height float64) {
+	e.viewportWidth, e.viewportHeight = width, height
+}
+
+// absolutize replaces every dimension in v whose unit scale knows with the
+// equivalent length in points, including those inside calc() and shorthands.
+// scale returns 0 for units it leaves alone. Strings and url() are kept as is.
+func absolutize(v string, scale func(unit string) float64) string {
+	var b strings.Builder
+	for i := 0; i < len(v); {
+		if strings.HasPrefix(strings.ToLower(v[i:]), "url(") {
+			end := strings.IndexByte(v[i:], ')')
+			if end < 0 {
+				end = len(v) - i - 1
+			}
+			b.WriteString(v[i : i+end+1])
+			i += end + 1
+			continue
+		}
+		if q := v[i]; q == '"' || q == '\'' {
+			end := strings.IndexByte(v[i+1:], q)
+			if end < 0 {
+				end = len(v) - i - 2
+			}
+			b.WriteString(v[i : i+end+2])
+			i += end + 2
+			continue
+		}
+		// A number starts a dimension only when it does not continue an
+		// identifier or another number
+		c := v[i]
+		if !(c >= '0' && c <= '9' || c == '.') || i > 0 && (isUnitLetter(v[i-1]) || v[i-1] >= '0' && v[i-1] <= '9' || v[i-1] == '.' || v[i-1] == '#') {
+			b.WriteByte(c)
+			i++
+			continue
+		}
+		j := i
+		for j < len(v) && (v[j] >= '0' && v[j] <= '9' || v[j] == '.') {
+			j++
+		}
+		k := j
+		for k < len(v) && isUnitLetter(v[k]) {
+			k++
+		}
+		n, err := strconv.ParseFloat(v[i:j], 64)
+		f := 0.0
+		if err == nil && k > j {
+			f = scale(strings.ToLower(v[j:k]))
+		}
+		if f == 0 {
+			b.WriteString(v[i:k])
+		} else {
+			b.WriteString(formatPoints(n * f))
+		}
+		i = k
+	}
+	return b.String()
+}
+
+// formatPoints formats a length in points as a CSS value
+func formatPoints(n float64) string {
+	return strconv.FormatFloat(math.Round(n*1e4)/1e4, 'f', -1, 64) + "pt"
+}
+
+// isUnitLetter reports whether c can be part of a unit name
+func isUnitLetter(c byte) bool {
+	return c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z'
+}
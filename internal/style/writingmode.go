@@ -0,0 +1,82 @@
+package style
+
+import (
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// WritingMode returns the writing-mode of a style: horizontal-tb,
+// vertical-rl or vertical-lr. sideways-rl and sideways-lr are set as
+// vertical-rl and vertical-lr with all their text sideways.
+func (cs ComputedStyle) WritingMode() string {
+	switch v := strings.ToLower(strings.TrimSpace(cs["writing-mode"].Value)); v {
+	case "vertical-rl", "vertical-lr":
+		return v
+	case "sideways-rl", "tb-rl":
+		return "vertical-rl"
+	case "sideways-lr", "tb":
+		return "vertical-lr"
+	}
+	return "horizontal-tb"
+}
+
+// VerticalWritingMode reports whether the lines of a style run from top
+// to bottom
+func (cs ComputedStyle) VerticalWritingMode() bool {
+	return cs.WritingMode() != "horizontal-tb"
+}
+
+// Upright reports whether a character of text set vertically in a style
+// stands upright rather than being turned sideways along the line: every
+// character for text-orientation: upright, none for sideways, and for
+// mixed, the default, the characters of East Asian scripts and fullwidth
+// forms
+func (cs ComputedStyle) Upright(r rune) bool {
+	mode := strings.ToLower(strings.TrimSpace(cs["writing-mode"].Value))
+	if strings.HasPrefix(mode, "sideways") {
+		return false
+	}
+	switch strings.ToLower(strings.TrimSpace(cs["text-orientation"].Value)) {
+	case "upright":
+		return !unicode.IsSpace(r)
+	case "sideways", "sideways-right":
+		return false
+	}
+	switch {
+	case unicode.In(r, unicode.Han, unicode.Hiragana, unicode.Katakana, unicode.Hangul, unicode.Bopomofo):
+		return true
+	case r >= 0x3000 && r <= 0x303f, r >= 0xff01 && r <= 0xff60, r >= 0xffe0 && r <= 0xffe6:
+		// CJK symbols and punctuation and fullwidth forms
+		return r != 0x3000
+	}
+	return false
+}
+
+// VerticalSegment is a piece of text set vertically: a run of characters
+// turned sideways, or a single upright character
+type VerticalSegment struct {
+	Text    string
+	Upright bool
+}
+
+// VerticalSegments splits text set vertically in a style into its sideways
+// runs and upright characters
+func (cs ComputedStyle) VerticalSegments(s string) []VerticalSegment {
+	var segs []VerticalSegment
+	start := 0
+	for i, r := range s {
+		if !cs.Upright(r) {
+			continue
+		}
+		if i > start {
+			segs = append(segs, VerticalSegment{Text: s[start:i]})
+		}
+		start = i + utf8.RuneLen(r)
+		segs = append(segs, VerticalSegment{Text: s[i:start], Upright: true})
+	}
+	if start < len(s) {
+		segs = append(segs, VerticalSegment{Text: s[start:]})
+	}
+	return segs
+}
@@ -1,5 +1,11 @@
 package text
 
+import (
+	"strings"
+
+	"golang.org/x/text/unicode/bidi"
+)
+
 // Direction represents text direction
 type Direction int
 
@@ -18,7 +24,8 @@ type BidiParagraph struct {
 	Runs      []BidiRun
 }
 
-// BidiRun represents a run of text with the same direction
+// BidiRun represents a run of text with the same direction. Start and Length
+// are byte offsets into the paragraph text.
 type BidiRun struct {
 	Start     int
 	Length    int
@@ -27,48 +34,258 @@ type BidiRun struct {
 	Level     uint8
 }
 
+// lrm and rlm are the invisible marks prepended to a paragraph to force its
+// base direction
+const (
+	lrm = "\u200e"
+	rlm = "\u200f"
+)
+
 // NewBidiProcessor creates a new bidirectional text processor
 func NewBidiProcessor() *BidiProcessor {
 	return &BidiProcessor{}
 }
 
-// Process processes bidirectional text
+// Process runs the Unicode bidirectional algorithm over text, taking the
+// paragraph direction from its first strong character. The runs are in
+// logical order.
 func (p *BidiProcessor) Process(text string) *BidiParagraph {
-	paragraph := &BidiParagraph{
-		Text:      text,
-		Direction: LeftToRight, // Default to LTR
-		Runs:      []BidiRun{},
+	return p.process(text, p.baseDirection(text))
+}
+
+// ProcessDirection runs the Unicode bidirectional algorithm over text with
+// the given paragraph direction, as set by the CSS direction property
+func (p *BidiProcessor) ProcessDirection(text string, dir Direction) *BidiParagraph {
+	return p.process(text, dir)
+}
+
+func (p *BidiProcessor) process(text string, dir Direction) *BidiParagraph {
+	paragraph := &BidiParagraph{Text: text, Direction: dir}
+	if text == "" {
+		return paragraph
 	}
 
-	paragraph.Runs = append(paragraph.Runs, BidiRun{
-		Start:     0,
-		Length:    len(text),
-		Text:      text,
-		Direction: LeftToRight,
-		Level:     0,
-	})
+	// The bidi package picks the paragraph direction from the first strong
+	// character, so a mark of the wanted direction is put in front
+	mark := lrm
+	if dir == RightToLeft {
+		mark = rlm
+	}
+	var para bidi.Paragraph
+	if _, err := para.SetString(mark + strings.ReplaceAll(text, "\n", " ")); err != nil {
+		return p.singleRun(paragraph)
+	}
+	ordering, err := para.Order()
+	if err != nil {
+		return p.singleRun(paragraph)
+	}
 
+	// Run positions count runes, which are turned into byte offsets
+	runes := []rune(text)
+	offsets := make([]int, len(runes)+1)
+	for i, r := range runes {
+		offsets[i+1] = offsets[i] + len(string(r))
+	}
+	for i := 0; i < ordering.NumRuns(); i++ {
+		run := ordering.Run(i)
+		// Positions count the mark and the end is inclusive, so end is
+		// already the exclusive end in text
+		start, end := run.Pos()
+		start--
+		if start < 0 {
+			start = 0
+		}
+		if end <= start {
+			continue
+		}
+		runDir := LeftToRight
+		if run.Direction() == bidi.RightToLeft {
+			runDir = RightToLeft
+		}
+		paragraph.Runs = append(paragraph.Runs, BidiRun{
+			Start:     offsets[start],
+			Length:    offsets[end] - offsets[start],
+			Text:      text[offsets[start]:offsets[end]],
+			Direction: runDir,
+		})
+	}
+	p.assignLevels(paragraph)
+	return paragraph
+}
+
+// singleRun makes the paragraph one run in its own direction, for text the
+// bidi package cannot process
+func (p *BidiProcessor) singleRun(paragraph *BidiParagraph) *BidiParagraph {
+	level := uint8(0)
+	if paragraph.Direction == RightToLeft {
+		level = 1
+	}
+	paragraph.Runs = []BidiRun{{
+		Length:    len(paragraph.Text),
+		Text:      paragraph.Text,
+		Direction: paragraph.Direction,
+		Level:     level,
+	}}
 	return paragraph
 }
 
+// assignLevels gives every run its embedding level. The bidi package only
+// reports directions: runs in the paragraph direction are at its level and
+// the others one above, except numbers between right-to-left runs of a
+// left-to-right paragraph, which nest inside them at level 2.
+func (p *BidiProcessor) assignLevels(paragraph *BidiParagraph) {
+	base := uint8(0)
+	if paragraph.Direction == RightToLeft {
+		base = 1
+	}
+	runs := paragraph.Runs
+	for i := range runs {
+		switch {
+		case runs[i].Direction == paragraph.Direction:
+			runs[i].Level = base
+		default:
+			runs[i].Level = base + 1
+		}
+	}
+	if base == 1 {
+		return
+	}
+	for i := 1; i < len(runs)-1; i++ {
+		if runs[i-1].Direction == RightToLeft && runs[i+1].Direction == RightToLeft && !hasStrongLTR(runs[i].Text) {
+			runs[i].Level = 2
+		}
+	}
+}
+
+// hasStrongLTR reports whether text contains a strong left-to-right character
+func hasStrongLTR(text string) bool {
+	for _, r := range text {
+		if props, _ := bidi.LookupRune(r); props.Class() == bidi.L {
+			return true
+		}
+	}
+	return false
+}
+
+// baseDirection returns the direction of the first strong character of
+// text, or left-to-right when there is none
+func (p *BidiProcessor) baseDirection(text string) Direction {
+	return DirectionOf(text)
+}
+
+// DirectionOf returns the direction of the first strong character of text,
+// or left-to-right when there is none
+func DirectionOf(text string) Direction {
+	for _, r := range text {
+		props, _ := bidi.LookupRune(r)
+		switch props.Class() {
+		case bidi.L:
+			return LeftToRight
+		case bidi.R, bidi.AL:
+			return RightToLeft
+		}
+	}
+	return LeftToRight
+}
+
 // IsRTL checks if a string contains right-to-left text
-// This is a simplified implementation that only checks for Arabic and Hebrew ranges
 func (p *BidiProcessor) IsRTL(text string) bool {
 	for _, r := range text {
-		// Check for Arabic (0x0600-0x06FF) or Hebrew (0x0590-0x05FF) characters
-		if (r >= 0x0590 && r <= 0x06FF) || (r >= 0xFB50 && r <= 0xFDFF) || (r >= 0xFE70 && r <= 0xFEFF) {
+		props, _ := bidi.LookupRune(r)
+		if c := props.Class(); c == bidi.R || c == bidi.AL {
 			return true
 		}
 	}
 	return false
 }
 
-// GetDisplayText returns the text in display order
+// GetDisplayText returns the text in display order: runs are reordered by
+// level and right-to-left runs reversed, with mirrored brackets
 func (p *BidiProcessor) GetDisplayText(paragraph *BidiParagraph) string {
-	return paragraph.Text
+	levels := make([]uint8, len(paragraph.Runs))
+	for i, run := range paragraph.Runs {
+		levels[i] = run.Level
+	}
+	var sb strings.Builder
+	for _, i := range VisualOrder(levels) {
+		run := paragraph.Runs[i]
+		if run.Level%2 == 1 {
+			sb.WriteString(ReverseText(run.Text))
+		} else {
+			sb.WriteString(run.Text)
+		}
+	}
+	return sb.String()
 }
 
-// SplitMixedDirectionText splits text with mixed directions into separate runs
+// SplitMixedDirectionText splits text with mixed directions into separate
+// runs, in logical order
 func (p *BidiProcessor) SplitMixedDirectionText(text string) []string {
-	return []string{text}
+	paragraph := p.Process(text)
+	parts := make([]string, 0, len(paragraph.Runs))
+	for _, run := range paragraph.Runs {
+		parts = append(parts, run.Text)
+	}
+	if len(parts) == 0 {
+		return []string{text}
+	}
+	return parts
+}
+
+// VisualOrder returns the order in which items at the given embedding levels
+// are displayed from left to right (rule L2 of the bidi algorithm): every
+// sequence at or above a level is reversed, from the highest level down to
+// the lowest odd one
+func VisualOrder(levels []uint8) []int {
+	order := make([]int, len(levels))
+	var highest, lowestOdd uint8 = 0, 255
+	for i, l := range levels {
+		order[i] = i
+		if l > highest {
+			highest = l
+		}
+		if l%2 == 1 && l < lowestOdd {
+			lowestOdd = l
+		}
+	}
+	for level := highest; level >= lowestOdd && level > 0; level-- {
+		for i := 0; i < len(order); {
+			if levels[order[i]] < level {
+				i++
+				continue
+			}
+			j := i
+			for j < len(order) && levels[order[j]] >= level {
+				j++
+			}
+			for a, b := i, j-1; a < b; a, b = a+1, b-1 {
+				order[a], order[b] = order[b], order[a]
+			}
+			i = j
+		}
+	}
+	return order
+}
+
+// ReverseText reverses right-to-left text for drawing with a left-to-right
+// glyph pipeline. Combining marks stay after their base characters and
+// brackets are swapped for their mirrored counterparts.
+func ReverseText(text string) string {
+	var clusters []string
+	for _, r := range text {
+		props, _ := bidi.LookupRune(r)
+		switch {
+		case props.Class() == bidi.NSM && len(clusters) > 0:
+			clusters[len(clusters)-1] += string(r)
+		case props.IsBracket():
+			clusters = append(clusters, bidi.ReverseString(string(r)))
+		default:
+			clusters = append(clusters, string(r))
+		}
+	}
+	var sb strings.Builder
+	for i := len(clusters) - 1; i >= 0; i-- {
+		sb.WriteString(clusters[i])
+	}
+	return sb.String()
 }
@@ -1,12 +1,35 @@
 package text
 
 import (
+	"bytes"
+	"math"
+	"strings"
+	"sync"
 	"unicode"
+
+	"github.com/go-text/typesetting/di"
+	"github.com/go-text/typesetting/font"
+	"github.com/go-text/typesetting/language"
+	"github.com/go-text/typesetting/shaping"
+	"golang.org/x/image/math/fixed"
 )
 
-// TextShaper handles text shaping operations
+// TextShaper shapes text with the HarfBuzz algorithm: Arabic joining, Indic
+// reordering, ligatures, kerning and mark positioning all come from the
+// OpenType tables of the font. Fonts without data fall back to an
+// approximation of 0.6em per character.
 type TextShaper struct {
-	// Configuration options could be added here
+	mu     sync.Mutex
+	shaper shaping.HarfbuzzShaper
+	// faces caches parsed fonts by the address of their data
+	faces map[*byte]*shapingFace
+}
+
+// shapingFace is a parsed font with the reverse of its character map
+type shapingFace struct {
+	face *font.Face
+	// runes maps glyphs back to the characters drawing them
+	runes map[font.GID]rune
 }
 
 // ShapedText represents shaped text ready for rendering
@@ -20,7 +43,9 @@ type ShapedText struct {
 	LineGap float64
 }
 
-// Glyph represents a single glyph in shaped text
+// Glyph represents a single glyph in shaped text. X and Y are the position
+// of its origin, offsets included, with Y growing downwards from the top of
+// the first line.
 type Glyph struct {
 	Rune    rune
 	Index   uint16
@@ -29,6 +54,12 @@ type Glyph struct {
 	Width   float64
 	Height  float64
 	Advance float64
+	// Text is the text that draws the glyph with a font mapping characters
+	// to glyphs: the character the font maps to it, such as an Arabic
+	// presentation form or a ligature, or for a glyph no character maps to
+	// the source text of its cluster on the first glyph of the cluster.
+	// It is empty for the other glyphs of such a cluster.
+	Text string
 }
 
 // Font represents a font used for text shaping
@@ -38,136 +69,216 @@ type Font struct {
 	Weight     int
 	Size       float64
 	LineHeight float64
+	// Data holds the TrueType (sfnt) bytes of the font; without it text
+	// is approximated
+	Data []byte
 }
 
 // NewTextShaper creates a new text shaper
 func NewTextShaper() *TextShaper {
-	return &TextShaper{}
+	return &TextShaper{faces: make(map[*byte]*shapingFace)}
 }
 
-// ShapeText shapes text for rendering
-func (s *TextShaper) ShapeText(text string, font *Font, maxWidth float64) *ShapedText {
-	shaped := &ShapedText{
-		Text:   text,
-		Glyphs: make([]Glyph, 0, len(text)),
+// Shape shapes a single line of text in the given direction. The glyphs of
+// right-to-left text are returned in visual order, from left to right.
+func (s *TextShaper) Shape(text string, f *Font, dir Direction) *ShapedText {
+	ascent, descent := f.Size*0.8, f.Size*0.2
+	shaped := &ShapedText{Text: text, Height: ascent + descent, Ascent: ascent, Descent: descent}
+	if text == "" {
+		return shaped
+	}
+	sf := s.face(f.Data)
+	if sf == nil {
+		return s.approximate(text, f)
+	}
+	runes := []rune(text)
+	input := shaping.Input{
+		Text:      runes,
+		RunStart:  0,
+		RunEnd:    len(runes),
+		Direction: di.DirectionLTR,
+		Face:      sf.face,
+		Size:      fixed.Int26_6(math.Round(f.Size * 64)),
+		Script:    scriptOf(runes),
+	}
+	if dir == RightToLeft {
+		input.Direction = di.DirectionRTL
 	}
 
-	charWidth := font.Size * 0.6 // Approximate width for monospace
-	lineHeight := font.Size * font.LineHeight
-	ascent := font.Size * 0.8
-	descent := font.Size * 0.2
-
-	x := 0.0
-	y := ascent
-
-	for _, r := range text {
-		if r == '\n' {
-			x = 0
-			y += lineHeight
-			continue
-		}
+	s.mu.Lock()
+	out := s.shaper.Shape(input)
+	s.mu.Unlock()
 
-		if unicode.IsSpace(r) {
-			x += charWidth
-			continue
-		}
+	if ext, ok := sf.face.FontHExtents(); ok {
+		scale := f.Size / float64(sf.face.Upem())
+		shaped.Ascent = float64(ext.Ascender) * scale
+		shaped.Descent = -float64(ext.Descender) * scale
+		shaped.LineGap = float64(ext.LineGap) * scale
+		shaped.Height = shaped.Ascent + shaped.Descent
+	}
 
+	x := 0.0
+	lastCluster := -1
+	for _, g := range out.Glyphs {
 		glyph := Glyph{
-			Rune:    r,
-			Index:   0, // In a real implementation, this would be the glyph index
-			X:       x,
-			Y:       y,
-			Width:   charWidth,
-			Height:  font.Size,
-			Advance: charWidth,
+			Index:   uint16(g.GlyphID),
+			X:       x + fromFixed(g.XOffset),
+			Y:       shaped.Ascent - fromFixed(g.YOffset),
+			Width:   fromFixed(g.Width),
+			Height:  -fromFixed(g.Height),
+			Advance: fromFixed(g.XAdvance),
 		}
-
+		if r, ok := sf.runes[g.GlyphID]; ok {
+			glyph.Rune, glyph.Text = r, string(r)
+		} else if g.ClusterIndex != lastCluster && g.ClusterIndex+g.RuneCount <= len(runes) {
+			cluster := runes[g.ClusterIndex : g.ClusterIndex+g.RuneCount]
+			if dir == RightToLeft {
+				glyph.Text = ReverseText(string(cluster))
+			} else {
+				glyph.Text = string(cluster)
+			}
+			glyph.Rune = cluster[0]
+		}
+		lastCluster = g.ClusterIndex
 		shaped.Glyphs = append(shaped.Glyphs, glyph)
+		x += glyph.Advance
+	}
+	shaped.Width = x
+	return shaped
+}
 
-		x += charWidth
-
-		if maxWidth > 0 && x > maxWidth {
-			x = 0
+// ShapeText shapes text for rendering, breaking lines at newlines and where
+// a line would grow wider than maxWidth, if it is positive
+func (s *TextShaper) ShapeText(text string, font *Font, maxWidth float64) *ShapedText {
+	shaped := &ShapedText{Text: text}
+	lineHeight := font.Size * font.LineHeight
+	y := 0.0
+	for i, line := range s.SplitTextToLines(text, font, maxWidth) {
+		if i > 0 {
 			y += lineHeight
 		}
+		shapedLine := s.Shape(line, font, DirectionOf(line))
+		if i == 0 {
+			shaped.Ascent, shaped.Descent = shapedLine.Ascent, shapedLine.Descent
+			shaped.LineGap = lineHeight - (shapedLine.Ascent + shapedLine.Descent)
+		}
+		for _, g := range shapedLine.Glyphs {
+			g.Y += y
+			shaped.Glyphs = append(shaped.Glyphs, g)
+		}
+		shaped.Width = math.Max(shaped.Width, shapedLine.Width)
+	}
+	shaped.Height = y + shaped.Ascent + shaped.Descent
+	if maxWidth > 0 {
+		shaped.Width = maxWidth
 	}
-
-	shaped.Width = maxWidth
-	shaped.Height = y + descent
-	shaped.Ascent = ascent
-	shaped.Descent = descent
-	shaped.LineGap = lineHeight - (ascent + descent)
-
 	return shaped
 }
 
-// MeasureText measures text without shaping it
+// MeasureText measures the width of the widest line of text and the height
+// of its lines
 func (s *TextShaper) MeasureText(text string, font *Font) (width, height float64) {
-	// This is a simplified implementation
-	// In a real implementation, we would use a proper text measurement library
-
-	charWidth := font.Size * 0.6 // Approximate width for monospace
-	lineHeight := font.Size * font.LineHeight
-
-	maxWidth := 0.0
-	currentWidth := 0.0
-	lines := 1
+	lines := strings.Split(text, "\n")
+	for _, line := range lines {
+		width = max(width, s.Shape(line, font, DirectionOf(line)).Width)
+	}
+	return width, float64(len(lines)) * font.Size * font.LineHeight
+}
 
-	for _, r := range text {
-		if r == '\n' {
-			maxWidth = max(maxWidth, currentWidth)
-			currentWidth = 0
-			lines++
+// SplitTextToLines splits text into lines at newlines and between words
+// where a line would grow wider than maxWidth, if it is positive
+func (s *TextShaper) SplitTextToLines(text string, font *Font, maxWidth float64) []string {
+	var lines []string
+	for _, paragraph := range strings.Split(text, "\n") {
+		if maxWidth <= 0 {
+			lines = append(lines, paragraph)
 			continue
 		}
-
-		currentWidth += charWidth
+		var currentLine string
+		for _, word := range splitIntoWords(paragraph) {
+			candidate := word
+			if currentLine != "" {
+				candidate = currentLine + " " + word
+			}
+			if currentLine != "" && s.Shape(candidate, font, DirectionOf(candidate)).Width > maxWidth {
+				lines = append(lines, currentLine)
+				currentLine = word
+			} else {
+				currentLine = candidate
+			}
+		}
+		lines = append(lines, currentLine)
 	}
-
-	maxWidth = max(maxWidth, currentWidth)
-	height = float64(lines) * lineHeight
-
-	return maxWidth, height
+	return lines
 }
 
-// SplitTextToLines splits text into lines based on a maximum width
-func (s *TextShaper) SplitTextToLines(text string, font *Font, maxWidth float64) []string {
-	// This is a simplified implementation
-	// In a real implementation, you would use a proper line breaking algorithm
-
-	if maxWidth <= 0 {
-		return []string{text}
+// face parses font data once, returning nil for missing or invalid data
+func (s *TextShaper) face(data []byte) *shapingFace {
+	if len(data) == 0 {
+		return nil
 	}
-
-	charWidth := font.Size * 0.6 // Approximate width for monospace
-	charsPerLine := int(maxWidth / charWidth)
-
-	if charsPerLine <= 0 {
-		charsPerLine = 1
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if sf, ok := s.faces[&data[0]]; ok {
+		return sf
 	}
+	face, err := font.ParseTTF(bytes.NewReader(data))
+	if err != nil {
+		s.faces[&data[0]] = nil
+		return nil
+	}
+	// Glyphs mapped from several characters draw with the first of them,
+	// which prefers base letters over their presentation forms
+	runes := make(map[font.GID]rune)
+	for it := face.Cmap.Iter(); it.Next(); {
+		r, gid := it.Char()
+		if prev, ok := runes[gid]; !ok || r < prev {
+			runes[gid] = r
+		}
+	}
+	sf := &shapingFace{face: face, runes: runes}
+	s.faces[&data[0]] = sf
+	return sf
+}
 
-	var lines []string
-	var currentLine string
-
-	words := splitIntoWords(text)
-
-	for _, word := range words {
-		if len(currentLine)+len(word)+1 > charsPerLine && currentLine != "" {
-			lines = append(lines, currentLine)
-			currentLine = word
-		} else {
-			if currentLine != "" {
-				currentLine += " "
-			}
-			currentLine += word
+// approximate lays text out at 0.6em per character, for fonts that cannot
+// be shaped
+func (s *TextShaper) approximate(text string, f *Font) *ShapedText {
+	charWidth := f.Size * 0.6
+	shaped := &ShapedText{Text: text, Ascent: f.Size * 0.8, Descent: f.Size * 0.2, Height: f.Size}
+	x := 0.0
+	for _, r := range text {
+		if !unicode.IsSpace(r) {
+			shaped.Glyphs = append(shaped.Glyphs, Glyph{
+				Rune:    r,
+				X:       x,
+				Y:       shaped.Ascent,
+				Width:   charWidth,
+				Height:  f.Size,
+				Advance: charWidth,
+				Text:    string(r),
+			})
 		}
+		x += charWidth
 	}
+	shaped.Width = x
+	return shaped
+}
 
-	if currentLine != "" {
-		lines = append(lines, currentLine)
+// scriptOf returns the script of the first character of text that belongs
+// to a specific one, which selects the shaping rules
+func scriptOf(runes []rune) language.Script {
+	for _, r := range runes {
+		if sc := language.LookupScript(r); sc != language.Common && sc != language.Inherited && sc != language.Unknown {
+			return sc
+		}
 	}
+	return language.Latin
+}
 
-	return lines
+// fromFixed converts a 26.6 fixed point value to points
+func fromFixed(v fixed.Int26_6) float64 {
+	return float64(v) / 64
 }
 
 // splitIntoWords splits text into words
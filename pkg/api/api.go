@@ -2,12 +2,19 @@ package api
 
 import (
 	"bytes"
+	"context"
 	"fmt"
 	"io"
+	"io/fs"
 	"os"
+	"slices"
+	"strconv"
 	"strings"
+	"time"
 
+	"github.com/gompdf/gompdf/internal/fonts"
 	"github.com/gompdf/gompdf/internal/layout"
+	"github.com/gompdf/gompdf/internal/logging"
 	"github.com/gompdf/gompdf/internal/pagination"
 	"github.com/gompdf/gompdf/internal/parser/css"
 	"github.com/gompdf/gompdf/internal/parser/html"
@@ -17,10 +24,19 @@ import (
 	xhtml "golang.org/x/net/html"
 )
 
-// Converter is the main API for converting HTML to PDF
+// Converter is the main API for converting HTML to PDF. A Converter is safe
+// for concurrent use: its methods never change it, and methods configuring
+// it return a new one. Conversions with the same Converter share the parsed
+// user agent stylesheet, the fonts of the font directories and the widths
+// of measured text, so a server should reuse one Converter for its requests.
 type Converter struct {
 	options Options
-	loader  *res.Loader
+	// cache holds what the conversions of the converter share
+	cache *converterCache
+	// loader and warnings are set on the copy of the converter a conversion
+	// runs with; see conversion
+	loader   *res.Loader
+	warnings *logging.Collector
 }
 
 // New creates a new HTML to PDF converter with default options
@@ -32,12 +48,90 @@ func New() *Converter {
 func NewWithOptions(options Options) *Converter {
 	return &Converter{
 		options: options,
-		loader:  res.NewLoader(""),
+		cache:   newConverterCache(),
 	}
 }
 
+// conversion returns a copy of the converter for a single conversion,
+// loading resources relative to base and collecting the conversion's
+// warnings, so that conversions running at the same time share nothing but
+// the cache. A converter that is already a conversion's copy is returned
+// unchanged.
+func (c *Converter) conversion(ctx context.Context, base string) *Converter {
+	if c.loader != nil {
+		return c
+	}
+	conv := *c
+	conv.loader = c.newLoader(ctx, base)
+	conv.warnings = &logging.Collector{Next: c.hostLogger()}
+	return &conv
+}
+
+// newLoader creates a loader of the resources of a document at base for a
+// conversion bounded by ctx
+func (c *Converter) newLoader(ctx context.Context, base string) *res.Loader {
+	loader := res.NewLoader(base)
+	loader.SetContext(ctx)
+	loader.SetTimeout(c.options.ResourceTimeout)
+	loader.SetCache(c.options.ResourceCache)
+	loader.SetClient(c.cache.httpClient(&c.options))
+	loader.SetPolicy(c.options.ResourcePolicy)
+	loader.SetFS(c.options.ResourceFS)
+	loader.SetFetchers(c.options.Fetchers)
+	loader.SetResolver(c.options.ResolveURL)
+	loader.SetHeaders(c.options.HTTPHeaders)
+	loader.SetCookies(c.options.Cookies)
+	for _, path := range c.options.ResourcePaths {
+		loader.AddSearchPath(path)
+	}
+	return loader
+}
+
+// readFile reads the input file at path from the ResourceFS of the options,
+// or else from the file system
+func (c *Converter) readFile(path string) ([]byte, error) {
+	if c.options.ResourceFS != nil {
+		return fs.ReadFile(c.options.ResourceFS, res.FSPath(path))
+	}
+	return os.ReadFile(path)
+}
+
+// logger returns the logger that receives the conversion's diagnostics. In
+// debug mode without a configured Logger, messages go to standard error.
+func (c *Converter) logger() logging.Logger {
+	if c.warnings != nil {
+		return c.warnings
+	}
+	return c.hostLogger()
+}
+
+// hostLogger returns the logger the options configure
+func (c *Converter) hostLogger() logging.Logger {
+	switch {
+	case c.options.Logger != nil:
+		return c.options.Logger
+	case c.options.Debug:
+		return logging.NewWriter(os.Stderr)
+	}
+	return logging.Discard
+}
+
 // Convert converts HTML to PDF and writes the result to the specified writer
 func (c *Converter) Convert(htmlContent string, output io.Writer) error {
+	return c.ConvertContext(context.Background(), htmlContent, output)
+}
+
+// ConvertContext is like Convert but stops with the context's error when the
+// context is cancelled or its deadline passes
+func (c *Converter) ConvertContext(ctx context.Context, htmlContent string, output io.Writer) error {
+	return writeThroughFile(output, func(path string) error {
+		return c.ConvertToFileContext(ctx, htmlContent, path)
+	})
+}
+
+// writeThroughFile has convert write a PDF to a temporary file and copies it
+// to output
+func writeThroughFile(output io.Writer, convert func(path string) error) error {
 	tempFile, err := os.CreateTemp("", "gompdf-*.pdf")
 	if err != nil {
 		return fmt.Errorf("failed to create temporary file: %w", err)
@@ -45,7 +139,7 @@ func (c *Converter) Convert(htmlContent string, output io.Writer) error {
 	defer os.Remove(tempFile.Name())
 	defer tempFile.Close()
 
-	err = c.ConvertToFile(htmlContent, tempFile.Name())
+	err = convert(tempFile.Name())
 	if err != nil {
 		return err
 	}
@@ -65,92 +159,447 @@ func (c *Converter) Convert(htmlContent string, output io.Writer) error {
 
 // ConvertToFile converts HTML to PDF and writes the result to the specified file
 func (c *Converter) ConvertToFile(htmlContent, outputPath string) error {
-	if c.loader == nil {
-		c.loader = res.NewLoader("")
-	}
-	for _, path := range c.options.ResourcePaths {
-		c.loader.AddSearchPath(path)
+	return c.ConvertToFileContext(context.Background(), htmlContent, outputPath)
+}
+
+// ConvertToFileContext is like ConvertToFile but threads the context through
+// resource loading, layout and rendering so the conversion can be cancelled
+func (c *Converter) ConvertToFileContext(ctx context.Context, htmlContent, outputPath string) error {
+	_, err := c.ConvertToFileWithResultContext(ctx, htmlContent, outputPath)
+	return err
+}
+
+// convertDocument converts a parsed HTML document to a PDF file, loading its
+// resources with the converter's loader, and reports on the result
+func (c *Converter) convertDocument(ctx context.Context, doc *html.Document, outputPath string) (*Result, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
 	}
+	c = c.conversion(ctx, "")
 
-	htmlParser := html.NewParser()
-	doc, err := htmlParser.Parse(strings.NewReader(htmlContent))
+	fontRegistry := c.newFontRegistry()
+	if c.options.Streaming {
+		return c.convertStreaming(ctx, doc, fontRegistry, outputPath)
+	}
+	pages, err := c.paginateDocument(ctx, doc, c.loader, fontRegistry)
 	if err != nil {
-		return fmt.Errorf("failed to parse HTML: %w", err)
+		return nil, err
+	}
+	// The renderer releases the pages as it draws them
+	result := newResult()
+	for _, page := range pages {
+		result.addPage(page)
+	}
+	if err := c.render(ctx, pages, nil, fontRegistry, outputPath); err != nil {
+		return nil, err
 	}
+	result.Warnings = c.warnings.Warnings()
+	return result, nil
+}
 
-	cssParser := css.NewParser()
-	uaStylesheet, err := cssParser.ParseString(c.options.UserAgentStylesheet)
+// convertStreaming converts a parsed HTML document as convertDocument does,
+// drawing every page as soon as it is cut so that the boxes of only a few
+// pages are held at a time. The pages of documents whose generated content
+// refers to pages yet to come are counted by laying them out once before.
+func (c *Converter) convertStreaming(ctx context.Context, doc *html.Document, fontRegistry *fonts.Registry, outputPath string) (*Result, error) {
+	d, err := c.prepareDocument(ctx, doc, c.loader, fontRegistry)
 	if err != nil {
-		return fmt.Errorf("failed to parse CSS: %w", err)
+		return nil, err
+	}
+	pageCount, targets := 0, map[string]*pagination.Page{}
+	if hasDocumentCounters(doc.Root) {
+		// The pages are dropped as they are counted and the diagnostics of
+		// the layout left to the one that counts
+		d.layout.SetLogger(logging.Discard)
+		err := d.stream(doc, func(page *pagination.Page) error {
+			pageCount++
+			for id, p := range pagination.Targets([]*pagination.Page{page}) {
+				if _, ok := targets[id]; !ok {
+					targets[id] = p
+				}
+			}
+			page.Boxes = nil
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+		d.layout.SetLogger(c.logger())
 	}
 
-	styleEngine := style.NewStyleEngine()
-	styleEngine.AddStylesheet(uaStylesheet)
+	renderer, renderOptions := c.newRenderer(nil, fontRegistry)
+	if err := renderer.Begin(ctx, renderOptions, pageCount, targets); err != nil {
+		return nil, fmt.Errorf("failed to render PDF: %w", err)
+	}
+	result := newResult()
+	err = d.stream(doc, func(page *pagination.Page) error {
+		result.addPage(page)
+		if err := renderer.RenderPage(page); err != nil {
+			return fmt.Errorf("failed to render PDF: %w", err)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	if err := c.options.Hooks.laidOut(result.Pages); err != nil {
+		return nil, err
+	}
+	if err := renderer.End(outputPath); err != nil {
+		return nil, fmt.Errorf("failed to render PDF: %w", err)
+	}
+	result.Warnings = c.warnings.Warnings()
+	return result, nil
+}
 
-	for _, cssText := range collectDocumentStylesheets(doc.Root, c.loader, c.options.Debug) {
-		if sheet, parseErr := cssParser.ParseString(cssText); parseErr == nil {
-			styleEngine.AddStylesheet(sheet)
-		} else if c.options.Debug {
-			fmt.Printf("Failed to parse stylesheet: %v\n", parseErr)
+// hasDocumentCounters reports whether the generated content of a document
+// shows the page count or the page of an element
+func hasDocumentCounters(n *html.Node) bool {
+	if n.Type == xhtml.TextNode && style.HasDocumentCounters(n.Data) {
+		return true
+	}
+	for c := n.FirstChild; c != nil; c = c.NextSibling {
+		if hasDocumentCounters(c) {
+			return true
 		}
 	}
-	computedStyles := styleEngine.ComputeStyles(doc) // Compute styles and use the result
+	return false
+}
 
-	pageWidth := c.options.PageWidth
-	pageHeight := c.options.PageHeight
+// newFontRegistry returns a registry of the fonts of the font directories
+// with the fallbacks of the options, for a conversion to add the fonts of
+// its @font-face rules to
+func (c *Converter) newFontRegistry() *fonts.Registry {
+	fontRegistry, errs := c.cache.fontRegistry(&c.options)
+	for _, e := range errs {
+		logging.Warnf(c.logger(), logging.WarningFont, "", "Failed to load fonts from %s: %v", e.dir, e.err)
+	}
+	return fontRegistry
+}
 
-	// Determine orientation code based on user option
-	orientationCode := "P"
+// pageSize returns the page size turned to the orientation of the options,
+// and the orientation code of the renderer
+func (c *Converter) pageSize() (width, height float64, orientationCode string) {
+	width, height = c.options.PageWidth, c.options.PageHeight
 	switch c.options.PageOrientation {
 	case PageOrientationLandscape:
 		orientationCode = "L"
 		// Always swap dimensions for landscape to ensure width > height
-		if pageWidth < pageHeight {
-			pageWidth, pageHeight = pageHeight, pageWidth
+		if width < height {
+			width, height = height, width
 		}
-	case PageOrientationPortrait, "":
+	default:
 		orientationCode = "P"
 		// Always swap dimensions for portrait to ensure height > width
-		if pageWidth > pageHeight {
-			pageWidth, pageHeight = pageHeight, pageWidth
+		if width > height {
+			width, height = height, width
+		}
+	}
+	return width, height, orientationCode
+}
+
+// creationDate returns the date the document is given, zero for the time
+// it is written. Reproducible documents without a creation date are dated
+// by SOURCE_DATE_EPOCH, or else by the start of 1970.
+func (c *Converter) creationDate() time.Time {
+	if !c.options.CreationDate.IsZero() || !c.options.Reproducible {
+		return c.options.CreationDate
+	}
+	epoch, err := strconv.ParseInt(strings.TrimSpace(os.Getenv("SOURCE_DATE_EPOCH")), 10, 64)
+	if err != nil {
+		epoch = 0
+	}
+	return time.Unix(epoch, 0).UTC()
+}
+
+// paginate lays out an HTML document and cuts it into pages. The loader
+// resolves the document's resources and the fonts of its @font-face rules
+// are added to fontRegistry.
+func (c *Converter) paginate(ctx context.Context, htmlContent string, loader *res.Loader, fontRegistry *fonts.Registry) ([]*pagination.Page, error) {
+	htmlParser := html.NewParser()
+	doc, err := htmlParser.Parse(strings.NewReader(htmlContent))
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse HTML: %w", err)
+	}
+	return c.paginateDocument(ctx, doc, loader, fontRegistry)
+}
+
+// paginateDocument lays out a parsed HTML document and cuts it into pages,
+// as paginate does
+func (c *Converter) paginateDocument(ctx context.Context, doc *html.Document, loader *res.Loader, fontRegistry *fonts.Registry) ([]*pagination.Page, error) {
+	d, err := c.prepareDocument(ctx, doc, loader, fontRegistry)
+	if err != nil {
+		return nil, err
+	}
+	rootBox := d.layout.Layout(doc)
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	d.pagination.RunningElements = d.layout.RunningElements()
+	paginationEngine := pagination.NewEngine()
+	paginationEngine.SetOptions(d.pagination)
+	paginationEngine.SetContext(ctx)
+	pages, err := paginationEngine.Paginate(rootBox)
+	if err != nil {
+		return nil, err
+	}
+	if err := c.options.Hooks.laidOut(len(pages)); err != nil {
+		return nil, err
+	}
+	return pages, nil
+}
+
+// preparedDocument is a styled document with the engines that lay it out
+// and the options that cut it into pages
+type preparedDocument struct {
+	ctx        context.Context
+	layout     *layout.Engine
+	pagination pagination.Options
+}
+
+// stream lays out the document and cuts it into pages, passing each page
+// to emit as soon as it is complete
+func (d *preparedDocument) stream(doc *html.Document, emit func(*pagination.Page) error) error {
+	paginationEngine := pagination.NewEngine()
+	paginationEngine.SetOptions(d.pagination)
+	paginationEngine.SetContext(d.ctx)
+	stream := paginationEngine.NewStream(d.layout.RunningElements, emit)
+	d.layout.SetSink(stream)
+	defer d.layout.SetSink(nil)
+	d.layout.Layout(doc)
+	if err := d.ctx.Err(); err != nil {
+		return err
+	}
+	return stream.Finish()
+}
+
+// prepareDocument styles a parsed HTML document, loading its stylesheets
+// and fonts, and sets up its layout and pagination
+func (c *Converter) prepareDocument(ctx context.Context, doc *html.Document, loader *res.Loader, fontRegistry *fonts.Registry) (*preparedDocument, error) {
+	logger := c.logger()
+
+	cssParser := css.NewParser()
+	uaStylesheet, err := c.cache.userAgentStylesheet(&c.options)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse CSS: %w", err)
+	}
+
+	styleEngine := style.NewStyleEngine()
+	styleEngine.AddStylesheet(uaStylesheet)
+	if buildTableOfContents(doc.Root, c.options.TableOfContents, c.options.TOCTitle, c.options.TOCDepth) {
+		tocSheet, err := cssParser.ParseString(tocStylesheet)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse CSS: %w", err)
 		}
+		styleEngine.AddStylesheet(tocSheet)
+	}
+	var pageRules []*css.PageRule
+	if hasPageElements(doc.Root) {
+		elementSheet, err := cssParser.ParseString(pageElementStylesheet)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse CSS: %w", err)
+		}
+		styleEngine.AddStylesheet(elementSheet)
+		pageRules = append(pageRules, elementSheet.PageRules()...)
+	}
+	if insertPageTemplates(doc.Root, c.options.HeaderTemplate, c.options.FooterTemplate) {
+		templateSheet, err := cssParser.ParseString(pageTemplateStylesheet)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse CSS: %w", err)
+		}
+		styleEngine.AddStylesheet(templateSheet)
+		pageRules = append(pageRules, templateSheet.PageRules()...)
+	}
+
+	pageLabels := pageLabelsFromCSS(uaStylesheet)
+	if applyBaseURL(doc.Root, loader) {
+		logger.Debugf("Resolved document URLs against its <base> element")
+	}
+	properties := newPropertyChecker(logger)
+	properties.checkStyleAttributes(doc.Root)
+	c.prefetch(loader, documentResources(doc.Root, c.imageOptions()))
+	var sheets []*css.Stylesheet
+	for _, cssText := range collectDocumentStylesheets(doc.Root, loader, logger, c.options.MaxImportDepth) {
+		if sheet, parseErr := cssParser.ParseString(cssText); parseErr == nil {
+			sheets = append(sheets, sheet)
+		} else {
+			logging.Warnf(logger, logging.WarningStylesheet, "", "Failed to parse stylesheet: %v", parseErr)
+		}
+	}
+	c.prefetch(loader, fontSources(sheets))
+	for _, sheet := range sheets {
+		properties.checkStylesheet(sheet)
+		styleEngine.AddStylesheet(sheet)
+		pageLabels = append(pageLabels, pageLabelsFromCSS(sheet)...)
+		pageRules = append(pageRules, sheet.PageRules()...)
+		loadFontFaces(sheet, fontRegistry, loader, logger)
 	}
 
-	if c.options.Debug {
-		fmt.Printf("Page orientation: %s (%s), dimensions: %.2f x %.2f\n",
-			c.options.PageOrientation, orientationCode, pageWidth, pageHeight)
+	// Labels given in the options win over those of the stylesheets
+	for _, l := range c.options.PageLabels {
+		pageLabels = append(pageLabels, pagination.PageLabel(l))
 	}
 
-	layout.SetMeasurementOrientation(orientationCode)
+	pageWidth, pageHeight, orientationCode := c.pageSize()
+	logger.Debugf("Page orientation: %s (%s), dimensions: %.2f x %.2f",
+		c.options.PageOrientation, orientationCode, pageWidth, pageHeight)
+
+	// Media queries match the page size; viewport units resolve against the
+	// page area, the content box of the page
+	margins, first, left, right := c.pageMargins(pageRules)
+	if err := checkPageArea(pageWidth, pageHeight, margins, first, left, right); err != nil {
+		return nil, err
+	}
+	styleEngine.SetPageSize(pageWidth, pageHeight)
+	styleEngine.SetViewport(pageWidth-margins.Left-margins.Right, pageHeight-margins.Top-margins.Bottom)
+	if c.options.MediaType != "" {
+		styleEngine.SetMediaType(c.options.MediaType)
+	}
+	computedStyles := styleEngine.ComputeStyles(doc) // Compute styles and use the result
+	if c.options.RenderBackgrounds {
+		c.prefetch(loader, backgroundImages(computedStyles))
+	}
+	if err := c.options.Hooks.parsed(); err != nil {
+		return nil, err
+	}
 
 	layoutEngine := layout.NewEngine()
 	layoutEngine.SetOptions(layout.Options{
-		Width:  pageWidth,
-		Height: pageHeight,
-		DPI:    c.options.DPI,
+		Width:     pageWidth,
+		Height:    pageHeight,
+		DPI:       c.options.DPI,
+		MediaType: c.options.MediaType,
+
+		IgnoreImageOrientation: c.options.IgnoreImageOrientation,
+
+		MarginTop:    margins.Top,
+		MarginRight:  margins.Right,
+		MarginBottom: margins.Bottom,
+		MarginLeft:   margins.Left,
 	})
 	layoutEngine.Debug = c.options.Debug
+	layoutEngine.SetLogger(logger)
+	layoutEngine.SetLoader(loader)
+	layoutEngine.SetContext(ctx)
+	layoutEngine.SetMeasurer(layout.NewMeasurer(fontRegistry, c.cache.widthCache()))
 
 	layoutEngine.SetStyles(computedStyles)
-	rootBox := layoutEngine.Layout(doc)
+	layoutEngine.SetFirstLineStyles(styleEngine.FirstLineStyles())
 
-	paginationEngine := pagination.NewEngine()
-	paginationEngine.SetOptions(pagination.Options{
-		PageWidth:    pageWidth,
-		PageHeight:   pageHeight,
-		MarginTop:    c.options.MarginTop,
-		MarginRight:  c.options.MarginRight,
-		MarginBottom: c.options.MarginBottom,
-		MarginLeft:   c.options.MarginLeft,
-	})
-	pages := paginationEngine.Paginate(rootBox)
+	return &preparedDocument{
+		ctx:    ctx,
+		layout: layoutEngine,
+		pagination: pagination.Options{
+			PageWidth:    pageWidth,
+			PageHeight:   pageHeight,
+			MarginTop:    margins.Top,
+			MarginRight:  margins.Right,
+			MarginBottom: margins.Bottom,
+			MarginLeft:   margins.Left,
+
+			FirstPageMargins: &first,
+			LeftPageMargins:  &left,
+			RightPageMargins: &right,
+
+			MarginBoxes: marginBoxesFromCSS(pageRules),
+
+			RepeatTableHeaders: c.options.RepeatTableHeaders,
+			MinTableRows:       c.options.MinTableRows,
+			PageLabels:         pageLabels,
+			MaxPages:           c.options.MaxPages,
+		},
+	}, nil
+}
+
+// render writes pages to a PDF file. The resources of pages are loaded by
+// the converter's loader, or else by the one pageLoaders gives for them.
+// The boxes of the pages are dropped as they are drawn.
+func (c *Converter) render(ctx context.Context, pages []*pagination.Page, pageLoaders map[*pagination.Page]*res.Loader, fontRegistry *fonts.Registry, outputPath string) error {
+	renderer, renderOptions := c.newRenderer(pageLoaders, fontRegistry)
+	if err := renderer.RenderContext(ctx, pages, outputPath, renderOptions); err != nil {
+		return fmt.Errorf("failed to render PDF: %w", err)
+	}
 
+	return nil
+}
+
+// newRenderer returns a PDF renderer set up with the converter's options,
+// and the options of the document it renders
+func (c *Converter) newRenderer(pageLoaders map[*pagination.Page]*res.Loader, fontRegistry *fonts.Registry) (*pdf.Renderer, pdf.RenderOptions) {
+	_, _, orientationCode := c.pageSize()
 	renderer := pdf.NewRenderer(c.loader)
+	renderer.PageLoaders = pageLoaders
 	renderer.DPI = c.options.DPI
 	renderer.Debug = c.options.Debug
+	renderer.Logger = c.logger()
 	renderer.RenderBackgrounds = c.options.RenderBackgrounds
 	renderer.RenderBorders = c.options.RenderBorders
 	renderer.DebugDrawBoxes = c.options.DebugDrawBoxes
+	renderer.Bookmarks = c.options.Bookmarks
+	renderer.FormFields = c.options.FormFields
+	renderer.CreationDate = c.creationDate()
+	renderer.CompressionLevel = int(c.options.CompressionLevel)
+	renderer.MaxImageDPI = c.options.MaxImageDPI
+	renderer.JPEGQuality = c.options.JPEGQuality
+	renderer.SubsetFonts = c.options.SubsetFonts
+	renderer.PDFVersion = string(c.options.PDFVersion)
+	renderer.Fonts = fontRegistry
+	renderer.OnPage = c.options.Hooks.OnPageRendered
+	renderer.ReleasePages = true
+	for _, a := range c.options.Attachments {
+		renderer.Attachments = append(renderer.Attachments, pdf.Attachment{
+			Name:         a.Name,
+			Description:  a.Description,
+			MIMEType:     a.MIMEType,
+			Relationship: string(a.Relationship),
+			Content:      a.Data,
+		})
+	}
+	if lh := c.options.Letterhead; lh != nil {
+		renderer.Letterhead = &pdf.Letterhead{
+			Source:             lh.Source,
+			Data:               lh.Data,
+			Page:               lh.Page,
+			ContinuationSource: lh.ContinuationSource,
+			ContinuationData:   lh.ContinuationData,
+			ContinuationPage:   lh.ContinuationPage,
+		}
+	}
+	if oi := c.options.OutputIntent; oi != nil {
+		renderer.OutputIntent = &pdf.OutputIntent{
+			Source:       oi.Source,
+			Profile:      oi.Profile,
+			Subtype:      string(oi.Subtype),
+			Identifier:   oi.Identifier,
+			RegistryName: oi.RegistryName,
+			Condition:    oi.Condition,
+			Info:         oi.Info,
+		}
+	}
+	for _, p := range c.options.AppendedPages {
+		renderer.AppendedPages = append(renderer.AppendedPages, pdf.PDFPages{
+			Source: p.Source,
+			Data:   p.Data,
+			Pages:  p.Pages,
+		})
+	}
+	if wm := c.options.Watermark; wm != nil {
+		renderer.Watermark = &pdf.Watermark{
+			Text:       wm.Text,
+			FontFamily: wm.FontFamily,
+			FontSize:   wm.FontSize,
+			Bold:       wm.Bold,
+			Color:      wm.Color,
+			Image:      wm.Image,
+			Width:      wm.Width,
+			Height:     wm.Height,
+			Opacity:    wm.Opacity,
+			Rotation:   wm.Rotation,
+			Position:   string(wm.Position),
+			Above:      wm.Above,
+		}
+	}
 
 	for _, dir := range c.options.FontDirectories {
 		renderer.AddFontDirectory(dir)
@@ -164,20 +613,15 @@ func (c *Converter) ConvertToFile(htmlContent, outputPath string) error {
 		Producer:    "GomPDF",
 		Orientation: orientationCode, // Pass the orientation to the renderer
 	}
-
-	err = renderer.Render(pages, outputPath, renderOptions)
-	if err != nil {
-		return fmt.Errorf("failed to render PDF: %w", err)
-	}
-
-	return nil
+	return renderer, renderOptions
 }
 
 // collectDocumentStylesheets walks the HTML node tree in document order and
 // returns the concatenated list of author stylesheets (external <link rel="stylesheet">
 // and inline <style> blocks) preserving source order. The loader is used to
 // resolve and load external stylesheets based on the current BaseURL and search paths.
-func collectDocumentStylesheets(n *html.Node, loader *res.Loader, debug bool) []string {
+// @import rules are followed up to maxImportDepth levels deep.
+func collectDocumentStylesheets(n *html.Node, loader *res.Loader, logger logging.Logger, maxImportDepth int) []string {
 	var styles []string
 
 	var walk func(*html.Node)
@@ -189,23 +633,25 @@ func collectDocumentStylesheets(n *html.Node, loader *res.Loader, debug bool) []
 		if cur.Type == xhtml.ElementNode {
 			// <link rel="stylesheet" href="...">
 			if strings.EqualFold(cur.Data, "link") {
-				var rel, href string
+				var rel, href, media string
 				for _, a := range cur.Attr {
 					if strings.EqualFold(a.Key, "rel") {
 						rel = a.Val
 					} else if strings.EqualFold(a.Key, "href") {
 						href = a.Val
+					} else if strings.EqualFold(a.Key, "media") {
+						media = a.Val
 					}
 				}
 				if href != "" && strings.Contains(strings.ToLower(rel), "stylesheet") {
 					if loader != nil {
 						if resrc, err := loader.LoadCSS(href); err == nil {
-							if debug {
-								fmt.Printf("Loaded external stylesheet: %s\n", href)
-							}
-							styles = append(styles, resrc.GetString())
-						} else if debug {
-							fmt.Printf("Failed to load external stylesheet %s: %v\n", href, err)
+							logger.Debugf("Loaded external stylesheet: %s", href)
+							base, _ := loader.ResolveReference("", href)
+							cssText := resolveImports(resrc.GetString(), base, loader, logger, maxImportDepth, []string{base})
+							styles = append(styles, withMedia(cssText, media))
+						} else {
+							logging.Warnf(logger, logging.WarningResource, cur.Path(), "Failed to load external stylesheet %s: %v", href, err)
 						}
 					}
 				}
@@ -220,8 +666,15 @@ func collectDocumentStylesheets(n *html.Node, loader *res.Loader, debug bool) []
 						b.WriteString("\n")
 					}
 				}
+				var media string
+				for _, a := range cur.Attr {
+					if strings.EqualFold(a.Key, "media") {
+						media = a.Val
+					}
+				}
 				if cssText := strings.TrimSpace(b.String()); cssText != "" {
-					styles = append(styles, cssText)
+					cssText = resolveImports(cssText, "", loader, logger, maxImportDepth, nil)
+					styles = append(styles, withMedia(cssText, media))
 				}
 			}
 		}
@@ -235,30 +688,301 @@ func collectDocumentStylesheets(n *html.Node, loader *res.Loader, debug bool) []
 	return styles
 }
 
+// resolveImports replaces the @import rules of a stylesheet with the sheets
+// they import, loaded relative to base, the URL of the importing sheet ("" for
+// the document). chain lists the sheets being imported, outermost first, to
+// break cycles; depth is the number of further levels that may be followed.
+// The url()s of a sheet loaded from base are resolved against it, since the
+// sheets are all parsed as if they were the document's.
+func resolveImports(cssText, base string, loader *res.Loader, logger logging.Logger, depth int, chain []string) string {
+	if loader != nil && base != "" {
+		cssText = css.ResolveURLs(cssText, func(ref string) string {
+			return resolveCSSURL(loader, base, ref)
+		})
+	}
+	imports, rest := css.SplitImports(cssText)
+	if len(imports) == 0 {
+		return cssText
+	}
+	var b strings.Builder
+	for _, imp := range imports {
+		if loader == nil {
+			continue
+		}
+		if depth <= 0 {
+			logging.Warnf(logger, logging.WarningStylesheet, "", "Skipping @import of %s: nesting deeper than the import depth limit", imp.URL)
+			continue
+		}
+		ref, err := loader.ResolveReference(base, imp.URL)
+		if err != nil {
+			logging.Warnf(logger, logging.WarningResource, "", "Failed to resolve @import %s: %v", imp.URL, err)
+			continue
+		}
+		if slices.Contains(chain, ref) {
+			logging.Warnf(logger, logging.WarningStylesheet, "", "Skipping @import of %s: import cycle", imp.URL)
+			continue
+		}
+		resrc, err := loader.LoadCSS(ref)
+		if err != nil {
+			logging.Warnf(logger, logging.WarningResource, "", "Failed to load imported stylesheet %s: %v", imp.URL, err)
+			continue
+		}
+		logger.Debugf("Loaded imported stylesheet: %s", ref)
+		imported := resolveImports(resrc.GetString(), ref, loader, logger, depth-1, append(chain[:len(chain):len(chain)], ref))
+		b.WriteString(withMedia(imported, imp.Media))
+		b.WriteString("\n")
+	}
+	b.WriteString(rest)
+	return b.String()
+}
+
+// withMedia wraps a stylesheet in an @media block for the media attribute of
+// the <link> or <style> element it came from
+func withMedia(cssText, media string) string {
+	media = strings.TrimSpace(media)
+	if media == "" || strings.EqualFold(media, "all") {
+		return cssText
+	}
+	return "@media " + media + " {\n" + cssText + "\n}"
+}
+
+// loadFontFaces registers the faces declared by @font-face rules of a
+// stylesheet. The first source of a face that loads and decodes is used.
+func loadFontFaces(sheet *css.Stylesheet, registry *fonts.Registry, loader *res.Loader, logger logging.Logger) {
+	if loader == nil {
+		return
+	}
+	for _, face := range sheet.FontFaces() {
+		weight, italic := fonts.ParseWeight(face.Weight), fonts.IsItalic(face.Style)
+		for _, src := range face.Sources {
+			resrc, err := loader.LoadFont(src)
+			if err == nil {
+				err = registry.AddFaceWeight(face.Family, weight, italic, resrc.Data)
+			}
+			if err == nil {
+				break
+			}
+			logging.Warnf(logger, logging.WarningFont, "", "Failed to load font %q from %s: %v", face.Family, src, err)
+		}
+	}
+}
+
+// pageLabelsFromCSS returns the sections of page numbering declared by the
+// @page rules of a stylesheet. A rule for a named page starts a section where
+// that page begins; a rule for every page numbers the document from its
+// first page. counter-reset: page sets the first number and the
+// -gompdf-page-label descriptor the list style, optionally after a prefix
+// string, as in -gompdf-page-label: "A-" decimal.
+func pageLabelsFromCSS(sheet *css.Stylesheet) []pagination.PageLabel {
+	var labels []pagination.PageLabel
+	for _, rule := range sheet.PageRules() {
+		if len(rule.Pseudo) > 0 {
+			continue
+		}
+		label := pagination.PageLabel{Name: rule.Name}
+		if rule.Name == "" {
+			label.Page = 1
+		}
+		found := false
+		for _, decl := range rule.Declarations {
+			switch strings.ToLower(decl.Property) {
+			case "counter-reset":
+				fields := strings.Fields(decl.Value)
+				for i, f := range fields {
+					if f != "page" {
+						continue
+					}
+					found = true
+					label.Start = 1
+					if i+1 < len(fields) {
+						if n, err := strconv.Atoi(fields[i+1]); err == nil {
+							label.Start = n
+						}
+					}
+				}
+			case "-gompdf-page-label":
+				found = true
+				value := strings.TrimSpace(decl.Value)
+				if q := value[:min(1, len(value))]; q == "\"" || q == "'" {
+					if end := strings.Index(value[1:], q); end >= 0 {
+						label.Prefix = value[1 : end+1]
+						value = strings.TrimSpace(value[end+2:])
+					}
+				}
+				label.Style = value
+			}
+		}
+		if found {
+			labels = append(labels, label)
+		}
+	}
+	return labels
+}
+
+// pageMargins returns the margins of the document's pages: those of every
+// page, which content is laid out for, and those of the first page and of
+// left- and right-hand pages. The margins of the options are overridden by
+// the margin declarations of the document's @page rules, whose :first,
+// :left and :right rules win over those for every page. The first page is
+// a right-hand page. The user agent stylesheet's @page rules give way to
+// the options.
+func (c *Converter) pageMargins(rules []*css.PageRule) (margins, first, left, right pagination.Margins) {
+	apply := func(m *pagination.Margins, pseudo string) {
+		for _, rule := range rules {
+			if rule.Name != "" || strings.Join(rule.Pseudo, ":") != pseudo {
+				continue
+			}
+			for _, decl := range rule.Declarations {
+				applyPageMargin(m, decl)
+			}
+		}
+	}
+	margins = pagination.Margins{
+		Top:    c.options.MarginTop,
+		Right:  c.options.MarginRight,
+		Bottom: c.options.MarginBottom,
+		Left:   c.options.MarginLeft,
+	}
+	apply(&margins, "")
+
+	right = margins
+	apply(&right, "right")
+	left = margins
+	if c.options.MirrorMargins {
+		left.Left, left.Right = left.Right, left.Left
+	}
+	apply(&left, "left")
+	first = right
+	if m := c.options.FirstPageMargins; m != nil {
+		first = pagination.Margins(*m)
+	}
+	apply(&first, "first")
+	return margins, first, left, right
+}
+
+// checkPageArea reports an error when the margins of a page, those of the
+// options or of @page rules, leave no room for content on a page of the
+// given size
+func checkPageArea(width, height float64, margins ...pagination.Margins) error {
+	if !(width > 0 && height > 0) {
+		return fmt.Errorf("invalid page size %.2f x %.2f", width, height)
+	}
+	for _, m := range margins {
+		if !(width-m.Left-m.Right > 0 && height-m.Top-m.Bottom > 0) {
+			return fmt.Errorf("page margins %.2f %.2f %.2f %.2f leave no content area on a %.2f x %.2f page",
+				m.Top, m.Right, m.Bottom, m.Left, width, height)
+		}
+	}
+	return nil
+}
+
+// marginBoxesFromCSS returns the margin boxes of @page rules, for every page
+// or for :first, :left or :right pages, that show running elements with
+// content: element(name[, policy]), or nothing with content: none
+func marginBoxesFromCSS(rules []*css.PageRule) []pagination.MarginBox {
+	var boxes []pagination.MarginBox
+	for _, rule := range rules {
+		pages := strings.Join(rule.Pseudo, ":")
+		if rule.Name != "" || (pages != "" && pages != "first" && pages != "left" && pages != "right") {
+			continue
+		}
+		for _, mb := range rule.MarginBoxes {
+			switch mb.Name {
+			case "top-left", "top-center", "top-right", "bottom-left", "bottom-center", "bottom-right":
+			default:
+				continue
+			}
+			for _, decl := range mb.Declarations {
+				if !strings.EqualFold(decl.Property, "content") {
+					continue
+				}
+				box := pagination.MarginBox{Position: mb.Name, Pages: pages}
+				value := strings.TrimSpace(decl.Value)
+				lower := strings.ToLower(value)
+				switch {
+				case lower == "none":
+				case strings.HasPrefix(lower, "element(") && strings.HasSuffix(value, ")"):
+					args := strings.Split(value[len("element("):len(value)-1], ",")
+					box.Element = strings.TrimSpace(args[0])
+					if len(args) > 1 {
+						box.Policy = strings.TrimSpace(args[1])
+					}
+				default:
+					continue
+				}
+				boxes = append(boxes, box)
+			}
+		}
+	}
+	return boxes
+}
+
+// applyPageMargin sets the sides of m a margin declaration of an @page rule
+// gives an absolute length
+func applyPageMargin(m *pagination.Margins, decl *css.Declaration) {
+	sides := []*float64{&m.Top, &m.Right, &m.Bottom, &m.Left}
+	set := func(side *float64, v string) {
+		if n, ok := style.AbsoluteLength(v); ok {
+			*side = n
+		}
+	}
+	switch strings.ToLower(decl.Property) {
+	case "margin":
+		// One to four values, as for the margin of elements
+		values := strings.Fields(decl.Value)
+		if len(values) == 0 || len(values) > 4 {
+			return
+		}
+		for i, side := range sides {
+			switch {
+			case i < len(values):
+				set(side, values[i])
+			case i == 3 && len(values) > 1:
+				set(side, values[1])
+			default:
+				set(side, values[0])
+			}
+		}
+	case "margin-top":
+		set(&m.Top, decl.Value)
+	case "margin-right":
+		set(&m.Right, decl.Value)
+	case "margin-bottom":
+		set(&m.Bottom, decl.Value)
+	case "margin-left":
+		set(&m.Left, decl.Value)
+	}
+}
+
 // ConvertFile converts an HTML file to PDF and writes the result to the specified file
 func (c *Converter) ConvertFile(inputPath, outputPath string) error {
-	htmlContent, err := os.ReadFile(inputPath)
+	return c.ConvertFileContext(context.Background(), inputPath, outputPath)
+}
+
+// ConvertFileContext is like ConvertFile but stops with the context's error
+// when the context is cancelled or its deadline passes
+func (c *Converter) ConvertFileContext(ctx context.Context, inputPath, outputPath string) error {
+	htmlContent, err := c.readFile(inputPath)
 	if err != nil {
 		return fmt.Errorf("failed to read HTML file: %w", err)
 	}
-	c.loader = res.NewLoader(inputPath)
-	for _, path := range c.options.ResourcePaths {
-		c.loader.AddSearchPath(path)
-	}
-	return c.ConvertToFile(string(htmlContent), outputPath)
+	return c.conversion(ctx, inputPath).ConvertToFileContext(ctx, string(htmlContent), outputPath)
 }
 
 // ConvertURL converts an HTML URL to PDF and writes the result to the specified file
 func (c *Converter) ConvertURL(url, outputPath string) error {
-	c.loader = res.NewLoader(url)
-	for _, path := range c.options.ResourcePaths {
-		c.loader.AddSearchPath(path)
-	}
-	resource, err := c.loader.LoadHTML(url)
+	return c.ConvertURLContext(context.Background(), url, outputPath)
+}
+
+// ConvertURLContext is like ConvertURL; the context also bounds fetching the
+// page and its resources
+func (c *Converter) ConvertURLContext(ctx context.Context, url, outputPath string) error {
+	conv := c.conversion(ctx, url)
+	resource, err := conv.loader.LoadHTML(url)
 	if err != nil {
 		return fmt.Errorf("failed to load HTML from URL: %w", err)
 	}
-	return c.ConvertToFile(resource.GetString(), outputPath)
+	return conv.ConvertToFileContext(ctx, resource.GetString(), outputPath)
 }
 
 // ConvertBytes converts HTML bytes to PDF bytes
@@ -330,6 +1054,13 @@ func (c *Converter) SetDebug(debug bool) *Converter {
 	return NewWithOptions(newOptions)
 }
 
+// SetLogger sets the logger that receives warnings and debug output
+func (c *Converter) SetLogger(logger Logger) *Converter {
+	newOptions := c.options
+	newOptions.Logger = logger
+	return NewWithOptions(newOptions)
+}
+
 // SetTitle sets the document title
 func (c *Converter) SetTitle(title string) *Converter {
 	newOptions := c.options
@@ -0,0 +1,130 @@
+package api
+
+import (
+	"strings"
+
+	"github.com/gompdf/gompdf/internal/parser/css"
+	"github.com/gompdf/gompdf/internal/parser/html"
+	"github.com/gompdf/gompdf/internal/res"
+	xhtml "golang.org/x/net/html"
+)
+
+// urlAttributes are the attributes holding URLs that a <base> element
+// applies to, by element
+var urlAttributes = map[string]string{
+	"img":  "src",
+	"link": "href",
+	"a":    "href",
+}
+
+// applyBaseURL resolves the relative URLs of a document against the href
+// of its first <base> element, itself relative to the location of the
+// document: those of the attributes of urlAttributes and of srcset
+// attributes, and the url()s of style attributes and <style> elements. It
+// reports whether the document has a base URL.
+func applyBaseURL(root *html.Node, loader *res.Loader) bool {
+	if loader == nil {
+		return false
+	}
+	href, ok := baseHref(root)
+	if !ok {
+		return false
+	}
+	base, err := loader.ResolveReference("", href)
+	if err != nil {
+		return false
+	}
+	// A base ending in a slash is a directory, which resolving file paths
+	// drops
+	if strings.HasSuffix(href, "/") && !strings.HasSuffix(base, "/") {
+		base += "/"
+	}
+	resolve := func(ref string) string {
+		return resolveCSSURL(loader, base, ref)
+	}
+
+	var walk func(n *html.Node)
+	walk = func(n *html.Node) {
+		if n.Type == xhtml.ElementNode {
+			name := strings.ToLower(n.Data)
+			for i, a := range n.Attr {
+				switch {
+				case strings.EqualFold(a.Key, urlAttributes[name]):
+					n.Attr[i].Val = resolve(strings.TrimSpace(a.Val))
+				case strings.EqualFold(a.Key, "style"):
+					n.Attr[i].Val = css.ResolveURLs(a.Val, resolve)
+				case strings.EqualFold(a.Key, "srcset") && (name == "img" || name == "source"):
+					n.Attr[i].Val = resolveSrcset(a.Val, resolve)
+				}
+			}
+			if name == "style" {
+				for c := n.FirstChild; c != nil; c = c.NextSibling {
+					if c.Type == xhtml.TextNode {
+						c.Data = css.ResolveURLs(c.Data, resolve)
+					}
+				}
+			}
+		}
+		for c := n.FirstChild; c != nil; c = c.NextSibling {
+			walk(c)
+		}
+	}
+	walk(root)
+	return true
+}
+
+// baseHref returns the href of the first <base> element of a document
+func baseHref(n *html.Node) (string, bool) {
+	if n.Type == xhtml.ElementNode && strings.EqualFold(n.Data, "base") {
+		if href := strings.TrimSpace(attribute(n, "href")); href != "" {
+			return href, true
+		}
+	}
+	for c := n.FirstChild; c != nil; c = c.NextSibling {
+		if href, ok := baseHref(c); ok {
+			return href, true
+		}
+	}
+	return "", false
+}
+
+// resolveSrcset resolves the URLs of the candidates of a srcset attribute
+func resolveSrcset(srcset string, resolve func(ref string) string) string {
+	candidates := html.ParseSrcset(srcset)
+	parts := make([]string, len(candidates))
+	for i, c := range candidates {
+		c.URL = resolve(c.URL)
+		parts[i] = c.String()
+	}
+	return strings.Join(parts, ", ")
+}
+
+// resolveCSSURL resolves a reference of a document or stylesheet against
+// base. Fragments, which point into the document, and absolute URLs, which
+// include those of schemes the loader does not know such as mailto:, are
+// left as they are.
+func resolveCSSURL(loader *res.Loader, base, ref string) string {
+	if ref == "" || strings.HasPrefix(ref, "#") || hasScheme(ref) {
+		return ref
+	}
+	resolved, err := loader.ResolveReference(base, ref)
+	if err != nil {
+		return ref
+	}
+	return resolved
+}
+
+// hasScheme reports whether ref starts with a URL scheme. A single letter
+// before the colon is the drive of a Windows path.
+func hasScheme(ref string) bool {
+	scheme, _, ok := strings.Cut(ref, ":")
+	if !ok || len(scheme) < 2 {
+		return false
+	}
+	for _, c := range scheme {
+		if !(c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9' || c == '+' || c == '-' || c == '.') {
+			return false
+		}
+	}
+	return true
+}
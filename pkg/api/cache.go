@@ -0,0 +1,105 @@
+package api
+
+import (
+	"net/http"
+	"sync"
+
+	"github.com/gompdf/gompdf/internal/fonts"
+	"github.com/gompdf/gompdf/internal/layout"
+	"github.com/gompdf/gompdf/internal/parser/css"
+)
+
+// converterCache holds what the conversions of a converter share, so that a
+// server reusing one converter does the work once: the parsed user agent
+// stylesheet, the fonts of the font directories, the widths of measured
+// text and the HTTP client with its open connections. Everything is built as first needed; a converterCache is safe for
+// concurrent use and a nil one caches nothing.
+type converterCache struct {
+	uaOnce  sync.Once
+	uaSheet *css.Stylesheet
+	uaErr   error
+
+	fontsOnce sync.Once
+	fonts     *fonts.Registry
+	// fontErrs are the errors of scanning the font directories, by
+	// directory, reported again by every conversion
+	fontErrs []fontDirError
+
+	widths *layout.WidthCache
+
+	clientOnce sync.Once
+	client     *http.Client
+}
+
+// fontDirError is the error of scanning a font directory
+type fontDirError struct {
+	dir string
+	err error
+}
+
+func newConverterCache() *converterCache {
+	return &converterCache{widths: layout.NewWidthCache()}
+}
+
+// userAgentStylesheet returns the parsed user agent stylesheet of options.
+// Stylesheets are not changed by the style engine, so one can serve every
+// conversion.
+func (cc *converterCache) userAgentStylesheet(options *Options) (*css.Stylesheet, error) {
+	if cc == nil {
+		return css.NewParser().ParseString(options.UserAgentStylesheet)
+	}
+	cc.uaOnce.Do(func() {
+		cc.uaSheet, cc.uaErr = css.NewParser().ParseString(options.UserAgentStylesheet)
+	})
+	return cc.uaSheet, cc.uaErr
+}
+
+// fontRegistry returns a registry of the fonts of the font directories with
+// the fallbacks of options, and the errors of scanning the directories. The
+// directories are scanned once; every call returns a clone of the result to
+// which the conversion can add its @font-face rules.
+func (cc *converterCache) fontRegistry(options *Options) (*fonts.Registry, []fontDirError) {
+	if cc == nil {
+		return loadFontRegistry(options)
+	}
+	cc.fontsOnce.Do(func() {
+		cc.fonts, cc.fontErrs = loadFontRegistry(options)
+	})
+	return cc.fonts.Clone(), cc.fontErrs
+}
+
+// loadFontRegistry builds the registry of fontRegistry
+func loadFontRegistry(options *Options) (*fonts.Registry, []fontDirError) {
+	fontRegistry := fonts.NewRegistry()
+	fontRegistry.SetFallbacks(options.FallbackFonts...)
+	for script, families := range options.FontFallbacks {
+		fontRegistry.SetScriptFallbacks(script, families...)
+	}
+	var errs []fontDirError
+	for _, dir := range options.FontDirectories {
+		if err := fontRegistry.AddDirectory(dir); err != nil {
+			errs = append(errs, fontDirError{dir: dir, err: err})
+		}
+	}
+	return fontRegistry, errs
+}
+
+// widthCache returns the cache of measured text widths
+func (cc *converterCache) widthCache() *layout.WidthCache {
+	if cc == nil {
+		return nil
+	}
+	return cc.widths
+}
+
+// httpClient returns the client of remote requests, which enforces the
+// resource policy of options
+func (cc *converterCache) httpClient(options *Options) *http.Client {
+	if cc == nil {
+		return options.ResourcePolicy.Client(options.HTTPClient)
+	}
+	cc.clientOnce.Do(func() {
+		cc.client = options.ResourcePolicy.Client(options.HTTPClient)
+	})
+	return cc.client
+}
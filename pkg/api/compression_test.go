@@ -0,0 +1,18 @@
+package api
+
+import "testing"
+
+func TestCompressionLevel(t *testing.T) {
+	for _, level := range []CompressionLevel{CompressionNone, CompressionDefault, 1, CompressionBest} {
+		c := NewWithOptions(DefaultOptions()).WithOption(WithCompressionLevel(level))
+		if _, err := c.ConvertBytes([]byte("<p>text</p>")); err != nil {
+			t.Errorf("level %d: %v", level, err)
+		}
+	}
+	for _, level := range []CompressionLevel{-2, 10, 42} {
+		c := NewWithOptions(DefaultOptions()).WithOption(WithCompressionLevel(level))
+		if _, err := c.ConvertBytes([]byte("<p>text</p>")); err == nil {
+			t.Errorf("level %d: got no error", level)
+		}
+	}
+}
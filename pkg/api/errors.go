@@ -0,0 +1,34 @@
+package api
+
+import (
+	"errors"
+
+	"github.com/gompdf/gompdf/internal/fonts"
+	"github.com/gompdf/gompdf/internal/pagination"
+	"github.com/gompdf/gompdf/internal/parser/css"
+	"github.com/gompdf/gompdf/internal/res"
+)
+
+// Classes of failures. Errors returned by the converter, and the Err of
+// warnings, wrap them so that callers can tell failures apart with
+// errors.Is instead of matching messages.
+var (
+	// ErrResourceNotFound is a resource that does not exist, such as an
+	// image, stylesheet or font the document refers to or a URL answering
+	// 404 Not Found
+	ErrResourceNotFound = res.ErrNotFound
+	// ErrResourceDenied is a resource the ResourcePolicy does not allow to
+	// load, such as one on a private network or larger than allowed
+	ErrResourceDenied = res.ErrDenied
+	// ErrCSSParse is CSS that cannot be parsed, such as a malformed selector
+	ErrCSSParse = css.ErrSyntax
+	// ErrFontLoad is a font that cannot be loaded: an unreadable, malformed
+	// or unsupported font file
+	ErrFontLoad = fonts.ErrLoad
+	// ErrUnsupportedFeature is a feature GomPDF does not support, such as
+	// WOFF2 fonts or an unknown image format. It is errors.ErrUnsupported.
+	ErrUnsupportedFeature = errors.ErrUnsupported
+	// ErrTooManyPages is a document with more pages than Options.MaxPages
+	// allows
+	ErrTooManyPages = pagination.ErrTooManyPages
+)
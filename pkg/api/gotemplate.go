@@ -0,0 +1,67 @@
+package api
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"html/template"
+	"io"
+	"path/filepath"
+
+	"github.com/gompdf/gompdf/internal/res"
+)
+
+// ConvertTemplate executes an HTML template with data and converts the
+// result to PDF, written to output. Relative resources of the template are
+// resolved against the working directory and the resource paths of the
+// options.
+func (c *Converter) ConvertTemplate(tmpl *template.Template, data any, output io.Writer) error {
+	return c.ConvertTemplateContext(context.Background(), tmpl, data, output)
+}
+
+// ConvertTemplateContext is like ConvertTemplate but stops with the
+// context's error when the context is cancelled or its deadline passes
+func (c *Converter) ConvertTemplateContext(ctx context.Context, tmpl *template.Template, data any, output io.Writer) error {
+	htmlContent, err := executeTemplate(tmpl, data)
+	if err != nil {
+		return err
+	}
+	return c.ConvertContext(ctx, htmlContent, output)
+}
+
+// ConvertTemplateFile parses the HTML template file at templatePath with
+// funcs, which may be nil, executes it with data and converts the result to
+// a PDF file. Relative resources of the template, such as stylesheets and
+// images, are resolved against the directory of the template file.
+func (c *Converter) ConvertTemplateFile(templatePath string, funcs template.FuncMap, data any, outputPath string) error {
+	return c.ConvertTemplateFileContext(context.Background(), templatePath, funcs, data, outputPath)
+}
+
+// ConvertTemplateFileContext is like ConvertTemplateFile but stops with the
+// context's error when the context is cancelled or its deadline passes
+func (c *Converter) ConvertTemplateFileContext(ctx context.Context, templatePath string, funcs template.FuncMap, data any, outputPath string) error {
+	tmpl := template.New(filepath.Base(templatePath)).Funcs(funcs)
+	var err error
+	if c.options.ResourceFS != nil {
+		tmpl, err = tmpl.ParseFS(c.options.ResourceFS, res.FSPath(templatePath))
+	} else {
+		tmpl, err = tmpl.ParseFiles(templatePath)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to parse template: %w", err)
+	}
+	htmlContent, err := executeTemplate(tmpl, data)
+	if err != nil {
+		return err
+	}
+	return c.conversion(ctx, templatePath).ConvertToFileContext(ctx, htmlContent, outputPath)
+}
+
+// executeTemplate executes a template with data into a string
+func executeTemplate(tmpl *template.Template, data any) (string, error) {
+	var buf bytes.Buffer
+	if err := tmpl.Execute(&buf, data); err != nil {
+		return "", fmt.Errorf("failed to execute template: %w", err)
+	}
+	return buf.String(), nil
+}
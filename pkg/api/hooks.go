@@ -0,0 +1,36 @@
+package api
+
+// Hooks are called as a conversion progresses, to report its progress or
+// to stop stages that take too long: an error returned by a hook stops the
+// conversion with that error. Conversions of several documents, such as
+// ConvertFiles, call OnParse and OnLayoutDone for each of them. Nil hooks
+// are skipped.
+type Hooks struct {
+	// OnParse is called once the document and its stylesheets are parsed
+	// and styled, before layout
+	OnParse func() error
+	// OnLayoutDone is called once the document is laid out and cut into
+	// pageCount pages. Streamed documents are drawn as they are cut, so
+	// their pages are rendered before it is called.
+	OnLayoutDone func(pageCount int) error
+	// OnPageRendered is called after the page of the given index, from 0,
+	// is drawn to the PDF or to an image. Pages without content, which are
+	// left out of the output, are not reported.
+	OnPageRendered func(index int) error
+}
+
+// parsed calls the OnParse hook
+func (h Hooks) parsed() error {
+	if h.OnParse == nil {
+		return nil
+	}
+	return h.OnParse()
+}
+
+// laidOut calls the OnLayoutDone hook
+func (h Hooks) laidOut(pageCount int) error {
+	if h.OnLayoutDone == nil {
+		return nil
+	}
+	return h.OnLayoutDone(pageCount)
+}
@@ -0,0 +1,122 @@
+package api
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"image"
+	"image/jpeg"
+	"image/png"
+
+	"github.com/gompdf/gompdf/internal/render/raster"
+)
+
+// ImageFormat is the encoding of page images
+type ImageFormat string
+
+const (
+	ImageFormatPNG  ImageFormat = "png"
+	ImageFormatJPEG ImageFormat = "jpeg"
+)
+
+// ImageOptions control the images ConvertToImages encodes
+type ImageOptions struct {
+	// Format is the encoding of the images, PNG by default
+	Format ImageFormat
+	// DPI is the resolution of the images; zero takes the DPI of the
+	// converter's options
+	DPI float64
+	// Quality is the JPEG quality from 1 to 100, 90 by default
+	Quality int
+}
+
+// RenderImages lays out and paginates HTML as Convert does and paints every
+// page to an image at dpi dots per inch, 96 when zero. Images are drawn as
+// the PDF pages are, without background images and watermarks, and text in
+// the core PDF fonts is drawn in the Go fonts.
+func (c *Converter) RenderImages(htmlContent string, dpi float64) ([]image.Image, error) {
+	return c.RenderImagesContext(context.Background(), htmlContent, dpi)
+}
+
+// RenderImagesContext is like RenderImages but stops with the context's
+// error when the context is cancelled or its deadline passes
+func (c *Converter) RenderImagesContext(ctx context.Context, htmlContent string, dpi float64) ([]image.Image, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	c = c.conversion(ctx, "")
+
+	fontRegistry := c.newFontRegistry()
+	pages, err := c.paginate(ctx, htmlContent, c.loader, fontRegistry)
+	if err != nil {
+		return nil, err
+	}
+	renderer := raster.NewRenderer(c.loader)
+	renderer.DPI = dpi
+	renderer.Logger = c.logger()
+	renderer.RenderBackgrounds = c.options.RenderBackgrounds
+	renderer.RenderBorders = c.options.RenderBorders
+	renderer.Fonts = fontRegistry
+	renderer.OnPage = c.options.Hooks.OnPageRendered
+	rendered, err := renderer.Render(ctx, pages)
+	if err != nil {
+		return nil, fmt.Errorf("failed to render images: %w", err)
+	}
+	images := make([]image.Image, len(rendered))
+	for i, img := range rendered {
+		images[i] = img
+	}
+	return images, nil
+}
+
+// ConvertToImages converts HTML to one encoded image per page, as
+// RenderImages paints them
+func (c *Converter) ConvertToImages(htmlContent string, options ImageOptions) ([][]byte, error) {
+	return c.ConvertToImagesContext(context.Background(), htmlContent, options)
+}
+
+// ConvertToImagesContext is like ConvertToImages but stops with the
+// context's error when the context is cancelled or its deadline passes
+func (c *Converter) ConvertToImagesContext(ctx context.Context, htmlContent string, options ImageOptions) ([][]byte, error) {
+	encode, err := imageEncoder(options)
+	if err != nil {
+		return nil, err
+	}
+	dpi := options.DPI
+	if dpi <= 0 {
+		dpi = c.options.DPI
+	}
+	images, err := c.RenderImagesContext(ctx, htmlContent, dpi)
+	if err != nil {
+		return nil, err
+	}
+	encoded := make([][]byte, len(images))
+	for i, img := range images {
+		var buf bytes.Buffer
+		if err := encode(&buf, img); err != nil {
+			return nil, fmt.Errorf("failed to encode page %d: %w", i+1, err)
+		}
+		encoded[i] = buf.Bytes()
+	}
+	return encoded, nil
+}
+
+// imageEncoder returns the function encoding images in the format of the
+// options
+func imageEncoder(options ImageOptions) (func(*bytes.Buffer, image.Image) error, error) {
+	switch options.Format {
+	case "", ImageFormatPNG:
+		return func(buf *bytes.Buffer, img image.Image) error {
+			return png.Encode(buf, img)
+		}, nil
+	case ImageFormatJPEG:
+		quality := options.Quality
+		if quality <= 0 || quality > 100 {
+			quality = 90
+		}
+		return func(buf *bytes.Buffer, img image.Image) error {
+			return jpeg.Encode(buf, img, &jpeg.Options{Quality: quality})
+		}, nil
+	}
+	return nil, fmt.Errorf("image format %q: %w", options.Format, ErrUnsupportedFeature)
+}
@@ -0,0 +1,198 @@
+package api
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/gompdf/gompdf/internal/layout"
+	"github.com/gompdf/gompdf/internal/parser/html"
+	"github.com/gompdf/gompdf/internal/style"
+	xhtml "golang.org/x/net/html"
+)
+
+// Layout is a read-only view of a document laid out on its pages, for tests
+// and tools that check where content ends up without reading the PDF
+type Layout struct {
+	Pages []*PageLayout
+}
+
+// PageLayout is a page of a Layout
+type PageLayout struct {
+	// Index is the position of the page in the document, from 0
+	Index int
+	// Number is the value of the page counter on the page and Label the
+	// page number as printed, such as "iv" in a roman numbered section
+	Number int
+	Label  string
+	// Name is the named page (CSS page property) of the page, "" for
+	// unnamed pages
+	Name string
+	// Width and Height are the size of the page in points
+	Width  float64
+	Height float64
+	// Boxes are the boxes placed on the page, in document order
+	Boxes []*BoxLayout
+}
+
+// BoxLayout is a box placed on a page. An element split across pages has a
+// box on each of them, and text has boxes of its own.
+type BoxLayout struct {
+	// Element is the lowercase name of the element the box belongs to, ""
+	// for text and anonymous boxes
+	Element string
+	// Attributes are the attributes of the element
+	Attributes map[string]string
+	// Text is the text a text box shows
+	Text string
+	// Page is the index of the page the box is on
+	Page int
+	// X and Y are the top left corner of the box in points from the top left
+	// corner of the page, and Width and Height its size
+	X      float64
+	Y      float64
+	Width  float64
+	Height float64
+	// Children are the boxes inside the box
+	Children []*BoxLayout
+
+	node *html.Node
+}
+
+// Inspect lays out and paginates HTML as Convert does and returns where
+// every box ends up, without producing a PDF
+func (c *Converter) Inspect(htmlContent string) (*Layout, error) {
+	return c.InspectContext(context.Background(), htmlContent)
+}
+
+// InspectContext is like Inspect but stops with the context's error when
+// the context is cancelled or its deadline passes
+func (c *Converter) InspectContext(ctx context.Context, htmlContent string) (*Layout, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	c = c.conversion(ctx, "")
+
+	pages, err := c.paginate(ctx, htmlContent, c.loader, c.newFontRegistry())
+	if err != nil {
+		return nil, err
+	}
+	result := &Layout{Pages: make([]*PageLayout, len(pages))}
+	for i, page := range pages {
+		pl := &PageLayout{
+			Index:  i,
+			Number: page.Number,
+			Label:  page.Label(),
+			Name:   page.Name,
+			Width:  page.Width,
+			Height: page.Height,
+		}
+		pl.Boxes = inspectPage(page.Boxes, i)
+		result.Pages[i] = pl
+	}
+	return result, nil
+}
+
+// inspectPage rebuilds the tree of the boxes of a page. Pages hold their
+// boxes in a flat list in document order, so a box goes inside the closest
+// box before it that belongs to an ancestor of its node; text laid out into
+// lines has no node and goes inside the closest box enclosing it.
+func inspectPage(boxes []layout.Box, page int) []*BoxLayout {
+	var roots, open []*BoxLayout
+	for _, box := range boxes {
+		if box == nil {
+			continue
+		}
+		bl := inspectBox(box, page)
+		for len(open) > 0 && !encloses(open[len(open)-1], bl) {
+			open = open[:len(open)-1]
+		}
+		if len(open) > 0 {
+			parent := open[len(open)-1]
+			parent.Children = append(parent.Children, bl)
+		} else {
+			roots = append(roots, bl)
+		}
+		if bl.Element != "" {
+			open = append(open, bl)
+		}
+	}
+	return roots
+}
+
+// encloses reports whether box goes inside parent
+func encloses(parent, box *BoxLayout) bool {
+	if box.node == nil {
+		const epsilon = 0.5
+		return box.Y >= parent.Y-epsilon && box.Y+box.Height <= parent.Y+parent.Height+epsilon
+	}
+	for n := box.node.Parent; n != nil; n = n.Parent {
+		if n == parent.node {
+			return true
+		}
+	}
+	return false
+}
+
+// inspectBox converts a laid out box, leaving out its children
+func inspectBox(box layout.Box, page int) *BoxLayout {
+	bl := &BoxLayout{
+		Page:   page,
+		X:      box.GetX(),
+		Y:      box.GetY(),
+		Width:  box.GetWidth(),
+		Height: box.GetHeight(),
+		node:   box.GetNode(),
+	}
+	if n := bl.node; n != nil && n.Type == xhtml.ElementNode && !style.IsGenerated(n) {
+		bl.Element = strings.ToLower(n.Data)
+		bl.Attributes = make(map[string]string, len(n.Attr))
+		for _, attr := range n.Attr {
+			bl.Attributes[attr.Key] = attr.Val
+		}
+	}
+	if ib, ok := box.(*layout.InlineBox); ok {
+		bl.Text = ib.Text
+	}
+	return bl
+}
+
+// Find returns the boxes of the elements matching a CSS selector, such as
+// "#total" or "table.items tr", in page and document order
+func (l *Layout) Find(selector string) ([]*BoxLayout, error) {
+	sel, ok := style.ParseSelector(selector)
+	if !ok {
+		return nil, fmt.Errorf("%w: invalid selector %q", ErrCSSParse, selector)
+	}
+	var found []*BoxLayout
+	var walk func(boxes []*BoxLayout)
+	walk = func(boxes []*BoxLayout) {
+		for _, b := range boxes {
+			if b.Element != "" && sel.Matches(b.node) {
+				found = append(found, b)
+			}
+			walk(b.Children)
+		}
+	}
+	for _, page := range l.Pages {
+		walk(page.Boxes)
+	}
+	return found, nil
+}
+
+// TextContent returns the text of the box and the boxes inside it, the
+// pieces separated by spaces
+func (b *BoxLayout) TextContent() string {
+	var parts []string
+	var walk func(b *BoxLayout)
+	walk = func(b *BoxLayout) {
+		if text := strings.TrimSpace(b.Text); text != "" {
+			parts = append(parts, text)
+		}
+		for _, child := range b.Children {
+			walk(child)
+		}
+	}
+	walk(b)
+	return strings.Join(parts, " ")
+}
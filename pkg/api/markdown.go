@@ -0,0 +1,199 @@
+package api
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"html"
+	"io"
+
+	"github.com/yuin/goldmark"
+	"github.com/yuin/goldmark/extension"
+	"github.com/yuin/goldmark/parser"
+	goldmarkhtml "github.com/yuin/goldmark/renderer/html"
+)
+
+// MarkdownRenderer converts Markdown to HTML, which is put in the body of
+// the document ConvertMarkdown converts
+type MarkdownRenderer interface {
+	Render(source []byte, w io.Writer) error
+}
+
+// MarkdownRendererFunc adapts a function to a MarkdownRenderer
+type MarkdownRendererFunc func(source []byte, w io.Writer) error
+
+// Render calls f
+func (f MarkdownRendererFunc) Render(source []byte, w io.Writer) error {
+	return f(source, w)
+}
+
+// NewMarkdownRenderer returns the default Markdown renderer: CommonMark with
+// the GitHub extensions (tables, strikethrough, autolinks and task lists).
+// Headings get ids from their text so that links, the table of contents and
+// target-counter() can refer to them, and raw HTML is kept.
+func NewMarkdownRenderer() MarkdownRenderer {
+	md := goldmark.New(
+		goldmark.WithExtensions(extension.GFM),
+		goldmark.WithParserOptions(parser.WithAutoHeadingID()),
+		goldmark.WithRendererOptions(goldmarkhtml.WithUnsafe()),
+	)
+	return MarkdownRendererFunc(func(source []byte, w io.Writer) error {
+		return md.Convert(source, w)
+	})
+}
+
+// DefaultMarkdownStylesheet styles documents converted from Markdown for
+// print, over the user agent stylesheet
+const DefaultMarkdownStylesheet = `
+body {
+  font-family: Helvetica, Arial, sans-serif;
+  font-size: 11pt;
+  line-height: 1.45;
+  color: #222222;
+}
+
+h1, h2, h3, h4, h5, h6 {
+  color: #111111;
+  break-after: avoid;
+}
+
+h1 {
+  font-size: 22pt;
+  border-bottom: 1px solid #cccccc;
+  padding-bottom: 4pt;
+}
+
+h2 {
+  font-size: 16pt;
+  border-bottom: 1px solid #eeeeee;
+  padding-bottom: 3pt;
+}
+
+h3 {
+  font-size: 13pt;
+}
+
+a {
+  color: #0645ad;
+  text-decoration: none;
+}
+
+code {
+  font-family: Courier, monospace;
+  font-size: 9.5pt;
+  background-color: #f3f3f3;
+}
+
+pre {
+  font-family: Courier, monospace;
+  font-size: 9pt;
+  line-height: 1.3;
+  background-color: #f6f8fa;
+  border: 1px solid #e1e4e8;
+  padding: 8pt;
+  break-inside: avoid;
+}
+
+pre code {
+  background-color: transparent;
+}
+
+blockquote {
+  margin: 1em 0;
+  padding: 0 12pt;
+  color: #555555;
+  border-left: 3pt solid #dddddd;
+}
+
+table {
+  margin: 1em 0;
+}
+
+th, td {
+  border: 1px solid #cccccc;
+  padding: 4pt 8pt;
+}
+
+th {
+  background-color: #f3f3f3;
+}
+
+tr {
+  break-inside: avoid;
+}
+
+hr {
+  border: 0;
+  border-top: 1px solid #cccccc;
+}
+
+img {
+  break-inside: avoid;
+}
+
+@page {
+  margin: 2cm;
+}
+`
+
+// ConvertMarkdown converts Markdown to PDF and writes it to output. The
+// Markdown is rendered to HTML with the MarkdownRenderer of the options,
+// styled with their MarkdownStylesheet and converted as Convert does.
+func (c *Converter) ConvertMarkdown(markdown string, output io.Writer) error {
+	return c.ConvertMarkdownContext(context.Background(), markdown, output)
+}
+
+// ConvertMarkdownContext is like ConvertMarkdown but stops with the
+// context's error when the context is cancelled or its deadline passes
+func (c *Converter) ConvertMarkdownContext(ctx context.Context, markdown string, output io.Writer) error {
+	htmlContent, err := c.markdownDocument([]byte(markdown))
+	if err != nil {
+		return err
+	}
+	return c.ConvertContext(ctx, htmlContent, output)
+}
+
+// ConvertMarkdownFile converts a Markdown file to PDF and writes it to
+// outputPath. Images and links of the Markdown are resolved relative to it.
+func (c *Converter) ConvertMarkdownFile(inputPath, outputPath string) error {
+	return c.ConvertMarkdownFileContext(context.Background(), inputPath, outputPath)
+}
+
+// ConvertMarkdownFileContext is like ConvertMarkdownFile but stops with the
+// context's error when the context is cancelled or its deadline passes
+func (c *Converter) ConvertMarkdownFileContext(ctx context.Context, inputPath, outputPath string) error {
+	markdown, err := c.readFile(inputPath)
+	if err != nil {
+		return fmt.Errorf("failed to read Markdown file: %w", err)
+	}
+	htmlContent, err := c.markdownDocument(markdown)
+	if err != nil {
+		return err
+	}
+	return c.conversion(ctx, inputPath).ConvertToFileContext(ctx, htmlContent, outputPath)
+}
+
+// markdownDocument renders Markdown into the body of an HTML document
+// styled with the Markdown stylesheet of the options
+func (c *Converter) markdownDocument(markdown []byte) (string, error) {
+	renderer := c.options.MarkdownRenderer
+	if renderer == nil {
+		renderer = NewMarkdownRenderer()
+	}
+	stylesheet := c.options.MarkdownStylesheet
+	if stylesheet == "" {
+		stylesheet = DefaultMarkdownStylesheet
+	}
+
+	var b bytes.Buffer
+	b.WriteString("<!DOCTYPE html>\n<html>\n<head>\n<meta charset=\"utf-8\">\n")
+	if c.options.Title != "" {
+		b.WriteString("<title>" + html.EscapeString(c.options.Title) + "</title>\n")
+	}
+	b.WriteString("<style>" + stylesheet + "</style>\n</head>\n<body>\n")
+	if err := renderer.Render(markdown, &b); err != nil {
+		return "", fmt.Errorf("failed to render Markdown: %w", err)
+	}
+	b.WriteString("</body>\n</html>\n")
+	return b.String(), nil
+}
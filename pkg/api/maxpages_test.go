@@ -0,0 +1,32 @@
+package api
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestMaxPages(t *testing.T) {
+	tests := []struct {
+		name    string
+		options []Option
+		html    string
+		want    error
+	}{
+		{"tall block", nil, `<div style="height: 100000000px"></div>`, ErrTooManyPages},
+		{"limit", []Option{WithMaxPages(3)}, paragraphs(200, ""), ErrTooManyPages},
+		{"under the limit", []Option{WithMaxPages(30)}, paragraphs(200, ""), nil},
+		{"no limit", []Option{WithMaxPages(-1)}, `<div style="height: 1000000px"></div>`, nil},
+	}
+	for _, tt := range tests {
+		for _, streaming := range []bool{false, true} {
+			c := New().WithOption(WithStreaming(streaming))
+			for _, o := range tt.options {
+				c = c.WithOption(o)
+			}
+			_, err := c.ConvertBytes([]byte(tt.html))
+			if !errors.Is(err, tt.want) || (err == nil) != (tt.want == nil) {
+				t.Errorf("%s, streaming %v: got error %v, want %v", tt.name, streaming, err, tt.want)
+			}
+		}
+	}
+}
@@ -0,0 +1,94 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/gompdf/gompdf/internal/pagination"
+	"github.com/gompdf/gompdf/internal/res"
+)
+
+// ConvertMany converts HTML documents into one PDF, such as a cover page, a
+// body and an appendix, and writes it to output. Each document is laid out
+// on its own, with its own stylesheets and @page rules, starting on a new
+// page. Pages are numbered on from one document to the next unless a
+// document starts a section of numbering of its own, and the bookmarks of
+// all documents make up one outline.
+func (c *Converter) ConvertMany(documents []string, output io.Writer) error {
+	return c.ConvertManyContext(context.Background(), documents, output)
+}
+
+// ConvertManyContext is like ConvertMany but stops with the context's error
+// when the context is cancelled or its deadline passes
+func (c *Converter) ConvertManyContext(ctx context.Context, documents []string, output io.Writer) error {
+	return writeThroughFile(output, func(path string) error {
+		return c.ConvertManyToFileContext(ctx, documents, path)
+	})
+}
+
+// ConvertManyToFile is like ConvertMany but writes the PDF to a file
+func (c *Converter) ConvertManyToFile(documents []string, outputPath string) error {
+	return c.ConvertManyToFileContext(context.Background(), documents, outputPath)
+}
+
+// ConvertManyToFileContext is like ConvertManyToFile but stops with the
+// context's error when the context is cancelled or its deadline passes
+func (c *Converter) ConvertManyToFileContext(ctx context.Context, documents []string, outputPath string) error {
+	c = c.conversion(ctx, "")
+	return c.convertMany(ctx, len(documents), func(i int) (string, *res.Loader, error) {
+		return documents[i], c.loader, nil
+	}, outputPath)
+}
+
+// ConvertFiles converts HTML files into one PDF file, as ConvertMany does.
+// The resources of every file are resolved relative to it.
+func (c *Converter) ConvertFiles(inputPaths []string, outputPath string) error {
+	return c.ConvertFilesContext(context.Background(), inputPaths, outputPath)
+}
+
+// ConvertFilesContext is like ConvertFiles but stops with the context's
+// error when the context is cancelled or its deadline passes
+func (c *Converter) ConvertFilesContext(ctx context.Context, inputPaths []string, outputPath string) error {
+	c = c.conversion(ctx, "")
+	return c.convertMany(ctx, len(inputPaths), func(i int) (string, *res.Loader, error) {
+		htmlContent, err := c.readFile(inputPaths[i])
+		if err != nil {
+			return "", nil, fmt.Errorf("failed to read HTML file: %w", err)
+		}
+		return string(htmlContent), c.newLoader(ctx, inputPaths[i]), nil
+	}, outputPath)
+}
+
+// convertMany paginates count documents, which load returns with the loader
+// of their resources, one after the other and renders all their pages to
+// one PDF file
+func (c *Converter) convertMany(ctx context.Context, count int, load func(i int) (string, *res.Loader, error), outputPath string) error {
+	if count == 0 {
+		return errors.New("no documents to convert")
+	}
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	fontRegistry := c.newFontRegistry()
+	var pages []*pagination.Page
+	pageLoaders := make(map[*pagination.Page]*res.Loader)
+	for i := 0; i < count; i++ {
+		htmlContent, loader, err := load(i)
+		if err != nil {
+			return err
+		}
+		documentPages, err := c.paginate(ctx, htmlContent, loader, fontRegistry)
+		if err != nil {
+			return fmt.Errorf("document %d: %w", i+1, err)
+		}
+		pagination.ContinueNumbering(pages, documentPages)
+		for _, page := range documentPages {
+			pageLoaders[page] = loader
+		}
+		pages = append(pages, documentPages...)
+	}
+	return c.render(ctx, pages, pageLoaders, fontRegistry, outputPath)
+}
@@ -0,0 +1,42 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"io"
+
+	"github.com/gompdf/gompdf/internal/parser/html"
+	xhtml "golang.org/x/net/html"
+)
+
+// ConvertNode converts a parsed or programmatically built HTML tree to PDF
+// and writes it to output, without serializing it first. The node is a
+// document as html.Parse returns it, an html element, or any other node,
+// such as an element of a fragment, which is converted as the content of
+// the body of a document. The tree is not changed.
+func (c *Converter) ConvertNode(node *xhtml.Node, output io.Writer) error {
+	return c.ConvertNodeContext(context.Background(), node, output)
+}
+
+// ConvertNodeContext is like ConvertNode but stops with the context's error
+// when the context is cancelled or its deadline passes
+func (c *Converter) ConvertNodeContext(ctx context.Context, node *xhtml.Node, output io.Writer) error {
+	return writeThroughFile(output, func(path string) error {
+		return c.ConvertNodeToFileContext(ctx, node, path)
+	})
+}
+
+// ConvertNodeToFile is like ConvertNode but writes the PDF to a file
+func (c *Converter) ConvertNodeToFile(node *xhtml.Node, outputPath string) error {
+	return c.ConvertNodeToFileContext(context.Background(), node, outputPath)
+}
+
+// ConvertNodeToFileContext is like ConvertNodeToFile but stops with the
+// context's error when the context is cancelled or its deadline passes
+func (c *Converter) ConvertNodeToFileContext(ctx context.Context, node *xhtml.Node, outputPath string) error {
+	if node == nil {
+		return errors.New("no HTML node to convert")
+	}
+	_, err := c.convertDocument(ctx, html.FromNode(node), outputPath)
+	return err
+}
@@ -1,5 +1,71 @@
 package api
 
+import (
+	"io"
+	"io/fs"
+	"log/slog"
+	"maps"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gompdf/gompdf/internal/logging"
+	"github.com/gompdf/gompdf/internal/res"
+)
+
+// Logger receives diagnostics from a conversion. Debugf carries tracing
+// output; Warnf reports problems the conversion recovered from, such as an
+// image or stylesheet that could not be loaded.
+type Logger = logging.Logger
+
+// NewSlogLogger adapts an slog.Logger, logging debug output at slog.LevelDebug
+// and warnings at slog.LevelWarn
+func NewSlogLogger(l *slog.Logger) Logger {
+	return logging.NewSlog(l)
+}
+
+// NewWriterLogger returns a Logger that writes one line per message to w
+func NewWriterLogger(w io.Writer) Logger {
+	return logging.NewWriter(w)
+}
+
+// ResourceCache keeps the remote images, fonts and stylesheets of
+// conversions, by URL, so that later conversions need not download them
+// again. It must be safe for concurrent use.
+type ResourceCache = res.Cache
+
+// Resource is a resource kept in a ResourceCache
+type Resource = res.Resource
+
+// ResourcePolicy restricts the resources conversions load: the URL schemes
+// and hosts allowed, whether private networks may be reached, and how large
+// resources and how many redirects may be
+type ResourcePolicy = res.Policy
+
+// Fetcher loads the resources of the URLs of a scheme, such as
+// "asset://logo.png" from object storage. Fetchers are called concurrently
+// and may leave the MIME type and type of the resources they return out,
+// which are then found from the URL or the content.
+type Fetcher = res.Fetcher
+
+// FetcherFunc is a function used as a Fetcher
+type FetcherFunc = res.FetcherFunc
+
+// ResolveFunc rewrites the URL of a resource, resolved against the
+// document, before it is loaded, such as to sign it
+type ResolveFunc = res.ResolveFunc
+
+// DiskCache is a ResourceCache keeping resources as files in a directory
+type DiskCache = res.DiskCache
+
+// NewDiskCache creates a ResourceCache in dir, which is created if needed.
+// Entries are used for ttl after they were downloaded, for ever when ttl is
+// 0, and the oldest are removed when they take more than maxSize bytes, 0
+// for no limit.
+func NewDiskCache(dir string, ttl time.Duration, maxSize int64) (*DiskCache, error) {
+	return res.NewDiskCache(dir, ttl, maxSize)
+}
+
 // Options represents configuration options for the HTML to PDF converter
 type Options struct {
 	// Page dimensions
@@ -13,10 +79,20 @@ type Options struct {
 	MarginRight  float64
 	MarginBottom float64
 	MarginLeft   float64
+	// FirstPageMargins, when set, replace the page margins on the first page
+	FirstPageMargins *PageMargins
+	// MirrorMargins swaps the left and right margins on left-hand (even)
+	// pages for duplex printing, making MarginLeft the inner margin.
+	// @page rules of the document's stylesheets, including those for
+	// :first, :left and :right pages, override these margins side by side.
+	MirrorMargins bool
 
 	// Rendering options
 	DPI   float64
 	Debug bool
+	// Logger receives warnings and debug output. When nil, output is
+	// discarded unless Debug is set, in which case it goes to standard error.
+	Logger Logger
 
 	// Visual rendering toggles
 	// When false, backgrounds will not be painted
@@ -25,6 +101,48 @@ type Options struct {
 	RenderBorders bool
 	// When true, draw debug box overlays (outlines and placeholder backgrounds/labels)
 	DebugDrawBoxes bool
+	// When true, JPEG images are drawn as stored instead of turned upright
+	// by their EXIF orientation, as image-orientation: none does for an
+	// element
+	IgnoreImageOrientation bool
+
+	// Output size options
+	// CompressionLevel is the zlib compression level of the streams of the
+	// document, from 1 (fastest) to 9 (smallest); CompressionDefault
+	// compresses for speed and CompressionNone leaves the content of pages
+	// uncompressed, for reading it
+	CompressionLevel CompressionLevel
+	// MaxImageDPI, when above 0, scales raster images down to that many
+	// pixels per inch of the size they are drawn at, such as 150 for
+	// documents sent by email and 300 for print
+	MaxImageDPI float64
+	// JPEGQuality, from 1 to 100, compresses JPEG images again at that
+	// quality, keeping those it does not make smaller. 0 keeps them as they
+	// are unless they are scaled or turned, which encodes them at 92.
+	JPEGQuality int
+	// When true, the default, fonts are embedded with the glyphs of the
+	// characters drawn only; when false every glyph of the faces drawn with
+	// is embedded, so that the document can be edited or its form fields
+	// filled with any character
+	SubsetFonts bool
+
+	// Pagination options
+	// When true, a table's <thead> rows are repeated at the top of every page the table continues on
+	RepeatTableHeaders bool
+	// MinTableRows is the fewest body rows a table keeps with its <thead>
+	// at the bottom of a page; a table that would break sooner starts on
+	// the next page. 0 lets a header end a page.
+	MinTableRows int
+	// Streaming has the document laid out, cut into pages and drawn a few
+	// pages at a time, so that the memory its boxes take depends on the size
+	// of its pages rather than on that of the document. Documents whose
+	// generated content shows the page count or the page of another element
+	// are laid out twice, the first time to count their pages.
+	Streaming bool
+	// MaxPages is the most pages a document may have; conversions of longer
+	// documents fail with ErrTooManyPages. 0 allows 10000 pages and a
+	// negative value any number.
+	MaxPages int
 
 	// Testing options
 	UseSampleContent bool
@@ -32,17 +150,331 @@ type Options struct {
 	// Resource paths
 	ResourcePaths   []string
 	FontDirectories []string
+	// ResourceConcurrency is how many of the images, stylesheets and fonts
+	// of a document are fetched at the same time before layout; 1 fetches
+	// them one after the other
+	ResourceConcurrency int
+	// ResourceTimeout limits how long fetching a remote resource may take;
+	// 0 for no limit
+	ResourceTimeout time.Duration
+	// ResourceCache, when set, keeps remote images, fonts and stylesheets
+	// across conversions
+	ResourceCache ResourceCache
+	// HTTPClient requests remote documents and resources, such as through a
+	// proxy or a rate limiting transport; a default client when nil
+	HTTPClient *http.Client
+	// HTTPHeaders are sent with every remote request, whichever host it goes
+	// to, such as User-Agent or Authorization
+	HTTPHeaders http.Header
+	// Cookies are sent with remote requests. A cookie with a Domain goes to
+	// that domain and its subdomains; one without goes to the host of the
+	// document only.
+	Cookies []*http.Cookie
+	// ResourceFS, when set, holds the files conversions read in place of
+	// the file system, such as files embedded with go:embed: the documents
+	// of ConvertFile, ConvertFiles, ConvertMarkdownFile and
+	// ConvertTemplateFile and the local resources of documents. Paths are
+	// looked up without their leading slash. Font directories and output
+	// files stay on the file system.
+	ResourceFS fs.FS
+	// ResolveURL, when set, rewrites the URLs of resources, and of the
+	// document of ConvertURL, before they are loaded
+	ResolveURL ResolveFunc
+	// Fetchers load the URLs of schemes, by lower case scheme such as
+	// "asset". Relative URLs in documents and stylesheets loaded by a
+	// fetcher resolve against their URL. A fetcher for "http" or "https"
+	// replaces the HTTP client and ResourceCache for those URLs.
+	Fetchers map[string]Fetcher
+	// ResourcePolicy, when set, restricts the resources conversions load,
+	// the document of ConvertURL included. Services converting documents of
+	// their users should set one blocking private networks.
+	ResourcePolicy *ResourcePolicy
+	// FallbackFonts lists font families, in order of preference, used for
+	// characters the requested font has no glyph for
+	FallbackFonts []string
+	// FontFallbacks lists font families per Unicode script, tried in order
+	// before FallbackFonts for characters of that script the requested font
+	// has no glyph for. Scripts are named as in the unicode package, such as
+	// "Han", "Hiragana", "Arabic" or "Devanagari".
+	FontFallbacks map[string][]string
+
+	// Watermark, when set, is stamped on every page
+	Watermark *Watermark
+	// Letterhead, when set, is stationery the pages are printed on: pages
+	// of an existing PDF document drawn beneath their content
+	Letterhead *Letterhead
+	// AppendedPages are pages of existing PDF documents added after the
+	// pages of the document, each at its own size, such as standard terms
+	// and conditions. They are not counted by page counters. <img> elements
+	// place pages of PDF documents in the flow of the document instead.
+	AppendedPages []PDFPages
+	// PDFVersion, when set, is the version of PDF the document is written
+	// as. Features the version lacks are left out where the document looks
+	// the same without them: images are flattened onto white before PDF
+	// 1.4, which added transparency, and watermark text is mixed with white
+	// instead. Conversion fails for features that cannot be left out, such
+	// as an output intent in PDF 1.3. Without it the document has the
+	// version of the features it uses.
+	PDFVersion PDFVersion
+	// OutputIntent, when set, embeds the ICC color profile of the device
+	// the document is meant for as its output intent, as print vendors and
+	// PDF/A require
+	OutputIntent *OutputIntent
+	// Attachments are files embedded in the document, such as the XML of an
+	// electronic invoice
+	Attachments []Attachment
+	// PageLabels starts sections of page numbering, such as roman numbers
+	// for the front matter. They set the labels PDF viewers show for pages
+	// and the value of counter(page) in generated content.
+	PageLabels []PageLabel
+	// TableOfContents inserts a table of contents at the start of the body
+	// when the document has no <nav id="toc"> element, which is always
+	// filled. It lists headings down to TOCDepth (3 by default) with their
+	// page numbers, under the title TOCTitle ("Contents" by default).
+	TableOfContents bool
+	TOCTitle        string
+	TOCDepth        int
+	// HeaderTemplate and FooterTemplate are HTML fragments shown centered in
+	// the top and bottom margin of every page. Elements of class pageNumber
+	// and totalPages in them show the page number and the page count.
+	// Margin boxes of the document's @page rules override them.
+	HeaderTemplate string
+	FooterTemplate string
+
+	// Bookmarks adds a bookmark for every h1 to h6 heading to the outline
+	// PDF viewers show beside the pages, nested by heading level
+	Bookmarks bool
+	// FormFields makes the text fields, checkboxes, radio buttons and lists
+	// of HTML forms fillable fields of a PDF form, named by their name
+	// attribute. Otherwise they are only drawn.
+	FormFields bool
 
 	// Document metadata
 	Title    string
 	Author   string
 	Subject  string
 	Keywords string
+	// CreationDate, when set, is the creation and modification date of the
+	// document instead of the time of the conversion
+	CreationDate time.Time
+	// Reproducible makes converting the same input write the same bytes, as
+	// golden file tests and build caches need. Without a CreationDate the
+	// document is dated by the SOURCE_DATE_EPOCH environment variable,
+	// seconds since 1970 as for reproducible builds, or else 1 January 1970.
+	Reproducible bool
 
 	// Default stylesheets
 	UserAgentStylesheet string
+	// MediaType is the media type @media rules are evaluated for, "print"
+	// by default. Width and height media features refer to the page size.
+	MediaType string
+	// MaxImportDepth limits how many levels of nested @import rules are
+	// followed; 0 ignores @import
+	MaxImportDepth int
+
+	// MarkdownRenderer converts the Markdown of ConvertMarkdown to HTML,
+	// NewMarkdownRenderer when nil
+	MarkdownRenderer MarkdownRenderer
+	// MarkdownStylesheet styles documents converted from Markdown,
+	// DefaultMarkdownStylesheet when empty
+	MarkdownStylesheet string
+
+	// Hooks are called as a conversion progresses
+	Hooks Hooks
+}
+
+// Watermark is text and/or an image stamped on every page, such as a DRAFT
+// or CONFIDENTIAL mark
+type Watermark struct {
+	// Text is drawn in FontFamily (a CSS font-family list, Helvetica by
+	// default) at FontSize points (72 by default) in Color (a CSS color,
+	// gray by default)
+	Text       string
+	FontFamily string
+	FontSize   float64
+	Bold       bool
+	Color      string
+	// Image is the path or URL of an image, resolved like <img src>. It is
+	// drawn Width by Height points; with one of them set the other keeps
+	// the aspect ratio, with neither the image has its natural size.
+	Image  string
+	Width  float64
+	Height float64
+	// Opacity ranges from 0 to 1; 0 means the default of 0.25
+	Opacity float64
+	// Rotation turns the watermark counterclockwise by degrees around its
+	// center
+	Rotation float64
+	// Position places the watermark on the page, centered by default
+	Position WatermarkPosition
+	// Above draws the watermark over the page content instead of beneath it
+	Above bool
+}
+
+// WatermarkPosition places a watermark on the page
+type WatermarkPosition string
+
+const (
+	WatermarkCenter      WatermarkPosition = "center"
+	WatermarkTop         WatermarkPosition = "top"
+	WatermarkBottom      WatermarkPosition = "bottom"
+	WatermarkLeft        WatermarkPosition = "left"
+	WatermarkRight       WatermarkPosition = "right"
+	WatermarkTopLeft     WatermarkPosition = "top-left"
+	WatermarkTopRight    WatermarkPosition = "top-right"
+	WatermarkBottomLeft  WatermarkPosition = "bottom-left"
+	WatermarkBottomRight WatermarkPosition = "bottom-right"
+)
+
+// Letterhead is stationery the pages of a PDF document are printed on, such
+// as a company letterhead: a page of an existing PDF document drawn beneath
+// the content of the first page, and optionally another beneath the content
+// of the others. Pages are scaled to fit the page and centered on it. Page
+// images do not show the letterhead.
+type Letterhead struct {
+	// Source is the path or URL of the PDF document, resolved like
+	// <img src>; Data holds the document instead. Page is the page drawn
+	// beneath the first page, from 1; 0 means 1.
+	Source string
+	Data   []byte
+	Page   int
+	// ContinuationSource, ContinuationData and ContinuationPage give the
+	// stationery of the other pages in the same way. Without a document of
+	// its own it is a page of the letterhead's document, such as page 2 of
+	// a letterhead with a continuation sheet; with none of them set the
+	// other pages have no stationery.
+	ContinuationSource string
+	ContinuationData   []byte
+	ContinuationPage   int
+}
+
+// PDFPages are pages of an existing PDF document
+type PDFPages struct {
+	// Source is the path or URL of the PDF document, resolved like
+	// <img src>; Data holds the document instead
+	Source string
+	Data   []byte
+	// Pages are the numbers of the pages, from 1, in order; all pages when
+	// empty
+	Pages []int
+}
+
+// OutputIntent is the color profile of the device a PDF document is meant
+// for: the colors of the document are taken to be in it by print vendors
+// and PDF/A readers. The content is not converted to the profile.
+type OutputIntent struct {
+	// Source is the path or URL of an ICC profile of a gray, RGB or CMYK
+	// device, resolved like <img src>; Profile holds the profile instead.
+	// Without either the sRGB IEC61966-2.1 profile is embedded, the color
+	// space of CSS colors.
+	Source  string
+	Profile []byte
+	// Subtype is the standard the output intent is for; OutputIntentPDFA
+	// by default
+	Subtype OutputIntentSubtype
+	// Identifier names the output condition, such as "FOGRA39" for a
+	// condition of the ICC registry, and RegistryName the registry that
+	// defines it, such as "http://www.color.org". Condition and Info
+	// describe the condition for people. Identifier and Info default to the
+	// description of the profile.
+	Identifier   string
+	RegistryName string
+	Condition    string
+	Info         string
+}
+
+// OutputIntentSubtype is the standard an output intent is for
+type OutputIntentSubtype string
+
+const (
+	// OutputIntentPDFA is the output intent of PDF/A documents
+	OutputIntentPDFA OutputIntentSubtype = "GTS_PDFA1"
+	// OutputIntentPDFX is the output intent of PDF/X documents, the
+	// exchange format of print
+	OutputIntentPDFX OutputIntentSubtype = "GTS_PDFX"
+)
+
+// Attachment is a file embedded in the PDF document
+type Attachment struct {
+	// Name is the file name PDF viewers show, such as "factur-x.xml"
+	Name        string
+	Description string
+	// MIMEType is the media type of Data, such as "text/xml"
+	MIMEType string
+	// Relationship relates the file to the document as a PDF/A-3
+	// associated file, as e-invoicing standards such as ZUGFeRD and
+	// Factur-X require. Files without one are plain attachments.
+	Relationship AttachmentRelationship
+	Data         []byte
+}
+
+// AttachmentRelationship is the relation of an attached file to the document
+type AttachmentRelationship string
+
+const (
+	// AttachmentSource is the source the document was created from
+	AttachmentSource AttachmentRelationship = "Source"
+	// AttachmentData holds data the document's content is based on
+	AttachmentData AttachmentRelationship = "Data"
+	// AttachmentAlternative is an alternative representation of the
+	// document, such as the machine-readable XML of an invoice
+	AttachmentAlternative AttachmentRelationship = "Alternative"
+	// AttachmentSupplement supplements the document
+	AttachmentSupplement AttachmentRelationship = "Supplement"
+	// AttachmentUnspecified has no specified relation to the document
+	AttachmentUnspecified AttachmentRelationship = "Unspecified"
+)
+
+// PageMargins are the margins of a page in points
+type PageMargins struct {
+	Top    float64
+	Right  float64
+	Bottom float64
+	Left   float64
 }
 
+// PageLabel starts a section of page numbering
+type PageLabel struct {
+	// Page is the 1-based index of the page the section starts on. When
+	// Name is set instead, the section starts on every page where the CSS
+	// named page Name (the page property) begins and lasts as long as it.
+	Page int
+	Name string
+	// Style is the list-style-type numbers are written in: decimal (the
+	// default), lower-roman, upper-roman, lower-alpha, upper-alpha or none
+	Style string
+	// Prefix is written before the number, such as "A-"
+	Prefix string
+	// Start is the number of the section's first page, 1 when 0
+	Start int
+}
+
+// CompressionLevel is the zlib compression level of the streams of a PDF
+// document, from 1 (fastest) to 9 (smallest)
+type CompressionLevel int
+
+const (
+	// CompressionDefault compresses for speed
+	CompressionDefault CompressionLevel = 0
+	// CompressionNone leaves the content of pages uncompressed; fonts and
+	// images stay compressed
+	CompressionNone CompressionLevel = -1
+	// CompressionBest compresses the most, taking the longest
+	CompressionBest CompressionLevel = 9
+)
+
+// PDFVersion is a version of PDF a document is written as
+type PDFVersion string
+
+const (
+	PDFVersion13 PDFVersion = "1.3"
+	PDFVersion14 PDFVersion = "1.4"
+	PDFVersion15 PDFVersion = "1.5"
+	PDFVersion16 PDFVersion = "1.6"
+	PDFVersion17 PDFVersion = "1.7"
+	PDFVersion20 PDFVersion = "2.0"
+)
+
 // Option is a function that modifies Options
 type Option func(*Options)
 
@@ -82,9 +514,22 @@ func DefaultOptions() Options {
 		RenderBorders:     false,
 		DebugDrawBoxes:    false,
 
+		// Default pagination behavior
+		RepeatTableHeaders: true,
+		MinTableRows:       1,
+
+		// Default output size
+		SubsetFonts: true,
+
+		// Default outline
+		Bookmarks: true,
+
 		// Default resource paths
-		ResourcePaths:   []string{},
-		FontDirectories: []string{},
+		ResourcePaths:       []string{},
+		FontDirectories:     []string{},
+		FallbackFonts:       []string{},
+		FontFallbacks:       map[string][]string{},
+		ResourceConcurrency: 6,
 
 		// Default document metadata
 		Title:    "",
@@ -94,6 +539,8 @@ func DefaultOptions() Options {
 
 		// Default user agent stylesheet
 		UserAgentStylesheet: defaultUserAgentStylesheet,
+		MediaType:           "print",
+		MaxImportDepth:      8,
 	}
 }
 
@@ -115,6 +562,20 @@ func WithMargins(top, right, bottom, left float64) Option {
 	}
 }
 
+// WithFirstPageMargins sets the margins of the first page
+func WithFirstPageMargins(top, right, bottom, left float64) Option {
+	return func(o *Options) {
+		o.FirstPageMargins = &PageMargins{Top: top, Right: right, Bottom: bottom, Left: left}
+	}
+}
+
+// WithMirrorMargins swaps the left and right margins on left-hand pages
+func WithMirrorMargins(mirror bool) Option {
+	return func(o *Options) {
+		o.MirrorMargins = mirror
+	}
+}
+
 // WithDPI sets the DPI
 func WithDPI(dpi float64) Option {
 	return func(o *Options) {
@@ -129,6 +590,13 @@ func WithDebug(debug bool) Option {
 	}
 }
 
+// WithLogger sets the logger that receives warnings and debug output
+func WithLogger(logger Logger) Option {
+	return func(o *Options) {
+		o.Logger = logger
+	}
+}
+
 // WithResourcePath adds a path to search for resources
 func WithResourcePath(path string) Option {
 	return func(o *Options) {
@@ -136,6 +604,102 @@ func WithResourcePath(path string) Option {
 	}
 }
 
+// WithResourceConcurrency sets how many resources of a document are fetched
+// at the same time; 1 fetches them one after the other
+func WithResourceConcurrency(n int) Option {
+	return func(o *Options) {
+		o.ResourceConcurrency = n
+	}
+}
+
+// WithResourceTimeout limits how long fetching a remote resource may take
+func WithResourceTimeout(timeout time.Duration) Option {
+	return func(o *Options) {
+		o.ResourceTimeout = timeout
+	}
+}
+
+// WithResourceCache sets the cache that keeps remote images, fonts and
+// stylesheets across conversions
+func WithResourceCache(cache ResourceCache) Option {
+	return func(o *Options) {
+		o.ResourceCache = cache
+	}
+}
+
+// WithHTTPClient sets the HTTP client remote documents and resources are
+// requested with
+func WithHTTPClient(client *http.Client) Option {
+	return func(o *Options) {
+		o.HTTPClient = client
+	}
+}
+
+// WithHTTPTransport requests remote documents and resources through a
+// RoundTripper, such as one adding authentication or limiting the rate
+func WithHTTPTransport(transport http.RoundTripper) Option {
+	return func(o *Options) {
+		o.HTTPClient = &http.Client{Transport: transport}
+	}
+}
+
+// WithHTTPHeader adds a header sent with every remote request
+func WithHTTPHeader(key, value string) Option {
+	return func(o *Options) {
+		// The headers of the options this was copied from stay as they are
+		headers := o.HTTPHeaders.Clone()
+		if headers == nil {
+			headers = http.Header{}
+		}
+		headers.Add(key, value)
+		o.HTTPHeaders = headers
+	}
+}
+
+// WithCookie adds a cookie sent with remote requests
+func WithCookie(cookie *http.Cookie) Option {
+	return func(o *Options) {
+		o.Cookies = append(o.Cookies, cookie)
+	}
+}
+
+// WithResourceFS reads input documents and local resources from fsys
+// instead of the file system
+func WithResourceFS(fsys fs.FS) Option {
+	return func(o *Options) {
+		o.ResourceFS = fsys
+	}
+}
+
+// WithResolveURL sets the function rewriting the URLs of resources before
+// they are loaded
+func WithResolveURL(resolve ResolveFunc) Option {
+	return func(o *Options) {
+		o.ResolveURL = resolve
+	}
+}
+
+// WithFetcher sets the fetcher loading the URLs of a scheme, such as
+// "asset" for "asset://logo.png"
+func WithFetcher(scheme string, fetcher Fetcher) Option {
+	return func(o *Options) {
+		// The fetchers of the options this was copied from stay as they are
+		fetchers := maps.Clone(o.Fetchers)
+		if fetchers == nil {
+			fetchers = map[string]Fetcher{}
+		}
+		fetchers[strings.ToLower(scheme)] = fetcher
+		o.Fetchers = fetchers
+	}
+}
+
+// WithResourcePolicy restricts the resources conversions load
+func WithResourcePolicy(policy ResourcePolicy) Option {
+	return func(o *Options) {
+		o.ResourcePolicy = &policy
+	}
+}
+
 // WithFontDirectory adds a directory to search for fonts
 func WithFontDirectory(dir string) Option {
 	return func(o *Options) {
@@ -143,6 +707,130 @@ func WithFontDirectory(dir string) Option {
 	}
 }
 
+// WithFallbackFonts sets the font families used, in order, for characters
+// the requested font cannot display
+func WithFallbackFonts(families ...string) Option {
+	return func(o *Options) {
+		o.FallbackFonts = append([]string(nil), families...)
+	}
+}
+
+// WithFontFallbacks sets the font families used, in order, for characters of
+// a Unicode script the requested font cannot display
+func WithFontFallbacks(script string, families ...string) Option {
+	return func(o *Options) {
+		fallbacks := make(map[string][]string, len(o.FontFallbacks)+1)
+		for k, v := range o.FontFallbacks {
+			fallbacks[k] = v
+		}
+		fallbacks[script] = append([]string(nil), families...)
+		o.FontFallbacks = fallbacks
+	}
+}
+
+// WithWatermark stamps a watermark on every page
+func WithWatermark(watermark Watermark) Option {
+	return func(o *Options) {
+		o.Watermark = &watermark
+	}
+}
+
+// WithTextWatermark stamps text diagonally across the middle of every page,
+// beneath the content, as for a DRAFT mark
+func WithTextWatermark(text string) Option {
+	return WithWatermark(Watermark{Text: text, Bold: true, Rotation: 45})
+}
+
+// WithLetterhead prints the pages on the stationery of a letterhead
+func WithLetterhead(letterhead Letterhead) Option {
+	return func(o *Options) {
+		o.Letterhead = &letterhead
+	}
+}
+
+// WithAppendedPages adds pages of an existing PDF document after the pages
+// of the document, all of them when no page numbers are given
+func WithAppendedPages(source string, pages ...int) Option {
+	return func(o *Options) {
+		o.AppendedPages = append(o.AppendedPages, PDFPages{Source: source, Pages: append([]int(nil), pages...)})
+	}
+}
+
+// WithPDFVersion writes the document as a version of PDF, leaving out the
+// features it lacks
+func WithPDFVersion(version PDFVersion) Option {
+	return func(o *Options) {
+		o.PDFVersion = version
+	}
+}
+
+// WithOutputIntent embeds the ICC profile of the device the document is
+// meant for as its output intent
+func WithOutputIntent(intent OutputIntent) Option {
+	return func(o *Options) {
+		o.OutputIntent = &intent
+	}
+}
+
+// WithSRGBOutputIntent embeds the sRGB profile as the output intent of the
+// document, the color space of CSS colors and of most images
+func WithSRGBOutputIntent() Option {
+	return WithOutputIntent(OutputIntent{})
+}
+
+// WithAttachment embeds a file in the document
+func WithAttachment(attachment Attachment) Option {
+	return func(o *Options) {
+		o.Attachments = append(o.Attachments, attachment)
+	}
+}
+
+// WithPageLabels sets the sections of page numbering
+func WithPageLabels(labels ...PageLabel) Option {
+	return func(o *Options) {
+		o.PageLabels = append([]PageLabel(nil), labels...)
+	}
+}
+
+// WithTableOfContents inserts a table of contents listing the headings
+// from h1 down to h<depth>
+func WithTableOfContents(depth int) Option {
+	return func(o *Options) {
+		o.TableOfContents = true
+		o.TOCDepth = depth
+	}
+}
+
+// WithHeaderTemplate shows an HTML fragment in the top margin of every page
+func WithHeaderTemplate(template string) Option {
+	return func(o *Options) {
+		o.HeaderTemplate = template
+	}
+}
+
+// WithFooterTemplate shows an HTML fragment in the bottom margin of every
+// page
+func WithFooterTemplate(template string) Option {
+	return func(o *Options) {
+		o.FooterTemplate = template
+	}
+}
+
+// WithBookmarks controls whether headings are added to the document outline
+func WithBookmarks(bookmarks bool) Option {
+	return func(o *Options) {
+		o.Bookmarks = bookmarks
+	}
+}
+
+// WithFormFields controls whether form controls become fillable PDF form
+// fields
+func WithFormFields(fields bool) Option {
+	return func(o *Options) {
+		o.FormFields = fields
+	}
+}
+
 // WithTitle sets the document title
 func WithTitle(title string) Option {
 	return func(o *Options) {
@@ -171,6 +859,22 @@ func WithKeywords(keywords string) Option {
 	}
 }
 
+// WithCreationDate sets the creation and modification date of the document
+func WithCreationDate(date time.Time) Option {
+	return func(o *Options) {
+		o.CreationDate = date
+	}
+}
+
+// WithReproducibleOutput makes converting the same input write the same
+// bytes, dating the document by SOURCE_DATE_EPOCH unless a creation date is
+// set
+func WithReproducibleOutput() Option {
+	return func(o *Options) {
+		o.Reproducible = true
+	}
+}
+
 // WithUserAgentStylesheet sets the user agent stylesheet
 func WithUserAgentStylesheet(stylesheet string) Option {
 	return func(o *Options) {
@@ -178,6 +882,44 @@ func WithUserAgentStylesheet(stylesheet string) Option {
 	}
 }
 
+// WithMediaType sets the media type @media rules are evaluated for, such as
+// "print" or "screen"
+func WithMediaType(mediaType string) Option {
+	return func(o *Options) {
+		o.MediaType = mediaType
+	}
+}
+
+// WithMaxImportDepth limits how many levels of nested @import rules are
+// followed; 0 ignores @import
+func WithMaxImportDepth(depth int) Option {
+	return func(o *Options) {
+		o.MaxImportDepth = depth
+	}
+}
+
+// WithMarkdownRenderer sets the renderer converting Markdown to HTML
+func WithMarkdownRenderer(renderer MarkdownRenderer) Option {
+	return func(o *Options) {
+		o.MarkdownRenderer = renderer
+	}
+}
+
+// WithMarkdownStylesheet sets the stylesheet of documents converted from
+// Markdown
+func WithMarkdownStylesheet(stylesheet string) Option {
+	return func(o *Options) {
+		o.MarkdownStylesheet = stylesheet
+	}
+}
+
+// WithHooks sets the functions called as a conversion progresses
+func WithHooks(hooks Hooks) Option {
+	return func(o *Options) {
+		o.Hooks = hooks
+	}
+}
+
 // WithPageOrientation sets the page orientation
 func WithPageOrientation(orientation PageOrientation) Option {
 	return func(o *Options) {
@@ -185,6 +927,77 @@ func WithPageOrientation(orientation PageOrientation) Option {
 	}
 }
 
+// WithRepeatTableHeaders controls whether table headers repeat on continuation pages
+func WithRepeatTableHeaders(repeat bool) Option {
+	return func(o *Options) {
+		o.RepeatTableHeaders = repeat
+	}
+}
+
+// WithMinTableRows sets the fewest body rows a table keeps with its header at
+// the bottom of a page, so that a header is not left alone there; 0 allows it
+func WithMinTableRows(n int) Option {
+	return func(o *Options) {
+		o.MinTableRows = n
+	}
+}
+
+// WithStreaming controls whether documents are laid out, paginated and
+// drawn a few pages at a time instead of all at once
+func WithStreaming(streaming bool) Option {
+	return func(o *Options) {
+		o.Streaming = streaming
+	}
+}
+
+// WithMaxPages sets the most pages a document may have, negative for no
+// limit
+func WithMaxPages(n int) Option {
+	return func(o *Options) {
+		o.MaxPages = n
+	}
+}
+
+// WithIgnoreImageOrientation controls whether JPEG images are drawn as
+// stored instead of turned upright by their EXIF orientation
+func WithIgnoreImageOrientation(ignore bool) Option {
+	return func(o *Options) {
+		o.IgnoreImageOrientation = ignore
+	}
+}
+
+// WithCompressionLevel sets the zlib compression level of the streams of
+// the document. Conversions fail for levels other than CompressionNone,
+// CompressionDefault and 1 to 9.
+func WithCompressionLevel(level CompressionLevel) Option {
+	return func(o *Options) {
+		o.CompressionLevel = level
+	}
+}
+
+// WithMaxImageDPI scales raster images down to at most dpi pixels per inch
+// of the size they are drawn at
+func WithMaxImageDPI(dpi float64) Option {
+	return func(o *Options) {
+		o.MaxImageDPI = dpi
+	}
+}
+
+// WithJPEGQuality compresses JPEG images again at a quality from 1 to 100
+func WithJPEGQuality(quality int) Option {
+	return func(o *Options) {
+		o.JPEGQuality = quality
+	}
+}
+
+// WithSubsetFonts controls whether fonts are embedded with the glyphs of the
+// characters drawn only, instead of whole
+func WithSubsetFonts(subset bool) Option {
+	return func(o *Options) {
+		o.SubsetFonts = subset
+	}
+}
+
 // Standard page sizes in points (1/72 inch)
 const (
 	// A series
@@ -283,6 +1096,16 @@ u {
   text-decoration: underline;
 }
 
+sub {
+  vertical-align: sub;
+  font-size: smaller;
+}
+
+sup {
+  vertical-align: super;
+  font-size: smaller;
+}
+
 a {
   color: #0000EE;
   text-decoration: underline;
@@ -292,14 +1115,37 @@ a:visited {
   color: #551A8B;
 }
 
+[data-ref]::after {
+  content: target-counter(attr(data-ref), page);
+}
+
 table {
   border-collapse: collapse;
   border-spacing: 0;
 }
 
+thead, tbody, tfoot, tr {
+  vertical-align: middle;
+}
+
 th, td {
   padding: 0.2em 0.5em;
   border: 1px solid #000000;
+  vertical-align: inherit;
+}
+
+th {
+  font-weight: bold;
+  text-align: center;
+}
+
+caption {
+  text-align: center;
+}
+
+figure {
+  margin: 1em 40px;
+  break-inside: avoid;
 }
 
 ul, ol {
@@ -315,6 +1161,51 @@ ol {
   list-style-type: decimal;
 }
 
+ul ul, ol ul {
+  list-style-type: circle;
+}
+
+ul ul ul, ul ol ul, ol ul ul, ol ol ul {
+  list-style-type: square;
+}
+
+ul ul, ul ol, ol ul, ol ol {
+  margin-top: 0;
+  margin-bottom: 0;
+}
+
+ol[type="1"], li[type="1"] {
+  list-style-type: decimal;
+}
+
+ol[type="a"], li[type="a"] {
+  list-style-type: lower-alpha;
+}
+
+ol[type="A"], li[type="A"] {
+  list-style-type: upper-alpha;
+}
+
+ol[type="i"], li[type="i"] {
+  list-style-type: lower-roman;
+}
+
+ol[type="I"], li[type="I"] {
+  list-style-type: upper-roman;
+}
+
+ul[type="disc" i], li[type="disc" i] {
+  list-style-type: disc;
+}
+
+ul[type="circle" i], li[type="circle" i] {
+  list-style-type: circle;
+}
+
+ul[type="square" i], li[type="square" i] {
+  list-style-type: square;
+}
+
 li {
   display: list-item;
 }
@@ -323,19 +1214,33 @@ blockquote {
   margin: 1em 40px;
 }
 
+dl {
+  margin: 1em 0;
+}
+
+dd {
+  margin-left: 40px;
+}
+
 pre {
   font-family: monospace;
   white-space: pre;
   margin: 1em 0;
 }
 
-code {
+code, kbd, samp, tt {
   font-family: monospace;
 }
 
 hr {
-  border: 1px solid #000000;
-  margin: 0.5em 0;
+  border: none;
+  border-top: 1px solid #000000;
+  margin: 0.5em auto;
+}
+
+pagebreak {
+  display: block;
+  break-after: page;
 }
 
 img {
@@ -343,6 +1248,47 @@ img {
   height: auto;
 }
 
+input, textarea, select, button {
+  display: inline-block;
+  font-family: Arial, Helvetica, sans-serif;
+  font-size: 13.33px;
+  font-weight: normal;
+  font-style: normal;
+  line-height: normal;
+  color: #000000;
+  text-align: left;
+  text-indent: 0;
+  padding: 1px 2px;
+  border: 1px solid #767676;
+  background-color: #ffffff;
+}
+
+textarea {
+  font-family: monospace;
+}
+
+button, select, input[type="submit" i], input[type="reset" i],
+input[type="button" i], input[type="file" i], input[type="image" i] {
+  box-sizing: border-box;
+  background-color: #efefef;
+}
+
+button, input[type="submit" i], input[type="reset" i],
+input[type="button" i], input[type="file" i], input[type="image" i] {
+  padding: 1px 6px;
+  text-align: center;
+}
+
+select[multiple] {
+  background-color: #ffffff;
+}
+
+input[type="checkbox" i], input[type="radio" i] {
+  box-sizing: border-box;
+  padding: 0;
+  margin: 3px 3px 3px 4px;
+}
+
 @page {
   margin: 0.5in;
 }
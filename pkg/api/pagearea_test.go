@@ -0,0 +1,24 @@
+package api
+
+import "testing"
+
+func TestInvalidPageArea(t *testing.T) {
+	tests := []struct {
+		name      string
+		converter *Converter
+		html      string
+	}{
+		{"zero page", New().SetPageSize(0, 0), "<p>text</p>"},
+		{"negative page", New().SetPageSize(-10, 100), "<p>text</p>"},
+		{"margins", New().SetMargins(500, 72, 500, 72), "<p>text</p>"},
+		{"first page rule", New(), "<style>@page :first { margin-top: 900pt }</style><p>text</p>"},
+	}
+	for _, tt := range tests {
+		if _, err := tt.converter.ConvertBytes([]byte(tt.html)); err == nil {
+			t.Errorf("%s: got no error", tt.name)
+		}
+	}
+	if _, err := New().SetMargins(0, 0, 0, 0).ConvertBytes([]byte("<p>text</p>")); err != nil {
+		t.Errorf("no margins: %v", err)
+	}
+}
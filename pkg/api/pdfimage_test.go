@@ -0,0 +1,35 @@
+package api
+
+import (
+	"bytes"
+	"encoding/base64"
+	"fmt"
+	"io"
+	"testing"
+)
+
+// corruptObjectStreamPDF returns a document without a cross-reference
+// table whose catalog, page tree and page are in an object stream with a
+// negative /First
+func corruptObjectStreamPDF() []byte {
+	stream := "1 0 2 40 3 90 " +
+		"<< /Type /Catalog /Pages 2 0 R >>\n" +
+		"<< /Type /Pages /Kids [3 0 R] /Count 1 >>\n" +
+		"<< /Type /Page /Parent 2 0 R /MediaBox [0 0 200 100] >>\n"
+	var b bytes.Buffer
+	b.WriteString("%PDF-1.5\n")
+	fmt.Fprintf(&b, "4 0 obj\n<< /Type /ObjStm /N 3 /First -100 /Length %d >>\nstream\n%s\nendstream\nendobj\n", len(stream), stream)
+	b.WriteString("trailer\n<< /Root 1 0 R >>\n%%EOF\n")
+	return b.Bytes()
+}
+
+func TestCorruptPDFImage(t *testing.T) {
+	src := "data:application/pdf;base64," + base64.StdEncoding.EncodeToString(corruptObjectStreamPDF())
+	result, err := New().ConvertWithResult(`<p>before</p><img src="`+src+`" width="100" height="50"><p>after</p>`, io.Discard)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(result.Warnings) == 0 {
+		t.Error("got no warning for the corrupt PDF image")
+	}
+}
@@ -0,0 +1,107 @@
+package api
+
+import (
+	"strings"
+
+	"github.com/gompdf/gompdf/internal/layout"
+	"github.com/gompdf/gompdf/internal/parser/css"
+	"github.com/gompdf/gompdf/internal/parser/html"
+	"github.com/gompdf/gompdf/internal/res"
+	"github.com/gompdf/gompdf/internal/style"
+	xhtml "golang.org/x/net/html"
+)
+
+// prefetch fetches resources concurrently into the loader's cache ahead of
+// the stages that use them, which load them one at a time
+func (c *Converter) prefetch(loader *res.Loader, urls []string) {
+	if loader == nil || len(urls) == 0 || c.options.ResourceConcurrency <= 1 {
+		return
+	}
+	loader.Prefetch(urls, c.options.ResourceConcurrency)
+}
+
+// documentResources returns the sources of the images and external
+// stylesheets of a document, as layout and collectDocumentStylesheets load
+// them; images are chosen among their srcset candidates for the page and
+// DPI of options
+func documentResources(root *html.Node, options layout.Options) []string {
+	var urls []string
+	var walk func(n *html.Node)
+	walk = func(n *html.Node) {
+		if n.Type == xhtml.ElementNode {
+			switch strings.ToLower(n.Data) {
+			case "img":
+				if src := layout.ImageSource(n, options); src != "" {
+					urls = append(urls, src)
+				}
+			case "link":
+				if href := attribute(n, "href"); href != "" && strings.Contains(strings.ToLower(attribute(n, "rel")), "stylesheet") {
+					urls = append(urls, href)
+				}
+			}
+		}
+		for child := n.FirstChild; child != nil; child = child.NextSibling {
+			walk(child)
+		}
+	}
+	if root != nil {
+		walk(root)
+	}
+	return urls
+}
+
+// imageOptions returns the layout options choosing the images of srcset
+// attributes: the page size, DPI and media type
+func (c *Converter) imageOptions() layout.Options {
+	width, height, _ := c.pageSize()
+	return layout.Options{Width: width, Height: height, DPI: c.options.DPI, MediaType: c.options.MediaType}
+}
+
+// attribute returns the value of an attribute of n, "" when it has none
+func attribute(n *html.Node, key string) string {
+	for _, a := range n.Attr {
+		if strings.EqualFold(a.Key, key) {
+			return a.Val
+		}
+	}
+	return ""
+}
+
+// fontSources returns the first source of every @font-face rule of sheets;
+// the others are only loaded when it fails
+func fontSources(sheets []*css.Stylesheet) []string {
+	var urls []string
+	for _, sheet := range sheets {
+		for _, face := range sheet.FontFaces() {
+			if len(face.Sources) > 0 {
+				urls = append(urls, face.Sources[0])
+			}
+		}
+	}
+	return urls
+}
+
+// backgroundImages returns the background images of the elements of a
+// document, as the renderer loads them
+func backgroundImages(styles map[*html.Node]style.ComputedStyle) []string {
+	var urls []string
+	for _, st := range styles {
+		if src := cssURL(st["background-image"].Value); src != "" {
+			urls = append(urls, src)
+		}
+	}
+	return urls
+}
+
+// cssURL returns the address of a url() value, "" for other values
+func cssURL(value string) string {
+	v := strings.TrimSpace(value)
+	if !strings.HasPrefix(strings.ToLower(v), "url(") {
+		return ""
+	}
+	end := strings.Index(v, ")")
+	if end < 4 {
+		return ""
+	}
+	return strings.Trim(strings.TrimSpace(v[4:end]), "'\"")
+}
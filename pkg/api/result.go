@@ -0,0 +1,115 @@
+package api
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/gompdf/gompdf/internal/pagination"
+	"github.com/gompdf/gompdf/internal/parser/html"
+)
+
+// Result reports where the content of a converted document ended up, for
+// building external indexes or post-processing the PDF with other tools
+type Result struct {
+	// Pages is the number of pages of the PDF
+	Pages int
+	// Anchors maps the id of every element laid out on a page to where the
+	// element starts
+	Anchors map[string]Location
+	// Warnings are the problems the conversion recovered from, in the order
+	// they occurred, so that CI can fail on unexpected degradations
+	Warnings []Warning
+}
+
+// Location is a rectangle on a page of the PDF
+type Location struct {
+	// Page is the position of the page in the PDF, from 1, and Label the
+	// page number printed on it, such as "iv" in a roman numbered section
+	Page  int
+	Label string
+	// X and Y are the top left corner of the rectangle in points from the
+	// top left corner of the page, and Width and Height its size. For an
+	// element split across pages it covers the part on the first of them.
+	X      float64
+	Y      float64
+	Width  float64
+	Height float64
+}
+
+// ConvertWithResult is like Convert but also reports the page count, where
+// the elements with an id ended up and the warnings of the conversion
+func (c *Converter) ConvertWithResult(htmlContent string, output io.Writer) (*Result, error) {
+	return c.ConvertWithResultContext(context.Background(), htmlContent, output)
+}
+
+// ConvertWithResultContext is like ConvertWithResult but stops with the
+// context's error when the context is cancelled or its deadline passes
+func (c *Converter) ConvertWithResultContext(ctx context.Context, htmlContent string, output io.Writer) (*Result, error) {
+	var result *Result
+	err := writeThroughFile(output, func(path string) error {
+		var err error
+		result, err = c.ConvertToFileWithResultContext(ctx, htmlContent, path)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// ConvertToFileWithResult is like ConvertToFile but also reports the page
+// count, where the elements with an id ended up and the warnings of the
+// conversion
+func (c *Converter) ConvertToFileWithResult(htmlContent, outputPath string) (*Result, error) {
+	return c.ConvertToFileWithResultContext(context.Background(), htmlContent, outputPath)
+}
+
+// ConvertToFileWithResultContext is like ConvertToFileWithResult but stops
+// with the context's error when the context is cancelled or its deadline
+// passes
+func (c *Converter) ConvertToFileWithResultContext(ctx context.Context, htmlContent, outputPath string) (*Result, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	doc, err := html.NewParser().ParseString(htmlContent)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse HTML: %w", err)
+	}
+	return c.convertDocument(ctx, doc, outputPath)
+}
+
+// newResult returns the report on a document whose pages are added to it
+// with addPage
+func newResult() *Result {
+	return &Result{Anchors: make(map[string]Location)}
+}
+
+// addPage reports on the next page of the document. Like the targets of
+// cross references, an id is located at the first box of its element.
+func (r *Result) addPage(page *pagination.Page) {
+	r.Pages++
+	for _, box := range page.Boxes {
+		node := box.GetNode()
+		if node == nil {
+			continue
+		}
+		for _, a := range node.Attr {
+			if !strings.EqualFold(a.Key, "id") || a.Val == "" {
+				continue
+			}
+			if _, ok := r.Anchors[a.Val]; ok {
+				continue
+			}
+			r.Anchors[a.Val] = Location{
+				Page:   r.Pages,
+				Label:  page.Label(),
+				X:      box.GetX(),
+				Y:      box.GetY(),
+				Width:  box.GetWidth(),
+				Height: box.GetHeight(),
+			}
+		}
+	}
+}
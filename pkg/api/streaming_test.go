@@ -0,0 +1,117 @@
+package api
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/gompdf/gompdf/internal/pagination"
+	"github.com/gompdf/gompdf/internal/parser/html"
+)
+
+// streamedDocuments are documents whose pages come out the same laid out
+// at once and a few pages at a time
+var streamedDocuments = []struct {
+	name string
+	html string
+}{
+	{"paragraphs", paragraphs(200, "")},
+	{"nested blocks", `<div id="outer" style="border: 2px solid; padding: 10px"><section id="inner" style="margin: 20px 0">` +
+		paragraphs(150, "") + `</section></div><p id="after">after</p>`},
+	{"table", strings.Replace(stripedTable(300, 120), "<table>", `<table id="t">`, 1)},
+	{"floats", `<div style="float: right; width: 100px; height: 300px" id="float"></div>` + paragraphs(120, "")},
+	{"page breaks", paragraphs(30, "") + `<h1 id="chapter" style="break-before: page">Chapter</h1>` + paragraphs(60, "")},
+	{"kept together", paragraphs(40, "") + `<div id="kept" style="break-inside: avoid">` + paragraphs(20, "") + `</div>` + paragraphs(40, "")},
+	{"running header", `<style>@page { @top-center { content: element(header) } } .h { position: running(header) }</style>` +
+		`<div class="h">Header</div>` + paragraphs(150, "") + `<div class="h">Appendix</div>` + paragraphs(50, "")},
+	{"page count", `<style>p.last::after { content: " of " counter(pages) }</style>` + paragraphs(150, "last")},
+	{"target counter", `<style>a::after { content: " on page " target-counter(attr(href), page) }</style>` +
+		`<a href="#p140">end</a>` + paragraphs(150, "")},
+}
+
+// paragraphs returns n numbered paragraphs with ids p1 to pn of the given
+// class
+func paragraphs(n int, class string) string {
+	var b strings.Builder
+	for i := 1; i <= n; i++ {
+		fmt.Fprintf(&b, `<p id="p%d" class="%s">Paragraph %d of text long enough to wrap once the line is full of words.</p>`, i, class, i)
+	}
+	return b.String()
+}
+
+func TestStreamingMatchesWholeLayout(t *testing.T) {
+	dir := t.TempDir()
+	for _, doc := range streamedDocuments {
+		whole, err := New().ConvertToFileWithResult(doc.html, filepath.Join(dir, "whole.pdf"))
+		if err != nil {
+			t.Fatalf("%s: %v", doc.name, err)
+		}
+		streamed, err := New().WithOption(WithStreaming(true)).ConvertToFileWithResult(doc.html, filepath.Join(dir, "streamed.pdf"))
+		if err != nil {
+			t.Fatalf("%s streamed: %v", doc.name, err)
+		}
+		if streamed.Pages != whole.Pages {
+			t.Errorf("%s: got %d pages streamed, want %d", doc.name, streamed.Pages, whole.Pages)
+		}
+		if len(streamed.Anchors) != len(whole.Anchors) {
+			t.Errorf("%s: got %d anchors streamed, want %d", doc.name, len(streamed.Anchors), len(whole.Anchors))
+		}
+		for id, want := range whole.Anchors {
+			got, ok := streamed.Anchors[id]
+			if !ok || got.Page != want.Page || got.Label != want.Label || math.Abs(got.Y-want.Y) > 0.01 || math.Abs(got.Height-want.Height) > 0.01 {
+				t.Errorf("%s: #%s is at %+v streamed, want %+v", doc.name, id, got, want)
+			}
+		}
+		a, err := os.ReadFile(filepath.Join(dir, "whole.pdf"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		b, err := os.ReadFile(filepath.Join(dir, "streamed.pdf"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if doc.name != "page count" && doc.name != "target counter" && len(a) != len(b) {
+			t.Errorf("%s: got a PDF of %d bytes streamed, want %d", doc.name, len(b), len(a))
+		}
+	}
+}
+
+func TestStreamingEmitsPagesDuringLayout(t *testing.T) {
+	// Every section starts with a running element, which tells how far
+	// layout has got when a page is emitted
+	const sections = 40
+	var b strings.Builder
+	b.WriteString(`<style>.h { position: running(header) }</style>`)
+	for i := 0; i < sections; i++ {
+		fmt.Fprintf(&b, `<section><div class="h">Section %d</div>%s</section>`, i, paragraphs(20, ""))
+	}
+	doc, err := html.NewParser().ParseString(b.String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	c := New().conversion(context.Background(), "")
+	d, err := c.prepareDocument(context.Background(), doc, c.loader, c.newFontRegistry())
+	if err != nil {
+		t.Fatal(err)
+	}
+	var laidOut []int
+	err = d.stream(doc, func(page *pagination.Page) error {
+		laidOut = append(laidOut, len(d.layout.RunningElements()))
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(laidOut) < sections {
+		t.Fatalf("got %d pages, want at least %d", len(laidOut), sections)
+	}
+	for k, n := range laidOut[:len(laidOut)/2] {
+		if n >= sections {
+			t.Errorf("page %d was emitted once layout was done, want it emitted with %d of %d sections laid out", k+1, k+2, sections)
+		}
+	}
+}
@@ -0,0 +1,121 @@
+package api
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/gompdf/gompdf/internal/layout"
+	"github.com/gompdf/gompdf/internal/parser/html"
+)
+
+// stripedTable returns a table striped by tr:nth-child with a header of two
+// rows and rows body rows, numbered by their data-i attribute. Row tall is
+// taller than a page.
+func stripedTable(rows, tall int) string {
+	var b strings.Builder
+	b.WriteString(`<style>tr:nth-child(even) { background-color: #cccccc }</style>`)
+	b.WriteString(`<table><thead><tr data-i="1"><th>A</th></tr><tr data-i="2"><th>B</th></tr></thead><tbody>`)
+	for i := 1; i <= rows; i++ {
+		style := ""
+		if i == tall {
+			style = ` style="height: 1200px"`
+		}
+		fmt.Fprintf(&b, `<tr data-i="%d"><td%s>row %d</td></tr>`, i, style, i)
+	}
+	b.WriteString(`</tbody></table>`)
+	return b.String()
+}
+
+// rowIndex returns the data-i attribute of a row
+func rowIndex(n *html.Node) int {
+	for _, a := range n.Attr {
+		if a.Key == "data-i" {
+			i, _ := strconv.Atoi(a.Val)
+			return i
+		}
+	}
+	return 0
+}
+
+func TestStripesAcrossPages(t *testing.T) {
+	const rows, tall = 120, 50
+	c := NewWithOptions(DefaultOptions()).conversion(context.Background(), "")
+	pages, err := c.paginate(context.Background(), stripedTable(rows, tall), c.loader, c.newFontRegistry())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(pages) < 4 {
+		t.Fatalf("got %d pages, want the table on at least 4", len(pages))
+	}
+
+	// backgrounds holds the background of the odd and even rows of each
+	// section, taken from the first of them met
+	backgrounds := map[string]map[bool]string{"thead": {}, "tbody": {}}
+	// pagesOf counts the pages each body row is on
+	pagesOf := make(map[int]int)
+	last, repeated := 0, 0
+	for k, page := range pages {
+		headerRows := make(map[int]int)
+		first := true
+		for _, box := range page.Boxes {
+			bb, ok := box.(*layout.BlockBox)
+			if !ok || bb.Node == nil || bb.Node.Data != "tr" {
+				continue
+			}
+			section := bb.Node.Parent.Data
+			i := rowIndex(bb.Node)
+			bg := bb.Style["background-color"].Value
+			want, ok := backgrounds[section][i%2 == 0]
+			if !ok {
+				backgrounds[section][i%2 == 0] = bg
+			} else if bg != want {
+				t.Errorf("page %d: %s row %d has background %q, want %q as the other rows of its parity", k+1, section, i, bg, want)
+			}
+
+			if section == "thead" {
+				headerRows[i]++
+				continue
+			}
+			pagesOf[i]++
+			// Rows go on in order from page to page, the first of a page
+			// being the last of the previous one when it was split
+			if first && k > 0 && i != last && i != last+1 {
+				t.Errorf("page %d starts with row %d after row %d", k+1, i, last)
+			}
+			first = false
+			last = i
+		}
+		for i, n := range headerRows {
+			if n > 1 {
+				t.Errorf("page %d has header row %d %d times", k+1, i, n)
+			}
+		}
+		if k > 0 && len(headerRows) != 0 && len(headerRows) != 2 {
+			t.Errorf("page %d repeats %d of the 2 header rows", k+1, len(headerRows))
+		}
+		if k > 0 && len(headerRows) > 0 {
+			repeated++
+		}
+	}
+	if repeated < 2 {
+		t.Errorf("the header is repeated on %d pages, want at least 2", repeated)
+	}
+
+	if backgrounds["tbody"][true] == backgrounds["tbody"][false] {
+		t.Errorf("odd and even rows have the same background %q", backgrounds["tbody"][true])
+	}
+	if backgrounds["thead"][true] != backgrounds["tbody"][true] {
+		t.Errorf("even header rows have background %q, want %q", backgrounds["thead"][true], backgrounds["tbody"][true])
+	}
+	for i := 1; i <= rows; i++ {
+		switch n := pagesOf[i]; {
+		case i == tall && n < 2:
+			t.Errorf("row %d taller than a page is on %d page", i, n)
+		case i != tall && n != 1:
+			t.Errorf("row %d is on %d pages", i, n)
+		}
+	}
+}
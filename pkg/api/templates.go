@@ -0,0 +1,127 @@
+package api
+
+import (
+	"strings"
+
+	"github.com/gompdf/gompdf/internal/parser/html"
+)
+
+// pageElementStylesheet repeats elements of class page-header and
+// page-footer, and those with data-repeat="all-pages", in the top and bottom
+// center margin boxes of every page as running elements, also on the pages
+// before them. An element with data-repeat is a footer when it is a
+// <footer>. It comes before the stylesheet of the templates, whose margin
+// boxes win, and those of the document, which can place the elements
+// elsewhere with element(page-header) and element(page-footer).
+const pageElementStylesheet = `
+.page-header,
+[data-repeat="all-pages"] {
+  position: running(page-header);
+}
+
+.page-footer,
+footer[data-repeat="all-pages"] {
+  position: running(page-footer);
+}
+
+@page {
+  @top-center {
+    content: element(page-header, all-pages);
+  }
+  @bottom-center {
+    content: element(page-footer, all-pages);
+  }
+}
+`
+
+// pageTemplateStylesheet shows the header and footer templates as running
+// elements in the top and bottom center margin boxes. Its @page rule comes
+// before those of the document, whose margin boxes win.
+const pageTemplateStylesheet = `
+.gompdf-header {
+  position: running(gompdf-header);
+}
+
+.gompdf-footer {
+  position: running(gompdf-footer);
+}
+
+.gompdf-header .pageNumber::after,
+.gompdf-footer .pageNumber::after {
+  content: counter(page);
+}
+
+.gompdf-header .totalPages::after,
+.gompdf-footer .totalPages::after {
+  content: counter(pages);
+}
+
+@page {
+  @top-center {
+    content: element(gompdf-header);
+  }
+  @bottom-center {
+    content: element(gompdf-footer);
+  }
+}
+`
+
+// insertPageTemplates puts the header and footer templates at the start of
+// the body of a document, wrapped in elements that pageTemplateStylesheet
+// turns into running elements. It reports whether it inserted any.
+func insertPageTemplates(root *html.Node, header, footer string) bool {
+	body := findElement(root, func(n *html.Node) bool { return n.Data == "body" })
+	if body == nil {
+		return false
+	}
+	inserted := false
+	// Prepending the footer first keeps the header first in the document
+	for _, t := range []struct{ class, template string }{
+		{"gompdf-footer", footer},
+		{"gompdf-header", header},
+	} {
+		if t.template == "" {
+			continue
+		}
+		wrapper := parseTemplate(t.class, t.template)
+		if wrapper == nil {
+			continue
+		}
+		prependNode(body, wrapper)
+		inserted = true
+	}
+	return inserted
+}
+
+// parseTemplate parses an HTML fragment into a div of the given class,
+// detached from the document it was parsed in
+func parseTemplate(class, template string) *html.Node {
+	doc, err := html.NewParser().ParseString(`<div class="` + class + `">` + template + `</div>`)
+	if err != nil {
+		return nil
+	}
+	wrapper := findElement(doc.Root, func(n *html.Node) bool {
+		return n.Data == "div" && nodeAttr(n, "class") == class
+	})
+	if wrapper == nil {
+		return nil
+	}
+	wrapper.Parent, wrapper.PrevSibling, wrapper.NextSibling = nil, nil, nil
+	return wrapper
+}
+
+// hasPageElements reports whether a document has elements that
+// pageElementStylesheet repeats on every page
+func hasPageElements(root *html.Node) bool {
+	return findElement(root, func(n *html.Node) bool {
+		if nodeAttr(n, "data-repeat") == "all-pages" {
+			return true
+		}
+		for _, class := range strings.Fields(nodeAttr(n, "class")) {
+			if class == "page-header" || class == "page-footer" {
+				return true
+			}
+		}
+		return false
+	}) != nil
+}
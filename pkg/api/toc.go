@@ -0,0 +1,206 @@
+package api
+
+import (
+	"strconv"
+	"strings"
+
+	"github.com/gompdf/gompdf/internal/parser/html"
+	xhtml "golang.org/x/net/html"
+)
+
+// tocStylesheet styles generated tables of contents. It comes after the user
+// agent stylesheet and before the document's own, which may override it.
+// Entries end with a dot leader and the page number of their heading, which
+// is aligned right in the space its number is measured with.
+const tocStylesheet = `
+nav#toc ol.toc {
+  list-style-type: none;
+  margin: 0;
+  padding-left: 0;
+}
+
+nav#toc li {
+  margin: 0.25em 0;
+}
+
+nav#toc li.toc-level-2 {
+  margin-left: 1.5em;
+}
+
+nav#toc li.toc-level-3 {
+  margin-left: 3em;
+}
+
+nav#toc li.toc-level-4 {
+  margin-left: 4.5em;
+}
+
+nav#toc li.toc-level-5 {
+  margin-left: 6em;
+}
+
+nav#toc li.toc-level-6 {
+  margin-left: 7.5em;
+}
+
+nav#toc a {
+  color: inherit;
+  text-decoration: none;
+}
+
+nav#toc a::after {
+  content: leader(dotted) target-counter(attr(href), page);
+  text-align: right;
+}
+`
+
+// defaultTOCTitle is the heading of inserted tables of contents
+const defaultTOCTitle = "Contents"
+
+// buildTableOfContents fills the <nav id="toc"> element of a document with
+// a list of links to its headings, from h1 down to h<depth>. Without such an
+// element, insert puts one with a title heading at the start of the body.
+// Headings without an id get one so that they can be linked to. It reports
+// whether the document has a table of contents.
+func buildTableOfContents(root *html.Node, insert bool, title string, depth int) bool {
+	if depth <= 0 || depth > 6 {
+		depth = 3
+	}
+	nav := findElement(root, func(n *html.Node) bool {
+		return n.Data == "nav" && nodeAttr(n, "id") == "toc"
+	})
+	if nav == nil {
+		if !insert {
+			return false
+		}
+		body := findElement(root, func(n *html.Node) bool { return n.Data == "body" })
+		if body == nil {
+			return false
+		}
+		if title == "" {
+			title = defaultTOCTitle
+		}
+		nav = newElement("nav", "id", "toc")
+		h := newElement("h2", "class", "toc-title")
+		appendNode(h, &html.Node{Type: xhtml.TextNode, Data: title})
+		appendNode(nav, h)
+		prependNode(body, nav)
+	}
+
+	var headings []*html.Node
+	walkElements(root, func(n *html.Node) bool {
+		if n == nav {
+			return false
+		}
+		if level := headingLevel(n); level > 0 && level <= depth {
+			headings = append(headings, n)
+		}
+		return true
+	})
+
+	list := newElement("ol", "class", "toc")
+	for i, h := range headings {
+		id := nodeAttr(h, "id")
+		if id == "" {
+			id = "toc-" + strconv.Itoa(i+1)
+			h.Attr = append(h.Attr, xhtml.Attribute{Key: "id", Val: id})
+		}
+		li := newElement("li", "class", "toc-level-"+strconv.Itoa(headingLevel(h)))
+		a := newElement("a", "href", "#"+id)
+		appendNode(a, &html.Node{Type: xhtml.TextNode, Data: nodeText(h)})
+		appendNode(li, a)
+		appendNode(list, li)
+	}
+	appendNode(nav, list)
+	return true
+}
+
+// headingLevel returns the level of an h1 to h6 element, 0 for other nodes
+func headingLevel(n *html.Node) int {
+	if n.Type != xhtml.ElementNode || len(n.Data) != 2 || n.Data[0] != 'h' || n.Data[1] < '1' || n.Data[1] > '6' {
+		return 0
+	}
+	return int(n.Data[1] - '0')
+}
+
+// walkElements calls visit for the elements under n in document order,
+// skipping the descendants of those visit returns false for
+func walkElements(n *html.Node, visit func(*html.Node) bool) {
+	for c := n.FirstChild; c != nil; c = c.NextSibling {
+		if c.Type == xhtml.ElementNode && !visit(c) {
+			continue
+		}
+		walkElements(c, visit)
+	}
+}
+
+// findElement returns the first element under n that match reports true for
+func findElement(n *html.Node, match func(*html.Node) bool) *html.Node {
+	var found *html.Node
+	walkElements(n, func(c *html.Node) bool {
+		if found == nil && match(c) {
+			found = c
+		}
+		return found == nil
+	})
+	return found
+}
+
+// nodeAttr returns the value of an attribute of n, "" when it has none
+func nodeAttr(n *html.Node, key string) string {
+	for _, a := range n.Attr {
+		if strings.EqualFold(a.Key, key) {
+			return a.Val
+		}
+	}
+	return ""
+}
+
+// nodeText returns the text content of n with white space collapsed
+func nodeText(n *html.Node) string {
+	var b strings.Builder
+	var collect func(*html.Node)
+	collect = func(n *html.Node) {
+		if n.Type == xhtml.TextNode {
+			b.WriteString(n.Data)
+		}
+		for c := n.FirstChild; c != nil; c = c.NextSibling {
+			collect(c)
+		}
+	}
+	collect(n)
+	return strings.Join(strings.Fields(b.String()), " ")
+}
+
+// newElement returns an element with the given attribute key/value pairs
+func newElement(tag string, attrs ...string) *html.Node {
+	n := &html.Node{Type: xhtml.ElementNode, Data: tag}
+	for i := 0; i+1 < len(attrs); i += 2 {
+		n.Attr = append(n.Attr, xhtml.Attribute{Key: attrs[i], Val: attrs[i+1]})
+	}
+	return n
+}
+
+// appendNode makes child the last child of parent
+func appendNode(parent, child *html.Node) {
+	child.Parent = parent
+	child.PrevSibling = parent.LastChild
+	if parent.LastChild != nil {
+		parent.LastChild.NextSibling = child
+	} else {
+		parent.FirstChild = child
+	}
+	parent.LastChild = child
+}
+
+// prependNode makes child the first child of parent
+func prependNode(parent, child *html.Node) {
+	child.Parent = parent
+	child.NextSibling = parent.FirstChild
+	if parent.FirstChild != nil {
+		parent.FirstChild.PrevSibling = child
+	} else {
+		parent.LastChild = child
+	}
+	parent.FirstChild = child
+}
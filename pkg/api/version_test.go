@@ -0,0 +1,31 @@
+package api
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestPDFVersionWithoutTarget(t *testing.T) {
+	file := Attachment{Name: "data.txt", MIMEType: "text/plain", Data: []byte("data")}
+	related := file
+	related.Relationship = AttachmentAlternative
+	tests := []struct {
+		name   string
+		option Option
+		want   string
+	}{
+		{"no features", WithDebug(false), "%PDF-1.3"},
+		{"output intent", WithSRGBOutputIntent(), "%PDF-1.4"},
+		{"attachment", WithAttachment(file), "%PDF-1.4"},
+		{"attachment relationship", WithAttachment(related), "%PDF-1.7"},
+	}
+	for _, tt := range tests {
+		data, err := New().WithOption(tt.option).ConvertBytes([]byte("<p>text</p>"))
+		if err != nil {
+			t.Fatalf("%s: %v", tt.name, err)
+		}
+		if !strings.HasPrefix(string(data), tt.want) {
+			t.Errorf("%s: header %q, want %q", tt.name, data[:8], tt.want)
+		}
+	}
+}
@@ -0,0 +1,89 @@
+package api
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/gompdf/gompdf/internal/logging"
+	"github.com/gompdf/gompdf/internal/parser/css"
+	"github.com/gompdf/gompdf/internal/parser/html"
+	"github.com/gompdf/gompdf/internal/style"
+	xhtml "golang.org/x/net/html"
+)
+
+// Warning is a problem a conversion recovered from, such as an image that
+// could not be loaded. The warnings of a conversion are in its Result; a
+// Logger implementing WarningLogger receives them as they occur.
+type Warning = logging.Warning
+
+// WarningCode classifies a Warning
+type WarningCode = logging.WarningCode
+
+// WarningLogger is a Logger that receives warnings in structured form
+type WarningLogger = logging.WarningLogger
+
+const (
+	WarningResource        = logging.WarningResource
+	WarningImage           = logging.WarningImage
+	WarningFont            = logging.WarningFont
+	WarningStylesheet      = logging.WarningStylesheet
+	WarningUnknownProperty = logging.WarningUnknownProperty
+	WarningOther           = logging.WarningOther
+)
+
+// propertyChecker reports declarations of properties CSS does not define,
+// which the cascade drops silently, once per property
+type propertyChecker struct {
+	logger logging.Logger
+	seen   map[string]bool
+}
+
+func newPropertyChecker(logger logging.Logger) *propertyChecker {
+	return &propertyChecker{logger: logger, seen: make(map[string]bool)}
+}
+
+// checkStylesheet checks the style rules of a stylesheet. The declarations
+// of at-rules such as @page and @font-face are descriptors and are skipped.
+func (pc *propertyChecker) checkStylesheet(sheet *css.Stylesheet) {
+	for _, rule := range sheet.Rules {
+		if len(rule.Selectors) == 0 || strings.HasPrefix(rule.Selectors[0], "@") {
+			continue
+		}
+		for _, decl := range rule.Declarations {
+			pc.check(decl.Property, "", fmt.Sprintf("in rule %q", strings.Join(rule.Selectors, ", ")))
+		}
+	}
+}
+
+// checkStyleAttributes checks the style attributes of the elements of a
+// document
+func (pc *propertyChecker) checkStyleAttributes(n *html.Node) {
+	if n.Type == xhtml.ElementNode {
+		for _, a := range n.Attr {
+			if !strings.EqualFold(a.Key, "style") {
+				continue
+			}
+			sheet, err := css.NewParser().ParseString("dummy { " + a.Val + " }")
+			if err != nil || len(sheet.Rules) == 0 {
+				continue
+			}
+			for _, decl := range sheet.Rules[0].Declarations {
+				pc.check(decl.Property, n.Path(), "in style attribute")
+			}
+		}
+	}
+	for c := n.FirstChild; c != nil; c = c.NextSibling {
+		pc.checkStyleAttributes(c)
+	}
+}
+
+// check reports property, declared at where, when it is unknown and was not
+// reported before
+func (pc *propertyChecker) check(property, node, where string) {
+	name := strings.ToLower(strings.TrimSpace(property))
+	if name == "" || style.KnownProperty(name) || pc.seen[name] {
+		return
+	}
+	pc.seen[name] = true
+	logging.Warnf(pc.logger, logging.WarningUnknownProperty, node, "Unknown CSS property %q %s", property, where)
+}